@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io"
 	"os/exec"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -15,15 +16,28 @@ func addBackend(name string, back backend) {
 }
 
 func getBackend() (backend, error) {
-	return backends[a.opts.Config.Backend.Type], nil
+	back := backends[a.opts.Config.Backend.Type]
+	if a.opts.Config.Backend.Type == "docker" && a.opts.Config.Backend.DockerHosts != "" {
+		back = newBackendDockerPool(strings.Split(a.opts.Config.Backend.DockerHosts, ","))
+	}
+	if back == nil || a.opts.NoCache || a.opts.Config.Backend.InventoryCacheTTL <= 0 {
+		return back, nil
+	}
+	return newBackendCache(back, a.opts.Config.Backend.InventoryCacheTTL)
+}
+
+// backendShellInterpreted returns true for backends which run node commands through a remote shell (ssh), where shell metacharacters such as ';' must be escaped;
+// docker, kubernetes and mock pass the command arguments to the process as-is
+func backendShellInterpreted() bool {
+	return a.opts.Config.Backend.Type == "aws" || a.opts.Config.Backend.Type == "gcp"
 }
 
 type backendExtra struct {
 	clientType          string    // all: ams|elasticsearch|rest-gateway|VSCode|...
-	cpuLimit            string    // docker only
-	ramLimit            string    // docker only
+	cpuLimit            string    // docker/kubernetes only
+	ramLimit            string    // docker/kubernetes only
 	swapLimit           string    // docker only
-	privileged          bool      // docker only
+	privileged          bool      // docker/kubernetes only
 	exposePorts         []string  // docker only
 	switches            []string  // docker only
 	dockerHostname      bool      // docker only
@@ -152,7 +166,7 @@ type backend interface {
 	// may implement
 	ListSubnets() error
 	// may implement (docker related mostly)
-	CreateNetwork(name string, driver string, subnet string, mtu string) error
+	CreateNetwork(name string, driver string, subnet string, mtu string, parent string) error
 	DeleteNetwork(name string) error
 	PruneNetworks() error
 	ListNetworks(csv bool, writer io.Writer) error
@@ -381,6 +395,9 @@ func checkExecRetcode(err error) int {
 	if err != nil {
 		exiterr, ok := err.(*exec.ExitError)
 		if !ok {
+			if exitcode, ok := err.(interface{ ExitCode() int }); ok {
+				return exitcode.ExitCode()
+			}
 			return 666
 		}
 		return exiterr.Sys().(syscall.WaitStatus).ExitStatus()
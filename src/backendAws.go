@@ -1273,7 +1273,7 @@ func (d *backendAws) Inventory(filterOwner string, inventoryItems []int) (invent
 	return ij, nil
 }
 
-func (d *backendAws) CreateNetwork(name string, driver string, subnet string, mtu string) error {
+func (d *backendAws) CreateNetwork(name string, driver string, subnet string, mtu string, parent string) error {
 	return nil
 }
 func (d *backendAws) DeleteNetwork(name string) error {
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backendCache wraps a backend, caching cluster and node listings on disk for a TTL, so that consecutive commands do not list all instances again;
+// any call which may change instances, templates, networks, security groups or volumes invalidates the whole cache
+type backendCache struct {
+	backend
+	ttl    time.Duration
+	path   string
+	client bool
+	lock   *sync.Mutex
+	file   *backendCacheFile
+}
+
+// backendCacheFile is the cache file; Invalidated allows dropping listings which started before another process invalidated the cache
+type backendCacheFile struct {
+	Invalidated time.Time
+	Entries     map[string]*backendCacheEntry
+}
+
+type backendCacheEntry struct {
+	Updated time.Time
+	Value   json.RawMessage
+}
+
+// backendCacheIdentityEnv lists the environment variables which select the account, region, project, engine or cluster the backend talks to
+var backendCacheIdentityEnv = []string{
+	"AWS_PROFILE", "AWS_DEFAULT_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_ACCESS_KEY_ID", "AWS_CONFIG_FILE", "AWS_SHARED_CREDENTIALS_FILE",
+	"CLOUDSDK_CORE_PROJECT", "GOOGLE_CLOUD_PROJECT", "GCLOUD_PROJECT", "CLOUDSDK_CONFIG", "CLOUDSDK_ACTIVE_CONFIG_NAME", "GOOGLE_APPLICATION_CREDENTIALS",
+	"DOCKER_HOST", "DOCKER_CONTEXT", "DOCKER_CONFIG", "CONTAINER_HOST", "CONTAINER_CONNECTION",
+	"KUBECONFIG",
+}
+
+// backendCacheIdentity returns everything which decides which instances the backend lists: the backend configuration, the identity environment variables
+// and the selected docker context
+func backendCacheIdentity() string {
+	cfg := a.opts.Config.Backend
+	id := []string{cfg.Type, cfg.Region, cfg.AWSProfile, cfg.Project, cfg.ContainerCLI, cfg.DockerHosts, cfg.KubeContext, cfg.KubeNamespace}
+	for _, env := range backendCacheIdentityEnv {
+		id = append(id, env+"="+os.Getenv(env))
+	}
+	id = append(id, "docker-context="+backendCacheDockerContext())
+	return strings.Join(id, "\n")
+}
+
+// backendCacheDockerContext returns the context selected using `docker context use`
+func backendCacheDockerContext() string {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	contents, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return ""
+	}
+	config := struct {
+		CurrentContext string `json:"currentContext"`
+	}{}
+	json.Unmarshal(contents, &config)
+	return config.CurrentContext
+}
+
+// newBackendCache returns the backend wrapped in the inventory cache; the cache file is specific to the backend identity, see backendCacheIdentity
+func newBackendCache(back backend, ttl time.Duration) (backend, error) {
+	rootDir, err := a.aerolabRootDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg := a.opts.Config.Backend
+	id := sha256.Sum256([]byte(backendCacheIdentity()))
+	return &backendCache{
+		backend: back,
+		ttl:     ttl,
+		path:    filepath.Join(rootDir, "cache", "inventory-"+cfg.Type+"-"+hex.EncodeToString(id[:])[:12]+".json"),
+		lock:    new(sync.Mutex),
+	}, nil
+}
+
+func (d *backendCache) WorkOnClients() {
+	d.lock.Lock()
+	d.client = true
+	d.lock.Unlock()
+	d.backend.WorkOnClients()
+}
+
+func (d *backendCache) WorkOnServers() {
+	d.lock.Lock()
+	d.client = false
+	d.lock.Unlock()
+	d.backend.WorkOnServers()
+}
+
+// key returns the cache key of a call, separating client and server listings
+func (d *backendCache) key(call string, args ...string) string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	key := "servers"
+	if d.client {
+		key = "clients"
+	}
+	key += "/" + call
+	for _, arg := range args {
+		key += "/" + arg
+	}
+	return key
+}
+
+// load reads the cache file once; a missing or corrupt file results in an empty cache
+func (d *backendCache) load() {
+	if d.file != nil {
+		return
+	}
+	d.file = &backendCacheFile{}
+	contents, err := os.ReadFile(d.path)
+	if err == nil {
+		json.Unmarshal(contents, d.file)
+	}
+	if d.file.Entries == nil {
+		d.file.Entries = make(map[string]*backendCacheEntry)
+	}
+}
+
+// get fills value from the cache, returning false if the entry is missing or expired
+func (d *backendCache) get(key string, value interface{}) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.load()
+	entry, ok := d.file.Entries[key]
+	if !ok || time.Since(entry.Updated) > d.ttl {
+		return false
+	}
+	return json.Unmarshal(entry.Value, value) == nil
+}
+
+// set stores a value listed at the given start time in the cache and saves the cache file, dropping expired entries;
+// the cache file is read again first, and the value is dropped if the cache was invalidated after the listing started
+func (d *backendCache) set(key string, value interface{}, started time.Time) {
+	contents, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	invalidated := time.Time{}
+	if d.file != nil {
+		invalidated = d.file.Invalidated
+	}
+	d.file = nil
+	d.load()
+	if d.file.Invalidated.Before(invalidated) {
+		d.file.Invalidated = invalidated
+	}
+	if started.Before(d.file.Invalidated) {
+		return
+	}
+	d.file.Entries[key] = &backendCacheEntry{Updated: started, Value: contents}
+	for k, entry := range d.file.Entries {
+		if time.Since(entry.Updated) > d.ttl {
+			delete(d.file.Entries, k)
+		}
+	}
+	d.save()
+}
+
+// save writes the cache file via a temporary file, so that concurrent aerolab processes never read a partial file
+func (d *backendCache) save() {
+	contents, err := json.Marshal(d.file)
+	if err != nil {
+		return
+	}
+	err = os.MkdirAll(filepath.Dir(d.path), 0700)
+	if err != nil {
+		log.Printf("WARNING: could not create inventory cache directory: %s", err)
+		return
+	}
+	tmp := d.path + "." + strconv.Itoa(os.Getpid())
+	err = os.WriteFile(tmp, contents, 0600)
+	if err != nil {
+		log.Printf("WARNING: could not write inventory cache: %s", err)
+		return
+	}
+	if err = os.Rename(tmp, d.path); err != nil {
+		os.Remove(tmp)
+		log.Printf("WARNING: could not write inventory cache: %s", err)
+	}
+}
+
+// invalidate drops all cached entries and records the invalidation time, so that listings which are still running are not cached afterwards
+func (d *backendCache) invalidate() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.file = &backendCacheFile{
+		Invalidated: time.Now(),
+		Entries:     make(map[string]*backendCacheEntry),
+	}
+	d.save()
+}
+
+func (d *backendCache) ClusterList() ([]string, error) {
+	key := d.key("ClusterList")
+	ret := []string{}
+	if d.get(key, &ret) {
+		return ret, nil
+	}
+	started := time.Now()
+	ret, err := d.backend.ClusterList()
+	if err == nil {
+		d.set(key, ret, started)
+	}
+	return ret, err
+}
+
+func (d *backendCache) NodeListInCluster(name string) ([]int, error) {
+	key := d.key("NodeListInCluster", name)
+	ret := []int{}
+	if d.get(key, &ret) {
+		return ret, nil
+	}
+	started := time.Now()
+	ret, err := d.backend.NodeListInCluster(name)
+	if err == nil {
+		d.set(key, ret, started)
+	}
+	return ret, err
+}
+
+func (d *backendCache) GetClusterNodeIps(name string) ([]string, error) {
+	key := d.key("GetClusterNodeIps", name)
+	ret := []string{}
+	if d.get(key, &ret) {
+		return ret, nil
+	}
+	started := time.Now()
+	ret, err := d.backend.GetClusterNodeIps(name)
+	if err == nil {
+		d.set(key, ret, started)
+	}
+	return ret, err
+}
+
+func (d *backendCache) GetNodeIpMap(name string, internalIPs bool) (map[int]string, error) {
+	key := d.key("GetNodeIpMap", name, strconv.FormatBool(internalIPs))
+	ret := make(map[int]string)
+	if d.get(key, &ret) {
+		return ret, nil
+	}
+	started := time.Now()
+	ret, err := d.backend.GetNodeIpMap(name, internalIPs)
+	if err == nil {
+		d.set(key, ret, started)
+	}
+	return ret, err
+}
+
+func (d *backendCache) IsNodeArm(clusterName string, nodeNumber int) (bool, error) {
+	key := d.key("IsNodeArm", clusterName, strconv.Itoa(nodeNumber))
+	var ret bool
+	if d.get(key, &ret) {
+		return ret, nil
+	}
+	started := time.Now()
+	ret, err := d.backend.IsNodeArm(clusterName, nodeNumber)
+	if err == nil {
+		d.set(key, ret, started)
+	}
+	return ret, err
+}
+
+func (d *backendCache) DeployCluster(v backendVersion, name string, nodeCount int, extra *backendExtra) error {
+	defer d.invalidate()
+	return d.backend.DeployCluster(v, name, nodeCount, extra)
+}
+
+func (d *backendCache) ClusterStart(name string, nodes []int) error {
+	defer d.invalidate()
+	return d.backend.ClusterStart(name, nodes)
+}
+
+func (d *backendCache) ClusterStop(name string, nodes []int) error {
+	defer d.invalidate()
+	return d.backend.ClusterStop(name, nodes)
+}
+
+func (d *backendCache) ClusterDestroy(name string, nodes []int) error {
+	defer d.invalidate()
+	return d.backend.ClusterDestroy(name, nodes)
+}
+
+func (d *backendCache) SetLabel(clusterName string, key string, value string, gcpZone string) error {
+	defer d.invalidate()
+	return d.backend.SetLabel(clusterName, key, value, gcpZone)
+}
+
+func (d *backendCache) DeployTemplate(v backendVersion, script string, files []fileListReader, extra *backendExtra) error {
+	defer d.invalidate()
+	return d.backend.DeployTemplate(v, script, files, extra)
+}
+
+func (d *backendCache) TemplateDestroy(v backendVersion) error {
+	defer d.invalidate()
+	return d.backend.TemplateDestroy(v)
+}
+
+func (d *backendCache) VacuumTemplates() error {
+	defer d.invalidate()
+	return d.backend.VacuumTemplates()
+}
+
+func (d *backendCache) VacuumTemplate(v backendVersion) error {
+	defer d.invalidate()
+	return d.backend.VacuumTemplate(v)
+}
+
+func (d *backendCache) ClusterExpiry(zone string, clusterName string, expiry time.Duration, nodes []int) error {
+	defer d.invalidate()
+	return d.backend.ClusterExpiry(zone, clusterName, expiry, nodes)
+}
+
+func (d *backendCache) AttachAndRun(clusterName string, node int, command []string, isInteractive bool) error {
+	// commands run in a shell may power off or reconfigure the node
+	defer d.invalidate()
+	return d.backend.AttachAndRun(clusterName, node, command, isInteractive)
+}
+
+func (d *backendCache) RunCustomOut(clusterName string, node int, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer, isInteractive bool) error {
+	defer d.invalidate()
+	return d.backend.RunCustomOut(clusterName, node, command, stdin, stdout, stderr, isInteractive)
+}
+
+func (d *backendCache) DeleteSecurityGroups(vpc string, namePrefix string, internal bool) error {
+	defer d.invalidate()
+	return d.backend.DeleteSecurityGroups(vpc, namePrefix, internal)
+}
+
+func (d *backendCache) CreateSecurityGroups(vpc string, namePrefix string) error {
+	defer d.invalidate()
+	return d.backend.CreateSecurityGroups(vpc, namePrefix)
+}
+
+func (d *backendCache) LockSecurityGroups(ip string, lockSSH bool, vpc string, namePrefix string) error {
+	defer d.invalidate()
+	return d.backend.LockSecurityGroups(ip, lockSSH, vpc, namePrefix)
+}
+
+func (d *backendCache) AssignSecurityGroups(clusterName string, names []string, vpcOrZone string, remove bool) error {
+	defer d.invalidate()
+	return d.backend.AssignSecurityGroups(clusterName, names, vpcOrZone, remove)
+}
+
+func (d *backendCache) CreateNetwork(name string, driver string, subnet string, mtu string, parent string) error {
+	defer d.invalidate()
+	return d.backend.CreateNetwork(name, driver, subnet, mtu, parent)
+}
+
+func (d *backendCache) DeleteNetwork(name string) error {
+	defer d.invalidate()
+	return d.backend.DeleteNetwork(name)
+}
+
+func (d *backendCache) PruneNetworks() error {
+	defer d.invalidate()
+	return d.backend.PruneNetworks()
+}
+
+func (d *backendCache) CreateVolume(name string, zone string, tags []string, expires time.Duration) error {
+	defer d.invalidate()
+	return d.backend.CreateVolume(name, zone, tags, expires)
+}
+
+func (d *backendCache) TagVolume(fsId string, tagName string, tagValue string) error {
+	defer d.invalidate()
+	return d.backend.TagVolume(fsId, tagName, tagValue)
+}
+
+func (d *backendCache) DeleteVolume(name string) error {
+	defer d.invalidate()
+	return d.backend.DeleteVolume(name)
+}
+
+func (d *backendCache) CreateMountTarget(volume *inventoryVolume, subnet string, secGroups []string) (inventoryMountTarget, error) {
+	defer d.invalidate()
+	return d.backend.CreateMountTarget(volume, subnet, secGroups)
+}
+
+func (d *backendCache) MountTargetAddSecurityGroup(mountTarget *inventoryMountTarget, volume *inventoryVolume, addGroups []string) error {
+	defer d.invalidate()
+	return d.backend.MountTargetAddSecurityGroup(mountTarget, volume, addGroups)
+}
+
+func (d *backendCache) ExpiriesSystemInstall(intervalMinutes int, deployRegion string) error {
+	defer d.invalidate()
+	return d.backend.ExpiriesSystemInstall(intervalMinutes, deployRegion)
+}
+
+func (d *backendCache) ExpiriesSystemRemove(region string) error {
+	defer d.invalidate()
+	return d.backend.ExpiriesSystemRemove(region)
+}
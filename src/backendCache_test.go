@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMockBackendCache(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "mydc", "2")
+	if _, ok := b.(*backendCache); ok {
+		t.Fatal("the inventory cache must be disabled by default")
+	}
+	a.opts.Config.Backend.InventoryCacheTTL = time.Minute
+	defer func() { a.opts.Config.Backend.InventoryCacheTTL = 0 }()
+	if back, _ := getBackend(); reflect.TypeOf(back) != reflect.TypeOf(&backendCache{}) {
+		t.Fatalf("expected the backend to be wrapped in the inventory cache, got %T", back)
+	}
+
+	cache, err := newBackendCache(m, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusters, _ := cache.ClusterList()
+	if !reflect.DeepEqual(clusters, []string{"mydc"}) {
+		t.Fatalf("unexpected cluster list %v", clusters)
+	}
+	ips, _ := cache.GetNodeIpMap("mydc", false)
+
+	// changes made behind the cache's back are not seen until the cache is invalidated, also by another aerolab process
+	if err := m.DeployCluster(mockVersion, "other", 1, &backendExtra{}); err != nil {
+		t.Fatal(err)
+	}
+	cache, _ = newBackendCache(m, time.Minute)
+	if clusters, _ = cache.ClusterList(); !reflect.DeepEqual(clusters, []string{"mydc"}) {
+		t.Errorf("expected cached cluster list, got %v", clusters)
+	}
+	if cached, _ := cache.GetNodeIpMap("mydc", false); !reflect.DeepEqual(cached, ips) {
+		t.Errorf("expected cached ip map %v, got %v", ips, cached)
+	}
+	cache.WorkOnClients()
+	if clusters, _ = cache.ClusterList(); len(clusters) != 0 {
+		t.Errorf("client listing served from server cache: %v", clusters)
+	}
+	cache.WorkOnServers()
+
+	if err := cache.SetLabel("mydc", "team", "db", ""); err != nil {
+		t.Fatal(err)
+	}
+	if clusters, _ = cache.ClusterList(); !reflect.DeepEqual(clusters, []string{"mydc", "other"}) {
+		t.Errorf("cache not invalidated after SetLabel: %v", clusters)
+	}
+	if err := cache.ClusterDestroy("other", nil); err != nil {
+		t.Fatal(err)
+	}
+	if clusters, _ = cache.ClusterList(); !reflect.DeepEqual(clusters, []string{"mydc"}) {
+		t.Errorf("cache not invalidated after ClusterDestroy: %v", clusters)
+	}
+
+	// attaching to a node may change it, for example power it off
+	if err := m.DeployCluster(mockVersion, "other", 1, &backendExtra{}); err != nil {
+		t.Fatal(err)
+	}
+	cache.AttachAndRun("mydc", 1, []string{"true"}, false)
+	if clusters, _ = cache.ClusterList(); !reflect.DeepEqual(clusters, []string{"mydc", "other"}) {
+		t.Errorf("cache not invalidated after AttachAndRun: %v", clusters)
+	}
+	m.ClusterDestroy("other", nil)
+
+	// a listing which started before another process invalidated the cache must not be cached
+	started := time.Now()
+	other, _ := newBackendCache(m, time.Minute)
+	other.SetLabel("mydc", "team", "qa", "")
+	stale := cache.(*backendCache)
+	stale.set(stale.key("ClusterList"), []string{"stale"}, started)
+	fresh, _ := newBackendCache(m, time.Minute)
+	if clusters, _ = fresh.ClusterList(); !reflect.DeepEqual(clusters, []string{"mydc"}) {
+		t.Errorf("listing started before the invalidation was cached: %v", clusters)
+	}
+
+	if err := m.DeployCluster(mockVersion, "other", 1, &backendExtra{}); err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := newBackendCache(m, time.Nanosecond)
+	if clusters, _ = expired.ClusterList(); !reflect.DeepEqual(clusters, []string{"mydc", "other"}) {
+		t.Errorf("expired entry served from cache: %v", clusters)
+	}
+
+	a.opts.NoCache = true
+	defer func() { a.opts.NoCache = false }()
+	if back, _ := getBackend(); back != m {
+		t.Errorf("expected the backend not to be wrapped with --no-cache, got %T", back)
+	}
+}
+
+func TestBackendCacheIdentity(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	for _, env := range backendCacheIdentityEnv {
+		if env != "DOCKER_CONFIG" {
+			t.Setenv(env, "")
+		}
+	}
+	base := backendCacheIdentity()
+	t.Setenv("AWS_PROFILE", "other")
+	if backendCacheIdentity() == base {
+		t.Error("AWS_PROFILE must change the cache identity")
+	}
+	t.Setenv("AWS_PROFILE", "")
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"currentContext":"remote"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if backendCacheIdentity() == base {
+		t.Error("the docker context must change the cache identity")
+	}
+}
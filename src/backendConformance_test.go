@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bestmethod/inslice"
+)
+
+// backendConformance checks that a backend implements the behaviour the commands rely on. It deploys a
+// cluster from template v using extra, and destroys it at the end. Where backends legitimately differ, the
+// accepted variants are noted with each check.
+func backendConformance(t *testing.T, back backend, v backendVersion, extra *backendExtra) {
+	back.WorkOnServers()
+	name := fmt.Sprintf("conformance-%d", time.Now().UnixNano()%1000000)
+	missing := name + "-missing"
+	t.Cleanup(func() {
+		back.WorkOnServers()
+		if nodes, err := back.NodeListInCluster(name); err == nil && len(nodes) > 0 {
+			back.ClusterStop(name, nil)
+			back.ClusterDestroy(name, nil)
+		}
+	})
+	steps := []struct {
+		name string
+		fn   func(t *testing.T)
+	}{
+		{"missing cluster", func(t *testing.T) {
+			// listing nodes of a cluster which does not exist is not an error
+			nodes, err := back.NodeListInCluster(missing)
+			if err != nil || len(nodes) != 0 {
+				t.Fatalf("NodeListInCluster: expected no nodes and no error, got %v, %v", nodes, err)
+			}
+			clusters, err := back.ClusterList()
+			if err != nil {
+				t.Fatalf("ClusterList: %s", err)
+			}
+			if inslice.HasString(clusters, missing) {
+				t.Fatalf("ClusterList: lists a cluster which does not exist")
+			}
+			// stopping, starting or destroying all nodes of a cluster which does not exist is a no-op
+			for call, fn := range map[string]func(string, []int) error{"ClusterStop": back.ClusterStop, "ClusterStart": back.ClusterStart, "ClusterDestroy": back.ClusterDestroy} {
+				if err := fn(missing, nil); err != nil {
+					t.Errorf("%s: expected no error, got %s", call, err)
+				}
+			}
+		}},
+		{"deploy", func(t *testing.T) {
+			if err := back.DeployCluster(v, name, 2, extra); err != nil {
+				t.Fatalf("DeployCluster: %s", err)
+			}
+			clusters, err := back.ClusterList()
+			if err != nil || !inslice.HasString(clusters, name) {
+				t.Fatalf("ClusterList: deployed cluster not listed: %v, %v", clusters, err)
+			}
+			backendConformanceNodes(t, back, name, []int{1, 2})
+			// nodes may or may not be running after deployment, commands always start them
+			if err := back.ClusterStart(name, nil); err != nil {
+				t.Fatalf("ClusterStart: %s", err)
+			}
+		}},
+		{"grow", func(t *testing.T) {
+			// deploying into an existing cluster adds nodes numbered after the highest existing node
+			if err := back.DeployCluster(v, name, 1, extra); err != nil {
+				t.Fatalf("DeployCluster: %s", err)
+			}
+			backendConformanceNodes(t, back, name, []int{1, 2, 3})
+			if err := back.ClusterStart(name, []int{3}); err != nil {
+				t.Fatalf("ClusterStart: %s", err)
+			}
+		}},
+		{"addresses", func(t *testing.T) {
+			backendConformanceAddresses(t, back, name, []int{1, 2, 3}, nil)
+			// backends without separate internal addresses return no internal addresses at all
+			internal, err := back.GetNodeIpMap(name, true)
+			if err != nil {
+				t.Fatalf("GetNodeIpMap(internalIPs=true): %s", err)
+			}
+			if len(internal) != 0 && len(internal) != 3 {
+				t.Errorf("GetNodeIpMap(internalIPs=true): expected no addresses or addresses of all 3 nodes, got %v", internal)
+			}
+		}},
+		{"servers and clients", func(t *testing.T) {
+			back.WorkOnClients()
+			defer back.WorkOnServers()
+			clusters, err := back.ClusterList()
+			if err != nil {
+				t.Fatalf("ClusterList: %s", err)
+			}
+			if inslice.HasString(clusters, name) {
+				t.Errorf("ClusterList: server cluster listed as a client group")
+			}
+		}},
+		{"files and commands", func(t *testing.T) {
+			contents := "conformance\n"
+			err := back.CopyFilesToClusterReader(name, []fileListReader{{"/tmp/conformance.txt", strings.NewReader(contents), len(contents)}}, []int{1, 3})
+			if err != nil {
+				t.Fatalf("CopyFilesToClusterReader: %s", err)
+			}
+			// output is returned per node, then per command
+			out, err := back.RunCommands(name, [][]string{{"cat", "/tmp/conformance.txt"}, {"cat", "/tmp/conformance.txt"}}, []int{1, 3})
+			if err != nil || len(out) != 4 {
+				t.Fatalf("RunCommands: expected 4 outputs, got %d, %v", len(out), err)
+			}
+			for i := range out {
+				if string(out[i]) != contents {
+					t.Errorf("RunCommands: output %d: expected %q, got %q", i, contents, string(out[i]))
+				}
+			}
+			// a command failing returns an error
+			if _, err = back.RunCommands(name, [][]string{{"cat", "/tmp/conformance-missing.txt"}}, []int{1}); err == nil {
+				t.Errorf("RunCommands: expected failing command to return an error")
+			}
+			dir := t.TempDir()
+			if err = back.Download(name, 3, "/tmp/conformance.txt", filepath.Join(dir, "downloaded.txt"), false, false); err != nil {
+				t.Fatalf("Download: %s", err)
+			}
+			if err = back.Upload(name, 2, filepath.Join(dir, "downloaded.txt"), "/tmp/uploaded.txt", false, false); err != nil {
+				t.Fatalf("Upload: %s", err)
+			}
+			out, err = back.RunCommands(name, [][]string{{"cat", "/tmp/uploaded.txt"}}, []int{2})
+			if err != nil || string(out[0]) != contents {
+				t.Errorf("Upload/Download: expected %q, got %q, %v", contents, string(out[0]), err)
+			}
+		}},
+		{"stop", func(t *testing.T) {
+			// stopping a stopped node is not an error
+			for i := 0; i < 2; i++ {
+				if err := back.ClusterStop(name, []int{2}); err != nil {
+					t.Fatalf("ClusterStop: attempt %d: %s", i+1, err)
+				}
+			}
+			backendConformanceNodes(t, back, name, []int{1, 2, 3})
+			backendConformanceAddresses(t, back, name, []int{1, 3}, []int{2})
+			if _, err := back.RunCommands(name, [][]string{{"cat", "/tmp/conformance.txt"}}, []int{2}); err == nil {
+				t.Errorf("RunCommands: expected an error running on a stopped node")
+			}
+		}},
+		{"start", func(t *testing.T) {
+			// starting a running node is not an error
+			for _, nodes := range [][]int{{2}, {1, 2}} {
+				if err := back.ClusterStart(name, nodes); err != nil {
+					t.Fatalf("ClusterStart %v: %s", nodes, err)
+				}
+			}
+			backendConformanceAddresses(t, back, name, []int{1, 2, 3}, nil)
+			if _, err := back.RunCommands(name, [][]string{{"cat", "/tmp/uploaded.txt"}}, []int{2}); err != nil {
+				t.Errorf("RunCommands: files not kept across stop and start: %s", err)
+			}
+		}},
+		{"destroy node", func(t *testing.T) {
+			if err := back.ClusterStop(name, []int{2}); err != nil {
+				t.Fatalf("ClusterStop: %s", err)
+			}
+			if err := back.ClusterDestroy(name, []int{2}); err != nil {
+				t.Fatalf("ClusterDestroy: %s", err)
+			}
+			backendConformanceNodes(t, back, name, []int{1, 3})
+		}},
+		{"destroy cluster", func(t *testing.T) {
+			if err := back.ClusterStop(name, nil); err != nil {
+				t.Fatalf("ClusterStop: %s", err)
+			}
+			if err := back.ClusterDestroy(name, nil); err != nil {
+				t.Fatalf("ClusterDestroy: %s", err)
+			}
+			clusters, err := back.ClusterList()
+			if err != nil || inslice.HasString(clusters, name) {
+				t.Fatalf("ClusterList: destroyed cluster still listed: %v, %v", clusters, err)
+			}
+			backendConformanceNodes(t, back, name, []int{})
+		}},
+	}
+	for _, step := range steps {
+		if !t.Run(step.name, step.fn) {
+			return
+		}
+	}
+}
+
+// backendConformanceNodes checks the node list of a cluster; the order of nodes is not defined
+func backendConformanceNodes(t *testing.T, back backend, name string, expected []int) {
+	t.Helper()
+	nodes, err := back.NodeListInCluster(name)
+	if err != nil {
+		t.Fatalf("NodeListInCluster: %s", err)
+	}
+	nodes = append([]int{}, nodes...)
+	sort.Ints(nodes)
+	if !reflect.DeepEqual(nodes, expected) {
+		t.Fatalf("NodeListInCluster: expected %v, got %v", expected, nodes)
+	}
+}
+
+// backendConformanceAddresses checks that running nodes have an address, and stopped nodes are either not listed or have a placeholder which is not an address
+func backendConformanceAddresses(t *testing.T, back backend, name string, running []int, stopped []int) {
+	t.Helper()
+	ips, err := back.GetNodeIpMap(name, false)
+	if err != nil {
+		t.Fatalf("GetNodeIpMap: %s", err)
+	}
+	for _, node := range running {
+		if net.ParseIP(ips[node]) == nil {
+			t.Errorf("GetNodeIpMap: running node %d: expected an IP address, got %q", node, ips[node])
+		}
+	}
+	for _, node := range stopped {
+		if ip, ok := ips[node]; ok && net.ParseIP(ip) != nil {
+			t.Errorf("GetNodeIpMap: stopped node %d: expected no IP address, got %s", node, ip)
+		}
+	}
+	list, err := back.GetClusterNodeIps(name)
+	if err != nil {
+		t.Fatalf("GetClusterNodeIps: %s", err)
+	}
+	if len(list) != len(running) {
+		t.Errorf("GetClusterNodeIps: expected %d addresses of running nodes, got %v", len(running), list)
+	}
+}
+
+func TestBackendConformanceMock(t *testing.T) {
+	m, _ := mockSetup(t)
+	backendConformance(t, m, mockVersion, &backendExtra{})
+}
+
+// TestBackendConformanceDocker runs the suite against the local docker, using the first available template;
+// enable with AEROLAB_TEST_DOCKER=1 and create a template first, ex: aerolab template create -d ubuntu -i 22.04
+func TestBackendConformanceDocker(t *testing.T) {
+	if os.Getenv("AEROLAB_TEST_DOCKER") == "" {
+		t.Skip("set AEROLAB_TEST_DOCKER=1 to run against docker")
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker not found")
+	}
+	back := backends["docker"]
+	if err := back.Init(); err != nil {
+		t.Fatalf("Init: %s", err)
+	}
+	templates, err := back.ListTemplates()
+	if err != nil {
+		t.Fatalf("ListTemplates: %s", err)
+	}
+	if len(templates) == 0 {
+		t.Skip("no docker templates found, create one first using: aerolab template create")
+	}
+	backendConformance(t, back, templates[0], &backendExtra{})
+}
+
+// backendConformanceCloud selects, configures and initialises a cloud backend for the suite, restoring the backend configuration at the end
+func backendConformanceCloud(t *testing.T, backendType string, configure func(c *configBackendCmd)) (backend, backendVersion) {
+	backConfig := a.opts.Config.Backend
+	t.Cleanup(func() { a.opts.Config.Backend = backConfig })
+	a.opts.Config.Backend.Type = backendType
+	configure(&a.opts.Config.Backend)
+	back := backends[backendType]
+	if err := back.Init(); err != nil {
+		t.Fatalf("Init: %s", err)
+	}
+	templates, err := back.ListTemplates()
+	if err != nil {
+		t.Fatalf("ListTemplates: %s", err)
+	}
+	if len(templates) == 0 {
+		t.Skipf("no %s templates found, create one first using: aerolab template create", backendType)
+	}
+	return back, templates[0]
+}
+
+// TestBackendConformanceAws runs the suite against aws, using the default aws credentials and the first available template;
+// enable with AEROLAB_TEST_AWS=1, optionally setting AEROLAB_TEST_AWS_REGION and AEROLAB_TEST_AWS_INSTANCE (default: t3a.medium);
+// this creates billable instances, which expire after 2 hours should the test be interrupted before cleanup
+func TestBackendConformanceAws(t *testing.T) {
+	if os.Getenv("AEROLAB_TEST_AWS") == "" {
+		t.Skip("set AEROLAB_TEST_AWS=1 to run against aws")
+	}
+	back, v := backendConformanceCloud(t, "aws", func(c *configBackendCmd) {
+		c.Region = os.Getenv("AEROLAB_TEST_AWS_REGION")
+	})
+	instanceType := os.Getenv("AEROLAB_TEST_AWS_INSTANCE")
+	if instanceType == "" {
+		instanceType = "t3a.medium"
+		if v.isArm {
+			instanceType = "t4g.medium"
+		}
+	}
+	backendConformance(t, back, v, &backendExtra{
+		instanceType:       instanceType,
+		firewallNamePrefix: []string{"AeroLab"},
+		tags:               []string{"owner=conformance"},
+		expiresTime:        time.Now().Add(2 * time.Hour),
+	})
+}
+
+// TestBackendConformanceGcp runs the suite against gcp, using the default gcloud credentials and the first available template;
+// enable with AEROLAB_TEST_GCP=PROJECT-ID, optionally setting AEROLAB_TEST_GCP_ZONE (default: us-central1-a) and AEROLAB_TEST_GCP_INSTANCE (default: e2-medium);
+// this creates billable instances, which expire after 2 hours should the test be interrupted before cleanup
+func TestBackendConformanceGcp(t *testing.T) {
+	project := os.Getenv("AEROLAB_TEST_GCP")
+	if project == "" {
+		t.Skip("set AEROLAB_TEST_GCP=PROJECT-ID to run against gcp")
+	}
+	back, v := backendConformanceCloud(t, "gcp", func(c *configBackendCmd) {
+		c.Project = project
+	})
+	zone := os.Getenv("AEROLAB_TEST_GCP_ZONE")
+	if zone == "" {
+		zone = "us-central1-a"
+	}
+	instanceType := os.Getenv("AEROLAB_TEST_GCP_INSTANCE")
+	if instanceType == "" {
+		instanceType = "e2-medium"
+		if v.isArm {
+			instanceType = "t2a-standard-2"
+		}
+	}
+	backendConformance(t, back, v, &backendExtra{
+		instanceType:       instanceType,
+		zone:               zone,
+		firewallNamePrefix: []string{"aerolab-managed-external"},
+		labels:             []string{"owner=conformance"},
+		expiresTime:        time.Now().Add(2 * time.Hour),
+	})
+}
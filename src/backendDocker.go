@@ -10,6 +10,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,9 +20,13 @@ import (
 )
 
 type backendDocker struct {
-	server bool
-	client bool
-	isArm  bool
+	server   bool
+	client   bool
+	isArm    bool
+	api      *dockerApi
+	host     string // docker host of this backend when part of a pool of hosts, empty: use the environment and current context
+	cli      string // container engine command, docker or podman
+	rootless bool   // set if the container engine runs rootless, in which case containers cannot manipulate iptables or tc
 }
 
 func init() {
@@ -143,7 +148,13 @@ func (d *backendDocker) Inventory(owner string, inventoryItems []int) (inventory
 		} else {
 			d.WorkOnClients()
 		}
-		out, err := exec.Command("docker", "container", "list", "-a", "--format", "{{.ID}}\t{{.Names}}\t{{.Status}}\t{{.Image}}\t{{.Label \"aerolab.client.type\"}}\t{{.Ports}}").CombinedOutput()
+		if d.api != nil {
+			if err := d.inventoryApi(&ij, i == 1); err != nil {
+				return ij, err
+			}
+			continue
+		}
+		out, err := d.command("container", "list", "-a", "--format", "{{.ID}}\t{{.Names}}\t{{.Status}}\t{{.Image}}\t{{.Label \"aerolab.client.type\"}}\t{{.Ports}}").CombinedOutput()
 		if err != nil {
 			return ij, err
 		}
@@ -169,7 +180,8 @@ func (d *backendDocker) Inventory(owner string, inventoryItems []int) (inventory
 				if len(nameNo) != 2 {
 					return
 				}
-				outl, err := exec.Command("docker", "container", "inspect", "--format", "{{json .Config.Labels}}", tt[1]).CombinedOutput()
+				tt[3] = dockerImageName(tt[3])
+				outl, err := d.command("container", "inspect", "--format", "{{json .Config.Labels}}", tt[1]).CombinedOutput()
 				if err != nil {
 					lineErrorLock.Lock()
 					lineError = err
@@ -184,7 +196,12 @@ func (d *backendDocker) Inventory(owner string, inventoryItems []int) (inventory
 					lineErrorLock.Unlock()
 					return
 				}
-				out2, err := exec.Command("docker", "container", "inspect", "--format", "{{json .Config.ExposedPorts}} {{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", tt[1]).CombinedOutput()
+				// podman keeps port mappings of stopped containers in network settings, docker only lists them as exposed ports
+				portsField := ".Config.ExposedPorts"
+				if d.isPodman() {
+					portsField = ".NetworkSettings.Ports"
+				}
+				out2, err := d.command("container", "inspect", "--format", "{{json "+portsField+"}} {{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", tt[1]).CombinedOutput()
 				if err != nil {
 					lineErrorLock.Lock()
 					lineError = err
@@ -211,28 +228,6 @@ func (d *backendDocker) Inventory(owner string, inventoryItems []int) (inventory
 						break
 					}
 				}
-				arch := "amd64"
-				if d.isArm {
-					arch = "arm64"
-				}
-				var i1, asdVer string
-				var i2 []string
-				i3 := []string{""}
-				if i == 1 {
-					i1 = strings.TrimPrefix(tt[3], "aerolab-")
-					i2 = strings.Split(i1, "_")
-					if len(i2) > 1 {
-						i3 = strings.Split(i2[1], ":")
-					}
-					if len(i3) > 1 {
-						asdVer = i3[1]
-					}
-				} else {
-					i2 = strings.Split(tt[3], ":")
-					if len(i2) > 1 {
-						i3[0] = i2[1]
-					}
-				}
 				clientType := ""
 				if len(tt) > 4 {
 					clientType = tt[4]
@@ -258,47 +253,18 @@ func (d *backendDocker) Inventory(owner string, inventoryItems []int) (inventory
 				}
 				invLock.Lock()
 				defer invLock.Unlock()
-				if i == 1 {
-					features, _ := strconv.Atoi(clientType)
-					ij.Clusters = append(ij.Clusters, inventoryCluster{
-						ClusterName:        nameNo[0],
-						NodeNo:             nameNo[1],
-						PublicIp:           "",
-						PrivateIp:          strings.ReplaceAll(ip, " ", ","),
-						InstanceId:         tt[0],
-						ImageId:            tt[3],
-						State:              strings.ReplaceAll(tt[2], " ", "_"),
-						Arch:               arch,
-						Distribution:       i2[0],
-						OSVersion:          i3[0],
-						AerospikeVersion:   asdVer,
-						DockerExposePorts:  exposePorts,
-						DockerInternalPort: intPorts,
-						Features:           FeatureSystem(features),
-						AGILabel:           allLabels["agiLabel"],
-						dockerLabels:       allLabels,
-						Owner:              allLabels["owner"],
-					})
-				} else {
-					ij.Clients = append(ij.Clients, inventoryClient{
-						ClientName:         nameNo[0],
-						NodeNo:             nameNo[1],
-						PublicIp:           "",
-						PrivateIp:          strings.ReplaceAll(ip, " ", ","),
-						InstanceId:         tt[0],
-						ImageId:            tt[3],
-						State:              strings.ReplaceAll(tt[2], " ", "_"),
-						Arch:               arch,
-						Distribution:       i2[0],
-						OSVersion:          i3[0],
-						AerospikeVersion:   asdVer,
-						ClientType:         clientType,
-						DockerExposePorts:  exposePorts,
-						DockerInternalPort: intPorts,
-						dockerLabels:       allLabels,
-						Owner:              allLabels["owner"],
-					})
-				}
+				d.inventoryAppend(&ij, i == 1, &dockerInventoryContainer{
+					id:         tt[0],
+					name:       nameNo[0],
+					nodeNo:     nameNo[1],
+					status:     tt[2],
+					image:      tt[3],
+					clientType: clientType,
+					labels:     allLabels,
+					ip:         ip,
+					exposePort: exposePorts,
+					intPort:    intPorts,
+				})
 			}(t)
 		}
 		lineWait.Wait()
@@ -309,6 +275,87 @@ func (d *backendDocker) Inventory(owner string, inventoryItems []int) (inventory
 	return ij, nil
 }
 
+// dockerInventoryContainer is a container listed for the inventory, using either the CLI or the engine API
+type dockerInventoryContainer struct {
+	id         string
+	name       string
+	nodeNo     string
+	status     string
+	image      string
+	clientType string
+	labels     map[string]string
+	ip         string
+	exposePort string
+	intPort    string
+}
+
+// inventoryAppend adds a container to the inventory cluster or client list
+func (d *backendDocker) inventoryAppend(ij *inventoryJson, servers bool, c *dockerInventoryContainer) {
+	arch := "amd64"
+	if d.isArm {
+		arch = "arm64"
+	}
+	var i1, asdVer string
+	var i2 []string
+	i3 := []string{""}
+	if servers {
+		i1 = strings.TrimPrefix(c.image, "aerolab-")
+		i2 = strings.Split(i1, "_")
+		if len(i2) > 1 {
+			i3 = strings.Split(i2[1], ":")
+		}
+		if len(i3) > 1 {
+			asdVer = i3[1]
+		}
+	} else {
+		i2 = strings.Split(c.image, ":")
+		if len(i2) > 1 {
+			i3[0] = i2[1]
+		}
+	}
+	if servers {
+		features, _ := strconv.Atoi(c.clientType)
+		ij.Clusters = append(ij.Clusters, inventoryCluster{
+			ClusterName:        c.name,
+			NodeNo:             c.nodeNo,
+			PublicIp:           "",
+			PrivateIp:          strings.ReplaceAll(c.ip, " ", ","),
+			InstanceId:         c.id,
+			ImageId:            c.image,
+			State:              strings.ReplaceAll(c.status, " ", "_"),
+			Arch:               arch,
+			Distribution:       i2[0],
+			OSVersion:          i3[0],
+			AerospikeVersion:   asdVer,
+			DockerExposePorts:  c.exposePort,
+			DockerInternalPort: c.intPort,
+			Features:           FeatureSystem(features),
+			AGILabel:           c.labels["agiLabel"],
+			dockerLabels:       c.labels,
+			Owner:              c.labels["owner"],
+		})
+	} else {
+		ij.Clients = append(ij.Clients, inventoryClient{
+			ClientName:         c.name,
+			NodeNo:             c.nodeNo,
+			PublicIp:           "",
+			PrivateIp:          strings.ReplaceAll(c.ip, " ", ","),
+			InstanceId:         c.id,
+			ImageId:            c.image,
+			State:              strings.ReplaceAll(c.status, " ", "_"),
+			Arch:               arch,
+			Distribution:       i2[0],
+			OSVersion:          i3[0],
+			AerospikeVersion:   asdVer,
+			ClientType:         c.clientType,
+			DockerExposePorts:  c.exposePort,
+			DockerInternalPort: c.intPort,
+			dockerLabels:       c.labels,
+			Owner:              c.labels["owner"],
+		})
+	}
+}
+
 func (d *backendDocker) IsSystemArm(systemType string) (bool, error) {
 	return d.isArm, nil
 }
@@ -349,16 +396,13 @@ func (d *backendDocker) LockSecurityGroups(ip string, lockSSH bool, vpc string,
 }
 
 func (d *backendDocker) ClusterList() ([]string, error) {
-	out, err := exec.Command("docker", "container", "list", "-a", "--format", "{{json .Names}}").CombinedOutput()
+	names, err := d.containerNames()
 	if err != nil {
 		return nil, err
 	}
 	var clusterList []string
 	clusterList = []string{}
-	scanner := bufio.NewScanner(strings.NewReader(string(out)))
-	for scanner.Scan() {
-		t := scanner.Text()
-		t = strings.Trim(t, "'\"")
+	for _, t := range names {
 		if strings.Contains(t, dockerNameHeader+"") {
 			t = t[len(dockerNameHeader):]
 			cnametmp := strings.Split(t, "_")
@@ -373,15 +417,12 @@ func (d *backendDocker) ClusterList() ([]string, error) {
 }
 
 func (d *backendDocker) NodeListInCluster(name string) ([]int, error) {
-	out, err := exec.Command("docker", "container", "list", "-a", "--format", "{{json .Names}}").CombinedOutput()
+	names, err := d.containerNames()
 	if err != nil {
 		return nil, err
 	}
 	var nodeList []int
-	scanner := bufio.NewScanner(strings.NewReader(string(out)))
-	for scanner.Scan() {
-		t := scanner.Text()
-		t = strings.Trim(t, "'\"")
+	for _, t := range names {
 		if strings.Contains(t, dockerNameHeader+"") {
 			t = t[len(dockerNameHeader):]
 			cnametmp := strings.Split(t, "_")
@@ -400,7 +441,7 @@ func (d *backendDocker) NodeListInCluster(name string) ([]int, error) {
 }
 
 func (d *backendDocker) ListTemplates() ([]backendVersion, error) {
-	out, err := exec.Command("docker", "image", "list", "-a", "--format", "{{json .Repository}};{{.Tag}}").CombinedOutput()
+	out, err := d.command("image", "list", "-a", "--format", "{{json .Repository}};{{.Tag}}").CombinedOutput()
 	if err != nil {
 		return nil, err
 	}
@@ -408,7 +449,7 @@ func (d *backendDocker) ListTemplates() ([]backendVersion, error) {
 	scanner := bufio.NewScanner(strings.NewReader(string(out)))
 	for scanner.Scan() {
 		t := scanner.Text()
-		repo := strings.Trim(strings.Split(t, ";")[0], "'\"")
+		repo := dockerImageName(strings.Trim(strings.Split(t, ";")[0], "'\""))
 		if strings.Contains(repo, dockerNameHeader+"") {
 			if len(repo) > len(dockerNameHeader)+2 {
 				repo = repo[len(dockerNameHeader):]
@@ -439,22 +480,22 @@ func (d *backendDocker) WorkOnServers() {
 }
 
 func (d *backendDocker) Init() error {
+	cli, err := dockerDetectCli(a.opts.Config.Backend.ContainerCLI)
+	if err != nil {
+		return err
+	}
+	d.cli = cli
 	ctx, ctxCancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer ctxCancel()
-	out, err := exec.CommandContext(ctx, "docker", "info").CombinedOutput()
+	out, err := d.commandContext(ctx, "info").CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("docker command not found, or docker appears to be unreachable or down: %s", string(out))
+		return fmt.Errorf("%s command not found, or %s appears to be unreachable or down: %s", d.cli, d.cli, string(out))
 	}
-	for _, line := range strings.Split(string(out), "\n") {
-		line = strings.Trim(line, "\r\n\t ")
-		if !strings.HasPrefix(line, "Architecture: ") {
-			continue
-		}
-		arch := strings.Split(line, ": ")[1]
-		if strings.Contains(arch, "arm") || strings.Contains(arch, "aarch") {
-			d.isArm = true
-		}
-		break
+	d.isArm, d.rootless = dockerParseInfo(string(out))
+	// use the engine API if it is reachable, otherwise fall back to parsing CLI output, ex: if the podman service is not running
+	d.api = nil
+	if api, err := newDockerApi(d.apiHost()); err == nil && api.ping() == nil {
+		d.api = api
 	}
 	d.WorkOnServers()
 	return nil
@@ -473,7 +514,7 @@ func (d *backendDocker) versionToReal(v *backendVersion) error {
 }
 
 func (d *backendDocker) VacuumTemplates() error {
-	out, err := exec.Command("docker", "container", "list", "-a").CombinedOutput()
+	out, err := d.command("container", "list", "-a").CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("docker command failed: %s", err)
 	}
@@ -489,8 +530,8 @@ func (d *backendDocker) VacuumTemplates() error {
 	}
 	errs := ""
 	for _, id := range ids {
-		exec.Command("docker", "stop", "-t", "1", id).CombinedOutput()
-		out, err := exec.Command("docker", "rm", "-f", id).CombinedOutput()
+		d.command("stop", "-t", "1", id).CombinedOutput()
+		out, err := d.command("rm", "-f", id).CombinedOutput()
 		if err != nil {
 			errs = errs + err.Error() + "\n" + string(out) + "\n"
 		}
@@ -506,11 +547,11 @@ func (d *backendDocker) VacuumTemplate(v backendVersion) error {
 		return err
 	}
 	templName := fmt.Sprintf("aerotmpl-%s-%s-%s", v.distroName, v.distroVersion, v.aerospikeVersion)
-	out, err := exec.Command("docker", "stop", "-t", "1", templName).CombinedOutput()
+	out, err := d.command("stop", "-t", "1", templName).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("could not stop temporary template container: %s;%s", out, err)
 	}
-	out, err = exec.Command("docker", "rm", "-f", templName).CombinedOutput()
+	out, err = d.command("rm", "-f", templName).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("could not destroy temporary template container: %s;%s", out, err)
 	}
@@ -528,12 +569,12 @@ func (d *backendDocker) DeployTemplate(v backendVersion, script string, files []
 		for len(deployTemplateShutdownMaking) > 0 {
 			time.Sleep(time.Second)
 		}
-		exec.Command("docker", "rm", "-f", templName).CombinedOutput()
+		d.command("rm", "-f", templName).CombinedOutput()
 	})
 	defer delShutdownHandler("deployTemplate")
 	// deploy container with os
 	deployTemplateShutdownMaking <- 1
-	out, err := exec.Command("docker", "run", "-td", "--name", templName, d.centosNaming(v)).CombinedOutput()
+	out, err := d.command("run", "-td", "--name", templName, d.centosNaming(v)).CombinedOutput()
 	<-deployTemplateShutdownMaking
 	if err != nil {
 		return fmt.Errorf("could not start vanilla container: %s;%s", out, err)
@@ -546,27 +587,27 @@ func (d *backendDocker) DeployTemplate(v backendVersion, script string, files []
 		return fmt.Errorf("could not copy files to container: %s", err)
 	}
 	// run script
-	out, err = exec.Command("docker", "exec", "-t", templName, "chmod", "755", "/root/install.sh").CombinedOutput()
+	out, err = d.command("exec", "-t", templName, "chmod", "755", "/root/install.sh").CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("could not chmod 755 /root/install.sh: %s;%s", out, err)
 	}
-	out, err = exec.Command("docker", "exec", "-t", templName, "/bin/bash", "-c", "/root/install.sh").CombinedOutput()
+	out, err = d.command("exec", "-t", templName, "/bin/bash", "-c", "/root/install.sh").CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("script /root/install.sh failed with: %s;%s", out, err)
 	}
 	// stop container
-	out, err = exec.Command("docker", "stop", templName).CombinedOutput()
+	out, err = d.command("stop", templName).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed stopping container: %s;%s", out, err)
 	}
 	// docker container commit container_name dist_ver:aeroVer
 	templImg := fmt.Sprintf(dockerNameHeader+"%s_%s:%s", v.distroName, v.distroVersion, v.aerospikeVersion)
-	out, err = exec.Command("docker", "container", "commit", templName, templImg).CombinedOutput()
+	out, err = d.command("container", "commit", templName, templImg).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to commit container to image: %s;%s", out, err)
 	}
 	// docker rm container_name
-	out, err = exec.Command("docker", "rm", templName).CombinedOutput()
+	out, err = d.command("rm", templName).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to remove temporary container: %s;%s", out, err)
 	}
@@ -578,104 +619,107 @@ func (d *backendDocker) TemplateDestroy(v backendVersion) error {
 		v.distroName = "centos"
 	}
 	name := fmt.Sprintf(dockerNameHeader+"%s_%s:%s", v.distroName, v.distroVersion, v.aerospikeVersion)
-	out, err := exec.Command("docker", "image", "list", "--format", "{{json .ID}}", fmt.Sprintf("--filter=reference=%s", name)).CombinedOutput()
+	if d.isPodman() {
+		out, err := d.command("rmi", name).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to rmi '%s': %s;%s", name, string(out), err)
+		}
+		return nil
+	}
+	out, err := d.command("image", "list", "--format", "{{json .ID}}", fmt.Sprintf("--filter=reference=%s", name)).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to get image list: %s;%s", string(out), err)
 	}
 	imageId := strings.Trim(string(out), "\"' \n\r")
-	out, err = exec.Command("docker", "rmi", imageId).CombinedOutput()
+	out, err = d.command("rmi", imageId).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to rmi '%s': %s;%s", imageId, string(out), err)
 	}
 	return nil
 }
 
-func (d *backendDocker) DeployCluster(v backendVersion, name string, nodeCount int, extra *backendExtra) error {
-	name = strings.Trim(name, "\r\n\t ")
-	if extra.network != "" {
-		b := new(bytes.Buffer)
-		err := d.ListNetworks(true, b)
-		if err != nil {
-			return err
+// dockerDeployNetwork checks that the network to deploy nodes into exists, offering to create it otherwise
+func dockerDeployNetwork(d dockerNetworker, network string) error {
+	if network == "" {
+		return nil
+	}
+	b := new(bytes.Buffer)
+	err := d.ListNetworks(true, b)
+	if err != nil {
+		return err
+	}
+	found := false
+	for i, line := range strings.Split(b.String(), "\n") {
+		if i == 0 {
+			continue
 		}
-		found := false
-		for i, line := range strings.Split(b.String(), "\n") {
-			if i == 0 {
-				continue
-			}
-			netName := strings.Split(line, ",")[0]
-			if netName == extra.network {
-				found = true
-				break
+		netName := strings.Split(line, ",")[0]
+		if netName == network {
+			found = true
+			break
+		}
+	}
+	if !found {
+		fmt.Printf("Network %s not found! Create (y/n)? ", network)
+		reader := bufio.NewReader(os.Stdin)
+		answer := ""
+		for strings.ToLower(answer) != "y" && strings.ToLower(answer) != "n" && strings.ToLower(answer) != "yes" && strings.ToLower(answer) != "no" {
+			answer, _ = reader.ReadString('\n')
+			answer = strings.Trim(answer, "\t\r\n ")
+			if strings.ToLower(answer) != "y" && strings.ToLower(answer) != "n" && strings.ToLower(answer) != "yes" && strings.ToLower(answer) != "no" {
+				fmt.Println("Invalid input: answer either 'y' or 'n'")
+				fmt.Printf("Network %s not found! Create (y/n)? ", network)
 			}
 		}
-		if !found {
-			fmt.Printf("Network %s not found! Create (y/n)? ", extra.network)
-			reader := bufio.NewReader(os.Stdin)
+		if strings.HasPrefix(answer, "n") {
+			return fmt.Errorf("network not found, choose another network or create one first with: aerolab config docker help")
+		}
+		ok := false
+		for !ok {
+			fmt.Printf("Subnet (empty=default): ")
+			subnet, _ := reader.ReadString('\n')
+			subnet = strings.Trim(subnet, "\t\r\n ")
+			fmt.Printf("Driver (empty=default): ")
+			driver, _ := reader.ReadString('\n')
+			driver = strings.Trim(driver, "\t\r\n ")
+			fmt.Printf("MTU (empty=default): ")
+			mtu, _ := reader.ReadString('\n')
+			mtu = strings.Trim(mtu, "\t\r\n ")
+			fmt.Printf("OK (y/n/q)? ")
 			answer := ""
-			for strings.ToLower(answer) != "y" && strings.ToLower(answer) != "n" && strings.ToLower(answer) != "yes" && strings.ToLower(answer) != "no" {
+			for strings.ToLower(answer) != "y" && strings.ToLower(answer) != "n" && strings.ToLower(answer) != "yes" && strings.ToLower(answer) != "no" && strings.ToLower(answer) != "q" && strings.ToLower(answer) != "quit" {
 				answer, _ = reader.ReadString('\n')
 				answer = strings.Trim(answer, "\t\r\n ")
-				if strings.ToLower(answer) != "y" && strings.ToLower(answer) != "n" && strings.ToLower(answer) != "yes" && strings.ToLower(answer) != "no" {
+				if strings.ToLower(answer) != "y" && strings.ToLower(answer) != "n" && strings.ToLower(answer) != "yes" && strings.ToLower(answer) != "no" && strings.ToLower(answer) != "q" && strings.ToLower(answer) != "quit" {
 					fmt.Println("Invalid input: answer either 'y' or 'n'")
-					fmt.Printf("Network %s not found! Create (y/n)? ", extra.network)
+					fmt.Printf("OK (y/n/q)? ")
 				}
 			}
-			if strings.HasPrefix(answer, "n") {
+			if strings.HasPrefix(answer, "q") {
 				return fmt.Errorf("network not found, choose another network or create one first with: aerolab config docker help")
 			}
-			ok := false
-			for !ok {
-				fmt.Printf("Subnet (empty=default): ")
-				subnet, _ := reader.ReadString('\n')
-				subnet = strings.Trim(subnet, "\t\r\n ")
-				fmt.Printf("Driver (empty=default): ")
-				driver, _ := reader.ReadString('\n')
-				driver = strings.Trim(driver, "\t\r\n ")
-				fmt.Printf("MTU (empty=default): ")
-				mtu, _ := reader.ReadString('\n')
-				mtu = strings.Trim(mtu, "\t\r\n ")
-				fmt.Printf("OK (y/n/q)? ")
-				answer := ""
-				for strings.ToLower(answer) != "y" && strings.ToLower(answer) != "n" && strings.ToLower(answer) != "yes" && strings.ToLower(answer) != "no" && strings.ToLower(answer) != "q" && strings.ToLower(answer) != "quit" {
-					answer, _ = reader.ReadString('\n')
-					answer = strings.Trim(answer, "\t\r\n ")
-					if strings.ToLower(answer) != "y" && strings.ToLower(answer) != "n" && strings.ToLower(answer) != "yes" && strings.ToLower(answer) != "no" && strings.ToLower(answer) != "q" && strings.ToLower(answer) != "quit" {
-						fmt.Println("Invalid input: answer either 'y' or 'n'")
-						fmt.Printf("OK (y/n/q)? ")
-					}
-				}
-				if strings.HasPrefix(answer, "q") {
-					return fmt.Errorf("network not found, choose another network or create one first with: aerolab config docker help")
+			if strings.HasPrefix(answer, "y") {
+				if driver == "" {
+					driver = "bridge"
 				}
-				if strings.HasPrefix(answer, "y") {
-					if driver == "" {
-						driver = "bridge"
-					}
-					err = d.CreateNetwork(extra.network, driver, subnet, mtu)
-					if err != nil {
-						return err
-					}
-					ok = true
+				err = d.CreateNetwork(network, driver, subnet, mtu, "")
+				if err != nil {
+					return err
 				}
+				ok = true
 			}
 		}
 	}
-	if err := d.versionToReal(&v); err != nil {
+	return nil
+}
+
+func (d *backendDocker) DeployCluster(v backendVersion, name string, nodeCount int, extra *backendExtra) error {
+	name = strings.Trim(name, "\r\n\t ")
+	if err := dockerDeployNetwork(d, extra.network); err != nil {
 		return err
 	}
-	if !d.client {
-		templ, err := d.ListTemplates()
-		if err != nil {
-			return err
-		}
-		inArray, err := inslice.Reflect(templ, v, 1)
-		if err != nil {
-			return err
-		}
-		if len(inArray) == 0 {
-			return errors.New("template not found")
-		}
+	if err := d.checkTemplate(&v); err != nil {
+		return err
 	}
 	list, err := d.ClusterList()
 	if err != nil {
@@ -698,6 +742,36 @@ func (d *backendDocker) DeployCluster(v backendVersion, name string, nodeCount i
 	}
 	highestNode = highestNode + 1
 
+	nodes := []int{}
+	for node := highestNode; node < nodeCount+highestNode; node = node + 1 {
+		nodes = append(nodes, node)
+	}
+	return d.deployNodes(v, name, nodes, extra)
+}
+
+// checkTemplate converts the version to the distribution actually deployed and checks that the server template exists
+func (d *backendDocker) checkTemplate(v *backendVersion) error {
+	if err := d.versionToReal(v); err != nil {
+		return err
+	}
+	if !d.client {
+		templ, err := d.ListTemplates()
+		if err != nil {
+			return err
+		}
+		inArray, err := inslice.Reflect(templ, *v, 1)
+		if err != nil {
+			return err
+		}
+		if len(inArray) == 0 {
+			return errors.New("template not found")
+		}
+	}
+	return nil
+}
+
+// deployNodes runs containers for the given node numbers of a cluster
+func (d *backendDocker) deployNodes(v backendVersion, name string, nodes []int, extra *backendExtra) error {
 	var exposedList []int
 	if extra.autoExpose {
 		abc := d.server
@@ -729,10 +803,12 @@ func (d *backendDocker) DeployCluster(v backendVersion, name string, nodeCount i
 			exposeFreeList = append(exposeFreeList, i)
 		}
 	}
+	if d.rootless && !extra.autoExpose && len(extra.exposePorts) == 0 {
+		fmt.Printf("WARNING: %s is running in rootless mode, where container addresses are not reachable from the host; nodes will only be reachable from other containers, publish ports using --expose-ports or remove --no-autoexpose\n", d.engine())
+	}
 	exposeFreeListNext := -1
-	for node := highestNode; node < nodeCount+highestNode; node = node + 1 {
+	for _, node := range nodes {
 		exposeFreeListNext++
-		var out []byte
 		exposeList := []string{"run"}
 		if extra.clientType != "" {
 			exposeList = append(exposeList, "--label", "aerolab.client.type="+extra.clientType)
@@ -768,14 +844,21 @@ func (d *backendDocker) DeployCluster(v backendVersion, name string, nodeCount i
 		}
 		if extra.network != "" {
 			exposeList = append(exposeList, "--network", extra.network)
+		} else if d.isPodman() {
+			// rootless podman does not give containers an address other containers can reach on the default network; the podman network
+			// addresses are still not reachable from the host in rootless mode, where the host reaches nodes through published ports only
+			exposeList = append(exposeList, "--network", "podman")
+		}
+		if extra.privileged && !d.rootless {
+			exposeList = append(exposeList, "--device-cgroup-rule=b 7:* rmw")
 		}
 		if extra.privileged {
 			fmt.Println("WARNING: privileged container")
-			exposeList = append(exposeList, "--device-cgroup-rule=b 7:* rmw", "--privileged=true", "--cap-add=NET_ADMIN", "--cap-add=NET_RAW", "-td", "--name", fmt.Sprintf(dockerNameHeader+"%s_%d", name, node), tmplName, "/bin/bash", "-c", "while true; do [ -f /tmp/poweroff.now ] && rm -f /tmp/poweroff.now && exit; sleep 1; done")
+			exposeList = append(exposeList, "--privileged=true", "--cap-add=NET_ADMIN", "--cap-add=NET_RAW", "-td", "--name", fmt.Sprintf(dockerNameHeader+"%s_%d", name, node), tmplName, "/bin/bash", "-c", "while true; do [ -f /tmp/poweroff.now ] && rm -f /tmp/poweroff.now && exit; sleep 1; done")
 		} else {
 			exposeList = append(exposeList, "--cap-add=NET_ADMIN", "--cap-add=NET_RAW", "-td", "--name", fmt.Sprintf(dockerNameHeader+"%s_%d", name, node), tmplName, "/bin/bash", "-c", "while true; do [ -f /tmp/poweroff.now ] && rm -f /tmp/poweroff.now && exit; sleep 1; done")
 		}
-		out, err = exec.Command("docker", exposeList...).CombinedOutput()
+		out, err := d.command(exposeList...).CombinedOutput()
 		if err != nil {
 			return fmt.Errorf("error running container: %s;%s", out, err)
 		}
@@ -785,7 +868,7 @@ func (d *backendDocker) DeployCluster(v backendVersion, name string, nodeCount i
 
 func (d *backendDocker) centosNaming(v backendVersion) (templName string) {
 	if v.distroName != "centos" {
-		return fmt.Sprintf("%s:%s", v.distroName, v.distroVersion)
+		return d.publicImage(fmt.Sprintf("%s:%s", v.distroName, v.distroVersion))
 	}
 	switch v.distroVersion {
 	case "6":
@@ -835,8 +918,14 @@ func (d *backendDocker) CopyFilesToClusterReader(name string, files []fileListRe
 		}
 		for _, node := range nodes {
 			nodeName := fmt.Sprintf(dockerNameHeader+"%s_%d", name, node)
+			if d.api != nil {
+				if err = d.api.upload(nodeName, tmpfileName, file.filePath); err != nil {
+					return fmt.Errorf("error copying to container: %s\ntmpfileName: %s\nfilePath: %s", err, tmpfileName, fmt.Sprintf("%s:%s", nodeName, file.filePath))
+				}
+				continue
+			}
 			var out []byte
-			out, err = exec.Command("docker", "cp", tmpfileName, fmt.Sprintf("%s:%s", nodeName, file.filePath)).CombinedOutput()
+			out, err = d.command("cp", tmpfileName, fmt.Sprintf("%s:%s", nodeName, file.filePath)).CombinedOutput()
 			if err != nil {
 				return fmt.Errorf("error with docker cp: %s;%s\ntmpfileName: %s\nfilePath: %s", string(out), err, tmpfileName, fmt.Sprintf("%s:%s", nodeName, file.filePath))
 			}
@@ -863,9 +952,18 @@ func (d *backendDocker) RunCommands(clusterName string, commands [][]string, nod
 		var out []byte
 		var err error
 		for _, command := range commands {
+			if d.api != nil {
+				buf := new(bytes.Buffer)
+				err = d.api.exec(name, command, []string{fmt.Sprintf("NODE=%d", node)}, false, buf, buf)
+				fout = append(fout, buf.Bytes())
+				if checkExecRetcode(err) != 0 {
+					return fout, fmt.Errorf("error running %s: %s", command, err)
+				}
+				continue
+			}
 			head := []string{"exec", "-e", fmt.Sprintf("NODE=%d", node), name}
 			command = append(head, command...)
-			out, err = exec.Command("docker", command...).CombinedOutput()
+			out, err = d.command(command...).CombinedOutput()
 			fout = append(fout, out)
 			if checkExecRetcode(err) != 0 {
 				return fout, fmt.Errorf("error running %s: %s", command, err)
@@ -876,6 +974,22 @@ func (d *backendDocker) RunCommands(clusterName string, commands [][]string, nod
 }
 
 func (d *backendDocker) GetClusterNodeIps(name string) ([]string, error) {
+	if d.api != nil {
+		nodeIps, err := d.apiNodeIps(name)
+		if err != nil {
+			return nil, err
+		}
+		nodes := []int{}
+		for node := range nodeIps {
+			nodes = append(nodes, node)
+		}
+		sort.Ints(nodes)
+		ips := []string{}
+		for _, node := range nodes {
+			ips = append(ips, nodeIps[node])
+		}
+		return ips, nil
+	}
 	clusters, err := d.ClusterList()
 	if err != nil {
 		return nil, err
@@ -891,7 +1005,7 @@ func (d *backendDocker) GetClusterNodeIps(name string) ([]string, error) {
 	var out []byte
 	for _, node := range nodes {
 		containerName := fmt.Sprintf(dockerNameHeader+"%s_%d", name, node)
-		out, err = exec.Command("docker", "container", "inspect", "--format", "{{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", containerName).CombinedOutput()
+		out, err = d.command("container", "inspect", "--format", "{{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", containerName).CombinedOutput()
 		if err != nil {
 			return nil, err
 		}
@@ -907,6 +1021,9 @@ func (d *backendDocker) GetNodeIpMap(name string, internalIPs bool) (map[int]str
 	if internalIPs {
 		return nil, nil
 	}
+	if d.api != nil {
+		return d.apiNodeIps(name)
+	}
 	clusters, err := d.ClusterList()
 	if err != nil {
 		return nil, err
@@ -922,7 +1039,7 @@ func (d *backendDocker) GetNodeIpMap(name string, internalIPs bool) (map[int]str
 	var out []byte
 	for _, node := range nodes {
 		containerName := fmt.Sprintf(dockerNameHeader+"%s_%d", name, node)
-		out, err = exec.Command("docker", "container", "inspect", "--format", "{{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", containerName).CombinedOutput()
+		out, err = d.command("container", "inspect", "--format", "{{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", containerName).CombinedOutput()
 		if err != nil {
 			return nil, err
 		}
@@ -945,7 +1062,13 @@ func (d *backendDocker) ClusterStart(name string, nodes []int) error {
 	for _, node := range nodes {
 		var out []byte
 		name := fmt.Sprintf(dockerNameHeader+"%s_%d", name, node)
-		out, err = exec.Command("docker", "start", name).CombinedOutput()
+		if d.api != nil {
+			if err = d.api.start(name); err != nil {
+				return err
+			}
+			continue
+		}
+		out, err = d.command("start", name).CombinedOutput()
 		if err != nil {
 			return fmt.Errorf("%s;%s", string(out), err)
 		}
@@ -964,7 +1087,13 @@ func (d *backendDocker) ClusterStop(name string, nodes []int) error {
 	for _, node := range nodes {
 		var out []byte
 		name := fmt.Sprintf(dockerNameHeader+"%s_%d", name, node)
-		out, err = exec.Command("docker", "stop", "-t", "1", name).CombinedOutput()
+		if d.api != nil {
+			if err = d.api.stop(name); err != nil {
+				return err
+			}
+			continue
+		}
+		out, err = d.command("stop", "-t", "1", name).CombinedOutput()
 		if err != nil {
 			return fmt.Errorf("%s;%s", string(out), err)
 		}
@@ -983,7 +1112,13 @@ func (d *backendDocker) ClusterDestroy(name string, nodes []int) error {
 	for _, node := range nodes {
 		var out []byte
 		name := fmt.Sprintf(dockerNameHeader+"%s_%d", name, node)
-		out, err = exec.Command("docker", "rm", name).CombinedOutput()
+		if d.api != nil {
+			if err = d.api.remove(name); err != nil {
+				return err
+			}
+			continue
+		}
+		out, err = d.command("rm", name).CombinedOutput()
 		if err != nil {
 			return fmt.Errorf("%s;%s", string(out), err)
 		}
@@ -993,8 +1128,11 @@ func (d *backendDocker) ClusterDestroy(name string, nodes []int) error {
 
 func (d *backendDocker) Upload(clusterName string, node int, source string, destination string, verbose bool, legacy bool) error {
 	name := fmt.Sprintf(dockerNameHeader+"%s_%d", clusterName, node)
+	if d.api != nil {
+		return d.api.upload(name, source, destination)
+	}
 	cmd := []string{"cp", source, name + ":" + destination}
-	out, err := exec.Command("docker", cmd...).CombinedOutput()
+	out, err := d.command(cmd...).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("%s: %s", err, strings.ReplaceAll(string(out), "\n", "; "))
 	}
@@ -1003,8 +1141,11 @@ func (d *backendDocker) Upload(clusterName string, node int, source string, dest
 
 func (d *backendDocker) Download(clusterName string, node int, source string, destination string, verbose bool, legacy bool) error {
 	name := fmt.Sprintf(dockerNameHeader+"%s_%d", clusterName, node)
+	if d.api != nil {
+		return d.api.download(name, source, destination)
+	}
 	cmd := []string{"cp", name + ":" + source, destination}
-	out, err := exec.Command("docker", cmd...).CombinedOutput()
+	out, err := d.command(cmd...).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("%s: %s", err, strings.ReplaceAll(string(out), "\n", "; "))
 	}
@@ -1022,13 +1163,20 @@ func (d *backendDocker) RunCustomOut(clusterName string, node int, command []str
 	if isInteractive {
 		termMode = "-ti"
 	}
+	// interactive sessions need terminal handling, which is left to the CLI
+	if d.api != nil && !isInteractive {
+		if len(command) == 0 {
+			command = []string{"/bin/bash"}
+		}
+		return d.api.exec(name, command, []string{fmt.Sprintf("NODE=%d", node)}, true, stdout, stderr)
+	}
 	head := []string{"exec", "-e", fmt.Sprintf("NODE=%d", node), termMode, name}
 	if len(command) == 0 {
 		command = append(head, "/bin/bash")
 	} else {
 		command = append(head, command...)
 	}
-	cmd = exec.Command("docker", command...)
+	cmd = d.command(command...)
 	cmd.Stdin = stdin
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
@@ -1056,7 +1204,11 @@ func (d *backendDocker) copyFilesToContainer(name string, files []fileListReader
 		}
 		nodeName := name
 		var out []byte
-		out, err = exec.Command("docker", "cp", tmpfileName, fmt.Sprintf("%s:%s", nodeName, file.filePath)).CombinedOutput()
+		if d.api != nil {
+			err = d.api.upload(nodeName, tmpfileName, file.filePath)
+		} else {
+			out, err = d.command("cp", tmpfileName, fmt.Sprintf("%s:%s", nodeName, file.filePath)).CombinedOutput()
+		}
 		if err != nil {
 			return fmt.Errorf("error with docker cp: %s;%s", string(out), err)
 		}
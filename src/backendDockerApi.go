@@ -0,0 +1,709 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dockerApiVersion is the engine API version requested; it is supported by docker 19.03+ and the podman compatibility API
+const dockerApiVersion = "v1.40"
+
+// dockerApi is a minimal docker engine API client, talking to the same engine as the CLI
+type dockerApi struct {
+	client *http.Client
+	base   string
+}
+
+// dockerApiError is an error response from the engine API
+type dockerApiError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *dockerApiError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.StatusCode)
+}
+
+// dockerExitError is returned when a command executed in a container exits with a non-zero exit code
+type dockerExitError struct {
+	code int
+}
+
+func (e *dockerExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func (e *dockerExitError) ExitCode() int {
+	return e.code
+}
+
+// dockerApiContainer is a container as returned by the container list call
+type dockerApiContainer struct {
+	Id     string
+	Names  []string
+	Image  string
+	State  string
+	Status string
+	Labels map[string]string
+	Ports  []struct {
+		PrivatePort int
+		PublicPort  int
+	}
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string
+		}
+	}
+}
+
+// name returns the container name without the leading slash
+func (c *dockerApiContainer) name() string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// ips returns the container addresses in network name order, matching the CLI inspect template output; stopped containers have no addresses
+func (c *dockerApiContainer) ips() []string {
+	names := []string{}
+	for name := range c.NetworkSettings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ips := []string{}
+	for _, name := range names {
+		if ip := c.NetworkSettings.Networks[name].IPAddress; ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// apiHost returns the engine address the CLI talks to: the pool host, DOCKER_HOST, the current docker context, or the podman service socket
+func (d *backendDocker) apiHost() string {
+	host := d.host
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" && d.isPodman() {
+		out, err := d.command("info", "--format", "{{.Host.RemoteSocket.Path}}").CombinedOutput()
+		if err != nil {
+			return ""
+		}
+		host = strings.Trim(string(out), "\r\n\t ")
+	} else if host == "" {
+		out, err := d.command("context", "inspect", "--format", "{{.Endpoints.docker.Host}}").CombinedOutput()
+		if err != nil {
+			return "unix:///var/run/docker.sock"
+		}
+		host = strings.Trim(string(out), "\r\n\t ")
+	}
+	if strings.HasPrefix(host, "/") {
+		host = "unix://" + host
+	}
+	return host
+}
+
+// newDockerApi returns an engine API client for unix://, tcp:// (with DOCKER_TLS_VERIFY and DOCKER_CERT_PATH) and ssh:// hosts
+func newDockerApi(host string) (*dockerApi, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse docker host %s: %s", host, err)
+	}
+	transport := &http.Transport{
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     30 * time.Second,
+	}
+	api := &dockerApi{
+		client: &http.Client{Transport: transport},
+		base:   "http://docker",
+	}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	case "tcp":
+		api.base = "http://" + u.Host
+		if os.Getenv("DOCKER_TLS_VERIFY") != "" {
+			api.base = "https://" + u.Host
+			transport.TLSClientConfig, err = dockerApiTlsConfig()
+			if err != nil {
+				return nil, err
+			}
+		}
+	case "ssh":
+		transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			return dockerApiDialSsh(u)
+		}
+	default:
+		return nil, fmt.Errorf("docker host %s: scheme %s not supported", host, u.Scheme)
+	}
+	return api, nil
+}
+
+// dockerApiTlsConfig loads the client certificates from DOCKER_CERT_PATH, or ~/.docker
+func dockerApiTlsConfig() (*tls.Config, error) {
+	certPath := os.Getenv("DOCKER_CERT_PATH")
+	if certPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		certPath = filepath.Join(home, ".docker")
+	}
+	ca, err := os.ReadFile(filepath.Join(certPath, "ca.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read docker CA certificate: %s", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+	cert, err := tls.LoadX509KeyPair(filepath.Join(certPath, "cert.pem"), filepath.Join(certPath, "key.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("could not load docker client certificate: %s", err)
+	}
+	return &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cert}}, nil
+}
+
+// dockerSshConn is a connection to a remote engine through `docker system dial-stdio` over ssh, the same way the CLI connects
+type dockerSshConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func dockerApiDialSsh(u *url.URL) (net.Conn, error) {
+	args := []string{"-o", "ConnectTimeout=30"}
+	if u.Port() != "" {
+		args = append(args, "-p", u.Port())
+	}
+	if u.User != nil {
+		args = append(args, "-l", u.User.Username())
+	}
+	args = append(args, "--", u.Hostname(), "docker", "system", "dial-stdio")
+	cmd := exec.Command("ssh", args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not run ssh: %s", err)
+	}
+	return &dockerSshConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+func (c *dockerSshConn) Read(b []byte) (int, error)  { return c.stdout.Read(b) }
+func (c *dockerSshConn) Write(b []byte) (int, error) { return c.stdin.Write(b) }
+func (c *dockerSshConn) Close() error {
+	c.stdin.Close()
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	return nil
+}
+func (c *dockerSshConn) LocalAddr() net.Addr                { return &net.UnixAddr{Name: "ssh", Net: "unix"} }
+func (c *dockerSshConn) RemoteAddr() net.Addr               { return &net.UnixAddr{Name: "ssh", Net: "unix"} }
+func (c *dockerSshConn) SetDeadline(t time.Time) error      { return nil }
+func (c *dockerSshConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dockerSshConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// do performs an API call, converting error responses to dockerApiError; the caller must close the response body
+func (api *dockerApi) do(method string, urlPath string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	u := api.base + "/" + dockerApiVersion + urlPath
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		contents, _ := io.ReadAll(resp.Body)
+		msg := struct{ Message string }{}
+		if json.Unmarshal(contents, &msg) != nil || msg.Message == "" {
+			msg.Message = strings.Trim(string(contents), "\r\n\t ")
+		}
+		if msg.Message == "" {
+			msg.Message = resp.Status
+		}
+		return nil, &dockerApiError{StatusCode: resp.StatusCode, Message: msg.Message}
+	}
+	return resp, nil
+}
+
+// doJson performs an API call with an optional json request body, decoding the json response into out if set
+func (api *dockerApi) doJson(method string, urlPath string, query url.Values, in interface{}, out interface{}) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		contents, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(contents)
+		contentType = "application/json"
+	}
+	resp, err := api.do(method, urlPath, query, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ping checks that the engine API is reachable, timing out after 10 seconds
+func (api *dockerApi) ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", api.base+"/_ping", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ping: %s", resp.Status)
+	}
+	return nil
+}
+
+// containers lists all containers, including stopped ones
+func (api *dockerApi) containers() ([]dockerApiContainer, error) {
+	containers := []dockerApiContainer{}
+	err := api.doJson("GET", "/containers/json", url.Values{"all": {"1"}}, nil, &containers)
+	return containers, err
+}
+
+// portBindings returns the configured port bindings of a container, which, unlike the listed ports, are also known while the container is stopped
+func (api *dockerApi) portBindings(container string) (map[string][]struct{ HostPort string }, error) {
+	inspect := struct {
+		HostConfig struct {
+			PortBindings map[string][]struct{ HostPort string }
+		}
+	}{}
+	err := api.doJson("GET", "/containers/"+url.PathEscape(container)+"/json", nil, nil, &inspect)
+	return inspect.HostConfig.PortBindings, err
+}
+
+// start starts a container; starting a running container is not an error
+func (api *dockerApi) start(container string) error {
+	return api.doJson("POST", "/containers/"+url.PathEscape(container)+"/start", nil, nil, nil)
+}
+
+// stop stops a container with a timeout of 1 second; stopping a stopped container is not an error
+func (api *dockerApi) stop(container string) error {
+	return api.doJson("POST", "/containers/"+url.PathEscape(container)+"/stop", url.Values{"t": {"1"}}, nil, nil)
+}
+
+// remove removes a stopped container
+func (api *dockerApi) remove(container string) error {
+	return api.doJson("DELETE", "/containers/"+url.PathEscape(container), nil, nil, nil)
+}
+
+// exec runs a command in a container, streaming output as it arrives; without a tty, stdout and stderr are demultiplexed.
+// A non-zero exit code results in a dockerExitError.
+func (api *dockerApi) exec(container string, command []string, env []string, tty bool, stdout io.Writer, stderr io.Writer) error {
+	created := struct{ Id string }{}
+	err := api.doJson("POST", "/containers/"+url.PathEscape(container)+"/exec", nil, map[string]interface{}{
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Tty":          tty,
+		"Env":          env,
+		"Cmd":          command,
+	}, &created)
+	if err != nil {
+		return err
+	}
+	start, err := json.Marshal(map[string]interface{}{"Detach": false, "Tty": tty})
+	if err != nil {
+		return err
+	}
+	resp, err := api.do("POST", "/exec/"+created.Id+"/start", nil, bytes.NewReader(start), "application/json")
+	if err != nil {
+		return err
+	}
+	if tty {
+		_, err = io.Copy(stdout, resp.Body)
+	} else {
+		err = dockerDemux(resp.Body, stdout, stderr)
+	}
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	state := struct {
+		Running  bool
+		ExitCode int
+	}{}
+	if err = api.doJson("GET", "/exec/"+created.Id+"/json", nil, nil, &state); err != nil {
+		return err
+	}
+	if state.ExitCode != 0 {
+		return &dockerExitError{state.ExitCode}
+	}
+	return nil
+}
+
+// dockerDemux splits a multiplexed exec stream into stdout and stderr; each frame has an 8 byte header holding the stream type and frame size
+func dockerDemux(r io.Reader, stdout io.Writer, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		_, err := io.ReadFull(r, header)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		w := stdout
+		if header[0] == 2 {
+			w = stderr
+		}
+		if _, err = io.CopyN(w, r, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			return err
+		}
+	}
+}
+
+// isDir returns whether a path exists in a container and is a directory
+func (api *dockerApi) isDir(container string, dst string) (bool, error) {
+	resp, err := api.do("HEAD", "/containers/"+url.PathEscape(container)+"/archive", url.Values{"path": {dst}}, nil, "")
+	if err != nil {
+		apiErr := &dockerApiError{}
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	resp.Body.Close()
+	stat := struct{ Mode os.FileMode }{}
+	contents, err := base64.StdEncoding.DecodeString(resp.Header.Get("X-Docker-Container-Path-Stat"))
+	if err != nil {
+		return false, err
+	}
+	if err = json.Unmarshal(contents, &stat); err != nil {
+		return false, err
+	}
+	return stat.Mode.IsDir(), nil
+}
+
+// upload copies a local file or directory to a container, with the same semantics as `docker cp`: if the destination is an existing
+// directory, the source is copied into it, otherwise it is copied as the destination
+func (api *dockerApi) upload(container string, source string, destination string) error {
+	dir, name := path.Split(destination)
+	isDir, err := api.isDir(container, destination)
+	if err != nil {
+		return err
+	}
+	if isDir {
+		dir = destination
+		name = filepath.Base(source)
+	}
+	if dir == "" {
+		dir = "/"
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(dockerTarPath(pw, source, name))
+	}()
+	resp, err := api.do("PUT", "/containers/"+url.PathEscape(container)+"/archive", url.Values{"path": {dir}}, pr, "application/x-tar")
+	pr.Close()
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// download copies a file or directory from a container, with the same semantics as `docker cp`
+func (api *dockerApi) download(container string, source string, destination string) error {
+	resp, err := api.do("GET", "/containers/"+url.PathEscape(container)+"/archive", url.Values{"path": {source}}, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return dockerUntar(resp.Body, destination)
+}
+
+// dockerTarPath writes a file or directory to a tar stream, naming it name; ownership is reset to root, like `docker cp` does
+func dockerTarPath(w io.Writer, source string, name string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(source, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(fpath); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, fpath)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(name, filepath.ToSlash(rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "root", "root"
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(fpath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// dockerUntar extracts a tar stream holding one file or directory: into destination if it is an existing directory, otherwise as destination;
+// links are never followed, so that an entry cannot be written outside destination through a symlink extracted earlier
+func dockerUntar(r io.Reader, destination string) error {
+	into := false
+	if st, err := os.Stat(destination); err == nil && st.IsDir() {
+		into = true
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean("/" + hdr.Name)[1:]
+		if name == "" {
+			continue
+		}
+		target := filepath.Join(destination, filepath.FromSlash(name))
+		if !into {
+			parts := strings.SplitN(name, "/", 2)
+			target = destination
+			if len(parts) == 2 {
+				target = filepath.Join(destination, filepath.FromSlash(parts[1]))
+			}
+		}
+		if err = dockerUntarCheckPath(destination, target); err != nil {
+			return err
+		}
+		// an existing symlink in place of the entry is replaced, not followed
+		if st, err := os.Lstat(target); err == nil && st.Mode()&os.ModeSymlink != 0 && target != destination {
+			if err = os.Remove(target); err != nil {
+				return err
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(target, os.FileMode(hdr.Mode)|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			os.Remove(target)
+			if err = os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// dockerUntarCheckPath checks that target is within destination, and that none of the directories between them is a symlink
+func dockerUntarCheckPath(destination string, target string) error {
+	rel, err := filepath.Rel(destination, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("archive entry %s is outside of %s", target, destination)
+	}
+	if rel == "." {
+		return nil
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	dir := destination
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		if st, err := os.Lstat(dir); err == nil && st.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("archive entry %s: %s is a symlink", target, dir)
+		}
+	}
+	return nil
+}
+
+// inventoryApi adds all containers of the current type (servers or clients) to the inventory, using a single list call
+func (d *backendDocker) inventoryApi(ij *inventoryJson, servers bool) error {
+	containers, err := d.api.containers()
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		name := c.name()
+		if !strings.HasPrefix(name, dockerNameHeader) {
+			continue
+		}
+		nameNo := strings.Split(strings.TrimPrefix(name, dockerNameHeader), "_")
+		if len(nameNo) != 2 {
+			continue
+		}
+		item := &dockerInventoryContainer{
+			id:         c.Id,
+			name:       nameNo[0],
+			nodeNo:     nameNo[1],
+			status:     c.Status,
+			image:      dockerImageName(c.Image),
+			clientType: c.Labels["aerolab.client.type"],
+			labels:     c.Labels,
+		}
+		if len(item.id) > 12 {
+			item.id = item.id[:12]
+		}
+		if item.labels == nil {
+			item.labels = make(map[string]string)
+		}
+		if ips := c.ips(); len(ips) > 0 {
+			item.ip = ips[0]
+		}
+		for _, port := range c.Ports {
+			if port.PublicPort > 0 {
+				item.exposePort = strconv.Itoa(port.PublicPort)
+				item.intPort = strconv.Itoa(port.PrivatePort)
+				break
+			}
+		}
+		// the list call has the ports of running containers; only stopped containers without listed ports are inspected for their configured bindings
+		if item.exposePort == "" && len(c.Ports) == 0 && c.State != "running" && c.State != "paused" {
+			bindings, err := d.api.portBindings(c.Id)
+			if err != nil {
+				return err
+			}
+			for port, binds := range bindings {
+				item.intPort = strings.Split(port, "/")[0]
+				item.exposePort = item.intPort
+				if len(binds) > 0 && binds[0].HostPort != "" {
+					item.exposePort = binds[0].HostPort
+				}
+			}
+		}
+		d.inventoryAppend(ij, servers, item)
+	}
+	return nil
+}
+
+// containerNames lists the names of all containers
+func (d *backendDocker) containerNames() ([]string, error) {
+	names := []string{}
+	if d.api != nil {
+		containers, err := d.api.containers()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			names = append(names, c.name())
+		}
+		return names, nil
+	}
+	out, err := d.command("container", "list", "-a", "--format", "{{json .Names}}").CombinedOutput()
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		// podman may print names as a list
+		names = append(names, strings.Trim(scanner.Text(), "'\"[]"))
+	}
+	return names, nil
+}
+
+// apiNodeIps returns the addresses of the nodes of a cluster, stopped nodes have no addresses
+func (d *backendDocker) apiNodeIps(name string) (map[int]string, error) {
+	containers, err := d.api.containers()
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	ips := make(map[int]string)
+	for _, c := range containers {
+		cname := c.name()
+		if !strings.HasPrefix(cname, dockerNameHeader+name+"_") {
+			continue
+		}
+		node, err := strconv.Atoi(strings.TrimPrefix(cname, dockerNameHeader+name+"_"))
+		if err != nil {
+			continue
+		}
+		found = true
+		if cips := c.ips(); len(cips) > 0 {
+			ips[node] = cips[0]
+		}
+	}
+	if !found {
+		return nil, errors.New("cluster not found")
+	}
+	return ips, nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// dockerApiFake is a fake engine API, serving a fixed container list and recording uploads and container inspects
+type dockerApiFake struct {
+	lock       sync.Mutex
+	containers []dockerApiContainer
+	dirs       map[string]bool
+	uploads    map[string]string
+	inspected  []string
+}
+
+func (f *dockerApiFake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, "/"+dockerApiVersion)
+	if strings.HasPrefix(p, "/containers/") && strings.HasSuffix(p, "/json") && p != "/containers/json" {
+		f.lock.Lock()
+		f.inspected = append(f.inspected, strings.Split(p, "/")[2])
+		f.lock.Unlock()
+	}
+	switch {
+	case p == "/_ping":
+		w.Write([]byte("OK"))
+	case p == "/containers/json":
+		json.NewEncoder(w).Encode(f.containers)
+	case p == "/containers/fedcba9876543210/json":
+		w.Write([]byte(`{"HostConfig":{"PortBindings":{"3100/tcp":[{"HostIp":"","HostPort":"3101"}]}}}`))
+	case strings.HasPrefix(p, "/containers/") && strings.HasSuffix(p, "/json"):
+		w.Write([]byte(`{"HostConfig":{}}`))
+	case strings.HasSuffix(p, "/start") && strings.HasPrefix(p, "/containers/"):
+		w.WriteHeader(http.StatusNotModified)
+	case strings.HasSuffix(p, "/stop"):
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "DELETE":
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"message":"You cannot remove a running container"}`))
+	case strings.HasSuffix(p, "/exec"):
+		cmd := struct{ Cmd []string }{}
+		json.NewDecoder(r.Body).Decode(&cmd)
+		w.Write([]byte(`{"Id":"` + strings.Join(cmd.Cmd, "-") + `"}`))
+	case strings.HasPrefix(p, "/exec/") && strings.HasSuffix(p, "/start"):
+		for _, frame := range []struct {
+			stream byte
+			data   string
+		}{{1, "out1\n"}, {2, "err\n"}, {1, "out2\n"}} {
+			header := make([]byte, 8)
+			header[0] = frame.stream
+			binary.BigEndian.PutUint32(header[4:], uint32(len(frame.data)))
+			w.Write(append(header, frame.data...))
+		}
+	case strings.HasPrefix(p, "/exec/") && strings.HasSuffix(p, "/json"):
+		code := 0
+		if strings.Contains(p, "false") {
+			code = 3
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"Running": false, "ExitCode": code})
+	case strings.HasSuffix(p, "/archive") && r.Method == "HEAD":
+		if !f.dirs[r.URL.Query().Get("path")] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		stat, _ := json.Marshal(map[string]interface{}{"name": "dir", "mode": uint32(os.ModeDir | 0755)})
+		w.Header().Set("X-Docker-Container-Path-Stat", base64.StdEncoding.EncodeToString(stat))
+	case strings.HasSuffix(p, "/archive") && r.Method == "PUT":
+		tr := tar.NewReader(r.Body)
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				break
+			}
+			contents, _ := io.ReadAll(tr)
+			f.lock.Lock()
+			f.uploads[filepath.Join(r.URL.Query().Get("path"), hdr.Name)] = string(contents)
+			f.lock.Unlock()
+		}
+	case strings.HasSuffix(p, "/archive") && r.Method == "GET":
+		tw := tar.NewWriter(w)
+		tw.WriteHeader(&tar.Header{Name: "logs/", Typeflag: tar.TypeDir, Mode: 0755})
+		tw.WriteHeader(&tar.Header{Name: "logs/aerospike.log", Typeflag: tar.TypeReg, Mode: 0644, Size: 4})
+		tw.Write([]byte("log\n"))
+		tw.Close()
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"no such container"}`))
+	}
+}
+
+func dockerApiFakeServer(t *testing.T) (*dockerApiFake, *dockerApi) {
+	fake := &dockerApiFake{dirs: map[string]bool{"/opt": true}, uploads: make(map[string]string)}
+	fake.containers = []dockerApiContainer{
+		{Id: "0123456789abcdef", Names: []string{"/aerolab-mydc_1"}, Image: "aerolab-ubuntu_22.04:7.0.0", State: "running", Status: "Up 2 minutes", Labels: map[string]string{"owner": "me"}},
+		{Id: "fedcba9876543210", Names: []string{"/aerolab-mydc_2"}, Image: "localhost/aerolab-ubuntu_22.04:7.0.0", State: "exited", Status: "Exited (0) 1 minute ago"},
+		{Id: "aaaaaaaaaaaaaaaa", Names: []string{"/aerolab_c-tools_1"}, Image: "ubuntu:22.04", State: "running", Status: "Up 1 minute", Labels: map[string]string{"aerolab.client.type": "tools"}},
+	}
+	fake.containers[0].Ports = append(fake.containers[0].Ports, struct {
+		PrivatePort int
+		PublicPort  int
+	}{3100, 3100})
+	fake.containers[0].NetworkSettings.Networks = map[string]struct{ IPAddress string }{"default": {"172.17.0.2"}, "aaa": {"10.0.0.2"}}
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: fake}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	api, err := newDockerApi("unix://" + socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = api.ping(); err != nil {
+		t.Fatal(err)
+	}
+	return fake, api
+}
+
+func TestDockerApi(t *testing.T) {
+	fake, api := dockerApiFakeServer(t)
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	if err := api.exec("aerolab-mydc_1", []string{"cat", "x"}, nil, false, stdout, stderr); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "out1\nout2\n" || stderr.String() != "err\n" {
+		t.Errorf("unexpected demultiplexed output %q %q", stdout.String(), stderr.String())
+	}
+	err := api.exec("aerolab-mydc_1", []string{"false"}, nil, false, io.Discard, io.Discard)
+	if checkExecRetcode(err) != 3 {
+		t.Errorf("expected exit code 3, got %v", err)
+	}
+	if err = api.remove("aerolab-mydc_1"); err == nil || !strings.Contains(err.Error(), "cannot remove a running container") {
+		t.Errorf("expected API error message, got %v", err)
+	}
+
+	src := filepath.Join(t.TempDir(), "features.conf")
+	os.WriteFile(src, []byte("features"), 0644)
+	if err = api.upload("aerolab-mydc_1", src, "/etc/aerospike/features.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if err = api.upload("aerolab-mydc_1", src, "/opt"); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"/etc/aerospike/features.conf": "features", "/opt/features.conf": "features"}
+	if !reflect.DeepEqual(fake.uploads, expected) {
+		t.Errorf("expected uploads %v, got %v", expected, fake.uploads)
+	}
+
+	dst := filepath.Join(t.TempDir(), "downloaded")
+	if err = api.download("aerolab-mydc_1", "/var/log/aerospike", dst); err != nil {
+		t.Fatal(err)
+	}
+	if contents, err := os.ReadFile(filepath.Join(dst, "aerospike.log")); err != nil || string(contents) != "log\n" {
+		t.Errorf("download as new path: %q, %v", contents, err)
+	}
+	if err = api.download("aerolab-mydc_1", "/var/log/aerospike", dst); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dst, "logs", "aerospike.log")); err != nil {
+		t.Errorf("download into existing directory: %s", err)
+	}
+}
+
+func TestDockerApiBackend(t *testing.T) {
+	fake, api := dockerApiFakeServer(t)
+	d := &backendDocker{api: api}
+	d.WorkOnServers()
+	defer d.WorkOnServers()
+
+	clusters, err := d.ClusterList()
+	if err != nil || !reflect.DeepEqual(clusters, []string{"mydc"}) {
+		t.Errorf("ClusterList: %v, %v", clusters, err)
+	}
+	nodes, err := d.NodeListInCluster("mydc")
+	if err != nil || !reflect.DeepEqual(nodes, []int{1, 2}) {
+		t.Errorf("NodeListInCluster: %v, %v", nodes, err)
+	}
+	ips, err := d.GetNodeIpMap("mydc", false)
+	if err != nil || !reflect.DeepEqual(ips, map[int]string{1: "10.0.0.2"}) {
+		t.Errorf("GetNodeIpMap: %v, %v", ips, err)
+	}
+	if _, err = d.GetClusterNodeIps("missing"); err == nil {
+		t.Error("GetClusterNodeIps: expected cluster not found error")
+	}
+	if err = d.ClusterStart("mydc", nil); err != nil {
+		t.Errorf("ClusterStart: starting running containers: %s", err)
+	}
+	out, err := d.RunCommands("mydc", [][]string{{"cat", "x"}}, []int{1})
+	if err != nil || len(out) != 1 || string(out[0]) != "out1\nerr\nout2\n" {
+		t.Errorf("RunCommands: %q, %v", out, err)
+	}
+
+	fake.lock.Lock()
+	fake.inspected = nil
+	fake.lock.Unlock()
+	ij, err := d.Inventory("", []int{InventoryItemClusters, InventoryItemClients})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fake.inspected, []string{"fedcba9876543210"}) {
+		t.Errorf("inventory must only inspect stopped containers, inspected: %v", fake.inspected)
+	}
+	if len(ij.Clusters) != 2 || len(ij.Clients) != 1 {
+		t.Fatalf("unexpected inventory %+v", ij)
+	}
+	for _, node := range ij.Clusters {
+		if node.AerospikeVersion != "7.0.0" || node.Distribution != "ubuntu" {
+			t.Errorf("node %s: unexpected image details %+v", node.NodeNo, node)
+		}
+		switch node.NodeNo {
+		case "1":
+			if node.InstanceId != "0123456789ab" || node.PrivateIp != "10.0.0.2" || node.DockerExposePorts != "3100" || node.Owner != "me" || node.State != "Up_2_minutes" {
+				t.Errorf("node 1: unexpected inventory entry %+v", node)
+			}
+		case "2":
+			// stopped containers have no listed ports, the configured port bindings are used
+			if node.DockerExposePorts != "3101" || node.DockerInternalPort != "3100" || node.PrivateIp != "" {
+				t.Errorf("node 2: unexpected inventory entry %+v", node)
+			}
+		}
+	}
+	if ij.Clients[0].ClientType != "tools" || ij.Clients[0].ClientName != "tools" {
+		t.Errorf("unexpected client %+v", ij.Clients[0])
+	}
+}
+
+func TestDockerUntarSymlinks(t *testing.T) {
+	outside := t.TempDir()
+	for name, entries := range map[string][]tar.Header{
+		"file through symlink":     {{Name: "logs/", Typeflag: tar.TypeDir, Mode: 0755}, {Name: "logs/out", Typeflag: tar.TypeSymlink, Linkname: outside}, {Name: "logs/out/escaped", Typeflag: tar.TypeReg, Mode: 0644, Size: 4}},
+		"dir through symlink":      {{Name: "logs/", Typeflag: tar.TypeDir, Mode: 0755}, {Name: "logs/out", Typeflag: tar.TypeSymlink, Linkname: "../../../../../../../../" + outside}, {Name: "logs/out/escaped/", Typeflag: tar.TypeDir, Mode: 0755}},
+		"file replacing a symlink": {{Name: "logs/", Typeflag: tar.TypeDir, Mode: 0755}, {Name: "logs/escaped", Typeflag: tar.TypeSymlink, Linkname: filepath.Join(outside, "escaped")}, {Name: "logs/escaped", Typeflag: tar.TypeReg, Mode: 0644, Size: 4}},
+	} {
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		for _, hdr := range entries {
+			hdr := hdr
+			tw.WriteHeader(&hdr)
+			if hdr.Size > 0 {
+				tw.Write([]byte("bad\n"))
+			}
+		}
+		tw.Close()
+		dst := t.TempDir()
+		dockerUntar(buf, dst)
+		if _, err := os.Lstat(filepath.Join(outside, "escaped")); err == nil {
+			t.Fatalf("%s: archive entry written outside of the destination", name)
+		}
+	}
+}
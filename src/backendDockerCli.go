@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// dockerDetectCli returns the container engine command to use; if one is not configured, docker is preferred, falling back to podman
+func dockerDetectCli(configured string) (string, error) {
+	switch configured {
+	case "docker", "podman":
+		if _, err := exec.LookPath(configured); err != nil {
+			return "", fmt.Errorf("%s command not found: %s", configured, err)
+		}
+		return configured, nil
+	case "":
+		for _, cli := range []string{"docker", "podman"} {
+			if _, err := exec.LookPath(cli); err == nil {
+				return cli, nil
+			}
+		}
+		return "", errors.New("neither docker nor podman command found")
+	default:
+		return "", fmt.Errorf("container CLI %s not supported, supported: docker|podman", configured)
+	}
+}
+
+// dockerParseInfo finds the architecture and rootless mode in the output of `docker info` or `podman info`
+func dockerParseInfo(out string) (isArm bool, rootless bool) {
+	archFound := false
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.Trim(line, "\r\n\t ")
+		switch {
+		case !archFound && (strings.HasPrefix(line, "Architecture: ") || strings.HasPrefix(line, "arch: ")):
+			arch := strings.SplitN(line, ": ", 2)[1]
+			isArm = strings.Contains(arch, "arm") || strings.Contains(arch, "aarch")
+			archFound = true
+		case line == "rootless" || line == "rootless: true":
+			rootless = true
+		}
+	}
+	return isArm, rootless
+}
+
+// engine returns the container engine command of this backend, docker unless Init detected podman
+func (d *backendDocker) engine() string {
+	if d.cli == "" {
+		return "docker"
+	}
+	return d.cli
+}
+
+func (d *backendDocker) isPodman() bool {
+	return d.engine() == "podman"
+}
+
+// command returns a container CLI command talking to the docker host of this backend
+func (d *backendDocker) command(args ...string) *exec.Cmd {
+	return d.commandContext(context.Background(), args...)
+}
+
+func (d *backendDocker) commandContext(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, d.engine(), args...)
+	if d.host != "" {
+		cmd.Env = append(os.Environ(), "DOCKER_HOST="+d.host, "CONTAINER_HOST="+d.host)
+	}
+	return cmd
+}
+
+// dockerImageName strips the localhost/ prefix podman adds to locally committed images
+func dockerImageName(image string) string {
+	return strings.TrimPrefix(image, "localhost/")
+}
+
+// publicImage qualifies official images with their registry, as podman may refuse to resolve short names
+func (d *backendDocker) publicImage(image string) string {
+	if !d.isPodman() || strings.Contains(strings.Split(image, ":")[0], "/") {
+		return image
+	}
+	return "docker.io/library/" + image
+}
+
+// sysctl sets a kernel parameter of the docker host, or of the VM the container engine runs in, using a privileged container
+func (d *backendDocker) sysctl(setting string) ([]byte, error) {
+	return d.command("run", "--rm", "-i", "--privileged", d.publicImage("ubuntu:22.04"), "sysctl", "-w", setting).CombinedOutput()
+}
+
+// dockerSysctlMaxMapCount raises vm.max_map_count, required by elasticsearch, on the docker hosts running the given nodes; failures are only reported, as the nodes may still start
+func dockerSysctlMaxMapCount(name string, nodes []int, consequence string) {
+	hosts, err := dockerNodeHosts(name, nodes)
+	if err != nil {
+		fmt.Printf("Workaround `sysctl -w vm.max_map_count=262144` for docker failed, %s...\n", consequence)
+		fmt.Println(err)
+		return
+	}
+	for _, d := range hosts {
+		out, err := d.sysctl("vm.max_map_count=262144")
+		if err != nil {
+			fmt.Printf("Workaround `sysctl -w vm.max_map_count=262144` for docker failed, %s...\n", consequence)
+			fmt.Println(err)
+			fmt.Println(string(out))
+		}
+	}
+}
+
+// dockerNodeHosts returns the docker hosts running the given nodes of a cluster, or nothing if the backend is not docker; no nodes means all nodes
+func dockerNodeHosts(name string, nodes []int) ([]*backendDocker, error) {
+	back := b
+	if cache, ok := back.(*backendCache); ok {
+		back = cache.backend
+	}
+	if p, ok := back.(*backendDockerPool); ok {
+		return p.nodeHosts(name, nodes)
+	}
+	return dockerHosts(), nil
+}
+
+// dockerHosts returns the docker hosts of the backend in use, or nothing if the backend is not docker
+func dockerHosts() []*backendDocker {
+	back := b
+	if cache, ok := back.(*backendCache); ok {
+		back = cache.backend
+	}
+	switch d := back.(type) {
+	case *backendDocker:
+		return []*backendDocker{d}
+	case *backendDockerPool:
+		return d.hosts
+	}
+	return nil
+}
+
+// dockerCheckNetRules returns an error if network rules cannot be applied in containers, as is the case with rootless container engines
+func dockerCheckNetRules() error {
+	for _, d := range dockerHosts() {
+		if d.rootless {
+			return fmt.Errorf("%s is running in rootless mode, where iptables and tc are not available in containers; net block, net unblock and net loss-delay require a rootful container engine (ex: podman machine set --rootful)", d.engine())
+		}
+	}
+	return nil
+}
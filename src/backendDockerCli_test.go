@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDockerParseInfo(t *testing.T) {
+	docker := `Client:
+ Context:    default
+Server:
+ Server Version: 24.0.7
+ Security Options:
+  seccomp
+   Profile: builtin
+  rootless
+  cgroupns
+ Architecture: aarch64
+ CPUs: 4
+`
+	podman := `host:
+  arch: amd64
+  buildahVersion: 1.33.2
+  security:
+    apparmorEnabled: false
+    rootless: true
+    seccompEnabled: true
+`
+	rootful := strings.ReplaceAll(podman, "rootless: true", "rootless: false")
+	for name, test := range map[string]struct {
+		out      string
+		arm      bool
+		rootless bool
+	}{
+		"docker rootless": {docker, true, true},
+		"docker":          {strings.ReplaceAll(docker, "  rootless\n", ""), true, false},
+		"podman rootless": {podman, false, true},
+		"podman":          {rootful, false, false},
+	} {
+		arm, rootless := dockerParseInfo(test.out)
+		if arm != test.arm || rootless != test.rootless {
+			t.Errorf("%s: expected arm=%t rootless=%t, got arm=%t rootless=%t", name, test.arm, test.rootless, arm, rootless)
+		}
+	}
+}
+
+func TestDockerImageNames(t *testing.T) {
+	d := &backendDocker{cli: "docker"}
+	if img := d.publicImage("ubuntu:22.04"); img != "ubuntu:22.04" {
+		t.Errorf("docker: unexpected image %s", img)
+	}
+	d.cli = "podman"
+	for in, out := range map[string]string{"ubuntu:22.04": "docker.io/library/ubuntu:22.04", "quay.io/centos/centos:7": "quay.io/centos/centos:7"} {
+		if img := d.publicImage(in); img != out {
+			t.Errorf("podman: expected %s, got %s", out, img)
+		}
+	}
+	if img := dockerImageName("localhost/aerolab-ubuntu_22.04:7.0.0"); img != "aerolab-ubuntu_22.04:7.0.0" {
+		t.Errorf("unexpected image name %s", img)
+	}
+	if _, err := dockerDetectCli("lxc"); err == nil {
+		t.Error("expected unsupported container CLI error")
+	}
+}
+
+func TestDockerCheckNetRules(t *testing.T) {
+	defer func(back backend) { b = back }(b)
+	b = &backendMock{}
+	if err := dockerCheckNetRules(); err != nil {
+		t.Errorf("mock: unexpected error %s", err)
+	}
+	b = &backendDocker{cli: "podman"}
+	if err := dockerCheckNetRules(); err != nil {
+		t.Errorf("rootful: unexpected error %s", err)
+	}
+	pool := newBackendDockerPool([]string{"tcp://server1:2376", "tcp://server2:2376"})
+	pool.hosts[1].cli = "podman"
+	pool.hosts[1].rootless = true
+	b = &backendCache{backend: pool}
+	if err := dockerCheckNetRules(); err == nil || !strings.Contains(err.Error(), "podman is running in rootless mode") {
+		t.Errorf("rootless: expected rootless error, got %v", err)
+	}
+}
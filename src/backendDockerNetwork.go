@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"strings"
 	"text/tabwriter"
 )
@@ -19,23 +18,67 @@ type dockerListNetwork struct {
 			Gateway string
 		}
 	}
+	Subnets []struct {
+		Subnet  string
+		Gateway string
+	}
 	Options map[string]string
 }
 
-func (d *backendDocker) CreateNetwork(name string, driver string, subnet string, mtu string) error {
+// dockerNetworker lists and creates networks, implemented by a single docker host and by a pool of hosts
+type dockerNetworker interface {
+	CreateNetwork(name string, driver string, subnet string, mtu string, parent string) error
+	ListNetworks(csv bool, writer io.Writer) error
+}
+
+func (d *backendDocker) CreateNetwork(name string, driver string, subnet string, mtu string, parent string) error {
+	return d.createNetwork(name, driver, subnet, "", mtu, parent)
+}
+
+// createNetwork creates a network, optionally limiting container addresses to ipRange of the subnet; parent is the host interface for macvlan and ipvlan networks
+func (d *backendDocker) createNetwork(name string, driver string, subnet string, ipRange string, mtu string, parent string) error {
 	if driver == "" {
 		driver = "bridge"
 	}
+	if d.isPodman() {
+		opts := []string{"network", "create", "-d", driver}
+		if subnet != "" {
+			opts = append(opts, "--subnet", subnet)
+		}
+		if ipRange != "" {
+			opts = append(opts, "--ip-range", ipRange)
+		}
+		if mtu != "" {
+			opts = append(opts, "--opt", "mtu="+mtu)
+		}
+		if parent != "" {
+			opts = append(opts, "--opt", "parent="+parent)
+		}
+		opts = append(opts, name)
+		out, err := d.command(opts...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %s", err, string(out))
+		}
+		return nil
+	}
 	opts := []string{"network", "create", "--attachable", "-d", driver}
 	if subnet != "" {
 		opts = append(opts, "--subnet", subnet)
 	}
-	opts = append(opts, "--opt", "com.docker.network.bridge.enable_icc=true", "--opt", "com.docker.network.bridge.enable_ip_masquerade=true", "--opt", "com.docker.network.bridge.host_binding_ipv4=0.0.0.0", "--opt", "com.docker.network.bridge.name="+name)
+	if ipRange != "" {
+		opts = append(opts, "--ip-range", ipRange)
+	}
+	if driver == "bridge" {
+		opts = append(opts, "--opt", "com.docker.network.bridge.enable_icc=true", "--opt", "com.docker.network.bridge.enable_ip_masquerade=true", "--opt", "com.docker.network.bridge.host_binding_ipv4=0.0.0.0", "--opt", "com.docker.network.bridge.name="+name)
+	}
 	if mtu != "" {
 		opts = append(opts, "--opt", "com.docker.network.driver.mtu="+mtu)
 	}
+	if parent != "" {
+		opts = append(opts, "--opt", "parent="+parent)
+	}
 	opts = append(opts, name)
-	out, err := exec.Command("docker", opts...).CombinedOutput()
+	out, err := d.command(opts...).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("%s: %s", err, string(out))
 	}
@@ -43,7 +86,7 @@ func (d *backendDocker) CreateNetwork(name string, driver string, subnet string,
 }
 
 func (d *backendDocker) DeleteNetwork(name string) error {
-	out, err := exec.Command("docker", "network", "rm", name).CombinedOutput()
+	out, err := d.command("network", "rm", name).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("%s: %s", err, string(out))
 	}
@@ -51,16 +94,26 @@ func (d *backendDocker) DeleteNetwork(name string) error {
 }
 
 func (d *backendDocker) PruneNetworks() error {
-	out, err := exec.Command("docker", "network", "prune", "-f").CombinedOutput()
+	out, err := d.command("network", "prune", "-f").CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("%s: %s", err, string(out))
 	}
 	return nil
 }
 func (d *backendDocker) ListNetworks(csv bool, writer io.Writer) error {
-	out, err := exec.Command("docker", "network", "list", "--format", "{{.Name}}").CombinedOutput()
+	netlist, err := d.networks()
 	if err != nil {
-		return fmt.Errorf("%s: %s", err, string(out))
+		return err
+	}
+	dockerPrintNetworks([]string{d.host}, [][]dockerListNetwork{netlist}, csv, writer)
+	return nil
+}
+
+// networks returns the networks of the docker host of this backend
+func (d *backendDocker) networks() ([]dockerListNetwork, error) {
+	out, err := d.command("network", "list", "--format", "{{.Name}}").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err, string(out))
 	}
 	networks := []string{"network", "inspect"}
 	for _, line := range strings.Split(string(out), "\n") {
@@ -70,43 +123,68 @@ func (d *backendDocker) ListNetworks(csv bool, writer io.Writer) error {
 		}
 		networks = append(networks, line)
 	}
-	out, err = exec.Command("docker", networks...).CombinedOutput()
+	out, err = d.command(networks...).CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("%s: %s", err, string(out))
+		return nil, fmt.Errorf("%s: %s", err, string(out))
 	}
 
 	netlist := []dockerListNetwork{}
 	err = json.Unmarshal(out, &netlist)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return netlist, nil
+}
+
+// dockerPrintNetworks lists the networks of each docker host, netlists being indexed like hosts; with more than one host, the host is printed as the last column
+func dockerPrintNetworks(hosts []string, netlists [][]dockerListNetwork, csv bool, writer io.Writer) {
 	if writer == nil {
 		writer = os.Stdout
 	}
+	multi := len(hosts) > 1
 	w := tabwriter.NewWriter(writer, 1, 1, 4, ' ', 0)
 	if !csv {
-		fmt.Fprintln(w, "NAME\tDRIVER\tSUBNETS\tMTU")
-		fmt.Fprintln(w, "----\t------\t-------\t---")
+		if multi {
+			fmt.Fprintln(w, "NAME\tDRIVER\tSUBNETS\tMTU\tHOST")
+			fmt.Fprintln(w, "----\t------\t-------\t---\t----")
+		} else {
+			fmt.Fprintln(w, "NAME\tDRIVER\tSUBNETS\tMTU")
+			fmt.Fprintln(w, "----\t------\t-------\t---")
+		}
+	} else if multi {
+		fmt.Fprintln(writer, "name,driver,subnets,mtu,host")
 	} else {
 		fmt.Fprintln(writer, "name,driver,subnets,mtu")
 	}
-	for _, net := range netlist {
-		subnets := []string{}
-		for _, sub := range net.IPAM.Config {
-			subnets = append(subnets, sub.Subnet)
-		}
-		mtuOpt, ok := net.Options["com.docker.network.driver.mtu"]
-		if !ok {
-			mtuOpt = "default"
-		}
-		if !csv {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", net.Name, net.Driver, strings.Join(subnets, ","), mtuOpt)
-		} else {
-			fmt.Fprintf(writer, "%s,%s,%s,%s\n", net.Name, net.Driver, strings.Join(subnets, ","), mtuOpt)
+	for i, netlist := range netlists {
+		for _, net := range netlist {
+			subnets := []string{}
+			for _, sub := range net.IPAM.Config {
+				subnets = append(subnets, sub.Subnet)
+			}
+			// podman lists subnets and options in its own format
+			for _, sub := range net.Subnets {
+				subnets = append(subnets, sub.Subnet)
+			}
+			mtuOpt, ok := net.Options["com.docker.network.driver.mtu"]
+			if !ok {
+				mtuOpt, ok = net.Options["mtu"]
+			}
+			if !ok {
+				mtuOpt = "default"
+			}
+			fields := []string{net.Name, net.Driver, strings.Join(subnets, ","), mtuOpt}
+			if multi {
+				fields = append(fields, hosts[i])
+			}
+			if !csv {
+				fmt.Fprintln(w, strings.Join(fields, "\t"))
+			} else {
+				fmt.Fprintln(writer, strings.Join(fields, ","))
+			}
 		}
 	}
 	if !csv {
 		w.Flush()
 	}
-	return nil
 }
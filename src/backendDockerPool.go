@@ -0,0 +1,664 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/bestmethod/inslice"
+)
+
+// backendDockerPool places the nodes of each cluster across a pool of docker hosts; calls concerning nodes are routed to the hosts running them,
+// listings are merged from all hosts, and other calls go to the first host
+type backendDockerPool struct {
+	*backendDocker
+	hosts []*backendDocker
+}
+
+// newBackendDockerPool returns a docker backend spanning the given DOCKER_HOST style endpoints, ex. ssh://user@server1 or tcp://server2:2376
+func newBackendDockerPool(endpoints []string) *backendDockerPool {
+	p := &backendDockerPool{}
+	for _, endpoint := range endpoints {
+		endpoint = strings.Trim(endpoint, "\r\n\t ")
+		if endpoint == "" {
+			continue
+		}
+		p.hosts = append(p.hosts, &backendDocker{host: endpoint})
+	}
+	if len(p.hosts) == 0 {
+		p.hosts = append(p.hosts, &backendDocker{})
+	}
+	p.backendDocker = p.hosts[0]
+	return p
+}
+
+// dockerPoolPlace spreads node numbers round-robin across hosts, returning the nodes to deploy on each host; placement only depends on the node number, so growing a cluster keeps it balanced
+func dockerPoolPlace(nodes []int, hostCount int) [][]int {
+	placement := make([][]int, hostCount)
+	for _, node := range nodes {
+		i := (node - 1) % hostCount
+		placement[i] = append(placement[i], node)
+	}
+	return placement
+}
+
+// dockerPoolIpRanges splits an IPv4 subnet into one address range per host, so that macvlan networks created separately on each host do not hand out the same addresses
+func dockerPoolIpRanges(subnet string, hostCount int) ([]string, error) {
+	_, ipnet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, err
+	}
+	base := ipnet.IP.To4()
+	if base == nil {
+		return nil, errors.New("only IPv4 subnets can be split across docker hosts")
+	}
+	ones, _ := ipnet.Mask.Size()
+	for bits := 0; 1<<bits < hostCount; bits++ {
+		ones++
+	}
+	if ones > 30 {
+		return nil, fmt.Errorf("subnet %s is too small to split across %d docker hosts", subnet, hostCount)
+	}
+	start := uint32(base[0])<<24 | uint32(base[1])<<16 | uint32(base[2])<<8 | uint32(base[3])
+	ranges := []string{}
+	for i := 0; i < hostCount; i++ {
+		ip := start + uint32(i)<<(32-ones)
+		ranges = append(ranges, fmt.Sprintf("%d.%d.%d.%d/%d", byte(ip>>24), byte(ip>>16), byte(ip>>8), byte(ip), ones))
+	}
+	return ranges, nil
+}
+
+func (p *backendDockerPool) Init() error {
+	for _, h := range p.hosts {
+		if err := h.Init(); err != nil {
+			return fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+		if h.isArm != p.hosts[0].isArm {
+			return fmt.Errorf("docker hosts %s and %s have different architectures, all hosts in the pool must be either amd64 or arm64", p.hosts[0].host, h.host)
+		}
+	}
+	return nil
+}
+
+func (p *backendDockerPool) WorkOnClients() {
+	for _, h := range p.hosts {
+		h.WorkOnClients()
+	}
+}
+
+func (p *backendDockerPool) WorkOnServers() {
+	for _, h := range p.hosts {
+		h.WorkOnServers()
+	}
+}
+
+// locate returns the index of the host each node of a cluster runs on
+func (p *backendDockerPool) locate(name string) (map[int]int, error) {
+	nodeHosts := make(map[int]int)
+	for i, h := range p.hosts {
+		nodes, err := h.NodeListInCluster(name)
+		if err != nil {
+			return nil, fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+		for _, node := range nodes {
+			nodeHosts[node] = i
+		}
+	}
+	return nodeHosts, nil
+}
+
+// nodeHost returns the host a node of a cluster runs on
+func (p *backendDockerPool) nodeHost(name string, node int) (*backendDocker, error) {
+	nodeHosts, err := p.locate(name)
+	if err != nil {
+		return nil, err
+	}
+	i, ok := nodeHosts[node]
+	if !ok {
+		return nil, fmt.Errorf("node %d not found in cluster %s", node, name)
+	}
+	return p.hosts[i], nil
+}
+
+// byHost groups nodes of a cluster by the host they run on, indexed like hosts; no nodes means all nodes of the cluster
+func (p *backendDockerPool) byHost(name string, nodes []int) ([][]int, error) {
+	nodeHosts, err := p.locate(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		for node := range nodeHosts {
+			nodes = append(nodes, node)
+		}
+		sort.Ints(nodes)
+	}
+	grouped := make([][]int, len(p.hosts))
+	for _, node := range nodes {
+		i, ok := nodeHosts[node]
+		if !ok {
+			return nil, fmt.Errorf("node %d not found in cluster %s", node, name)
+		}
+		grouped[i] = append(grouped[i], node)
+	}
+	return grouped, nil
+}
+
+func (p *backendDockerPool) ClusterList() ([]string, error) {
+	clusterList := []string{}
+	for _, h := range p.hosts {
+		clusters, err := h.ClusterList()
+		if err != nil {
+			return nil, fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+		for _, cluster := range clusters {
+			if !inslice.HasString(clusterList, cluster) {
+				clusterList = append(clusterList, cluster)
+			}
+		}
+	}
+	return clusterList, nil
+}
+
+func (p *backendDockerPool) NodeListInCluster(name string) ([]int, error) {
+	var nodeList []int
+	for _, h := range p.hosts {
+		nodes, err := h.NodeListInCluster(name)
+		if err != nil {
+			return nil, fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+		nodeList = append(nodeList, nodes...)
+	}
+	sort.Ints(nodeList)
+	return nodeList, nil
+}
+
+// ListTemplates returns the templates available on all hosts
+func (p *backendDockerPool) ListTemplates() ([]backendVersion, error) {
+	var templateList []backendVersion
+	for i, h := range p.hosts {
+		templ, err := h.ListTemplates()
+		if err != nil {
+			return nil, fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+		if i == 0 {
+			templateList = templ
+			continue
+		}
+		common := []backendVersion{}
+		for _, v := range templateList {
+			for _, t := range templ {
+				if t == v {
+					common = append(common, v)
+					break
+				}
+			}
+		}
+		templateList = common
+	}
+	return templateList, nil
+}
+
+// hasTemplate returns whether the given host has the server template for a version
+func (p *backendDockerPool) hasTemplate(h *backendDocker, v backendVersion) (bool, error) {
+	if err := h.versionToReal(&v); err != nil {
+		return false, err
+	}
+	templ, err := h.ListTemplates()
+	if err != nil {
+		return false, fmt.Errorf("docker host %s: %s", h.host, err)
+	}
+	for _, t := range templ {
+		if t.distroName == v.distroName && t.distroVersion == v.distroVersion && t.aerospikeVersion == v.aerospikeVersion {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeployTemplate builds the template on each host which does not have it yet
+func (p *backendDockerPool) DeployTemplate(v backendVersion, script string, files []fileListReader, extra *backendExtra) error {
+	for _, h := range p.hosts {
+		found, err := p.hasTemplate(h, v)
+		if err != nil {
+			return err
+		}
+		if found {
+			continue
+		}
+		for _, file := range files {
+			if _, err = file.fileContents.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		if err = h.DeployTemplate(v, script, files, extra); err != nil {
+			return fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+	}
+	return nil
+}
+
+func (p *backendDockerPool) TemplateDestroy(v backendVersion) error {
+	destroyed := false
+	for _, h := range p.hosts {
+		found, err := p.hasTemplate(h, v)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		if err = h.TemplateDestroy(v); err != nil {
+			return fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+		destroyed = true
+	}
+	if !destroyed {
+		return p.hosts[0].TemplateDestroy(v)
+	}
+	return nil
+}
+
+func (p *backendDockerPool) VacuumTemplates() error {
+	errs := ""
+	for _, h := range p.hosts {
+		if err := h.VacuumTemplates(); err != nil {
+			errs = errs + "docker host " + h.host + ": " + err.Error() + "\n"
+		}
+	}
+	if errs == "" {
+		return nil
+	}
+	return errors.New(errs)
+}
+
+// VacuumTemplate removes the temporary template container from whichever hosts it was being built on
+func (p *backendDockerPool) VacuumTemplate(v backendVersion) error {
+	var err error
+	for _, h := range p.hosts {
+		if herr := h.VacuumTemplate(v); herr == nil {
+			return nil
+		} else if err == nil {
+			err = herr
+		}
+	}
+	return err
+}
+
+// DeployCluster places new nodes round-robin across hosts; nodes on different hosts can only reach each other over an overlay or macvlan network
+func (p *backendDockerPool) DeployCluster(v backendVersion, name string, nodeCount int, extra *backendExtra) error {
+	name = strings.Trim(name, "\r\n\t ")
+	if len(p.hosts) > 1 && extra.network == "" {
+		return errors.New("deploying across multiple docker hosts requires a network spanning them, create one with `aerolab config docker create-network -d overlay|macvlan` and specify it using --network")
+	}
+	if err := dockerDeployNetwork(p, extra.network); err != nil {
+		return err
+	}
+	for _, h := range p.hosts {
+		hv := v
+		if err := h.checkTemplate(&hv); err != nil {
+			return fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+	}
+	if err := p.versionToReal(&v); err != nil {
+		return err
+	}
+	nodeList, err := p.NodeListInCluster(name)
+	if err != nil {
+		return err
+	}
+	highestNode := 0
+	for _, i := range nodeList {
+		if i > highestNode {
+			highestNode = i
+		}
+	}
+	nodes := []int{}
+	for node := highestNode + 1; node <= nodeCount+highestNode; node++ {
+		nodes = append(nodes, node)
+	}
+	for i, hostNodes := range dockerPoolPlace(nodes, len(p.hosts)) {
+		if len(hostNodes) == 0 {
+			continue
+		}
+		if err = p.hosts[i].deployNodes(v, name, hostNodes, extra); err != nil {
+			return fmt.Errorf("docker host %s: %s", p.hosts[i].host, err)
+		}
+	}
+	return nil
+}
+
+func (p *backendDockerPool) CopyFilesToCluster(name string, files []fileList, nodes []int) error {
+	grouped, err := p.byHost(name, nodes)
+	if err != nil {
+		return err
+	}
+	for i, hostNodes := range grouped {
+		if len(hostNodes) == 0 {
+			continue
+		}
+		if err = p.hosts[i].CopyFilesToCluster(name, files, hostNodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *backendDockerPool) CopyFilesToClusterReader(name string, files []fileListReader, nodes []int) error {
+	grouped, err := p.byHost(name, nodes)
+	if err != nil {
+		return err
+	}
+	for i, hostNodes := range grouped {
+		if len(hostNodes) == 0 {
+			continue
+		}
+		for _, file := range files {
+			if _, err = file.fileContents.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		if err = p.hosts[i].CopyFilesToClusterReader(name, files, hostNodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunCommands runs the commands node by node, so that outputs are returned in the order of nodes as with a single host
+func (p *backendDockerPool) RunCommands(clusterName string, commands [][]string, nodes []int) ([][]byte, error) {
+	nodeHosts, err := p.locate(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if nodes == nil {
+		for node := range nodeHosts {
+			nodes = append(nodes, node)
+		}
+		sort.Ints(nodes)
+	}
+	var fout [][]byte
+	for _, node := range nodes {
+		i, ok := nodeHosts[node]
+		if !ok {
+			return fout, fmt.Errorf("node %d not found in cluster %s", node, clusterName)
+		}
+		out, err := p.hosts[i].RunCommands(clusterName, commands, []int{node})
+		fout = append(fout, out...)
+		if err != nil {
+			return fout, err
+		}
+	}
+	return fout, nil
+}
+
+func (p *backendDockerPool) GetClusterNodeIps(name string) ([]string, error) {
+	nodeIps, err := p.GetNodeIpMap(name, false)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []int{}
+	for node := range nodeIps {
+		nodes = append(nodes, node)
+	}
+	sort.Ints(nodes)
+	ips := []string{}
+	for _, node := range nodes {
+		ips = append(ips, nodeIps[node])
+	}
+	return ips, nil
+}
+
+func (p *backendDockerPool) GetNodeIpMap(name string, internalIPs bool) (map[int]string, error) {
+	if internalIPs {
+		return nil, nil
+	}
+	found := false
+	nodeIps := make(map[int]string)
+	for _, h := range p.hosts {
+		clusters, err := h.ClusterList()
+		if err != nil {
+			return nil, fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+		if !inslice.HasString(clusters, name) {
+			continue
+		}
+		found = true
+		ips, err := h.GetNodeIpMap(name, false)
+		if err != nil {
+			return nil, fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+		for node, ip := range ips {
+			nodeIps[node] = ip
+		}
+	}
+	if !found {
+		return nil, errors.New("cluster not found")
+	}
+	return nodeIps, nil
+}
+
+func (p *backendDockerPool) IsNodeArm(clusterName string, nodeNumber int) (bool, error) {
+	h, err := p.nodeHost(clusterName, nodeNumber)
+	if err != nil {
+		return false, err
+	}
+	return h.IsNodeArm(clusterName, nodeNumber)
+}
+
+func (p *backendDockerPool) ClusterStart(name string, nodes []int) error {
+	return p.clusterAction(name, nodes, (*backendDocker).ClusterStart)
+}
+
+func (p *backendDockerPool) ClusterStop(name string, nodes []int) error {
+	return p.clusterAction(name, nodes, (*backendDocker).ClusterStop)
+}
+
+func (p *backendDockerPool) ClusterDestroy(name string, nodes []int) error {
+	return p.clusterAction(name, nodes, (*backendDocker).ClusterDestroy)
+}
+
+// clusterAction runs a start, stop or destroy on each host for the nodes placed on it
+func (p *backendDockerPool) clusterAction(name string, nodes []int, action func(*backendDocker, string, []int) error) error {
+	grouped, err := p.byHost(name, nodes)
+	if err != nil {
+		return err
+	}
+	for i, hostNodes := range grouped {
+		if len(hostNodes) == 0 {
+			continue
+		}
+		if err = action(p.hosts[i], name, hostNodes); err != nil {
+			return fmt.Errorf("docker host %s: %s", p.hosts[i].host, err)
+		}
+	}
+	return nil
+}
+
+func (p *backendDockerPool) Upload(clusterName string, node int, source string, destination string, verbose bool, legacy bool) error {
+	h, err := p.nodeHost(clusterName, node)
+	if err != nil {
+		return err
+	}
+	return h.Upload(clusterName, node, source, destination, verbose, legacy)
+}
+
+func (p *backendDockerPool) Download(clusterName string, node int, source string, destination string, verbose bool, legacy bool) error {
+	h, err := p.nodeHost(clusterName, node)
+	if err != nil {
+		return err
+	}
+	return h.Download(clusterName, node, source, destination, verbose, legacy)
+}
+
+func (p *backendDockerPool) AttachAndRun(clusterName string, node int, command []string, isInteractive bool) (err error) {
+	h, err := p.nodeHost(clusterName, node)
+	if err != nil {
+		return err
+	}
+	return h.AttachAndRun(clusterName, node, command, isInteractive)
+}
+
+func (p *backendDockerPool) RunCustomOut(clusterName string, node int, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer, isInteractive bool) (err error) {
+	h, err := p.nodeHost(clusterName, node)
+	if err != nil {
+		return err
+	}
+	return h.RunCustomOut(clusterName, node, command, stdin, stdout, stderr, isInteractive)
+}
+
+// Inventory merges the inventories of all hosts, reporting the host of each node as its zone
+func (p *backendDockerPool) Inventory(owner string, inventoryItems []int) (inventoryJson, error) {
+	ij := inventoryJson{}
+	client := p.client
+	defer func() {
+		if client {
+			p.WorkOnClients()
+		} else {
+			p.WorkOnServers()
+		}
+	}()
+	for _, h := range p.hosts {
+		hij, err := h.Inventory(owner, inventoryItems)
+		if err != nil {
+			return ij, fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+		for _, c := range hij.Clusters {
+			c.Zone = h.host
+			ij.Clusters = append(ij.Clusters, c)
+		}
+		for _, c := range hij.Clients {
+			c.Zone = h.host
+			ij.Clients = append(ij.Clients, c)
+		}
+		for _, t := range hij.Templates {
+			found := false
+			for _, tt := range ij.Templates {
+				if tt == t {
+					found = true
+					break
+				}
+			}
+			if !found {
+				ij.Templates = append(ij.Templates, t)
+			}
+		}
+		for _, rule := range hij.FirewallRules {
+			found := false
+			for _, r := range ij.FirewallRules {
+				if r.Docker != nil && rule.Docker != nil && r.Docker.NetworkName == rule.Docker.NetworkName {
+					found = true
+					break
+				}
+			}
+			if !found {
+				ij.FirewallRules = append(ij.FirewallRules, rule)
+			}
+		}
+	}
+	return ij, nil
+}
+
+// CreateNetwork creates a network spanning the hosts: overlay networks are created once through the swarm manager, which must be the first host,
+// while macvlan and ipvlan networks are created on every host, each handing out addresses from its own part of the subnet
+func (p *backendDockerPool) CreateNetwork(name string, driver string, subnet string, mtu string, parent string) error {
+	if len(p.hosts) == 1 {
+		return p.hosts[0].CreateNetwork(name, driver, subnet, mtu, parent)
+	}
+	switch driver {
+	case "overlay":
+		return p.hosts[0].CreateNetwork(name, driver, subnet, mtu, parent)
+	case "macvlan", "ipvlan":
+		if subnet == "" || parent == "" {
+			return fmt.Errorf("%s networks spanning docker hosts require a subnet and a parent interface, create one with `aerolab config docker create-network -d %s -s SUBNET -p INTERFACE`", driver, driver)
+		}
+		ranges, err := dockerPoolIpRanges(subnet, len(p.hosts))
+		if err != nil {
+			return err
+		}
+		for i, h := range p.hosts {
+			if err = h.createNetwork(name, driver, subnet, ranges[i], mtu, parent); err != nil {
+				return fmt.Errorf("docker host %s: %s", h.host, err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s networks do not span docker hosts, use the overlay or macvlan driver", driver)
+	}
+}
+
+// DeleteNetwork removes the network from all hosts it exists on
+func (p *backendDockerPool) DeleteNetwork(name string) error {
+	var err error
+	deleted := false
+	for _, h := range p.hosts {
+		if herr := h.DeleteNetwork(name); herr == nil {
+			deleted = true
+		} else if err == nil {
+			err = fmt.Errorf("docker host %s: %s", h.host, herr)
+		}
+	}
+	if deleted {
+		return nil
+	}
+	return err
+}
+
+func (p *backendDockerPool) PruneNetworks() error {
+	for _, h := range p.hosts {
+		if err := h.PruneNetworks(); err != nil {
+			return fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+	}
+	return nil
+}
+
+// ListNetworks lists the networks of all hosts
+func (p *backendDockerPool) ListNetworks(csv bool, writer io.Writer) error {
+	hosts := []string{}
+	netlists := [][]dockerListNetwork{}
+	for _, h := range p.hosts {
+		netlist, err := h.networks()
+		if err != nil {
+			return fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+		hosts = append(hosts, h.host)
+		netlists = append(netlists, netlist)
+	}
+	dockerPrintNetworks(hosts, netlists, csv, writer)
+	return nil
+}
+
+// nodeHosts returns the hosts running the given nodes of a cluster; no nodes means all nodes of the cluster
+func (p *backendDockerPool) nodeHosts(name string, nodes []int) ([]*backendDocker, error) {
+	grouped, err := p.byHost(name, nodes)
+	if err != nil {
+		return nil, err
+	}
+	hosts := []*backendDocker{}
+	for i, hostNodes := range grouped {
+		if len(hostNodes) > 0 {
+			hosts = append(hosts, p.hosts[i])
+		}
+	}
+	return hosts, nil
+}
+
+// SetLabel sets the label on each host the cluster runs on
+func (p *backendDockerPool) SetLabel(clusterName string, key string, value string, gcpZone string) error {
+	hosts, err := p.nodeHosts(clusterName, nil)
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("cluster %s not found", clusterName)
+	}
+	for _, h := range hosts {
+		if err = h.SetLabel(clusterName, key, value, gcpZone); err != nil {
+			return fmt.Errorf("docker host %s: %s", h.host, err)
+		}
+	}
+	return nil
+}
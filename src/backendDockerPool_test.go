@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDockerPoolPlace(t *testing.T) {
+	placement := dockerPoolPlace([]int{1, 2, 3, 4, 5, 6}, 3)
+	if !reflect.DeepEqual(placement, [][]int{{1, 4}, {2, 5}, {3, 6}}) {
+		t.Errorf("unexpected placement %v", placement)
+	}
+	// growing a cluster continues the round-robin
+	placement = dockerPoolPlace([]int{7, 8}, 3)
+	if !reflect.DeepEqual(placement, [][]int{{7}, {8}, nil}) {
+		t.Errorf("unexpected placement when growing %v", placement)
+	}
+}
+
+func TestDockerPoolIpRanges(t *testing.T) {
+	ranges, err := dockerPoolIpRanges("192.168.10.0/24", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ranges, []string{"192.168.10.0/26", "192.168.10.64/26", "192.168.10.128/26"}) {
+		t.Errorf("unexpected ranges %v", ranges)
+	}
+	if _, err = dockerPoolIpRanges("192.168.10.0/30", 2); err == nil {
+		t.Error("expected subnet too small error")
+	}
+}
+
+func TestDockerPoolBackend(t *testing.T) {
+	_, api1 := dockerApiFakeServer(t)
+	fake2, api2 := dockerApiFakeServer(t)
+	fake2.containers = fake2.containers[:1]
+	fake2.containers[0].Names = []string{"/aerolab-mydc_3"}
+	p := newBackendDockerPool([]string{"tcp://server1:2376", " tcp://server2:2376"})
+	p.hosts[0].api = api1
+	p.hosts[1].api = api2
+	p.WorkOnServers()
+	defer p.WorkOnServers()
+
+	clusters, err := p.ClusterList()
+	if err != nil || !reflect.DeepEqual(clusters, []string{"mydc"}) {
+		t.Errorf("ClusterList: %v, %v", clusters, err)
+	}
+	nodes, err := p.NodeListInCluster("mydc")
+	if err != nil || !reflect.DeepEqual(nodes, []int{1, 2, 3}) {
+		t.Errorf("NodeListInCluster: %v, %v", nodes, err)
+	}
+	if nodes, err = p.NodeListInCluster("missing"); err != nil || nodes != nil {
+		t.Errorf("NodeListInCluster: missing cluster: %v, %v", nodes, err)
+	}
+	ips, err := p.GetNodeIpMap("mydc", false)
+	if err != nil || !reflect.DeepEqual(ips, map[int]string{1: "10.0.0.2", 3: "10.0.0.2"}) {
+		t.Errorf("GetNodeIpMap: %v, %v", ips, err)
+	}
+	if _, err = p.GetClusterNodeIps("missing"); err == nil {
+		t.Error("GetClusterNodeIps: expected cluster not found error")
+	}
+	grouped, err := p.byHost("mydc", nil)
+	if err != nil || !reflect.DeepEqual(grouped, [][]int{{1, 2}, {3}}) {
+		t.Errorf("byHost: %v, %v", grouped, err)
+	}
+	if _, err = p.nodeHost("mydc", 4); err == nil {
+		t.Error("nodeHost: expected node not found error")
+	}
+	out, err := p.RunCommands("mydc", [][]string{{"cat", "x"}}, []int{3, 1})
+	if err != nil || len(out) != 2 {
+		t.Errorf("RunCommands: %q, %v", out, err)
+	}
+	if err = p.ClusterStart("mydc", nil); err != nil {
+		t.Errorf("ClusterStart: %s", err)
+	}
+
+	ij, err := p.Inventory("", []int{InventoryItemClusters, InventoryItemClients})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ij.Clusters) != 3 || len(ij.Clients) != 1 {
+		t.Fatalf("unexpected inventory %+v", ij)
+	}
+	for _, node := range ij.Clusters {
+		expected := "tcp://server1:2376"
+		if node.NodeNo == "3" {
+			expected = "tcp://server2:2376"
+		}
+		if node.Zone != expected {
+			t.Errorf("node %s: expected host %s, got %s", node.NodeNo, expected, node.Zone)
+		}
+	}
+	if p.client || dockerNameHeader != "aerolab-" {
+		t.Error("Inventory did not restore working on servers")
+	}
+
+	// node specific host commands, ex. sysctl for elasticsearch, are routed to the hosts running the nodes
+	defer func(back backend) { b = back }(b)
+	b = &backendCache{backend: p}
+	hosts, err := dockerNodeHosts("mydc", []int{3})
+	if err != nil || len(hosts) != 1 || hosts[0] != p.hosts[1] {
+		t.Errorf("dockerNodeHosts: %v, %v", hosts, err)
+	}
+	if hosts, err = dockerNodeHosts("mydc", nil); err != nil || len(hosts) != 2 {
+		t.Errorf("dockerNodeHosts: all nodes: %v, %v", hosts, err)
+	}
+	if err = p.SetLabel("missing", "team", "db", ""); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("SetLabel: expected cluster not found error, got %v", err)
+	}
+}
+
+func TestDockerPrintNetworks(t *testing.T) {
+	netlists := [][]dockerListNetwork{{{Name: "aerolab", Driver: "macvlan", Options: map[string]string{"mtu": "1500"}}}, {{Name: "aerolab", Driver: "macvlan"}}}
+	out := new(bytes.Buffer)
+	dockerPrintNetworks([]string{"tcp://server1:2376", "tcp://server2:2376"}, netlists, true, out)
+	expected := "name,driver,subnets,mtu,host\naerolab,macvlan,,1500,tcp://server1:2376\naerolab,macvlan,,default,tcp://server2:2376\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+	out.Reset()
+	dockerPrintNetworks([]string{""}, netlists[:1], true, out)
+	if out.String() != "name,driver,subnets,mtu\naerolab,macvlan,,1500\n" {
+		t.Errorf("single host: unexpected output:\n%s", out.String())
+	}
+}
@@ -1153,7 +1153,7 @@ func gcplabelEncodeToSave(a string) string {
 	return strings.ReplaceAll(a, ".", "-")
 }
 
-func (d *backendGcp) CreateNetwork(name string, driver string, subnet string, mtu string) error {
+func (d *backendGcp) CreateNetwork(name string, driver string, subnet string, mtu string, parent string) error {
 	return nil
 }
 func (d *backendGcp) DeleteNetwork(name string) error {
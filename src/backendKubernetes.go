@@ -0,0 +1,778 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestmethod/inslice"
+)
+
+// backendKubernetes deploys each cluster node as a single-replica StatefulSet in a kubernetes namespace, using kubectl; templates are
+// built with the local container engine and pushed to a registry, or loaded directly into kind, k3d and single-node k3s clusters
+type backendKubernetes struct {
+	server    bool
+	client    bool
+	isArm     bool
+	context   string
+	namespace string
+}
+
+func init() {
+	addBackend("kubernetes", &backendKubernetes{})
+}
+
+// kubeTemplatesConfigMap lists the templates available to the cluster, mapping template keys to images
+const kubeTemplatesConfigMap = "aerolab-templates"
+
+func (d *backendKubernetes) GetAZName(subnetId string) (string, error) {
+	return "", nil
+}
+
+func (d *backendKubernetes) TagVolume(fsId string, tagName string, tagValue string) error {
+	return nil
+}
+
+func (d *backendKubernetes) CreateMountTarget(volume *inventoryVolume, subnet string, secGroups []string) (inventoryMountTarget, error) {
+	return inventoryMountTarget{}, nil
+}
+
+func (d *backendKubernetes) MountTargetAddSecurityGroup(mountTarget *inventoryMountTarget, volume *inventoryVolume, addGroups []string) error {
+	return nil
+}
+
+func (d *backendKubernetes) DeleteVolume(name string) error {
+	return nil
+}
+
+func (d *backendKubernetes) CreateVolume(name string, zone string, tags []string, expires time.Duration) error {
+	return nil
+}
+
+func (d *backendKubernetes) SetLabel(clusterName string, key string, value string, gcpZone string) error {
+	if err := kubeCheckLabelValue("label "+key, value); err != nil {
+		return err
+	}
+	_, err := d.kubectlRun(nil, "label", "pods", "-l", kubeSelector(d.typeLabel(), clusterName, nil), "--overwrite", key+"="+value)
+	if err != nil {
+		return err
+	}
+	return d.patchLabels(clusterName, nil, map[string]interface{}{key: value})
+}
+
+func (d *backendKubernetes) GetKeyPath(clusterName string) (keyPath string, err error) {
+	return "", fmt.Errorf("feature not supported on kubernetes")
+}
+
+func (d *backendKubernetes) EnableServices() error {
+	return nil
+}
+
+func (d *backendKubernetes) ExpiriesSystemInstall(intervalMinutes int, deployRegion string) error {
+	return nil
+}
+func (d *backendKubernetes) ExpiriesSystemRemove(region string) error {
+	return nil
+}
+func (d *backendKubernetes) ExpiriesSystemFrequency(intervalMinutes int) error {
+	return nil
+}
+func (d *backendKubernetes) ClusterExpiry(zone string, clusterName string, expiry time.Duration, nodes []int) error {
+	return nil
+}
+
+func (d *backendKubernetes) GetInstanceTypes(minCpu int, maxCpu int, minRam float64, maxRam float64, minDisks int, maxDisks int, findArm bool, gcpZone string) ([]instanceType, error) {
+	return nil, nil
+}
+
+func (d *backendKubernetes) IsSystemArm(systemType string) (bool, error) {
+	return d.isArm, nil
+}
+
+func (d *backendKubernetes) IsNodeArm(clusterName string, nodeNumber int) (bool, error) {
+	return d.isArm, nil
+}
+
+func (d *backendKubernetes) Arch() TypeArch {
+	if d.isArm {
+		return TypeArchArm
+	}
+	return TypeArchAmd
+}
+
+func (d *backendKubernetes) AssignSecurityGroups(clusterName string, names []string, vpcOrZone string, remove bool) error {
+	return nil
+}
+
+func (d *backendKubernetes) DeleteSecurityGroups(vpc string, namePrefix string, internal bool) error {
+	return nil
+}
+
+func (d *backendKubernetes) CreateSecurityGroups(vpc string, namePrefix string) error {
+	return nil
+}
+
+func (d *backendKubernetes) ListSecurityGroups() error {
+	return nil
+}
+
+func (d *backendKubernetes) ListSubnets() error {
+	return nil
+}
+
+func (d *backendKubernetes) LockSecurityGroups(ip string, lockSSH bool, vpc string, namePrefix string) error {
+	return nil
+}
+
+func (d *backendKubernetes) CreateNetwork(name string, driver string, subnet string, mtu string, parent string) error {
+	return errors.New("networks are not supported on kubernetes, pods use the cluster network")
+}
+
+func (d *backendKubernetes) DeleteNetwork(name string) error {
+	return errors.New("networks are not supported on kubernetes, pods use the cluster network")
+}
+
+func (d *backendKubernetes) PruneNetworks() error {
+	return errors.New("networks are not supported on kubernetes, pods use the cluster network")
+}
+
+func (d *backendKubernetes) ListNetworks(csv bool, writer io.Writer) error {
+	return errors.New("networks are not supported on kubernetes, pods use the cluster network")
+}
+
+func (d *backendKubernetes) WorkOnClients() {
+	d.server = false
+	d.client = true
+}
+
+func (d *backendKubernetes) WorkOnServers() {
+	d.server = true
+	d.client = false
+}
+
+// typeLabel returns the value of the aerolab.type label of the nodes worked on
+func (d *backendKubernetes) typeLabel() string {
+	if d.client {
+		return "client"
+	}
+	return "server"
+}
+
+// nameHeader returns the prefix of statefulset names of the nodes worked on
+func (d *backendKubernetes) nameHeader() string {
+	if d.client {
+		return "aerolab-c-"
+	}
+	return "aerolab-"
+}
+
+func (d *backendKubernetes) Init() error {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return fmt.Errorf("kubectl command not found: %s", err)
+	}
+	d.context = a.opts.Config.Backend.KubeContext
+	d.namespace = a.opts.Config.Backend.KubeNamespace
+	if d.namespace == "" {
+		d.namespace = "aerolab"
+	}
+	if d.context == "" {
+		out, err := exec.Command("kubectl", "config", "current-context").CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("no kubectl context is selected, select one with `kubectl config use-context` or `aerolab config backend -t kubernetes --kube-context NAME`: %s", strings.Trim(string(out), "\r\n\t "))
+		}
+		d.context = strings.Trim(string(out), "\r\n\t ")
+	}
+	nodes := struct {
+		Items []struct {
+			Status struct {
+				NodeInfo struct {
+					Architecture string
+				}
+			}
+		}
+	}{}
+	if err := d.kubectlJson(&nodes, "get", "nodes"); err != nil {
+		return fmt.Errorf("kubernetes cluster of context %s appears to be unreachable: %s", d.context, err)
+	}
+	if len(nodes.Items) > 0 {
+		d.isArm = strings.Contains(nodes.Items[0].Status.NodeInfo.Architecture, "arm")
+	}
+	d.WorkOnServers()
+	return nil
+}
+
+func (d *backendKubernetes) ClusterList() ([]string, error) {
+	sets, err := d.statefulSets("", nil)
+	if err != nil {
+		return nil, err
+	}
+	clusterList := []string{}
+	for _, set := range sets {
+		if !inslice.HasString(clusterList, set.Metadata.Labels["aerolab.cluster"]) {
+			clusterList = append(clusterList, set.Metadata.Labels["aerolab.cluster"])
+		}
+	}
+	return clusterList, nil
+}
+
+func (d *backendKubernetes) NodeListInCluster(name string) ([]int, error) {
+	sets, err := d.statefulSets(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	var nodeList []int
+	for _, set := range sets {
+		nodeList = append(nodeList, set.node())
+	}
+	sort.Ints(nodeList)
+	return nodeList, nil
+}
+
+// templates returns the images of the templates available to the cluster, by template key
+func (d *backendKubernetes) templates() (map[string]string, error) {
+	configMap := struct{ Data map[string]string }{}
+	if err := d.kubectlJson(&configMap, "get", "configmap", kubeTemplatesConfigMap, "--ignore-not-found"); err != nil {
+		return nil, err
+	}
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	return configMap.Data, nil
+}
+
+func (d *backendKubernetes) ListTemplates() ([]backendVersion, error) {
+	templates, err := d.templates()
+	if err != nil {
+		return nil, err
+	}
+	var templateList []backendVersion
+	for key := range templates {
+		distVer := strings.SplitN(key, "_", 3)
+		if len(distVer) != 3 {
+			continue
+		}
+		templateList = append(templateList, backendVersion{distVer[0], distVer[1], distVer[2], d.isArm})
+	}
+	return templateList, nil
+}
+
+// DeployTemplate builds the template image with the local container engine and makes it available to the kubernetes cluster
+func (d *backendKubernetes) DeployTemplate(v backendVersion, script string, files []fileListReader, extra *backendExtra) error {
+	local := new(backendDocker)
+	if err := local.Init(); err != nil {
+		return fmt.Errorf("templates are built using the local container engine: %s", err)
+	}
+	if err := local.versionToReal(&v); err != nil {
+		return err
+	}
+	if err := local.DeployTemplate(v, script, files, extra); err != nil {
+		return err
+	}
+	image, err := d.publishImage(local, fmt.Sprintf("aerolab-%s_%s:%s", v.distroName, v.distroVersion, v.aerospikeVersion))
+	if err != nil {
+		return err
+	}
+	if err = d.ensureNamespace(); err != nil {
+		return err
+	}
+	if _, err = d.kubectlRun(nil, "create", "configmap", kubeTemplatesConfigMap); err != nil && !strings.Contains(err.Error(), "AlreadyExists") {
+		return err
+	}
+	_, err = d.kubectlRun(nil, "patch", "configmap", kubeTemplatesConfigMap, "--type", "merge", "-p", fmt.Sprintf(`{"data":{%q:%q}}`, kubeTemplateKey(v), image))
+	return err
+}
+
+// publishImage makes an image built by the local container engine available to the cluster: pushed to the configured registry, or loaded into kind, k3d or single-node k3s clusters
+func (d *backendKubernetes) publishImage(local *backendDocker, image string) (string, error) {
+	registry := strings.TrimSuffix(a.opts.Config.Backend.KubeRegistry, "/")
+	var cmd *exec.Cmd
+	switch {
+	case registry != "":
+		ref := registry + "/" + image
+		if out, err := local.command("tag", image, ref).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("could not tag image %s: %s;%s", image, out, err)
+		}
+		if out, err := local.command("push", ref).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("could not push image %s: %s;%s", ref, out, err)
+		}
+		return ref, nil
+	case strings.HasPrefix(d.context, "kind-"):
+		cmd = exec.Command("kind", "load", "docker-image", image, "--name", strings.TrimPrefix(d.context, "kind-"))
+	case strings.HasPrefix(d.context, "k3d-"):
+		cmd = exec.Command("k3d", "image", "import", image, "-c", strings.TrimPrefix(d.context, "k3d-"))
+	default:
+		nodes, err := d.k3sNodes()
+		if err != nil {
+			return "", err
+		}
+		if nodes == 0 {
+			return "", fmt.Errorf("cannot load images into the cluster of context %s directly, configure a registry to push templates to using: aerolab config backend -t kubernetes --kube-registry REGISTRY", d.context)
+		}
+		if _, err := exec.LookPath("k3s"); err != nil || nodes > 1 {
+			return "", fmt.Errorf("images can only be loaded directly into a single-node k3s cluster running on this host, configure a registry to push templates to using: aerolab config backend -t kubernetes --kube-registry REGISTRY")
+		}
+		// k3s runs its own containerd, the image is streamed into it from the local container engine
+		save := local.command("save", image)
+		saveErr := new(bytes.Buffer)
+		save.Stderr = saveErr
+		cmd = exec.Command("k3s", "ctr", "images", "import", "-")
+		if cmd.Stdin, err = save.StdoutPipe(); err != nil {
+			return "", err
+		}
+		if err = save.Start(); err != nil {
+			return "", fmt.Errorf("could not save image %s: %s", image, err)
+		}
+		out, err := cmd.CombinedOutput()
+		if serr := save.Wait(); serr != nil {
+			return "", fmt.Errorf("could not save image %s: %s;%s", image, saveErr.String(), serr)
+		}
+		if err != nil {
+			return "", fmt.Errorf("could not import image %s into k3s, k3s ctr usually requires root: %s;%s", image, out, err)
+		}
+		return image, nil
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("could not load image %s into the cluster: %s;%s", image, out, err)
+	}
+	return image, nil
+}
+
+// k3sNodes returns the number of nodes in the cluster if it is a k3s cluster, or 0 otherwise
+func (d *backendKubernetes) k3sNodes() (int, error) {
+	out, err := d.kubectlRun(nil, "get", "nodes", "-o", "jsonpath={.items[*].status.nodeInfo.kubeletVersion}")
+	if err != nil {
+		return 0, err
+	}
+	versions := strings.Fields(string(out))
+	for _, v := range versions {
+		if !strings.Contains(v, "+k3s") {
+			return 0, nil
+		}
+	}
+	return len(versions), nil
+}
+
+func (d *backendKubernetes) TemplateDestroy(v backendVersion) error {
+	if v.distroName == "el" {
+		v.distroName = "centos"
+	}
+	templates, err := d.templates()
+	if err != nil {
+		return err
+	}
+	if _, ok := templates[kubeTemplateKey(v)]; !ok {
+		return errors.New("template not found")
+	}
+	_, err = d.kubectlRun(nil, "patch", "configmap", kubeTemplatesConfigMap, "--type", "json", "-p", fmt.Sprintf(`[{"op":"remove","path":"/data/%s"}]`, kubeTemplateKey(v)))
+	return err
+}
+
+func (d *backendKubernetes) VacuumTemplates() error {
+	local := new(backendDocker)
+	if err := local.Init(); err != nil {
+		return err
+	}
+	return local.VacuumTemplates()
+}
+
+func (d *backendKubernetes) VacuumTemplate(v backendVersion) error {
+	local := new(backendDocker)
+	if err := local.Init(); err != nil {
+		return err
+	}
+	return local.VacuumTemplate(v)
+}
+
+func (d *backendKubernetes) DeployCluster(v backendVersion, name string, nodeCount int, extra *backendExtra) error {
+	name = strings.Trim(name, "\r\n\t ")
+	if err := new(backendDocker).versionToReal(&v); err != nil {
+		return err
+	}
+	image := new(backendDocker).centosNaming(v)
+	if !d.client {
+		templates, err := d.templates()
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if image, ok = templates[kubeTemplateKey(v)]; !ok {
+			return errors.New("template not found")
+		}
+	}
+	if err := d.ensureNamespace(); err != nil {
+		return err
+	}
+	nodeList, err := d.NodeListInCluster(name)
+	if err != nil {
+		return err
+	}
+	highestNode := 0
+	for _, i := range nodeList {
+		if i > highestNode {
+			highestNode = i
+		}
+	}
+	nodes := []int{}
+	for node := highestNode + 1; node <= nodeCount+highestNode; node++ {
+		manifest, err := kubeStatefulSet(kubeObjectName(d.nameHeader(), name, node), d.typeLabel(), name, node, image, extra)
+		if err != nil {
+			return err
+		}
+		if err = d.kubectlApply(manifest); err != nil {
+			return fmt.Errorf("error creating statefulset: %s", err)
+		}
+		nodes = append(nodes, node)
+	}
+	return d.waitRunning(name, nodes, 5*time.Minute)
+}
+
+func (d *backendKubernetes) CopyFilesToCluster(name string, files []fileList, nodes []int) error {
+	fr := []fileListReader{}
+	for _, f := range files {
+		fr = append(fr, fileListReader{
+			filePath:     f.filePath,
+			fileSize:     f.fileSize,
+			fileContents: strings.NewReader(f.fileContents),
+		})
+	}
+	return d.CopyFilesToClusterReader(name, fr, nodes)
+}
+
+func (d *backendKubernetes) CopyFilesToClusterReader(name string, files []fileListReader, nodes []int) error {
+	pods, err := d.pods(name, nodes)
+	if err != nil {
+		return err
+	}
+	if nodes == nil {
+		for node := range pods {
+			nodes = append(nodes, node)
+		}
+		sort.Ints(nodes)
+	}
+	for _, file := range files {
+		tmpfile, err := os.CreateTemp(string(a.opts.Config.Backend.TmpDir), "aerolab-tmp")
+		if err != nil {
+			return err
+		}
+		tmpfileName := tmpfile.Name()
+		_, err = io.Copy(tmpfile, file.fileContents)
+		tmpfile.Close()
+		if err != nil {
+			os.Remove(tmpfileName)
+			return fmt.Errorf("error making tmpfile: %s", err)
+		}
+		for _, node := range nodes {
+			pod, ok := pods[node]
+			if !ok || !pod.running() {
+				os.Remove(tmpfileName)
+				return fmt.Errorf("node %d of cluster %s is not running", node, name)
+			}
+			if err = d.upload(pod.Metadata.Name, tmpfileName, file.filePath); err != nil {
+				os.Remove(tmpfileName)
+				return fmt.Errorf("error copying to pod %s: %s", pod.Metadata.Name, err)
+			}
+		}
+		if err = os.Remove(tmpfileName); err != nil {
+			return fmt.Errorf("error removing tmpfile: %s", err)
+		}
+	}
+	return nil
+}
+
+func (d *backendKubernetes) RunCommands(clusterName string, commands [][]string, nodes []int) ([][]byte, error) {
+	pods, err := d.pods(clusterName, nodes)
+	if err != nil {
+		return nil, err
+	}
+	if nodes == nil {
+		for node := range pods {
+			nodes = append(nodes, node)
+		}
+		sort.Ints(nodes)
+	}
+	var fout [][]byte
+	for _, node := range nodes {
+		pod, ok := pods[node]
+		if !ok || !pod.running() {
+			return fout, fmt.Errorf("node %d of cluster %s is not running", node, clusterName)
+		}
+		for _, command := range commands {
+			out := new(strings.Builder)
+			err = d.exec(pod.Metadata.Name, node, command, nil, out, out, false)
+			fout = append(fout, []byte(out.String()))
+			if checkExecRetcode(err) != 0 {
+				return fout, fmt.Errorf("error running %s: %s", command, err)
+			}
+		}
+	}
+	return fout, nil
+}
+
+func (d *backendKubernetes) GetClusterNodeIps(name string) ([]string, error) {
+	nodeIps, err := d.GetNodeIpMap(name, false)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []int{}
+	for node := range nodeIps {
+		nodes = append(nodes, node)
+	}
+	sort.Ints(nodes)
+	ips := []string{}
+	for _, node := range nodes {
+		ips = append(ips, nodeIps[node])
+	}
+	return ips, nil
+}
+
+func (d *backendKubernetes) GetNodeIpMap(name string, internalIPs bool) (map[int]string, error) {
+	if internalIPs {
+		return nil, nil
+	}
+	sets, err := d.statefulSets(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(sets) == 0 {
+		return nil, errors.New("cluster not found")
+	}
+	pods, err := d.pods(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	nodeIps := make(map[int]string)
+	for node, pod := range pods {
+		if pod.running() {
+			nodeIps[node] = pod.Status.PodIP
+		}
+	}
+	return nodeIps, nil
+}
+
+// ClusterStart resumes stopped nodes; as kubernetes cannot stop a pod without discarding its filesystem, stopped nodes keep their pods
+func (d *backendKubernetes) ClusterStart(name string, nodes []int) error {
+	pods, err := d.pods(name, nodes)
+	if err != nil {
+		return err
+	}
+	for node, pod := range pods {
+		if !pod.stopped() {
+			continue
+		}
+		if _, err = d.kubectlRun(nil, "label", "pod", pod.Metadata.Name, "aerolab.state-"); err != nil {
+			return fmt.Errorf("node %d: %s", node, err)
+		}
+		if err = d.patchLabels(name, []int{node}, map[string]interface{}{"aerolab.state": nil}); err != nil {
+			return fmt.Errorf("node %d: %s", node, err)
+		}
+	}
+	started := []int{}
+	for node := range pods {
+		started = append(started, node)
+	}
+	return d.waitRunning(name, started, 5*time.Minute)
+}
+
+// ClusterStop stops all processes of the nodes, keeping the pods and their filesystems, and marks the nodes stopped
+func (d *backendKubernetes) ClusterStop(name string, nodes []int) error {
+	pods, err := d.pods(name, nodes)
+	if err != nil {
+		return err
+	}
+	for node, pod := range pods {
+		if pod.stopped() || pod.Status.Phase != "Running" {
+			continue
+		}
+		killAll := "for p in /proc/[0-9]*; do pid=${p#/proc/}; [ $pid -ne 1 ] && [ $pid -ne $$ ] && kill -9 $pid 2>/dev/null; done; exit 0"
+		if err = d.exec(pod.Metadata.Name, node, []string{"/bin/bash", "-c", killAll}, nil, io.Discard, io.Discard, false); err != nil {
+			return fmt.Errorf("node %d: could not stop processes: %s", node, err)
+		}
+		if _, err = d.kubectlRun(nil, "label", "pod", pod.Metadata.Name, "aerolab.state=stopped", "--overwrite"); err != nil {
+			return fmt.Errorf("node %d: %s", node, err)
+		}
+		if err = d.patchLabels(name, []int{node}, map[string]interface{}{"aerolab.state": "stopped"}); err != nil {
+			return fmt.Errorf("node %d: %s", node, err)
+		}
+	}
+	return nil
+}
+
+func (d *backendKubernetes) ClusterDestroy(name string, nodes []int) error {
+	sets, err := d.statefulSets(name, nodes)
+	if err != nil {
+		return err
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+	_, err = d.kubectlRun(nil, "delete", "statefulsets", "-l", kubeSelector(d.typeLabel(), name, nodes), "--cascade=foreground", "--wait")
+	return err
+}
+
+func (d *backendKubernetes) Upload(clusterName string, node int, source string, destination string, verbose bool, legacy bool) error {
+	pod, err := d.runningPod(clusterName, node)
+	if err != nil {
+		return err
+	}
+	return d.upload(pod.Metadata.Name, source, destination)
+}
+
+func (d *backendKubernetes) Download(clusterName string, node int, source string, destination string, verbose bool, legacy bool) error {
+	pod, err := d.runningPod(clusterName, node)
+	if err != nil {
+		return err
+	}
+	return d.download(pod.Metadata.Name, source, destination)
+}
+
+func (d *backendKubernetes) AttachAndRun(clusterName string, node int, command []string, isInteractive bool) (err error) {
+	return d.RunCustomOut(clusterName, node, command, os.Stdin, os.Stdout, os.Stderr, isInteractive)
+}
+
+func (d *backendKubernetes) RunCustomOut(clusterName string, node int, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer, isInteractive bool) (err error) {
+	pod, err := d.runningPod(clusterName, node)
+	if err != nil {
+		return err
+	}
+	if len(command) == 0 {
+		command = []string{"/bin/bash"}
+	}
+	if !isInteractive {
+		stdin = nil
+	}
+	return d.exec(pod.Metadata.Name, node, command, stdin, stdout, stderr, isInteractive)
+}
+
+func (d *backendKubernetes) Inventory(owner string, inventoryItems []int) (inventoryJson, error) {
+	ij := inventoryJson{}
+	arch := "amd64"
+	if d.isArm {
+		arch = "arm64"
+	}
+	if inslice.HasInt(inventoryItems, InventoryItemTemplates) {
+		tmpl, err := d.ListTemplates()
+		if err != nil {
+			return ij, err
+		}
+		for _, t := range tmpl {
+			ij.Templates = append(ij.Templates, inventoryTemplate{
+				AerospikeVersion: t.aerospikeVersion,
+				Distribution:     t.distroName,
+				OSVersion:        t.distroVersion,
+				Arch:             arch,
+			})
+		}
+	}
+	client := d.client
+	defer func() {
+		if client {
+			d.WorkOnClients()
+		} else {
+			d.WorkOnServers()
+		}
+	}()
+	for _, servers := range []bool{true, false} {
+		if servers && !inslice.HasInt(inventoryItems, InventoryItemClusters) || !servers && !inslice.HasInt(inventoryItems, InventoryItemClients) {
+			continue
+		}
+		if servers {
+			d.WorkOnServers()
+		} else {
+			d.WorkOnClients()
+		}
+		list := struct{ Items []*kubePod }{}
+		if err := d.kubectlJson(&list, "get", "pods", "-l", kubeSelector(d.typeLabel(), "", nil)); err != nil {
+			return ij, err
+		}
+		for _, pod := range list.Items {
+			labels := pod.Metadata.Labels
+			if owner != "" && labels["owner"] != owner {
+				continue
+			}
+			image := ""
+			if len(pod.Spec.Containers) > 0 {
+				image = pod.Spec.Containers[0].Image
+			}
+			image = image[strings.LastIndex(image, "/")+1:]
+			state := pod.Status.Phase
+			if pod.stopped() {
+				state = "Stopped"
+			}
+			ip := pod.Status.PodIP
+			if !pod.running() {
+				ip = ""
+			}
+			distVer := strings.Split(strings.TrimPrefix(image, "aerolab-"), ":")
+			osVer := []string{distVer[0], ""}
+			if servers {
+				osVer = append(strings.SplitN(distVer[0], "_", 2), "")
+			} else if len(distVer) > 1 {
+				osVer[1] = distVer[1]
+			}
+			asdVer := ""
+			if servers && len(distVer) > 1 {
+				asdVer = distVer[1]
+			}
+			if servers {
+				features, _ := strconv.Atoi(labels["aerolab.client.type"])
+				ij.Clusters = append(ij.Clusters, inventoryCluster{
+					ClusterName:      labels["aerolab.cluster"],
+					NodeNo:           labels["aerolab.node"],
+					PrivateIp:        ip,
+					InstanceId:       pod.Metadata.Name,
+					ImageId:          image,
+					State:            state,
+					Arch:             arch,
+					Distribution:     osVer[0],
+					OSVersion:        osVer[1],
+					AerospikeVersion: asdVer,
+					Zone:             pod.Spec.NodeName,
+					Features:         FeatureSystem(features),
+					AGILabel:         labels["agiLabel"],
+					Owner:            labels["owner"],
+				})
+			} else {
+				ij.Clients = append(ij.Clients, inventoryClient{
+					ClientName:   labels["aerolab.cluster"],
+					NodeNo:       labels["aerolab.node"],
+					PrivateIp:    ip,
+					InstanceId:   pod.Metadata.Name,
+					ImageId:      image,
+					State:        state,
+					Arch:         arch,
+					Distribution: osVer[0],
+					OSVersion:    osVer[1],
+					ClientType:   labels["aerolab.client.type"],
+					Zone:         pod.Spec.NodeName,
+					Owner:        labels["owner"],
+				})
+			}
+		}
+	}
+	return ij, nil
+}
+
+// returns an unformatted string with list of clusters, to be printed to user
+func (d *backendKubernetes) ClusterListFull(isJson bool, owner string, pager bool, isPretty bool, sort []string) (string, error) {
+	a.opts.Inventory.List.Json = isJson
+	a.opts.Inventory.List.Pager = pager
+	a.opts.Inventory.List.JsonPretty = isPretty
+	a.opts.Inventory.List.SortBy = sort
+	return "", a.opts.Inventory.List.run(d.server, d.client, false, false, false)
+}
+
+// returns an unformatted string with list of clusters, to be printed to user
+func (d *backendKubernetes) TemplateListFull(isJson bool, pager bool, isPretty bool, sort []string) (string, error) {
+	a.opts.Inventory.List.Json = isJson
+	a.opts.Inventory.List.Pager = pager
+	a.opts.Inventory.List.JsonPretty = isPretty
+	a.opts.Inventory.List.SortBy = sort
+	return "", a.opts.Inventory.List.run(false, false, true, false, false)
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kubeObject is the metadata of a kubernetes object, as listed by kubectl
+type kubeObject struct {
+	Metadata struct {
+		Name   string
+		Labels map[string]string
+	}
+}
+
+// kubePod is a pod, as listed by kubectl
+type kubePod struct {
+	kubeObject
+	Spec struct {
+		NodeName   string
+		Containers []struct {
+			Image string
+		}
+	}
+	Status struct {
+		Phase string
+		PodIP string
+	}
+}
+
+// node returns the aerolab node number of a pod or statefulset
+func (o *kubeObject) node() int {
+	node, _ := strconv.Atoi(o.Metadata.Labels["aerolab.node"])
+	return node
+}
+
+// stopped returns whether aerolab stopped the node running in the pod
+func (p *kubePod) stopped() bool {
+	return p.Metadata.Labels["aerolab.state"] == "stopped"
+}
+
+// running returns whether the pod is running and was not stopped by aerolab
+func (p *kubePod) running() bool {
+	return p.Status.Phase == "Running" && p.Status.PodIP != "" && !p.stopped()
+}
+
+var kubeNameInvalid = regexp.MustCompile("[^a-z0-9-]+")
+
+// kubeObjectName returns a valid statefulset name for a cluster node; names which had to be changed to be valid get a hash of the cluster name, so that they remain unique
+func kubeObjectName(header string, name string, node int) string {
+	valid := strings.Trim(kubeNameInvalid.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if valid != name || len(valid) > 36 {
+		sum := sha256.Sum256([]byte(name))
+		if len(valid) > 29 {
+			valid = valid[:29]
+		}
+		valid = valid + "-" + hex.EncodeToString(sum[:])[:6]
+	}
+	return fmt.Sprintf("%s%s-%d", header, valid, node)
+}
+
+var kubeLabelValueValid = regexp.MustCompile(`^(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?$`)
+
+// kubeCheckLabelValue returns an error if a value, ex. a cluster name, cannot be used as a kubernetes label value: at most 63 characters of [A-Za-z0-9_.-], starting and ending with a letter or digit
+func kubeCheckLabelValue(what string, value string) error {
+	if len(value) > 63 || !kubeLabelValueValid.MatchString(value) {
+		return fmt.Errorf("%s %q is not a valid kubernetes label value: it must be at most 63 characters of letters, digits, '-', '_' and '.', starting and ending with a letter or digit", what, value)
+	}
+	return nil
+}
+
+// kubeSelector returns the label selector for nodes of a cluster; no nodes selects all nodes
+func kubeSelector(typeLabel string, name string, nodes []int) string {
+	selector := "aerolab.type=" + typeLabel
+	if name != "" {
+		selector = selector + ",aerolab.cluster=" + name
+	}
+	if len(nodes) > 0 {
+		nodeList := []string{}
+		for _, node := range nodes {
+			nodeList = append(nodeList, strconv.Itoa(node))
+		}
+		selector = selector + ",aerolab.node in (" + strings.Join(nodeList, ",") + ")"
+	}
+	return selector
+}
+
+// kubeTemplateKey returns the key of a template in the aerolab-templates configmap
+func kubeTemplateKey(v backendVersion) string {
+	return fmt.Sprintf("%s_%s_%s", v.distroName, v.distroVersion, v.aerospikeVersion)
+}
+
+// kubeStatefulSet returns the manifest of a single-replica statefulset running one node of a cluster, so that every node keeps its number and can be stopped or destroyed on its own
+func kubeStatefulSet(objectName string, typeLabel string, name string, node int, image string, extra *backendExtra) (map[string]interface{}, error) {
+	if err := kubeCheckLabelValue("cluster name", name); err != nil {
+		return nil, err
+	}
+	labels := map[string]string{
+		"aerolab.type":    typeLabel,
+		"aerolab.cluster": name,
+		"aerolab.node":    strconv.Itoa(node),
+	}
+	selector := map[string]string{}
+	for k, v := range labels {
+		selector[k] = v
+	}
+	if extra.clientType != "" {
+		labels["aerolab.client.type"] = extra.clientType
+	}
+	for _, label := range extra.labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("label %s is not in the format key=value", label)
+		}
+		if err := kubeCheckLabelValue("label "+kv[0], kv[1]); err != nil {
+			return nil, err
+		}
+		labels[kv[0]] = kv[1]
+	}
+	container := map[string]interface{}{
+		"name":            "aerolab",
+		"image":           image,
+		"imagePullPolicy": "IfNotPresent",
+		"command":         []string{"/bin/bash", "-c", "while true; do [ -f /tmp/poweroff.now ] && rm -f /tmp/poweroff.now && exit; sleep 1; done"},
+		"stdin":           true,
+		"tty":             true,
+		"securityContext": map[string]interface{}{
+			"privileged":   extra.privileged,
+			"capabilities": map[string]interface{}{"add": []string{"NET_ADMIN", "NET_RAW"}},
+		},
+	}
+	limits := map[string]string{}
+	if extra.cpuLimit != "" {
+		limits["cpu"] = extra.cpuLimit
+	}
+	if extra.ramLimit != "" {
+		limits["memory"] = extra.ramLimit
+	}
+	if len(limits) > 0 {
+		container["resources"] = map[string]interface{}{"limits": limits}
+	}
+	hostname := strings.Trim(kubeNameInvalid.ReplaceAllString(strings.ToLower(fmt.Sprintf("%s-%d", name, node)), "-"), "-")
+	if len(hostname) > 63 {
+		hostname = hostname[len(hostname)-63:]
+	}
+	return map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "StatefulSet",
+		"metadata": map[string]interface{}{
+			"name":   objectName,
+			"labels": labels,
+		},
+		"spec": map[string]interface{}{
+			"replicas":    1,
+			"serviceName": objectName,
+			"selector":    map[string]interface{}{"matchLabels": selector},
+			// label changes are made to the pod template too; they must not recreate the pod, which would discard its filesystem
+			"updateStrategy": map[string]interface{}{"type": "OnDelete"},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": labels},
+				"spec": map[string]interface{}{
+					"hostname":                      hostname,
+					"terminationGracePeriodSeconds": 1,
+					"containers":                    []interface{}{container},
+				},
+			},
+		},
+	}, nil
+}
+
+// kubeLabelsPatch returns a merge patch setting labels on a statefulset and its pod template, so that they are kept when the pod is recreated; a nil value removes the label
+// the patch also switches statefulsets created with the default update strategy to OnDelete, so that patching the template does not recreate the pod
+func kubeLabelsPatch(labels map[string]interface{}) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": labels},
+		"spec": map[string]interface{}{
+			"updateStrategy": map[string]interface{}{"type": "OnDelete"},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": labels},
+			},
+		},
+	})
+}
+
+// patchLabels sets or, for nil values, removes labels on the statefulsets of the given nodes of a cluster and on their pod templates
+func (d *backendKubernetes) patchLabels(name string, nodes []int, labels map[string]interface{}) error {
+	patch, err := kubeLabelsPatch(labels)
+	if err != nil {
+		return err
+	}
+	sets, err := d.statefulSets(name, nodes)
+	if err != nil {
+		return err
+	}
+	for _, set := range sets {
+		if _, err = d.kubectlRun(nil, "patch", "statefulset", set.Metadata.Name, "--type", "merge", "-p", string(patch)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kubectl returns a kubectl command using the configured context and namespace
+func (d *backendKubernetes) kubectl(args ...string) *exec.Cmd {
+	head := []string{}
+	if d.context != "" {
+		head = append(head, "--context", d.context)
+	}
+	head = append(head, "-n", d.namespace)
+	return exec.Command("kubectl", append(head, args...)...)
+}
+
+// kubectlRun runs a kubectl command, returning its output and any error including what kubectl printed
+func (d *backendKubernetes) kubectlRun(stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := d.kubectl(args...)
+	cmd.Stdin = stdin
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return out, fmt.Errorf("kubectl %s: %s: %s", args[0], err, strings.Trim(stderr.String(), "\r\n\t "))
+	}
+	return out, nil
+}
+
+// kubectlJson runs a kubectl get command, decoding the output
+func (d *backendKubernetes) kubectlJson(out interface{}, args ...string) error {
+	stdout, err := d.kubectlRun(nil, append(args, "-o", "json")...)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(stdout)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(stdout, out)
+}
+
+// kubectlApply creates or updates an object from its manifest
+func (d *backendKubernetes) kubectlApply(manifest interface{}) error {
+	contents, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	_, err = d.kubectlRun(bytes.NewReader(contents), "apply", "-f", "-")
+	return err
+}
+
+// ensureNamespace creates the namespace aerolab works in, if it does not exist yet
+func (d *backendKubernetes) ensureNamespace() error {
+	_, err := d.kubectlRun(nil, "create", "namespace", d.namespace)
+	if err != nil && !strings.Contains(err.Error(), "AlreadyExists") {
+		return err
+	}
+	return nil
+}
+
+// statefulSets returns the statefulsets of the given nodes of a cluster of the current type; an empty name returns those of all clusters
+func (d *backendKubernetes) statefulSets(name string, nodes []int) ([]kubeObject, error) {
+	list := struct{ Items []kubeObject }{}
+	err := d.kubectlJson(&list, "get", "statefulsets", "-l", kubeSelector(d.typeLabel(), name, nodes))
+	return list.Items, err
+}
+
+// pods returns the pods of the given nodes of a cluster of the current type, by node number; an empty name returns those of all clusters
+func (d *backendKubernetes) pods(name string, nodes []int) (map[int]*kubePod, error) {
+	list := struct{ Items []*kubePod }{}
+	if err := d.kubectlJson(&list, "get", "pods", "-l", kubeSelector(d.typeLabel(), name, nodes)); err != nil {
+		return nil, err
+	}
+	pods := make(map[int]*kubePod)
+	for _, pod := range list.Items {
+		pods[pod.node()] = pod
+	}
+	return pods, nil
+}
+
+// runningPod returns the pod of a node, if the node is running
+func (d *backendKubernetes) runningPod(name string, node int) (*kubePod, error) {
+	pods, err := d.pods(name, []int{node})
+	if err != nil {
+		return nil, err
+	}
+	pod, ok := pods[node]
+	if !ok {
+		return nil, fmt.Errorf("node %d not found in cluster %s", node, name)
+	}
+	if !pod.running() {
+		return nil, fmt.Errorf("node %d of cluster %s is not running", node, name)
+	}
+	return pod, nil
+}
+
+// waitRunning waits for the pods of the given nodes to be running
+func (d *backendKubernetes) waitRunning(name string, nodes []int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		pods, err := d.pods(name, nodes)
+		if err != nil {
+			return err
+		}
+		waiting := []int{}
+		for _, node := range nodes {
+			if pod, ok := pods[node]; !ok || pod.Status.Phase != "Running" || pod.Status.PodIP == "" {
+				waiting = append(waiting, node)
+			}
+		}
+		if len(waiting) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for pods of cluster %s nodes %v to start, check with: kubectl -n %s describe pods -l %s", name, waiting, d.namespace, kubeSelector(d.typeLabel(), name, waiting))
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// exec runs a command in the aerolab container of a pod
+func (d *backendKubernetes) exec(pod string, node int, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer, tty bool) error {
+	args := []string{"exec"}
+	if stdin != nil {
+		args = append(args, "-i")
+	}
+	if tty {
+		args = append(args, "-t")
+	}
+	args = append(args, pod, "-c", "aerolab", "--", "env", fmt.Sprintf("NODE=%d", node))
+	cmd := d.kubectl(append(args, command...)...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// upload copies a local file or directory into a pod by streaming a tar archive, with the same semantics as `docker cp`
+func (d *backendKubernetes) upload(pod string, source string, destination string) error {
+	dir, name := path.Split(destination)
+	if d.exec(pod, 0, []string{"test", "-d", destination}, nil, io.Discard, io.Discard, false) == nil {
+		dir = destination
+		name = filepath.Base(source)
+	}
+	if dir == "" {
+		dir = "/"
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(dockerTarPath(pw, source, name))
+	}()
+	stderr := new(bytes.Buffer)
+	err := d.exec(pod, 0, []string{"tar", "-xf", "-", "-C", dir}, pr, io.Discard, stderr, false)
+	pr.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.Trim(stderr.String(), "\r\n\t "))
+	}
+	return nil
+}
+
+// download copies a file or directory from a pod by streaming a tar archive, with the same semantics as `docker cp`
+func (d *backendKubernetes) download(pod string, source string, destination string) error {
+	source = path.Clean(source)
+	if source == "/" {
+		return errors.New("cannot download the root directory of a node")
+	}
+	pr, pw := io.Pipe()
+	stderr := new(bytes.Buffer)
+	execErr := make(chan error, 1)
+	go func() {
+		err := d.exec(pod, 0, []string{"tar", "-cf", "-", "-C", path.Dir(source), path.Base(source)}, nil, pw, stderr, false)
+		pw.CloseWithError(err)
+		execErr <- err
+	}()
+	err := dockerUntar(pr, destination)
+	if err == nil {
+		// consume the archive padding, so that tar exits normally and its exit code is meaningful
+		io.Copy(io.Discard, pr)
+	}
+	pr.Close()
+	// stderr is only safe to read once the exec has returned
+	if eerr := <-execErr; err == nil {
+		err = eerr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.Trim(stderr.String(), "\r\n\t "))
+	}
+	return nil
+}
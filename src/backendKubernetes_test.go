@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKubeObjectName(t *testing.T) {
+	if name := kubeObjectName("aerolab-", "mydc", 2); name != "aerolab-mydc-2" {
+		t.Errorf("unexpected name %s", name)
+	}
+	// invalid names are sanitized and made unique with a hash
+	a := kubeObjectName("aerolab-", "My_DC", 1)
+	b := kubeObjectName("aerolab-", "my-dc", 1)
+	if a == b || !strings.HasPrefix(a, "aerolab-my-dc-") || !strings.HasSuffix(a, "-1") {
+		t.Errorf("unexpected sanitized name %s", a)
+	}
+	long := kubeObjectName("aerolab-c-", strings.Repeat("x", 60), 10)
+	if len(long) > 63 {
+		t.Errorf("name %s is longer than 63 characters", long)
+	}
+}
+
+func TestKubeSelector(t *testing.T) {
+	if s := kubeSelector("server", "", nil); s != "aerolab.type=server" {
+		t.Errorf("unexpected selector %s", s)
+	}
+	if s := kubeSelector("client", "mydc", []int{1, 3}); s != "aerolab.type=client,aerolab.cluster=mydc,aerolab.node in (1,3)" {
+		t.Errorf("unexpected selector %s", s)
+	}
+}
+
+func TestKubeStatefulSet(t *testing.T) {
+	extra := &backendExtra{
+		labels:     []string{"owner=me"},
+		clientType: "tools",
+		cpuLimit:   "2",
+		privileged: true,
+	}
+	manifest, err := kubeStatefulSet("aerolab-c-mydc-3", "client", "mydc", 3, "registry/aerolab-ubuntu_22.04:latest", extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := manifest["spec"].(map[string]interface{})
+	selector := spec["selector"].(map[string]interface{})["matchLabels"].(map[string]string)
+	if !reflect.DeepEqual(selector, map[string]string{"aerolab.type": "client", "aerolab.cluster": "mydc", "aerolab.node": "3"}) {
+		t.Errorf("unexpected selector %v", selector)
+	}
+	if !reflect.DeepEqual(spec["updateStrategy"], map[string]interface{}{"type": "OnDelete"}) {
+		t.Errorf("unexpected update strategy %v", spec["updateStrategy"])
+	}
+	template := spec["template"].(map[string]interface{})
+	labels := template["metadata"].(map[string]interface{})["labels"].(map[string]string)
+	if labels["owner"] != "me" || labels["aerolab.client.type"] != "tools" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	podSpec := template["spec"].(map[string]interface{})
+	if podSpec["hostname"] != "mydc-3" {
+		t.Errorf("unexpected hostname %v", podSpec["hostname"])
+	}
+	container := podSpec["containers"].([]interface{})[0].(map[string]interface{})
+	if container["image"] != "registry/aerolab-ubuntu_22.04:latest" || container["securityContext"].(map[string]interface{})["privileged"] != true {
+		t.Errorf("unexpected container %v", container)
+	}
+	if !reflect.DeepEqual(container["resources"], map[string]interface{}{"limits": map[string]string{"cpu": "2"}}) {
+		t.Errorf("unexpected resources %v", container["resources"])
+	}
+	if _, err = kubeStatefulSet("x", "server", "mydc", 1, "image", &backendExtra{labels: []string{"bad"}}); err == nil {
+		t.Error("expected invalid label error")
+	}
+	for _, name := range []string{"my dc", "-mydc", "mydc/1", strings.Repeat("a", 64)} {
+		if _, err = kubeStatefulSet("x", "server", name, 1, "image", &backendExtra{}); err == nil {
+			t.Errorf("cluster name %q: expected invalid label value error", name)
+		}
+	}
+	if _, err = kubeStatefulSet("x", "server", "mydc", 1, "image", &backendExtra{labels: []string{"owner=me@example.com"}}); err == nil {
+		t.Error("expected invalid label value error")
+	}
+	if _, err = kubeStatefulSet("x", "server", "My_DC.2", 1, "image", &backendExtra{labels: []string{"owner="}}); err != nil {
+		t.Errorf("valid cluster name and empty label value: %s", err)
+	}
+}
+
+func TestKubeLabelsPatch(t *testing.T) {
+	patch, err := kubeLabelsPatch(map[string]interface{}{"owner": "me", "aerolab.state": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"metadata":{"labels":{"aerolab.state":null,"owner":"me"}},"spec":{"template":{"metadata":{"labels":{"aerolab.state":null,"owner":"me"}}},"updateStrategy":{"type":"OnDelete"}}}`
+	if string(patch) != expected {
+		t.Errorf("unexpected patch %s", string(patch))
+	}
+}
+
+// TestBackendConformanceKubernetes runs the suite against the current kubectl context, ex: a local kind or k3d cluster, using the first available template;
+// enable with AEROLAB_TEST_KUBERNETES=1 and create a template first, ex: aerolab config backend -t kubernetes && aerolab template create -d ubuntu -i 22.04
+func TestBackendConformanceKubernetes(t *testing.T) {
+	if os.Getenv("AEROLAB_TEST_KUBERNETES") == "" {
+		t.Skip("set AEROLAB_TEST_KUBERNETES=1 to run against kubernetes")
+	}
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		t.Skip("kubectl not found")
+	}
+	back := backends["kubernetes"]
+	if err := back.Init(); err != nil {
+		t.Fatalf("Init: %s", err)
+	}
+	templates, err := back.ListTemplates()
+	if err != nil {
+		t.Fatalf("ListTemplates: %s", err)
+	}
+	if len(templates) == 0 {
+		t.Skip("no kubernetes templates found, create one first using: aerolab template create")
+	}
+	backendConformance(t, back, templates[0], &backendExtra{})
+}
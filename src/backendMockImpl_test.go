@@ -0,0 +1,1052 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bestmethod/inslice"
+)
+
+// backendMock is an in-process test backend which keeps all clusters, clients, templates, labels, firewalls and volumes in memory
+// nothing is executed; each node has a simple file map, commands are answered from the file map (cat) or from scripted responses
+// all RunCommands and CopyFilesToCluster calls are recorded, so that tests can inspect what a command did to the nodes
+type backendMock struct {
+	server    bool
+	client    bool
+	lock      sync.Mutex
+	templates []*mockTemplate
+	clusters  map[string]*mockCluster
+	clients   map[string]*mockCluster
+	networks  []inventoryFirewallRuleDocker
+	secGroups map[string][]string
+	volumes   []inventoryVolume
+	responses []mockResponse
+	calls     []mockCall
+	lastIp    int
+}
+
+type mockTemplate struct {
+	version backendVersion
+	script  string
+	files   map[string][]byte
+}
+
+type mockCluster struct {
+	labels    map[string]string
+	firewalls []string
+	nodes     map[int]*mockNode
+}
+
+type mockNode struct {
+	ip         string
+	running    bool
+	version    backendVersion
+	clientType string
+	expires    time.Time
+	files      map[string][]byte
+}
+
+// a scripted response; node 0 matches all nodes, command is matched as a prefix of the executed command
+type mockResponse struct {
+	clusterName string
+	isClient    bool
+	node        int
+	command     []string
+	output      []byte
+	err         error
+}
+
+// a recorded RunCommands or CopyFilesToCluster call, one per node per command/file
+type mockCall struct {
+	Method       string
+	ClusterName  string
+	IsClient     bool
+	Node         int
+	Command      []string
+	FilePath     string
+	FileContents string
+}
+
+// default configuration file installed by the aerospike server package
+var mockAerospikeConf = `# Aerospike database configuration file for use with systemd.
+
+service {
+	proto-fd-max 15000
+}
+
+logging {
+	console {
+		context any info
+	}
+}
+
+network {
+	service {
+		address any
+		port 3000
+	}
+
+	heartbeat {
+		mode multicast
+		multicast-group 239.1.99.222
+		port 9918
+
+		interval 150
+		timeout 10
+	}
+
+	fabric {
+		port 3001
+	}
+
+	info {
+		port 3003
+	}
+}
+
+namespace test {
+	replication-factor 2
+	storage-engine memory {
+		data-size 4G
+	}
+}
+`
+
+func init() {
+	addBackend("mock", &backendMock{})
+}
+
+// reset forgets all state, scripted responses and recorded calls
+func (d *backendMock) reset() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.templates = nil
+	d.clusters = nil
+	d.clients = nil
+	d.networks = nil
+	d.secGroups = nil
+	d.volumes = nil
+	d.responses = nil
+	d.calls = nil
+	d.lastIp = 0
+}
+
+// setResponse scripts the output of a command on servers; node 0 applies to all nodes; later responses take precedence
+func (d *backendMock) setResponse(clusterName string, node int, command []string, output string, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.responses = append([]mockResponse{{clusterName, false, node, command, []byte(output), err}}, d.responses...)
+}
+
+// setClientResponse is like setResponse, but for client machines
+func (d *backendMock) setClientResponse(clusterName string, node int, command []string, output string, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.responses = append([]mockResponse{{clusterName, true, node, command, []byte(output), err}}, d.responses...)
+}
+
+// getCalls returns a copy of all recorded calls
+func (d *backendMock) getCalls() []mockCall {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return append([]mockCall{}, d.calls...)
+}
+
+// getFile returns the contents of a file on a given server node
+func (d *backendMock) getFile(clusterName string, node int, filePath string) (contents string, ok bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	c, exists := d.clusters[clusterName]
+	if !exists {
+		return "", false
+	}
+	n, exists := c.nodes[node]
+	if !exists {
+		return "", false
+	}
+	f, ok := n.files[filePath]
+	return string(f), ok
+}
+
+func (d *backendMock) getClusters() map[string]*mockCluster {
+	if d.clusters == nil {
+		d.clusters = make(map[string]*mockCluster)
+	}
+	if d.clients == nil {
+		d.clients = make(map[string]*mockCluster)
+	}
+	if d.client {
+		return d.clients
+	}
+	return d.clusters
+}
+
+func (d *backendMock) getNodes(name string, nodes []int) (*mockCluster, []int, error) {
+	c, ok := d.getClusters()[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("cluster %s not found", name)
+	}
+	if len(nodes) == 0 {
+		nodes = c.nodeList()
+	}
+	for _, node := range nodes {
+		if _, ok := c.nodes[node]; !ok {
+			return nil, nil, fmt.Errorf("node %d not found in cluster %s", node, name)
+		}
+	}
+	return c, nodes, nil
+}
+
+func (c *mockCluster) nodeList() []int {
+	nodes := []int{}
+	for node := range c.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Ints(nodes)
+	return nodes
+}
+
+func (d *backendMock) GetAZName(subnetId string) (string, error) {
+	return "mock-zone", nil
+}
+
+func (d *backendMock) CreateVolume(name string, zone string, tags []string, expires time.Duration) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, vol := range d.volumes {
+		if vol.Name == name {
+			return fmt.Errorf("volume %s already exists", name)
+		}
+	}
+	vol := inventoryVolume{
+		AvailabilityZoneName: zone,
+		CreationTime:         time.Now(),
+		FileSystemId:         "fs-mock-" + name,
+		LifeCycleState:       "available",
+		Name:                 name,
+		Tags:                 make(map[string]string),
+	}
+	for _, tag := range tags {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("malformed tag %s", tag)
+		}
+		vol.Tags[kv[0]] = kv[1]
+	}
+	if expires != 0 {
+		vol.Tags["expireDuration"] = expires.String()
+	}
+	vol.Owner = vol.Tags["aerolab7owner"]
+	d.volumes = append(d.volumes, vol)
+	return nil
+}
+
+func (d *backendMock) TagVolume(fsId string, tagName string, tagValue string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, vol := range d.volumes {
+		if vol.FileSystemId == fsId {
+			vol.Tags[tagName] = tagValue
+			return nil
+		}
+	}
+	return fmt.Errorf("volume %s not found", fsId)
+}
+
+func (d *backendMock) DeleteVolume(name string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i, vol := range d.volumes {
+		if vol.Name == name {
+			d.volumes = append(d.volumes[:i], d.volumes[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("volume %s not found", name)
+}
+
+func (d *backendMock) CreateMountTarget(volume *inventoryVolume, subnet string, secGroups []string) (inventoryMountTarget, error) {
+	return inventoryMountTarget{
+		FileSystemId:   volume.FileSystemId,
+		LifeCycleState: "available",
+		MountTargetId:  "fsmt-mock-" + volume.Name,
+		SubnetId:       subnet,
+		SecurityGroups: secGroups,
+	}, nil
+}
+
+func (d *backendMock) MountTargetAddSecurityGroup(mountTarget *inventoryMountTarget, volume *inventoryVolume, addGroups []string) error {
+	mountTarget.SecurityGroups = append(mountTarget.SecurityGroups, addGroups...)
+	return nil
+}
+
+func (d *backendMock) EnableServices() error {
+	return nil
+}
+
+func (d *backendMock) ExpiriesSystemInstall(intervalMinutes int, deployRegion string) error {
+	return nil
+}
+
+func (d *backendMock) ExpiriesSystemRemove(region string) error {
+	return nil
+}
+
+func (d *backendMock) ExpiriesSystemFrequency(intervalMinutes int) error {
+	return nil
+}
+
+func (d *backendMock) ClusterExpiry(zone string, clusterName string, expiry time.Duration, nodes []int) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	c, nodes, err := d.getNodes(clusterName, nodes)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if expiry == 0 {
+			c.nodes[node].expires = time.Time{}
+		} else {
+			c.nodes[node].expires = time.Now().Add(expiry)
+		}
+	}
+	return nil
+}
+
+func (d *backendMock) IsSystemArm(systemType string) (bool, error) {
+	return false, nil
+}
+
+func (d *backendMock) IsNodeArm(clusterName string, nodeNumber int) (bool, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	c, _, err := d.getNodes(clusterName, []int{nodeNumber})
+	if err != nil {
+		return false, err
+	}
+	return c.nodes[nodeNumber].version.isArm, nil
+}
+
+func (d *backendMock) Arch() TypeArch {
+	return TypeArchUndef
+}
+
+func (d *backendMock) WorkOnClients() {
+	d.server = false
+	d.client = true
+}
+
+func (d *backendMock) WorkOnServers() {
+	d.server = true
+	d.client = false
+}
+
+func (d *backendMock) Init() error {
+	return nil
+}
+
+func (d *backendMock) ClusterList() ([]string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	clusterList := []string{}
+	for name := range d.getClusters() {
+		clusterList = append(clusterList, name)
+	}
+	sort.Strings(clusterList)
+	return clusterList, nil
+}
+
+func (d *backendMock) NodeListInCluster(name string) ([]int, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	c, ok := d.getClusters()[name]
+	if !ok {
+		return []int{}, nil
+	}
+	return c.nodeList(), nil
+}
+
+func (d *backendMock) ListTemplates() ([]backendVersion, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	templateList := []backendVersion{}
+	for _, t := range d.templates {
+		templateList = append(templateList, t.version)
+	}
+	return templateList, nil
+}
+
+func (d *backendMock) DeployTemplate(v backendVersion, script string, files []fileListReader, extra *backendExtra) error {
+	nfiles := make(map[string][]byte)
+	for _, file := range files {
+		contents, err := io.ReadAll(file.fileContents)
+		if err != nil {
+			return err
+		}
+		nfiles[file.filePath] = contents
+	}
+	if _, ok := nfiles["/etc/aerospike/aerospike.conf"]; !ok {
+		nfiles["/etc/aerospike/aerospike.conf"] = []byte(mockAerospikeConf)
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, t := range d.templates {
+		if t.version == v {
+			return fmt.Errorf("template %v already exists", v)
+		}
+	}
+	d.templates = append(d.templates, &mockTemplate{
+		version: v,
+		script:  script,
+		files:   nfiles,
+	})
+	return nil
+}
+
+func (d *backendMock) TemplateDestroy(v backendVersion) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i, t := range d.templates {
+		if t.version == v {
+			d.templates = append(d.templates[:i], d.templates[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("template %v not found", v)
+}
+
+func (d *backendMock) VacuumTemplates() error {
+	return nil
+}
+
+func (d *backendMock) VacuumTemplate(v backendVersion) error {
+	return nil
+}
+
+func (d *backendMock) DeployCluster(v backendVersion, name string, nodeCount int, extra *backendExtra) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var template *mockTemplate
+	for _, t := range d.templates {
+		if t.version == v {
+			template = t
+		}
+	}
+	if template == nil {
+		return fmt.Errorf("template %v not found", v)
+	}
+	clusters := d.getClusters()
+	c, ok := clusters[name]
+	if !ok {
+		c = &mockCluster{
+			labels: make(map[string]string),
+			nodes:  make(map[int]*mockNode),
+		}
+		clusters[name] = c
+	}
+	for _, label := range extra.labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("malformed label %s", label)
+		}
+		c.labels[kv[0]] = kv[1]
+	}
+	for _, fw := range extra.firewallNamePrefix {
+		if !inslice.HasString(c.firewalls, fw) {
+			c.firewalls = append(c.firewalls, fw)
+		}
+	}
+	start := 1
+	for node := range c.nodes {
+		if node >= start {
+			start = node + 1
+		}
+	}
+	for node := start; node < start+nodeCount; node++ {
+		d.lastIp++
+		n := &mockNode{
+			ip:         fmt.Sprintf("10.0.%d.%d", d.lastIp/250, d.lastIp%250+1),
+			version:    v,
+			clientType: extra.clientType,
+			expires:    extra.expiresTime,
+			files:      make(map[string][]byte),
+		}
+		for fn, contents := range template.files {
+			n.files[fn] = append([]byte{}, contents...)
+		}
+		c.nodes[node] = n
+	}
+	return nil
+}
+
+func (d *backendMock) CopyFilesToCluster(name string, files []fileList, nodes []int) error {
+	fr := []fileListReader{}
+	for _, f := range files {
+		fr = append(fr, fileListReader{
+			filePath:     f.filePath,
+			fileSize:     f.fileSize,
+			fileContents: strings.NewReader(f.fileContents),
+		})
+	}
+	return d.CopyFilesToClusterReader(name, fr, nodes)
+}
+
+func (d *backendMock) CopyFilesToClusterReader(name string, files []fileListReader, nodes []int) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	c, nodes, err := d.getNodes(name, nodes)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		contents, err := io.ReadAll(file.fileContents)
+		if err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			c.nodes[node].files[file.filePath] = append([]byte{}, contents...)
+			d.calls = append(d.calls, mockCall{
+				Method:       "CopyFilesToCluster",
+				ClusterName:  name,
+				IsClient:     d.client,
+				Node:         node,
+				FilePath:     file.filePath,
+				FileContents: string(contents),
+			})
+		}
+	}
+	return nil
+}
+
+func (d *backendMock) RunCommands(clusterName string, commands [][]string, nodes []int) ([][]byte, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	c, nodes, err := d.getNodes(clusterName, nodes)
+	if err != nil {
+		return nil, err
+	}
+	var fout [][]byte
+	for _, node := range nodes {
+		for _, command := range commands {
+			d.calls = append(d.calls, mockCall{
+				Method:      "RunCommands",
+				ClusterName: clusterName,
+				IsClient:    d.client,
+				Node:        node,
+				Command:     command,
+			})
+			out, err := d.runCommand(clusterName, node, c.nodes[node], command)
+			fout = append(fout, out)
+			if err != nil {
+				return fout, fmt.Errorf("error running %s: %s", command, err)
+			}
+		}
+	}
+	return fout, nil
+}
+
+// runCommand resolves a single command on a single node: scripted responses first, then built-in commands, otherwise empty success
+func (d *backendMock) runCommand(clusterName string, nodeNo int, node *mockNode, command []string) ([]byte, error) {
+	if !node.running {
+		return nil, errors.New("node is not running")
+	}
+	for _, resp := range d.responses {
+		if resp.clusterName != clusterName || resp.isClient != d.client || (resp.node != 0 && resp.node != nodeNo) || len(resp.command) > len(command) {
+			continue
+		}
+		match := true
+		for i := range resp.command {
+			if resp.command[i] != command[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return resp.output, resp.err
+		}
+	}
+	if len(command) == 0 {
+		return nil, nil
+	}
+	switch command[0] {
+	case "cat":
+		out := []byte{}
+		for _, fn := range command[1:] {
+			contents, ok := node.files[fn]
+			if !ok {
+				return out, fmt.Errorf("cat: %s: No such file or directory", fn)
+			}
+			out = append(out, contents...)
+		}
+		return out, nil
+	case "rm":
+		for _, fn := range command[1:] {
+			if !strings.HasPrefix(fn, "-") {
+				delete(node.files, fn)
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (d *backendMock) GetClusterNodeIps(name string) ([]string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	c, nodes, err := d.getNodes(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	ips := []string{}
+	for _, node := range nodes {
+		if c.nodes[node].running {
+			ips = append(ips, c.nodes[node].ip)
+		}
+	}
+	return ips, nil
+}
+
+func (d *backendMock) GetNodeIpMap(name string, internalIPs bool) (map[int]string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	c, nodes, err := d.getNodes(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	ips := make(map[int]string)
+	for _, node := range nodes {
+		if c.nodes[node].running {
+			ips[node] = c.nodes[node].ip
+		}
+	}
+	return ips, nil
+}
+
+func (d *backendMock) ClusterStart(name string, nodes []int) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.getClusters()[name]; !ok && len(nodes) == 0 {
+		return nil
+	}
+	c, nodes, err := d.getNodes(name, nodes)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		c.nodes[node].running = true
+	}
+	return nil
+}
+
+func (d *backendMock) ClusterStop(name string, nodes []int) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.getClusters()[name]; !ok && len(nodes) == 0 {
+		return nil
+	}
+	c, nodes, err := d.getNodes(name, nodes)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		c.nodes[node].running = false
+	}
+	return nil
+}
+
+func (d *backendMock) ClusterDestroy(name string, nodes []int) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.getClusters()[name]; !ok && len(nodes) == 0 {
+		return nil
+	}
+	c, nodes, err := d.getNodes(name, nodes)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		delete(c.nodes, node)
+	}
+	if len(c.nodes) == 0 {
+		delete(d.getClusters(), name)
+	}
+	return nil
+}
+
+func (d *backendMock) AttachAndRun(clusterName string, node int, command []string, isInteractive bool) (err error) {
+	return d.RunCustomOut(clusterName, node, command, os.Stdin, os.Stdout, os.Stderr, isInteractive)
+}
+
+func (d *backendMock) RunCustomOut(clusterName string, node int, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer, isInteractive bool) (err error) {
+	out, err := d.RunCommands(clusterName, [][]string{command}, []int{node})
+	if len(out) > 0 {
+		stdout.Write(out[0])
+	}
+	return err
+}
+
+func (d *backendMock) Upload(clusterName string, node int, source string, destination string, verbose bool, legacy bool) error {
+	files := []fileList{}
+	err := filepath.Walk(source, func(fn string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		contents, err := os.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, fn)
+		if err != nil {
+			return err
+		}
+		dst := destination
+		if rel != "." {
+			dst = filepath.ToSlash(filepath.Join(destination, rel))
+		}
+		files = append(files, fileList{dst, string(contents), len(contents)})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return d.CopyFilesToCluster(clusterName, files, []int{node})
+}
+
+func (d *backendMock) Download(clusterName string, node int, source string, destination string, verbose bool, legacy bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	c, _, err := d.getNodes(clusterName, []int{node})
+	if err != nil {
+		return err
+	}
+	if contents, ok := c.nodes[node].files[source]; ok {
+		return os.WriteFile(destination, contents, 0644)
+	}
+	found := false
+	prefix := strings.TrimSuffix(source, "/") + "/"
+	for fn, contents := range c.nodes[node].files {
+		if !strings.HasPrefix(fn, prefix) {
+			continue
+		}
+		found = true
+		dst := filepath.Join(destination, filepath.FromSlash(strings.TrimPrefix(fn, prefix)))
+		err = os.MkdirAll(filepath.Dir(dst), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(dst, contents, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s: no such file or directory", source)
+	}
+	return nil
+}
+
+func (d *backendMock) ClusterListFull(isJson bool, owner string, pager bool, isPretty bool, sort []string) (string, error) {
+	a.opts.Inventory.List.Json = isJson
+	a.opts.Inventory.List.Pager = pager
+	a.opts.Inventory.List.JsonPretty = isPretty
+	a.opts.Inventory.List.SortBy = sort
+	return "", a.opts.Inventory.List.run(d.server, d.client, false, false, false)
+}
+
+func (d *backendMock) TemplateListFull(isJson bool, pager bool, isPretty bool, sort []string) (string, error) {
+	a.opts.Inventory.List.Json = isJson
+	a.opts.Inventory.List.Pager = pager
+	a.opts.Inventory.List.JsonPretty = isPretty
+	a.opts.Inventory.List.SortBy = sort
+	return "", a.opts.Inventory.List.run(false, false, true, false, false)
+}
+
+func (d *backendMock) DeleteSecurityGroups(vpc string, namePrefix string, internal bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.secGroups, namePrefix)
+	return nil
+}
+
+func (d *backendMock) CreateSecurityGroups(vpc string, namePrefix string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.secGroups == nil {
+		d.secGroups = make(map[string][]string)
+	}
+	if _, ok := d.secGroups[namePrefix]; !ok {
+		d.secGroups[namePrefix] = []string{}
+	}
+	return nil
+}
+
+func (d *backendMock) LockSecurityGroups(ip string, lockSSH bool, vpc string, namePrefix string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.secGroups[namePrefix]; !ok {
+		return fmt.Errorf("security group %s not found", namePrefix)
+	}
+	d.secGroups[namePrefix] = []string{ip}
+	return nil
+}
+
+func (d *backendMock) AssignSecurityGroups(clusterName string, names []string, vpcOrZone string, remove bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	c, _, err := d.getNodes(clusterName, nil)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if remove {
+			for i, fw := range c.firewalls {
+				if fw == name {
+					c.firewalls = append(c.firewalls[:i], c.firewalls[i+1:]...)
+					break
+				}
+			}
+		} else if !inslice.HasString(c.firewalls, name) {
+			c.firewalls = append(c.firewalls, name)
+		}
+	}
+	return nil
+}
+
+func (d *backendMock) ListSecurityGroups() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	names := []string{}
+	for name := range d.secGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s\t%s\n", name, strings.Join(d.secGroups[name], ","))
+	}
+	return nil
+}
+
+func (d *backendMock) ListSubnets() error {
+	return nil
+}
+
+func (d *backendMock) CreateNetwork(name string, driver string, subnet string, mtu string, parent string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, n := range d.networks {
+		if n.NetworkName == name {
+			return fmt.Errorf("network %s already exists", name)
+		}
+	}
+	d.networks = append(d.networks, inventoryFirewallRuleDocker{
+		NetworkName:   name,
+		NetworkDriver: driver,
+		Subnets:       subnet,
+		MTU:           mtu,
+	})
+	return nil
+}
+
+func (d *backendMock) DeleteNetwork(name string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i, n := range d.networks {
+		if n.NetworkName == name {
+			d.networks = append(d.networks[:i], d.networks[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("network %s not found", name)
+}
+
+func (d *backendMock) PruneNetworks() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.networks = nil
+	return nil
+}
+
+func (d *backendMock) ListNetworks(csv bool, writer io.Writer) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if csv {
+		fmt.Fprintln(writer, "NetworkName,NetworkDriver,Subnets,MTU")
+	}
+	for _, n := range d.networks {
+		if csv {
+			fmt.Fprintf(writer, "%s,%s,%s,%s\n", n.NetworkName, n.NetworkDriver, n.Subnets, n.MTU)
+		} else {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", n.NetworkName, n.NetworkDriver, n.Subnets, n.MTU)
+		}
+	}
+	return nil
+}
+
+func (d *backendMock) Inventory(owner string, inventoryItems []int) (inventoryJson, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	ij := inventoryJson{}
+	if d.clusters == nil {
+		d.clusters = make(map[string]*mockCluster)
+	}
+	if d.clients == nil {
+		d.clients = make(map[string]*mockCluster)
+	}
+	if inslice.HasInt(inventoryItems, InventoryItemTemplates) {
+		for _, t := range d.templates {
+			ij.Templates = append(ij.Templates, inventoryTemplate{
+				AerospikeVersion: t.version.aerospikeVersion,
+				Distribution:     t.version.distroName,
+				OSVersion:        t.version.distroVersion,
+				Arch:             mockArch(t.version.isArm),
+			})
+		}
+	}
+	if inslice.HasInt(inventoryItems, InventoryItemFirewalls) {
+		for i := range d.networks {
+			n := d.networks[i]
+			ij.FirewallRules = append(ij.FirewallRules, inventoryFirewallRule{
+				Docker: &n,
+			})
+		}
+	}
+	if inslice.HasInt(inventoryItems, InventoryItemVolumes) {
+		ij.Volumes = append(ij.Volumes, d.volumes...)
+	}
+	if inslice.HasInt(inventoryItems, InventoryItemClusters) {
+		for _, name := range mockSortedNames(d.clusters) {
+			c := d.clusters[name]
+			if owner != "" && c.labels["owner"] != owner {
+				continue
+			}
+			for _, node := range c.nodeList() {
+				n := c.nodes[node]
+				labels := make(map[string]string)
+				for k, v := range c.labels {
+					labels[k] = v
+				}
+				features := ClusterFeatureAerospike | ClusterFeatureAerospikeTools
+				if n.clientType == "AGI" {
+					features = ClusterFeatureAGI
+				}
+				ij.Clusters = append(ij.Clusters, inventoryCluster{
+					ClusterName:      name,
+					NodeNo:           strconv.Itoa(node),
+					PrivateIp:        n.mockIp(),
+					InstanceId:       fmt.Sprintf("mock-%s-%d", name, node),
+					ImageId:          fmt.Sprintf("%s:%s:%s", n.version.distroName, n.version.distroVersion, n.version.aerospikeVersion),
+					State:            n.mockState(),
+					Arch:             mockArch(n.version.isArm),
+					Distribution:     n.version.distroName,
+					OSVersion:        n.version.distroVersion,
+					AerospikeVersion: n.version.aerospikeVersion,
+					Firewalls:        append([]string{}, c.firewalls...),
+					Owner:            c.labels["owner"],
+					Expires:          n.mockExpires(),
+					Features:         features,
+					AGILabel:         c.labels["agiLabel"],
+					dockerLabels:     labels,
+				})
+			}
+		}
+	}
+	if inslice.HasInt(inventoryItems, InventoryItemClients) {
+		for _, name := range mockSortedNames(d.clients) {
+			c := d.clients[name]
+			if owner != "" && c.labels["owner"] != owner {
+				continue
+			}
+			for _, node := range c.nodeList() {
+				n := c.nodes[node]
+				labels := make(map[string]string)
+				for k, v := range c.labels {
+					labels[k] = v
+				}
+				ij.Clients = append(ij.Clients, inventoryClient{
+					ClientName:       name,
+					NodeNo:           strconv.Itoa(node),
+					PrivateIp:        n.mockIp(),
+					InstanceId:       fmt.Sprintf("mock-%s-%d", name, node),
+					ImageId:          fmt.Sprintf("%s:%s:%s", n.version.distroName, n.version.distroVersion, n.version.aerospikeVersion),
+					State:            n.mockState(),
+					Arch:             mockArch(n.version.isArm),
+					Distribution:     n.version.distroName,
+					OSVersion:        n.version.distroVersion,
+					AerospikeVersion: n.version.aerospikeVersion,
+					ClientType:       n.clientType,
+					Firewalls:        append([]string{}, c.firewalls...),
+					Owner:            c.labels["owner"],
+					Expires:          n.mockExpires(),
+					dockerLabels:     labels,
+				})
+			}
+		}
+	}
+	return ij, nil
+}
+
+func (n *mockNode) mockIp() string {
+	if !n.running {
+		return ""
+	}
+	return n.ip
+}
+
+func (n *mockNode) mockState() string {
+	if n.running {
+		return "running"
+	}
+	return "stopped"
+}
+
+func (n *mockNode) mockExpires() string {
+	if n.expires.IsZero() {
+		return ""
+	}
+	return n.expires.Format(time.RFC3339)
+}
+
+func mockArch(isArm bool) string {
+	if isArm {
+		return "arm64"
+	}
+	return "amd64"
+}
+
+func mockSortedNames(clusters map[string]*mockCluster) []string {
+	names := []string{}
+	for name := range clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *backendMock) GetInstanceTypes(minCpu int, maxCpu int, minRam float64, maxRam float64, minDisks int, maxDisks int, findArm bool, gcpZone string) ([]instanceType, error) {
+	return []instanceType{}, nil
+}
+
+func (d *backendMock) SetLabel(clusterName string, key string, value string, gcpZone string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	c, ok := d.getClusters()[clusterName]
+	if !ok {
+		return fmt.Errorf("cluster %s not found", clusterName)
+	}
+	c.labels[key] = value
+	return nil
+}
+
+func (d *backendMock) GetKeyPath(clusterName string) (keyPath string, err error) {
+	return "", fmt.Errorf("feature not supported on mock")
+}
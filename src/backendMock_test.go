@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var mockVersion = backendVersion{"ubuntu", "22.04", "7.0.0.3", false}
+
+// mockSetup points aerolab at a fresh mock backend with a single template deployed and returns the backend and a features file path
+func mockSetup(t *testing.T) (*backendMock, string) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "aerolab.conf")
+	if err := os.WriteFile(conf, []byte("[config.backend]\nType = mock\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	features := filepath.Join(dir, "features.conf")
+	if err := os.WriteFile(features, []byte("feature-key-version 2\nserial-number 1\nvalid-until-date 3000-01-01\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", dir)
+	t.Setenv("AEROLAB_CONFIG_FILE", conf)
+	m := backends["mock"].(*backendMock)
+	m.reset()
+	if err := m.DeployTemplate(mockVersion, "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	return m, features
+}
+
+func mockRun(t *testing.T, args ...string) {
+	t.Helper()
+	(&restCmd{}).resetBools()
+	if err := a.main("aerolab", args); err != nil {
+		t.Fatalf("aerolab %s: %s", strings.Join(args, " "), err)
+	}
+}
+
+func mockCreateCluster(t *testing.T, features string, command string, name string, count string) {
+	t.Helper()
+	mockRun(t, "cluster", command, "-n", name, "-c", count, "-d", mockVersion.distroName, "-i", mockVersion.distroVersion, "-v", mockVersion.aerospikeVersion, "-f", features)
+}
+
+func mockHasCall(calls []mockCall, clusterName string, node int, command []string) bool {
+	for _, call := range calls {
+		if call.Method == "RunCommands" && call.ClusterName == clusterName && call.Node == node && reflect.DeepEqual(call.Command, command) {
+			return true
+		}
+	}
+	return false
+}
+
+func mockCheckMesh(t *testing.T, m *backendMock, clusterName string) {
+	t.Helper()
+	nodes, _ := m.NodeListInCluster(clusterName)
+	ips, _ := m.GetClusterNodeIps(clusterName)
+	for _, node := range nodes {
+		conf, ok := m.getFile(clusterName, node, "/etc/aerospike/aerospike.conf")
+		if !ok {
+			t.Fatalf("node %d: aerospike.conf missing", node)
+		}
+		if !strings.Contains(conf, "mode mesh") || strings.Contains(conf, "multicast-group") {
+			t.Errorf("node %d: heartbeat not switched to mesh:\n%s", node, conf)
+		}
+		for _, ip := range ips {
+			if !strings.Contains(conf, "mesh-seed-address-port "+ip+" 3002") {
+				t.Errorf("node %d: seed %s missing:\n%s", node, ip, conf)
+			}
+		}
+	}
+}
+
+func TestMockClusterCreateGrowDestroy(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "mydc", "2")
+
+	clusters, _ := m.ClusterList()
+	if !reflect.DeepEqual(clusters, []string{"mydc"}) {
+		t.Fatalf("expected cluster mydc, got %v", clusters)
+	}
+	nodes, _ := m.NodeListInCluster("mydc")
+	if !reflect.DeepEqual(nodes, []int{1, 2}) {
+		t.Fatalf("expected nodes [1 2], got %v", nodes)
+	}
+	mockCheckMesh(t, m, "mydc")
+	calls := m.getCalls()
+	for _, node := range nodes {
+		if conf, _ := m.getFile("mydc", node, "/etc/aerospike/aerospike.conf"); !strings.Contains(conf, "cluster-name mydc") {
+			t.Errorf("node %d: cluster-name not set:\n%s", node, conf)
+		}
+		if _, ok := m.getFile("mydc", node, "/etc/aerospike/features.conf"); !ok {
+			t.Errorf("node %d: features.conf not installed", node)
+		}
+		if ver, _ := m.getFile("mydc", node, "/opt/aerolab.aerospike.version"); ver != mockVersion.aerospikeVersion {
+			t.Errorf("node %d: expected version %s, got %s", node, mockVersion.aerospikeVersion, ver)
+		}
+		if host, _ := m.getFile("mydc", node, "/etc/hostname"); host != fmt.Sprintf("mydc-%d\n", node) {
+			t.Errorf("node %d: unexpected hostname %q", node, host)
+		}
+		if !mockHasCall(calls, "mydc", node, []string{"service", "aerospike", "start"}) {
+			t.Errorf("node %d: aerospike not started", node)
+		}
+	}
+
+	mockCreateCluster(t, features, "grow", "mydc", "1")
+	nodes, _ = m.NodeListInCluster("mydc")
+	if !reflect.DeepEqual(nodes, []int{1, 2, 3}) {
+		t.Fatalf("expected nodes [1 2 3] after grow, got %v", nodes)
+	}
+	if conf, _ := m.getFile("mydc", 3, "/etc/aerospike/aerospike.conf"); !strings.Contains(conf, "cluster-name mydc") || !strings.Contains(conf, "mode mesh") {
+		t.Errorf("grown node not configured:\n%s", conf)
+	}
+
+	mockRun(t, "cluster", "destroy", "-n", "mydc", "-f")
+	clusters, _ = m.ClusterList()
+	if len(clusters) != 0 {
+		t.Fatalf("expected no clusters after destroy, got %v", clusters)
+	}
+}
+
+func TestMockRosterApply(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "mydc", "2")
+	m.setResponse("mydc", 0, []string{"asinfo", "-v", "roster:namespace=test"}, "roster=null:pending_roster=null:observed_nodes=BB9020011AC4202,BB9030011AC4202\n", nil)
+
+	mockRun(t, "roster", "apply", "-n", "mydc")
+	calls := m.getCalls()
+	for _, node := range []int{1, 2} {
+		if !mockHasCall(calls, "mydc", node, []string{"asinfo", "-v", "roster-set:namespace=test;nodes=BB9020011AC4202,BB9030011AC4202"}) {
+			t.Errorf("node %d: roster-set not issued", node)
+		}
+		if !mockHasCall(calls, "mydc", node, []string{"asinfo", "-v", "recluster:namespace=test"}) {
+			t.Errorf("node %d: recluster not issued", node)
+		}
+	}
+}
+
+func TestMockXdrConnect(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "mydc", "1")
+	mockCreateCluster(t, features, "create", "destdc", "2")
+
+	mockRun(t, "xdr", "connect", "-S", "mydc", "-D", "destdc")
+	conf, _ := m.getFile("mydc", 1, "/etc/aerospike/aerospike.conf")
+	if !strings.Contains(conf, "dc destdc") {
+		t.Fatalf("xdr dc stanza missing:\n%s", conf)
+	}
+	ips, _ := m.GetClusterNodeIps("destdc")
+	for _, ip := range ips {
+		if !strings.Contains(conf, "node-address-port "+ip+" 3000") {
+			t.Errorf("destination node %s missing:\n%s", ip, conf)
+		}
+	}
+	calls := m.getCalls()
+	if !mockHasCall(calls, "mydc", 1, []string{"service", "aerospike", "stop"}) || !mockHasCall(calls, "mydc", 1, []string{"service", "aerospike", "start"}) {
+		t.Error("source cluster not restarted")
+	}
+
+	// connecting again must not duplicate the configuration
+	mockRun(t, "xdr", "connect", "-S", "mydc", "-D", "destdc")
+	conf2, _ := m.getFile("mydc", 1, "/etc/aerospike/aerospike.conf")
+	if strings.Count(conf2, "dc destdc") != 1 {
+		t.Errorf("xdr dc stanza duplicated:\n%s", conf2)
+	}
+}
+
+func TestMockConfFixMesh(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "mydc", "2")
+	if err := m.CopyFilesToCluster("mydc", []fileList{{"/etc/aerospike/aerospike.conf", mockAerospikeConf, len(mockAerospikeConf)}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mockRun(t, "conf", "fix-mesh", "-n", "mydc")
+	mockCheckMesh(t, m, "mydc")
+}
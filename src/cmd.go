@@ -29,6 +29,7 @@ type commands struct {
 	Rest         restCmd         `command:"rest-api" subcommands-optional:"true" description:"Launch HTTP rest API"`
 	AGI          agiCmd          `command:"agi" subcommands-optional:"true" description:"Launch or manage AGI troubleshooting instances"`
 	Volume       volumeCmd       `command:"volume" subcommands-optional:"true" description:"Volume management (AWS EFS only)"`
+	Apply        applyCmd        `command:"apply" subcommands-optional:"true" description:"Create or update an environment of clusters and clients from a yaml definition file"`
 	ShowCommands showcommandsCmd `command:"showcommands" subcommands-optional:"true" description:"Install showsysinfo,showconf,showinterrupts on the current system"`
 	commandsDefaults
 }
@@ -1,9 +1,37 @@
 package main
 
+import (
+	"fmt"
+	"log"
+)
+
 type aerospikeRestartCmd struct {
 	aerospikeStartCmd
+	aerospikeRollingCmd
 }
 
 func (c *aerospikeRestartCmd) Execute(args []string) error {
-	return c.run(args, "restart")
+	if !c.Rolling {
+		return c.run(args, "restart")
+	}
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running aerospike.restart --rolling")
+	nodes, err := c.nodeList()
+	if err != nil {
+		return err
+	}
+	err = c.roll(string(c.ClusterName), nodes, func(nodes []int) error {
+		out, err := b.RunCommands(string(c.ClusterName), [][]string{{"service", "aerospike", "stop"}, {"sleep", "2"}, {"service", "aerospike", "start"}}, nodes)
+		if err != nil {
+			return fmt.Errorf("%s\n%s", err, out)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Print("Done")
+	return nil
 }
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestmethod/inslice"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+type aerospikeRollingCmd struct {
+	Rolling        bool          `long:"rolling" description:"process one node at a time, waiting for it to rejoin, for the cluster to be stable and for migrations to finish before moving on"`
+	RollingRack    bool          `long:"rolling-rack" description:"with --rolling, process one rack at a time instead of one node at a time"`
+	RollingTimeout time.Duration `long:"rolling-timeout" description:"with --rolling, abort if the cluster has not settled within this time after processing a node or rack" default:"30m"`
+}
+
+// time between cluster state checks when waiting for the cluster to settle
+var aerospikeRollingInterval = 5 * time.Second
+
+type aerospikeRollingStep struct {
+	nodes    []int
+	rack     string
+	started  time.Time
+	settled  time.Time
+	result   string
+	finished bool
+}
+
+// roll calls do on one node, or one rack, at a time, waiting for the cluster to settle after each; a per-node report is printed at the end
+func (c *aerospikeRollingCmd) roll(clusterName string, nodes []int, do func(nodes []int) error) error {
+	allNodes, err := b.NodeListInCluster(clusterName)
+	if err != nil {
+		return err
+	}
+	// nodes running aerospike before we start are expected to be back in the cluster after each step
+	participating := []int{}
+	expectedSize := 0
+	for _, node := range allNodes {
+		stats, err := aerospikeRollingInfo(clusterName, node, "statistics")
+		if err != nil {
+			continue
+		}
+		participating = append(participating, node)
+		size, _ := strconv.Atoi(stats["cluster_size"])
+		if size > expectedSize {
+			expectedSize = size
+		}
+	}
+	if len(participating) == 0 {
+		return errors.New("aerospike is not running on any node, cannot perform a rolling operation")
+	}
+	for _, node := range nodes {
+		if !inslice.HasInt(participating, node) {
+			return fmt.Errorf("aerospike is not running on node %d, cannot perform a rolling operation", node)
+		}
+	}
+	log.Printf("Rolling: waiting for cluster to be stable with size %d before starting", expectedSize)
+	err = c.waitSettled(clusterName, participating, nil, expectedSize)
+	if err != nil {
+		return fmt.Errorf("cluster not stable before starting, aborting: %s", err)
+	}
+
+	steps, err := c.rollingSteps(clusterName, nodes)
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		step.started = time.Now()
+		if step.rack != "" {
+			log.Printf("Rolling: processing rack %s, nodes %s", step.rack, intSliceToString(step.nodes, ","))
+		} else {
+			log.Printf("Rolling: processing node %d", step.nodes[0])
+		}
+		err = do(step.nodes)
+		if err != nil {
+			step.result = "failed: " + err.Error()
+			err = fmt.Errorf("nodes %s failed, aborting: %s", intSliceToString(step.nodes, ","), err)
+			break
+		}
+		err = c.waitSettled(clusterName, participating, step.nodes, expectedSize)
+		if err != nil {
+			step.result = "not settled: " + err.Error()
+			err = fmt.Errorf("nodes %s did not settle, aborting: %s", intSliceToString(step.nodes, ","), err)
+			break
+		}
+		step.settled = time.Now()
+		step.result = "ok"
+		step.finished = true
+	}
+	aerospikeRollingReport(steps)
+	return err
+}
+
+// rollingSteps groups nodes into steps, per node, or per rack as reported by the cluster
+func (c *aerospikeRollingCmd) rollingSteps(clusterName string, nodes []int) ([]*aerospikeRollingStep, error) {
+	steps := []*aerospikeRollingStep{}
+	if !c.RollingRack {
+		for _, node := range nodes {
+			steps = append(steps, &aerospikeRollingStep{nodes: []int{node}, result: "skipped"})
+		}
+		return steps, nil
+	}
+	racks := make(map[string][]int)
+	for _, node := range nodes {
+		nodeId, err := aerospikeRollingInfoRaw(clusterName, node, "node")
+		if err != nil {
+			return nil, fmt.Errorf("node %d: could not get node id: %s", node, err)
+		}
+		rackInfo, err := aerospikeRollingInfoRaw(clusterName, node, "racks:")
+		if err != nil {
+			return nil, fmt.Errorf("node %d: could not get rack information: %s", node, err)
+		}
+		rack := aerospikeRollingFindRack(rackInfo, nodeId)
+		racks[rack] = append(racks[rack], node)
+	}
+	rackNames := []string{}
+	for rack := range racks {
+		rackNames = append(rackNames, rack)
+	}
+	sort.Slice(rackNames, func(i, j int) bool {
+		ii, erri := strconv.Atoi(rackNames[i])
+		jj, errj := strconv.Atoi(rackNames[j])
+		if erri != nil || errj != nil {
+			return rackNames[i] < rackNames[j]
+		}
+		return ii < jj
+	})
+	for _, rack := range rackNames {
+		steps = append(steps, &aerospikeRollingStep{nodes: racks[rack], rack: rack, result: "skipped"})
+	}
+	return steps, nil
+}
+
+// aerospikeRollingFindRack finds the rack id of a node in `racks:` output, ex: ns=test:rack_1=BB9020011AC4202,BB9030011AC4202:rack_2=BB9040011AC4202;ns=bar:...
+func aerospikeRollingFindRack(rackInfo string, nodeId string) string {
+	for _, ns := range strings.Split(strings.TrimSpace(rackInfo), ";") {
+		for _, item := range strings.Split(ns, ":") {
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 || !strings.HasPrefix(kv[0], "rack_") {
+				continue
+			}
+			for _, id := range strings.Split(kv[1], ",") {
+				if id == nodeId {
+					return strings.TrimPrefix(kv[0], "rack_")
+				}
+			}
+		}
+	}
+	return "0"
+}
+
+// waitSettled waits for the processed nodes to rejoin, for all participating nodes to agree on a stable cluster of the expected size, and for migrations to finish
+func (c *aerospikeRollingCmd) waitSettled(clusterName string, participating []int, processed []int, expectedSize int) error {
+	deadline := time.Now().Add(c.RollingTimeout)
+	lastReason := ""
+	for {
+		reason := aerospikeRollingCheck(clusterName, participating, processed, expectedSize)
+		if reason == "" {
+			return nil
+		}
+		if reason != lastReason {
+			log.Printf("Rolling: waiting: %s", reason)
+			lastReason = reason
+		}
+		if time.Now().Add(aerospikeRollingInterval).After(deadline) {
+			return fmt.Errorf("timeout after %s: %s", c.RollingTimeout, reason)
+		}
+		time.Sleep(aerospikeRollingInterval)
+	}
+}
+
+// aerospikeRollingCheck returns the reason the cluster has not settled yet, or an empty string if it has
+func aerospikeRollingCheck(clusterName string, participating []int, processed []int, expectedSize int) string {
+	clusterSize := make(map[int]int)
+	migrationsRemaining := 0
+	for _, node := range participating {
+		stats, err := aerospikeRollingInfo(clusterName, node, "statistics")
+		if err != nil {
+			if inslice.HasInt(processed, node) {
+				return fmt.Sprintf("node %d has not rejoined the cluster", node)
+			}
+			return fmt.Sprintf("node %d is not responding: %s", node, err)
+		}
+		clusterSize[node], _ = strconv.Atoi(stats["cluster_size"])
+		remaining, _ := strconv.Atoi(stats["migrate_partitions_remaining"])
+		migrationsRemaining += remaining
+	}
+	for _, node := range participating {
+		if clusterSize[node] != expectedSize {
+			if inslice.HasInt(processed, node) {
+				return fmt.Sprintf("node %d has not rejoined the cluster (cluster_size=%d, expected %d)", node, clusterSize[node], expectedSize)
+			}
+			return fmt.Sprintf("node %d sees cluster_size=%d, expected %d", node, clusterSize[node], expectedSize)
+		}
+	}
+	stableCmd := "cluster-stable:size=" + strconv.Itoa(expectedSize) + ";ignore-migrations=true"
+	if backendShellInterpreted() {
+		stableCmd = "cluster-stable:size=" + strconv.Itoa(expectedSize) + "\\;ignore-migrations=true"
+	}
+	clusterKey := ""
+	for _, node := range participating {
+		key, err := aerospikeRollingInfoRaw(clusterName, node, stableCmd)
+		if err != nil {
+			return fmt.Sprintf("node %d cluster-stable check failed: %s", node, err)
+		}
+		if strings.HasPrefix(key, "ERROR") {
+			return fmt.Sprintf("node %d reports cluster not stable: %s", node, key)
+		}
+		if clusterKey == "" {
+			clusterKey = key
+		} else if clusterKey != key {
+			return fmt.Sprintf("nodes do not agree on cluster key (%s != %s)", clusterKey, key)
+		}
+	}
+	if migrationsRemaining > 0 {
+		return fmt.Sprintf("%d partition migrations remaining", migrationsRemaining)
+	}
+	return ""
+}
+
+// aerospikeRollingInfoRaw runs an asinfo command on a node and returns the trimmed output
+func aerospikeRollingInfoRaw(clusterName string, node int, command string) (string, error) {
+	out, err := b.RunCommands(clusterName, [][]string{{"asinfo", "-v", command}}, []int{node})
+	if err != nil {
+		if len(out) > 0 {
+			return "", fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out[0])))
+		}
+		return "", err
+	}
+	if len(out) == 0 {
+		return "", errors.New("no output")
+	}
+	return strings.TrimSpace(string(out[0])), nil
+}
+
+// aerospikeRollingInfo runs an asinfo command on a node returning key=value;key=value output, and parses it
+func aerospikeRollingInfo(clusterName string, node int, command string) (map[string]string, error) {
+	out, err := aerospikeRollingInfoRaw(clusterName, node, command)
+	if err != nil {
+		return nil, err
+	}
+	if out == "" || strings.HasPrefix(out, "ERROR") {
+		return nil, fmt.Errorf("asinfo %s: %q", command, out)
+	}
+	ret := make(map[string]string)
+	for _, item := range strings.Split(out, ";") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) == 2 {
+			ret[kv[0]] = kv[1]
+		}
+	}
+	return ret, nil
+}
+
+func aerospikeRollingReport(steps []*aerospikeRollingStep) {
+	t := table.NewWriter()
+	t.SetStyle(table.StyleDefault)
+	t.AppendHeader(table.Row{"Node", "Rack", "Started", "Settled", "Duration", "Result"})
+	for _, step := range steps {
+		for _, node := range step.nodes {
+			started := ""
+			settled := ""
+			duration := ""
+			if !step.started.IsZero() {
+				started = step.started.Format(time.TimeOnly)
+			}
+			if step.finished {
+				settled = step.settled.Format(time.TimeOnly)
+				duration = step.settled.Sub(step.started).Round(time.Second).String()
+			}
+			t.AppendRow(table.Row{node, step.rack, started, settled, duration, step.result})
+		}
+	}
+	fmt.Println(t.Render())
+}
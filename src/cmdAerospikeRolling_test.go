@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockSettledCluster scripts asinfo responses of a stable cluster with no migrations
+func mockSettledCluster(m *backendMock, clusterName string, size string) {
+	m.setResponse(clusterName, 0, []string{"asinfo", "-v", "statistics"}, "cluster_size="+size+";migrate_partitions_remaining=0\n", nil)
+	m.setResponse(clusterName, 0, []string{"asinfo", "-v", "cluster-stable:size=" + size + ";ignore-migrations=true"}, "A1B2C3D4E5F6\n", nil)
+}
+
+// mockCallIndex returns the position of the first matching call, or -1
+func mockCallIndex(calls []mockCall, clusterName string, node int, command []string) int {
+	for i, call := range calls {
+		if call.Method == "RunCommands" && call.ClusterName == clusterName && call.Node == node && reflect.DeepEqual(call.Command, command) {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestMockRollingRestart(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "mydc", "3")
+	mockSettledCluster(m, "mydc", "3")
+	before := len(m.getCalls())
+
+	mockRun(t, "aerospike", "restart", "-n", "mydc", "--rolling")
+	calls := m.getCalls()[before:]
+	stop := []string{"service", "aerospike", "stop"}
+	start := []string{"service", "aerospike", "start"}
+	for node := 1; node <= 3; node++ {
+		if mockCallIndex(calls, "mydc", node, stop) < 0 || mockCallIndex(calls, "mydc", node, start) < 0 {
+			t.Fatalf("node %d: not restarted", node)
+		}
+	}
+	// each node must only be stopped once the previous one has been restarted and the cluster checked
+	for node := 2; node <= 3; node++ {
+		prevStart := mockCallIndex(calls, "mydc", node-1, start)
+		thisStop := mockCallIndex(calls, "mydc", node, stop)
+		if prevStart > thisStop {
+			t.Errorf("node %d stopped before node %d was started", node, node-1)
+		}
+		checked := false
+		for _, call := range calls[prevStart:thisStop] {
+			if len(call.Command) == 3 && strings.HasPrefix(call.Command[2], "cluster-stable:") {
+				checked = true
+			}
+		}
+		if !checked {
+			t.Errorf("cluster stability not checked before stopping node %d", node)
+		}
+	}
+}
+
+func TestMockRollingRestartAbort(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "mydc", "2")
+	defer func(interval time.Duration) {
+		aerospikeRollingInterval = interval
+	}(aerospikeRollingInterval)
+	aerospikeRollingInterval = 10 * time.Millisecond
+	stop := []string{"service", "aerospike", "stop"}
+
+	// migrations never finish, so the cluster does not settle
+	m.setResponse("mydc", 0, []string{"asinfo", "-v", "statistics"}, "cluster_size=2;migrate_partitions_remaining=10\n", nil)
+	m.setResponse("mydc", 0, []string{"asinfo", "-v", "cluster-stable:size=2;ignore-migrations=true"}, "A1B2C3D4E5F6\n", nil)
+	(&restCmd{}).resetBools()
+	err := a.main("aerolab", []string{"aerospike", "restart", "-n", "mydc", "--rolling", "--rolling-timeout", "100ms"})
+	if err == nil || !strings.Contains(err.Error(), "timeout") || !strings.Contains(err.Error(), "20 partition migrations remaining") {
+		t.Fatalf("expected migration timeout, got %v", err)
+	}
+	if mockCallIndex(m.getCalls(), "mydc", 1, stop) >= 0 {
+		t.Error("node 1 restarted even though the cluster was not stable")
+	}
+
+	// the first node fails to start, the second must not be touched
+	mockSettledCluster(m, "mydc", "2")
+	before := len(m.getCalls())
+	m.setResponse("mydc", 1, []string{"service", "aerospike", "start"}, "", errors.New("start failed"))
+	(&restCmd{}).resetBools()
+	err = a.main("aerolab", []string{"aerospike", "restart", "-n", "mydc", "--rolling"})
+	if err == nil || !strings.Contains(err.Error(), "start failed") {
+		t.Fatalf("expected start failure, got %v", err)
+	}
+	if mockCallIndex(m.getCalls()[before:], "mydc", 2, stop) >= 0 {
+		t.Error("node 2 restarted after rolling restart aborted")
+	}
+}
+
+func TestMockRollingRestartRack(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "mydc", "4")
+	mockSettledCluster(m, "mydc", "4")
+	for node, id := range map[int]string{1: "BB901", 2: "BB902", 3: "BB903", 4: "BB904"} {
+		m.setResponse("mydc", node, []string{"asinfo", "-v", "node"}, id+"\n", nil)
+	}
+	m.setResponse("mydc", 0, []string{"asinfo", "-v", "racks:"}, "ns=test:rack_2=BB901,BB903:rack_1=BB902,BB904\n", nil)
+
+	before := len(m.getCalls())
+	mockRun(t, "aerospike", "restart", "-n", "mydc", "--rolling", "--rolling-rack")
+	calls := m.getCalls()[before:]
+	stop := []string{"service", "aerospike", "stop"}
+	start := []string{"service", "aerospike", "start"}
+	// rack 1 (nodes 2,4) goes first, then rack 2 (nodes 1,3)
+	for _, rack1 := range []int{2, 4} {
+		for _, rack2 := range []int{1, 3} {
+			if mockCallIndex(calls, "mydc", rack1, start) > mockCallIndex(calls, "mydc", rack2, stop) {
+				t.Errorf("node %d of rack 2 stopped before node %d of rack 1 was started", rack2, rack1)
+			}
+		}
+	}
+}
+
+func TestAerospikeRollingFindRack(t *testing.T) {
+	racks := "ns=test:rack_1=BB9020011AC4202,BB9030011AC4202:rack_2=BB9040011AC4202;ns=bar:roster_rack_3=BB9050011AC4202:rack_3=BB9050011AC4202"
+	for node, rack := range map[string]string{"BB9030011AC4202": "1", "BB9040011AC4202": "2", "BB9050011AC4202": "3", "BB9060011AC4202": "0"} {
+		if r := aerospikeRollingFindRack(racks, node); r != rack {
+			t.Errorf("%s: expected rack %s, got %s", node, rack, r)
+		}
+	}
+}
+
+func TestMockRollingUpgrade(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "mydc", "2")
+	mockSettledCluster(m, "mydc", "2")
+
+	// serve the installer listing locally and pre-place the installer in the work directory, so that nothing is downloaded
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `<a href="aerospike-server-enterprise_7.0.0.5_tools-10.0.0_ubuntu22.04_x86_64.tgz">`)
+	}))
+	defer srv.Close()
+	defer func(url string) {
+		enterpriseUrl = url
+	}(enterpriseUrl)
+	enterpriseUrl = srv.URL + "/"
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "aerospike-server-enterprise-7.0.0.5-ubuntu22.04.x86_64.tgz"), []byte("installer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	before := len(m.getCalls())
+	mockRun(t, "aerospike", "upgrade", "-n", "mydc", "-v", "7.0.0.5", "-d", "ubuntu", "-i", "22.04", "-W", dir, "--rolling")
+	calls := m.getCalls()[before:]
+	stop := []string{"service", "aerospike", "stop"}
+	start := []string{"service", "aerospike", "start"}
+	for node := 1; node <= 2; node++ {
+		if content, _ := m.getFile("mydc", node, "/root/upgrade.tgz"); content != "installer" {
+			t.Errorf("node %d: installer not uploaded", node)
+		}
+		stopped := mockCallIndex(calls, "mydc", node, stop)
+		started := mockCallIndex(calls, "mydc", node, start)
+		installed := -1
+		for i, call := range calls {
+			if call.Method == "RunCommands" && call.Node == node && len(call.Command) == 3 && strings.HasSuffix(call.Command[2], "./asinstall") {
+				installed = i
+			}
+		}
+		if stopped < 0 || installed < stopped || started < installed {
+			t.Fatalf("node %d: expected stop, install, start in order, got %d %d %d", node, stopped, installed, started)
+		}
+	}
+	// node 2 must only be stopped once node 1 is upgraded and the cluster checked
+	checked := false
+	for _, call := range calls[mockCallIndex(calls, "mydc", 1, start):mockCallIndex(calls, "mydc", 2, stop)] {
+		if len(call.Command) == 3 && strings.HasPrefix(call.Command[2], "cluster-stable:") {
+			checked = true
+		}
+	}
+	if !checked {
+		t.Error("cluster stability not checked before upgrading node 2")
+	}
+}
@@ -11,42 +11,48 @@ import (
 	"github.com/bestmethod/inslice"
 )
 
-func (c *aerospikeStartCmd) run(args []string, command string) error {
-	if earlyProcess(args) {
-		return nil
-	}
-	log.Printf("Running aerospike.%s", command)
-
-	// check cluster exists
+// nodeList checks that the cluster exists and resolves the selected nodes, or all nodes of the cluster if none were selected
+func (c *aerospikeStartCmd) nodeList() ([]int, error) {
 	clusterList, err := b.ClusterList()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !inslice.HasString(clusterList, string(c.ClusterName)) {
-		err = fmt.Errorf("cluster does not exist: %s", string(c.ClusterName))
-		return err
+		return nil, fmt.Errorf("cluster does not exist: %s", string(c.ClusterName))
 	}
 	var nodes []int
 	if c.Nodes == "" {
 		nodes, err = b.NodeListInCluster(string(c.ClusterName))
 		if err != nil {
-			return err
+			return nil, err
 		}
 	} else {
 		err = c.Nodes.ExpandNodes(string(c.ClusterName))
 		if err != nil {
-			return err
+			return nil, err
 		}
 		for _, nodeString := range strings.Split(c.Nodes.String(), ",") {
 			nodeInt, err := strconv.Atoi(nodeString)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			nodes = append(nodes, nodeInt)
 		}
 	}
 	if len(nodes) == 0 {
-		err = errors.New("found 0 nodes in cluster")
+		return nil, errors.New("found 0 nodes in cluster")
+	}
+	return nodes, nil
+}
+
+func (c *aerospikeStartCmd) run(args []string, command string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Printf("Running aerospike.%s", command)
+
+	nodes, err := c.nodeList()
+	if err != nil {
 		return err
 	}
 
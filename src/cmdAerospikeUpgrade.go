@@ -19,6 +19,7 @@ type aerospikeUpgradeCmd struct {
 	Aws              aerospikeUpgradeCmdAws `no-flag:"true"`
 	Gcp              aerospikeUpgradeCmdAws `no-flag:"true"`
 	RestartAerospike TypeYesNo              `short:"s" long:"restart" description:"Restart aerospike after upgrade (y/n)" default:"y"`
+	aerospikeRollingCmd
 	parallelThreadsCmd
 }
 
@@ -68,6 +69,9 @@ func (c *aerospikeUpgradeCmd) Execute(args []string) error {
 	if !inslice.HasString([]string{"YES", "NO", "Y", "N"}, strings.ToUpper(c.RestartAerospike.String())) {
 		return errors.New("value for restartAerospike should be one of: y|n")
 	}
+	if c.Rolling && !inslice.HasString([]string{"YES", "Y"}, strings.ToUpper(c.RestartAerospike.String())) {
+		return errors.New("rolling upgrade requires aerospike to be restarted after upgrade")
+	}
 
 	// download aerospike
 	bv := &backendVersion{
@@ -150,6 +154,36 @@ func (c *aerospikeUpgradeCmd) Execute(args []string) error {
 		return err
 	}
 
+	ntime := strconv.Itoa(int(time.Now().Unix()))
+
+	// rolling upgrade: stop, upgrade and start one node or rack at a time, waiting for the cluster to settle in between
+	if c.Rolling {
+		err = c.roll(string(c.ClusterName), nodeList, func(nodes []int) error {
+			out, err := b.RunCommands(string(c.ClusterName), [][]string{{"service", "aerospike", "stop"}}, nodes)
+			if err != nil {
+				return fmt.Errorf("%s\n%s", err, out)
+			}
+			returns := parallelize.MapLimit(nodes, c.ParallelThreads, func(i int) error {
+				return c.upgradeNode(ntime, i)
+			})
+			for i, ret := range returns {
+				if ret != nil {
+					return fmt.Errorf("node %d: %s", nodes[i], ret)
+				}
+			}
+			out, err = b.RunCommands(string(c.ClusterName), [][]string{{"service", "aerospike", "start"}}, nodes)
+			if err != nil {
+				return fmt.Errorf("%s\n%s", err, out)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		log.Print("Done")
+		return nil
+	}
+
 	// stop aerospike
 	a.opts.Aerospike.Stop.ClusterName = c.ClusterName
 	a.opts.Aerospike.Stop.Nodes = c.Nodes
@@ -161,34 +195,13 @@ func (c *aerospikeUpgradeCmd) Execute(args []string) error {
 
 	log.Print("Upgrading Aerospike")
 	// upgrade
-	ntime := strconv.Itoa(int(time.Now().Unix()))
 	returns := parallelize.MapLimit(nodeList, c.ParallelThreads, func(i int) error {
-		// backup aerospike.conf
-		nret, err := b.RunCommands(string(c.ClusterName), [][]string{{"cat", "/etc/aerospike/aerospike.conf"}, {"mkdir", "-p", "/tmp/" + ntime}}, []int{i})
-		if err != nil {
-			return err
-		}
-		nfile := nret[0]
-		out, err := b.RunCommands(string(c.ClusterName), [][]string{{"tar", "-zxvf", "/root/upgrade.tgz", "-C", "/tmp/" + ntime}}, []int{i})
-		if err != nil {
-			return fmt.Errorf("%s : %s", string(out[0]), err)
-		}
-		// upgrade
-		out, err = b.RunCommands(string(c.ClusterName), [][]string{{"/bin/bash", "-c", fmt.Sprintf("export DEBIAN_FRONTEND=noninteractive; cd /tmp/%s/aerospike* && ./asinstall", ntime)}}, []int{i})
-		if err != nil {
-			return fmt.Errorf("%s : %s", string(out[0]), err)
-		}
-		// recover aerospike.conf backup
-		err = b.CopyFilesToCluster(string(c.ClusterName), []fileList{{"/etc/aerospike/aerospike.conf", string(nfile), len(nfile)}}, []int{i})
-		if err != nil {
-			return err
-		}
-		return nil
+		return c.upgradeNode(ntime, i)
 	})
 	isError := false
 	for i, ret := range returns {
 		if ret != nil {
-			log.Printf("Node %d returned %s", nodes[i], ret)
+			log.Printf("Node %d returned %s", nodeList[i], ret)
 			isError = true
 		}
 	}
@@ -210,3 +223,28 @@ func (c *aerospikeUpgradeCmd) Execute(args []string) error {
 	log.Print("Done")
 	return nil
 }
+
+// upgradeNode installs the uploaded /root/upgrade.tgz on a node, keeping the existing aerospike.conf
+func (c *aerospikeUpgradeCmd) upgradeNode(ntime string, i int) error {
+	// backup aerospike.conf
+	nret, err := b.RunCommands(string(c.ClusterName), [][]string{{"cat", "/etc/aerospike/aerospike.conf"}, {"mkdir", "-p", "/tmp/" + ntime}}, []int{i})
+	if err != nil {
+		return err
+	}
+	nfile := nret[0]
+	out, err := b.RunCommands(string(c.ClusterName), [][]string{{"tar", "-zxvf", "/root/upgrade.tgz", "-C", "/tmp/" + ntime}}, []int{i})
+	if err != nil {
+		return fmt.Errorf("%s : %s", string(out[0]), err)
+	}
+	// upgrade
+	out, err = b.RunCommands(string(c.ClusterName), [][]string{{"/bin/bash", "-c", fmt.Sprintf("export DEBIAN_FRONTEND=noninteractive; cd /tmp/%s/aerospike* && ./asinstall", ntime)}}, []int{i})
+	if err != nil {
+		return fmt.Errorf("%s : %s", string(out[0]), err)
+	}
+	// recover aerospike.conf backup
+	err = b.CopyFilesToCluster(string(c.ClusterName), []fileList{{"/etc/aerospike/aerospike.conf", string(nfile), len(nfile)}}, []int{i})
+	if err != nil {
+		return err
+	}
+	return nil
+}
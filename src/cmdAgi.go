@@ -32,6 +32,10 @@ type agiCmd struct {
 	Relabel   agiRelabelCmd   `command:"change-label" subcommands-optional:"true" description:"Change instance name label"`
 	Retrigger agiRetriggerCmd `command:"run-ingest" subcommands-optional:"true" description:"Retrigger log ingest again (will only do bits that have not been done before)"`
 	Attach    agiAttachCmd    `command:"attach" subcommands-optional:"true" description:"Attach to an AGI Instance"`
+	Query     agiQueryCmd     `command:"query" subcommands-optional:"true" description:"Query statistics of an AGI instance, as a table, csv or chart"`
+	Follow    agiFollowCmd    `command:"follow" subcommands-optional:"true" description:"Stream logs from the nodes of a running cluster to AGI for live ingest"`
+	Patterns  agiPatternsCmd  `command:"patterns" subcommands-optional:"true" description:"Author and test custom log ingest patterns files"`
+	Findings  agiFindingsCmd  `command:"findings" subcommands-optional:"true" description:"List findings of the ingest analysis, such as stop-writes, evictions and clock skew"`
 	AddToken  agiAddTokenCmd  `command:"add-auth-token" subcommands-optional:"true" description:"Add an auth token to AGI Proxy - only valid if token auth type was selected"`
 	Share     clusterShareCmd `command:"share" subcommands-optional:"true" description:"AWS/GCP: share the AGI node by importing a provided ssh public key file"`
 	Exec      agiExecCmd      `command:"exec" hidden:"true" subcommands-optional:"true" description:"Run an AGI subsystem"`
@@ -250,13 +254,43 @@ type agiRetriggerCmd struct {
 	S3Secret         *string         `long:"source-s3-secret-key" description:"(optional) secret key"`
 	S3path           *string         `long:"source-s3-path" description:"path on s3 to download logs from"`
 	S3Regex          *string         `long:"source-s3-regex" description:"regex to apply for choosing what to download, the regex is applied on paths AFTER the s3-path specification, not the whole path; start wih ^"`
+	GcsEnable        *bool           `long:"source-gcs-enable" description:"enable google cloud storage source"`
+	GcsThreads       *int            `long:"source-gcs-threads" description:"number of concurrent downloader threads"`
+	GcsBucket        *string         `long:"source-gcs-bucket" description:"gcs bucket name"`
+	GcsCredentials   *flags.Filename `long:"source-gcs-credentials" description:"(optional) service account json key file; default: instance default credentials, or anonymous access"`
+	GcsPath          *string         `long:"source-gcs-path" description:"path on gcs to download logs from"`
+	GcsRegex         *string         `long:"source-gcs-regex" description:"regex to apply for choosing what to download, the regex is applied on paths AFTER the gcs-path specification, not the whole path; start wih ^"`
+	AzureEnable      *bool           `long:"source-azure-enable" description:"enable azure blob storage source"`
+	AzureThreads     *int            `long:"source-azure-threads" description:"number of concurrent downloader threads"`
+	AzureAccount     *string         `long:"source-azure-account" description:"azure storage account name"`
+	AzureContainer   *string         `long:"source-azure-container" description:"azure blob container name"`
+	AzureSasToken    *string         `long:"source-azure-sas-token" description:"(optional) SAS token with read and list permissions"`
+	AzurePath        *string         `long:"source-azure-path" description:"path in the container to download logs from"`
+	AzureRegex       *string         `long:"source-azure-regex" description:"regex to apply for choosing what to download, the regex is applied on paths AFTER the azure-path specification, not the whole path; start wih ^"`
+	HttpEnable       *bool           `long:"source-http-enable" description:"enable http(s) source"`
+	HttpThreads      *int            `long:"source-http-threads" description:"number of concurrent downloader threads"`
+	HttpUrls         []string        `long:"source-http-url" description:"URL to download, can be a pre-signed link; this parameter can be specified multiple times; replaces the configured URLs"`
+	HttpUser         *string         `long:"source-http-user" description:"(optional) basic auth user"`
+	HttpPass         *string         `long:"source-http-pass" description:"(optional) basic auth password"`
+	HttpRegex        *string         `long:"source-http-regex" description:"regex to apply for choosing what to download, the regex is applied on host/path of each URL; start wih ^"`
+	ClusterSource    *string         `long:"source-cluster" description:"get logs from the nodes of an aerolab cluster with this name"`
+	ClusterNodes     TypeNodes       `long:"source-cluster-nodes" description:"cluster nodes to get logs from, comma separated; default: all nodes"`
+	ClusterPath      string          `long:"source-cluster-path" description:"log directory or file on the cluster nodes; for docker use /var/log/aerospike.log" default:"/var/log/aerospike"`
+	ClusterRegex     string          `long:"source-cluster-regex" description:"regex to apply for choosing what to download, the regex is applied on paths AFTER the cluster-path specification, not the whole path; start wih ^"`
+	ClusterJournal   bool            `long:"source-cluster-journal" description:"get logs from journald instead of log files"`
+	ClusterThreads   int             `long:"source-cluster-threads" description:"number of nodes to get logs from concurrently" default:"6"`
 	TimeRanges       *bool           `long:"ingest-timeranges-enable" description:"enable importing statistics only on a specified time range found in the logs"`
 	TimeRangesFrom   *string         `long:"ingest-timeranges-from" description:"time range from, format: 2006-01-02T15:04:05Z07:00"`
 	TimeRangesTo     *string         `long:"ingest-timeranges-to" description:"time range to, format: 2006-01-02T15:04:05Z07:00"`
 	CustomSourceName *string         `long:"ingest-custom-source-name" description:"custom source name to disaplay in grafana"`
 	PatternsFile     *flags.Filename `long:"ingest-patterns-file" description:"provide a custom patterns YAML file to the log ingest system"`
+	FindingsFile     *flags.Filename `long:"ingest-findings-file" description:"provide a custom findings rules YAML file to the log ingest system; see: aerolab agi findings --default-rules"`
+	Anonymize        *bool           `long:"ingest-anonymize" description:"replace hostnames, IPs, namespace, set and user names with consistent tokens before processing; only newly added logs are anonymized, using tokens numbered in a new namespace"`
+	AnonymizeFile    *flags.Filename `long:"ingest-anonymize-rules-file" description:"provide a custom anonymize rules YAML file to the log ingest system; see: aerolab logs anonymize --default-rules"`
 	IngestLogLevel   *int            `long:"ingest-log-level" description:"1-CRITICAL,2-ERROR,3-WARN,4-INFO,5-DEBUG,6-DETAIL"`
 	IngestCpuProfile *bool           `long:"ingest-cpu-profiling" description:"enable log ingest cpu profiling"`
+	IngestFollow     *bool           `long:"ingest-follow" description:"after ingesting, keep following log files for new lines; a following ingest is stopped and restarted by run-ingest"`
+	FollowPaths      []string        `long:"ingest-follow-path" description:"glob pattern of log files on the instance to follow; can be specified multiple times; replaces the configured paths"`
 	Force            bool            `long:"force" description:"do not ask for confirmation, just continue"`
 	Help             helpCmd         `command:"help" subcommands-optional:"true" description:"Print help"`
 }
@@ -265,8 +299,8 @@ func (c *agiRetriggerCmd) Execute(args []string) error {
 	if earlyProcess(args) {
 		return nil
 	}
-	// if sftp key, local source or patterns file are specified, ensure they exist
-	for _, k := range []*string{(*string)(c.SftpKey), (*string)(c.PatternsFile), (*string)(c.LocalSource)} {
+	// if sftp key, local source, patterns or findings file are specified, ensure they exist
+	for _, k := range []*string{(*string)(c.SftpKey), (*string)(c.GcsCredentials), (*string)(c.PatternsFile), (*string)(c.FindingsFile), (*string)(c.AnonymizeFile), (*string)(c.LocalSource)} {
 		if k != nil && *k != "" {
 			if _, err := os.Stat(*k); err != nil {
 				return fmt.Errorf("could not access %s: %s", *k, err)
@@ -290,17 +324,8 @@ func (c *agiRetriggerCmd) Execute(args []string) error {
 		}
 	}
 
-	// check if ingest is already running
-	out, err := b.RunCommands(c.ClusterName.String(), [][]string{{"/bin/bash", "-c", "cat /opt/agi/ingest.pid"}}, []int{1})
-	if err == nil {
-		_, err = b.RunCommands(c.ClusterName.String(), [][]string{{"/bin/bash", "-c", "ls /proc |egrep '^" + strings.Trim(string(out[0]), "\r\n\t ") + "$'"}}, []int{1})
-		if err == nil {
-			return errors.New("ingest already running")
-		}
-	}
-
 	// read current config into the config struct
-	out, err = b.RunCommands(c.ClusterName.String(), [][]string{{"cat", "/opt/agi/ingest.yaml"}}, []int{1})
+	out, err := b.RunCommands(c.ClusterName.String(), [][]string{{"cat", "/opt/agi/ingest.yaml"}}, []int{1})
 	if err != nil {
 		return fmt.Errorf("could not get current config: %s: %s", err, string(out[0]))
 	}
@@ -310,6 +335,23 @@ func (c *agiRetriggerCmd) Execute(args []string) error {
 		return fmt.Errorf("could not unmarshal current config: %s", err)
 	}
 
+	// check if ingest is already running; an ingest in follow mode never finishes, so stop it instead
+	out, err = b.RunCommands(c.ClusterName.String(), [][]string{{"/bin/bash", "-c", "cat /opt/agi/ingest.pid"}}, []int{1})
+	if err == nil {
+		pid := strings.Trim(string(out[0]), "\r\n\t ")
+		_, err = b.RunCommands(c.ClusterName.String(), [][]string{{"/bin/bash", "-c", "ls /proc |egrep '^" + pid + "$'"}}, []int{1})
+		if err == nil {
+			if !conf.Follow.Enabled {
+				return errors.New("ingest already running")
+			}
+			log.Println("Stopping ingest running in follow mode")
+			out, err = b.RunCommands(c.ClusterName.String(), [][]string{{"/bin/bash", "-c", "kill " + pid + "; for i in $(seq 1 600); do [ -d /proc/" + pid + " ] || exit 0; sleep 1; done; exit 1"}}, []int{1})
+			if err != nil {
+				return fmt.Errorf("could not stop running ingest: %s: %s", err, string(out[0]))
+			}
+		}
+	}
+
 	// update any relevant parameters
 	if c.SftpEnable != nil {
 		conf.Downloader.SftpSource.Enabled = *c.SftpEnable
@@ -362,6 +404,76 @@ func (c *agiRetriggerCmd) Execute(args []string) error {
 	if c.S3Regex != nil {
 		conf.Downloader.S3Source.SearchRegex = *c.S3Regex
 	}
+	if c.GcsEnable != nil {
+		conf.Downloader.GcsSource.Enabled = *c.GcsEnable
+	}
+	if c.GcsThreads != nil {
+		conf.Downloader.GcsSource.Threads = *c.GcsThreads
+	}
+	if c.GcsBucket != nil {
+		conf.Downloader.GcsSource.BucketName = *c.GcsBucket
+	}
+	if c.GcsCredentials != nil {
+		conf.Downloader.GcsSource.CredentialsFile = ""
+		if *c.GcsCredentials != "" {
+			conf.Downloader.GcsSource.CredentialsFile = "/opt/agi/gcs.json"
+		}
+	}
+	if c.GcsPath != nil {
+		conf.Downloader.GcsSource.PathPrefix = *c.GcsPath
+	}
+	if c.GcsRegex != nil {
+		conf.Downloader.GcsSource.SearchRegex = *c.GcsRegex
+	}
+	if c.AzureEnable != nil {
+		conf.Downloader.AzureSource.Enabled = *c.AzureEnable
+	}
+	if c.AzureThreads != nil {
+		conf.Downloader.AzureSource.Threads = *c.AzureThreads
+	}
+	if c.AzureAccount != nil {
+		conf.Downloader.AzureSource.AccountName = *c.AzureAccount
+	}
+	if c.AzureContainer != nil {
+		conf.Downloader.AzureSource.ContainerName = *c.AzureContainer
+	}
+	if c.AzureSasToken != nil {
+		conf.Downloader.AzureSource.SasToken = *c.AzureSasToken
+	}
+	if c.AzurePath != nil {
+		conf.Downloader.AzureSource.PathPrefix = *c.AzurePath
+	}
+	if c.AzureRegex != nil {
+		conf.Downloader.AzureSource.SearchRegex = *c.AzureRegex
+	}
+	if c.HttpEnable != nil {
+		conf.Downloader.HttpSource.Enabled = *c.HttpEnable
+	}
+	if c.HttpThreads != nil {
+		conf.Downloader.HttpSource.Threads = *c.HttpThreads
+	}
+	if len(c.HttpUrls) > 0 {
+		conf.Downloader.HttpSource.URLs = c.HttpUrls
+	}
+	if c.HttpUser != nil {
+		conf.Downloader.HttpSource.Username = *c.HttpUser
+	}
+	if c.HttpPass != nil {
+		conf.Downloader.HttpSource.Password = *c.HttpPass
+	}
+	if c.HttpRegex != nil {
+		conf.Downloader.HttpSource.SearchRegex = *c.HttpRegex
+	}
+	if c.ClusterSource != nil {
+		conf.Downloader.ClusterSource.Enabled = *c.ClusterSource != ""
+		conf.Downloader.ClusterSource.ClusterName = *c.ClusterSource
+		conf.Downloader.ClusterSource.Nodes = c.ClusterNodes.String()
+		conf.Downloader.ClusterSource.PathPrefix = c.ClusterPath
+		conf.Downloader.ClusterSource.SearchRegex = c.ClusterRegex
+		if c.ClusterJournal {
+			conf.Downloader.ClusterSource.PathPrefix = "journald"
+		}
+	}
 	if c.TimeRanges != nil {
 		conf.IngestTimeRanges.Enabled = *c.TimeRanges
 	}
@@ -377,9 +489,30 @@ func (c *agiRetriggerCmd) Execute(args []string) error {
 	if c.PatternsFile != nil {
 		conf.PatternsFile = "/opt/agi/patterns.yaml"
 	}
+	if c.FindingsFile != nil {
+		conf.Findings.RulesFile = "/opt/agi/findings.yaml"
+	}
+	if c.AnonymizeFile != nil {
+		conf.Anonymize.RulesFile = "/opt/agi/anonymize.yaml"
+		conf.Anonymize.Enabled = true
+	}
+	if c.Anonymize != nil {
+		conf.Anonymize.Enabled = *c.Anonymize
+	}
+	// the map allows reversing the tokens, it must never be stored on the instance, which is shared
+	conf.Anonymize.MapFile = ""
 	if c.IngestLogLevel != nil {
 		conf.LogLevel = *c.IngestLogLevel
 	}
+	if c.IngestFollow != nil {
+		conf.Follow.Enabled = *c.IngestFollow
+	}
+	if len(c.FollowPaths) > 0 {
+		conf.Follow.Paths = c.FollowPaths
+	}
+	if len(conf.Follow.Paths) == 0 {
+		conf.Follow.Paths = []string{agiFollowPaths}
+	}
 	if c.IngestCpuProfile != nil {
 		if *c.IngestCpuProfile {
 			conf.CPUProfilingOutputFile = "/opt/agi/cpu.ingest.pprof"
@@ -393,6 +526,17 @@ func (c *agiRetriggerCmd) Execute(args []string) error {
 		return errors.New("S3 source is enabled, but SecretKey has been redacted by the previous run; update the secret key value")
 	}
 
+	// check if azure or http are enabled but the secrets have been redacted
+	if conf.Downloader.AzureSource.Enabled && conf.Downloader.AzureSource.SasToken == "<redacted>" {
+		return errors.New("azure source is enabled, but SasToken has been redacted by the previous run; update the SAS token value")
+	}
+	if conf.Downloader.HttpSource.Enabled && conf.Downloader.HttpSource.Password == "<redacted>" {
+		return errors.New("http source is enabled, but Password has been redacted by the previous run; update the password value")
+	}
+	if conf.Downloader.GcsSource.Enabled && conf.Downloader.GcsSource.CredentialsFile == "<redacted>" {
+		return errors.New("gcs source is enabled, but the credentials file has been redacted by the previous run; provide the credentials file")
+	}
+
 	// check if sftp is enabled but no pass/key present, key does not exist, or pass=="<redacted>"
 	if conf.Downloader.SftpSource.Enabled && (conf.Downloader.SftpSource.KeyFile == "<redacted>" || conf.Downloader.SftpSource.Password == "<redacted>") {
 		return errors.New("sftp source is enabled, but the password ot keyFile is in <redacted> state, provide one and set the other to an empty string")
@@ -452,6 +596,38 @@ func (c *agiRetriggerCmd) Execute(args []string) error {
 			fileSize:     int(stat.Size()),
 		})
 	}
+	if c.FindingsFile != nil && *c.FindingsFile != "" {
+		stat, err := os.Stat(string(*c.FindingsFile))
+		if err != nil {
+			return fmt.Errorf("could not access findings file: %s", err)
+		}
+		f, err := os.Open(string(*c.FindingsFile))
+		if err != nil {
+			return fmt.Errorf("failed to open findings file: %s", err)
+		}
+		defer f.Close()
+		flist = append(flist, fileListReader{
+			filePath:     "/opt/agi/findings.yaml",
+			fileContents: f,
+			fileSize:     int(stat.Size()),
+		})
+	}
+	if c.AnonymizeFile != nil && *c.AnonymizeFile != "" {
+		stat, err := os.Stat(string(*c.AnonymizeFile))
+		if err != nil {
+			return fmt.Errorf("could not access anonymize rules file: %s", err)
+		}
+		f, err := os.Open(string(*c.AnonymizeFile))
+		if err != nil {
+			return fmt.Errorf("failed to open anonymize rules file: %s", err)
+		}
+		defer f.Close()
+		flist = append(flist, fileListReader{
+			filePath:     "/opt/agi/anonymize.yaml",
+			fileContents: f,
+			fileSize:     int(stat.Size()),
+		})
+	}
 	if c.SftpKey != nil && *c.SftpKey != "" {
 		stat, err := os.Stat(string(*c.SftpKey))
 		if err != nil {
@@ -468,6 +644,22 @@ func (c *agiRetriggerCmd) Execute(args []string) error {
 			fileSize:     int(stat.Size()),
 		})
 	}
+	if c.GcsCredentials != nil && *c.GcsCredentials != "" {
+		stat, err := os.Stat(string(*c.GcsCredentials))
+		if err != nil {
+			return fmt.Errorf("could not access gcs credentials file: %s", err)
+		}
+		f, err := os.Open(string(*c.GcsCredentials))
+		if err != nil {
+			return fmt.Errorf("failed to open gcs credentials file: %s", err)
+		}
+		defer f.Close()
+		flist = append(flist, fileListReader{
+			filePath:     "/opt/agi/gcs.json",
+			fileContents: f,
+			fileSize:     int(stat.Size()),
+		})
+	}
 	flist = append(flist, fileListReader{
 		filePath:     "/opt/agi/ingest.yaml",
 		fileContents: bytes.NewReader(newConfig),
@@ -490,8 +682,23 @@ func (c *agiRetriggerCmd) Execute(args []string) error {
 			return fmt.Errorf("failed to upload local source to remote: %s", err)
 		}
 	}
-	// remove /opt/agi/ingest/steps.json on remote and restart agi-ingest
-	if a.opts.Config.Backend.Type != "docker" {
+	// if cluster source is specified, pull the logs from the cluster and upload to remote
+	if c.ClusterSource != nil && *c.ClusterSource != "" {
+		cs := &agiClusterSource{
+			ClusterName: *c.ClusterSource,
+			Nodes:       c.ClusterNodes,
+			Path:        c.ClusterPath,
+			Regex:       c.ClusterRegex,
+			Journal:     c.ClusterJournal,
+			Threads:     c.ClusterThreads,
+		}
+		err = cs.pull(c.ClusterName.String())
+		if err != nil {
+			return fmt.Errorf("failed to get logs from cluster source: %s", err)
+		}
+	}
+	// remove /opt/agi/ingest/steps.json on remote and restart agi-ingest; containers have no init system, so it is started by its autoload script
+	if a.opts.Config.Backend.Type != "docker" && a.opts.Config.Backend.Type != "kubernetes" {
 		out, err = b.RunCommands(c.ClusterName.String(), [][]string{{"/bin/bash", "-c", "rm -f /opt/agi/ingest/steps.json; service agi-ingest start"}}, []int{1})
 		if err != nil {
 			return fmt.Errorf("could not start ingest system: %s: %s", err, string(out[0]))
@@ -585,8 +792,10 @@ func (c *agiStatusCmd) Execute(args []string) error {
 	fmt.Printf("* DOWNLOAD     : %s%s\n", c.boolToProgress(clusterStatus.Ingest.CompleteSteps.Download, "DONE", "PENDING", "IN-PROGRESS", clusterStatus.Ingest.CompleteSteps.Init), downloadStr)
 	fmt.Printf("* UNPACK       : %s\n", c.boolToProgress(clusterStatus.Ingest.CompleteSteps.Unpack, "DONE", "PENDING", "IN-PROGRESS", clusterStatus.Ingest.CompleteSteps.Download))
 	fmt.Printf("* PRE-PROCESS  : %s\n", c.boolToProgress(clusterStatus.Ingest.CompleteSteps.PreProcess, "DONE", "PENDING", "IN-PROGRESS", clusterStatus.Ingest.CompleteSteps.Unpack))
+	fmt.Printf("* ANONYMIZE    : %s\n", c.boolToProgress(clusterStatus.Ingest.CompleteSteps.Anonymize, "DONE", "PENDING", "IN-PROGRESS", clusterStatus.Ingest.CompleteSteps.PreProcess))
 	fmt.Printf("* PROCESS-LOGS : %s%s\n", c.boolToProgress(clusterStatus.Ingest.CompleteSteps.ProcessLogs, "DONE", "PENDING", "IN-PROGRESS", clusterStatus.Ingest.CompleteSteps.PreProcess), processStr)
 	fmt.Printf("* COLLECTINFO  : %s\n", c.boolToProgress(clusterStatus.Ingest.CompleteSteps.ProcessCollectInfo, "DONE", "PENDING", "IN-PROGRESS", clusterStatus.Ingest.CompleteSteps.PreProcess))
+	fmt.Printf("* FINDINGS     : %s\n", c.boolToProgress(clusterStatus.Ingest.CompleteSteps.Analyze, "DONE", "PENDING", "IN-PROGRESS", clusterStatus.Ingest.CompleteSteps.ProcessLogs && clusterStatus.Ingest.CompleteSteps.ProcessCollectInfo))
 
 	if len(clusterStatus.Ingest.Errors) > 0 {
 		fmt.Println("\nINGEST ERRORS:")
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/bestmethod/inslice"
+)
+
+// agiClusterSource pulls aerospike logs from the nodes of an aerolab cluster via the backend and stages them on the AGI instance for the ingest cluster source
+type agiClusterSource struct {
+	ClusterName string
+	Nodes       TypeNodes
+	Path        string
+	Regex       string
+	Journal     bool
+	Threads     int
+}
+
+const agiClusterSourceTmp = "/tmp/aerolab-agi-logs.tgz"
+
+// stageDir returns the directory on the AGI instance in which the log archives of the cluster nodes are staged
+func (s *agiClusterSource) stageDir() string {
+	return path.Join("/opt/agi/files/input/clustersource", s.ClusterName)
+}
+
+// nodes resolves the node list, defaulting to all nodes of the cluster
+func (s *agiClusterSource) nodes() ([]int, error) {
+	clusterList, err := b.ClusterList()
+	if err != nil {
+		return nil, err
+	}
+	if !inslice.HasString(clusterList, s.ClusterName) {
+		return nil, fmt.Errorf("cluster does not exist: %s", s.ClusterName)
+	}
+	err = s.Nodes.ExpandNodes(s.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+	nodesList, err := b.NodeListInCluster(s.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+	if s.Nodes == "" {
+		return nodesList, nil
+	}
+	nodes := []int{}
+	for _, nodeString := range strings.Split(s.Nodes.String(), ",") {
+		nodeInt, err := strconv.Atoi(nodeString)
+		if err != nil {
+			return nil, err
+		}
+		if !inslice.HasInt(nodesList, nodeInt) {
+			return nil, fmt.Errorf("node %d does not exist in cluster", nodeInt)
+		}
+		nodes = append(nodes, nodeInt)
+	}
+	return nodes, nil
+}
+
+// pull downloads the logs of each node as node-X.tgz and uploads them to the AGI instance
+func (s *agiClusterSource) pull(agiName string) error {
+	var regex *regexp.Regexp
+	var err error
+	if s.Regex != "" {
+		regex, err = regexp.Compile(s.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid cluster source regex: %s", err)
+		}
+	}
+	nodes, err := s.nodes()
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return errors.New("found 0 nodes in cluster")
+	}
+	if s.Threads < 1 {
+		s.Threads = 1
+	}
+	tmpDir, err := os.MkdirTemp("", "aerolab-agi-cluster-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	log.Printf("Pulling logs from cluster %s nodes %v", s.ClusterName, nodes)
+	returns := parallelize.MapLimit(nodes, s.Threads, func(node int) error {
+		return s.pullNode(node, regex, filepath.Join(tmpDir, "node-"+strconv.Itoa(node)+".tgz"))
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", nodes[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return errors.New("could not pull logs from some nodes")
+	}
+
+	flist := []fileListReader{}
+	for _, node := range nodes {
+		fn := filepath.Join(tmpDir, "node-"+strconv.Itoa(node)+".tgz")
+		stat, err := os.Stat(fn)
+		if err != nil {
+			continue
+		}
+		f, err := os.Open(fn)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		flist = append(flist, fileListReader{
+			filePath:     path.Join(s.stageDir(), "node-"+strconv.Itoa(node)+".tgz"),
+			fileContents: f,
+			fileSize:     int(stat.Size()),
+		})
+	}
+	if len(flist) == 0 {
+		return fmt.Errorf("no log files found in %s on cluster %s", s.Path, s.ClusterName)
+	}
+	out, err := b.RunCommands(agiName, [][]string{{"mkdir", "-p", s.stageDir()}}, []int{1})
+	if err != nil {
+		return fmt.Errorf("could not create %s on remote: %s: %s", s.stageDir(), err, string(out[0]))
+	}
+	err = b.CopyFilesToClusterReader(agiName, flist, []int{1})
+	if err != nil {
+		return fmt.Errorf("could not upload cluster logs to instance: %s", err)
+	}
+	return nil
+}
+
+// pullNode archives the matching log files on the node and downloads the archive to dst; no file is created if no logs match
+func (s *agiClusterSource) pullNode(node int, regex *regexp.Regexp, dst string) error {
+	if s.Journal {
+		out, err := b.RunCommands(s.ClusterName, [][]string{{"/bin/bash", "-c", "journalctl -u aerospike --no-pager > /tmp/aerospike.log"}}, []int{node})
+		if err != nil {
+			return fmt.Errorf("journalctl error: %s: %s", err, string(out[0]))
+		}
+		out, err = b.RunCommands(s.ClusterName, [][]string{{"tar", "-czf", agiClusterSourceTmp, "-C", "/tmp", "aerospike.log"}, {"rm", "-f", "/tmp/aerospike.log"}}, []int{node})
+		if err != nil {
+			return fmt.Errorf("could not archive logs: %s: %s", err, string(out[0]))
+		}
+	} else {
+		out, err := b.RunCommands(s.ClusterName, [][]string{{"find", s.Path, "-type", "f"}}, []int{node})
+		if err != nil {
+			return fmt.Errorf("could not list %s: %s: %s", s.Path, err, string(out[0]))
+		}
+		files := []string{}
+		for _, line := range strings.Split(string(out[0]), "\n") {
+			line = strings.Trim(line, "\r\t ")
+			if line == "" {
+				continue
+			}
+			// the regex is applied on paths AFTER the path specification, or on the file name if the path is a file
+			regexOn := strings.TrimPrefix(strings.TrimPrefix(line, s.Path), "/")
+			if regexOn == "" {
+				regexOn = path.Base(line)
+			}
+			if regex != nil && !regex.MatchString(regexOn) {
+				continue
+			}
+			files = append(files, strings.TrimPrefix(line, "/"))
+		}
+		if len(files) == 0 {
+			log.Printf("Node %d: no log files found in %s", node, s.Path)
+			return nil
+		}
+		out, err = b.RunCommands(s.ClusterName, [][]string{append([]string{"tar", "-czf", agiClusterSourceTmp, "-C", "/"}, files...)}, []int{node})
+		if err != nil {
+			return fmt.Errorf("could not archive logs: %s: %s", err, string(out[0]))
+		}
+		log.Printf("Node %d: pulling %d log files", node, len(files))
+	}
+	err := b.Download(s.ClusterName, node, agiClusterSourceTmp, dst, false, false)
+	if err != nil {
+		return fmt.Errorf("could not download logs: %s", err)
+	}
+	_, err = b.RunCommands(s.ClusterName, [][]string{{"rm", "-f", agiClusterSourceTmp}}, []int{node})
+	return err
+}
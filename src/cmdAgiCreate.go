@@ -39,6 +39,31 @@ type agiCreateCmd struct {
 	S3Secret         string          `long:"source-s3-secret-key" description:"(optional) secret key"`
 	S3path           string          `long:"source-s3-path" description:"path on s3 to download logs from"`
 	S3Regex          string          `long:"source-s3-regex" description:"regex to apply for choosing what to download, the regex is applied on paths AFTER the s3-path specification, not the whole path; start wih ^"`
+	GcsEnable        bool            `long:"source-gcs-enable" description:"enable google cloud storage source"`
+	GcsThreads       int             `long:"source-gcs-threads" description:"number of concurrent downloader threads" default:"6"`
+	GcsBucket        string          `long:"source-gcs-bucket" description:"gcs bucket name"`
+	GcsCredentials   flags.Filename  `long:"source-gcs-credentials" description:"(optional) service account json key file; default: instance default credentials, or anonymous access"`
+	GcsPath          string          `long:"source-gcs-path" description:"path on gcs to download logs from"`
+	GcsRegex         string          `long:"source-gcs-regex" description:"regex to apply for choosing what to download, the regex is applied on paths AFTER the gcs-path specification, not the whole path; start wih ^"`
+	AzureEnable      bool            `long:"source-azure-enable" description:"enable azure blob storage source"`
+	AzureThreads     int             `long:"source-azure-threads" description:"number of concurrent downloader threads" default:"6"`
+	AzureAccount     string          `long:"source-azure-account" description:"azure storage account name"`
+	AzureContainer   string          `long:"source-azure-container" description:"azure blob container name"`
+	AzureSasToken    string          `long:"source-azure-sas-token" description:"(optional) SAS token with read and list permissions"`
+	AzurePath        string          `long:"source-azure-path" description:"path in the container to download logs from"`
+	AzureRegex       string          `long:"source-azure-regex" description:"regex to apply for choosing what to download, the regex is applied on paths AFTER the azure-path specification, not the whole path; start wih ^"`
+	HttpEnable       bool            `long:"source-http-enable" description:"enable http(s) source"`
+	HttpThreads      int             `long:"source-http-threads" description:"number of concurrent downloader threads" default:"6"`
+	HttpUrls         []string        `long:"source-http-url" description:"URL to download, can be a pre-signed link; this parameter can be specified multiple times"`
+	HttpUser         string          `long:"source-http-user" description:"(optional) basic auth user"`
+	HttpPass         string          `long:"source-http-pass" description:"(optional) basic auth password"`
+	HttpRegex        string          `long:"source-http-regex" description:"regex to apply for choosing what to download, the regex is applied on host/path of each URL; start wih ^"`
+	ClusterSource    string          `long:"source-cluster" description:"get logs from the nodes of an aerolab cluster with this name"`
+	ClusterNodes     TypeNodes       `long:"source-cluster-nodes" description:"cluster nodes to get logs from, comma separated; default: all nodes"`
+	ClusterPath      string          `long:"source-cluster-path" description:"log directory or file on the cluster nodes; for docker use /var/log/aerospike.log" default:"/var/log/aerospike"`
+	ClusterRegex     string          `long:"source-cluster-regex" description:"regex to apply for choosing what to download, the regex is applied on paths AFTER the cluster-path specification, not the whole path; start wih ^"`
+	ClusterJournal   bool            `long:"source-cluster-journal" description:"get logs from journald instead of log files"`
+	ClusterThreads   int             `long:"source-cluster-threads" description:"number of nodes to get logs from concurrently" default:"6"`
 	ProxyDisableSSL  bool            `long:"proxy-ssl-disable" description:"switch to disable TLS on the proxy"`
 	ProxyCert        flags.Filename  `long:"proxy-ssl-cert" description:"if not provided snakeoil will be used"`
 	ProxyKey         flags.Filename  `long:"proxy-ssl-key" description:"if not provided snakeoil will be used"`
@@ -49,8 +74,13 @@ type agiCreateCmd struct {
 	TimeRangesTo     string          `long:"ingest-timeranges-to" description:"time range to, format: 2006-01-02T15:04:05Z07:00"`
 	CustomSourceName string          `long:"ingest-custom-source-name" description:"custom source name to disaplay in grafana"`
 	PatternsFile     flags.Filename  `long:"ingest-patterns-file" description:"provide a custom patterns YAML file to the log ingest system"`
+	FindingsFile     flags.Filename  `long:"ingest-findings-file" description:"provide a custom findings rules YAML file to the log ingest system; see: aerolab agi findings --default-rules"`
+	Anonymize        bool            `long:"ingest-anonymize" description:"replace hostnames, IPs, namespace, set and user names with consistent tokens before processing"`
+	AnonymizeFile    flags.Filename  `long:"ingest-anonymize-rules-file" description:"provide a custom anonymize rules YAML file to the log ingest system; see: aerolab logs anonymize --default-rules"`
 	IngestLogLevel   int             `long:"ingest-log-level" description:"1-CRITICAL,2-ERROR,3-WARN,4-INFO,5-DEBUG,6-DETAIL" default:"4"`
 	IngestCpuProfile bool            `long:"ingest-cpu-profiling" description:"enable log ingest cpu profiling"`
+	IngestFollow     bool            `long:"ingest-follow" description:"after ingesting, keep following log files for new lines; stream logs from a cluster using: aerolab agi follow"`
+	FollowPaths      []string        `long:"ingest-follow-path" description:"glob pattern of log files on the instance to follow; can be specified multiple times; default: logs streamed by aerolab agi follow"`
 	PluginCpuProfile bool            `long:"plugin-cpu-profiling" description:"enable CPU profiling for the grafana plugin"`
 	PluginLogLevel   int             `long:"plugin-log-level" description:"1-CRITICAL,2-ERROR,3-WARN,4-INFO,5-DEBUG,6-DETAIL" default:"4"`
 	NoConfigOverride bool            `long:"no-config-override" description:"if set, existing configuration will not be overridden; useful when restarting EFS-based AGIs"`
@@ -130,9 +160,12 @@ func (c *agiCreateCmd) Execute(args []string) error {
 	for _, fn := range []string{
 		string(c.LocalSource),
 		string(c.SftpKey),
+		string(c.GcsCredentials),
 		string(c.ProxyCert),
 		string(c.ProxyKey),
 		string(c.PatternsFile),
+		string(c.FindingsFile),
+		string(c.AnonymizeFile),
 	} {
 		if fn == "" {
 			continue
@@ -339,6 +372,14 @@ func (c *agiCreateCmd) Execute(args []string) error {
 		}
 	}
 
+	// pull and upload cluster source logs
+	if c.ClusterSource != "" {
+		err = c.clusterSource().pull(c.ClusterName.String())
+		if err != nil {
+			return fmt.Errorf("failed to get logs from cluster source: %s", err)
+		}
+	}
+
 	// we need to know if node is arm
 	isArm, err := b.IsNodeArm(c.ClusterName.String(), 1)
 	if err != nil {
@@ -390,6 +431,42 @@ func (c *agiCreateCmd) Execute(args []string) error {
 		})
 	}
 
+	// upload custom findings rules file
+	if c.FindingsFile != "" {
+		stat, err := os.Stat(string(c.FindingsFile))
+		if err != nil {
+			return fmt.Errorf("could not access findings file: %s", err)
+		}
+		f, err := os.Open(string(c.FindingsFile))
+		if err != nil {
+			return fmt.Errorf("failed to open findings file: %s", err)
+		}
+		defer f.Close()
+		flist = append(flist, fileListReader{
+			filePath:     "/opt/agi/findings.yaml",
+			fileContents: f,
+			fileSize:     int(stat.Size()),
+		})
+	}
+
+	// upload custom anonymize rules file
+	if c.AnonymizeFile != "" {
+		stat, err := os.Stat(string(c.AnonymizeFile))
+		if err != nil {
+			return fmt.Errorf("could not access anonymize rules file: %s", err)
+		}
+		f, err := os.Open(string(c.AnonymizeFile))
+		if err != nil {
+			return fmt.Errorf("failed to open anonymize rules file: %s", err)
+		}
+		defer f.Close()
+		flist = append(flist, fileListReader{
+			filePath:     "/opt/agi/anonymize.yaml",
+			fileContents: f,
+			fileSize:     int(stat.Size()),
+		})
+	}
+
 	// upload proxy key
 	if c.ProxyKey != "" {
 		stat, err := os.Stat(string(c.ProxyKey))
@@ -444,6 +521,24 @@ func (c *agiCreateCmd) Execute(args []string) error {
 		})
 	}
 
+	// upload gcs credentials
+	if c.GcsCredentials != "" {
+		stat, err := os.Stat(string(c.GcsCredentials))
+		if err != nil {
+			return fmt.Errorf("could not access gcs credentials file: %s", err)
+		}
+		f, err := os.Open(string(c.GcsCredentials))
+		if err != nil {
+			return fmt.Errorf("failed to open gcs credentials file: %s", err)
+		}
+		defer f.Close()
+		flist = append(flist, fileListReader{
+			filePath:     "/opt/agi/gcs.json",
+			fileContents: f,
+			fileSize:     int(stat.Size()),
+		})
+	}
+
 	// generate ingest.yaml
 	config, err := ingest.MakeConfigReader(true, nil, true)
 	if err != nil {
@@ -468,6 +563,15 @@ func (c *agiCreateCmd) Execute(args []string) error {
 	if c.PatternsFile != "" {
 		config.PatternsFile = "/opt/agi/patterns.yaml"
 	}
+	if c.FindingsFile != "" {
+		config.Findings.RulesFile = "/opt/agi/findings.yaml"
+	}
+	config.Findings.OutputFile = agiFindingsFile
+	config.Anonymize.Enabled = c.Anonymize || c.AnonymizeFile != ""
+	if c.AnonymizeFile != "" {
+		config.Anonymize.RulesFile = "/opt/agi/anonymize.yaml"
+	}
+	config.Anonymize.NamespaceFile = "/opt/agi/ingest/anonymize-namespace"
 	config.Directories.CollectInfo = "/opt/agi/files/collectinfo"
 	config.Directories.DirtyTmp = "/opt/agi/files/input"
 	config.Directories.Logs = "/opt/agi/files/logs"
@@ -478,6 +582,11 @@ func (c *agiCreateCmd) Execute(args []string) error {
 		config.CPUProfilingOutputFile = "/opt/agi/cpu.ingest.pprof"
 	}
 	config.CustomSourceName = c.CustomSourceName
+	config.Follow.Enabled = c.IngestFollow
+	config.Follow.Paths = c.FollowPaths
+	if len(config.Follow.Paths) == 0 {
+		config.Follow.Paths = []string{agiFollowPaths}
+	}
 	config.IngestTimeRanges.Enabled = c.TimeRanges
 	if c.TimeRanges {
 		config.IngestTimeRanges.From = tfrom
@@ -506,6 +615,39 @@ func (c *agiCreateCmd) Execute(args []string) error {
 	config.Downloader.S3Source.SecretKey = c.S3Secret
 	config.Downloader.S3Source.PathPrefix = c.S3path
 	config.Downloader.S3Source.SearchRegex = c.S3Regex
+	// sources - gcs
+	config.Downloader.GcsSource.Enabled = c.GcsEnable
+	config.Downloader.GcsSource.Threads = c.GcsThreads
+	config.Downloader.GcsSource.BucketName = c.GcsBucket
+	if c.GcsCredentials != "" {
+		config.Downloader.GcsSource.CredentialsFile = "/opt/agi/gcs.json"
+	}
+	config.Downloader.GcsSource.PathPrefix = c.GcsPath
+	config.Downloader.GcsSource.SearchRegex = c.GcsRegex
+	// sources - azure
+	config.Downloader.AzureSource.Enabled = c.AzureEnable
+	config.Downloader.AzureSource.Threads = c.AzureThreads
+	config.Downloader.AzureSource.AccountName = c.AzureAccount
+	config.Downloader.AzureSource.ContainerName = c.AzureContainer
+	config.Downloader.AzureSource.SasToken = c.AzureSasToken
+	config.Downloader.AzureSource.PathPrefix = c.AzurePath
+	config.Downloader.AzureSource.SearchRegex = c.AzureRegex
+	// sources - http
+	config.Downloader.HttpSource.Enabled = c.HttpEnable
+	config.Downloader.HttpSource.Threads = c.HttpThreads
+	config.Downloader.HttpSource.URLs = c.HttpUrls
+	config.Downloader.HttpSource.Username = c.HttpUser
+	config.Downloader.HttpSource.Password = c.HttpPass
+	config.Downloader.HttpSource.SearchRegex = c.HttpRegex
+	// sources - cluster
+	config.Downloader.ClusterSource.Enabled = c.ClusterSource != ""
+	config.Downloader.ClusterSource.ClusterName = c.ClusterSource
+	config.Downloader.ClusterSource.Nodes = c.ClusterNodes.String()
+	config.Downloader.ClusterSource.PathPrefix = c.ClusterPath
+	config.Downloader.ClusterSource.SearchRegex = c.ClusterRegex
+	if c.ClusterJournal {
+		config.Downloader.ClusterSource.PathPrefix = "journald"
+	}
 	var encBuf bytes.Buffer
 	enc := yaml.NewEncoder(&encBuf)
 	enc.SetIndent(2)
@@ -559,7 +701,7 @@ func (c *agiCreateCmd) Execute(args []string) error {
 	if c.NoConfigOverride {
 		override = "0"
 	}
-	if a.opts.Config.Backend.Type == "docker" {
+	if a.opts.Config.Backend.Type == "docker" || a.opts.Config.Backend.Type == "kubernetes" {
 		installScript = fmt.Sprintf(agiCreateScriptDocker, override, c.NoDIM, c.Owner, edition, edition, memSize/1024/1024/1024, memSize/1024/1024/1024, !c.NoDIM, c.NoDIM, c.ClusterName, c.ClusterName, c.AGILabel, proxyPort, proxySSL, proxyCert, proxyKey, proxyMaxInactive, proxyMaxUptime, maxDp, c.PluginLogLevel, cpuProfiling, notifierYaml)
 	} else {
 		installScript = fmt.Sprintf(agiCreateScript, override, c.NoDIM, c.Owner, edition, edition, memSize/1024/1024/1024, memSize/1024/1024/1024, !c.NoDIM, c.NoDIM, c.ClusterName, c.ClusterName, c.AGILabel, proxyPort, proxySSL, proxyCert, proxyKey, proxyMaxInactive, proxyMaxUptime, maxDp, c.PluginLogLevel, cpuProfiling, notifierYaml)
@@ -575,7 +717,8 @@ func (c *agiCreateCmd) Execute(args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to run install script: %s\n%s", err, string(out[0]))
 	}
-	if a.opts.Config.Backend.Type == "docker" {
+	if a.opts.Config.Backend.Type == "docker" || a.opts.Config.Backend.Type == "kubernetes" {
+		// restart the node so that the services are started by their autoload scripts
 		a.opts.Cluster.Stop.ClusterName = c.ClusterName
 		a.opts.Cluster.Stop.Nodes = "1"
 		err = a.opts.Cluster.Stop.Execute(nil)
@@ -598,6 +741,17 @@ func (c *agiCreateCmd) Execute(args []string) error {
 	return nil
 }
 
+func (c *agiCreateCmd) clusterSource() *agiClusterSource {
+	return &agiClusterSource{
+		ClusterName: c.ClusterSource,
+		Nodes:       c.ClusterNodes,
+		Path:        c.ClusterPath,
+		Regex:       c.ClusterRegex,
+		Journal:     c.ClusterJournal,
+		Threads:     c.ClusterThreads,
+	}
+}
+
 //go:embed cmdAgiCreate.script.cloud.sh
 var agiCreateScript string
 
@@ -87,6 +87,7 @@ dashboards:
   loadEmbedded: true
 grafanaURL: "http://127.0.0.1:8850"
 annotationFile: "/opt/agi/annotations.json"
+findingsFile: "/opt/agi/findings.json"
 EOF
 fi
 
@@ -1,17 +1,24 @@
 package main
 
 import (
+	"bytes"
 	"compress/gzip"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/aerospike/aerolab/grafanafix"
@@ -29,6 +36,9 @@ type agiExecCmd struct {
 	Proxy        agiExecProxyCmd        `command:"proxy" subcommands-optional:"true" description:"Proxy from aerolab to AGI services"`
 	IngestStatus agiExecIngestStatusCmd `command:"ingest-status" subcommands-optional:"true" description:"Ingest logs into aerospike"`
 	IngestDetail agiExecIngestDetailCmd `command:"ingest-detail" subcommands-optional:"true" description:"Ingest logs into aerospike"`
+	PluginQuery  agiExecPluginQueryCmd  `command:"plugin-query" subcommands-optional:"true" description:"Run a grafana query against the local plugin"`
+	FollowAppend agiExecFollowAppendCmd `command:"follow-append" subcommands-optional:"true" description:"Append streamed logs to a followed file"`
+	Findings     agiExecFindingsCmd     `command:"findings" subcommands-optional:"true" description:"Print the findings report"`
 	Help         helpCmd                `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
@@ -138,6 +148,37 @@ func (c *agiExecPluginCmd) Execute(args []string) error {
 	return err
 }
 
+type agiExecPluginQueryCmd struct {
+	YamlFile string  `short:"y" long:"yaml" description:"Plugin yaml config file" default:"/opt/agi/plugin.yaml"`
+	Payload  string  `short:"p" long:"payload" description:"base64-encoded query payload"`
+	Help     helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *agiExecPluginQueryCmd) Execute(args []string) error {
+	if earlyProcessNoBackend(args) {
+		return nil
+	}
+	payload, err := base64.StdEncoding.DecodeString(c.Payload)
+	if err != nil {
+		return fmt.Errorf("could not decode payload: %s", err)
+	}
+	conf, err := plugin.MakeConfig(true, c.YamlFile, true)
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(fmt.Sprintf("http://%s:%d/query", conf.Service.ListenAddress, conf.Service.ListenPort), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("plugin query failed: %s: %s", resp.Status, string(body))
+	}
+	_, err = io.Copy(os.Stdout, resp.Body)
+	return err
+}
+
 type agiExecGrafanaFixCmd struct {
 	YamlFile string  `short:"y" long:"yaml" description:"Yaml config file"`
 	Help     helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
@@ -288,6 +329,7 @@ func (c *agiExecIngestCmd) run(args []string) error {
 	if config.Downloader.SftpSource.Enabled {
 		slacksftpsource = fmt.Sprintf("\n> *SFTP Source*: %s:%s %s", config.Downloader.SftpSource.Host, config.Downloader.SftpSource.PathPrefix, config.Downloader.SftpSource.SearchRegex)
 	}
+	slackothersources := agiSlackOtherSources(config)
 	slackcustomsource := ""
 	if config.CustomSourceName != "" {
 		slackcustomsource = fmt.Sprintf("\n> *Custom Source*: %s", config.CustomSourceName)
@@ -322,7 +364,7 @@ func (c *agiExecIngestCmd) run(args []string) error {
 			return fmt.Errorf("notify: %s", err)
 		}
 		slackagiLabel, _ := os.ReadFile("/opt/agi/label")
-		c.notify.NotifySlack(AgiEventInitComplete, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s", AgiEventInitComplete, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), owner, slacks3source, slacksftpsource, slackcustomsource), slackAccessDetails)
+		c.notify.NotifySlack(AgiEventInitComplete, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s%s", AgiEventInitComplete, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), owner, slacks3source, slacksftpsource, slackothersources, slackcustomsource), slackAccessDetails)
 	}
 	if !steps.Download {
 		err = i.Download()
@@ -351,13 +393,17 @@ func (c *agiExecIngestCmd) run(args []string) error {
 				return fmt.Errorf("notify: %s", err)
 			}
 			slackagiLabel, _ := os.ReadFile("/opt/agi/label")
-			c.notify.NotifySlack(AgiEventDownloadComplete, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s", AgiEventDownloadComplete, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), owner, slacks3source, slacksftpsource, slackcustomsource), slackAccessDetails)
+			c.notify.NotifySlack(AgiEventDownloadComplete, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s%s", AgiEventDownloadComplete, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), owner, slacks3source, slacksftpsource, slackothersources, slackcustomsource), slackAccessDetails)
 		}
 		if c.YamlFile != "" {
 			// rewrite, redacting passwords for sources
 			s3Pw := config.Downloader.S3Source.SecretKey
 			sftpPw := config.Downloader.SftpSource.Password
 			keyFile := config.Downloader.SftpSource.KeyFile
+			gcsFile := config.Downloader.GcsSource.CredentialsFile
+			azureSas := config.Downloader.AzureSource.SasToken
+			httpPw := config.Downloader.HttpSource.Password
+			httpUrls := config.Downloader.HttpSource.URLs
 			if config.Downloader.S3Source.SecretKey != "" {
 				config.Downloader.S3Source.SecretKey = "<redacted>"
 			}
@@ -367,6 +413,16 @@ func (c *agiExecIngestCmd) run(args []string) error {
 			if config.Downloader.SftpSource.KeyFile != "" {
 				config.Downloader.SftpSource.KeyFile = "<redacted>"
 			}
+			if config.Downloader.GcsSource.CredentialsFile != "" {
+				config.Downloader.GcsSource.CredentialsFile = "<redacted>"
+			}
+			if config.Downloader.AzureSource.SasToken != "" {
+				config.Downloader.AzureSource.SasToken = "<redacted>"
+			}
+			if config.Downloader.HttpSource.Password != "" {
+				config.Downloader.HttpSource.Password = "<redacted>"
+			}
+			config.Downloader.HttpSource.URLs = agiRedactUrls(httpUrls)
 			f, err := os.OpenFile(c.YamlFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
 			if err != nil {
 				return err
@@ -381,8 +437,13 @@ func (c *agiExecIngestCmd) run(args []string) error {
 			config.Downloader.S3Source.SecretKey = s3Pw
 			config.Downloader.SftpSource.Password = sftpPw
 			config.Downloader.SftpSource.KeyFile = keyFile
+			config.Downloader.GcsSource.CredentialsFile = gcsFile
+			config.Downloader.AzureSource.SasToken = azureSas
+			config.Downloader.HttpSource.Password = httpPw
+			config.Downloader.HttpSource.URLs = httpUrls
 		}
 		os.Remove("/opt/agi/sftp.key")
+		os.Remove("/opt/agi/gcs.json")
 	}
 	if !steps.Unpack {
 		err = i.Unpack()
@@ -410,7 +471,7 @@ func (c *agiExecIngestCmd) run(args []string) error {
 				return fmt.Errorf("notify: %s", err)
 			}
 			slackagiLabel, _ := os.ReadFile("/opt/agi/label")
-			c.notify.NotifySlack(AgiEventUnpackComplete, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s", AgiEventUnpackComplete, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), owner, slacks3source, slacksftpsource, slackcustomsource), slackAccessDetails)
+			c.notify.NotifySlack(AgiEventUnpackComplete, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s%s", AgiEventUnpackComplete, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), owner, slacks3source, slacksftpsource, slackothersources, slackcustomsource), slackAccessDetails)
 		}
 	}
 	if !steps.PreProcess {
@@ -442,7 +503,21 @@ func (c *agiExecIngestCmd) run(args []string) error {
 				return fmt.Errorf("notify: %s", err)
 			}
 			slackagiLabel, _ := os.ReadFile("/opt/agi/label")
-			c.notify.NotifySlack(AgiEventPreProcessComplete, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s", AgiEventPreProcessComplete, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), owner, slacks3source, slacksftpsource, slackcustomsource), slackAccessDetails)
+			c.notify.NotifySlack(AgiEventPreProcessComplete, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s%s", AgiEventPreProcessComplete, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), owner, slacks3source, slacksftpsource, slackothersources, slackcustomsource), slackAccessDetails)
+		}
+	}
+	if !steps.Anonymize {
+		err = i.Anonymize()
+		if err != nil {
+			return fmt.Errorf("Anonymize: %s", err)
+		}
+		steps.Anonymize = true
+		f, err := json.Marshal(steps)
+		if err == nil {
+			err = os.WriteFile("/opt/agi/ingest/steps.json.new", f, 0644)
+			if err == nil {
+				os.Rename("/opt/agi/ingest/steps.json.new", "/opt/agi/ingest/steps.json")
+			}
 		}
 	}
 	nerr := []error{}
@@ -498,7 +573,7 @@ func (c *agiExecIngestCmd) run(args []string) error {
 				return fmt.Errorf("notify: %s", err)
 			}
 			slackagiLabel, _ := os.ReadFile("/opt/agi/label")
-			c.notify.NotifySlack(AgiEventProcessComplete, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s", AgiEventProcessComplete, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), owner, slacks3source, slacksftpsource, slackcustomsource), slackAccessDetails)
+			c.notify.NotifySlack(AgiEventProcessComplete, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s%s", AgiEventProcessComplete, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), owner, slacks3source, slacksftpsource, slackothersources, slackcustomsource), slackAccessDetails)
 		}
 	}
 	if len(nerr) > 0 {
@@ -511,6 +586,20 @@ func (c *agiExecIngestCmd) run(args []string) error {
 		}
 		return errors.New(errstr)
 	}
+	if !steps.Analyze {
+		err = i.Analyze()
+		if err != nil {
+			return fmt.Errorf("Analyze: %s", err)
+		}
+		steps.Analyze = true
+		f, err = json.Marshal(steps)
+		if err == nil {
+			err = os.WriteFile("/opt/agi/ingest/steps.json.new", f, 0644)
+			if err == nil {
+				os.Rename("/opt/agi/ingest/steps.json.new", "/opt/agi/ingest/steps.json")
+			}
+		}
+	}
 	notifyData, err = getAgiStatus("/opt/agi/ingest/")
 	if err == nil {
 		notifyItem := &ingest.NotifyEvent{
@@ -524,7 +613,56 @@ func (c *agiExecIngestCmd) run(args []string) error {
 			return fmt.Errorf("notify: %s", err)
 		}
 		slackagiLabel, _ := os.ReadFile("/opt/agi/label")
-		c.notify.NotifySlack(AgiEventIngestFinish, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s", AgiEventIngestFinish, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), owner, slacks3source, slacksftpsource, slackcustomsource), slackAccessDetails)
+		c.notify.NotifySlack(AgiEventIngestFinish, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s%s", AgiEventIngestFinish, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), owner, slacks3source, slacksftpsource, slackothersources, slackcustomsource), slackAccessDetails)
+	}
+	if config.Follow.Enabled {
+		// keep ingesting new log lines until the service is stopped
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err = i.Follow(ctx)
+		i.Close()
+		if err != nil {
+			return fmt.Errorf("Follow: %s", err)
+		}
 	}
 	return nil
 }
+
+// agiSlackOtherSources returns the slack notification lines for the gcs, azure, http and cluster sources
+func agiSlackOtherSources(config *ingest.Config) string {
+	sources := ""
+	if config.Downloader.GcsSource != nil && config.Downloader.GcsSource.Enabled {
+		sources += fmt.Sprintf("\n> *GCS Source*: %s:%s %s", config.Downloader.GcsSource.BucketName, config.Downloader.GcsSource.PathPrefix, config.Downloader.GcsSource.SearchRegex)
+	}
+	if config.Downloader.AzureSource != nil && config.Downloader.AzureSource.Enabled {
+		sources += fmt.Sprintf("\n> *Azure Source*: %s/%s:%s %s", config.Downloader.AzureSource.AccountName, config.Downloader.AzureSource.ContainerName, config.Downloader.AzureSource.PathPrefix, config.Downloader.AzureSource.SearchRegex)
+	}
+	if config.Downloader.HttpSource != nil && config.Downloader.HttpSource.Enabled {
+		sources += fmt.Sprintf("\n> *HTTP Source*: %d URLs %s", len(config.Downloader.HttpSource.URLs), config.Downloader.HttpSource.SearchRegex)
+	}
+	if config.Downloader.ClusterSource != nil && config.Downloader.ClusterSource.Enabled {
+		sources += fmt.Sprintf("\n> *Cluster Source*: %s nodes:%s %s %s", config.Downloader.ClusterSource.ClusterName, config.Downloader.ClusterSource.Nodes, config.Downloader.ClusterSource.PathPrefix, config.Downloader.ClusterSource.SearchRegex)
+	}
+	return sources
+}
+
+// agiRedactUrls returns the URLs with user info and query strings redacted, as pre-signed URLs carry their credentials in the query
+func agiRedactUrls(urls []string) []string {
+	redacted := []string{}
+	for _, rawUrl := range urls {
+		u, err := url.Parse(rawUrl)
+		if err != nil {
+			redacted = append(redacted, "<redacted>")
+			continue
+		}
+		if u.User != nil {
+			u.User = url.User("redacted")
+		}
+		if u.RawQuery != "" {
+			u.RawQuery = "<redacted>"
+		}
+		u.Fragment = ""
+		redacted = append(redacted, u.String())
+	}
+	return redacted
+}
@@ -7,9 +7,9 @@ import (
 	"crypto/subtle"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"log"
 	"net/http"
 	"net/http/httputil"
@@ -28,7 +28,6 @@ import (
 	"github.com/aerospike/aerolab/notifier"
 	"github.com/bestmethod/inslice"
 	"github.com/bestmethod/logger"
-	"github.com/fsnotify/fsnotify"
 	ps "github.com/mitchellh/go-ps"
 	"gopkg.in/yaml.v3"
 )
@@ -61,6 +60,8 @@ type agiExecProxyCmd struct {
 	ttydProxy            *httputil.ReverseProxy
 	fbUrl                *url.URL
 	fbProxy              *httputil.ReverseProxy
+	pluginUrl            *url.URL
+	pluginProxy          *httputil.ReverseProxy
 	gottyConns           *counter
 	srv                  *http.Server
 	tokens               *tokens
@@ -69,6 +70,7 @@ type agiExecProxyCmd struct {
 	shuttingDownMutex    *sync.Mutex
 	slacks3source        string
 	slacksftpsource      string
+	slackothersources    string
 	slackcustomsource    string
 	owner                string
 	slackAccessDetails   string
@@ -80,75 +82,30 @@ type tokens struct {
 	tokens []string
 }
 
-func (c *agiExecProxyCmd) loadTokensDo() {
-	tokens := []string{}
-	err := filepath.Walk(c.TokenAuthLocation, func(fpath string, info fs.FileInfo, err error) error {
-		if err != nil {
-			logger.Error("error on walk %s: %s", fpath, err)
-			return nil
-		}
-		if info.IsDir() {
-			return nil
-		}
-		token, err := os.ReadFile(fpath)
-		if err != nil {
-			logger.Error("could not read token file %s: %s", fpath, err)
-			return nil
-		}
-		if len(token) < 64 {
-			logger.Error("Token file %s contents too short, minimum token length is 64 characters", fpath)
-			return nil
-		}
-		tokens = append(tokens, string(token))
-		return nil
-	})
-	if err != nil {
-		logger.Error("failed to read tokens: %s", err)
-		return
-	}
-	c.tokens.Lock()
-	c.tokens.tokens = tokens
-	c.tokens.Unlock()
-}
-
-func (c *agiExecProxyCmd) loadTokensInterval() {
-	for {
-		c.loadTokensDo()
-		time.Sleep(time.Minute)
-	}
-}
-
+// loadTokens monitors the token directory, if token auth is in use
 func (c *agiExecProxyCmd) loadTokens() {
 	if c.AuthType != "token" {
 		return
 	}
 	os.MkdirAll(c.TokenAuthLocation, 0755)
-	go c.loadTokensInterval()
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		logger.Error("fsnotify could not be started, tokens will not be dynamically monitored; switching to once-a-minute system: %s", err)
-		return
-	}
-	defer watcher.Close()
-	err = watcher.Add(c.TokenAuthLocation)
-	if err != nil {
-		logger.Error("fsnotify could not add token path, tokens will not be dynamically monitored; switching to once-a-minute system: %s", err)
-		return
-	}
-	for {
-		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				logger.Error("fsnotify events error, tokens will not be dynamically monitored; switching to once-a-minute system")
-				return
+	t := &tokenDir{
+		path: c.TokenAuthLocation,
+		logf: logger.Error,
+		reload: func(files []tokenFile) {
+			tokens := []string{}
+			for _, f := range files {
+				if len(f.contents) < 64 {
+					logger.Error("Token file %s contents too short, minimum token length is 64 characters", f.path)
+					continue
+				}
+				tokens = append(tokens, string(f.contents))
 			}
-			logger.Detail("fsnotify event:", event)
-			c.loadTokensDo()
-		case err, ok := <-watcher.Errors:
-			logger.Error("fsnotify watcher error, tokens will not be dynamically monitored; switching to once-a-minute system (ok:%t err:%s)", ok, err)
-			return
-		}
+			c.tokens.Lock()
+			c.tokens.tokens = tokens
+			c.tokens.Unlock()
+		},
 	}
+	t.watch()
 }
 
 type counter struct {
@@ -232,6 +189,10 @@ func (c *agiExecProxyCmd) Execute(args []string) error {
 	fproxy := httputil.NewSingleHostReverseProxy(furl)
 	c.fbUrl = furl
 	c.fbProxy = fproxy
+	purl, _ := url.Parse("http://127.0.0.1:8851/")
+	pproxy := httputil.NewSingleHostReverseProxy(purl)
+	c.pluginUrl = purl
+	c.pluginProxy = pproxy
 	c.tokens = new(tokens)
 	if c.AuthType == "basic" {
 		c.isBasicAuth = true
@@ -263,6 +224,7 @@ func (c *agiExecProxyCmd) Execute(args []string) error {
 				if ingestConfig.Downloader.SftpSource.Enabled {
 					c.slacksftpsource = fmt.Sprintf("\n> *SFTP Source*: %s:%s %s", ingestConfig.Downloader.SftpSource.Host, ingestConfig.Downloader.SftpSource.PathPrefix, ingestConfig.Downloader.SftpSource.SearchRegex)
 				}
+				c.slackothersources = agiSlackOtherSources(ingestConfig)
 				if ingestConfig.CustomSourceName != "" {
 					c.slackcustomsource = fmt.Sprintf("\n> *Custom Source*: %s", ingestConfig.CustomSourceName)
 				}
@@ -289,6 +251,8 @@ func (c *agiExecProxyCmd) Execute(args []string) error {
 	http.HandleFunc("/agi/poweroff", c.handlePoweroff)          // poweroff the instance
 	http.HandleFunc("/agi/status", c.handleStatus)              // high-level agi service status
 	http.HandleFunc("/agi/ingest/detail", c.handleIngestDetail) // detailed logingest progress json; form: ?detail=[]string{"downloader.json", "unpacker.json", "pre-processor.json", "log-processor.json", "cf-processor.json"}
+	http.HandleFunc("/agi/openmetrics", c.openMetricsHandler)   // export ingested timeseries in openmetrics/prometheus text format; form: ?set=&bin=&from=&to=&format=openmetrics|prometheus
+	http.HandleFunc("/agi/findings", c.handleFindings)          // findings report json of the ingest analysis; form: ?severity=critical|warning|info
 	http.HandleFunc("/", c.grafanaHandler)                      // grafana
 	c.srv = &http.Server{Addr: "0.0.0.0:" + strconv.Itoa(c.ListenPort)}
 	if c.HTTPS {
@@ -322,6 +286,7 @@ func (c *agiExecProxyCmd) handleList(w http.ResponseWriter, r *http.Request) {
 	<a href="/d/dashList/dashboard-list?from=now-7d&to=now&var-MaxIntervalSeconds=30&var-ProduceDelta&var-ClusterName=All&var-NodeIdent=All&var-Namespace=All&var-Histogram=NONE&var-HistogramDev=NONE&var-HistogramUs=NONE&var-HistogramCount=NONE&var-HistogramSize=NONE&var-XdrDcName=All&var-xdr5dc=All&var-warnC=All&var-warnCtx=All&var-errC=All&var-errCtx=All&orgId=1" target="_blank"><h1>Grafana</h1></a>
 	<a href="/agi/ttyd" target="_blank"><h1>Web Console (ttyd)</h1></a>
 	<a href="/agi/filebrowser" target="_blank"><h1>File Browser</h1></a>
+	<a href="/agi/findings" target="_blank"><h1>Findings (json)</h1></a>
 	</center></body></html>`)
 	w.Write(out)
 }
@@ -389,6 +354,31 @@ func (c *agiExecProxyCmd) handleStatus(w http.ResponseWriter, r *http.Request) {
 	enc.Encode(resp)
 }
 
+// form: ?severity=critical|warning|info
+func (c *agiExecProxyCmd) handleFindings(w http.ResponseWriter, r *http.Request) {
+	if !c.checkAuth(w, r) {
+		return
+	}
+	severity := r.FormValue("severity")
+	if severity != "" && ingest.FindingSeverityRank(severity) < 0 {
+		http.Error(w, "invalid severity", http.StatusBadRequest)
+		return
+	}
+	resp, err := getAgiFindings(agiFindingsFile, severity)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	enc.Encode(resp)
+}
+
 func getAgiStatus(ingestProgressPath string) (*ingest.IngestStatusStruct, error) {
 	status := new(ingest.IngestStatusStruct)
 	plist, err := ps.Processes()
@@ -483,29 +473,18 @@ func getAgiStatus(ingestProgressPath string) (*ingest.IngestStatusStruct, error)
 		json.NewDecoder(reader).Decode(p)
 		totalSize := int64(0)
 		dlSize := int64(0)
-		for fn, f := range p.S3Files {
-			if f.Error != "" {
-				status.Ingest.Errors = append(status.Ingest.Errors, fn+"::"+f.Error)
-			}
-			totalSize += f.Size
-			if f.IsDownloaded {
-				dlSize += f.Size
-			} else {
-				if nstat, err := os.Stat(path.Join("/opt/agi/files/input/s3source", fn)); err == nil {
-					dlSize += nstat.Size()
+		for source, files := range p.Sources() {
+			for fn, f := range files {
+				if f.Error != "" {
+					status.Ingest.Errors = append(status.Ingest.Errors, fn+"::"+f.Error)
 				}
-			}
-		}
-		for fn, f := range p.SftpFiles {
-			if f.Error != "" {
-				status.Ingest.Errors = append(status.Ingest.Errors, fn+"::"+f.Error)
-			}
-			totalSize += f.Size
-			if f.IsDownloaded {
-				dlSize += f.Size
-			} else {
-				if nstat, err := os.Stat(path.Join("/opt/agi/files/input/sftpsource", fn)); err == nil {
-					dlSize += nstat.Size()
+				totalSize += f.Size
+				if f.IsDownloaded {
+					dlSize += f.Size
+				} else {
+					if nstat, err := os.Stat(path.Join("/opt/agi/files/input", source, fn)); err == nil {
+						dlSize += nstat.Size()
+					}
 				}
 			}
 		}
@@ -618,7 +597,7 @@ func (c *agiExecProxyCmd) maxUptime() {
 			}
 			c.notify.NotifyJSON(notifyItem)
 			slackagiLabel, _ := os.ReadFile("/opt/agi/label")
-			c.notify.NotifySlack(AgiEventMaxAge, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s\n> *Max age reached, shutting down*", AgiEventMaxAge, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), c.owner, c.slacks3source, c.slacksftpsource, c.slackcustomsource), c.slackAccessDetails)
+			c.notify.NotifySlack(AgiEventMaxAge, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s%s\n> *Max age reached, shutting down*", AgiEventMaxAge, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), c.owner, c.slacks3source, c.slacksftpsource, c.slackothersources, c.slackcustomsource), c.slackAccessDetails)
 		}
 	}()
 	time.Sleep(time.Minute)
@@ -686,7 +665,7 @@ func (c *agiExecProxyCmd) spotMonitor() {
 		}
 		c.notify.NotifyJSON(notifyItem)
 		slackagiLabel, _ := os.ReadFile("/opt/agi/label")
-		c.notify.NotifySlack(AgiEventSpotNoCapacity, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s\n> *AWS Shutting spot instance down due to capacity restrictions*", AgiEventSpotNoCapacity, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), c.owner, c.slacks3source, c.slacksftpsource, c.slackcustomsource), c.slackAccessDetails)
+		c.notify.NotifySlack(AgiEventSpotNoCapacity, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s%s\n> *AWS Shutting spot instance down due to capacity restrictions*", AgiEventSpotNoCapacity, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), c.owner, c.slacks3source, c.slacksftpsource, c.slackothersources, c.slackcustomsource), c.slackAccessDetails)
 		time.Sleep(2 * time.Minute)
 		c.shuttingDownMutex.Lock()
 		c.shuttingDown = false
@@ -728,7 +707,7 @@ func (c *agiExecProxyCmd) serviceMonitor() {
 			}
 			c.notify.NotifyJSON(notifyItem)
 			slackagiLabel, _ := os.ReadFile("/opt/agi/label")
-			c.notify.NotifySlack(AgiEventServiceDown, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s\n> *A required service has quit unexpectedly, check: aerolab agi status*", AgiEventServiceDown, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), c.owner, c.slacks3source, c.slacksftpsource, c.slackcustomsource), c.slackAccessDetails)
+			c.notify.NotifySlack(AgiEventServiceDown, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s%s\n> *A required service has quit unexpectedly, check: aerolab agi status*", AgiEventServiceDown, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), c.owner, c.slacks3source, c.slacksftpsource, c.slackothersources, c.slackcustomsource), c.slackAccessDetails)
 		} else if notifyUp {
 			notifyItem := &ingest.NotifyEvent{
 				IsDataInMemory: c.isDim,
@@ -738,7 +717,7 @@ func (c *agiExecProxyCmd) serviceMonitor() {
 			}
 			c.notify.NotifyJSON(notifyItem)
 			slackagiLabel, _ := os.ReadFile("/opt/agi/label")
-			c.notify.NotifySlack(AgiEventServiceUp, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s\n> *A required service has started back up, check: aerolab agi status*", AgiEventServiceUp, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), c.owner, c.slacks3source, c.slacksftpsource, c.slackcustomsource), c.slackAccessDetails)
+			c.notify.NotifySlack(AgiEventServiceUp, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s%s\n> *A required service has started back up, check: aerolab agi status*", AgiEventServiceUp, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), c.owner, c.slacks3source, c.slacksftpsource, c.slackothersources, c.slackcustomsource), c.slackAccessDetails)
 		}
 	}
 }
@@ -792,7 +771,7 @@ func (c *agiExecProxyCmd) activityMonitor() {
 					}
 					c.notify.NotifyJSON(notifyItem)
 					slackagiLabel, _ := os.ReadFile("/opt/agi/label")
-					c.notify.NotifySlack(AgiEventMaxInactive, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s\n> *Max inactivity reached, shutting instance down*", AgiEventMaxInactive, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), c.owner, c.slacks3source, c.slacksftpsource, c.slackcustomsource), c.slackAccessDetails)
+					c.notify.NotifySlack(AgiEventMaxInactive, fmt.Sprintf("*%s* _@ %s_\n> *AGI Name*: %s\n> *AGI Label*: %s\n> *Owner*: %s%s%s%s%s\n> *Max inactivity reached, shutting instance down*", AgiEventMaxInactive, time.Now().Format(time.RFC822), c.AGIName, string(slackagiLabel), c.owner, c.slacks3source, c.slacksftpsource, c.slackothersources, c.slackcustomsource), c.slackAccessDetails)
 				}
 			}()
 			time.Sleep(time.Minute)
@@ -911,6 +890,20 @@ func (c *agiExecProxyCmd) fbHandler(w http.ResponseWriter, r *http.Request) {
 	c.fbProxy.ServeHTTP(w, r)
 }
 
+func (c *agiExecProxyCmd) openMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	// auth check
+	if !c.checkAuth(w, r) {
+		return
+	}
+	// reverse proxy to the plugin export endpoint
+	r.URL.Host = c.pluginUrl.Host
+	r.URL.Scheme = c.pluginUrl.Scheme
+	r.URL.Path = "/openmetrics"
+	r.Header.Set("X-Forwarded-Host", r.Header.Get("Host"))
+	r.Host = c.pluginUrl.Host
+	c.pluginProxy.ServeHTTP(w, r)
+}
+
 func (c *agiExecProxyCmd) getDeps() {
 	go func() {
 		logger.Info("Getting ttyd...")
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aerospike/aerolab/ingest"
+)
+
+// location of the findings report on the AGI instance, written by the ingest analysis stage
+const agiFindingsFile = "/opt/agi/findings.json"
+
+type agiFindingsCmd struct {
+	ClusterName  TypeClusterName `short:"n" long:"name" description:"AGI name" default:"agi"`
+	Severity     string          `short:"s" long:"severity" description:"minimum severity to list; critical|warning|info" default:"info"`
+	Rules        []string        `short:"r" long:"rule" description:"only list findings of the given rule; can be specified multiple times"`
+	Csv          bool            `short:"c" long:"csv" description:"print as csv instead of a table"`
+	Json         bool            `short:"j" long:"json" description:"print the findings report as json"`
+	DefaultRules bool            `long:"default-rules" description:"print the built-in findings rules file, as a starting point for custom rules, and exit"`
+	Help         helpCmd         `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *agiFindingsCmd) Execute(args []string) error {
+	if c.DefaultRules {
+		if earlyProcessV2(args, false) {
+			return nil
+		}
+		_, err := os.Stdout.Write(ingest.DefaultFindingRules())
+		return err
+	}
+	if earlyProcess(args) {
+		return nil
+	}
+	if ingest.FindingSeverityRank(c.Severity) < 0 {
+		return fmt.Errorf("severity %s not supported, supported: %s", c.Severity, strings.Join(ingest.FindingSeverities, "|"))
+	}
+	out, err := b.RunCommands(c.ClusterName.String(), [][]string{{"aerolab", "agi", "exec", "findings", "-s", c.Severity}}, []int{1})
+	if err != nil {
+		if len(out) > 0 {
+			return fmt.Errorf("%s : %s", err, string(out[0]))
+		}
+		return err
+	}
+	if len(out) == 0 {
+		return errors.New("no findings report returned")
+	}
+	report := new(ingest.FindingsReport)
+	err = json.Unmarshal(out[0], report)
+	if err != nil {
+		return fmt.Errorf("failed to decode findings report: %s", err)
+	}
+	report.Findings = agiFindingsFilter(report.Findings, c.Severity, c.Rules)
+	return c.write(os.Stdout, report)
+}
+
+func (c *agiFindingsCmd) write(w io.Writer, report *ingest.FindingsReport) error {
+	if c.Json {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(report)
+	}
+	for _, e := range report.Errors {
+		fmt.Fprintf(os.Stderr, "WARNING: rule not evaluated: %s\n", e)
+	}
+	if len(report.Findings) == 0 && !c.Csv {
+		_, err := fmt.Fprintln(w, "No findings")
+		return err
+	}
+	header := []string{"Start", "End", "Severity", "Rule", "Cluster", "Node", "Count", "Message"}
+	rows := [][]string{}
+	for _, f := range report.Findings {
+		rows = append(rows, []string{
+			f.Start.UTC().Format("2006-01-02T15:04:05Z07:00"),
+			f.End.UTC().Format("2006-01-02T15:04:05Z07:00"),
+			strings.ToUpper(f.Severity),
+			f.Rule,
+			f.ClusterName,
+			f.NodeIdent,
+			strconv.Itoa(f.Count),
+			f.Message,
+		})
+	}
+	return agiQueryWrite(w, header, rows, c.Csv)
+}
+
+// agiFindingsFilter returns the findings of at least the given severity, optionally only of the given rules
+func agiFindingsFilter(findings []*ingest.Finding, severity string, rules []string) []*ingest.Finding {
+	minRank := ingest.FindingSeverityRank(severity)
+	if minRank < 0 {
+		minRank = len(ingest.FindingSeverities) - 1
+	}
+	filtered := []*ingest.Finding{}
+	for _, f := range findings {
+		rank := ingest.FindingSeverityRank(f.Severity)
+		if rank < 0 || rank > minRank {
+			continue
+		}
+		if len(rules) > 0 {
+			found := false
+			for _, r := range rules {
+				if r == f.Rule {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		filtered = append(filtered, f)
+	}
+	return filtered
+}
+
+// getAgiFindings reads the findings report, keeping the findings of at least the given severity
+func getAgiFindings(fileName string, severity string) (*ingest.FindingsReport, error) {
+	if severity != "" && ingest.FindingSeverityRank(severity) < 0 {
+		return nil, fmt.Errorf("severity %s not supported, supported: %s", severity, strings.Join(ingest.FindingSeverities, "|"))
+	}
+	f, err := os.ReadFile(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("findings report not available yet, it is generated once log processing completes: %w", err)
+		}
+		return nil, err
+	}
+	report := new(ingest.FindingsReport)
+	err = json.Unmarshal(f, report)
+	if err != nil {
+		return nil, err
+	}
+	report.Findings = agiFindingsFilter(report.Findings, severity, nil)
+	return report, nil
+}
+
+type agiExecFindingsCmd struct {
+	FindingsFile string  `long:"findings-file" default:"/opt/agi/findings.json"`
+	Severity     string  `short:"s" long:"severity" description:"minimum severity; critical|warning|info" default:"info"`
+	Help         helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *agiExecFindingsCmd) Execute(args []string) error {
+	if earlyProcessNoBackend(args) {
+		return nil
+	}
+	resp, err := getAgiFindings(c.FindingsFile, c.Severity)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(resp)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aerospike/aerolab/ingest"
+)
+
+func TestAgiFindings(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "findings.json")
+	if _, err := getAgiFindings(fn, "info"); err == nil || !strings.Contains(err.Error(), "not available yet") {
+		t.Fatalf("expected not available error, got %v", err)
+	}
+	err := os.WriteFile(fn, []byte(`{"generated":"2023-11-01T12:00:00Z","findings":[
+		{"rule":"migrations","severity":"info","message":"test migrations","clusterName":"mydc","nodeIdent":"1_bb8","start":"2023-11-01T10:00:00Z","end":"2023-11-01T10:05:00Z","count":30},
+		{"rule":"stop-writes","severity":"critical","message":"test breached stop-writes","clusterName":"mydc","nodeIdent":"1_bb8","start":"2023-11-01T10:01:00Z","end":"2023-11-01T10:02:00Z","count":6},
+		{"rule":"clock-skew","severity":"warning","message":"cluster clock skew 3000ms","clusterName":"mydc","nodeIdent":"2_bb9","start":"2023-11-01T10:03:00Z","end":"2023-11-01T10:03:00Z","count":1}
+	]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = getAgiFindings(fn, "fatal"); err == nil {
+		t.Error("expected error for unsupported severity")
+	}
+	report, err := getAgiFindings(fn, "warning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Findings) != 2 || report.Findings[0].Rule != "stop-writes" || report.Findings[1].Rule != "clock-skew" || !report.Generated.Equal(time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if f := agiFindingsFilter(report.Findings, "info", []string{"clock-skew"}); len(f) != 1 || f[0].NodeIdent != "2_bb9" {
+		t.Errorf("unexpected rule filter result %+v", f)
+	}
+
+	out := new(bytes.Buffer)
+	c := &agiFindingsCmd{Csv: true}
+	if err = c.write(out, report); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Start,End,Severity,Rule,Cluster,Node,Count,Message\n" +
+		"2023-11-01T10:01:00Z,2023-11-01T10:02:00Z,CRITICAL,stop-writes,mydc,1_bb8,6,test breached stop-writes\n" +
+		"2023-11-01T10:03:00Z,2023-11-01T10:03:00Z,WARNING,clock-skew,mydc,2_bb9,1,cluster clock skew 3000ms\n"
+	if out.String() != expected {
+		t.Errorf("unexpected csv output:\n%s", out.String())
+	}
+	out.Reset()
+	c.Csv = false
+	if err = c.write(out, &ingest.FindingsReport{}); err != nil || strings.TrimSpace(out.String()) != "No findings" {
+		t.Errorf("unexpected empty output %q: %v", out.String(), err)
+	}
+}
+
+func TestMockAgiFindingsNoOutput(t *testing.T) {
+	mockSetup(t)
+	(&restCmd{}).resetBools()
+	// the backend returns no output at all if the instance does not exist
+	err := a.main("aerolab", []string{"agi", "findings", "-n", "missing"})
+	if err == nil {
+		t.Fatal("expected an error for a missing AGI instance")
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aerospike/aerolab/parallelize"
+)
+
+// agiFollowDir is where aerolab agi follow stores the streamed logs on the AGI instance, as <cluster>/node-<N>.log
+const agiFollowDir = "/opt/agi/files/follow"
+
+// agiFollowPaths is the default follow path of the ingest, matching the logs streamed by aerolab agi follow
+const agiFollowPaths = agiFollowDir + "/*/*.log"
+
+// agiFollowMaxChunk caps the size of a single append, as the payload is passed as a command argument
+const agiFollowMaxChunk = 64 * 1024
+
+type agiFollowCmd struct {
+	ClusterName   TypeClusterName `short:"n" long:"name" description:"AGI name" default:"agi"`
+	SourceCluster TypeClusterName `short:"c" long:"source-cluster" description:"aerolab cluster to stream logs from" default:"mydc"`
+	Nodes         TypeNodes       `short:"l" long:"nodes" description:"nodes to stream logs from, comma separated; default: all nodes"`
+	LogLocation   string          `short:"p" long:"path" description:"aerospike log file path on the nodes" default:"/var/log/aerospike.log"`
+	Journal       bool            `short:"j" long:"journal" description:"stream logs from journald instead of the log file"`
+	FlushInterval time.Duration   `short:"i" long:"flush-interval" description:"how often streamed log lines are sent to the AGI instance" default:"5s"`
+	Help          helpCmd         `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *agiFollowCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	if c.FlushInterval < time.Second {
+		return errors.New("flush interval must be at least 1s")
+	}
+	cs := &agiClusterSource{
+		ClusterName: c.SourceCluster.String(),
+		Nodes:       c.Nodes,
+	}
+	nodes, err := cs.nodes()
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return errors.New("found 0 nodes in cluster")
+	}
+	log.Printf("Streaming logs from cluster %s nodes %v to AGI %s, press Ctrl+C to stop", c.SourceCluster, nodes, c.ClusterName)
+	returns := parallelize.MapLimit(nodes, len(nodes), func(node int) error {
+		return c.followNode(node)
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", nodes[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return errors.New("could not stream logs from some nodes")
+	}
+	return nil
+}
+
+// dest returns the path of the streamed log of a node on the AGI instance
+func (c *agiFollowCmd) dest(node int) string {
+	return path.Join(agiFollowDir, c.SourceCluster.String(), "node-"+strconv.Itoa(node)+".log")
+}
+
+// followNode streams the log of a node, appending it to the copy on the AGI instance; the copy is a byte-exact copy of the log file, so its size is the offset to resume from
+func (c *agiFollowCmd) followNode(node int) error {
+	offset, err := c.appendRemote(node, -1, nil)
+	if err != nil {
+		return err
+	}
+	var command []string
+	if c.Journal {
+		command = []string{"journalctl", "-u", "aerospike", "--no-pager", "-f", "-n", "all"}
+		if offset > 0 {
+			// journald entries cannot be matched to the offset, only stream new entries
+			command = []string{"journalctl", "-u", "aerospike", "--no-pager", "-f", "-n", "0"}
+		}
+	} else {
+		start := offset
+		out, err := b.RunCommands(c.SourceCluster.String(), [][]string{{"stat", "-c", "%s", c.LogLocation}}, []int{node})
+		if err != nil {
+			if len(out) > 0 {
+				return fmt.Errorf("could not access %s: %s: %s", c.LogLocation, err, string(out[0]))
+			}
+			return fmt.Errorf("could not access %s: %s", c.LogLocation, err)
+		}
+		if len(out) == 0 {
+			return fmt.Errorf("could not access %s: no output from stat", c.LogLocation)
+		}
+		if size, err := strconv.ParseInt(strings.Trim(string(out[0]), "\r\n\t "), 10, 64); err == nil && size < start {
+			// the log was rotated or truncated since we last streamed it
+			start = 0
+		}
+		command = []string{"tail", "-c", "+" + strconv.FormatInt(start+1, 10), "-F", c.LogLocation}
+	}
+	log.Printf("Node %d: streaming to %s from offset %d", node, c.dest(node), offset)
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(b.RunCustomOut(c.SourceCluster.String(), node, command, nil, pw, io.Discard, false))
+	}()
+	defer pr.Close()
+	lines := make(chan string)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		r := bufio.NewReader(pr)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				readErr <- err
+				close(lines)
+				return
+			}
+			// docker allocates a terminal, which converts line endings
+			line = strings.TrimSuffix(line, "\r\n")
+			select {
+			case lines <- strings.TrimSuffix(line, "\n") + "\n":
+			case <-done:
+				return
+			}
+		}
+	}()
+	buf := new(bytes.Buffer)
+	ticker := time.NewTicker(c.FlushInterval)
+	defer ticker.Stop()
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		offset, err = c.appendRemote(node, offset, buf.Bytes())
+		buf.Reset()
+		return err
+	}
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				if err := flush(); err != nil {
+					return err
+				}
+				err := <-readErr
+				if err == io.EOF {
+					return errors.New("log stream ended")
+				}
+				return fmt.Errorf("log stream ended: %s", err)
+			}
+			if buf.Len()+len(line) > agiFollowMaxChunk {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			buf.WriteString(line)
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// appendRemote appends data to the streamed log of a node if its size on the AGI instance is offset, returning the new size; with nil data, only the current size is returned
+func (c *agiFollowCmd) appendRemote(node int, offset int64, data []byte) (int64, error) {
+	command := []string{"aerolab", "agi", "exec", "follow-append", "-f", c.dest(node)}
+	if data != nil {
+		payload := new(bytes.Buffer)
+		gz := gzip.NewWriter(payload)
+		gz.Write(data)
+		gz.Close()
+		command = append(command, "-o", strconv.FormatInt(offset, 10), "-p", base64.StdEncoding.EncodeToString(payload.Bytes()))
+	}
+	var size int64
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		var out [][]byte
+		out, err = b.RunCommands(c.ClusterName.String(), [][]string{command}, []int{1})
+		if err == nil && len(out) > 0 {
+			return strconv.ParseInt(strings.Trim(string(out[0]), "\r\n\t "), 10, 64)
+		}
+		if err == nil {
+			err = errors.New("no output returned")
+		}
+		if len(out) > 0 {
+			err = fmt.Errorf("%s: %s", err, string(out[0]))
+		}
+		if data == nil {
+			continue
+		}
+		// the append may have been applied even though the command failed; check before retrying
+		sout, serr := b.RunCommands(c.ClusterName.String(), [][]string{{"aerolab", "agi", "exec", "follow-append", "-f", c.dest(node)}}, []int{1})
+		if serr != nil || len(sout) == 0 {
+			continue
+		}
+		size, serr = strconv.ParseInt(strings.Trim(string(sout[0]), "\r\n\t "), 10, 64)
+		if serr != nil {
+			continue
+		}
+		if size == offset+int64(len(data)) {
+			return size, nil
+		}
+		if size != offset {
+			return 0, fmt.Errorf("streamed log %s on AGI has size %d, expected %d; is another aerolab agi follow running?", c.dest(node), size, offset)
+		}
+	}
+	return 0, fmt.Errorf("could not append to %s on AGI: %s", c.dest(node), err)
+}
+
+type agiExecFollowAppendCmd struct {
+	File    string  `short:"f" long:"file" description:"file to append to"`
+	Offset  int64   `short:"o" long:"offset" description:"expected file size; the append fails if the file size differs; -1: do not check" default:"-1"`
+	Payload string  `short:"p" long:"payload" description:"base64-encoded gzipped data to append; if not set, only the file size is printed"`
+	Help    helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *agiExecFollowAppendCmd) Execute(args []string) error {
+	if earlyProcessNoBackend(args) {
+		return nil
+	}
+	if c.File == "" {
+		return errors.New("file is required")
+	}
+	err := os.MkdirAll(path.Dir(c.File), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(c.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := stat.Size()
+	if c.Payload != "" {
+		if c.Offset >= 0 && c.Offset != size {
+			return fmt.Errorf("offset mismatch: file size is %d, expected %d", size, c.Offset)
+		}
+		payload, err := base64.StdEncoding.DecodeString(c.Payload)
+		if err != nil {
+			return fmt.Errorf("could not decode payload: %s", err)
+		}
+		gz, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("could not decompress payload: %s", err)
+		}
+		n, err := io.Copy(f, gz)
+		size += n
+		if err != nil {
+			return fmt.Errorf("could not append to %s: %s", c.File, err)
+		}
+	}
+	fmt.Println(size)
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func agiFollowTestPayload(data string) string {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	gz.Write([]byte(data))
+	gz.Close()
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestAgiExecFollowAppend(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "mydc", "node-1.log")
+	c := &agiExecFollowAppendCmd{File: fn, Offset: 0, Payload: agiFollowTestPayload("line1\n")}
+	if err := c.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	c.Offset = 6
+	c.Payload = agiFollowTestPayload("line2\n")
+	if err := c.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	// a retried append with a stale offset must not duplicate data
+	if err := c.Execute(nil); err == nil {
+		t.Error("expected offset mismatch")
+	}
+	if data, _ := os.ReadFile(fn); string(data) != "line1\nline2\n" {
+		t.Errorf("unexpected content %q", string(data))
+	}
+}
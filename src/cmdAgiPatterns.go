@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aerospike/aerolab/ingest"
+	"github.com/bestmethod/inslice"
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+type agiPatternsCmd struct {
+	Test    agiPatternsTestCmd    `command:"test" subcommands-optional:"true" description:"Test a patterns file against a sample log offline"`
+	Default agiPatternsDefaultCmd `command:"default" subcommands-optional:"true" description:"Print the built-in patterns file, as a starting point for custom patterns"`
+	Help    helpCmd               `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *agiPatternsCmd) Execute(args []string) error {
+	a.parser.WriteHelp(os.Stderr)
+	os.Exit(1)
+	return nil
+}
+
+type agiPatternsDefaultCmd struct {
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *agiPatternsDefaultCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	_, err := os.Stdout.Write(ingest.DefaultPatterns())
+	return err
+}
+
+type agiPatternsTestCmd struct {
+	PatternsFile flags.Filename `short:"p" long:"patterns" description:"patterns yaml file to test; default: the built-in patterns"`
+	LogFile      flags.Filename `short:"f" long:"file" description:"sample log file; use - to read from stdin"`
+	Show         string         `short:"s" long:"show" description:"lines to print; all|matched|unmatched|none" default:"all"`
+	Top          int            `short:"t" long:"top" description:"number of unmatched line groups to list in the summary" default:"20"`
+	Json         bool           `short:"j" long:"json" description:"print each line result and the summary as json, one object per line"`
+	Help         helpCmd        `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *agiPatternsTestCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	return c.run(os.Stdin, os.Stdout)
+}
+
+func (c *agiPatternsTestCmd) run(stdin io.Reader, w io.Writer) error {
+	if c.LogFile == "" {
+		return errors.New("sample log file is required, see: aerolab agi patterns test help")
+	}
+	if !inslice.HasString([]string{"all", "matched", "unmatched", "none"}, c.Show) {
+		return fmt.Errorf("show %s not supported, supported: all|matched|unmatched|none", c.Show)
+	}
+	r := stdin
+	if c.LogFile != "-" {
+		f, err := os.Open(string(c.LogFile))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	enc := json.NewEncoder(w)
+	summary, err := ingest.PatternTest(string(c.PatternsFile), r, func(line *ingest.PatternTestLine) {
+		if !c.show(line) {
+			return
+		}
+		if c.Json {
+			enc.Encode(line)
+			return
+		}
+		agiPatternsWriteLine(w, line)
+	})
+	if err != nil {
+		return err
+	}
+	if c.Top >= 0 && len(summary.Unmatched) > c.Top {
+		summary.Unmatched = summary.Unmatched[:c.Top]
+	}
+	if c.Json {
+		return enc.Encode(summary)
+	}
+	agiPatternsWriteSummary(w, summary)
+	return nil
+}
+
+// show returns true if the line result should be printed
+func (c *agiPatternsTestCmd) show(line *ingest.PatternTestLine) bool {
+	switch c.Show {
+	case "none":
+		return false
+	case "matched":
+		return len(line.Points) > 0 || (line.Result != ingest.PatternTestNotMatched && line.Result != ingest.PatternTestNoTimestamp && line.Result != ingest.PatternTestError)
+	case "unmatched":
+		return line.Result == ingest.PatternTestNotMatched || line.Result == ingest.PatternTestNoTimestamp || line.Result == ingest.PatternTestError
+	}
+	return true
+}
+
+func agiPatternsWriteLine(w io.Writer, line *ingest.PatternTestLine) {
+	if line.Result == ingest.PatternTestEndOfFile {
+		fmt.Fprintf(w, "end of file: flushing %d points\n", len(line.Points))
+	} else {
+		fmt.Fprintf(w, "%d %s: %s\n", line.LineNo, line.Result, line.Line)
+	}
+	if line.Error != "" {
+		fmt.Fprintf(w, "    error: %s\n", line.Error)
+	}
+	for _, p := range line.Points {
+		fmt.Fprintf(w, "    -> set:%s search:%q\n", p.SetName, p.Search)
+		fmt.Fprintf(w, "       regex: %s\n", p.Regex)
+		if p.Line != line.Line {
+			fmt.Fprintf(w, "       line: %s\n", p.Line)
+		}
+		if len(p.Labels) > 0 {
+			fmt.Fprintf(w, "       labels: %s\n", agiPatternsFormatMap(p.Labels))
+		}
+		fmt.Fprintf(w, "       values: %s\n", agiPatternsFormatMap(p.Values))
+	}
+}
+
+func agiPatternsFormatMap(m map[string]interface{}) string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	items := []string{}
+	for _, k := range keys {
+		items = append(items, fmt.Sprintf("%s=%v", k, m[k]))
+	}
+	return strings.Join(items, " ")
+}
+
+func agiPatternsWriteSummary(w io.Writer, summary *ingest.PatternTestSummary) {
+	pct := func(n int) float64 {
+		if summary.Lines == 0 {
+			return 0
+		}
+		return float64(n) * 100 / float64(summary.Lines)
+	}
+	fmt.Fprintln(w, "=== SUMMARY ===")
+	fmt.Fprintf(w, "lines:        %d\n", summary.Lines)
+	fmt.Fprintf(w, "matched:      %d (%.1f%%)\n", summary.Matched, pct(summary.Matched))
+	fmt.Fprintf(w, "not matched:  %d (%.1f%%)\n", summary.NotMatched, pct(summary.NotMatched))
+	fmt.Fprintf(w, "no timestamp: %d (%.1f%%)\n", summary.NoTimestamp, pct(summary.NoTimestamp))
+	if summary.Errors > 0 {
+		fmt.Fprintf(w, "errors:       %d (%.1f%%)\n", summary.Errors, pct(summary.Errors))
+	}
+	sets := []string{}
+	for set := range summary.Points {
+		sets = append(sets, set)
+	}
+	sort.Strings(sets)
+	fmt.Fprintln(w, "points by set:")
+	for _, set := range sets {
+		fmt.Fprintf(w, "    %s: %d\n", set, summary.Points[set])
+	}
+	if len(summary.Unmatched) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "most frequent unmatched WARNING/INFO lines:")
+	for _, u := range summary.Unmatched {
+		fmt.Fprintf(w, "    %6d %s %s\n", u.Count, u.Severity, u.Context)
+		fmt.Fprintf(w, "           example: %s\n", u.Sample)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAgiPatternsTest(t *testing.T) {
+	log := `Nov 01 2023 10:00:10 GMT: INFO (info): (ticker.c:170) system-memory: free-kbytes 100 free-pct 50
+Nov 01 2023 10:00:10 GMT: INFO (info): (ticker.c:999) not a statistic
+`
+	out := new(bytes.Buffer)
+	c := &agiPatternsTestCmd{LogFile: "-", Show: "unmatched", Top: 20}
+	if err := c.run(strings.NewReader(log), out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "system-memory") || !strings.Contains(out.String(), "2 not-matched: ") || !strings.Contains(out.String(), "         1 INFO (info): (ticker.c:999)\n") || !strings.Contains(out.String(), "    system: 1\n") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+	c.Show = "bad"
+	if err := c.run(strings.NewReader(log), out); err == nil {
+		t.Error("expected error for invalid show option")
+	}
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestmethod/inslice"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"golang.org/x/term"
+)
+
+type agiQueryCmd struct {
+	ClusterName TypeClusterName `short:"n" long:"name" description:"AGI name; a comma-separated list queries and compares multiple AGI instances" default:"agi"`
+	Set         string          `short:"s" long:"set" description:"set to query, ex: system; the set of each graph is the target of its grafana query"`
+	Bins        []string        `short:"b" long:"bins" description:"bins to query, comma-separated or specified multiple times; format: name or name:displayName"`
+	GroupBy     []string        `short:"g" long:"group-by" description:"labels to group series by, comma-separated or specified multiple times, ex: ClusterName,NodeIdent"`
+	Filters     []string        `short:"f" long:"filter" description:"ad-hoc filter, can be specified multiple times; operators: = != =~ !~ < >; ex: ClusterName=mydc, NodeIdent=~BB9.*"`
+	From        string          `long:"from" description:"time range start, format: 2006-01-02T15:04:05Z07:00; default: all data"`
+	To          string          `long:"to" description:"time range end, format: 2006-01-02T15:04:05Z07:00; default: now"`
+	Type        string          `short:"t" long:"type" description:"query type; timeseries|table" default:"timeseries"`
+	Delta       bool            `short:"d" long:"delta" description:"convert cumulative values to per-interval deltas"`
+	PerSecond   bool            `short:"p" long:"per-second" description:"with --delta, convert deltas to per-second values"`
+	MaxPoints   int             `short:"m" long:"max-points" description:"reduce each series to about this many datapoints in the time range, keeping the min/max of each interval; 0: do not reduce" default:"0"`
+	Output      string          `short:"o" long:"output" description:"output format; table|csv|chart" default:"table"`
+	Width       int             `short:"w" long:"width" description:"chart width; default: terminal width"`
+	Help        helpCmd         `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+// agiQueryRequest is the grafana query payload understood by the AGI plugin
+type agiQueryRequest struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	IntervalMs    int               `json:"intervalMs"`
+	MaxDataPoints int               `json:"maxDataPoints"`
+	Targets       []*agiQueryTarget `json:"targets"`
+	AdHocFilters  []*agiQueryFilter `json:"adhocFilters"`
+}
+
+type agiQueryTarget struct {
+	RefId   string           `json:"refId"`
+	Target  string           `json:"target"`
+	Payload *agiQueryPayload `json:"payload"`
+}
+
+type agiQueryPayload struct {
+	Type             string             `json:"type"`
+	Bins             []*agiQueryBin     `json:"bins"`
+	GroupBy          []*agiQueryGroupBy `json:"groupBy"`
+	TimestampBinName string             `json:"timestampBinName"`
+}
+
+type agiQueryBin struct {
+	Name             string `json:"name"`
+	DisplayName      string `json:"displayName"`
+	Type             string `json:"type"`
+	ProduceDelta     bool   `json:"produceDelta"`
+	DeltaToPerSecond bool   `json:"convertToPerSecond"`
+}
+
+type agiQueryGroupBy struct {
+	Name string `json:"name"`
+}
+
+type agiQueryFilter struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+// agiQuerySeries is a timeseries response; datapoints are [value, timestampMs] with null values for gaps
+type agiQuerySeries struct {
+	Target     string        `json:"target"`
+	Datapoints [][2]*float64 `json:"datapoints"`
+}
+
+type agiQueryTable struct {
+	Columns []struct {
+		Text string `json:"text"`
+	} `json:"columns"`
+	Rows [][]interface{} `json:"rows"`
+}
+
+func (c *agiQueryCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	req, err := c.request()
+	if err != nil {
+		return err
+	}
+	if c.Type == "table" && c.Output == "chart" {
+		return errors.New("chart output is only supported for timeseries queries")
+	}
+	if !inslice.HasString([]string{"table", "csv", "chart"}, c.Output) {
+		return fmt.Errorf("output %s not supported, supported: table|csv|chart", c.Output)
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	names := strings.Split(c.ClusterName.String(), ",")
+	series := []*agiQuerySeries{}
+	tables := &agiQueryTable{}
+	for _, name := range names {
+		out, err := b.RunCommands(name, [][]string{{"aerolab", "agi", "exec", "plugin-query", "-p", base64.StdEncoding.EncodeToString(payload)}}, []int{1})
+		if err != nil {
+			if len(out) > 0 {
+				return fmt.Errorf("%s: %s : %s", name, err, string(out[0]))
+			}
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		if len(out) == 0 {
+			return fmt.Errorf("%s: no response returned", name)
+		}
+		prefix := ""
+		if len(names) > 1 {
+			prefix = name + ": "
+		}
+		if c.Type == "table" {
+			resp := []*agiQueryTable{}
+			if err = json.Unmarshal(out[0], &resp); err != nil {
+				return fmt.Errorf("%s: could not parse response: %s", name, err)
+			}
+			for _, t := range resp {
+				agiQueryMergeTable(tables, t, prefix)
+			}
+			continue
+		}
+		resp := []*agiQuerySeries{}
+		if err = json.Unmarshal(out[0], &resp); err != nil {
+			return fmt.Errorf("%s: could not parse response: %s", name, err)
+		}
+		for _, s := range resp {
+			s.Target = prefix + s.Target
+		}
+		series = append(series, resp...)
+	}
+	if c.Type == "table" {
+		return agiQueryWriteTable(os.Stdout, tables, c.Output == "csv")
+	}
+	switch c.Output {
+	case "csv":
+		return agiQueryWriteSeries(os.Stdout, series, true)
+	case "chart":
+		width := c.Width
+		if width == 0 {
+			width, _, err = term.GetSize(int(os.Stdout.Fd()))
+			if err != nil || width < 1 {
+				width = 80
+			}
+		}
+		return agiQueryWriteChart(os.Stdout, series, width)
+	default:
+		return agiQueryWriteSeries(os.Stdout, series, false)
+	}
+}
+
+// request builds the plugin query payload from the command parameters
+func (c *agiQueryCmd) request() (*agiQueryRequest, error) {
+	if c.Set == "" {
+		return nil, errors.New("set name is required, see: aerolab agi query help")
+	}
+	if c.Type != "timeseries" && c.Type != "table" {
+		return nil, fmt.Errorf("query type %s not supported, supported: timeseries|table", c.Type)
+	}
+	req := &agiQueryRequest{
+		MaxDataPoints: 1,
+	}
+	req.Range.From = time.Unix(0, 0)
+	req.Range.To = time.Now()
+	var err error
+	if c.From != "" {
+		if req.Range.From, err = time.Parse("2006-01-02T15:04:05Z07:00", c.From); err != nil {
+			return nil, fmt.Errorf("invalid from time: %s", err)
+		}
+	}
+	if c.To != "" {
+		if req.Range.To, err = time.Parse("2006-01-02T15:04:05Z07:00", c.To); err != nil {
+			return nil, fmt.Errorf("invalid to time: %s", err)
+		}
+	}
+	if c.MaxPoints > 0 {
+		req.MaxDataPoints = c.MaxPoints
+		req.IntervalMs = int(req.Range.To.Sub(req.Range.From).Milliseconds() / int64(c.MaxPoints))
+	}
+	payload := &agiQueryPayload{
+		Type:             c.Type,
+		TimestampBinName: "timestamp",
+	}
+	for _, bins := range c.Bins {
+		for _, bin := range strings.Split(bins, ",") {
+			nameDisplay := strings.SplitN(bin, ":", 2)
+			nbin := &agiQueryBin{
+				Name:             nameDisplay[0],
+				DisplayName:      nameDisplay[0],
+				Type:             "number",
+				ProduceDelta:     c.Delta,
+				DeltaToPerSecond: c.Delta && c.PerSecond,
+			}
+			if len(nameDisplay) > 1 {
+				nbin.DisplayName = nameDisplay[1]
+			}
+			payload.Bins = append(payload.Bins, nbin)
+		}
+	}
+	if len(payload.Bins) == 0 {
+		return nil, errors.New("at least one bin is required, see: aerolab agi query help")
+	}
+	for _, groups := range c.GroupBy {
+		for _, group := range strings.Split(groups, ",") {
+			payload.GroupBy = append(payload.GroupBy, &agiQueryGroupBy{Name: group})
+		}
+	}
+	for _, filter := range c.Filters {
+		f, err := agiQueryParseFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		req.AdHocFilters = append(req.AdHocFilters, f)
+	}
+	req.Targets = []*agiQueryTarget{{
+		RefId:   "A",
+		Target:  c.Set,
+		Payload: payload,
+	}}
+	return req, nil
+}
+
+// agiQueryParseFilter parses filters in the key<operator>value format
+func agiQueryParseFilter(filter string) (*agiQueryFilter, error) {
+	idx := strings.IndexAny(filter, "=!<>")
+	if idx > 0 {
+		for _, op := range []string{"!=", "!~", "=~", "<", ">", "="} {
+			if strings.HasPrefix(filter[idx:], op) {
+				return &agiQueryFilter{
+					Key:      filter[:idx],
+					Operator: op,
+					Value:    filter[idx+len(op):],
+				}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid filter %s, format: key<operator>value, operators: = != =~ !~ < >", filter)
+}
+
+// agiQueryMergeTable appends the rows of a table response, adding a leading AGI column if comparing multiple instances
+func agiQueryMergeTable(dst *agiQueryTable, src *agiQueryTable, prefix string) {
+	if dst.Columns == nil {
+		if prefix != "" {
+			dst.Columns = append(dst.Columns, struct {
+				Text string `json:"text"`
+			}{Text: "AGI"})
+		}
+		dst.Columns = append(dst.Columns, src.Columns...)
+	}
+	for _, row := range src.Rows {
+		if prefix != "" {
+			row = append([]interface{}{strings.TrimSuffix(prefix, ": ")}, row...)
+		}
+		dst.Rows = append(dst.Rows, row)
+	}
+}
+
+// agiQueryWriteTable prints a table response as a table or csv
+func agiQueryWriteTable(w io.Writer, resp *agiQueryTable, isCsv bool) error {
+	header := []string{}
+	for _, col := range resp.Columns {
+		header = append(header, col.Text)
+	}
+	rows := [][]string{}
+	for _, row := range resp.Rows {
+		nrow := []string{}
+		for _, v := range row {
+			nrow = append(nrow, fmt.Sprint(v))
+		}
+		rows = append(rows, nrow)
+	}
+	return agiQueryWrite(w, header, rows, isCsv)
+}
+
+// agiQueryWriteSeries prints timeseries as a table or csv, with a row per timestamp and a column per series
+func agiQueryWriteSeries(w io.Writer, series []*agiQuerySeries, isCsv bool) error {
+	header := []string{"Time"}
+	values := make(map[int64][]string)
+	for si, s := range series {
+		header = append(header, s.Target)
+		for _, dp := range s.Datapoints {
+			if dp[1] == nil {
+				continue
+			}
+			ts := int64(*dp[1])
+			if _, ok := values[ts]; !ok {
+				values[ts] = make([]string, len(series))
+			}
+			if dp[0] != nil {
+				values[ts][si] = strconv.FormatFloat(*dp[0], 'f', -1, 64)
+			}
+		}
+	}
+	timestamps := []int64{}
+	for ts := range values {
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i] < timestamps[j]
+	})
+	rows := [][]string{}
+	for _, ts := range timestamps {
+		rows = append(rows, append([]string{time.UnixMilli(ts).UTC().Format("2006-01-02T15:04:05.000Z07:00")}, values[ts]...))
+	}
+	return agiQueryWrite(w, header, rows, isCsv)
+}
+
+func agiQueryWrite(w io.Writer, header []string, rows [][]string, isCsv bool) error {
+	if isCsv {
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	}
+	t := table.NewWriter()
+	t.SetStyle(table.StyleDefault)
+	t.SetOutputMirror(w)
+	hrow := table.Row{}
+	for _, h := range header {
+		hrow = append(hrow, h)
+	}
+	t.AppendHeader(hrow)
+	for _, row := range rows {
+		nrow := table.Row{}
+		for _, v := range row {
+			nrow = append(nrow, v)
+		}
+		t.AppendRow(nrow)
+	}
+	t.Render()
+	return nil
+}
+
+var agiQuerySparks = []rune("▁▂▃▄▅▆▇█")
+
+// agiQueryWriteChart prints a sparkline per series; all series share the time axis, each character being the average of its time bucket
+func agiQueryWriteChart(w io.Writer, series []*agiQuerySeries, width int) error {
+	minTs, maxTs := math.Inf(1), math.Inf(-1)
+	labelWidth := 0
+	for _, s := range series {
+		for _, dp := range s.Datapoints {
+			if dp[0] == nil || dp[1] == nil {
+				continue
+			}
+			minTs = math.Min(minTs, *dp[1])
+			maxTs = math.Max(maxTs, *dp[1])
+		}
+		if len(s.Target) > labelWidth {
+			labelWidth = len(s.Target)
+		}
+	}
+	if math.IsInf(minTs, 1) {
+		_, err := fmt.Fprintln(w, "No datapoints found")
+		return err
+	}
+	if labelWidth > width/3 {
+		labelWidth = width / 3
+	}
+	chartWidth := width - labelWidth - 2
+	if chartWidth < 10 {
+		chartWidth = 10
+	}
+	fmt.Fprintf(w, "%*s  %s -> %s\n", labelWidth, "", time.UnixMilli(int64(minTs)).UTC().Format(time.RFC3339), time.UnixMilli(int64(maxTs)).UTC().Format(time.RFC3339))
+	for _, s := range series {
+		sums := make([]float64, chartWidth)
+		counts := make([]int, chartWidth)
+		minVal, maxVal, total, count := math.Inf(1), math.Inf(-1), float64(0), 0
+		for _, dp := range s.Datapoints {
+			if dp[0] == nil || dp[1] == nil {
+				continue
+			}
+			bucket := 0
+			if maxTs > minTs {
+				bucket = int((*dp[1] - minTs) / (maxTs - minTs) * float64(chartWidth-1))
+			}
+			sums[bucket] += *dp[0]
+			counts[bucket]++
+			minVal = math.Min(minVal, *dp[0])
+			maxVal = math.Max(maxVal, *dp[0])
+			total += *dp[0]
+			count++
+		}
+		line := []rune{}
+		for i := range sums {
+			if counts[i] == 0 {
+				line = append(line, ' ')
+				continue
+			}
+			level := 0
+			if maxVal > minVal {
+				level = int((sums[i]/float64(counts[i]) - minVal) / (maxVal - minVal) * float64(len(agiQuerySparks)-1))
+			}
+			line = append(line, agiQuerySparks[level])
+		}
+		label := s.Target
+		if len(label) > labelWidth {
+			label = label[:labelWidth]
+		}
+		if count == 0 {
+			fmt.Fprintf(w, "%-*s  (no datapoints)\n", labelWidth, label)
+			continue
+		}
+		fmt.Fprintf(w, "%-*s  %s\n", labelWidth, label, string(line))
+		if _, err := fmt.Fprintf(w, "%-*s  min=%s max=%s avg=%s\n", labelWidth, "", strconv.FormatFloat(minVal, 'f', -1, 64), strconv.FormatFloat(maxVal, 'f', -1, 64), strconv.FormatFloat(total/float64(count), 'f', 2, 64)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAgiQueryRequest(t *testing.T) {
+	c := &agiQueryCmd{
+		Set:       "system",
+		Bins:      []string{"cpu:CPU,mem"},
+		GroupBy:   []string{"ClusterName,NodeIdent"},
+		Filters:   []string{"ClusterName=mydc", "NodeIdent!~BB9.*", "cpu>50"},
+		From:      "2023-09-01T00:00:00Z",
+		To:        "2023-09-01T01:00:00Z",
+		Type:      "timeseries",
+		MaxPoints: 60,
+	}
+	req, err := c.request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.IntervalMs != 60000 || req.MaxDataPoints != 60 {
+		t.Errorf("unexpected interval %d for %d points", req.IntervalMs, req.MaxDataPoints)
+	}
+	payload := req.Targets[0].Payload
+	if req.Targets[0].Target != "system" || len(payload.Bins) != 2 || payload.Bins[0].DisplayName != "CPU" || payload.Bins[1].DisplayName != "mem" || len(payload.GroupBy) != 2 {
+		t.Errorf("unexpected target %+v", req.Targets[0])
+	}
+	expected := []*agiQueryFilter{{"ClusterName", "=", "mydc"}, {"NodeIdent", "!~", "BB9.*"}, {"cpu", ">", "50"}}
+	if !reflect.DeepEqual(req.AdHocFilters, expected) {
+		t.Errorf("unexpected filters %+v", req.AdHocFilters)
+	}
+
+	for _, bad := range []*agiQueryCmd{
+		{Bins: []string{"cpu"}, Type: "timeseries"},
+		{Set: "system", Type: "timeseries"},
+		{Set: "system", Bins: []string{"cpu"}, Type: "graph"},
+		{Set: "system", Bins: []string{"cpu"}, Type: "timeseries", From: "yesterday"},
+		{Set: "system", Bins: []string{"cpu"}, Type: "timeseries", Filters: []string{"cpu"}},
+	} {
+		if _, err = bad.request(); err == nil {
+			t.Errorf("expected error for %+v", bad)
+		}
+	}
+}
+
+func agiQueryTestSeries() []*agiQuerySeries {
+	series := []*agiQuerySeries{}
+	json.Unmarshal([]byte(`[
+		{"target":"node1","datapoints":[[1,1000],[null,1500],[3,2000]]},
+		{"target":"node2","datapoints":[[5,2000],[7,3000]]}
+	]`), &series)
+	return series
+}
+
+func TestAgiQueryWriteSeries(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := agiQueryWriteSeries(out, agiQueryTestSeries(), true); err != nil {
+		t.Fatal(err)
+	}
+	expected := `Time,node1,node2
+1970-01-01T00:00:01.000Z,1,
+1970-01-01T00:00:01.500Z,,
+1970-01-01T00:00:02.000Z,3,5
+1970-01-01T00:00:03.000Z,,7
+`
+	if out.String() != expected {
+		t.Errorf("unexpected csv:\n%s", out.String())
+	}
+}
+
+func TestAgiQueryWriteChart(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := agiQueryWriteChart(out, agiQueryTestSeries(), 30); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) != 6 || !strings.HasPrefix(lines[1], "node1  ▁") || !strings.HasSuffix(strings.TrimRight(lines[1], " "), "█") || !strings.Contains(lines[4], "min=5 max=7 avg=6.00") {
+		t.Errorf("unexpected chart:\n%s", out.String())
+	}
+	out.Reset()
+	agiQueryWriteChart(out, nil, 30)
+	if out.String() != "No datapoints found\n" {
+		t.Errorf("unexpected empty chart %q", out.String())
+	}
+}
+
+func TestAgiQueryMergeTable(t *testing.T) {
+	tables := &agiQueryTable{}
+	src := &agiQueryTable{}
+	json.Unmarshal([]byte(`{"columns":[{"text":"Node"},{"text":"Errors"}],"rows":[["node1",5]]}`), src)
+	agiQueryMergeTable(tables, src, "agi1: ")
+	agiQueryMergeTable(tables, src, "agi2: ")
+	out := new(bytes.Buffer)
+	if err := agiQueryWriteTable(out, tables, true); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "AGI,Node,Errors\nagi1,node1,5\nagi2,node1,5\n" {
+		t.Errorf("unexpected table csv:\n%s", out.String())
+	}
+}
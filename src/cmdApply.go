@@ -0,0 +1,714 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bestmethod/inslice"
+	flags "github.com/rglonek/jeddevdk-goflags"
+	"gopkg.in/yaml.v3"
+)
+
+/* example lab.yaml
+name: lab
+clusters:
+  - name: src
+    count: 2
+    options:            # same payload as rest-api /cluster/create
+      AerospikeVersion: "7.0.*"
+    tls:
+      - TlsName: tls1   # same payload as rest-api /tls/generate
+    conf:
+      - ["set", "service.proto-fd-max", "30000"]
+  - name: dst
+    count: 2
+clients:
+  - name: ams
+    type: ams
+    options:
+      ConnectClusters: src,dst
+xdr:
+  - source: src
+    destinations: [dst]
+    options:
+      Namespaces: test
+*/
+
+type applyCmd struct {
+	File         flags.Filename  `short:"f" long:"file" description:"Environment definition file (yaml)" default:"lab.yaml"`
+	Confirm      bool            `long:"confirm" description:"set to apply (or destroy) without asking for confirmation"`
+	PruneAdopted bool            `long:"prune-adopted" description:"also destroy clusters and clients which existed before apply took them over, when they are removed from the file (or on destroy)"`
+	Plan         applyPlanCmd    `command:"plan" subcommands-optional:"true" description:"Show changes that apply would make to the environment"`
+	Destroy      applyDestroyCmd `command:"destroy" subcommands-optional:"true" description:"Destroy all clusters and clients created by applying the environment file"`
+	Help         helpCmd         `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type applySpec struct {
+	Name     string             `yaml:"name"`
+	Clusters []applySpecCluster `yaml:"clusters"`
+	Clients  []applySpecClient  `yaml:"clients"`
+	Xdr      []applySpecXdr     `yaml:"xdr"`
+}
+
+type applySpecCluster struct {
+	Name    string                   `yaml:"name"`
+	Count   int                      `yaml:"count"`
+	Options map[string]interface{}   `yaml:"options"`
+	Tls     []map[string]interface{} `yaml:"tls"`
+	Conf    [][]string               `yaml:"conf"`
+}
+
+type applySpecClient struct {
+	Name    string                 `yaml:"name"`
+	Type    string                 `yaml:"type"`
+	Count   int                    `yaml:"count"`
+	Options map[string]interface{} `yaml:"options"`
+}
+
+type applySpecXdr struct {
+	Source       string                 `yaml:"source"`
+	Destinations []string               `yaml:"destinations"`
+	Options      map[string]interface{} `yaml:"options"`
+}
+
+// applyState records what the environment file created, so that items removed from the file can be destroyed
+type applyState struct {
+	Name     string
+	Backend  string
+	Clusters map[string]string // cluster name -> hash of tls and conf definitions applied
+	Clients  map[string]string // client name -> client type
+	Xdr      map[string]string // source>destination -> hash of xdr definition applied
+	Adopted  map[string]bool   `json:",omitempty"` // cluster:name or client:name -> true for items which existed before apply took them over
+}
+
+type applyAction struct {
+	Description string
+	run         func() error
+}
+
+func (c *applyCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Println("Running apply")
+	spec, state, err := c.load()
+	if err != nil {
+		return err
+	}
+	actions, err := c.makePlan(spec, state)
+	if err != nil {
+		return err
+	}
+	if len(actions) == 0 {
+		log.Println("Environment is up to date")
+		return nil
+	}
+	c.printPlan(actions)
+	if !c.Confirm && !askYesNo("Apply the above changes") {
+		fmt.Println("Aborting")
+		return nil
+	}
+	for _, action := range actions {
+		log.Printf("Apply: %s", action.Description)
+		err = action.run()
+		if err != nil {
+			return fmt.Errorf("%s: %s", action.Description, err)
+		}
+		err = state.save()
+		if err != nil {
+			return err
+		}
+	}
+	log.Println("Done")
+	return nil
+}
+
+func askYesNo(question string) bool {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("%s (y/n)? ", question)
+		yesno, err := reader.ReadString('\n')
+		if err != nil {
+			logExit(err)
+		}
+		yesno = strings.ToLower(strings.TrimSpace(yesno))
+		if yesno == "y" || yesno == "yes" {
+			return true
+		} else if yesno == "n" || yesno == "no" {
+			return false
+		}
+	}
+}
+
+func (c *applyCmd) printPlan(actions []*applyAction) {
+	fmt.Println("Planned changes:")
+	for _, action := range actions {
+		fmt.Printf("  %s\n", action.Description)
+	}
+}
+
+// load parses the environment file and the state stored for it from a previous apply
+func (c *applyCmd) load() (spec *applySpec, state *applyState, err error) {
+	contents, err := os.ReadFile(string(c.File))
+	if err != nil {
+		return nil, nil, err
+	}
+	spec = new(applySpec)
+	dec := yaml.NewDecoder(bytes.NewReader(contents))
+	dec.KnownFields(true)
+	err = dec.Decode(spec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse %s: %s", c.File, err)
+	}
+	err = spec.validate()
+	if err != nil {
+		return nil, nil, err
+	}
+	state = &applyState{
+		Name:     spec.Name,
+		Backend:  a.opts.Config.Backend.Type,
+		Clusters: make(map[string]string),
+		Clients:  make(map[string]string),
+		Xdr:      make(map[string]string),
+		Adopted:  make(map[string]bool),
+	}
+	fn, err := state.fileName()
+	if err != nil {
+		return nil, nil, err
+	}
+	stateContents, err := os.ReadFile(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return spec, state, nil
+		}
+		return nil, nil, err
+	}
+	err = json.Unmarshal(stateContents, state)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse state file %s: %s", fn, err)
+	}
+	return spec, state, nil
+}
+
+func (s *applySpec) validate() error {
+	if s.Name == "" {
+		return errors.New("environment file must specify a name")
+	}
+	if !isLegalName(s.Name) {
+		return errors.New("environment name is not legal, only use a-zA-Z0-9_-")
+	}
+	names := []string{}
+	for i, cluster := range s.Clusters {
+		if cluster.Name == "" {
+			return fmt.Errorf("cluster %d does not have a name", i)
+		}
+		if inslice.HasString(names, cluster.Name) {
+			return fmt.Errorf("cluster %s is defined more than once", cluster.Name)
+		}
+		names = append(names, cluster.Name)
+		if cluster.Count < 1 {
+			s.Clusters[i].Count = 1
+		}
+	}
+	names = []string{}
+	for i, client := range s.Clients {
+		if client.Name == "" {
+			return fmt.Errorf("client %d does not have a name", i)
+		}
+		if inslice.HasString(names, client.Name) {
+			return fmt.Errorf("client %s is defined more than once", client.Name)
+		}
+		names = append(names, client.Name)
+		if client.Type == "" {
+			s.Clients[i].Type = "base"
+		}
+		if _, err := findApplyCommand([]string{"client", "create", s.Clients[i].Type}); err != nil {
+			return fmt.Errorf("client %s: unsupported client type %s", client.Name, client.Type)
+		}
+		if client.Count < 1 {
+			s.Clients[i].Count = 1
+		}
+	}
+	for _, xdr := range s.Xdr {
+		if xdr.Source == "" || len(xdr.Destinations) == 0 {
+			return errors.New("xdr definitions must specify source and destinations")
+		}
+	}
+	return nil
+}
+
+func (s *applyState) fileName() (string, error) {
+	rd, err := a.aerolabRootDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(rd, "apply", s.Name+"."+s.Backend+".json"), nil
+}
+
+func (s *applyState) save() error {
+	fn, err := s.fileName()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path.Dir(fn)); err != nil {
+		err = os.MkdirAll(path.Dir(fn), 0700)
+		if err != nil {
+			return err
+		}
+	}
+	contents, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fn, contents, 0600)
+}
+
+func (s *applyState) remove() error {
+	fn, err := s.fileName()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(fn)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// isAdopted returns true if the cluster or client existed before apply took it over; such items are not destroyed unless --prune-adopted is set
+func (s *applyState) isAdopted(itemType string, name string) bool {
+	if itemType == "cluster" && s.Clusters[name] == "adopted" {
+		return true
+	}
+	return s.Adopted[itemType+":"+name]
+}
+
+func applyHash(items ...interface{}) string {
+	contents, _ := json.Marshal(items)
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:])
+}
+
+// getApplyNodes returns a map of cluster name to existing node numbers, for servers and clients
+func getApplyNodes() (clusters map[string][]int, clients map[string][]int, err error) {
+	inv, err := b.Inventory("", []int{InventoryItemClusters, InventoryItemClients})
+	if err != nil {
+		return nil, nil, err
+	}
+	clusters = make(map[string][]int)
+	clients = make(map[string][]int)
+	for _, item := range inv.Clusters {
+		no, err := strconv.Atoi(item.NodeNo)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not parse node number %s of cluster %s: %s", item.NodeNo, item.ClusterName, err)
+		}
+		clusters[item.ClusterName] = append(clusters[item.ClusterName], no)
+	}
+	for _, item := range inv.Clients {
+		no, err := strconv.Atoi(item.NodeNo)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not parse node number %s of client %s: %s", item.NodeNo, item.ClientName, err)
+		}
+		clients[item.ClientName] = append(clients[item.ClientName], no)
+	}
+	for _, nodes := range clusters {
+		sort.Ints(nodes)
+	}
+	for _, nodes := range clients {
+		sort.Ints(nodes)
+	}
+	return clusters, clients, nil
+}
+
+// makePlan compares the environment file against the inventory and previous state and returns the list of actions to perform;
+// it does not modify the state, all state changes happen when the actions are run
+func (c *applyCmd) makePlan(spec *applySpec, state *applyState) ([]*applyAction, error) {
+	clusters, clients, err := getApplyNodes()
+	if err != nil {
+		return nil, err
+	}
+	actions := []*applyAction{}
+	specClusters := []string{}
+	for _, cluster := range spec.Clusters {
+		specClusters = append(specClusters, cluster.Name)
+	}
+	specClients := []string{}
+	for _, client := range spec.Clients {
+		specClients = append(specClients, client.Name)
+	}
+
+	// destroy items removed from the environment file
+	for _, name := range sortedKeys(state.Clients) {
+		if inslice.HasString(specClients, name) {
+			continue
+		}
+		if _, ok := clients[name]; !ok {
+			actions = append(actions, applyForget(state, "client", name, "no longer exists"))
+		} else if state.isAdopted("client", name) && !c.PruneAdopted {
+			actions = append(actions, applyForget(state, "client", name, "existed before apply, leaving it in place; use --prune-adopted to destroy it"))
+		} else {
+			actions = append(actions, applyDestroyClient(state, name))
+		}
+	}
+	for _, name := range sortedKeys(state.Clusters) {
+		if inslice.HasString(specClusters, name) {
+			continue
+		}
+		if _, ok := clusters[name]; !ok {
+			actions = append(actions, applyForget(state, "cluster", name, "no longer exists"))
+		} else if state.isAdopted("cluster", name) && !c.PruneAdopted {
+			actions = append(actions, applyForget(state, "cluster", name, "existed before apply, leaving it in place; use --prune-adopted to destroy it"))
+		} else {
+			actions = append(actions, applyDestroyCluster(state, name))
+		}
+	}
+
+	// clusters
+	changed := []string{}
+	for _, cluster := range spec.Clusters {
+		cluster := cluster
+		nodes, exists := clusters[cluster.Name]
+		if exists && state.Clusters[cluster.Name] == "" {
+			// cluster exists but was not created by us; take ownership of it
+			actions = append(actions, applyAdopt(state, "cluster", cluster.Name, "adopted"))
+		}
+		newNodes := ""
+		switch {
+		case !exists:
+			changed = append(changed, cluster.Name)
+			actions = append(actions, &applyAction{
+				Description: fmt.Sprintf("+ create cluster %s with %d nodes", cluster.Name, cluster.Count),
+				run: func() error {
+					err := runApplyCommand([]string{"cluster", "create"}, cluster.Options, map[string]interface{}{
+						"ClusterName": cluster.Name,
+						"NodeCount":   cluster.Count,
+					}, nil)
+					if err != nil {
+						return err
+					}
+					state.Clusters[cluster.Name] = "created"
+					return nil
+				},
+			})
+		case len(nodes) < cluster.Count:
+			changed = append(changed, cluster.Name)
+			for i := nodes[len(nodes)-1] + 1; i <= nodes[len(nodes)-1]+cluster.Count-len(nodes); i++ {
+				newNodes = newNodes + "," + strconv.Itoa(i)
+			}
+			newNodes = strings.TrimPrefix(newNodes, ",")
+			actions = append(actions, &applyAction{
+				Description: fmt.Sprintf("~ grow cluster %s by %d nodes", cluster.Name, cluster.Count-len(nodes)),
+				run: func() error {
+					return runApplyCommand([]string{"cluster", "grow"}, cluster.Options, map[string]interface{}{
+						"ClusterName": cluster.Name,
+						"NodeCount":   cluster.Count - len(nodes),
+					}, nil)
+				},
+			})
+		case len(nodes) > cluster.Count:
+			remove := nodes[cluster.Count:]
+			actions = append(actions, &applyAction{
+				Description: fmt.Sprintf("- shrink cluster %s by destroying nodes %s", cluster.Name, intSliceToString(remove, ",")),
+				run: func() error {
+					return runApplyCommand([]string{"cluster", "destroy"}, nil, map[string]interface{}{
+						"ClusterName": cluster.Name,
+						"Nodes":       intSliceToString(remove, ","),
+						"Force":       true,
+					}, nil)
+				},
+			})
+		}
+		hash := applyHash(cluster.Tls, cluster.Conf)
+		if len(cluster.Tls) == 0 && len(cluster.Conf) == 0 {
+			continue
+		}
+		if state.Clusters[cluster.Name] == hash && newNodes == "" && exists {
+			continue
+		}
+		if state.Clusters[cluster.Name] != hash {
+			// definition changed, apply to all nodes
+			newNodes = ""
+		}
+		actions = append(actions, applyConfigureCluster(state, cluster, hash, newNodes))
+	}
+
+	// xdr
+	for _, xdr := range spec.Xdr {
+		xdr := xdr
+		for _, dest := range xdr.Destinations {
+			dest := dest
+			key := xdr.Source + ">" + dest
+			hash := applyHash(xdr.Options)
+			if state.Xdr[key] == hash && !inslice.HasString(changed, xdr.Source) && !inslice.HasString(changed, dest) {
+				continue
+			}
+			actions = append(actions, &applyAction{
+				Description: fmt.Sprintf("~ connect xdr %s => %s", xdr.Source, dest),
+				run: func() error {
+					err := runApplyCommand([]string{"xdr", "connect"}, xdr.Options, map[string]interface{}{
+						"SourceClusterName":       xdr.Source,
+						"DestinationClusterNames": dest,
+					}, nil)
+					if err != nil {
+						return err
+					}
+					state.Xdr[key] = hash
+					return nil
+				},
+			})
+		}
+	}
+	for _, key := range sortedKeys(state.Xdr) {
+		found := false
+		for _, xdr := range spec.Xdr {
+			for _, dest := range xdr.Destinations {
+				if key == xdr.Source+">"+dest {
+					found = true
+				}
+			}
+		}
+		if !found {
+			key := key
+			actions = append(actions, &applyAction{
+				Description: fmt.Sprintf("! xdr %s was removed from the file; aerolab will not disconnect it, remove the configuration manually", strings.ReplaceAll(key, ">", " => ")),
+				run: func() error {
+					delete(state.Xdr, key)
+					return nil
+				},
+			})
+		}
+	}
+
+	// clients
+	for _, client := range spec.Clients {
+		client := client
+		nodes, exists := clients[client.Name]
+		if exists && state.Clients[client.Name] == "" {
+			actions = append(actions, applyAdopt(state, "client", client.Name, client.Type))
+		}
+		switch {
+		case !exists:
+			actions = append(actions, &applyAction{
+				Description: fmt.Sprintf("+ create %s client %s with %d machines", client.Type, client.Name, client.Count),
+				run: func() error {
+					err := runApplyCommand([]string{"client", "create", client.Type}, client.Options, map[string]interface{}{
+						"ClientName":  client.Name,
+						"ClientCount": client.Count,
+					}, nil)
+					if err != nil {
+						return err
+					}
+					state.Clients[client.Name] = client.Type
+					return nil
+				},
+			})
+		case len(nodes) < client.Count:
+			actions = append(actions, &applyAction{
+				Description: fmt.Sprintf("~ grow %s client %s by %d machines", client.Type, client.Name, client.Count-len(nodes)),
+				run: func() error {
+					return runApplyCommand([]string{"client", "grow", client.Type}, client.Options, map[string]interface{}{
+						"ClientName":  client.Name,
+						"ClientCount": client.Count - len(nodes),
+					}, nil)
+				},
+			})
+		case len(nodes) > client.Count:
+			remove := nodes[client.Count:]
+			actions = append(actions, &applyAction{
+				Description: fmt.Sprintf("- shrink client %s by destroying machines %s", client.Name, intSliceToString(remove, ",")),
+				run: func() error {
+					return runApplyCommand([]string{"client", "destroy"}, nil, map[string]interface{}{
+						"ClientName": client.Name,
+						"Machines":   intSliceToString(remove, ","),
+						"Force":      true,
+					}, nil)
+				},
+			})
+		}
+	}
+	return actions, nil
+}
+
+func applyConfigureCluster(state *applyState, cluster applySpecCluster, hash string, nodes string) *applyAction {
+	desc := fmt.Sprintf("~ configure tls/conf on cluster %s", cluster.Name)
+	if nodes != "" {
+		desc = fmt.Sprintf("~ configure tls/conf on cluster %s nodes %s", cluster.Name, nodes)
+	}
+	return &applyAction{
+		Description: desc,
+		run: func() error {
+			for _, tls := range cluster.Tls {
+				err := runApplyCommand([]string{"tls", "generate"}, tls, map[string]interface{}{
+					"ClusterName": cluster.Name,
+					"Nodes":       nodes,
+				}, nil)
+				if err != nil {
+					return err
+				}
+			}
+			for _, conf := range cluster.Conf {
+				err := runApplyCommand([]string{"conf", "adjust"}, nil, map[string]interface{}{
+					"ClusterName": cluster.Name,
+					"Nodes":       nodes,
+				}, conf)
+				if err != nil {
+					return err
+				}
+			}
+			err := runApplyCommand([]string{"aerospike", "restart"}, nil, map[string]interface{}{
+				"ClusterName": cluster.Name,
+				"Nodes":       nodes,
+			}, nil)
+			if err != nil {
+				return err
+			}
+			state.Clusters[cluster.Name] = hash
+			return nil
+		},
+	}
+}
+
+func applyDestroyCluster(state *applyState, name string) *applyAction {
+	return &applyAction{
+		Description: fmt.Sprintf("- destroy cluster %s", name),
+		run: func() error {
+			err := runApplyCommand([]string{"cluster", "destroy"}, nil, map[string]interface{}{
+				"ClusterName": name,
+				"Force":       true,
+			}, nil)
+			if err != nil {
+				return err
+			}
+			delete(state.Clusters, name)
+			delete(state.Adopted, "cluster:"+name)
+			return nil
+		},
+	}
+}
+
+func applyDestroyClient(state *applyState, name string) *applyAction {
+	return &applyAction{
+		Description: fmt.Sprintf("- destroy client %s", name),
+		run: func() error {
+			err := runApplyCommand([]string{"client", "destroy"}, nil, map[string]interface{}{
+				"ClientName": name,
+				"Force":      true,
+			}, nil)
+			if err != nil {
+				return err
+			}
+			delete(state.Clients, name)
+			delete(state.Adopted, "client:"+name)
+			return nil
+		},
+	}
+}
+
+func applyForget(state *applyState, itemType string, name string, reason string) *applyAction {
+	return &applyAction{
+		Description: fmt.Sprintf("- forget %s %s (%s)", itemType, name, reason),
+		run: func() error {
+			if itemType == "cluster" {
+				delete(state.Clusters, name)
+			} else {
+				delete(state.Clients, name)
+			}
+			delete(state.Adopted, itemType+":"+name)
+			return nil
+		},
+	}
+}
+
+// applyAdopt takes over a cluster or client which exists but was not created by apply
+func applyAdopt(state *applyState, itemType string, name string, value string) *applyAction {
+	return &applyAction{
+		Description: fmt.Sprintf("= adopt existing %s %s", itemType, name),
+		run: func() error {
+			if itemType == "cluster" {
+				state.Clusters[name] = value
+			} else {
+				state.Clients[name] = value
+			}
+			state.Adopted[itemType+":"+name] = true
+			return nil
+		},
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func findApplyCommand(command []string) (*reflect.Value, error) {
+	keyField := reflect.ValueOf(a.opts).Elem()
+	v, err := (&restCmd{}).findCommand(keyField, "", "", []string{}, command)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, fmt.Errorf("command %s not found", strings.Join(command, " "))
+	}
+	return v, nil
+}
+
+type applyGrower interface {
+	setGrow(isGrow bool)
+}
+
+// runApplyCommand locates the command struct in the same way as the rest-api does, fills it with the given options, and executes it;
+// the command struct is reset to its previous values afterwards, so that options do not leak between invocations
+func runApplyCommand(command []string, options map[string]interface{}, override map[string]interface{}, tail []string) error {
+	v, err := findApplyCommand(command)
+	if err != nil {
+		return err
+	}
+	// the telemetry started by earlyProcess reads the options in the background, wait for it before changing them
+	telemetryWait()
+	orig := reflect.New(v.Type()).Elem()
+	orig.Set(*v)
+	defer func() {
+		telemetryWait()
+		v.Set(orig)
+	}()
+	for _, payload := range []map[string]interface{}{options, override} {
+		if len(payload) == 0 {
+			continue
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(v.Addr().Interface())
+		if err != nil {
+			return fmt.Errorf("%s: invalid options: %s", strings.Join(command, " "), err)
+		}
+	}
+	if grower, ok := v.Addr().Interface().(applyGrower); ok {
+		grower.setGrow(command[1] == "grow")
+		defer grower.setGrow(false)
+	}
+	outv := v.Addr().MethodByName("Execute").Call([]reflect.Value{reflect.ValueOf(tail)})
+	if err, ok := outv[0].Interface().(error); ok && err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+type applyDestroyCmd struct {
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *applyDestroyCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Println("Running apply.destroy")
+	_, state, err := a.opts.Apply.load()
+	if err != nil {
+		return err
+	}
+	clusters, clients, err := getApplyNodes()
+	if err != nil {
+		return err
+	}
+	actions := []*applyAction{}
+	names := []string{}
+	for _, name := range sortedKeys(state.Clients) {
+		if _, ok := clients[name]; !ok {
+			continue
+		}
+		if state.isAdopted("client", name) && !a.opts.Apply.PruneAdopted {
+			log.Printf("Client %s existed before apply, leaving it in place; use --prune-adopted to destroy it", name)
+			continue
+		}
+		actions = append(actions, applyDestroyClient(state, name))
+		names = append(names, name)
+	}
+	for _, name := range sortedKeys(state.Clusters) {
+		if _, ok := clusters[name]; !ok {
+			continue
+		}
+		if state.isAdopted("cluster", name) && !a.opts.Apply.PruneAdopted {
+			log.Printf("Cluster %s existed before apply, leaving it in place; use --prune-adopted to destroy it", name)
+			continue
+		}
+		actions = append(actions, applyDestroyCluster(state, name))
+		names = append(names, name)
+	}
+	if len(actions) > 0 {
+		if !a.opts.Apply.Confirm && !askYesNo(fmt.Sprintf("Are you sure you want to destroy [%s]", strings.Join(names, ", "))) {
+			fmt.Println("Aborting")
+			return nil
+		}
+	}
+	for _, action := range actions {
+		log.Printf("Apply: %s", action.Description)
+		err = action.run()
+		if err != nil {
+			return fmt.Errorf("%s: %s", action.Description, err)
+		}
+	}
+	err = state.remove()
+	if err != nil {
+		return err
+	}
+	log.Println("Done")
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+)
+
+type applyPlanCmd struct {
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *applyPlanCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	spec, state, err := a.opts.Apply.load()
+	if err != nil {
+		return err
+	}
+	actions, err := a.opts.Apply.makePlan(spec, state)
+	if err != nil {
+		return err
+	}
+	if len(actions) == 0 {
+		fmt.Println("Environment is up to date")
+		return nil
+	}
+	a.opts.Apply.printPlan(actions)
+	return nil
+}
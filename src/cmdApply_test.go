@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func applyWriteSpec(t *testing.T, fn string, features string, clusters ...string) {
+	t.Helper()
+	spec := "name: lab\nclusters:\n"
+	for _, name := range clusters {
+		spec += fmt.Sprintf("  - name: %s\n    count: 1\n    options:\n      DistroName: %s\n      DistroVersion: %q\n      AerospikeVersion: %s\n      FeaturesFilePath: %s\n", name, mockVersion.distroName, mockVersion.distroVersion, mockVersion.aerospikeVersion, features)
+	}
+	if len(clusters) == 0 {
+		spec += "  []\n"
+	}
+	if err := os.WriteFile(fn, []byte(spec), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyPlanApplyRemove(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "pre", "1")
+	fn := filepath.Join(t.TempDir(), "lab.yaml")
+	applyWriteSpec(t, fn, features, "pre", "new")
+
+	// plan must not have side effects
+	mockRun(t, "apply", "plan", "-f", fn)
+	spec, state, err := a.opts.Apply.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions, err := a.opts.Apply.makePlan(spec, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	descriptions := []string{}
+	for _, action := range actions {
+		descriptions = append(descriptions, action.Description)
+	}
+	if !reflect.DeepEqual(descriptions, []string{"= adopt existing cluster pre", "+ create cluster new with 1 nodes"}) {
+		t.Fatalf("unexpected plan %v", descriptions)
+	}
+	if len(state.Clusters) != 0 || len(state.Adopted) != 0 {
+		t.Fatalf("plan modified the state: %+v", state)
+	}
+	stateFile, _ := state.fileName()
+	if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+		t.Fatalf("plan wrote the state file: %v", err)
+	}
+	if clusters, _ := m.ClusterList(); !reflect.DeepEqual(clusters, []string{"pre"}) {
+		t.Fatalf("plan changed the clusters: %v", clusters)
+	}
+
+	mockRun(t, "apply", "-f", fn, "--confirm")
+	if clusters, _ := m.ClusterList(); len(clusters) != 2 {
+		t.Fatalf("expected clusters pre and new, got %v", clusters)
+	}
+	contents, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(contents), `"new": "created"`) || !strings.Contains(string(contents), `"cluster:pre": true`) {
+		t.Errorf("unexpected state:\n%s", contents)
+	}
+
+	// removing both clusters from the file destroys only the one apply created
+	applyWriteSpec(t, fn, features)
+	mockRun(t, "apply", "-f", fn, "--confirm")
+	if clusters, _ := m.ClusterList(); !reflect.DeepEqual(clusters, []string{"pre"}) {
+		t.Fatalf("expected only the adopted cluster pre to remain, got %v", clusters)
+	}
+
+	// an adopted cluster is destroyed on removal only with --prune-adopted
+	applyWriteSpec(t, fn, features, "pre")
+	mockRun(t, "apply", "-f", fn, "--confirm")
+	mockRun(t, "apply", "destroy", "-f", fn, "--confirm")
+	if clusters, _ := m.ClusterList(); !reflect.DeepEqual(clusters, []string{"pre"}) {
+		t.Fatalf("destroy removed the adopted cluster: %v", clusters)
+	}
+	mockRun(t, "apply", "-f", fn, "--confirm")
+	applyWriteSpec(t, fn, features)
+	mockRun(t, "apply", "-f", fn, "--confirm", "--prune-adopted")
+	if clusters, _ := m.ClusterList(); len(clusters) != 0 {
+		t.Fatalf("expected adopted cluster to be pruned, got %v", clusters)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"log"
-	"os/exec"
 	"strconv"
 	"strings"
 
@@ -86,16 +85,15 @@ func (c *clientAddElasticSearchCmd) Execute(args []string) error {
 
 func (c *clientAddElasticSearchCmd) addElasticSearch(args []string) error {
 	isDocker := false
+	b.WorkOnClients()
 	if a.opts.Config.Backend.Type == "docker" {
 		isDocker = true
-		out, err := exec.Command("docker", "run", "--rm", "-i", "--privileged", "ubuntu:22.04", "sysctl", "-w", "vm.max_map_count=262144").CombinedOutput()
+		machines, err := c.Machines.Translate(c.ClientName.String())
 		if err != nil {
-			fmt.Println("Workaround `sysctl -w vm.max_map_count=262144` for docker failed, elasticsearch might fail to start...")
-			fmt.Println(err)
-			fmt.Println(string(out))
+			return err
 		}
+		dockerSysctlMaxMapCount(c.ClientName.String(), machines, "elasticsearch might fail to start")
 	}
-	b.WorkOnClients()
 	masterNode := 1
 	if len(c.existingNodes) == 0 {
 		script := c.installScriptAllNodes(c.RamLimit, isDocker) + c.installScriptMasterNode()
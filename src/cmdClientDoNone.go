@@ -23,11 +23,19 @@ type clientCreateNoneCmd struct {
 	Docker        clusterCreateCmdDocker `no-flag:"true"`
 	osSelectorCmd
 	parallelThreadsCmd
-	PriceOnly bool   `long:"price" description:"Only display price of ownership; do not actually create the cluster"`
-	Owner     string `long:"owner" description:"AWS/GCP only: create owner tag with this value"`
+	PriceOnly    bool   `long:"price" description:"Only display price of ownership; do not actually create the cluster"`
+	Owner        string `long:"owner" description:"AWS/GCP only: create owner tag with this value"`
+	growOverride bool
+}
+
+func (c *clientCreateNoneCmd) setGrow(isGrow bool) {
+	c.growOverride = isGrow
 }
 
 func (c *clientCreateNoneCmd) isGrow() bool {
+	if c.growOverride {
+		return true
+	}
 	if len(os.Args) >= 3 && os.Args[1] == "client" && os.Args[2] == "grow" {
 		return true
 	}
@@ -305,7 +313,7 @@ func (c *clientCreateNoneCmd) createBase(args []string, nt string) (machines []i
 		}
 	}
 	var nip map[int]string
-	if a.opts.Config.Backend.Type != "docker" && !c.NoSetHostname {
+	if a.opts.Config.Backend.Type != "docker" && a.opts.Config.Backend.Type != "kubernetes" && !c.NoSetHostname {
 		nip, err = b.GetNodeIpMap(string(c.ClientName), false)
 		if err != nil {
 			return nil, err
@@ -314,7 +322,7 @@ func (c *clientCreateNoneCmd) createBase(args []string, nt string) (machines []i
 	}
 	returns := parallelize.MapLimit(nodeListNew, c.ParallelThreads, func(nnode int) error {
 		// set hostnames for cloud
-		if a.opts.Config.Backend.Type != "docker" && !c.NoSetHostname {
+		if a.opts.Config.Backend.Type != "docker" && a.opts.Config.Backend.Type != "kubernetes" && !c.NoSetHostname {
 			newHostname := fmt.Sprintf("%s-%d", string(c.ClientName), nnode)
 			newHostname = strings.ReplaceAll(newHostname, "_", "-")
 			hComm := [][]string{
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 	"sync"
@@ -58,11 +57,8 @@ func (c *clientStartCmd) runStart(args []string) error {
 		return err
 	}
 	if a.opts.Config.Backend.Type == "docker" {
-		out, err := exec.Command("docker", "run", "--rm", "-i", "--privileged", "ubuntu:22.04", "sysctl", "-w", "vm.max_map_count=262144").CombinedOutput()
-		if err != nil {
-			fmt.Println("Workaround `sysctl -w vm.max_map_count=262144` for docker failed, elasticsearch clients might fail to start...")
-			fmt.Println(err)
-			fmt.Println(string(out))
+		for _, ClusterName := range cList {
+			dockerSysctlMaxMapCount(ClusterName, nodes[ClusterName], "elasticsearch clients might fail to start")
 		}
 	}
 	var nerr error
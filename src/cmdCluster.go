@@ -15,6 +15,7 @@ type clusterCmd struct {
 	Partition clusterPartitionCmd `command:"partition" subcommands-optional:"true" description:"node disk partitioner"`
 	Attach    attachShellCmd      `command:"attach" subcommands-optional:"true" description:"symlink to: attach shell"`
 	Share     clusterShareCmd     `command:"share" subcommands-optional:"true" description:"AWS/GCP: share the cluster by importing a provided ssh public key file"`
+	Snapshot  clusterSnapshotCmd  `command:"snapshot" subcommands-optional:"true" description:"Snapshot clusters and recreate them from snapshots"`
 	Help      helpCmd             `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
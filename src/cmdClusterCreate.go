@@ -32,6 +32,7 @@ type clusterCreateCmd struct {
 	aerospikeVersionSelectorCmd
 	AutoStartAerospike    TypeYesNo      `short:"s" long:"start" description:"Auto-start aerospike after creation of cluster (y/n)" default:"y"`
 	NoOverrideClusterName bool           `short:"O" long:"no-override-cluster-name" description:"Aerolab sets cluster-name by default, use this parameter to not set cluster-name"`
+	NoValidate            bool           `long:"no-validate" description:"Do not validate the custom aerospike config file before installing it"`
 	NoSetHostname         bool           `short:"H" long:"no-set-hostname" description:"by default, hostname of each machine will be set, use this to prevent hostname change"`
 	ScriptEarly           flags.Filename `short:"X" long:"early-script" description:"optionally specify a script to be installed which will run before every aerospike start"`
 	ScriptLate            flags.Filename `short:"Z" long:"late-script" description:"optionally specify a script to be installed which will run after every aerospike stop"`
@@ -215,6 +216,13 @@ func (c *clusterCreateCmd) realExecute2(args []string, isGrow bool) error {
 		log.Println("Running cluster.grow")
 	}
 
+	if a.opts.Config.Backend.Type == "kubernetes" {
+		// the cluster name is stored in a label of each node
+		if err := kubeCheckLabelValue("cluster name", c.ClusterName.String()); err != nil {
+			return logFatal(err)
+		}
+	}
+
 	var foundVol *inventoryVolume
 	var efsName, efsLocalPath, efsPath string
 	isArm := false
@@ -365,7 +373,10 @@ func (c *clusterCreateCmd) realExecute2(args []string, isGrow bool) error {
 		}
 	}
 
-	if c.HeartbeatMode == "mcast" || c.HeartbeatMode == "multicast" {
+	if c.HeartbeatMode == "multicast" {
+		c.HeartbeatMode = "mcast"
+	}
+	if c.HeartbeatMode == "mcast" {
 		if c.MulticastAddress == "" || c.MulticastPort == "" {
 			return logFatal("When using multicase mode, multicast address and port must be specified")
 		}
@@ -433,6 +444,25 @@ func (c *clusterCreateCmd) realExecute2(args []string, isGrow bool) error {
 	verNoSuffix := strings.TrimSuffix(c.AerospikeVersion.String(), "c")
 	verNoSuffix = strings.TrimSuffix(verNoSuffix, "f")
 
+	if string(c.CustomConfigFilePath) != "" && !c.NoValidate {
+		log.Println("Validating custom aerospike config file")
+		conf, err := os.ReadFile(string(c.CustomConfigFilePath))
+		if err != nil {
+			return err
+		}
+		v := &confValidator{
+			version:       verNoSuffix,
+			skipHeartbeat: c.HeartbeatMode == "mesh" || c.HeartbeatMode == "mcast",
+		}
+		if c.Docker.RamLimit != "" && a.opts.Config.Backend.Type == "docker" {
+			v.memory, _ = confValidateParseSize(c.Docker.RamLimit)
+		}
+		err = validateAerospikeConf(string(c.CustomConfigFilePath), conf, v)
+		if err != nil {
+			return err
+		}
+	}
+
 	// build extra
 	var ep []string
 	if c.Docker.ExposePortsToHost != "" {
@@ -848,7 +878,7 @@ func (c *clusterCreateCmd) realExecute2(args []string, isGrow bool) error {
 	}
 
 	// set hostnames for aws and gcp
-	if a.opts.Config.Backend.Type != "docker" && !c.NoSetHostname {
+	if a.opts.Config.Backend.Type != "docker" && a.opts.Config.Backend.Type != "kubernetes" && !c.NoSetHostname {
 		nip, err := b.GetNodeIpMap(string(c.ClusterName), false)
 		if err != nil {
 			return err
@@ -1235,7 +1265,7 @@ sed -e "s/access-address.*/access-address ${INTIP}/g" -e "s/alternate-access-add
 		returns := parallelize.MapLimit(nodeListNew, c.ParallelThreads, func(node int) error {
 			var comm [][]string
 			comm = append(comm, []string{"service", "aerospike", "start"})
-			_, err = b.RunCommands(string(c.ClusterName), comm, []int{node})
+			_, err := b.RunCommands(string(c.ClusterName), comm, []int{node})
 			if err != nil {
 				return err
 			}
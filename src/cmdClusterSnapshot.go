@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type clusterSnapshotCmd struct {
+	Create  clusterSnapshotCreateCmd  `command:"create" subcommands-optional:"true" description:"Snapshot the configuration, features files, data and labels of a cluster"`
+	List    clusterSnapshotListCmd    `command:"list" subcommands-optional:"true" description:"List snapshots"`
+	Restore clusterSnapshotRestoreCmd `command:"restore" subcommands-optional:"true" description:"Create a new cluster from a snapshot"`
+	Delete  clusterSnapshotDeleteCmd  `command:"delete" subcommands-optional:"true" description:"Delete a snapshot"`
+	Help    helpCmd                   `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *clusterSnapshotCmd) Execute(args []string) error {
+	a.parser.WriteHelp(os.Stderr)
+	os.Exit(1)
+	return nil
+}
+
+// clusterSnapshot is the snapshot metadata, stored as snapshot.json in the snapshot directory
+type clusterSnapshot struct {
+	Name             string
+	Created          time.Time
+	ClusterName      string
+	Backend          string
+	Nodes            []int
+	AerospikeVersion string
+	DistroName       string
+	DistroVersion    string
+	Labels           map[string]string `json:",omitempty"`
+	DataMode         string
+	Namespaces       []string         `json:",omitempty"` // asbackup mode: namespaces backed up from the first node
+	RawPaths         map[int][]string `json:",omitempty"` // raw mode: devices and files copied, per node
+	VolumePath       string           `json:",omitempty"` // if set, data was left in this path on the nodes instead of being downloaded
+}
+
+const (
+	clusterSnapshotDataAsbackup = "asbackup"
+	clusterSnapshotDataRaw      = "raw"
+	clusterSnapshotDataNone     = "none"
+)
+
+var clusterSnapshotNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]*$`)
+
+// clusterSnapshotRoot returns the directory in which snapshots are stored
+func clusterSnapshotRoot() (string, error) {
+	rd, err := a.aerolabRootDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(rd, "snapshots"), nil
+}
+
+// clusterSnapshotDir returns the directory of a given snapshot
+func clusterSnapshotDir(name string) (string, error) {
+	if !clusterSnapshotNameRegex.MatchString(name) {
+		return "", fmt.Errorf("invalid snapshot name %q, only letters, digits, '.', '_' and '-' are allowed", name)
+	}
+	root, err := clusterSnapshotRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, name), nil
+}
+
+// nodeDir returns the local directory holding the files of a snapshotted node
+func (s *clusterSnapshot) nodeDir(dir string, node int) string {
+	return filepath.Join(dir, "nodes", strconv.Itoa(node))
+}
+
+// dataFile returns the path of a node's data archive on the node itself
+func (s *clusterSnapshot) dataFile(node int) string {
+	if s.VolumePath != "" {
+		return fmt.Sprintf("%s/%s/node-%d.tgz", strings.TrimSuffix(s.VolumePath, "/"), s.Name, node)
+	}
+	return "/opt/aerolab-snapshot-" + s.Name + ".tgz"
+}
+
+// workDir returns the temporary directory used on the nodes while packing and unpacking data
+func (s *clusterSnapshot) workDir() string {
+	return "/opt/aerolab-snapshot-" + s.Name
+}
+
+func (s *clusterSnapshot) save(dir string) error {
+	contents, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "snapshot.json"), contents, 0600)
+}
+
+func loadClusterSnapshot(name string) (*clusterSnapshot, string, error) {
+	dir, err := clusterSnapshotDir(name)
+	if err != nil {
+		return nil, "", err
+	}
+	contents, err := os.ReadFile(filepath.Join(dir, "snapshot.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, "", fmt.Errorf("snapshot %s not found", name)
+		}
+		return nil, "", err
+	}
+	s := &clusterSnapshot{}
+	err = json.Unmarshal(contents, s)
+	if err != nil {
+		return nil, "", fmt.Errorf("snapshot %s: could not parse metadata: %s", name, err)
+	}
+	return s, dir, nil
+}
+
+// clusterSnapshotRawName returns the file name under which a raw device or file is stored in the data archive
+func clusterSnapshotRawName(path string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(path, "/"), "/", "_") + ".img"
+}
+
+// clusterSnapshotStoragePaths returns the devices and files configured in the storage-engine stanzas of aerospike.conf
+func clusterSnapshotStoragePaths(conf string) []string {
+	paths := []string{}
+	stanzas := []string{}
+	for _, line := range strings.Split(conf, "\n") {
+		line = strings.TrimSpace(strings.Split(line, "#")[0])
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.HasSuffix(line, "{") {
+			stanzas = append(stanzas, fields[0])
+			continue
+		}
+		if line == "}" {
+			if len(stanzas) > 0 {
+				stanzas = stanzas[:len(stanzas)-1]
+			}
+			continue
+		}
+		if len(stanzas) == 0 || stanzas[len(stanzas)-1] != "storage-engine" || len(fields) < 2 {
+			continue
+		}
+		if fields[0] == "file" || fields[0] == "device" {
+			paths = append(paths, fields[1])
+		}
+	}
+	return paths
+}
+
+// clusterSnapshotUserLabels returns the cluster labels or tags, skipping those aerolab manages itself
+func clusterSnapshotUserLabels(item inventoryCluster) map[string]string {
+	labels := make(map[string]string)
+	for _, src := range []map[string]string{item.awsTags, item.gcpLabels, item.dockerLabels} {
+		for k, v := range src {
+			lk := strings.ToLower(k)
+			if strings.HasPrefix(lk, "aerolab") || strings.HasPrefix(lk, "used") {
+				continue
+			}
+			switch lk {
+			case "name", "owner", "arch", "telemetry", "expireduration", "lastused", "agilabel":
+				continue
+			}
+			labels[k] = v
+		}
+	}
+	return labels
+}
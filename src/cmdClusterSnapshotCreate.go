@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/bestmethod/inslice"
+)
+
+type clusterSnapshotCreateCmd struct {
+	ClusterName  TypeClusterName `short:"n" long:"name" description:"Cluster name" default:"mydc"`
+	SnapshotName string          `short:"s" long:"snapshot" description:"Snapshot name; default: CLUSTERNAME-YYYYMMDD-HHMMSS"`
+	Data         string          `short:"d" long:"data" description:"How to capture data, one of: asbackup|raw|none; raw stops aerospike on all nodes while copying storage devices and files" default:"asbackup"`
+	RawPaths     []string        `short:"p" long:"raw-path" description:"raw mode: device or file to copy on each node; can be specified multiple times; default: all devices and files from storage-engine in aerospike.conf"`
+	VolumePath   string          `short:"V" long:"volume-path" description:"Store data in this path on the nodes instead of downloading it locally, ex: the mount path of a shared volume; it must be mounted at the same path when restoring"`
+	parallelThreadsCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *clusterSnapshotCreateCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running cluster.snapshot.create")
+	if !inslice.HasString([]string{clusterSnapshotDataAsbackup, clusterSnapshotDataRaw, clusterSnapshotDataNone}, c.Data) {
+		return fmt.Errorf("data mode must be one of: asbackup, raw, none; got %s", c.Data)
+	}
+	if c.SnapshotName == "" {
+		c.SnapshotName = string(c.ClusterName) + "-" + time.Now().Format("20060102-150405")
+	}
+	dir, err := clusterSnapshotDir(c.SnapshotName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("snapshot %s already exists", c.SnapshotName)
+	}
+
+	inv, err := b.Inventory("", []int{InventoryItemClusters})
+	if err != nil {
+		return err
+	}
+	s := &clusterSnapshot{
+		Name:        c.SnapshotName,
+		Created:     time.Now(),
+		ClusterName: string(c.ClusterName),
+		Backend:     a.opts.Config.Backend.Type,
+		DataMode:    c.Data,
+		VolumePath:  c.VolumePath,
+	}
+	for _, item := range inv.Clusters {
+		if item.ClusterName != string(c.ClusterName) {
+			continue
+		}
+		no, err := strconv.Atoi(item.NodeNo)
+		if err != nil {
+			return fmt.Errorf("could not parse node number %s: %s", item.NodeNo, err)
+		}
+		s.Nodes = append(s.Nodes, no)
+		if s.Labels == nil {
+			s.Labels = clusterSnapshotUserLabels(item)
+			s.AerospikeVersion = item.AerospikeVersion
+			s.DistroName = item.Distribution
+			s.DistroVersion = item.OSVersion
+		}
+	}
+	if len(s.Nodes) == 0 {
+		return fmt.Errorf("cluster does not exist: %s", string(c.ClusterName))
+	}
+	sort.Ints(s.Nodes)
+	// the version installed by aerolab carries the edition suffix, the inventory may not
+	out, err := b.RunCommands(s.ClusterName, [][]string{{"cat", "/opt/aerolab.aerospike.version"}}, []int{s.Nodes[0]})
+	if err == nil && len(out) > 0 && strings.TrimSpace(string(out[0])) != "" {
+		s.AerospikeVersion = strings.TrimSpace(string(out[0]))
+	}
+
+	log.Print("Saving configuration files")
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+	success := false
+	defer func() {
+		if !success {
+			os.RemoveAll(dir)
+		}
+	}()
+	confs := make(map[int]string)
+	for _, node := range s.Nodes {
+		nodeDir := s.nodeDir(dir, node)
+		err = os.MkdirAll(nodeDir, 0700)
+		if err != nil {
+			return err
+		}
+		out, err := b.RunCommands(s.ClusterName, [][]string{{"cat", "/etc/aerospike/aerospike.conf"}}, []int{node})
+		if err != nil {
+			return fmt.Errorf("node %d: could not read aerospike.conf: %s", node, err)
+		}
+		confs[node] = string(out[0])
+		err = os.WriteFile(filepath.Join(nodeDir, "aerospike.conf"), out[0], 0600)
+		if err != nil {
+			return err
+		}
+		// features files are optional, ex: community edition
+		out, err = b.RunCommands(s.ClusterName, [][]string{{"cat", "/etc/aerospike/features.conf"}}, []int{node})
+		if err == nil && len(out) > 0 && len(out[0]) > 0 {
+			err = os.WriteFile(filepath.Join(nodeDir, "features.conf"), out[0], 0600)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	dataNodes := []int{}
+	switch c.Data {
+	case clusterSnapshotDataAsbackup:
+		dataNodes = []int{s.Nodes[0]}
+		err = c.asbackup(s)
+	case clusterSnapshotDataRaw:
+		dataNodes = s.Nodes
+		err = c.raw(s, confs)
+	}
+	if err != nil {
+		return err
+	}
+	if s.VolumePath == "" && len(dataNodes) > 0 {
+		log.Print("Downloading data")
+		returns := parallelize.MapLimit(dataNodes, c.ParallelThreads, func(node int) error {
+			err := b.Download(s.ClusterName, node, s.dataFile(node), filepath.Join(s.nodeDir(dir, node), "data.tgz"), false, false)
+			if err != nil {
+				return err
+			}
+			_, err = b.RunCommands(s.ClusterName, [][]string{{"rm", "-f", s.dataFile(node)}}, []int{node})
+			return err
+		})
+		isError := false
+		for i, ret := range returns {
+			if ret != nil {
+				log.Printf("Node %d returned %s", dataNodes[i], ret)
+				isError = true
+			}
+		}
+		if isError {
+			return errors.New("could not download data from some nodes")
+		}
+	}
+
+	err = s.save(dir)
+	if err != nil {
+		return err
+	}
+	success = true
+	log.Printf("Snapshot %s created in %s", s.Name, dir)
+	log.Print("Done")
+	return nil
+}
+
+// asbackup backs up all namespaces from the first node into its data archive
+func (c *clusterSnapshotCreateCmd) asbackup(s *clusterSnapshot) error {
+	node := s.Nodes[0]
+	namespaces, err := aerospikeRollingInfoRaw(s.ClusterName, node, "namespaces")
+	if err != nil {
+		return fmt.Errorf("could not list namespaces, is aerospike running? %s", err)
+	}
+	for _, ns := range strings.Split(namespaces, ";") {
+		if ns != "" {
+			s.Namespaces = append(s.Namespaces, ns)
+		}
+	}
+	log.Printf("Backing up namespaces %s using asbackup on node %d", strings.Join(s.Namespaces, ","), node)
+	script := []string{"set -e", "rm -rf " + s.workDir(), "mkdir -p " + s.workDir()}
+	for _, ns := range s.Namespaces {
+		script = append(script, fmt.Sprintf("asbackup -h 127.0.0.1 -n %s -o %s/%s.asb", ns, s.workDir(), ns))
+	}
+	script = append(script, c.packScript(s, node)...)
+	out, err := b.RunCommands(s.ClusterName, [][]string{{"/bin/bash", "-c", strings.Join(script, "; ")}}, []int{node})
+	if err != nil {
+		if len(out) > 0 {
+			return fmt.Errorf("asbackup failed: %s: %s", err, strings.TrimSpace(string(out[0])))
+		}
+		return fmt.Errorf("asbackup failed: %s", err)
+	}
+	return nil
+}
+
+// raw stops aerospike on all nodes, copies the storage devices and files into each node's data archive, and starts aerospike again
+func (c *clusterSnapshotCreateCmd) raw(s *clusterSnapshot, confs map[int]string) error {
+	s.RawPaths = make(map[int][]string)
+	for _, node := range s.Nodes {
+		paths := c.RawPaths
+		if len(paths) == 0 {
+			paths = clusterSnapshotStoragePaths(confs[node])
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("node %d: no storage devices or files found in aerospike.conf; use --raw-path or --data=asbackup", node)
+		}
+		s.RawPaths[node] = paths
+	}
+	log.Print("Stopping aerospike and copying storage devices and files")
+	returns := parallelize.MapLimit(s.Nodes, c.ParallelThreads, func(node int) error {
+		script := []string{"trap 'service aerospike start' EXIT", "set -e", "service aerospike stop", "rm -rf " + s.workDir(), "mkdir -p " + s.workDir()}
+		for _, path := range s.RawPaths[node] {
+			script = append(script, fmt.Sprintf("dd if=%s of=%s/%s bs=4M status=none", path, s.workDir(), clusterSnapshotRawName(path)))
+		}
+		script = append(script, c.packScript(s, node)...)
+		out, err := b.RunCommands(s.ClusterName, [][]string{{"/bin/bash", "-c", strings.Join(script, "; ")}}, []int{node})
+		if err != nil && len(out) > 0 {
+			return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out[0])))
+		}
+		return err
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", s.Nodes[i], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return errors.New("raw copy failed on some nodes")
+	}
+	return nil
+}
+
+// packScript returns the commands which archive the work directory into the node's data file
+func (c *clusterSnapshotCreateCmd) packScript(s *clusterSnapshot, node int) []string {
+	return []string{
+		"mkdir -p " + filepath.ToSlash(filepath.Dir(s.dataFile(node))),
+		fmt.Sprintf("tar -czf %s -C %s .", s.dataFile(node), s.workDir()),
+		"rm -rf " + s.workDir(),
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+type clusterSnapshotDeleteCmd struct {
+	SnapshotName string  `short:"s" long:"snapshot" description:"Snapshot name" required:"true"`
+	Help         helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *clusterSnapshotDeleteCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running cluster.snapshot.delete")
+	s, dir, err := loadClusterSnapshot(c.SnapshotName)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	if s.VolumePath != "" && s.DataMode != clusterSnapshotDataNone {
+		log.Printf("WARNING: snapshot data stored in %s/%s on the volume was not removed", s.VolumePath, s.Name)
+	}
+	log.Print("Done")
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+type clusterSnapshotListCmd struct {
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *clusterSnapshotListCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	root, err := clusterSnapshotRoot()
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	snapshots := []*clusterSnapshot{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		s, _, err := loadClusterSnapshot(entry.Name())
+		if err != nil {
+			log.Printf("WARNING: skipping %s: %s", entry.Name(), err)
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.Before(snapshots[j].Created)
+	})
+	t := table.NewWriter()
+	t.SetStyle(table.StyleDefault)
+	t.AppendHeader(table.Row{"Name", "Created", "Cluster", "Backend", "Nodes", "Aerospike", "Distro", "Data", "Location"})
+	for _, s := range snapshots {
+		location := "local"
+		if s.VolumePath != "" {
+			location = "volume:" + s.VolumePath
+		}
+		t.AppendRow(table.Row{s.Name, s.Created.Format(time.RFC3339), s.ClusterName, s.Backend, len(s.Nodes), s.AerospikeVersion, strings.Join([]string{s.DistroName, s.DistroVersion}, ":"), s.DataMode, location})
+	}
+	fmt.Println(t.Render())
+	return nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/bestmethod/inslice"
+)
+
+type clusterSnapshotRestoreCmd struct {
+	SnapshotName          string          `short:"s" long:"snapshot" description:"Snapshot name" required:"true"`
+	ClusterName           TypeClusterName `short:"n" long:"name" description:"Name of the cluster to create; default: name of the cluster the snapshot was taken from"`
+	NoOverrideClusterName bool            `short:"O" long:"no-override-cluster-name" description:"Keep cluster-name from the snapshotted aerospike.conf instead of setting it to the new cluster name"`
+	NoData                bool            `long:"no-data" description:"Only restore configuration and labels, do not restore data"`
+	Timeout               time.Duration   `long:"timeout" description:"asbackup mode: how long to wait for the cluster to form before restoring data" default:"10m"`
+	Owner                 string          `long:"owner" description:"AWS/GCP only: create owner tag with this value"`
+	parallelThreadsCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *clusterSnapshotRestoreCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running cluster.snapshot.restore")
+	s, dir, err := loadClusterSnapshot(c.SnapshotName)
+	if err != nil {
+		return err
+	}
+	if c.ClusterName == "" {
+		c.ClusterName = TypeClusterName(s.ClusterName)
+	}
+	clusterName := string(c.ClusterName)
+	clusterList, err := b.ClusterList()
+	if err != nil {
+		return err
+	}
+	if inslice.HasString(clusterList, clusterName) {
+		return fmt.Errorf("cluster %s already exists, destroy it first or restore with a different name", clusterName)
+	}
+	restoreData := !c.NoData && s.DataMode != clusterSnapshotDataNone
+	if restoreData && s.VolumePath == "" {
+		for _, node := range c.dataNodes(s) {
+			if _, err := os.Stat(filepath.Join(s.nodeDir(dir, node), "data.tgz")); err != nil {
+				return fmt.Errorf("snapshot data of node %d missing: %s", node, err)
+			}
+		}
+	}
+
+	log.Printf("Creating cluster %s with %d nodes from snapshot %s", clusterName, len(s.Nodes), s.Name)
+	options := map[string]interface{}{
+		"ClusterName":        clusterName,
+		"NodeCount":          len(s.Nodes),
+		"DistroName":         s.DistroName,
+		"DistroVersion":      s.DistroVersion,
+		"AerospikeVersion":   s.AerospikeVersion,
+		"AutoStartAerospike": "n",
+		"Owner":              c.Owner,
+		"ParallelThreads":    c.ParallelThreads,
+	}
+	features := filepath.Join(s.nodeDir(dir, s.Nodes[0]), "features.conf")
+	if _, err := os.Stat(features); err == nil {
+		options["FeaturesFilePath"] = features
+	}
+	if len(s.Labels) > 0 {
+		labels := []string{}
+		for k, v := range s.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		switch a.opts.Config.Backend.Type {
+		case "aws":
+			options["Aws"] = map[string]interface{}{"Tags": labels}
+		case "gcp":
+			options["Gcp"] = map[string]interface{}{"Labels": labels}
+		default:
+			options["Docker"] = map[string]interface{}{"Labels": labels}
+		}
+	}
+	err = runApplyCommand([]string{"cluster", "create"}, options, nil, nil)
+	if err != nil {
+		return fmt.Errorf("could not create cluster: %s", err)
+	}
+
+	// snapshotted nodes map to the new cluster's nodes in order
+	nodes, err := b.NodeListInCluster(clusterName)
+	if err != nil {
+		return err
+	}
+	if len(nodes) != len(s.Nodes) {
+		return fmt.Errorf("expected %d nodes in the new cluster, found %d", len(s.Nodes), len(nodes))
+	}
+	nodeMap := make(map[int]int)
+	for i, node := range s.Nodes {
+		nodeMap[node] = nodes[i]
+	}
+	clusterIps, err := b.GetClusterNodeIps(clusterName)
+	if err != nil {
+		return err
+	}
+
+	log.Print("Restoring configuration files")
+	for _, node := range s.Nodes {
+		conf, err := os.ReadFile(filepath.Join(s.nodeDir(dir, node), "aerospike.conf"))
+		if err != nil {
+			return err
+		}
+		newconf := string(conf)
+		if clusterSnapshotIsMesh(newconf) {
+			newconf, err = fixAerospikeConfig(newconf, "", "mesh", clusterIps, nodes)
+			if err != nil {
+				return fmt.Errorf("node %d: could not fix mesh configuration: %s", node, err)
+			}
+		}
+		if !c.NoOverrideClusterName {
+			newconf, err = fixClusterNameConfig(newconf, clusterName)
+			if err != nil {
+				return fmt.Errorf("node %d: could not set cluster name: %s", node, err)
+			}
+		}
+		files := []fileListReader{{"/etc/aerospike/aerospike.conf", strings.NewReader(newconf), len(newconf)}}
+		features, err := os.ReadFile(filepath.Join(s.nodeDir(dir, node), "features.conf"))
+		if err == nil {
+			files = append(files, fileListReader{"/etc/aerospike/features.conf", strings.NewReader(string(features)), len(features)})
+		}
+		err = b.CopyFilesToClusterReader(clusterName, files, []int{nodeMap[node]})
+		if err != nil {
+			return err
+		}
+	}
+
+	if restoreData && s.DataMode == clusterSnapshotDataRaw {
+		err = c.restoreRaw(s, dir, nodeMap)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Print("Starting aerospike")
+	_, err = b.RunCommands(clusterName, [][]string{{"service", "aerospike", "start"}}, nodes)
+	if err != nil {
+		return err
+	}
+
+	if restoreData && s.DataMode == clusterSnapshotDataAsbackup {
+		log.Print("Waiting for the cluster to form")
+		err = (&aerospikeRollingCmd{RollingTimeout: c.Timeout}).waitSettled(clusterName, nodes, nodes, len(nodes))
+		if err != nil {
+			return fmt.Errorf("cluster did not form, data not restored: %s", err)
+		}
+		err = c.restoreAsbackup(s, dir, nodeMap)
+		if err != nil {
+			return err
+		}
+	}
+	log.Printf("Cluster %s restored from snapshot %s", clusterName, s.Name)
+	log.Print("Done")
+	return nil
+}
+
+// dataNodes returns the snapshotted nodes which hold a data archive
+func (c *clusterSnapshotRestoreCmd) dataNodes(s *clusterSnapshot) []int {
+	switch s.DataMode {
+	case clusterSnapshotDataAsbackup:
+		return []int{s.Nodes[0]}
+	case clusterSnapshotDataRaw:
+		return s.Nodes
+	}
+	return nil
+}
+
+// unpackScript uploads the data archive of a snapshotted node to the new node if stored locally, and returns the commands which unpack it into the work directory
+func (c *clusterSnapshotRestoreCmd) unpackScript(s *clusterSnapshot, dir string, node int, newNode int) ([]string, error) {
+	if s.VolumePath == "" {
+		err := b.Upload(string(c.ClusterName), newNode, filepath.Join(s.nodeDir(dir, node), "data.tgz"), s.dataFile(node), false, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	script := []string{"set -e", "rm -rf " + s.workDir(), "mkdir -p " + s.workDir(), fmt.Sprintf("tar -xzf %s -C %s", s.dataFile(node), s.workDir())}
+	if s.VolumePath == "" {
+		script = append(script, "rm -f "+s.dataFile(node))
+	}
+	return script, nil
+}
+
+// restoreRaw copies the storage devices and files back on each node while aerospike is stopped
+func (c *clusterSnapshotRestoreCmd) restoreRaw(s *clusterSnapshot, dir string, nodeMap map[int]int) error {
+	log.Print("Restoring storage devices and files")
+	returns := parallelize.MapLimit(s.Nodes, c.ParallelThreads, func(node int) error {
+		script, err := c.unpackScript(s, dir, node, nodeMap[node])
+		if err != nil {
+			return err
+		}
+		for _, path := range s.RawPaths[node] {
+			if !strings.HasPrefix(path, "/dev/") {
+				script = append(script, "mkdir -p "+filepath.ToSlash(filepath.Dir(path)))
+			}
+			script = append(script, fmt.Sprintf("dd if=%s/%s of=%s bs=4M status=none", s.workDir(), clusterSnapshotRawName(path), path))
+		}
+		script = append(script, "rm -rf "+s.workDir())
+		out, err := b.RunCommands(string(c.ClusterName), [][]string{{"/bin/bash", "-c", strings.Join(script, "; ")}}, []int{nodeMap[node]})
+		if err != nil && len(out) > 0 {
+			return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out[0])))
+		}
+		return err
+	})
+	isError := false
+	for i, ret := range returns {
+		if ret != nil {
+			log.Printf("Node %d returned %s", nodeMap[s.Nodes[i]], ret)
+			isError = true
+		}
+	}
+	if isError {
+		return errors.New("raw restore failed on some nodes")
+	}
+	return nil
+}
+
+// restoreAsbackup restores the backed up namespaces using asrestore on the first node
+func (c *clusterSnapshotRestoreCmd) restoreAsbackup(s *clusterSnapshot, dir string, nodeMap map[int]int) error {
+	node := s.Nodes[0]
+	log.Printf("Restoring namespaces %s using asrestore on node %d", strings.Join(s.Namespaces, ","), nodeMap[node])
+	script, err := c.unpackScript(s, dir, node, nodeMap[node])
+	if err != nil {
+		return err
+	}
+	for _, ns := range s.Namespaces {
+		script = append(script, fmt.Sprintf("asrestore -h 127.0.0.1 -i %s/%s.asb", s.workDir(), ns))
+	}
+	script = append(script, "rm -rf "+s.workDir())
+	out, err := b.RunCommands(string(c.ClusterName), [][]string{{"/bin/bash", "-c", strings.Join(script, "; ")}}, []int{nodeMap[node]})
+	if err != nil {
+		if len(out) > 0 {
+			return fmt.Errorf("asrestore failed: %s: %s", err, strings.TrimSpace(string(out[0])))
+		}
+		return fmt.Errorf("asrestore failed: %s", err)
+	}
+	return nil
+}
+
+// clusterSnapshotIsMesh returns true if aerospike.conf configures mesh heartbeat
+func clusterSnapshotIsMesh(conf string) bool {
+	for _, line := range strings.Split(conf, "\n") {
+		fields := strings.Fields(strings.Split(line, "#")[0])
+		if len(fields) == 2 && fields[0] == "mode" && fields[1] == "mesh" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMockClusterSnapshot(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "mydc", "2")
+	if err := m.SetLabel("mydc", "team", "db", ""); err != nil {
+		t.Fatal(err)
+	}
+	m.setResponse("mydc", 0, []string{"asinfo", "-v", "namespaces"}, "test;bar\n", nil)
+	// asbackup does not run on the mock, provide the archive it would have packed
+	archive := "backup archive"
+	if err := m.CopyFilesToClusterReader("mydc", []fileListReader{{"/opt/aerolab-snapshot-snap1.tgz", strings.NewReader(archive), len(archive)}}, []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	before := len(m.getCalls())
+	mockRun(t, "cluster", "snapshot", "create", "-n", "mydc", "-s", "snap1")
+
+	dir, err := clusterSnapshotDir("snap1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := os.ReadFile(filepath.Join(dir, "snapshot.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &clusterSnapshot{}
+	if err := json.Unmarshal(contents, s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ClusterName != "mydc" || !reflect.DeepEqual(s.Nodes, []int{1, 2}) || !reflect.DeepEqual(s.Namespaces, []string{"test", "bar"}) || s.Labels["team"] != "db" || s.Labels["owner"] != "" {
+		t.Errorf("unexpected snapshot metadata: %s", contents)
+	}
+	for _, node := range []string{"1", "2"} {
+		for _, fn := range []string{"aerospike.conf", "features.conf"} {
+			if _, err := os.Stat(filepath.Join(dir, "nodes", node, fn)); err != nil {
+				t.Errorf("node %s: %s not saved: %s", node, fn, err)
+			}
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "nodes", "1", "data.tgz")); err != nil || string(data) != archive {
+		t.Errorf("data archive not downloaded: %s", err)
+	}
+	asbackup := false
+	for _, call := range m.getCalls()[before:] {
+		if len(call.Command) == 3 && strings.Contains(call.Command[2], "asbackup -h 127.0.0.1 -n bar") {
+			asbackup = true
+		}
+	}
+	if !asbackup {
+		t.Error("asbackup not run")
+	}
+	if _, ok := m.getFile("mydc", 1, "/opt/aerolab-snapshot-snap1.tgz"); ok {
+		t.Error("data archive not removed from the node")
+	}
+
+	mockRun(t, "cluster", "snapshot", "list")
+
+	mockSettledCluster(m, "restored", "2")
+	before = len(m.getCalls())
+	mockRun(t, "cluster", "snapshot", "restore", "-s", "snap1", "-n", "restored")
+	nodes, _ := m.NodeListInCluster("restored")
+	if !reflect.DeepEqual(nodes, []int{1, 2}) {
+		t.Fatalf("expected nodes [1 2], got %v", nodes)
+	}
+	mockCheckMesh(t, m, "restored")
+	conf, _ := m.getFile("restored", 1, "/etc/aerospike/aerospike.conf")
+	if !strings.Contains(conf, "cluster-name restored") {
+		t.Errorf("cluster name not set:\n%s", conf)
+	}
+	if _, ok := m.getFile("restored", 2, "/etc/aerospike/features.conf"); !ok {
+		t.Error("features file not restored")
+	}
+	inv, _ := m.Inventory("", []int{InventoryItemClusters})
+	for _, item := range inv.Clusters {
+		if item.ClusterName == "restored" && item.dockerLabels["team"] != "db" {
+			t.Errorf("node %s: labels not restored: %v", item.NodeNo, item.dockerLabels)
+		}
+	}
+	asrestore := false
+	for _, call := range m.getCalls()[before:] {
+		if call.Method == "CopyFilesToCluster" && call.FilePath == "/opt/aerolab-snapshot-snap1.tgz" && call.FileContents != archive {
+			t.Error("uploaded data archive does not match")
+		}
+		if len(call.Command) == 3 && strings.Contains(call.Command[2], "asrestore -h 127.0.0.1 -i /opt/aerolab-snapshot-snap1/test.asb") {
+			asrestore = true
+		}
+	}
+	if !asrestore {
+		t.Error("asrestore not run")
+	}
+
+	mockRun(t, "cluster", "snapshot", "delete", "-s", "snap1")
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("snapshot not deleted: %v", err)
+	}
+}
+
+func TestClusterSnapshotStoragePaths(t *testing.T) {
+	conf := `logging {
+	file /var/log/aerospike.log {
+		context any info
+	}
+}
+namespace test {
+	storage-engine device {
+		device /dev/nvme0n1p1 /dev/sdb1 # with shadow device
+		file /opt/aerospike/data/test.dat
+		write-block-size 128K
+	}
+}
+namespace bar {
+	storage-engine memory
+}
+`
+	paths := clusterSnapshotStoragePaths(conf)
+	if !reflect.DeepEqual(paths, []string{"/dev/nvme0n1p1", "/opt/aerospike/data/test.dat"}) {
+		t.Errorf("unexpected storage paths %v", paths)
+	}
+}
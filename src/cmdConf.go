@@ -10,6 +10,8 @@ type confCmd struct {
 	RackID          confRackIdCmd          `command:"rackid" subcommands-optional:"true" description:"Change/add rack-id to namespaces in the existing cluster nodes"`
 	NamespaceMemory confNamespaceMemoryCmd `command:"namespace-memory" subcommands-optional:"true" description:"Adjust memory for a namespace using total percentages"`
 	Adjust          confAdjustCmd          `command:"adjust" subcommands-optional:"true" description:"Adjust running Aerospike configuration parameters"`
+	Diff            confDiffCmd            `command:"diff" subcommands-optional:"true" description:"Show configuration differences between nodes, and between running configuration and configuration files"`
+	Validate        confValidateCmd        `command:"validate" subcommands-optional:"true" description:"Validate an aerospike.conf file for a given server version"`
 	Help            helpCmd                `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
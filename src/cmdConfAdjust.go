@@ -17,6 +17,7 @@ type confAdjustCmd struct {
 	ClusterName TypeClusterName `short:"n" long:"name" description:"Cluster name" default:"mydc"`
 	Nodes       TypeNodes       `short:"l" long:"nodes" description:"Nodes list, comma separated. Empty=ALL" default:""`
 	Path        string          `short:"p" long:"path" description:"Path to aerospike.conf on the remote nodes" default:"/etc/aerospike/aerospike.conf"`
+	NoValidate  bool            `long:"no-validate" description:"Do not validate the resulting configuration file on set"`
 	parallelThreadsCmd
 }
 
@@ -171,6 +172,17 @@ func (c *confAdjustCmd) Execute(args []string) error {
 				return err
 			}
 			contents := buf.Bytes()
+			if command == "set" && !c.NoValidate {
+				version := ""
+				out, err := b.RunCommands(c.ClusterName.String(), [][]string{{"cat", "/opt/aerolab.aerospike.version"}}, []int{node})
+				if err == nil && len(out) > 0 {
+					version = strings.TrimSpace(string(out[0]))
+				}
+				err = validateAerospikeConf(fmt.Sprintf("node %d", node), contents, &confValidator{version: version})
+				if err != nil {
+					return err
+				}
+			}
 			fileContents = bytes.NewReader(contents)
 			// edit end
 			err = b.CopyFilesToClusterReader(c.ClusterName.String(), []fileListReader{{filePath: c.Path, fileContents: fileContents, fileSize: len(contents)}}, []int{node})
@@ -201,6 +213,7 @@ func (c *confAdjustCmd) help() {
 	-n, --name=  Cluster name (default: mydc)
 	-l, --nodes= Nodes list, comma separated. Empty=ALL
 	-p, --path=  Path to aerospike configuration file (default: /etc/aerospike/aerospike.conf)
+	--no-validate Do not validate the resulting configuration file on set
 	 --threads=  Number of parallel threads to run on (default: 50)`)
 	fmt.Println("\n" + `COMMANDS:
 	get    - get configuration/stanza and print to stdout
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aerospike/aerolab/diff"
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/jedib0t/go-pretty/v6/table"
+	aeroconf "github.com/rglonek/aerospike-config-file-parser"
+)
+
+type confDiffCmd struct {
+	ClusterName TypeClusterName `short:"n" long:"name" description:"Cluster name" default:"mydc"`
+	Nodes       TypeNodes       `short:"l" long:"nodes" description:"Nodes list, comma separated. Empty=ALL" default:""`
+	Path        string          `short:"p" long:"path" description:"Path to aerospike.conf on the remote nodes" default:"/etc/aerospike/aerospike.conf"`
+	Runtime     bool            `short:"r" long:"runtime" description:"Highlight parameters for which the running configuration differs from the configuration file"`
+	Files       bool            `short:"f" long:"files" description:"Also print a unified diff of each node's configuration file against the first node"`
+	Json        bool            `short:"j" long:"json" description:"Provide output in json format"`
+	JsonPretty  bool            `long:"pretty" description:"Provide json output with line-feeds and indentations"`
+	FailOnDrift bool            `long:"fail-on-drift" description:"Exit with an error if any differences are found, useful for CI"`
+	Ignore      []string        `short:"i" long:"ignore" description:"Ignore parameters starting with this path, ex: network.service.access-address; can be specified multiple times"`
+	parallelThreadsCmd
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+// confDiffParam is a parameter whose value differs between nodes; nodes without the parameter are not present in Values
+type confDiffParam struct {
+	Parameter string
+	Values    map[int]string
+}
+
+// confDiffMismatch is a parameter whose running value differs from the configuration file on a node
+type confDiffMismatch struct {
+	Node      int
+	Parameter string
+	File      string
+	Runtime   string
+}
+
+type confDiffResult struct {
+	ClusterName string
+	Nodes       []int
+	File        []*confDiffParam
+	Runtime     []*confDiffParam
+	Mismatches  []*confDiffMismatch `json:",omitempty"`
+	FileDiffs   map[int]string      `json:",omitempty"`
+	Errors      map[int]string      `json:",omitempty"`
+}
+
+type confDiffNode struct {
+	conf    []byte
+	file    map[string]string
+	runtime map[string]string
+}
+
+func (c *confDiffCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	if c.JsonPretty {
+		c.Json = true
+	}
+	if !c.Json {
+		log.Print("Running conf.diff")
+	}
+	err := c.Nodes.ExpandNodes(c.ClusterName.String())
+	if err != nil {
+		return err
+	}
+	nodes, err := c.Nodes.Translate(c.ClusterName.String())
+	if err != nil {
+		return err
+	}
+	sort.Ints(nodes)
+	result, err := c.diff(nodes)
+	if err != nil {
+		return err
+	}
+
+	if c.Json {
+		enc := json.NewEncoder(os.Stdout)
+		if c.JsonPretty {
+			enc.SetIndent("", "    ")
+		}
+		err = enc.Encode(result)
+		if err != nil {
+			return err
+		}
+	} else {
+		c.print(result)
+	}
+	if c.FailOnDrift && (len(result.File) > 0 || len(result.Runtime) > 0 || len(result.Mismatches) > 0) {
+		return fmt.Errorf("configuration drift found: %d file parameters, %d runtime parameters, %d runtime-vs-file mismatches", len(result.File), len(result.Runtime), len(result.Mismatches))
+	}
+	if !c.Json {
+		log.Print("Done")
+	}
+	return nil
+}
+
+// diff gathers configuration files and running configuration from the nodes and compares them
+func (c *confDiffCmd) diff(nodes []int) (*confDiffResult, error) {
+	result := &confDiffResult{
+		ClusterName: c.ClusterName.String(),
+		Nodes:       nodes,
+		Errors:      make(map[int]string),
+	}
+	data := make(map[int]*confDiffNode)
+	lock := new(sync.Mutex)
+	returns := parallelize.MapLimit(nodes, c.ParallelThreads, func(node int) error {
+		out, err := b.RunCommands(c.ClusterName.String(), [][]string{{"cat", c.Path}}, []int{node})
+		if err != nil {
+			return fmt.Errorf("could not read %s: %s", c.Path, err)
+		}
+		s, err := aeroconf.Parse(bytes.NewReader(out[0]))
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %s", c.Path, err)
+		}
+		n := &confDiffNode{
+			conf:    out[0],
+			file:    make(map[string]string),
+			runtime: make(map[string]string),
+		}
+		confDiffFlatten(s, "", n.file)
+		runtimeErr := c.getRuntime(node, n.runtime)
+		lock.Lock()
+		defer lock.Unlock()
+		data[node] = n
+		if runtimeErr != nil {
+			result.Errors[node] = "could not get running configuration: " + runtimeErr.Error()
+		}
+		return nil
+	})
+	for i, ret := range returns {
+		if ret != nil {
+			result.Errors[nodes[i]] = ret.Error()
+		}
+	}
+	if len(data) == 0 {
+		for _, node := range nodes {
+			log.Printf("Node %d returned %s", node, result.Errors[node])
+		}
+		return nil, errors.New("could not get configuration from any node")
+	}
+
+	files := make(map[int]map[string]string)
+	runtimes := make(map[int]map[string]string)
+	for node, n := range data {
+		files[node] = n.file
+		if _, ok := result.Errors[node]; !ok {
+			runtimes[node] = n.runtime
+		}
+	}
+	ignore := append(append([]string{}, confDiffNodeSpecific...), c.Ignore...)
+	result.File = confDiffParams(files, ignore)
+	result.Runtime = confDiffParams(runtimes, ignore)
+	if c.Runtime {
+		for _, node := range nodes {
+			if _, ok := runtimes[node]; ok {
+				result.Mismatches = append(result.Mismatches, confDiffRuntimeMismatches(node, data[node].file, data[node].runtime)...)
+			}
+		}
+	}
+	if c.Files {
+		result.FileDiffs = make(map[int]string)
+		first := -1
+		for _, node := range nodes {
+			if data[node] == nil {
+				continue
+			}
+			if first == -1 {
+				first = node
+				continue
+			}
+			d := diff.Diff(fmt.Sprintf("node-%d", first), data[first].conf, fmt.Sprintf("node-%d", node), data[node].conf)
+			if d != nil {
+				result.FileDiffs[node] = string(d)
+			}
+		}
+	}
+	return result, nil
+}
+
+// getRuntime fills the running configuration of a node, using configuration file style keys, ex: network.heartbeat.mode, namespace test.replication-factor
+func (c *confDiffCmd) getRuntime(node int, runtime map[string]string) error {
+	for _, context := range []string{"service", "network"} {
+		config, err := aerospikeRollingInfo(c.ClusterName.String(), node, "get-config:context="+context)
+		if err != nil {
+			return err
+		}
+		for k, v := range config {
+			runtime[context+"."+k] = v
+		}
+	}
+	namespaces, err := aerospikeRollingInfoRaw(c.ClusterName.String(), node, "namespaces")
+	if err != nil {
+		return err
+	}
+	sep := "\\;"
+	if a.opts.Config.Backend.Type == "docker" {
+		sep = ";"
+	}
+	for _, ns := range strings.Split(namespaces, ";") {
+		if ns == "" {
+			continue
+		}
+		config, err := aerospikeRollingInfo(c.ClusterName.String(), node, "get-config:context=namespace"+sep+"id="+ns)
+		if err != nil {
+			return err
+		}
+		for k, v := range config {
+			runtime["namespace "+ns+"."+k] = v
+		}
+	}
+	return nil
+}
+
+func (c *confDiffCmd) print(result *confDiffResult) {
+	for _, node := range result.Nodes {
+		if msg, ok := result.Errors[node]; ok {
+			log.Printf("WARNING: node %d: %s", node, msg)
+		}
+	}
+	header := table.Row{"Parameter"}
+	for _, node := range result.Nodes {
+		header = append(header, fmt.Sprintf("Node %d", node))
+	}
+	for _, section := range []struct {
+		title  string
+		params []*confDiffParam
+	}{
+		{"Configuration file differences between nodes", result.File},
+		{"Running configuration differences between nodes", result.Runtime},
+	} {
+		if len(section.params) == 0 {
+			fmt.Printf("%s: none\n\n", section.title)
+			continue
+		}
+		t := table.NewWriter()
+		t.SetStyle(table.StyleDefault)
+		t.SetTitle(section.title)
+		t.AppendHeader(header)
+		for _, param := range section.params {
+			row := table.Row{param.Parameter}
+			for _, node := range result.Nodes {
+				val, ok := param.Values[node]
+				if !ok {
+					val = "-"
+				}
+				row = append(row, val)
+			}
+			t.AppendRow(row)
+		}
+		fmt.Println(t.Render())
+		fmt.Println()
+	}
+	if c.Runtime {
+		if len(result.Mismatches) == 0 {
+			fmt.Printf("Running configuration vs configuration file mismatches: none\n\n")
+		} else {
+			t := table.NewWriter()
+			t.SetStyle(table.StyleDefault)
+			t.SetTitle("Running configuration vs configuration file mismatches")
+			t.AppendHeader(table.Row{"Node", "Parameter", "File", "Runtime"})
+			for _, m := range result.Mismatches {
+				t.AppendRow(table.Row{m.Node, m.Parameter, m.File, m.Runtime})
+			}
+			fmt.Println(t.Render())
+			fmt.Println()
+		}
+	}
+	for _, node := range result.Nodes {
+		if d, ok := result.FileDiffs[node]; ok {
+			fmt.Println(d)
+		}
+	}
+}
+
+// confDiffFlatten flattens a parsed configuration into path => value, joining multiple values of the same parameter with ", "
+func confDiffFlatten(s aeroconf.Stanza, path string, out map[string]string) {
+	for _, key := range s.ListKeys() {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+		if s.Type(key) == aeroconf.ValueStanza {
+			confDiffFlatten(s.Stanza(key), keyPath, out)
+			continue
+		}
+		values, err := s.GetValues(key)
+		if err != nil {
+			continue
+		}
+		vals := []string{}
+		for _, v := range values {
+			if v == nil {
+				vals = append(vals, "")
+			} else {
+				vals = append(vals, *v)
+			}
+		}
+		out[keyPath] = strings.Join(vals, ", ")
+	}
+}
+
+// running configuration parameters which are expected to differ between nodes
+var confDiffNodeSpecific = []string{"service.node-id"}
+
+// confDiffParams returns the parameters whose values are not the same on all nodes, skipping ignored parameters
+func confDiffParams(nodes map[int]map[string]string, ignore []string) []*confDiffParam {
+	keys := make(map[string]bool)
+	for _, params := range nodes {
+	nextKey:
+		for k := range params {
+			for _, prefix := range ignore {
+				if strings.HasPrefix(k, prefix) {
+					continue nextKey
+				}
+			}
+			keys[k] = true
+		}
+	}
+	ret := []*confDiffParam{}
+	for key := range keys {
+		param := &confDiffParam{Parameter: key, Values: make(map[int]string)}
+		first := true
+		firstVal := ""
+		firstOk := false
+		differs := false
+		for node, params := range nodes {
+			val, ok := params[key]
+			if ok {
+				param.Values[node] = val
+			}
+			if first {
+				firstVal, firstOk = val, ok
+				first = false
+			} else if val != firstVal || ok != firstOk {
+				differs = true
+			}
+		}
+		if differs {
+			ret = append(ret, param)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Parameter < ret[j].Parameter
+	})
+	return ret
+}
+
+// confDiffRuntimeMismatches compares parameters set in the configuration file with their running values; parameters with multiple values or without a running value are skipped
+func confDiffRuntimeMismatches(node int, file map[string]string, runtime map[string]string) []*confDiffMismatch {
+	ret := []*confDiffMismatch{}
+	for key, fileVal := range file {
+		if strings.Contains(fileVal, ", ") {
+			continue
+		}
+		rtKey := confDiffRuntimeKey(key)
+		rtVal, ok := runtime[rtKey]
+		if !ok {
+			continue
+		}
+		if !confDiffSameValue(fileVal, rtVal) {
+			ret = append(ret, &confDiffMismatch{Node: node, Parameter: key, File: fileVal, Runtime: rtVal})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Parameter < ret[j].Parameter
+	})
+	return ret
+}
+
+var confDiffStorageEngineRegex = regexp.MustCompile(`\.storage-engine [^.]+\.`)
+
+// confDiffRuntimeKey maps a configuration file path to the key of the running configuration, ex: namespace test.storage-engine device.write-block-size => namespace test.storage-engine.write-block-size
+func confDiffRuntimeKey(key string) string {
+	return confDiffStorageEngineRegex.ReplaceAllString(key, ".storage-engine.")
+}
+
+var confDiffTimeRegex = regexp.MustCompile(`^([0-9]+)([smhd])$`)
+var confDiffSizeRegex = regexp.MustCompile(`^[0-9]+[KMGT]$`)
+
+// confDiffSameValue compares a configuration file value with a running value, which is expressed in bytes and seconds
+func confDiffSameValue(fileVal string, rtVal string) bool {
+	if strings.EqualFold(fileVal, rtVal) {
+		return true
+	}
+	if confDiffSizeRegex.MatchString(fileVal) {
+		size, err := confValidateParseSize(fileVal)
+		return err == nil && strconv.FormatInt(size, 10) == rtVal
+	}
+	if m := confDiffTimeRegex.FindStringSubmatch(fileVal); m != nil {
+		no, _ := strconv.ParseInt(m[1], 10, 64)
+		mult := map[string]int64{"s": 1, "m": 60, "h": 3600, "d": 86400}[m[2]]
+		return strconv.FormatInt(no*mult, 10) == rtVal
+	}
+	return false
+}
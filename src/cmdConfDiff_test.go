@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMockConfDiff(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "mydc", "2")
+	mockRun(t, "conf", "adjust", "-n", "mydc", "-l", "2", "set", "namespace test.replication-factor", "3")
+
+	m.setResponse("mydc", 1, []string{"asinfo", "-v", "get-config:context=service"}, "proto-fd-max=15000;node-id=BB9020011AC4202\n", nil)
+	m.setResponse("mydc", 2, []string{"asinfo", "-v", "get-config:context=service"}, "proto-fd-max=20000;node-id=BB9030011AC4202\n", nil)
+	m.setResponse("mydc", 0, []string{"asinfo", "-v", "get-config:context=network"}, "heartbeat.mode=mesh;heartbeat.interval=150;service.port=3000\n", nil)
+	m.setResponse("mydc", 0, []string{"asinfo", "-v", "namespaces"}, "test\n", nil)
+	m.setResponse("mydc", 1, []string{"asinfo", "-v", "get-config:context=namespace\\;id=test"}, "replication-factor=2;storage-engine.data-size=4294967296\n", nil)
+	// node 2 was not restarted after the change, and proto-fd-max was changed at runtime
+	m.setResponse("mydc", 2, []string{"asinfo", "-v", "get-config:context=namespace\\;id=test"}, "replication-factor=2;storage-engine.data-size=4294967296\n", nil)
+
+	c := &confDiffCmd{ClusterName: "mydc", Path: "/etc/aerospike/aerospike.conf", Runtime: true, Files: true}
+	c.ParallelThreads = 2
+	result, err := c.diff([]int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", result.Errors)
+	}
+	if len(result.File) != 1 || result.File[0].Parameter != "namespace test.replication-factor" || !reflect.DeepEqual(result.File[0].Values, map[int]string{1: "2", 2: "3"}) {
+		t.Errorf("unexpected file differences: %+v", result.File)
+	}
+	if len(result.Runtime) != 1 || result.Runtime[0].Parameter != "service.proto-fd-max" {
+		t.Errorf("unexpected runtime differences, node-id must be ignored: %+v", result.Runtime)
+	}
+	expected := []confDiffMismatch{{Node: 2, Parameter: "namespace test.replication-factor", File: "3", Runtime: "2"}, {Node: 2, Parameter: "service.proto-fd-max", File: "15000", Runtime: "20000"}}
+	if len(result.Mismatches) != len(expected) {
+		t.Errorf("expected %d runtime mismatches, got %d", len(expected), len(result.Mismatches))
+	}
+	for i := range result.Mismatches {
+		if i < len(expected) && *result.Mismatches[i] != expected[i] {
+			t.Errorf("unexpected runtime mismatch: %+v", *result.Mismatches[i])
+		}
+	}
+	if d, ok := result.FileDiffs[2]; !ok || !strings.Contains(d, "+    replication-factor 3") {
+		t.Errorf("unexpected file diff: %q", d)
+	}
+
+	c.Ignore = []string{"namespace test.replication", "service.proto"}
+	result, err = c.diff([]int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.File) != 0 || len(result.Runtime) != 0 {
+		t.Errorf("ignored parameters reported: %+v %+v", result.File, result.Runtime)
+	}
+
+	(&restCmd{}).resetBools()
+	err = a.main("aerolab", []string{"conf", "diff", "-n", "mydc", "-j", "--fail-on-drift"})
+	if err == nil || !strings.Contains(err.Error(), "configuration drift found") {
+		t.Errorf("expected drift error, got %v", err)
+	}
+}
+
+func TestConfDiffSameValue(t *testing.T) {
+	tests := []struct {
+		file    string
+		runtime string
+		same    bool
+	}{
+		{"4G", "4294967296", true},
+		{"128K", "131072", true},
+		{"4G", "4294967295", false},
+		{"30d", "2592000", true},
+		{"10m", "600", true},
+		{"true", "True", true},
+		{"mesh", "multicast", false},
+		{"100", "100", true},
+	}
+	for _, test := range tests {
+		if same := confDiffSameValue(test.file, test.runtime); same != test.same {
+			t.Errorf("%s vs %s: expected %t, got %t", test.file, test.runtime, test.same, same)
+		}
+	}
+	if key := confDiffRuntimeKey("namespace test.storage-engine device.write-block-size"); key != "namespace test.storage-engine.write-block-size" {
+		t.Errorf("unexpected runtime key %s", key)
+	}
+}
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bestmethod/inslice"
+	"github.com/jedib0t/go-pretty/v6/table"
+	aeroconf "github.com/rglonek/aerospike-config-file-parser"
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+type confValidateCmd struct {
+	File     flags.Filename `short:"f" long:"file" description:"Path to aerospike.conf to validate" default:"aerospike.conf"`
+	Version  string         `short:"v" long:"aerospike-version" description:"Target aerospike server version, ex: 7.1.0; default: latest"`
+	Memory   string         `short:"m" long:"memory" description:"RAM available on each node, ex: 16G; if set, namespace memory totals are checked against it"`
+	Warnings bool           `short:"w" long:"fail-on-warnings" description:"Exit with an error if there are warnings"`
+	Help     helpCmd        `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *confValidateCmd) Execute(args []string) error {
+	if earlyProcessNoBackend(args) {
+		return nil
+	}
+	log.Print("Running conf.validate")
+	conf, err := os.ReadFile(string(c.File))
+	if err != nil {
+		return err
+	}
+	v := &confValidator{version: c.Version}
+	if c.Memory != "" {
+		v.memory, err = confValidateParseSize(c.Memory)
+		if err != nil {
+			return fmt.Errorf("invalid memory size %s: %s", c.Memory, err)
+		}
+	}
+	findings := v.validate(conf)
+	printConfValidateFindings(findings)
+	errs, warns := confValidateCount(findings)
+	if errs > 0 || (c.Warnings && warns > 0) {
+		return fmt.Errorf("%s: %d errors, %d warnings", c.File, errs, warns)
+	}
+	log.Printf("%s: %d errors, %d warnings", c.File, errs, warns)
+	log.Print("Done")
+	return nil
+}
+
+const (
+	confValidateError   = "ERROR"
+	confValidateWarning = "WARNING"
+)
+
+type confValidateFinding struct {
+	Level   string
+	Path    string
+	Message string
+}
+
+// confValidator checks an aerospike.conf for the given server version
+type confValidator struct {
+	version       string // target server version, empty for latest
+	memory        int64  // RAM available on each node in bytes, 0 if unknown
+	skipHeartbeat bool   // heartbeat configuration will be rewritten by aerolab, do not check it
+	findings      []*confValidateFinding
+}
+
+// confValidateParam describes a parameter which was added or removed in a given server version
+type confValidateParam struct {
+	added   string
+	removed string
+	hint    string
+}
+
+// version specific parameters, per context; the context of a namespace is "namespace", of a storage-engine is "storage-engine"
+var confValidateParams = map[string]map[string]confValidateParam{
+	"service": {
+		"enable-xdr": {removed: "5.0", hint: "configure the xdr stanza instead"},
+	},
+	"namespace": {
+		"memory-size":                {removed: "7.0", hint: "use storage-engine memory data-size and indexes-memory-budget"},
+		"single-bin":                 {removed: "7.0"},
+		"data-in-index":              {removed: "7.0"},
+		"high-water-memory-pct":      {removed: "7.0", hint: "use evict-sys-memory-pct or storage-engine evict-used-pct"},
+		"high-water-disk-pct":        {removed: "7.0", hint: "use storage-engine evict-used-pct"},
+		"stop-writes-pct":            {removed: "7.0", hint: "use stop-writes-sys-memory-pct or storage-engine stop-writes-used-pct"},
+		"enable-xdr":                 {removed: "5.0", hint: "configure the xdr stanza instead"},
+		"xdr-remote-datacenter":      {removed: "5.0", hint: "configure the xdr stanza instead"},
+		"indexes-memory-budget":      {added: "7.0"},
+		"evict-sys-memory-pct":       {added: "7.0"},
+		"stop-writes-sys-memory-pct": {added: "7.0"},
+	},
+	"storage-engine": {
+		"data-in-memory":        {removed: "7.0", hint: "use storage-engine memory with a device or file instead"},
+		"max-used-pct":          {removed: "7.0", hint: "use stop-writes-used-pct"},
+		"min-avail-pct":         {removed: "7.0", hint: "use stop-writes-avail-pct"},
+		"data-size":             {added: "7.0"},
+		"evict-used-pct":        {added: "7.0"},
+		"stop-writes-used-pct":  {added: "7.0"},
+		"stop-writes-avail-pct": {added: "7.0"},
+	},
+	"xdr": {
+		"xdr-digestlog-path": {removed: "5.0"},
+		"enable-xdr":         {removed: "5.0"},
+	},
+}
+
+// known parameters per context, used to report unknown parameters; contexts not listed here are not checked
+var confValidateKnown = map[string][]string{
+	"service":           {"advertise-ipv6", "auto-pin", "batch-index-threads", "batch-max-buffers-per-queue", "batch-max-requests", "batch-max-unused-buffers", "cluster-name", "debug-allocations", "disable-udf-execution", "enable-benchmarks-fabric", "enable-health-check", "enable-hist-info", "enforce-best-practices", "feature-key-file", "group", "indent-allocations", "info-max-ms", "info-threads", "keep-caps-ssd-health", "log-local-time", "log-millis", "microsecond-histograms", "migrate-fill-delay", "migrate-max-num-incoming", "migrate-threads", "min-cluster-size", "node-id", "node-id-interface", "os-group-perms", "paxos-single-replica-limit", "pidfile", "poison-allocations", "proto-fd-idle-ms", "proto-fd-max", "quarantine-allocations", "query-max-done", "query-threads-limit", "run-as-daemon", "secrets-address-port", "secrets-tls-context", "secrets-uds-path", "service-threads", "sindex-builder-threads", "sindex-gc-period", "stay-quiesced", "ticker-interval", "tls-refresh-period", "transaction-max-ms", "transaction-queues", "transaction-retry-ms", "transaction-threads-per-queue", "user", "vault-ca", "vault-namespace", "vault-path", "vault-token-file", "vault-url", "work-directory", "enable-xdr"},
+	"network.service":   {"access-address", "access-port", "address", "alternate-access-address", "alternate-access-port", "disable-localhost", "port", "tls-access-address", "tls-access-port", "tls-address", "tls-alternate-access-address", "tls-alternate-access-port", "tls-authenticate-client", "tls-name", "tls-port"},
+	"network.heartbeat": {"address", "connect-timeout-ms", "interval", "mesh-seed-address-port", "mode", "mtu", "multicast-group", "multicast-ttl", "port", "protocol", "timeout", "tls-address", "tls-mesh-seed-address-port", "tls-name", "tls-port"},
+	"network.fabric":    {"address", "channel-bulk-fds", "channel-bulk-recv-threads", "channel-ctrl-fds", "channel-ctrl-recv-threads", "channel-meta-fds", "channel-meta-recv-threads", "channel-rw-fds", "channel-rw-recv-pools", "channel-rw-recv-threads", "keepalive-enabled", "keepalive-intvl", "keepalive-probes", "keepalive-time", "latency-max-ms", "port", "recv-rearm-threshold", "send-threads", "tls-address", "tls-name", "tls-port"},
+	"network.info":      {"address", "port"},
+	"namespace":         {"active-rack", "allow-ttl-without-nsup", "background-query-max-rps", "conflict-resolution-policy", "conflict-resolve-writes", "data-in-index", "default-read-touch-ttl-pct", "default-ttl", "disable-cold-start-eviction", "disable-write-dup-res", "disallow-expunge", "disallow-null-setname", "enable-benchmarks-batch-sub", "enable-benchmarks-ops-sub", "enable-benchmarks-read", "enable-benchmarks-udf", "enable-benchmarks-udf-sub", "enable-benchmarks-write", "enable-hist-proxy", "enable-xdr", "evict-hist-buckets", "evict-indexes-memory-pct", "evict-sys-memory-pct", "evict-tenths-pct", "high-water-disk-pct", "high-water-memory-pct", "ignore-migrate-fill-delay", "index-stage-size", "indexes-memory-budget", "inline-short-queries", "max-record-size", "memory-size", "migrate-order", "migrate-retransmit-ms", "migrate-sleep", "nsup-hist-period", "nsup-period", "nsup-threads", "partition-tree-sprigs", "prefer-uniform-balance", "rack-id", "read-consistency-level-override", "reject-non-xdr-writes", "reject-xdr-writes", "replication-factor", "sindex-stage-size", "single-bin", "single-query-threads", "stop-writes-pct", "storage-engine", "stop-writes-sys-memory-pct", "strong-consistency", "strong-consistency-allow-expunge", "tomb-raider-eligible-age", "tomb-raider-period", "transaction-pending-limit", "truncate-threads", "write-commit-level-override", "xdr-bin-tombstone-ttl", "xdr-remote-datacenter", "xdr-tomb-raider-period", "xdr-tomb-raider-threads"},
+	"storage-engine":    {"cache-replica-writes", "cold-start-empty", "commit-min-size", "commit-to-device", "compression", "compression-acceleration", "compression-level", "data-in-memory", "data-size", "defrag-lwm-pct", "defrag-queue-min", "defrag-sleep", "defrag-startup-minimum", "device", "direct-files", "disable-odsync", "enable-benchmarks-storage", "encryption", "encryption-key-file", "encryption-old-key-file", "evict-used-pct", "file", "filesize", "flush-max-ms", "flush-size", "max-used-pct", "max-write-cache", "min-avail-pct", "post-write-cache", "post-write-queue", "read-page-cache", "scheduler-mode", "serialize-tomb-raider", "sindex-startup-device-scan", "stop-writes-avail-pct", "stop-writes-used-pct", "tomb-raider-sleep", "write-block-size"},
+}
+
+var confValidateTopStanzas = []string{"service", "logging", "network", "namespace", "security", "xdr", "mod-lua"}
+
+func (v *confValidator) add(level string, path string, format string, args ...interface{}) {
+	v.findings = append(v.findings, &confValidateFinding{Level: level, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// atLeast returns true if the target version is the same or newer than the given one
+func (v *confValidator) atLeast(version string) bool {
+	if v.version == "" || strings.HasPrefix(v.version, "latest") {
+		return true
+	}
+	ver := strings.TrimSuffix(strings.TrimSuffix(v.version, "c"), "f")
+	return VersionCheck(ver, version) <= 0
+}
+
+// validate runs all checks and returns the findings
+func (v *confValidator) validate(conf []byte) []*confValidateFinding {
+	v.findings = nil
+	if !v.checkSyntax(conf) {
+		return v.findings
+	}
+	s, err := aeroconf.Parse(bytes.NewReader(conf))
+	if err != nil {
+		v.add(confValidateError, "", "could not parse: %s", err)
+		return v.findings
+	}
+	for _, key := range confValidateSortedKeys(s) {
+		if !inslice.HasString(confValidateTopStanzas, strings.Fields(key)[0]) {
+			v.add(confValidateError, key, "unknown top-level configuration item")
+		}
+	}
+	v.checkParams(s, "")
+	if s.Type("service") != aeroconf.ValueStanza {
+		v.add(confValidateError, "service", "service stanza is missing")
+	}
+	if s.Type("network") != aeroconf.ValueStanza {
+		v.add(confValidateError, "network", "network stanza is missing")
+	} else {
+		if s.Stanza("network").Type("service") != aeroconf.ValueStanza {
+			v.add(confValidateError, "network.service", "network service stanza is missing")
+		}
+		v.checkHeartbeat(s.Stanza("network"))
+	}
+	v.checkNamespaces(s)
+	sort.SliceStable(v.findings, func(i, j int) bool {
+		return v.findings[i].Level == confValidateError && v.findings[j].Level != confValidateError
+	})
+	return v.findings
+}
+
+// checkSyntax checks stanza braces, as the parser silently ignores unbalanced ones
+func (v *confValidator) checkSyntax(conf []byte) bool {
+	depth := 0
+	ok := true
+	for i, line := range strings.Split(string(conf), "\n") {
+		line = strings.TrimSpace(strings.Split(line, "#")[0])
+		switch {
+		case line == "":
+		case line == "}":
+			depth--
+			if depth < 0 {
+				v.add(confValidateError, "", "line %d: unexpected '}'", i+1)
+				ok = false
+				depth = 0
+			}
+		case strings.HasSuffix(line, "{"):
+			if strings.TrimSpace(strings.TrimSuffix(line, "{")) == "" {
+				v.add(confValidateError, "", "line %d: stanza without a name", i+1)
+				ok = false
+			}
+			depth++
+		case strings.ContainsAny(line, "{}"):
+			v.add(confValidateError, "", "line %d: braces must be on their own, or at the end of the stanza name line: %s", i+1, line)
+			ok = false
+		}
+	}
+	if depth > 0 {
+		v.add(confValidateError, "", "%d stanzas not closed, missing '}'", depth)
+		ok = false
+	}
+	return ok
+}
+
+// confValidateContext returns the parameter context of a stanza path, ex: namespace test.storage-engine device => storage-engine
+func confValidateContext(path string) string {
+	parts := strings.Split(path, ".")
+	last := strings.Fields(parts[len(parts)-1])
+	if len(last) == 0 {
+		return ""
+	}
+	switch last[0] {
+	case "namespace", "storage-engine":
+		if len(parts) == 1 || last[0] == "storage-engine" {
+			return last[0]
+		}
+	case "service", "heartbeat", "fabric", "info":
+		if len(parts) <= 2 {
+			return path
+		}
+	case "xdr":
+		return "xdr"
+	}
+	return ""
+}
+
+// checkParams walks the configuration, reporting version specific and unknown parameters
+func (v *confValidator) checkParams(s aeroconf.Stanza, path string) {
+	context := confValidateContext(path)
+	for _, key := range confValidateSortedKeys(s) {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+		if s.Type(key) == aeroconf.ValueStanza {
+			v.checkParams(s.Stanza(key), keyPath)
+			continue
+		}
+		if param, ok := confValidateParams[context][key]; ok {
+			hint := ""
+			if param.hint != "" {
+				hint = "; " + param.hint
+			}
+			if param.removed != "" && v.atLeast(param.removed) {
+				v.add(confValidateError, keyPath, "parameter removed in server %s%s", param.removed, hint)
+			}
+			if param.added != "" && !v.atLeast(param.added) {
+				v.add(confValidateError, keyPath, "parameter requires server %s or later%s", param.added, hint)
+			}
+			continue
+		}
+		if known, ok := confValidateKnown[context]; ok && !inslice.HasString(known, key) {
+			v.add(confValidateWarning, keyPath, "unknown parameter")
+		}
+	}
+}
+
+func (v *confValidator) checkHeartbeat(network aeroconf.Stanza) {
+	if v.skipHeartbeat {
+		return
+	}
+	hb := network.Stanza("heartbeat")
+	if hb == nil {
+		v.add(confValidateError, "network.heartbeat", "heartbeat stanza is missing")
+		return
+	}
+	if confValidateValue(hb, "port") == "" && confValidateValue(hb, "tls-port") == "" {
+		v.add(confValidateError, "network.heartbeat.port", "heartbeat port is not set")
+	}
+	hasSeeds := hb.Type("mesh-seed-address-port") != aeroconf.ValueNil || hb.Type("tls-mesh-seed-address-port") != aeroconf.ValueNil
+	hasGroup := hb.Type("multicast-group") != aeroconf.ValueNil
+	switch mode := confValidateValue(hb, "mode"); mode {
+	case "mesh":
+		if !hasSeeds {
+			v.add(confValidateError, "network.heartbeat", "mode mesh requires mesh-seed-address-port or tls-mesh-seed-address-port")
+		}
+		if hasGroup {
+			v.add(confValidateWarning, "network.heartbeat.multicast-group", "multicast-group is ignored in mesh mode")
+		}
+	case "multicast":
+		if !hasGroup {
+			v.add(confValidateError, "network.heartbeat", "mode multicast requires multicast-group")
+		}
+		if hasSeeds {
+			v.add(confValidateWarning, "network.heartbeat.mesh-seed-address-port", "mesh seeds are ignored in multicast mode")
+		}
+	case "":
+		v.add(confValidateError, "network.heartbeat.mode", "heartbeat mode is not set")
+	default:
+		v.add(confValidateError, "network.heartbeat.mode", "unknown heartbeat mode %q, must be mesh or multicast", mode)
+	}
+}
+
+func (v *confValidator) checkNamespaces(s aeroconf.Stanza) {
+	namespaces := []string{}
+	for _, key := range confValidateSortedKeys(s) {
+		if strings.HasPrefix(key, "namespace ") && s.Type(key) == aeroconf.ValueStanza {
+			namespaces = append(namespaces, key)
+		}
+	}
+	if len(namespaces) == 0 {
+		v.add(confValidateError, "", "no namespaces defined")
+		return
+	}
+	var totalMemory int64
+	usedPaths := make(map[string]string)
+	withRack := []string{}
+	for _, nsKey := range namespaces {
+		ns := s.Stanza(nsKey)
+		rf := 2
+		if val := confValidateValue(ns, "replication-factor"); val != "" {
+			var err error
+			rf, err = strconv.Atoi(val)
+			if err != nil || rf < 1 {
+				v.add(confValidateError, nsKey+".replication-factor", "invalid replication-factor %q", val)
+			}
+		}
+		if val := confValidateValue(ns, "rack-id"); val != "" {
+			withRack = append(withRack, nsKey)
+			rack, err := strconv.Atoi(val)
+			if err != nil || rack < 0 || rack > 1000000 {
+				v.add(confValidateError, nsKey+".rack-id", "rack-id must be a number between 0 and 1000000, got %q", val)
+			} else if rf == 1 {
+				v.add(confValidateWarning, nsKey+".rack-id", "rack-id has no effect with replication-factor 1")
+			}
+		}
+
+		engines := []string{}
+		for _, key := range ns.ListKeys() {
+			if strings.HasPrefix(key, "storage-engine") {
+				engines = append(engines, key)
+			}
+		}
+		if len(engines) == 0 {
+			v.add(confValidateError, nsKey, "storage-engine is not configured")
+			continue
+		}
+		if len(engines) > 1 {
+			v.add(confValidateError, nsKey, "multiple storage-engine definitions: %s", strings.Join(engines, ", "))
+			continue
+		}
+		engineKey := engines[0]
+		enginePath := nsKey + "." + engineKey
+		engineType := strings.TrimSpace(strings.TrimPrefix(engineKey, "storage-engine"))
+		if ns.Type(engineKey) == aeroconf.ValueString {
+			engineType, _ = confValidateValues(ns, engineKey)
+		}
+		engine := ns.Stanza(engineKey)
+		if engineType == "device" && engine == nil {
+			v.add(confValidateError, enginePath, "storage-engine device must be a {} stanza")
+			continue
+		}
+		if !inslice.HasString([]string{"memory", "device", "pmem"}, engineType) {
+			v.add(confValidateError, enginePath, "unknown storage-engine type %q", engineType)
+			continue
+		}
+		hasStorage := v.checkStoragePaths(engine, enginePath, usedPaths)
+		if engineType == "device" && !hasStorage {
+			v.add(confValidateError, enginePath, "storage-engine device requires at least one device or file")
+		}
+
+		// memory used by the namespace on each node
+		if v.atLeast("7.0") {
+			if engineType == "memory" {
+				size, ok := v.size(engine, enginePath, "data-size")
+				if !ok && !hasStorage {
+					v.add(confValidateError, enginePath, "storage-engine memory requires data-size, or devices or files")
+				}
+				totalMemory += size
+			}
+			size, _ := v.size(ns, nsKey, "indexes-memory-budget")
+			totalMemory += size
+		} else {
+			size, ok := v.size(ns, nsKey, "memory-size")
+			if !ok {
+				v.add(confValidateWarning, nsKey, "memory-size is not set, server default of 4G applies")
+				size = 4 * 1024 * 1024 * 1024
+			}
+			totalMemory += size
+		}
+	}
+	if len(withRack) > 0 && len(withRack) != len(namespaces) {
+		v.add(confValidateWarning, "", "rack-id is only set on some namespaces: %s", strings.Join(withRack, ", "))
+	}
+	if v.memory > 0 && totalMemory > v.memory {
+		v.add(confValidateError, "", "namespaces require %s of memory in total, but only %s is available", confValidateFormatSize(totalMemory), confValidateFormatSize(v.memory))
+	}
+}
+
+// checkStoragePaths checks device and file paths of a storage-engine, returns true if any are configured
+func (v *confValidator) checkStoragePaths(engine aeroconf.Stanza, enginePath string, usedPaths map[string]string) bool {
+	if engine == nil {
+		return false
+	}
+	found := false
+	for _, key := range []string{"device", "file"} {
+		values, _ := engine.GetValues(key)
+		for _, value := range values {
+			if value == nil {
+				v.add(confValidateError, enginePath+"."+key, "%s without a path", key)
+				continue
+			}
+			found = true
+			// devices may be followed by a shadow device
+			for _, p := range strings.Fields(*value) {
+				switch {
+				case !strings.HasPrefix(p, "/"):
+					v.add(confValidateError, enginePath+"."+key, "%s path must be absolute: %s", key, p)
+				case key == "device" && !strings.HasPrefix(p, "/dev/"):
+					v.add(confValidateWarning, enginePath+"."+key, "device %s is not under /dev/", p)
+				}
+				if prev, ok := usedPaths[p]; ok {
+					v.add(confValidateError, enginePath+"."+key, "%s is already used by %s", p, prev)
+				} else {
+					usedPaths[p] = enginePath
+				}
+			}
+		}
+		if key == "file" && len(values) > 0 {
+			if _, ok := v.size(engine, enginePath, "filesize"); !ok {
+				v.add(confValidateError, enginePath+".filesize", "filesize must be set when using files")
+			}
+		}
+	}
+	return found
+}
+
+// size parses a size parameter, reporting invalid values; returns false if the parameter is not set or invalid
+func (v *confValidator) size(s aeroconf.Stanza, path string, key string) (int64, bool) {
+	val := confValidateValue(s, key)
+	if val == "" {
+		return 0, false
+	}
+	size, err := confValidateParseSize(val)
+	if err != nil {
+		v.add(confValidateError, path+"."+key, "invalid size %q", val)
+		return 0, false
+	}
+	return size, true
+}
+
+// confValidateValue returns the first value of a parameter, or an empty string
+func confValidateValue(s aeroconf.Stanza, key string) string {
+	val, _ := confValidateValues(s, key)
+	return val
+}
+
+func confValidateValues(s aeroconf.Stanza, key string) (string, int) {
+	if s == nil {
+		return "", 0
+	}
+	values, err := s.GetValues(key)
+	if err != nil || len(values) == 0 || values[0] == nil {
+		return "", len(values)
+	}
+	return *values[0], len(values)
+}
+
+func confValidateSortedKeys(s aeroconf.Stanza) []string {
+	keys := s.ListKeys()
+	sort.Strings(keys)
+	return keys
+}
+
+// confValidateParseSize parses sizes such as 4G, 512M, 1T or 1024 into bytes
+func confValidateParseSize(size string) (int64, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for suffix, mult := range map[string]int64{"K": 1024, "M": 1024 * 1024, "G": 1024 * 1024 * 1024, "T": 1024 * 1024 * 1024 * 1024} {
+		if strings.HasSuffix(size, suffix) {
+			multiplier = mult
+			size = strings.TrimSuffix(size, suffix)
+			break
+		}
+	}
+	no, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if no < 0 {
+		return 0, errors.New("size cannot be negative")
+	}
+	return no * multiplier, nil
+}
+
+func confValidateFormatSize(size int64) string {
+	return fmt.Sprintf("%.1fG", float64(size)/1024/1024/1024)
+}
+
+func confValidateCount(findings []*confValidateFinding) (errs int, warns int) {
+	for _, f := range findings {
+		if f.Level == confValidateError {
+			errs++
+		} else {
+			warns++
+		}
+	}
+	return errs, warns
+}
+
+func printConfValidateFindings(findings []*confValidateFinding) {
+	if len(findings) == 0 {
+		return
+	}
+	t := table.NewWriter()
+	t.SetStyle(table.StyleDefault)
+	t.AppendHeader(table.Row{"Level", "Path", "Message"})
+	for _, f := range findings {
+		t.AppendRow(table.Row{f.Level, f.Path, f.Message})
+	}
+	fmt.Println(t.Render())
+}
+
+// validateAerospikeConf validates configuration file contents before pushing them to nodes, printing findings and returning an error if there are errors
+func validateAerospikeConf(name string, conf []byte, v *confValidator) error {
+	findings := v.validate(conf)
+	printConfValidateFindings(findings)
+	errs, warns := confValidateCount(findings)
+	if errs > 0 {
+		return fmt.Errorf("%s: configuration validation failed with %d errors and %d warnings; use --no-validate to skip validation", name, errs, warns)
+	}
+	return nil
+}
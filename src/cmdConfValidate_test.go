@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const confValidateTestNetwork = `service {
+	proto-fd-max 15000
+}
+network {
+	service {
+		port 3000
+	}
+	heartbeat {
+		mode mesh
+		mesh-seed-address-port 10.0.0.1 3002
+		port 3002
+	}
+	fabric {
+		port 3001
+	}
+}
+`
+
+// confValidateHas returns true if a finding of the given level contains the message
+func confValidateHas(findings []*confValidateFinding, level string, message string) bool {
+	for _, f := range findings {
+		if f.Level == level && strings.Contains(f.Path+" "+f.Message, message) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConfValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		validator *confValidator
+		conf      string
+		level     string
+		message   string
+	}{
+		{"unbalanced", &confValidator{}, confValidateTestNetwork + "namespace test {\n", confValidateError, "not closed"},
+		{"extra brace", &confValidator{}, confValidateTestNetwork + "}\n", confValidateError, "unexpected '}'"},
+		{"removed in 7", &confValidator{version: "7.1.0"}, confValidateTestNetwork + "namespace test {\n\tmemory-size 4G\n\tstorage-engine memory {\n\t\tdata-size 4G\n\t}\n}\n", confValidateError, "memory-size parameter removed in server 7.0"},
+		{"added in 7", &confValidator{version: "6.4.0.2"}, confValidateTestNetwork + "namespace test {\n\tstorage-engine memory {\n\t\tdata-size 4G\n\t}\n}\n", confValidateError, "data-size parameter requires server 7.0"},
+		{"unknown", &confValidator{}, confValidateTestNetwork + "namespace test {\n\tbogus-param 1\n\tstorage-engine memory {\n\t\tdata-size 4G\n\t}\n}\n", confValidateWarning, "namespace test.bogus-param unknown parameter"},
+		{"memory total", &confValidator{memory: 4 * 1024 * 1024 * 1024}, confValidateTestNetwork + "namespace test {\n\tindexes-memory-budget 1G\n\tstorage-engine memory {\n\t\tdata-size 4G\n\t}\n}\n", confValidateError, "require 5.0G of memory"},
+		{"memory pre 7", &confValidator{version: "6.4.0", memory: 4 * 1024 * 1024 * 1024}, confValidateTestNetwork + "namespace test {\n\tmemory-size 3G\n\tstorage-engine memory\n}\nnamespace bar {\n\tmemory-size 3G\n\tstorage-engine memory\n}\n", confValidateError, "require 6.0G of memory"},
+		{"mesh without seeds", &confValidator{}, strings.Replace(confValidateTestNetwork, "mesh-seed-address-port", "multicast-group", 1) + "namespace test {\n\tstorage-engine memory {\n\t\tdata-size 4G\n\t}\n}\n", confValidateError, "requires mesh-seed-address-port"},
+		{"rack-id", &confValidator{}, confValidateTestNetwork + "namespace test {\n\track-id abc\n\tstorage-engine memory {\n\t\tdata-size 4G\n\t}\n}\n", confValidateError, "rack-id must be a number"},
+		{"rack-id rf1", &confValidator{}, confValidateTestNetwork + "namespace test {\n\treplication-factor 1\n\track-id 1\n\tstorage-engine memory {\n\t\tdata-size 4G\n\t}\n}\n", confValidateWarning, "no effect"},
+		{"relative device", &confValidator{}, confValidateTestNetwork + "namespace test {\n\tstorage-engine device {\n\t\tdevice sdb\n\t}\n}\n", confValidateError, "must be absolute"},
+		{"shared device", &confValidator{}, confValidateTestNetwork + "namespace test {\n\tstorage-engine device {\n\t\tdevice /dev/sdb\n\t}\n}\nnamespace bar {\n\tstorage-engine device {\n\t\tdevice /dev/sdc /dev/sdb\n\t}\n}\n", confValidateError, "/dev/sdb is already used by namespace"},
+		{"device without devices", &confValidator{}, confValidateTestNetwork + "namespace test {\n\tstorage-engine device {\n\t\twrite-block-size 1M\n\t}\n}\n", confValidateError, "requires at least one device or file"},
+	}
+	for _, test := range tests {
+		findings := test.validator.validate([]byte(test.conf))
+		if !confValidateHas(findings, test.level, test.message) {
+			t.Errorf("%s: expected %s %q, got:", test.name, test.level, test.message)
+			for _, f := range findings {
+				t.Logf("  %s %s %s", f.Level, f.Path, f.Message)
+			}
+		}
+	}
+
+	valid := confValidateTestNetwork + "namespace test {\n\track-id 1\n\tstorage-engine memory {\n\t\tdata-size 4G\n\t}\n}\nnamespace bar {\n\track-id 1\n\tstorage-engine device {\n\t\tfile /opt/aerospike/bar.dat\n\t\tfilesize 4G\n\t}\n}\n"
+	if findings := (&confValidator{version: "7.1.0", memory: 8 * 1024 * 1024 * 1024}).validate([]byte(valid)); len(findings) != 0 {
+		t.Errorf("expected no findings, got %d, first: %s %s", len(findings), findings[0].Path, findings[0].Message)
+	}
+}
+
+func TestMockConfValidateBeforePush(t *testing.T) {
+	m, features := mockSetup(t)
+	bad := filepath.Join(t.TempDir(), "aerospike.conf")
+	if err := os.WriteFile(bad, []byte(confValidateTestNetwork+"namespace test {\n\tmemory-size 4G\n\tstorage-engine memory\n}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	(&restCmd{}).resetBools()
+	err := a.main("aerolab", []string{"cluster", "create", "-n", "mydc", "-c", "1", "-d", mockVersion.distroName, "-i", mockVersion.distroVersion, "-v", mockVersion.aerospikeVersion, "-f", features, "-o", bad})
+	if err == nil || !strings.Contains(err.Error(), "validation failed") {
+		t.Fatalf("expected validation failure, got %v", err)
+	}
+	if clusters, _ := m.ClusterList(); len(clusters) != 0 {
+		t.Fatalf("cluster created from an invalid configuration: %v", clusters)
+	}
+	// parsed options persist between runs
+	a.opts.Cluster.Create.CustomConfigFilePath = ""
+
+	mockCreateCluster(t, features, "create", "mydc", "1")
+	before, _ := m.getFile("mydc", 1, "/etc/aerospike/aerospike.conf")
+	(&restCmd{}).resetBools()
+	err = a.main("aerolab", []string{"conf", "adjust", "-n", "mydc", "set", "namespace test.memory-size", "4G"})
+	if err == nil {
+		t.Fatal("expected conf adjust set to fail validation")
+	}
+	if after, _ := m.getFile("mydc", 1, "/etc/aerospike/aerospike.conf"); after != before {
+		t.Error("invalid configuration was written to the node")
+	}
+	mockRun(t, "conf", "adjust", "-n", "mydc", "set", "namespace test.replication-factor", "3")
+	if after, _ := m.getFile("mydc", 1, "/etc/aerospike/aerospike.conf"); !strings.Contains(after, "replication-factor 3") {
+		t.Error("valid change not written to the node")
+	}
+}
+
+func TestMockClusterCreateMulticast(t *testing.T) {
+	m, features := mockSetup(t)
+	// multicast is an alias of mcast: heartbeat is rewritten by aerolab, so it is not validated
+	mockRun(t, "cluster", "create", "-n", "mydc", "-c", "1", "-d", mockVersion.distroName, "-i", mockVersion.distroVersion, "-v", mockVersion.aerospikeVersion, "-f", features, "-m", "multicast", "-a", "239.2.3.4", "-p", "9918")
+	conf, _ := m.getFile("mydc", 1, "/etc/aerospike/aerospike.conf")
+	if !strings.Contains(conf, "mode multicast") || !strings.Contains(conf, "multicast-group 239.2.3.4") {
+		t.Errorf("multicast heartbeat not configured:\n%s", conf)
+	}
+	a.opts.Cluster.Create.HeartbeatMode = "mesh"
+	a.opts.Cluster.Create.MulticastAddress = ""
+	a.opts.Cluster.Create.MulticastPort = ""
+}
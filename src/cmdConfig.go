@@ -33,14 +33,20 @@ func (c *configCmd) Execute(args []string) error {
 }
 
 type configBackendCmd struct {
-	Type       string         `short:"t" long:"type" description:"Supported backends: aws|docker|gcp" default:""`
-	SshKeyPath flags.Filename `short:"p" long:"key-path" description:"AWS and GCP backends: specify a path to store SSH keys in, default: ${HOME}/aerolab-keys/" default:"${HOME}/aerolab-keys/"`
-	Region     string         `short:"r" long:"region" description:"AWS backend: override default aws configured region" default:""`
-	AWSProfile string         `short:"P" long:"aws-profile" description:"AWS backend: provide a profile to use; setting this ignores the AWS_PROFILE env variable"`
-	Project    string         `short:"o" long:"project" description:"GCP backend: override default gcp configured project" default:""`
-	TmpDir     flags.Filename `short:"d" long:"temp-dir" description:"use a non-default temporary directory" default:""`
-	Help       helpCmd        `command:"help" subcommands-optional:"true" description:"Print help"`
-	typeSet    string
+	Type              string         `short:"t" long:"type" description:"Supported backends: aws|docker|gcp|podman|kubernetes; podman is the docker backend using podman" default:""`
+	SshKeyPath        flags.Filename `short:"p" long:"key-path" description:"AWS and GCP backends: specify a path to store SSH keys in, default: ${HOME}/aerolab-keys/" default:"${HOME}/aerolab-keys/"`
+	Region            string         `short:"r" long:"region" description:"AWS backend: override default aws configured region" default:""`
+	AWSProfile        string         `short:"P" long:"aws-profile" description:"AWS backend: provide a profile to use; setting this ignores the AWS_PROFILE env variable"`
+	Project           string         `short:"o" long:"project" description:"GCP backend: override default gcp configured project" default:""`
+	TmpDir            flags.Filename `short:"d" long:"temp-dir" description:"use a non-default temporary directory" default:""`
+	ContainerCLI      string         `long:"container-cli" description:"Docker backend: container engine command to use, docker|podman; default: docker if installed, otherwise podman; with rootless podman, node addresses are not reachable from the host, use the published ports instead (cluster create --expose-ports, or auto-expose)" default:""`
+	DockerHosts       string         `long:"docker-hosts" description:"Docker backend: comma-separated pool of docker hosts to place cluster nodes across, ex. ssh://user@server1,tcp://server2:2376; default: DOCKER_HOST or the current context" default:""`
+	KubeContext       string         `long:"kube-context" description:"Kubernetes backend: kubectl context to use; default: the current context" default:""`
+	KubeNamespace     string         `long:"kube-namespace" description:"Kubernetes backend: namespace to deploy clusters in" default:"aerolab"`
+	KubeRegistry      string         `long:"kube-registry" description:"Kubernetes backend: registry to push templates to, ex. localhost:5001; default: load templates directly into kind, k3d and single-node local k3s clusters, other clusters require a registry" default:""`
+	InventoryCacheTTL time.Duration  `long:"inventory-cache-ttl" description:"Cache cluster and node listings on disk for this long between commands, ex. 60s; changes made outside of this aerolab installation are not seen until the cache expires, use --no-cache to bypass it; 0 disables the cache" default:"0s"`
+	Help              helpCmd        `command:"help" subcommands-optional:"true" description:"Print help"`
+	typeSet           string
 }
 
 type configDefaultsCmd struct {
@@ -74,7 +80,17 @@ func (c *configBackendCmd) Execute(args []string) error {
 	if c.Type == "gcp" {
 		fmt.Printf("Config.Backend.Project = %s\n", c.Project)
 	}
+	if c.Type == "docker" {
+		fmt.Printf("Config.Backend.ContainerCLI = %s\n", c.ContainerCLI)
+		fmt.Printf("Config.Backend.DockerHosts = %s\n", c.DockerHosts)
+	}
+	if c.Type == "kubernetes" {
+		fmt.Printf("Config.Backend.KubeContext = %s\n", c.KubeContext)
+		fmt.Printf("Config.Backend.KubeNamespace = %s\n", c.KubeNamespace)
+		fmt.Printf("Config.Backend.KubeRegistry = %s\n", c.KubeRegistry)
+	}
 	fmt.Printf("Config.Backend.TmpDir = %s\n", c.TmpDir)
+	fmt.Printf("Config.Backend.InventoryCacheTTL = %s\n", c.InventoryCacheTTL)
 	return nil
 }
 
@@ -96,6 +112,13 @@ func (c *configBackendCmd) ExecTypeSet(args []string) error {
 			}
 		}
 	}
+	if c.Type == "podman" {
+		c.Type = "docker"
+		c.ContainerCLI = "podman"
+	}
+	if c.Type == "docker" && c.ContainerCLI != "" && c.ContainerCLI != "docker" && c.ContainerCLI != "podman" {
+		return errors.New("container CLI types supported: docker, podman")
+	}
 	if c.Type == "aws" || c.Type == "gcp" {
 		if c.Type == "gcp" && c.Project == "" {
 			logExit("ERROR: When using GCP backend, project name must be defined. Use: aerolab config backend -t gcp -o project-name-here")
@@ -113,8 +136,8 @@ func (c *configBackendCmd) ExecTypeSet(args []string) error {
 				return err
 			}
 		}
-	} else if c.Type != "docker" && c.Type != "none" {
-		return errors.New("backend types supported: docker, aws, gcp")
+	} else if c.Type != "docker" && c.Type != "kubernetes" && c.Type != "none" {
+		return errors.New("backend types supported: docker, aws, gcp, podman, kubernetes")
 	}
 	if c.TmpDir == "" {
 		out, err := exec.Command("uname", "-r").CombinedOutput()
@@ -35,9 +35,10 @@ type deleteNetworkCmd struct {
 
 type createNetworkCmd struct {
 	Name   string  `short:"n" long:"name" description:"network name to create" default:""`
-	Driver string  `short:"d" long:"driver" description:"network driver" default:"bridge"`
+	Driver string  `short:"d" long:"driver" description:"network driver; with multiple docker hosts configured, use overlay or macvlan" default:"bridge"`
 	Subnet string  `short:"s" long:"subnet" description:"network subnet to create, ex. 172.18.0.0/24 or 172.18.1.0/24" default:"default"`
 	MTU    string  `short:"m" long:"mtu" description:"MTU in the network" default:"default"`
+	Parent string  `short:"p" long:"parent" description:"macvlan and ipvlan drivers: host network interface to attach the network to, ex. eth0" default:""`
 	Help   helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
@@ -102,7 +103,7 @@ func (c *createNetworkCmd) Execute(args []string) error {
 	if c.MTU == "default" {
 		c.MTU = ""
 	}
-	err := b.CreateNetwork(c.Name, c.Driver, c.Subnet, c.MTU)
+	err := b.CreateNetwork(c.Name, c.Driver, c.Subnet, c.MTU, c.Parent)
 	if err != nil {
 		return err
 	}
@@ -5,6 +5,7 @@ import "os"
 type dataCmd struct {
 	Insert dataInsertCmd `command:"insert" subcommands-optional:"true" description:"Insert data into an Aerospike cluster"`
 	Delete dataDeleteCmd `command:"delete" subcommands-optional:"true" description:"Delete data inserted via AeroLab"`
+	Bench  dataBenchCmd  `command:"bench" subcommands-optional:"true" description:"Run a mixed read/write workload and report latency histograms"`
 	Help   helpCmd       `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
@@ -0,0 +1,690 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aerospike/aerolab/parallelize"
+	"github.com/jedib0t/go-pretty/v6/table"
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+type dataBenchCmd struct {
+	dataInsertNsSetCmd
+	dataInsertPkCmd
+	Ops            string        `short:"o" long:"ops" description:"Operation mix as TYPE:WEIGHT, comma separated; types: read,write,update,delete,batch" default:"read:80,write:20"`
+	TPS            int           `short:"T" long:"tps" description:"Target transactions per second, split between all benchmark machines; 0=unlimited" default:"0"`
+	Duration       time.Duration `short:"D" long:"duration" description:"How long to run the benchmark for" default:"60s"`
+	KeyDist        string        `short:"k" long:"key-dist" description:"Key distribution: uniform | zipfian[:S] | hotspot[:OPS_PCT:KEYS_PCT]; ex: hotspot:90:10 sends 90% of operations to 10% of keys" default:"uniform"`
+	Bins           string        `short:"b" long:"bins" description:"Bins to write as TYPE[:SIZE], comma separated; types: int,str,blob,list,map,geo; the type is used as the bin name" default:"int,str:64"`
+	BatchSize      int           `short:"B" long:"batch-size" description:"Number of keys in each batch read" default:"10"`
+	Expression     string        `short:"x" long:"expression" description:"Filter expression applied to reads, batch reads, updates and deletes, as INTBIN OP VALUE, ex: 'int>=500'" default:""`
+	ReportInterval time.Duration `short:"R" long:"report-interval" description:"How often to print progress" default:"5s"`
+	Json           bool          `short:"j" long:"json" description:"Print the final report in json format"`
+	ResultFile     string        `long:"result-file" description:"Write the result of this benchmark machine to this file in json format" hidden:"true"`
+	StartAt        int64         `long:"start-at" description:"Unix timestamp at which to start the benchmark, used to start multiple machines at once" hidden:"true"`
+	dataInsertCommonCmd
+	ClusterName     TypeClusterName `short:"n" long:"name" description:"Cluster name of cluster to run aerolab on" default:"mydc"`
+	Nodes           TypeNodes       `short:"l" long:"nodes" description:"Nodes to run the benchmark from, comma separated; each node runs the whole workload" default:"1"`
+	IsClient        bool            `short:"I" long:"client" description:"set to indicate to run on client machines instead of server nodes"`
+	SeedNode        string          `short:"g" long:"seed-node" description:"Seed node IP:PORT. Only use if you are running the benchmark from different node to another one." default:"127.0.0.1:3000"`
+	LinuxBinaryPath flags.Filename  `short:"t" long:"path" description:"Path to the linux compiled aerolab binary; this should not be required" default:""`
+	Help            helpCmd         `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+const dataBenchRemoteResultFile = "/tmp/aerolab-bench-result.json"
+
+// operation types
+const (
+	dataBenchOpRead   = "read"
+	dataBenchOpWrite  = "write"
+	dataBenchOpUpdate = "update"
+	dataBenchOpDelete = "delete"
+	dataBenchOpBatch  = "batch"
+)
+
+var dataBenchOpTypes = []string{dataBenchOpRead, dataBenchOpWrite, dataBenchOpUpdate, dataBenchOpDelete, dataBenchOpBatch}
+
+// dataBenchResult is the outcome of a benchmark run on one machine, or the merged outcome of multiple machines
+type dataBenchResult struct {
+	Machines []string
+	Start    time.Time
+	Duration time.Duration
+	Ops      map[string]*dataBenchHistogram
+}
+
+func (c *dataBenchCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	log.Print("Running data.bench")
+	if c.RunDirect {
+		log.Print("Bench start")
+		defer log.Print("Bench done")
+		switch c.Version {
+		case "6":
+			return c.bench6()
+		default:
+			return errors.New("data bench requires aerospike client library version 6")
+		}
+	}
+	// fail early on invalid workload definitions rather than on each remote machine
+	if _, err := c.workload(); err != nil {
+		return err
+	}
+	if b == nil {
+		return logFatal("Invalid backend")
+	}
+	err := b.Init()
+	if err != nil {
+		return logFatal("Could not init backend: %s", err)
+	}
+	if c.IsClient {
+		b.WorkOnClients()
+	}
+	nodes, err := c.Nodes.Translate(string(c.ClusterName))
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return errors.New("no nodes to run the benchmark from")
+	}
+	selectors := make(map[int]*dataInsertSelectorCmd)
+	for _, node := range nodes {
+		selectors[node] = &dataInsertSelectorCmd{
+			ClusterName:     c.ClusterName,
+			Node:            TypeNode(node),
+			IsClient:        c.IsClient,
+			SeedNode:        c.SeedNode,
+			LinuxBinaryPath: c.LinuxBinaryPath,
+		}
+	}
+	var extraArgs []string
+	if a.opts.Config.Backend.Type == "docker" || a.opts.Config.Backend.Type == "kubernetes" {
+		found := false
+		for _, arg := range os.Args[1:] {
+			if strings.HasPrefix(arg, "-g") || strings.HasPrefix(arg, "--seed-node") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			seedNode, err := selectors[nodes[0]].checkSeedPort()
+			if err != nil {
+				return err
+			}
+			extraArgs = append(extraArgs, "-g", seedNode)
+		}
+	}
+	log.Print("Unpacking start")
+	for _, node := range nodes {
+		err = selectors[node].upload()
+		if err != nil {
+			return fmt.Errorf("node %d: %s", node, err)
+		}
+	}
+	log.Print("Unpacking done")
+
+	// all machines start at the same time, each running its share of the target TPS
+	if c.TPS > 0 {
+		extraArgs = append(extraArgs, "--tps", strconv.Itoa(int(math.Ceil(float64(c.TPS)/float64(len(nodes))))))
+	}
+	extraArgs = append(extraArgs, "--result-file", dataBenchRemoteResultFile, "--start-at", strconv.FormatInt(time.Now().Add(5*time.Second).Unix(), 10))
+	runCommand := []string{"/usr/local/bin/aerolab"}
+	runCommand = append(runCommand, os.Args[1:]...)
+	runCommand = append(runCommand, "-d", "1")
+	runCommand = append(runCommand, extraArgs...)
+	// the remote progress and table output are only streamed for a single machine; with --json, stdout must contain the json report only
+	if len(nodes) == 1 && !c.Json {
+		err = b.AttachAndRun(string(c.ClusterName), nodes[0], runCommand, false)
+		if err != nil {
+			return fmt.Errorf("data-bench: backend.AttachAndRun: %s", err)
+		}
+	} else {
+		log.Printf("Running benchmark on nodes %v for %s", nodes, c.Duration)
+		returns := parallelize.MapLimit(nodes, len(nodes), func(node int) error {
+			out, err := b.RunCommands(string(c.ClusterName), [][]string{runCommand}, []int{node})
+			if err != nil && len(out) > 0 {
+				return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out[0])))
+			}
+			return err
+		})
+		for i, ret := range returns {
+			if ret != nil {
+				log.Printf("Node %d returned %s", nodes[i], ret)
+			}
+		}
+	}
+
+	results := []*dataBenchResult{}
+	for _, node := range nodes {
+		out, err := b.RunCommands(string(c.ClusterName), [][]string{{"/bin/bash", "-c", "cat " + dataBenchRemoteResultFile + " && rm -f " + dataBenchRemoteResultFile}}, []int{node})
+		if err != nil {
+			log.Printf("WARNING: node %d: could not get benchmark result: %s", node, err)
+			continue
+		}
+		result := &dataBenchResult{}
+		err = json.Unmarshal(out[0], result)
+		if err != nil {
+			log.Printf("WARNING: node %d: could not parse benchmark result: %s", node, err)
+			continue
+		}
+		for i := range result.Machines {
+			result.Machines[i] = fmt.Sprintf("node %d (%s)", node, result.Machines[i])
+		}
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return errors.New("benchmark did not produce results on any node")
+	}
+	if len(nodes) > 1 || c.Json {
+		err = c.report(results)
+		if err != nil {
+			return err
+		}
+	}
+	log.Print("Done")
+	return nil
+}
+
+// report prints the merged result of all machines, followed by per-machine throughput if more than one machine ran the benchmark
+func (c *dataBenchCmd) report(results []*dataBenchResult) error {
+	merged := dataBenchMerge(results)
+	if c.Json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "    ")
+		return enc.Encode(merged)
+	}
+	if len(results) > 1 {
+		t := table.NewWriter()
+		t.SetStyle(table.StyleDefault)
+		t.SetTitle("Throughput per machine")
+		t.AppendHeader(table.Row{"Machine", "Duration", "Ops", "TPS", "Errors"})
+		for _, result := range results {
+			ops, errs := uint64(0), uint64(0)
+			for _, h := range result.Ops {
+				ops += h.Count
+				errs += h.Errors
+			}
+			t.AppendRow(table.Row{strings.Join(result.Machines, ", "), result.Duration.Round(time.Millisecond), ops, dataBenchTPS(ops, result.Duration), errs})
+		}
+		fmt.Println(t.Render())
+		fmt.Println()
+	}
+	dataBenchPrint(merged)
+	return nil
+}
+
+// dataBenchMerge merges the results of multiple machines; the duration is the longest run
+func dataBenchMerge(results []*dataBenchResult) *dataBenchResult {
+	merged := &dataBenchResult{Ops: make(map[string]*dataBenchHistogram)}
+	for _, result := range results {
+		merged.Machines = append(merged.Machines, result.Machines...)
+		if merged.Start.IsZero() || result.Start.Before(merged.Start) {
+			merged.Start = result.Start
+		}
+		if result.Duration > merged.Duration {
+			merged.Duration = result.Duration
+		}
+		for op, h := range result.Ops {
+			if _, ok := merged.Ops[op]; !ok {
+				merged.Ops[op] = newDataBenchHistogram()
+			}
+			merged.Ops[op].merge(h)
+		}
+	}
+	return merged
+}
+
+func dataBenchTPS(count uint64, duration time.Duration) int {
+	if duration < time.Second {
+		return int(count)
+	}
+	return int(float64(count) / duration.Seconds())
+}
+
+// dataBenchPrint prints the latency summary and histogram of each operation type
+func dataBenchPrint(result *dataBenchResult) {
+	t := table.NewWriter()
+	t.SetStyle(table.StyleDefault)
+	t.SetTitle(fmt.Sprintf("Latency per operation type, %d machine(s), %s", len(result.Machines), result.Duration.Round(time.Millisecond)))
+	t.AppendHeader(table.Row{"Operation", "Ops", "TPS", "Errors", "Not Found", "Filtered", "Avg", "p50", "p95", "p99", "Max"})
+	hist := table.NewWriter()
+	hist.SetStyle(table.StyleDefault)
+	hist.SetTitle("Latency histogram, %% of operations")
+	header := table.Row{"Operation"}
+	for _, band := range dataBenchBands {
+		header = append(header, "<="+band.String())
+	}
+	header = append(header, ">"+dataBenchBands[len(dataBenchBands)-1].String())
+	hist.AppendHeader(header)
+	for _, op := range dataBenchOpTypes {
+		h, ok := result.Ops[op]
+		if !ok {
+			continue
+		}
+		t.AppendRow(table.Row{op, h.Count, dataBenchTPS(h.Count, result.Duration), h.Errors, h.NotFound, h.Filtered, h.average(), h.percentile(50), h.percentile(95), h.percentile(99), time.Duration(h.MaxMicros) * time.Microsecond})
+		row := table.Row{op}
+		for _, pct := range h.bands() {
+			row = append(row, fmt.Sprintf("%.2f", pct))
+		}
+		hist.AppendRow(row)
+	}
+	fmt.Println(t.Render())
+	fmt.Println(hist.Render())
+}
+
+// dataBenchBucketBounds are the upper bounds of the latency histogram buckets; the last bucket holds everything above the last bound
+var dataBenchBucketBounds = []time.Duration{
+	100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 8 * time.Millisecond, 16 * time.Millisecond,
+	32 * time.Millisecond, 64 * time.Millisecond, 128 * time.Millisecond, 256 * time.Millisecond, 512 * time.Millisecond, time.Second,
+}
+
+// dataBenchBands are the latency bands printed in the histogram, each must be one of the bucket bounds
+var dataBenchBands = []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 8 * time.Millisecond, 16 * time.Millisecond, 32 * time.Millisecond, 64 * time.Millisecond}
+
+// dataBenchHistogram holds the counters and latency buckets of one operation type; latencies are only recorded for operations which did not error
+type dataBenchHistogram struct {
+	Count       uint64
+	Errors      uint64
+	NotFound    uint64
+	Filtered    uint64
+	TotalMicros uint64
+	MaxMicros   uint64
+	Buckets     []uint64
+	lock        *sync.Mutex
+}
+
+func newDataBenchHistogram() *dataBenchHistogram {
+	return &dataBenchHistogram{
+		Buckets: make([]uint64, len(dataBenchBucketBounds)+1),
+		lock:    new(sync.Mutex),
+	}
+}
+
+// record adds an operation with the given latency
+func (h *dataBenchHistogram) record(latency time.Duration, notFound bool, filtered bool) {
+	bucket := sort.Search(len(dataBenchBucketBounds), func(i int) bool {
+		return latency <= dataBenchBucketBounds[i]
+	})
+	micros := uint64(latency.Microseconds())
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.Count++
+	if notFound {
+		h.NotFound++
+	}
+	if filtered {
+		h.Filtered++
+	}
+	h.TotalMicros += micros
+	if micros > h.MaxMicros {
+		h.MaxMicros = micros
+	}
+	h.Buckets[bucket]++
+}
+
+func (h *dataBenchHistogram) recordError() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.Errors++
+}
+
+func (h *dataBenchHistogram) merge(o *dataBenchHistogram) {
+	h.Count += o.Count
+	h.Errors += o.Errors
+	h.NotFound += o.NotFound
+	h.Filtered += o.Filtered
+	h.TotalMicros += o.TotalMicros
+	if o.MaxMicros > h.MaxMicros {
+		h.MaxMicros = o.MaxMicros
+	}
+	for i := range o.Buckets {
+		if i < len(h.Buckets) {
+			h.Buckets[i] += o.Buckets[i]
+		}
+	}
+}
+
+// snapshot returns a copy of the histogram which is safe to read while operations are being recorded
+func (h *dataBenchHistogram) snapshot() *dataBenchHistogram {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	n := newDataBenchHistogram()
+	n.merge(h)
+	return n
+}
+
+func (h *dataBenchHistogram) average() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return time.Duration(h.TotalMicros/h.Count) * time.Microsecond
+}
+
+// percentile returns the upper bound of the bucket holding the given percentile, capped at the maximum recorded latency
+func (h *dataBenchHistogram) percentile(pct float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	max := time.Duration(h.MaxMicros) * time.Microsecond
+	rank := uint64(math.Ceil(float64(h.Count) * pct / 100))
+	seen := uint64(0)
+	for i, count := range h.Buckets {
+		seen += count
+		if seen >= rank {
+			if i < len(dataBenchBucketBounds) && dataBenchBucketBounds[i] < max {
+				return dataBenchBucketBounds[i]
+			}
+			return max
+		}
+	}
+	return max
+}
+
+// bands returns the percentage of operations within each of dataBenchBands, followed by the percentage above the last band
+func (h *dataBenchHistogram) bands() []float64 {
+	ret := make([]float64, len(dataBenchBands)+1)
+	if h.Count == 0 {
+		return ret
+	}
+	band := 0
+	for i, count := range h.Buckets {
+		for band < len(dataBenchBands) && i < len(dataBenchBucketBounds) && dataBenchBucketBounds[i] > dataBenchBands[band] {
+			band++
+		}
+		if i == len(dataBenchBucketBounds) {
+			band = len(dataBenchBands)
+		}
+		ret[band] += float64(count) * 100 / float64(h.Count)
+	}
+	return ret
+}
+
+// dataBenchOpWeight is an operation type with its relative weight in the workload
+type dataBenchOpWeight struct {
+	op     string
+	weight int
+}
+
+// dataBenchBinSpec is a bin written by the benchmark; size is the string length, blob size in bytes, or number of list and map elements
+type dataBenchBinSpec struct {
+	binType string
+	size    int
+}
+
+// dataBenchExpression is a filter expression comparing an integer bin with a value
+type dataBenchExpression struct {
+	bin   string
+	op    string
+	value int64
+}
+
+// dataBenchKeys picks keys according to the key distribution
+type dataBenchKeys struct {
+	start    int
+	count    int
+	dist     string
+	zipfS    float64
+	hotOps   float64
+	hotCount int
+}
+
+// dataBenchWorkload is the parsed definition of the benchmark workload
+type dataBenchWorkload struct {
+	ops        []dataBenchOpWeight
+	total      int
+	keys       *dataBenchKeys
+	bins       []dataBenchBinSpec
+	expression *dataBenchExpression
+}
+
+// pick selects an operation type according to the weights
+func (w *dataBenchWorkload) pick(r *rand.Rand) string {
+	n := r.Intn(w.total)
+	for _, op := range w.ops {
+		if n < op.weight {
+			return op.op
+		}
+		n -= op.weight
+	}
+	return w.ops[len(w.ops)-1].op
+}
+
+// workload parses and validates the workload definition
+func (c *dataBenchCmd) workload() (*dataBenchWorkload, error) {
+	w := &dataBenchWorkload{}
+	var err error
+	w.ops, err = parseDataBenchOps(c.Ops)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range w.ops {
+		w.total += op.weight
+	}
+	w.keys, err = parseDataBenchKeyDist(c.KeyDist, c.PkStartNumber, c.PkEndNumber)
+	if err != nil {
+		return nil, err
+	}
+	w.bins, err = parseDataBenchBins(c.Bins)
+	if err != nil {
+		return nil, err
+	}
+	if c.Expression != "" {
+		w.expression, err = parseDataBenchExpression(c.Expression)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.BatchSize < 1 {
+		return nil, errors.New("batch size must be at least 1")
+	}
+	if strings.HasPrefix(c.Set, "random:") {
+		return nil, errors.New("random set names are not supported by data bench")
+	}
+	return w, nil
+}
+
+// parseDataBenchOps parses the operation mix, ex: read:80,write:15,delete:5
+func parseDataBenchOps(ops string) ([]dataBenchOpWeight, error) {
+	ret := []dataBenchOpWeight{}
+	for _, item := range strings.Split(ops, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		opWeight := strings.Split(item, ":")
+		if len(opWeight) != 2 {
+			return nil, fmt.Errorf("invalid operation %q, must be TYPE:WEIGHT", item)
+		}
+		op := strings.ToLower(opWeight[0])
+		found := false
+		for _, t := range dataBenchOpTypes {
+			if t == op {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid operation type %q, must be one of: %s", opWeight[0], strings.Join(dataBenchOpTypes, ","))
+		}
+		weight, err := strconv.Atoi(opWeight[1])
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight of operation %s: %s", op, opWeight[1])
+		}
+		if weight > 0 {
+			ret = append(ret, dataBenchOpWeight{op, weight})
+		}
+	}
+	if len(ret) == 0 {
+		return nil, errors.New("no operations with a weight above 0 specified")
+	}
+	return ret, nil
+}
+
+// parseDataBenchKeyDist parses the key distribution over the keys start..end
+func parseDataBenchKeyDist(dist string, start int, end int) (*dataBenchKeys, error) {
+	if end < start {
+		return nil, errors.New("pk-end-number must not be lower than pk-start-number")
+	}
+	k := &dataBenchKeys{start: start, count: end - start + 1}
+	params := strings.Split(dist, ":")
+	k.dist = strings.ToLower(params[0])
+	switch k.dist {
+	case "uniform":
+		if len(params) != 1 {
+			return nil, errors.New("uniform key distribution does not take parameters")
+		}
+	case "zipfian":
+		k.zipfS = 1.1
+		if len(params) > 2 {
+			return nil, errors.New("zipfian key distribution format is zipfian[:S]")
+		}
+		if len(params) == 2 {
+			s, err := strconv.ParseFloat(params[1], 64)
+			if err != nil || s <= 1 {
+				return nil, fmt.Errorf("zipfian exponent must be a number above 1, got %s", params[1])
+			}
+			k.zipfS = s
+		}
+	case "hotspot":
+		opsPct, keysPct := 90.0, 10.0
+		if len(params) != 1 && len(params) != 3 {
+			return nil, errors.New("hotspot key distribution format is hotspot[:OPS_PCT:KEYS_PCT]")
+		}
+		if len(params) == 3 {
+			var err1, err2 error
+			opsPct, err1 = strconv.ParseFloat(params[1], 64)
+			keysPct, err2 = strconv.ParseFloat(params[2], 64)
+			if err1 != nil || err2 != nil || opsPct < 0 || opsPct > 100 || keysPct <= 0 || keysPct >= 100 {
+				return nil, fmt.Errorf("invalid hotspot percentages in %s", dist)
+			}
+		}
+		k.hotOps = opsPct / 100
+		k.hotCount = int(math.Max(1, math.Round(float64(k.count)*keysPct/100)))
+		if k.hotCount >= k.count {
+			return nil, fmt.Errorf("hotspot of %.2f%% of keys covers the whole key range", keysPct)
+		}
+	default:
+		return nil, fmt.Errorf("invalid key distribution %s, must be one of uniform, zipfian, hotspot", params[0])
+	}
+	return k, nil
+}
+
+// newZipf returns the zipfian generator for a thread, or nil for other distributions
+func (k *dataBenchKeys) newZipf(r *rand.Rand) *rand.Zipf {
+	if k.dist != "zipfian" {
+		return nil
+	}
+	return rand.NewZipf(r, k.zipfS, 1, uint64(k.count-1))
+}
+
+// next returns the next key number; with zipfian distribution, lower key numbers are the most frequent
+func (k *dataBenchKeys) next(r *rand.Rand, z *rand.Zipf) int {
+	switch k.dist {
+	case "zipfian":
+		return k.start + int(z.Uint64())
+	case "hotspot":
+		if r.Float64() < k.hotOps {
+			return k.start + r.Intn(k.hotCount)
+		}
+		return k.start + k.hotCount + r.Intn(k.count-k.hotCount)
+	}
+	return k.start + r.Intn(k.count)
+}
+
+// parseDataBenchBins parses the bin definitions, ex: int,str:100,map:10
+func parseDataBenchBins(bins string) ([]dataBenchBinSpec, error) {
+	defaultSizes := map[string]int{"int": 0, "str": 64, "blob": 1024, "list": 10, "map": 10, "geo": 0}
+	ret := []dataBenchBinSpec{}
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(bins, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		typeSize := strings.Split(item, ":")
+		binType := strings.ToLower(typeSize[0])
+		size, ok := defaultSizes[binType]
+		if !ok {
+			return nil, fmt.Errorf("invalid bin type %q, must be one of int,str,blob,list,map,geo", typeSize[0])
+		}
+		if seen[binType] {
+			return nil, fmt.Errorf("bin type %s specified more than once", binType)
+		}
+		seen[binType] = true
+		if len(typeSize) > 2 || (len(typeSize) == 2 && (binType == "int" || binType == "geo")) {
+			return nil, fmt.Errorf("invalid bin definition %q", item)
+		}
+		if len(typeSize) == 2 {
+			var err error
+			size, err = strconv.Atoi(typeSize[1])
+			if err != nil || size < 1 {
+				return nil, fmt.Errorf("invalid size of bin %s: %s", binType, typeSize[1])
+			}
+		}
+		ret = append(ret, dataBenchBinSpec{binType, size})
+	}
+	if len(ret) == 0 {
+		return nil, errors.New("at least one bin must be specified")
+	}
+	return ret, nil
+}
+
+// parseDataBenchExpression parses a filter expression in the form INTBIN OP VALUE, ex: int>=500
+func parseDataBenchExpression(expression string) (*dataBenchExpression, error) {
+	for _, op := range []string{"==", "!=", ">=", "<=", ">", "<"} {
+		parts := strings.SplitN(expression, op, 2)
+		if len(parts) != 2 {
+			continue
+		}
+		bin := strings.TrimSpace(parts[0])
+		value, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+		if bin == "" || err != nil {
+			return nil, fmt.Errorf("invalid expression %q, must be INTBIN OP VALUE, ex: int>=500", expression)
+		}
+		return &dataBenchExpression{bin, op, value}, nil
+	}
+	return nil, fmt.Errorf("invalid expression %q, operator must be one of ==,!=,>=,<=,>,<", expression)
+}
+
+// dataBenchLimiter spaces operations evenly to achieve the target TPS; a nil limiter does not limit
+type dataBenchLimiter struct {
+	lock     sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newDataBenchLimiter(tps int) *dataBenchLimiter {
+	if tps <= 0 {
+		return nil
+	}
+	return &dataBenchLimiter{interval: time.Second / time.Duration(tps), next: time.Now()}
+}
+
+// wait blocks until the next operation may run; if the workers fall behind, at most one second worth of operations is caught up on
+func (l *dataBenchLimiter) wait() {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	now := time.Now()
+	if l.next.Before(now.Add(-time.Second)) {
+		l.next = now.Add(-time.Second)
+	}
+	t := l.next
+	l.next = l.next.Add(l.interval)
+	l.lock.Unlock()
+	time.Sleep(time.Until(t))
+}
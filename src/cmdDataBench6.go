@@ -0,0 +1,268 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aerospike/aerospike-client-go/v6"
+	"github.com/aerospike/aerospike-client-go/v6/types"
+)
+
+func (c *dataBenchCmd) bench6() error {
+	w, err := c.workload()
+	if err != nil {
+		return err
+	}
+	ipPort := strings.Split(c.SeedNode, ":")
+	if len(ipPort) != 2 {
+		return fmt.Errorf("data-bench: Failed to process SeedNode, must be IP:PORT: %s", c.SeedNode)
+	}
+
+	port, err := strconv.Atoi(ipPort[1])
+	if err != nil {
+		return fmt.Errorf("data-bench: Error processing SeedNodePort: %s: %s", ipPort[1], err)
+	}
+
+	var client *aerospike.Client
+	if c.User == "" && c.TlsCaCert == "" && c.TlsClientCert == "" {
+		client, err = aerospike.NewClient(ipPort[0], port)
+	} else {
+		policy := aerospike.NewClientPolicy()
+		if c.User != "" {
+			policy.User = c.User
+			policy.Password = c.Pass
+			if c.AuthExternal {
+				policy.AuthMode = aerospike.AuthModeExternal
+			} else {
+				policy.AuthMode = aerospike.AuthModeInternal
+			}
+		}
+		tlsconfig := &tls.Config{}
+		if c.TlsCaCert != "" {
+			cacertpool := x509.NewCertPool()
+			ncertfile, err := os.ReadFile(c.TlsCaCert)
+			if err != nil {
+				return fmt.Errorf("data-bench: could not read ca cert: %s", err)
+			}
+			cacertpool.AppendCertsFromPEM(ncertfile)
+			tlsconfig.RootCAs = cacertpool
+		}
+		if c.TlsClientCert != "" {
+			clientcertpool := x509.NewCertPool()
+			ncertfile, err := os.ReadFile(c.TlsClientCert)
+			if err != nil {
+				return fmt.Errorf("data-bench: could not read client cert: %s", err)
+			}
+			clientcertpool.AppendCertsFromPEM(ncertfile)
+			tlsconfig.ClientCAs = clientcertpool
+		}
+		if c.TlsClientCert != "" || c.TlsCaCert != "" {
+			tlsconfig.ServerName = c.TlsServerName
+			policy.TlsConfig = tlsconfig
+		}
+		client, err = aerospike.NewClientWithPolicy(policy, ipPort[0], port)
+	}
+	if err != nil {
+		return fmt.Errorf("data-bench: Error connecting: %s", err)
+	}
+	defer client.Close()
+
+	threads := c.UseMultiThreaded
+	if threads < 1 {
+		threads = 1
+	}
+	client.WarmUp(threads)
+
+	var filter *aerospike.Expression
+	if w.expression != nil {
+		bin := aerospike.ExpIntBin(w.expression.bin)
+		val := aerospike.ExpIntVal(w.expression.value)
+		switch w.expression.op {
+		case "==":
+			filter = aerospike.ExpEq(bin, val)
+		case "!=":
+			filter = aerospike.ExpNotEq(bin, val)
+		case ">=":
+			filter = aerospike.ExpGreaterEq(bin, val)
+		case "<=":
+			filter = aerospike.ExpLessEq(bin, val)
+		case ">":
+			filter = aerospike.ExpGreater(bin, val)
+		case "<":
+			filter = aerospike.ExpLess(bin, val)
+		}
+	}
+	rp := aerospike.NewPolicy()
+	rp.FilterExpression = filter
+	bp := aerospike.NewBatchPolicy()
+	bp.FilterExpression = filter
+	wp := aerospike.NewWritePolicy(0, aerospike.TTLServerDefault)
+	wp.RecordExistsAction = aerospike.REPLACE
+	up := aerospike.NewWritePolicy(0, aerospike.TTLServerDefault)
+	up.RecordExistsAction = aerospike.UPDATE_ONLY
+	up.FilterExpression = filter
+	dp := aerospike.NewWritePolicy(0, 0)
+	dp.FilterExpression = filter
+	for _, p := range []*aerospike.BasePolicy{rp, &bp.BasePolicy, &wp.BasePolicy, &up.BasePolicy, &dp.BasePolicy} {
+		p.TotalTimeout = time.Second * 5
+		p.SocketTimeout = 0
+		p.MaxRetries = 2
+	}
+
+	stats := make(map[string]*dataBenchHistogram)
+	for _, op := range w.ops {
+		stats[op.op] = newDataBenchHistogram()
+	}
+	limiter := newDataBenchLimiter(c.TPS)
+	if c.StartAt > 0 {
+		log.Printf("Waiting until %s to start", time.Unix(c.StartAt, 0).Format(time.RFC3339))
+		time.Sleep(time.Until(time.Unix(c.StartAt, 0)))
+	}
+	startTime := time.Now()
+	deadline := startTime.Add(c.Duration)
+	done := make(chan bool)
+	go func() {
+		ticker := time.NewTicker(c.ReportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				line := []string{}
+				for _, op := range w.ops {
+					h := stats[op.op].snapshot()
+					line = append(line, fmt.Sprintf("%s: %d tps, %d errors, p99 %s", op.op, dataBenchTPS(h.Count, time.Since(startTime)), h.Errors, h.percentile(99)))
+				}
+				log.Printf("%s elapsed; %s", time.Since(startTime).Round(time.Second), strings.Join(line, "; "))
+			}
+		}
+	}()
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			z := w.keys.newZipf(r)
+			srcLock := new(sync.Mutex)
+			for time.Now().Before(deadline) {
+				limiter.wait()
+				op := w.pick(r)
+				opStart := time.Now()
+				var notFound, filtered bool
+				var err aerospike.Error
+				switch op {
+				case dataBenchOpRead:
+					_, err = client.Get(rp, c.bench6Key(w.keys.next(r, z)))
+				case dataBenchOpWrite:
+					err = client.Put(wp, c.bench6Key(w.keys.next(r, z)), c.bench6Bins(w.bins, r, srcLock))
+				case dataBenchOpUpdate:
+					bins := c.bench6Bins(w.bins[r.Intn(len(w.bins)):][:1], r, srcLock)
+					ops := []*aerospike.Operation{}
+					for name, val := range bins {
+						ops = append(ops, aerospike.PutOp(aerospike.NewBin(name, val)))
+					}
+					_, err = client.Operate(up, c.bench6Key(w.keys.next(r, z)), ops...)
+				case dataBenchOpDelete:
+					var existed bool
+					existed, err = client.Delete(dp, c.bench6Key(w.keys.next(r, z)))
+					notFound = err == nil && !existed
+				case dataBenchOpBatch:
+					keys := make([]*aerospike.Key, c.BatchSize)
+					for i := range keys {
+						keys[i] = c.bench6Key(w.keys.next(r, z))
+					}
+					_, err = client.BatchGet(bp, keys)
+				}
+				latency := time.Since(opStart)
+				if err != nil {
+					switch {
+					case err.Matches(types.KEY_NOT_FOUND_ERROR):
+						notFound = true
+					case err.Matches(types.FILTERED_OUT):
+						filtered = true
+					default:
+						stats[op].recordError()
+						continue
+					}
+				}
+				stats[op].record(latency, notFound, filtered)
+			}
+		}(time.Now().UnixNano() + int64(i))
+	}
+	wg.Wait()
+	close(done)
+
+	hostname, _ := os.Hostname()
+	result := &dataBenchResult{
+		Machines: []string{hostname},
+		Start:    startTime,
+		Duration: time.Since(startTime),
+		Ops:      stats,
+	}
+	if c.ResultFile != "" {
+		contents, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(c.ResultFile, contents, 0644)
+		if err != nil {
+			return fmt.Errorf("data-bench: could not write result file: %s", err)
+		}
+	}
+	if c.Json && c.ResultFile == "" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "    ")
+		return enc.Encode(result)
+	}
+	dataBenchPrint(result)
+	return nil
+}
+
+func (c *dataBenchCmd) bench6Key(i int) *aerospike.Key {
+	key, _ := aerospike.NewKey(c.Namespace, c.Set, fmt.Sprintf("%s%d", c.PkPrefix, i))
+	return key
+}
+
+// bench6Bins generates random bin values, the bin names are the bin types
+func (c *dataBenchCmd) bench6Bins(specs []dataBenchBinSpec, r *rand.Rand, srcLock *sync.Mutex) aerospike.BinMap {
+	bins := make(aerospike.BinMap)
+	for _, spec := range specs {
+		switch spec.binType {
+		case "int":
+			bins[spec.binType] = r.Int63n(1000)
+		case "str":
+			bins[spec.binType] = RandStringRunes(spec.size, r, srcLock)
+		case "blob":
+			blob := make([]byte, spec.size)
+			r.Read(blob)
+			bins[spec.binType] = blob
+		case "list":
+			list := make([]interface{}, spec.size)
+			for i := range list {
+				list[i] = r.Int63()
+			}
+			bins[spec.binType] = list
+		case "map":
+			m := make(map[interface{}]interface{}, spec.size)
+			for i := 0; i < spec.size; i++ {
+				m["k"+strconv.Itoa(i)] = r.Int63()
+			}
+			bins[spec.binType] = m
+		case "geo":
+			bins[spec.binType] = aerospike.NewGeoJSONValue(fmt.Sprintf(`{"type":"Point","coordinates":[%f,%f]}`, r.Float64()*360-180, r.Float64()*180-90))
+		}
+	}
+	return bins
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDataBenchParse(t *testing.T) {
+	ops, err := parseDataBenchOps("read:50,write:30,update:0,delete:15,batch:5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 4 || ops[0] != (dataBenchOpWeight{"read", 50}) || ops[3] != (dataBenchOpWeight{"batch", 5}) {
+		t.Errorf("unexpected ops %v", ops)
+	}
+	for _, bad := range []string{"", "read", "scan:10", "read:x", "read:0"} {
+		if _, err := parseDataBenchOps(bad); err == nil {
+			t.Errorf("ops %q: expected error", bad)
+		}
+	}
+
+	bins, err := parseDataBenchBins("int,str:100,blob,map:5,list,geo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []dataBenchBinSpec{{"int", 0}, {"str", 100}, {"blob", 1024}, {"map", 5}, {"list", 10}, {"geo", 0}}
+	if len(bins) != len(expected) {
+		t.Fatalf("unexpected bins %v", bins)
+	}
+	for i := range bins {
+		if bins[i] != expected[i] {
+			t.Errorf("bin %d: expected %v, got %v", i, expected[i], bins[i])
+		}
+	}
+	for _, bad := range []string{"", "float", "int:5", "str:0", "str,str"} {
+		if _, err := parseDataBenchBins(bad); err == nil {
+			t.Errorf("bins %q: expected error", bad)
+		}
+	}
+
+	expr, err := parseDataBenchExpression("int >= 500")
+	if err != nil || *expr != (dataBenchExpression{"int", ">=", 500}) {
+		t.Errorf("unexpected expression %v: %v", expr, err)
+	}
+	if expr, err = parseDataBenchExpression("int<-1"); err != nil || *expr != (dataBenchExpression{"int", "<", -1}) {
+		t.Errorf("unexpected expression %v: %v", expr, err)
+	}
+	for _, bad := range []string{"int", "int>=abc", ">=5"} {
+		if _, err := parseDataBenchExpression(bad); err == nil {
+			t.Errorf("expression %q: expected error", bad)
+		}
+	}
+
+	for _, bad := range []string{"gaussian", "zipfian:0.5", "hotspot:90", "hotspot:90:100", "uniform:1"} {
+		if _, err := parseDataBenchKeyDist(bad, 1, 1000); err == nil {
+			t.Errorf("key distribution %q: expected error", bad)
+		}
+	}
+}
+
+func TestDataBenchKeys(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, dist := range []string{"uniform", "zipfian", "zipfian:2", "hotspot:80:20"} {
+		keys, err := parseDataBenchKeyDist(dist, 101, 200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		z := keys.newZipf(r)
+		hot := 0
+		for i := 0; i < 10000; i++ {
+			key := keys.next(r, z)
+			if key < 101 || key > 200 {
+				t.Fatalf("%s: key %d out of range", dist, key)
+			}
+			if key <= 120 {
+				hot++
+			}
+		}
+		// the first 20% of keys get 20% of operations with uniform distribution, and most of them otherwise
+		if dist == "uniform" && (hot < 1500 || hot > 2500) {
+			t.Errorf("%s: %d operations on the first 20%% of keys", dist, hot)
+		}
+		if dist != "uniform" && hot < 7000 {
+			t.Errorf("%s: only %d operations on the first 20%% of keys", dist, hot)
+		}
+	}
+}
+
+func TestDataBenchHistogram(t *testing.T) {
+	h := newDataBenchHistogram()
+	for i := 0; i < 90; i++ {
+		h.record(800*time.Microsecond, false, false)
+	}
+	for i := 0; i < 9; i++ {
+		h.record(3*time.Millisecond, true, false)
+	}
+	h.record(100*time.Millisecond, false, true)
+	h.recordError()
+	if h.Count != 100 || h.Errors != 1 || h.NotFound != 9 || h.Filtered != 1 {
+		t.Errorf("unexpected counters %+v", h)
+	}
+	if p := h.percentile(50); p != time.Millisecond {
+		t.Errorf("p50: expected 1ms, got %s", p)
+	}
+	if p := h.percentile(99); p != 4*time.Millisecond {
+		t.Errorf("p99: expected 4ms, got %s", p)
+	}
+	if p := h.percentile(100); p != 100*time.Millisecond {
+		t.Errorf("p100: expected 100ms, got %s", p)
+	}
+	bands := h.bands()
+	if bands[0] != 90 || bands[2] != 9 || bands[len(bands)-1] != 1 {
+		t.Errorf("unexpected bands %v", bands)
+	}
+
+	other := newDataBenchHistogram()
+	other.record(2*time.Second, false, false)
+	merged := dataBenchMerge([]*dataBenchResult{
+		{Machines: []string{"a"}, Duration: 10 * time.Second, Ops: map[string]*dataBenchHistogram{"read": h}},
+		{Machines: []string{"b"}, Duration: 11 * time.Second, Ops: map[string]*dataBenchHistogram{"read": other}},
+	})
+	if merged.Duration != 11*time.Second || len(merged.Machines) != 2 || merged.Ops["read"].Count != 101 || merged.Ops["read"].MaxMicros != 2000000 || merged.Ops["read"].Buckets[len(dataBenchBucketBounds)] != 1 {
+		t.Errorf("unexpected merge result %+v", merged.Ops["read"])
+	}
+}
+
+func TestDataBenchLimiter(t *testing.T) {
+	l := newDataBenchLimiter(1000)
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		l.wait()
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("100 operations at 1000 tps took only %s", elapsed)
+	}
+	var unlimited *dataBenchLimiter
+	unlimited.wait()
+}
+
+func TestMockDataBench(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "mydc", "2")
+	binary := filepath.Join(t.TempDir(), "aerolab")
+	if err := os.WriteFile(binary, []byte("aerolab"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for node, machine := range map[int]string{1: "host1", 2: "host2"} {
+		h := newDataBenchHistogram()
+		h.record(time.Millisecond, false, false)
+		contents, _ := json.Marshal(&dataBenchResult{Machines: []string{machine}, Duration: time.Second, Ops: map[string]*dataBenchHistogram{"read": h}})
+		m.setResponse("mydc", node, []string{"/bin/bash", "-c", "cat " + dataBenchRemoteResultFile + " && rm -f " + dataBenchRemoteResultFile}, string(contents), nil)
+	}
+	before := len(m.getCalls())
+	mockRun(t, "data", "bench", "-n", "mydc", "-l", "1,2", "-t", binary, "-T", "1000", "-D", "1s", "-o", "read:1")
+	calls := m.getCalls()[before:]
+	for _, node := range []int{1, 2} {
+		if contents, ok := m.getFile("mydc", node, "/usr/local/bin/aerolab"); !ok || contents != "aerolab" {
+			t.Errorf("node %d: aerolab binary not uploaded", node)
+		}
+		found := false
+		for _, call := range calls {
+			if call.Method == "RunCommands" && call.Node == node && len(call.Command) > 0 && call.Command[0] == "/usr/local/bin/aerolab" {
+				args := strings.Join(call.Command, " ")
+				if !strings.Contains(args, "--tps 500") || !strings.Contains(args, "--result-file "+dataBenchRemoteResultFile) || !strings.Contains(args, "--start-at") {
+					t.Errorf("node %d: unexpected benchmark command %s", node, args)
+				}
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("node %d: benchmark not started", node)
+		}
+	}
+
+	// a single machine streams its table output, unless the json report is requested, which must be the only thing on stdout
+	m.setResponse("mydc", 1, []string{"/usr/local/bin/aerolab"}, "Benchmark results table\n", nil)
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	(&restCmd{}).resetBools()
+	err = a.main("aerolab", []string{"data", "bench", "-n", "mydc", "-l", "1", "-t", binary, "-D", "1s", "-o", "read:1", "-j"})
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := new(dataBenchResult)
+	if err = json.NewDecoder(r).Decode(report); err != nil || len(report.Machines) != 1 {
+		t.Errorf("expected a json report only, got %v %+v", err, report)
+	}
+	a.opts.Data.Bench.Json = false
+
+	(&restCmd{}).resetBools()
+	if err := a.main("aerolab", []string{"data", "bench", "-n", "mydc", "-o", "scan:1"}); err == nil || !strings.Contains(err.Error(), "invalid operation type") {
+		t.Errorf("expected invalid operation error, got %v", err)
+	}
+	a.opts.Data.Bench.Ops = "read:80,write:20"
+}
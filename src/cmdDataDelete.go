@@ -52,7 +52,7 @@ func (c *dataDeleteCmd) delete(args []string) error {
 		return err
 	}
 	var extraArgs []string
-	if a.opts.Config.Backend.Type == "docker" {
+	if a.opts.Config.Backend.Type == "docker" || a.opts.Config.Backend.Type == "kubernetes" {
 		found := false
 		for _, arg := range os.Args[1:] {
 			if strings.HasPrefix(arg, "-g") || strings.HasPrefix(arg, "--seed-node") {
@@ -73,7 +73,7 @@ func (c *dataDeleteCmd) delete(args []string) error {
 }
 
 func (c *dataInsertSelectorCmd) checkSeedPort() (string, error) {
-	if a.opts.Config.Backend.Type != "docker" {
+	if a.opts.Config.Backend.Type != "docker" && a.opts.Config.Backend.Type != "kubernetes" {
 		return c.SeedNode, nil
 	}
 	if c.SeedNode != "127.0.0.1:3000" {
@@ -97,7 +97,7 @@ func (c *dataInsertCmd) insert(args []string) error {
 		return err
 	}
 	var extraArgs []string
-	if a.opts.Config.Backend.Type == "docker" {
+	if a.opts.Config.Backend.Type == "docker" || a.opts.Config.Backend.Type == "kubernetes" {
 		found := false
 		for _, arg := range os.Args[1:] {
 			if strings.HasPrefix(arg, "-g") || strings.HasPrefix(arg, "--seed-node") {
@@ -118,6 +118,23 @@ func (c *dataInsertCmd) insert(args []string) error {
 }
 
 func (c *dataInsertSelectorCmd) unpack(args []string, extraArgs []string) error {
+	err := c.upload()
+	if err != nil {
+		return err
+	}
+	runCommand := []string{"/usr/local/bin/aerolab"}
+	runCommand = append(runCommand, os.Args[1:]...)
+	runCommand = append(runCommand, "-d", "1")
+	runCommand = append(runCommand, extraArgs...)
+	err = b.AttachAndRun(string(c.ClusterName), c.Node.Int(), runCommand, false)
+	if err != nil {
+		return fmt.Errorf("insert-data: backend.AttachAndRun(2): %s", err)
+	}
+	return nil
+}
+
+// upload copies the linux aerolab binary and the aerolab configuration file to the chosen node, so that aerolab can be run there
+func (c *dataInsertSelectorCmd) upload() error {
 	if c.IsClient {
 		b.WorkOnClients()
 	}
@@ -152,7 +169,6 @@ func (c *dataInsertSelectorCmd) unpack(args []string, extraArgs []string) error
 	}
 
 	// copy self to the chosen ClusterName_Node
-	// run self on the chosen ClusterName_Node with all parameters plus -d 1
 	stat, err := os.Stat(string(c.LinuxBinaryPath))
 	pfilelen := 0
 	if err != nil {
@@ -193,14 +209,6 @@ func (c *dataInsertSelectorCmd) unpack(args []string, extraArgs []string) error
 	if err != nil {
 		return fmt.Errorf("insert-data: backend.AttachAndRun(1): %s", err)
 	}
-	runCommand := []string{"/usr/local/bin/aerolab"}
-	runCommand = append(runCommand, os.Args[1:]...)
-	runCommand = append(runCommand, "-d", "1")
-	runCommand = append(runCommand, extraArgs...)
-	err = b.AttachAndRun(string(c.ClusterName), c.Node.Int(), runCommand, false)
-	if err != nil {
-		return fmt.Errorf("insert-data: backend.AttachAndRun(2): %s", err)
-	}
 	return nil
 }
 
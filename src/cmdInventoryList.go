@@ -401,6 +401,7 @@ func (c *inventoryListCmd) run(showClusters bool, showClients bool, showTemplate
 		fmt.Println()
 	}
 
+	isCloud := a.opts.Config.Backend.Type == "aws" || a.opts.Config.Backend.Type == "gcp"
 	if showClusters {
 		t.SetTitle(colorHiWhite.Sprint("CLUSTERS"))
 		t.ResetHeaders()
@@ -410,6 +411,8 @@ func (c *inventoryListCmd) run(showClusters bool, showClients bool, showTemplate
 			t.AppendHeader(table.Row{"ClusterName", "NodeNo", "ExpiresIn", "State", "PublicIP", "PrivateIP", "Owner", "AsdVer", "RunningCost", "Firewalls", "Arch", "Distro", "DistroVer", "Zone", "InstanceID"})
 		} else if a.opts.Config.Backend.Type == "aws" {
 			t.AppendHeader(table.Row{"ClusterName", "NodeNo", "ExpiresIn", "State", "PublicIP", "PrivateIP", "Owner", "AsdVer", "RunningCost", "Firewalls", "Arch", "Distro", "DistroVer", "Region", "InstanceID"})
+		} else if a.opts.Config.Backend.DockerHosts != "" || a.opts.Config.Backend.Type == "kubernetes" {
+			t.AppendHeader(table.Row{"ClusterName", "NodeNo", "State", "PublicIP", "PrivateIP", "ExposedPort", "Owner", "AsdVer", "Arch", "Distro", "DistroVer", "Host", "InstanceID", "ImageID"})
 		} else {
 			t.AppendHeader(table.Row{"ClusterName", "NodeNo", "State", "PublicIP", "PrivateIP", "ExposedPort", "Owner", "AsdVer", "Arch", "Distro", "DistroVer", "InstanceID", "ImageID"})
 		}
@@ -421,7 +424,7 @@ func (c *inventoryListCmd) run(showClusters bool, showClients bool, showTemplate
 				v.ClusterName,
 				v.NodeNo,
 			}
-			if a.opts.Config.Backend.Type != "docker" {
+			if isCloud {
 				if v.Expires == "" {
 					vv = append(vv, warnExp.Sprint("WARN: no expiry is set"))
 				} else {
@@ -441,11 +444,11 @@ func (c *inventoryListCmd) run(showClusters bool, showClients bool, showTemplate
 			}
 			vv = append(vv, v.State)
 			vv = append(vv, v.PublicIp, v.PrivateIp)
-			if a.opts.Config.Backend.Type == "docker" {
+			if !isCloud {
 				vv = append(vv, v.DockerExposePorts)
 			}
 			vv = append(vv, v.Owner, strings.ReplaceAll(v.AerospikeVersion, "-", "."))
-			if a.opts.Config.Backend.Type != "docker" {
+			if isCloud {
 				spot := ""
 				if v.AwsIsSpot {
 					spot = " (spot)"
@@ -454,19 +457,19 @@ func (c *inventoryListCmd) run(showClusters bool, showClients bool, showTemplate
 				vv = append(vv, strings.Join(v.Firewalls, "\n"))
 			}
 			vv = append(vv, v.Arch, v.Distribution, strings.ReplaceAll(v.OSVersion, "-", "."))
-			if a.opts.Config.Backend.Type != "docker" {
+			if isCloud || a.opts.Config.Backend.DockerHosts != "" || a.opts.Config.Backend.Type == "kubernetes" {
 				vv = append(vv, v.Zone)
 			}
 			vv = append(vv, v.InstanceId)
-			if a.opts.Config.Backend.Type == "docker" {
+			if !isCloud {
 				vv = append(vv, v.ImageId)
 			}
 			t.AppendRow(vv)
 		}
 		fmt.Println(t.Render())
-		if a.opts.Config.Backend.Type != "docker" {
+		if isCloud {
 			fmt.Fprint(os.Stderr, "* instance Running Cost displays only the cost of owning the instance in a running state for the duration it was running so far. It does not account for taxes, disk, network or transfer costs.\n\n")
-		} else {
+		} else if a.opts.Config.Backend.Type == "docker" {
 			fmt.Fprint(os.Stderr, "* to connect directly to the cluster (non-docker-desktop), execute 'aerolab cluster list' and connect to the node IP on the given exposed port (or configured aerospike services port - default 3000)\n")
 			fmt.Fprint(os.Stderr, "* to connect to the cluster when using Docker Desktop, execute 'aerolab cluster list` and connect to IP 127.0.0.1:EXPOSED_PORT with a connect policy of `--services-alternate`\n\n")
 		}
@@ -481,6 +484,8 @@ func (c *inventoryListCmd) run(showClusters bool, showClients bool, showTemplate
 			t.AppendHeader(table.Row{"ClusterName", "NodeNo", "ExpiresIn", "State", "PublicIP", "PrivateIP", "ClientType", "AccessURL", "AccessPort", "Owner", "AsdVer", "RunningCost", "Firewalls", "Arch", "Distro", "DistroVer", "Zone", "InstanceID"})
 		} else if a.opts.Config.Backend.Type == "aws" {
 			t.AppendHeader(table.Row{"ClusterName", "NodeNo", "ExpiresIn", "State", "PublicIP", "PrivateIP", "ClientType", "AccessURL", "AccessPort", "Owner", "AsdVer", "RunningCost", "Firewalls", "Arch", "Distro", "DistroVer", "Region", "InstanceID"})
+		} else if a.opts.Config.Backend.DockerHosts != "" || a.opts.Config.Backend.Type == "kubernetes" {
+			t.AppendHeader(table.Row{"ClusterName", "NodeNo", "State", "PublicIP", "PrivateIP", "ClientType", "AccessURL", "AccessPort", "Owner", "AsdVer", "Arch", "Distro", "DistroVer", "Host", "InstanceID", "ImageID"})
 		} else {
 			t.AppendHeader(table.Row{"ClusterName", "NodeNo", "State", "PublicIP", "PrivateIP", "ClientType", "AccessURL", "AccessPort", "Owner", "AsdVer", "Arch", "Distro", "DistroVer", "InstanceID", "ImageID"})
 		}
@@ -489,7 +494,7 @@ func (c *inventoryListCmd) run(showClusters bool, showClients bool, showTemplate
 				v.ClientName,
 				v.NodeNo,
 			}
-			if a.opts.Config.Backend.Type != "docker" {
+			if isCloud {
 				if v.Expires == "" {
 					vv = append(vv, warnExp.Sprint("WARN: no expiry is set"))
 				} else {
@@ -510,7 +515,7 @@ func (c *inventoryListCmd) run(showClusters bool, showClients bool, showTemplate
 			vv = append(vv, v.State)
 			vv = append(vv, v.PublicIp, v.PrivateIp, v.ClientType, v.AccessUrl, v.AccessPort)
 			vv = append(vv, v.Owner, strings.ReplaceAll(v.AerospikeVersion, "-", "."))
-			if a.opts.Config.Backend.Type != "docker" {
+			if isCloud {
 				spot := ""
 				if v.AwsIsSpot {
 					spot = " (spot)"
@@ -519,11 +524,11 @@ func (c *inventoryListCmd) run(showClusters bool, showClients bool, showTemplate
 				vv = append(vv, strings.Join(v.Firewalls, "\n"))
 			}
 			vv = append(vv, v.Arch, v.Distribution, strings.ReplaceAll(v.OSVersion, "-", "."))
-			if a.opts.Config.Backend.Type != "docker" {
+			if isCloud || a.opts.Config.Backend.DockerHosts != "" || a.opts.Config.Backend.Type == "kubernetes" {
 				vv = append(vv, v.Zone)
 			}
 			vv = append(vv, v.InstanceId)
-			if a.opts.Config.Backend.Type == "docker" {
+			if !isCloud {
 				vv = append(vv, v.ImageId)
 			}
 			t.AppendRow(vv)
@@ -531,7 +536,7 @@ func (c *inventoryListCmd) run(showClusters bool, showClients bool, showTemplate
 		fmt.Println(t.Render())
 		if a.opts.Config.Backend.Type == "docker" {
 			fmt.Fprint(os.Stderr, "* if using Docker Desktop and forwaring ports by exposing them (-e ...), use IP 127.0.0.1 for the Access URL\n\n")
-		} else {
+		} else if isCloud {
 			fmt.Fprint(os.Stderr, "* instance Running Cost displays only the cost of owning the instance in a running state for the duration it was running so far. It does not account for taxes, disk, network or transfer costs.\n\n")
 		}
 	}
@@ -5,9 +5,10 @@ import (
 )
 
 type logsCmd struct {
-	Get  logsGetCmd  `command:"get" subcommands-optional:"true" description:"Download logs from Aerospike logs"`
-	Show logsShowCmd `command:"show" subcommands-optional:"true" description:"Print logs from an Aerospike node"`
-	Help helpCmd     `command:"help" subcommands-optional:"true" description:"Print help"`
+	Get       logsGetCmd       `command:"get" subcommands-optional:"true" description:"Download logs from Aerospike logs"`
+	Show      logsShowCmd      `command:"show" subcommands-optional:"true" description:"Print logs from an Aerospike node"`
+	Anonymize logsAnonymizeCmd `command:"anonymize" subcommands-optional:"true" description:"Replace hostnames, IPs, namespace, set and user names in log and collectinfo files, using the AGI anonymize rules"`
+	Help      helpCmd          `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
 func (c *logsCmd) Execute(args []string) error {
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aerospike/aerolab/ingest"
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+type logsAnonymizeCmd struct {
+	Input        []string       `short:"i" long:"input" description:"log or collectinfo file, or a directory to anonymize recursively; can be specified multiple times"`
+	Output       flags.Filename `short:"o" long:"output" description:"directory to write the anonymized files to, keeping the names and directory structure of the input"`
+	InPlace      bool           `long:"in-place" description:"replace the input files with their anonymized version instead of writing them to an output directory"`
+	RulesFile    flags.Filename `short:"r" long:"rules" description:"anonymize rules yaml file; default: the built-in rules, as used by AGI"`
+	MapFile      flags.Filename `short:"m" long:"map" description:"pseudonymisation map json file; loaded if it exists and updated, so that tokens stay consistent across runs; allows reversing the tokens, keep it private; default: do not save the map"`
+	DefaultRules bool           `long:"default-rules" description:"print the built-in anonymize rules file, as a starting point for custom rules, and exit"`
+	Help         helpCmd        `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *logsAnonymizeCmd) Execute(args []string) error {
+	if earlyProcessV2(args, false) {
+		return nil
+	}
+	if c.DefaultRules {
+		_, err := os.Stdout.Write(ingest.DefaultAnonymizeRules())
+		return err
+	}
+	return c.run(os.Stdout)
+}
+
+type logsAnonymizeFile struct {
+	src string
+	dst string
+}
+
+func (c *logsAnonymizeCmd) run(w io.Writer) error {
+	if len(c.Input) == 0 {
+		return errors.New("at least one input is required, see: aerolab logs anonymize help")
+	}
+	if (c.Output == "") == !c.InPlace {
+		return errors.New("exactly one of --output or --in-place is required")
+	}
+	rules, err := ingest.LoadAnonymizeRules(string(c.RulesFile))
+	if err != nil {
+		return err
+	}
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+	anon, err := ingest.NewAnonymizer(rules, string(c.MapFile))
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, f := range files {
+		err = os.MkdirAll(filepath.Dir(f.dst), 0755)
+		if err == nil {
+			err = anon.File(f.src, f.dst)
+		}
+		if err == ingest.ErrAnonymizeBinary {
+			fmt.Fprintf(w, "SKIPPED %s: %s\n", f.src, err)
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(w, "ERROR %s: %s\n", f.src, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(w, "OK %s -> %s\n", f.src, f.dst)
+	}
+	err = anon.SaveMap(string(c.MapFile))
+	if err != nil {
+		return fmt.Errorf("failed to save map: %s", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d files could not be anonymized", failed)
+	}
+	return nil
+}
+
+// files lists the files to anonymize and their destinations; plain files come first, so that names they reveal are known when collectinfo archives are processed
+func (c *logsAnonymizeCmd) files() ([]*logsAnonymizeFile, error) {
+	files := []*logsAnonymizeFile{}
+	add := func(src string, rel string) {
+		dst := src
+		if !c.InPlace {
+			dst = filepath.Join(string(c.Output), rel)
+		}
+		files = append(files, &logsAnonymizeFile{src: src, dst: dst})
+	}
+	for _, input := range c.Input {
+		input = filepath.Clean(input)
+		st, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+		if !st.IsDir() {
+			add(input, filepath.Base(input))
+			continue
+		}
+		err = filepath.WalkDir(input, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(input, p)
+			if err != nil {
+				return err
+			}
+			add(p, filepath.Join(filepath.Base(input), rel))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return !logsAnonymizeIsArchive(files[i].src) && logsAnonymizeIsArchive(files[j].src)
+	})
+	return files, nil
+}
+
+func logsAnonymizeIsArchive(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range []string{".tgz", ".gz", ".zip"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+func TestLogsAnonymize(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "logs")
+	if err := os.MkdirAll(filepath.Join(in, "node1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"node1/aerospike.log": "Nov 01 2023 10:00:10 GMT: INFO (info): (ticker.c:170) {bar} node 10.0.0.1:3000\n",
+		"node2.log":           "Nov 01 2023 10:00:10 GMT: INFO (info): (ticker.c:170) {bar} node 10.0.0.1:3000\r\n",
+		"core.bin":            "\x7fELF\x00\x01",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(in, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &logsAnonymizeCmd{
+		Input:   []string{in},
+		Output:  flags.Filename(filepath.Join(dir, "out")),
+		MapFile: flags.Filename(filepath.Join(dir, "map.json")),
+	}
+	out := new(bytes.Buffer)
+	if err := c.run(out); err != nil {
+		t.Fatalf("%s\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "SKIPPED "+filepath.Join(in, "core.bin")) {
+		t.Errorf("binary file not skipped:\n%s", out.String())
+	}
+	for name, expect := range map[string]string{
+		"node1/aerospike.log": "Nov 01 2023 10:00:10 GMT: INFO (info): (ticker.c:170) {namespace1} node 240.0.0.1:3000\n",
+		"node2.log":           "Nov 01 2023 10:00:10 GMT: INFO (info): (ticker.c:170) {namespace1} node 240.0.0.1:3000\r\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, "out", "logs", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != expect {
+			t.Errorf("%s: unexpected contents %q", name, string(got))
+		}
+	}
+
+	// a second run reuses the map, anonymizing in place
+	c = &logsAnonymizeCmd{
+		Input:   []string{filepath.Join(in, "node2.log")},
+		InPlace: true,
+		MapFile: c.MapFile,
+	}
+	os.WriteFile(filepath.Join(in, "node2.log"), []byte("{foo} 10.0.0.2 {bar} 10.0.0.1\n"), 0644)
+	if err := c.run(new(bytes.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(in, "node2.log")); string(got) != "{namespace2} 240.0.0.2 {namespace1} 240.0.0.1\n" {
+		t.Errorf("unexpected in-place contents %q", string(got))
+	}
+	if err := (&logsAnonymizeCmd{Input: []string{in}}).run(new(bytes.Buffer)); err == nil {
+		t.Error("expected error without --output or --in-place")
+	}
+}
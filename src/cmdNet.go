@@ -7,6 +7,7 @@ type netCmd struct {
 	Unblock   netUnblockCmd   `command:"unblock" subcommands-optional:"true" description:"Unblock a port"`
 	List      netListCmd      `command:"list" subcommands-optional:"true" description:"List blocked ports"`
 	LossDelay netLossDelayCmd `command:"loss-delay" subcommands-optional:"true" description:"Simulate packet loss or latencies"`
+	Chaos     netChaosCmd     `command:"chaos" subcommands-optional:"true" description:"Run timed network and node failure scenarios"`
 	Help      helpCmd         `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
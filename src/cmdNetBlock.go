@@ -40,6 +40,9 @@ func (c *netBlockCmd) run(args []string, blockString string) error {
 	} else {
 		log.Print("Running net.unblock")
 	}
+	if err := dockerCheckNetRules(); err != nil {
+		return err
+	}
 	log.Print("Gathering cluster information")
 	if c.IsSourceClient {
 		b.WorkOnClients()
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bestmethod/inslice"
+	flags "github.com/rglonek/jeddevdk-goflags"
+	"gopkg.in/yaml.v3"
+)
+
+/* example scenario.yaml
+name: rack-b-partition
+steps:
+  - name: partition node 2 from rack B
+    at: 0s
+    duration: 90s
+    block:                        # same payload as rest-api /net/block; reverted using /net/unblock
+      SourceClusterName: mydc
+      SourceNodeList: "2"
+      DestinationClusterName: mydc
+      DestinationNodeList: "3,4"
+      Ports: "3001,3002"
+  - name: delay xdr link
+    at: 10s
+    duration: 60s
+    loss-delay:                   # same payload as rest-api /net/loss-delay; Action set is applied, del reverts
+      SourceClusterName: mydc
+      DestinationClusterName: mydc-xdr
+      Delay: 200ms
+  - name: flap node 3
+    at: 30s
+    duration: 2m
+    every: 30s                    # alternate between applying and reverting the block
+    block:
+      SourceClusterName: mydc
+      SourceNodeList: "3"
+      DestinationClusterName: mydc
+  - name: kill asd
+    at: 2m
+    duration: 30s                 # asd is started again after this time
+    kill:
+      ClusterName: mydc
+      Nodes: random               # or a comma-separated node list
+*/
+
+type netChaosCmd struct {
+	Run  netChaosRunCmd `command:"run" subcommands-optional:"true" description:"Run a chaos scenario file"`
+	Help helpCmd        `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *netChaosCmd) Execute(args []string) error {
+	a.parser.WriteHelp(os.Stderr)
+	os.Exit(1)
+	return nil
+}
+
+type netChaosRunCmd struct {
+	File     flags.Filename `short:"f" long:"file" description:"Scenario definition file (yaml)" default:"scenario.yaml"`
+	EventLog flags.Filename `short:"o" long:"event-log" description:"File to write the event log to, one JSON annotation per line; default: chaos-NAME-TIMESTAMP.json"`
+	Help     helpCmd        `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+type netChaosScenario struct {
+	Name  string          `yaml:"name"`
+	Steps []*netChaosStep `yaml:"steps"`
+}
+
+type netChaosStep struct {
+	Name      string                 `yaml:"name"`
+	At        time.Duration          `yaml:"at"`
+	Duration  time.Duration          `yaml:"duration"`
+	Every     time.Duration          `yaml:"every"`
+	Block     map[string]interface{} `yaml:"block"`
+	LossDelay map[string]interface{} `yaml:"loss-delay"`
+	Kill      *netChaosKill          `yaml:"kill"`
+	active    bool
+	killed    []int
+}
+
+type netChaosKill struct {
+	ClusterName string `yaml:"ClusterName"`
+	Nodes       string `yaml:"Nodes"`
+	Signal      string `yaml:"Signal"`
+}
+
+// netChaosEvent is a single point on the scenario timeline
+type netChaosEvent struct {
+	at     time.Duration
+	step   *netChaosStep
+	revert bool
+	toggle bool
+}
+
+// netChaosAnnotation is an event log line; time, text and tags are in the format of the grafana annotations api
+type netChaosAnnotation struct {
+	Time      int64    `json:"time"`
+	Timestamp string   `json:"timestamp"`
+	Scenario  string   `json:"scenario"`
+	Step      string   `json:"step,omitempty"`
+	Event     string   `json:"event"`
+	Text      string   `json:"text"`
+	Tags      []string `json:"tags"`
+}
+
+type netChaosEventLog struct {
+	lock     sync.Mutex
+	f        *os.File
+	scenario string
+}
+
+func (l *netChaosEventLog) write(step string, event string, text string) {
+	now := time.Now()
+	if step != "" {
+		log.Printf("Chaos: %s: %s: %s", step, event, text)
+	} else {
+		log.Printf("Chaos: %s: %s", event, text)
+	}
+	line, _ := json.Marshal(netChaosAnnotation{
+		Time:      now.UnixMilli(),
+		Timestamp: now.UTC().Format(time.RFC3339Nano),
+		Scenario:  l.scenario,
+		Step:      step,
+		Event:     event,
+		Text:      text,
+		Tags:      []string{"aerolab", "chaos", l.scenario, event},
+	})
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.f.Write(append(line, '\n'))
+}
+
+func (c *netChaosRunCmd) Execute(args []string) error {
+	if earlyProcess(args) {
+		return nil
+	}
+	log.Print("Running net.chaos.run")
+	scenario, err := c.load()
+	if err != nil {
+		return err
+	}
+	for _, step := range scenario.Steps {
+		if step.Block != nil || step.LossDelay != nil {
+			if err := dockerCheckNetRules(); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	timeline := scenario.timeline()
+	for _, ev := range timeline {
+		log.Printf("Chaos: timeline: %8s %-6s %s", ev.at, ev.action(), ev.step.Name)
+	}
+
+	eventLog := string(c.EventLog)
+	if eventLog == "" {
+		eventLog = "chaos-" + scenario.Name + "-" + time.Now().Format("20060102-150405") + ".json"
+	}
+	f, err := os.OpenFile(eventLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	events := &netChaosEventLog{f: f, scenario: scenario.Name}
+	log.Printf("Chaos: writing event log to %s", eventLog)
+
+	// on ctrl-c, stop the timeline and wait for all rules to be reverted before allowing aerolab to exit
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	stopOnce := new(sync.Once)
+	addShutdownHandler("net-chaos", func(os.Signal) {
+		stopOnce.Do(func() { close(stop) })
+		<-finished
+	})
+	defer delShutdownHandler("net-chaos")
+	defer close(finished)
+
+	events.write("", "start", fmt.Sprintf("scenario %s started with %d steps", scenario.Name, len(scenario.Steps)))
+	err = scenario.run(timeline, events, stop)
+	interrupted := false
+	select {
+	case <-stop:
+		interrupted = true
+	default:
+	}
+	rerr := scenario.revertAll(events)
+	switch {
+	case err != nil:
+		events.write("", "end", "scenario aborted: "+err.Error())
+	case interrupted:
+		events.write("", "end", "scenario interrupted")
+	default:
+		events.write("", "end", "scenario finished")
+	}
+	if err != nil {
+		return err
+	}
+	if rerr != nil {
+		return rerr
+	}
+	log.Print("Done")
+	return nil
+}
+
+func (c *netChaosRunCmd) load() (*netChaosScenario, error) {
+	data, err := os.ReadFile(string(c.File))
+	if err != nil {
+		return nil, err
+	}
+	scenario := new(netChaosScenario)
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err = dec.Decode(scenario)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", c.File, err)
+	}
+	if scenario.Name == "" {
+		scenario.Name = "chaos"
+	}
+	if len(scenario.Steps) == 0 {
+		return nil, errors.New("scenario has no steps")
+	}
+	for i, step := range scenario.Steps {
+		if step.Name == "" {
+			step.Name = "step " + strconv.Itoa(i+1)
+		}
+		err = step.validate()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", step.Name, err)
+		}
+	}
+	return scenario, nil
+}
+
+func (s *netChaosStep) validate() error {
+	actions := 0
+	if s.Block != nil {
+		actions++
+		if err := netChaosCheckOptions([]string{"net", "block"}, s.Block); err != nil {
+			return err
+		}
+	}
+	if s.LossDelay != nil {
+		actions++
+		if err := netChaosCheckOptions([]string{"net", "loss-delay"}, s.LossDelay); err != nil {
+			return err
+		}
+	}
+	if s.Kill != nil {
+		actions++
+		if s.Kill.ClusterName == "" {
+			s.Kill.ClusterName = "mydc"
+		}
+		if s.Kill.Signal == "" {
+			s.Kill.Signal = "KILL"
+		}
+		if s.Kill.Nodes == "" {
+			return errors.New("kill: Nodes must be specified, either as a list or as 'random'")
+		}
+	}
+	if actions != 1 {
+		return errors.New("exactly one of block, loss-delay or kill must be specified")
+	}
+	if s.At < 0 || s.Duration < 0 || s.Every < 0 {
+		return errors.New("at, duration and every cannot be negative")
+	}
+	if s.Every > 0 {
+		if s.Kill != nil {
+			return errors.New("every is not supported for kill")
+		}
+		if s.Duration == 0 {
+			return errors.New("every requires a duration")
+		}
+	}
+	return nil
+}
+
+// netChaosCheckOptions validates step options against the command they will be passed to, so that mistakes are found before the scenario starts
+func netChaosCheckOptions(command []string, options map[string]interface{}) error {
+	v, err := findApplyCommand(command)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(reflect.New(v.Type()).Interface())
+	if err != nil {
+		return fmt.Errorf("%s: invalid options: %s", strings.Join(command, " "), err)
+	}
+	return nil
+}
+
+// timeline lists all apply, toggle and revert events in order; steps without duration are reverted at the end of the scenario
+func (s *netChaosScenario) timeline() []*netChaosEvent {
+	events := []*netChaosEvent{}
+	for _, step := range s.Steps {
+		events = append(events, &netChaosEvent{at: step.At, step: step})
+		if step.Duration == 0 {
+			continue
+		}
+		if step.Every > 0 {
+			for at := step.At + step.Every; at < step.At+step.Duration; at += step.Every {
+				events = append(events, &netChaosEvent{at: at, step: step, toggle: true})
+			}
+		}
+		events = append(events, &netChaosEvent{at: step.At + step.Duration, step: step, revert: true})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].at == events[j].at {
+			return events[i].revert && !events[j].revert
+		}
+		return events[i].at < events[j].at
+	})
+	return events
+}
+
+func (e *netChaosEvent) action() string {
+	if e.revert {
+		return "revert"
+	}
+	if e.toggle {
+		return "toggle"
+	}
+	return "apply"
+}
+
+// run executes the timeline until done, until an action fails, or until stop is closed
+func (s *netChaosScenario) run(timeline []*netChaosEvent, events *netChaosEventLog, stop chan struct{}) error {
+	start := time.Now()
+	for _, ev := range timeline {
+		wait := time.Until(start.Add(ev.at))
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-stop:
+				return nil
+			}
+		}
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		var err error
+		switch {
+		case ev.revert:
+			err = ev.step.revert(events)
+		case ev.toggle && ev.step.active:
+			err = ev.step.revert(events)
+		default:
+			err = ev.step.apply(events)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", ev.step.Name, err)
+		}
+	}
+	return nil
+}
+
+// revertAll reverts all steps which are still active, latest first
+func (s *netChaosScenario) revertAll(events *netChaosEventLog) error {
+	var rerr error
+	for i := len(s.Steps) - 1; i >= 0; i-- {
+		err := s.Steps[i].revert(events)
+		if err != nil {
+			rerr = errors.New("some rules could not be reverted, see the event log")
+		}
+	}
+	return rerr
+}
+
+func (s *netChaosStep) apply(events *netChaosEventLog) error {
+	// mark active before applying, so that partially applied rules are also reverted
+	s.active = true
+	var err error
+	var text string
+	switch {
+	case s.Block != nil:
+		text = "block " + netChaosDescribe(s.Block)
+		err = runApplyCommand([]string{"net", "block"}, s.Block, nil, nil)
+	case s.LossDelay != nil:
+		text = "loss-delay " + netChaosDescribe(s.LossDelay)
+		err = runApplyCommand([]string{"net", "loss-delay"}, s.LossDelay, map[string]interface{}{"Action": "set"}, nil)
+	case s.Kill != nil:
+		s.killed, err = s.killNodes()
+		text = fmt.Sprintf("kill -%s asd on cluster %s nodes %s", s.Kill.Signal, s.Kill.ClusterName, intSliceToString(s.killed, ","))
+	}
+	if err != nil {
+		events.write(s.Name, "error", text+": "+err.Error())
+		return err
+	}
+	events.write(s.Name, "apply", text)
+	return nil
+}
+
+func (s *netChaosStep) revert(events *netChaosEventLog) error {
+	if !s.active {
+		return nil
+	}
+	s.active = false
+	var err error
+	var text string
+	switch {
+	case s.Block != nil:
+		text = "unblock " + netChaosDescribe(s.Block)
+		err = runApplyCommand([]string{"net", "unblock"}, s.Block, nil, nil)
+	case s.LossDelay != nil:
+		text = "remove loss-delay " + netChaosDescribe(s.LossDelay)
+		err = runApplyCommand([]string{"net", "loss-delay"}, s.LossDelay, map[string]interface{}{"Action": "del", "Delay": "", "Loss": "", "Rate": ""}, nil)
+	case s.Kill != nil:
+		text = fmt.Sprintf("start asd on cluster %s nodes %s", s.Kill.ClusterName, intSliceToString(s.killed, ","))
+		if len(s.killed) > 0 {
+			var out [][]byte
+			out, err = b.RunCommands(s.Kill.ClusterName, [][]string{{"service", "aerospike", "start"}}, s.killed)
+			if err != nil {
+				err = fmt.Errorf("%s: %s", err, out)
+			}
+		}
+	}
+	if err != nil {
+		events.write(s.Name, "error", text+": "+err.Error())
+		return err
+	}
+	events.write(s.Name, "revert", text)
+	return nil
+}
+
+func (s *netChaosStep) killNodes() ([]int, error) {
+	nodes, err := b.NodeListInCluster(s.Kill.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("cluster %s not found", s.Kill.ClusterName)
+	}
+	kill := []int{}
+	if s.Kill.Nodes == "random" {
+		kill = append(kill, nodes[rand.Intn(len(nodes))])
+	} else {
+		for _, node := range strings.Split(s.Kill.Nodes, ",") {
+			no, err := strconv.Atoi(strings.TrimSpace(node))
+			if err != nil {
+				return nil, fmt.Errorf("invalid node %s", node)
+			}
+			if !inslice.HasInt(nodes, no) {
+				return nil, fmt.Errorf("node %d does not exist in cluster %s", no, s.Kill.ClusterName)
+			}
+			kill = append(kill, no)
+		}
+	}
+	out, err := b.RunCommands(s.Kill.ClusterName, [][]string{{"pkill", "-" + s.Kill.Signal, "-x", "asd"}}, kill)
+	if err != nil {
+		return kill, fmt.Errorf("%s: %s", err, out)
+	}
+	return kill, nil
+}
+
+// netChaosDescribe prints step options in a stable order for the event log
+func netChaosDescribe(options map[string]interface{}) string {
+	keys := []string{}
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ret := []string{}
+	for _, k := range keys {
+		ret = append(ret, fmt.Sprintf("%s=%v", k, options[k]))
+	}
+	return strings.Join(ret, " ")
+}
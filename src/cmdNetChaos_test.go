@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMockNetChaosRun(t *testing.T) {
+	m, features := mockSetup(t)
+	mockCreateCluster(t, features, "create", "mydc", "3")
+	mockCreateCluster(t, features, "create", "mydc-xdr", "1")
+	dir := t.TempDir()
+	scenario := filepath.Join(dir, "scenario.yaml")
+	eventLog := filepath.Join(dir, "events.json")
+	err := os.WriteFile(scenario, []byte(`name: test
+steps:
+  - name: partition
+    at: 0s
+    duration: 30ms
+    block:
+      SourceClusterName: mydc
+      SourceNodeList: "2"
+      DestinationClusterName: mydc
+      DestinationNodeList: "3"
+  - name: delay
+    at: 10ms
+    loss-delay:
+      SourceClusterName: mydc
+      DestinationClusterName: mydc-xdr
+      Delay: 200ms
+  - name: flap
+    at: 0s
+    every: 20ms
+    duration: 50ms
+    block:
+      SourceClusterName: mydc
+      SourceNodeList: "1"
+      DestinationClusterName: mydc
+      DestinationNodeList: "3"
+      Ports: "3002"
+  - name: kill
+    at: 20ms
+    duration: 10ms
+    kill:
+      ClusterName: mydc
+      Nodes: "1"
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := len(m.getCalls())
+	mockRun(t, "net", "chaos", "run", "-f", scenario, "-o", eventLog)
+
+	// iptables rules are added and removed on the destination node, loss-delay set and removed on the source nodes
+	var iptablesAdd, iptablesDel, tcset, tcdel, kill, start int
+	for _, call := range m.getCalls()[before:] {
+		cmd := strings.Join(call.Command, " ")
+		switch {
+		case strings.Contains(cmd, "iptables -I INPUT"):
+			iptablesAdd++
+		case strings.Contains(cmd, "iptables -D INPUT"):
+			iptablesDel++
+		case strings.Contains(cmd, "tcset"):
+			if !strings.Contains(cmd, "--delay 200ms") {
+				t.Errorf("tcset without delay: %s", cmd)
+			}
+			tcset++
+		case strings.Contains(cmd, "tcdel"):
+			if strings.Contains(cmd, "--delay") {
+				t.Errorf("tcdel with delay: %s", cmd)
+			}
+			tcdel++
+		case cmd == "pkill -KILL -x asd":
+			if call.Node != 1 {
+				t.Errorf("asd killed on node %d", call.Node)
+			}
+			kill++
+		case cmd == "service aerospike start" && call.ClusterName == "mydc":
+			start++
+		}
+	}
+	// partition: 1 add, 1 del; flap: applied at 0ms and 40ms, reverted at 20ms and 50ms
+	if iptablesAdd != 3 || iptablesDel != 3 {
+		t.Errorf("expected 3 iptables additions and removals, got %d and %d", iptablesAdd, iptablesDel)
+	}
+	// one rule per source node for each of the destination node's public and internal IPs
+	if tcset != 6 || tcdel != 6 {
+		t.Errorf("expected loss-delay set and removed 6 times, got %d and %d", tcset, tcdel)
+	}
+	if kill != 1 || start != 1 {
+		t.Errorf("expected asd killed and started once, got %d and %d", kill, start)
+	}
+
+	f, err := os.Open(eventLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	counts := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		ev := netChaosAnnotation{}
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("invalid event log line %s: %s", scanner.Text(), err)
+		}
+		if ev.Time == 0 || ev.Scenario != "test" || len(ev.Tags) == 0 {
+			t.Errorf("incomplete event %s", scanner.Text())
+		}
+		counts[ev.Event]++
+	}
+	if counts["start"] != 1 || counts["end"] != 1 || counts["apply"] != 5 || counts["revert"] != 5 || counts["error"] != 0 {
+		t.Errorf("unexpected event counts: %v", counts)
+	}
+}
+
+func TestNetChaosValidate(t *testing.T) {
+	for _, step := range []*netChaosStep{
+		{Name: "none"},
+		{Name: "two", Kill: &netChaosKill{Nodes: "1"}, Block: map[string]interface{}{}},
+		{Name: "unknown option", Block: map[string]interface{}{"Bogus": 1}},
+		{Name: "every without duration", Every: 1, Block: map[string]interface{}{}},
+		{Name: "kill without nodes", Kill: &netChaosKill{}},
+	} {
+		if err := step.validate(); err == nil {
+			t.Errorf("%s: expected validation error", step.Name)
+		}
+	}
+}
@@ -31,6 +31,9 @@ func (c *netLossDelayCmd) Execute(args []string) error {
 	}
 
 	log.Print("Running net.loss-delay")
+	if err := dockerCheckNetRules(); err != nil {
+		return err
+	}
 
 	// check cluster exists already
 	clusterList := make(map[string]bool)
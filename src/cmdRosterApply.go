@@ -123,7 +123,7 @@ func (c *rosterApplyCmd) runApply(args []string) error {
 	}
 
 	rosterCmd := []string{"asinfo", "-v", "roster-set:namespace=" + c.Namespace + ";nodes=" + newRoster}
-	if a.opts.Config.Backend.Type != "docker" {
+	if backendShellInterpreted() {
 		rosterCmd = []string{"asinfo", "-v", "roster-set:namespace=" + c.Namespace + "\\;nodes=" + newRoster}
 	}
 
@@ -0,0 +1,190 @@
+package grafanafix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tag of all annotations created from the findings report; the dashboards show them by severity
+const findingsTag = "agi-finding"
+
+// embedded dashboards which show the findings annotations
+var findingsDashboards = []string{"general-stats.json", "histograms.json", "namespace-transactions.json", "namespace.json", "warnings-and-errors.json", "xdr.json"}
+
+// findingsDashboardAnnotations adds one annotation query per severity, showing the findings annotations, to the annotations list of a dashboard
+func findingsDashboardAnnotations(contents []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(contents))
+	dec.UseNumber()
+	dashboard := make(map[string]interface{})
+	err := dec.Decode(&dashboard)
+	if err != nil {
+		return nil, err
+	}
+	annotations, _ := dashboard["annotations"].(map[string]interface{})
+	if annotations == nil {
+		annotations = make(map[string]interface{})
+		dashboard["annotations"] = annotations
+	}
+	list, _ := annotations["list"].([]interface{})
+	for _, severity := range []struct {
+		name   string
+		color  string
+		enable bool
+	}{{"critical", "red", true}, {"warning", "orange", true}, {"info", "blue", false}} {
+		list = append(list, map[string]interface{}{
+			"datasource": map[string]interface{}{
+				"type": "datasource",
+				"uid":  "grafana",
+			},
+			"enable":    severity.enable,
+			"iconColor": severity.color,
+			"name":      "Findings: " + severity.name,
+			"target": map[string]interface{}{
+				"limit":    500,
+				"matchAny": false,
+				"refId":    "Anno",
+				"tags":     []string{findingsTag, severity.name},
+				"type":     "tags",
+			},
+		})
+	}
+	annotations["list"] = list
+	return json.Marshal(dashboard)
+}
+
+// finding is the subset of the ingest findings report used to create annotations
+type finding struct {
+	Rule        string    `json:"rule"`
+	Severity    string    `json:"severity"`
+	Message     string    `json:"message"`
+	ClusterName string    `json:"clusterName"`
+	NodeIdent   string    `json:"nodeIdent"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Count       int       `json:"count"`
+}
+
+type findingsReport struct {
+	Findings []*finding `json:"findings"`
+}
+
+// findingsAnnotations converts findings to organization-wide annotations, tagged with the findings tag, severity, rule, cluster and node
+func findingsAnnotations(findings []*finding) []annotation {
+	annotations := []annotation{}
+	for _, f := range findings {
+		tags := []string{findingsTag, strings.ToLower(f.Severity), f.Rule}
+		if f.ClusterName != "" {
+			tags = append(tags, "cluster:"+f.ClusterName)
+		}
+		if f.NodeIdent != "" {
+			tags = append(tags, "node:"+f.NodeIdent)
+		}
+		annotations = append(annotations, annotation{
+			Time:    int(f.Start.UnixMilli()),
+			TimeEnd: int(f.End.UnixMilli()),
+			Tags:    tags,
+			Text:    fmt.Sprintf("%s: %s (%s %s, %d matches)", strings.ToUpper(f.Severity), f.Message, f.ClusterName, f.NodeIdent, f.Count),
+		})
+	}
+	return annotations
+}
+
+// findingsLoop replaces the findings annotations each time the findings file changes
+func (g *GrafanaFix) findingsLoop() {
+	var lastMod time.Time
+	for {
+		st, err := os.Stat(g.FindingsFile)
+		if err == nil && !st.ModTime().Equal(lastMod) {
+			log.Println("Findings file changed, loading findings annotations")
+			err = g.loadFindings()
+			if err != nil {
+				log.Print(err)
+			} else {
+				lastMod = st.ModTime()
+			}
+		}
+		time.Sleep(30 * time.Second)
+	}
+}
+
+func (g *GrafanaFix) loadFindings() error {
+	contents, err := os.ReadFile(g.FindingsFile)
+	if err != nil {
+		return err
+	}
+	report := new(findingsReport)
+	err = json.Unmarshal(contents, report)
+	if err != nil {
+		return err
+	}
+	err = g.deleteFindingsAnnotations()
+	if err != nil {
+		return err
+	}
+	for _, a := range findingsAnnotations(report.Findings) {
+		res, err := json.Marshal(a)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		err = g.loadAnnotation(res)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+	}
+	return nil
+}
+
+// deleteFindingsAnnotations removes the annotations created from a previous findings report
+func (g *GrafanaFix) deleteFindingsAnnotations() error {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(g.GrafanaURL, "/")+"/api/annotations?limit=100000&tags="+findingsTag, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", `application/json`)
+	req.SetBasicAuth("admin", "admin")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("list annotations: %s: %s", resp.Status, string(body))
+	}
+	existing := []struct {
+		Id int `json:"id"`
+	}{}
+	err = json.Unmarshal(body, &existing)
+	if err != nil {
+		return err
+	}
+	for _, a := range existing {
+		req, err := http.NewRequest(http.MethodDelete, strings.TrimRight(g.GrafanaURL, "/")+"/api/annotations/"+strconv.Itoa(a.Id), nil)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth("admin", "admin")
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+	return nil
+}
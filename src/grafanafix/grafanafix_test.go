@@ -1,6 +1,7 @@
 package grafanafix
 
 import (
+	"encoding/json"
 	"os"
 	"os/exec"
 	"testing"
@@ -67,3 +68,39 @@ func TestAll(t *testing.T) {
 	}
 	Run(nil)
 }
+
+func TestFindingsDashboardAnnotations(t *testing.T) {
+	for _, name := range findingsDashboards {
+		contents, err := dashboards.ReadFile("dashboards/libraryxX1-Library/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err = findingsDashboardAnnotations(contents)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		dashboard := struct {
+			Uid         string `json:"uid"`
+			Annotations struct {
+				List []struct {
+					Name   string `json:"name"`
+					Enable bool   `json:"enable"`
+					Target struct {
+						Tags []string `json:"tags"`
+					} `json:"target"`
+				} `json:"list"`
+			} `json:"annotations"`
+		}{}
+		if err = json.Unmarshal(contents, &dashboard); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		list := dashboard.Annotations.List
+		if dashboard.Uid == "" || len(list) < 3 {
+			t.Fatalf("%s: unexpected dashboard %+v", name, dashboard)
+		}
+		last := list[len(list)-3:]
+		if last[0].Name != "Findings: critical" || !last[0].Enable || last[2].Name != "Findings: info" || last[2].Enable || len(last[1].Target.Tags) != 2 || last[1].Target.Tags[0] != findingsTag || last[1].Target.Tags[1] != "warning" {
+			t.Errorf("%s: unexpected findings annotations %+v", name, last)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"embed"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/fs"
 	"log"
@@ -14,6 +15,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/bestmethod/inslice"
 )
 
 //go:embed dashboards
@@ -131,6 +134,12 @@ func (g *GrafanaFix) importDashboards() error {
 		if err != nil {
 			return err
 		}
+		if inslice.HasString(findingsDashboards, path.Base(p)) {
+			contents, err = findingsDashboardAnnotations(contents)
+			if err != nil {
+				return fmt.Errorf("%s: %s", p, err)
+			}
+		}
 		folder, _ := path.Split(p)
 		folder = strings.TrimRight(folder, "/")
 		_, folder = path.Split(folder)
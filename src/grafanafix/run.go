@@ -29,6 +29,7 @@ type GrafanaFix struct {
 	} `yaml:"dashboards"`
 	GrafanaURL     string `yaml:"grafanaURL" envconfig:"GRAFANAFIX_URL" default:"http://127.0.0.1:8850"`
 	AnnotationFile string `yaml:"annotationFile" envconfig:"GRAFANAFIX_ANNOTATIONS" default:"annotations.json"`
+	FindingsFile   string `yaml:"findingsFile" envconfig:"GRAFANAFIX_FINDINGS" default:"findings.json"`
 }
 
 func MakeConfig(setDefaults bool, configYaml io.Reader, parseEnv bool) (*GrafanaFix, error) {
@@ -91,6 +92,10 @@ func Run(g *GrafanaFix) {
 	if err != nil {
 		log.Print(err)
 	}
+	if g.FindingsFile != "" {
+		log.Println("Following findings file for annotations")
+		go g.findingsLoop()
+	}
 	log.Println("Entering sleep-save-annotation loop")
 	for {
 		time.Sleep(time.Minute * 5)
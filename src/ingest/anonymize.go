@@ -0,0 +1,566 @@
+package ingest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bestmethod/inslice"
+	"github.com/bestmethod/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrAnonymizeBinary is returned when a file is neither text nor a supported archive, and cannot be anonymized
+var ErrAnonymizeBinary = errors.New("binary file, not anonymized")
+
+type AnonymizeRules struct {
+	Rules         []*AnonymizeRule `yaml:"rules"`
+	ReplaceQuoted []string         `yaml:"replaceQuoted"` // token types whose known values are also replaced wherever quoted
+}
+
+type AnonymizeRule struct {
+	Name     string   `yaml:"name"`
+	Token    string   `yaml:"token"`
+	Format   string   `yaml:"format"` // ip|<empty>
+	Regex    string   `yaml:"regex"`
+	Keep     []string `yaml:"keep"`
+	regex    *regexp.Regexp
+	valueIdx int
+	skipIdx  int
+}
+
+// LoadAnonymizeRules loads and compiles the anonymize rules file, or the embedded rules if rulesFile is empty
+func LoadAnonymizeRules(rulesFile string) (*AnonymizeRules, error) {
+	r := new(AnonymizeRules)
+	if rulesFile == "" {
+		logger.Debug("INIT: Loading embedded anonymize rules")
+		err := yaml.Unmarshal(anonymizeEmbed, r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal anonymize rules: %s", err)
+		}
+	} else {
+		logger.Debug("INIT: Loading %s", rulesFile)
+		f, err := os.Open(rulesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open specified anonymize rules file: %s", err)
+		}
+		defer f.Close()
+		err = yaml.NewDecoder(f).Decode(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal anonymize rules: %s", err)
+		}
+	}
+	return r, r.compile()
+}
+
+func (r *AnonymizeRules) compile() error {
+	for n, rule := range r.Rules {
+		if rule.Name == "" {
+			rule.Name = strconv.Itoa(n)
+		}
+		if rule.Token == "" {
+			return fmt.Errorf("anonymize rule %s: token is required", rule.Name)
+		}
+		if rule.Format != "" && rule.Format != "ip" {
+			return fmt.Errorf("anonymize rule %s: format %s not supported, supported: ip", rule.Name, rule.Format)
+		}
+		var err error
+		rule.regex, err = regexp.Compile(rule.Regex)
+		if err != nil {
+			return fmt.Errorf("anonymize rule %s: invalid regex: %s", rule.Name, err)
+		}
+		rule.valueIdx = rule.regex.SubexpIndex("value")
+		rule.skipIdx = rule.regex.SubexpIndex("skip")
+		if rule.valueIdx < 0 {
+			return fmt.Errorf("anonymize rule %s: regex must contain a (?P<value>...) group", rule.Name)
+		}
+	}
+	return nil
+}
+
+// DefaultAnonymizeRules returns the embedded anonymize rules file, as a starting point for custom rules
+func DefaultAnonymizeRules() []byte {
+	return anonymizeEmbed
+}
+
+// Anonymizer replaces sensitive values with tokens; the same value always gets the same token, so that data from different nodes and files can still be correlated
+type Anonymizer struct {
+	rules     *AnonymizeRules
+	lock      sync.Mutex
+	tokens    map[string]map[string]string // map[tokenType][value]token
+	quoted    map[string]*regexp.Regexp    // map[tokenType]regex of known quoted values, rebuilt when new values are found
+	changed   map[string]bool
+	unsaved   bool // new tokens were created since the map was last saved
+	namespace int  // if set, tokens are numbered within this namespace, see SetNamespace
+}
+
+// NewAnonymizer creates an anonymizer, loading the pseudonymisation map from mapFile if it is set and exists
+func NewAnonymizer(rules *AnonymizeRules, mapFile string) (*Anonymizer, error) {
+	a := &Anonymizer{
+		rules:   rules,
+		tokens:  make(map[string]map[string]string),
+		quoted:  make(map[string]*regexp.Regexp),
+		changed: make(map[string]bool),
+	}
+	if mapFile == "" {
+		return a, nil
+	}
+	f, err := os.ReadFile(mapFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return a, nil
+		}
+		return nil, fmt.Errorf("failed to read anonymize map: %s", err)
+	}
+	err = json.Unmarshal(f, &a.tokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal anonymize map: %s", err)
+	}
+	for tokenType := range a.tokens {
+		a.changed[tokenType] = true
+	}
+	return a, nil
+}
+
+// SetNamespace numbers the tokens within a namespace, so that they never match tokens created by anonymizers which did not share the map, such as previous ingest runs;
+// tokens become ex. host1-r2, and IP tokens 240.2.0.1, or ip1-r2 once the namespace or its IP range is exhausted
+func (a *Anonymizer) SetNamespace(namespace int) {
+	a.lock.Lock()
+	a.namespace = namespace
+	a.lock.Unlock()
+}
+
+// anonymizeNextNamespace increments the namespace number stored in file and returns it; the number is not secret, it only keeps the tokens of separate runs apart
+func anonymizeNextNamespace(file string) (int, error) {
+	namespace := 0
+	contents, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to read anonymize namespace: %s", err)
+	}
+	if err == nil {
+		namespace, err = strconv.Atoi(strings.TrimSpace(string(contents)))
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse anonymize namespace %s: %s", file, err)
+		}
+	}
+	namespace++
+	err = os.MkdirAll(path.Dir(file), 0755)
+	if err != nil {
+		return 0, err
+	}
+	err = os.WriteFile(file+".new", []byte(strconv.Itoa(namespace)+"\n"), 0644)
+	if err != nil {
+		return 0, err
+	}
+	return namespace, os.Rename(file+".new", file)
+}
+
+// SaveMap stores the pseudonymisation map if new tokens were created; the map allows reversing the tokens, so it is only readable by the owner
+func (a *Anonymizer) SaveMap(mapFile string) error {
+	if mapFile == "" {
+		return nil
+	}
+	a.lock.Lock()
+	if !a.unsaved {
+		a.lock.Unlock()
+		return nil
+	}
+	out, err := json.MarshalIndent(a.tokens, "", "    ")
+	a.unsaved = false
+	a.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(path.Dir(mapFile), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(mapFile+".new", out, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(mapFile+".new", mapFile)
+}
+
+// token returns the token of a value, creating it if the value has not been seen before
+func (a *Anonymizer) token(rule *AnonymizeRule, value string) string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if _, ok := a.tokens[rule.Token]; !ok {
+		a.tokens[rule.Token] = make(map[string]string)
+	}
+	if t, ok := a.tokens[rule.Token][value]; ok {
+		return t
+	}
+	n := len(a.tokens[rule.Token]) + 1
+	t := rule.Token + strconv.Itoa(n)
+	if a.namespace > 0 {
+		t = t + "-r" + strconv.Itoa(a.namespace)
+	}
+	switch {
+	case rule.Format != "ip":
+	case a.namespace == 0:
+		t = fmt.Sprintf("%d.%d.%d.%d", 240+(n>>24)&15, (n>>16)&255, (n>>8)&255, n&255)
+	case a.namespace < 4096 && n < 65536:
+		t = fmt.Sprintf("%d.%d.%d.%d", 240+(a.namespace>>8)&15, a.namespace&255, (n>>8)&255, n&255)
+	}
+	a.tokens[rule.Token][value] = t
+	a.changed[rule.Token] = true
+	a.unsaved = true
+	return t
+}
+
+// Value returns the token of a single value of the given token type, such as a cluster name read from a log before the log itself is anonymized
+func (a *Anonymizer) Value(tokenType string, value string) string {
+	for _, rule := range a.rules.Rules {
+		if rule.Token == tokenType {
+			if inslice.HasString(rule.Keep, value) {
+				return value
+			}
+			return a.token(rule, value)
+		}
+	}
+	return a.token(&AnonymizeRule{Token: tokenType}, value)
+}
+
+// quotedRegex returns the regex matching the known values of a token type as quoted strings
+func (a *Anonymizer) quotedRegex(tokenType string) *regexp.Regexp {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	tokens := a.tokens[tokenType]
+	if len(tokens) == 0 {
+		return nil
+	}
+	if a.changed[tokenType] {
+		values := []string{}
+		for v := range tokens {
+			values = append(values, regexp.QuoteMeta(v))
+		}
+		// longest first, so that a value which is a prefix of another does not win
+		sort.Slice(values, func(i, j int) bool {
+			if len(values[i]) != len(values[j]) {
+				return len(values[i]) > len(values[j])
+			}
+			return values[i] < values[j]
+		})
+		a.quoted[tokenType] = regexp.MustCompile(`"(?:` + strings.Join(values, "|") + `)"`)
+		a.changed[tokenType] = false
+	}
+	return a.quoted[tokenType]
+}
+
+// known returns the existing token of a value
+func (a *Anonymizer) known(tokenType string, value string) string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.tokens[tokenType][value]
+}
+
+// Line returns the line with all sensitive values replaced by their tokens
+func (a *Anonymizer) Line(line string) string {
+	for _, rule := range a.rules.Rules {
+		matches := rule.regex.FindAllStringSubmatchIndex(line, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		out := strings.Builder{}
+		last := 0
+		replaced := false
+		for _, m := range matches {
+			if rule.skipIdx > 0 && m[rule.skipIdx*2] >= 0 {
+				continue
+			}
+			start, end := m[rule.valueIdx*2], m[rule.valueIdx*2+1]
+			if start < 0 || start == end {
+				continue
+			}
+			value := line[start:end]
+			if inslice.HasString(rule.Keep, value) {
+				continue
+			}
+			out.WriteString(line[last:start])
+			out.WriteString(a.token(rule, value))
+			last = end
+			replaced = true
+		}
+		if replaced {
+			out.WriteString(line[last:])
+			line = out.String()
+		}
+	}
+	for _, tokenType := range a.rules.ReplaceQuoted {
+		if !strings.Contains(line, "\"") {
+			break
+		}
+		regex := a.quotedRegex(tokenType)
+		if regex == nil {
+			continue
+		}
+		line = regex.ReplaceAllStringFunc(line, func(v string) string {
+			return "\"" + a.known(tokenType, v[1:len(v)-1]) + "\""
+		})
+	}
+	return line
+}
+
+// Text anonymizes text line by line, preserving line endings
+func (a *Anonymizer) Text(r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	writer := bufio.NewWriter(w)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			ending := ""
+			if strings.HasSuffix(line, "\n") {
+				ending = "\n"
+				line = line[:len(line)-1]
+				if strings.HasSuffix(line, "\r") {
+					ending = "\r\n"
+					line = line[:len(line)-1]
+				}
+			}
+			if _, werr := writer.WriteString(a.Line(line) + ending); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// File anonymizes src into dst, which may be the same file; text files, gzip-compressed text, tar.gz archives (such as collectinfo) and zip archives are supported
+func (a *Anonymizer) File(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst+".anon", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	err = a.Stream(in, out)
+	out.Close()
+	if err != nil {
+		os.Remove(dst + ".anon")
+		return err
+	}
+	return os.Rename(dst+".anon", dst)
+}
+
+// Stream anonymizes the contents of r into w, detecting the content type as File does
+func (a *Anonymizer) Stream(r io.Reader, w io.Writer) error {
+	reader := bufio.NewReaderSize(r, 65536)
+	head, _ := reader.Peek(8192)
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return a.gzip(reader, w)
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		contents, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		return a.zip(contents, w)
+	case anonymizeIsBinary(head):
+		return ErrAnonymizeBinary
+	}
+	return a.Text(reader, w)
+}
+
+// anonymizeIsBinary detects binary content; log files starting with zeroes are text
+func anonymizeIsBinary(head []byte) bool {
+	return bytes.IndexByte(bytes.TrimLeft(head, "\x00"), 0) >= 0
+}
+
+func (a *Anonymizer) gzip(r io.Reader, w io.Writer) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+	reader := bufio.NewReaderSize(gr, 65536)
+	head, _ := reader.Peek(512)
+	gw := gzip.NewWriter(w)
+	if len(head) >= 262 && string(head[257:262]) == "ustar" {
+		err = a.tar(reader, gw)
+	} else if anonymizeIsBinary(head) {
+		err = ErrAnonymizeBinary
+	} else {
+		err = a.Text(reader, gw)
+	}
+	if err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func (a *Anonymizer) tar(r io.Reader, w io.Writer) error {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading tar archive: %s", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			if err = tw.WriteHeader(header); err != nil {
+				return err
+			}
+			continue
+		}
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("error reading %s from tar archive: %s", header.Name, err)
+		}
+		contents, err = a.member(header.Name, contents)
+		if err != nil {
+			return err
+		}
+		header.Size = int64(len(contents))
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err = tw.Write(contents); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func (a *Anonymizer) zip(contents []byte, w io.Writer) error {
+	zr, err := zip.NewReader(bytes.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		return fmt.Errorf("error opening zip for reading: %s", err)
+	}
+	zw := zip.NewWriter(w)
+	for _, file := range zr.File {
+		header := file.FileHeader
+		if file.FileInfo().IsDir() {
+			if _, err = zw.CreateHeader(&header); err != nil {
+				return err
+			}
+			continue
+		}
+		fd, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("error opening %s in zip: %s", file.Name, err)
+		}
+		fileContents, err := io.ReadAll(fd)
+		fd.Close()
+		if err != nil {
+			return fmt.Errorf("error reading %s in zip: %s", file.Name, err)
+		}
+		fileContents, err = a.member(file.Name, fileContents)
+		if err != nil {
+			return err
+		}
+		fw, err := zw.CreateHeader(&header)
+		if err != nil {
+			return err
+		}
+		if _, err = fw.Write(fileContents); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// member anonymizes a file contained in an archive; binary files are kept as they are
+func (a *Anonymizer) member(name string, contents []byte) ([]byte, error) {
+	out := new(bytes.Buffer)
+	err := a.Stream(bytes.NewReader(contents), out)
+	if err == ErrAnonymizeBinary {
+		logger.Detail("Anonymize: keeping binary archive member %s", name)
+		return contents, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return out.Bytes(), nil
+}
+
+// anonymizeClusterName returns the token of a cluster name read from a log, as the cluster name labels the data and names the log directories
+func (i *Ingest) anonymizeClusterName(clusterName string) string {
+	if i.anonymizer == nil || clusterName == "unset" {
+		return clusterName
+	}
+	return i.anonymizer.Value("cluster", clusterName)
+}
+
+// Anonymize replaces sensitive values in the pre-processed log and collectinfo files, in place; files which cannot be anonymized are removed, so that they are not ingested
+func (i *Ingest) Anonymize() error {
+	if i.anonymizer == nil {
+		return nil
+	}
+	logger.Debug("Anonymize running")
+	type anonFile struct {
+		fn      string
+		outPath string
+	}
+	todo := []anonFile{}
+	i.progress.Lock()
+	for fn, file := range i.progress.PreProcessor.Files {
+		if file.Anonymized {
+			continue
+		}
+		for _, outPath := range file.PreProcessOutPaths {
+			todo = append(todo, anonFile{fn, outPath})
+		}
+	}
+	i.progress.Unlock()
+	threads := make(chan bool, i.config.PreProcess.FileThreads)
+	wg := new(sync.WaitGroup)
+	for _, f := range todo {
+		wg.Add(1)
+		threads <- true
+		go func(f anonFile) {
+			defer func() {
+				<-threads
+				wg.Done()
+			}()
+			logger.Detail("Anonymize: %s", f.outPath)
+			err := i.anonymizer.File(f.outPath, f.outPath)
+			if err != nil {
+				logger.Warn("Failed to anonymize %s, removing: %s", f.outPath, err)
+				os.Remove(f.outPath)
+				i.progress.Lock()
+				i.progress.PreProcessor.Files[f.fn].Errors = append(i.progress.PreProcessor.Files[f.fn].Errors, fmt.Sprintf("anonymize %s: %s", f.outPath, err))
+				i.progress.Unlock()
+			}
+		}(f)
+	}
+	wg.Wait()
+	i.progress.Lock()
+	for _, f := range todo {
+		i.progress.PreProcessor.Files[f.fn].Anonymized = true
+	}
+	i.progress.PreProcessor.changed = true
+	i.progress.Unlock()
+	err := i.anonymizer.SaveMap(i.config.Anonymize.MapFile)
+	if err != nil {
+		return fmt.Errorf("failed to save anonymize map: %s", err)
+	}
+	logger.Debug("Anonymize finished, %d files", len(todo))
+	return nil
+}
@@ -0,0 +1,209 @@
+package ingest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizeLine(t *testing.T) {
+	rules, err := LoadAnonymizeRules("")
+	if err != nil {
+		t.Fatalf("embedded rules: %s", err)
+	}
+	a, err := NewAnonymizer(rules, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := [][2]string{
+		{
+			"Nov 01 2023 10:00:10 GMT: INFO (info): (ticker.c:170) {bar} migrations: remaining (0,0,0) active (0,0,0) complete-pct 100.00",
+			"Nov 01 2023 10:00:10 GMT: INFO (info): (ticker.c:170) {namespace1} migrations: remaining (0,0,0) active (0,0,0) complete-pct 100.00",
+		},
+		{
+			"Nov 01 2023 10:00:10 GMT: INFO (hb): (hb.c:1) node 10.0.0.1:3002 and 10.0.0.2:3002, listening on 0.0.0.0",
+			"Nov 01 2023 10:00:10 GMT: INFO (hb): (hb.c:1) node 240.0.0.1:3002 and 240.0.0.2:3002, listening on 0.0.0.0",
+		},
+		{
+			"Nov 01 2023 10:00:00 GMT: INFO (as): (as.c:1) Aerospike Enterprise Edition build 7.0.0.5 on 10.0.0.2",
+			"Nov 01 2023 10:00:00 GMT: INFO (as): (as.c:1) Aerospike Enterprise Edition build 7.0.0.5 on 240.0.0.2",
+		},
+		{
+			"INFO (security): permitted | client: 10.0.0.9:55123 | authenticated user: alice | action: login | detail: user=alice",
+			"INFO (security): permitted | client: 240.0.0.3:55123 | authenticated user: user1 | action: login | detail: user=user1",
+		},
+		{
+			"{bar|customers} truncate, set-name: customers, node ip-10-0-0-1.ec2.internal",
+			"{namespace1|set1} truncate, set-name: set1, node host1",
+		},
+		{
+			`{"hostname": ["db1"], "config": {"namespace": {"bar": {}, "test": {}}}, "ns": "bar"}`,
+			`{"hostname": ["host2"], "config": {"namespace": {"namespace1": {}, "test": {}}}, "ns": "namespace1"}`,
+		},
+		{
+			"Linux db1 5.15.0-1034-aws #38-Ubuntu SMP x86_64 GNU/Linux",
+			"Linux host2 5.15.0-1034-aws #38-Ubuntu SMP x86_64 GNU/Linux",
+		},
+		{
+			"namespace test {",
+			"namespace namespace2 {",
+		},
+	}
+	for _, test := range tests {
+		if out := a.Line(test[0]); out != test[1] {
+			t.Errorf("unexpected anonymized line\n   got: %s\nexpect: %s", out, test[1])
+		}
+	}
+
+	// tokens survive a save and load of the map, and collectinfo archives are anonymized consistently with logs
+	mapFile := filepath.Join(t.TempDir(), "map.json")
+	if err = a.SaveMap(mapFile); err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewAnonymizer(rules, mapFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := b.Line("{test} 10.0.0.1 10.0.0.50"); out != "{namespace2} 240.0.0.1 240.0.0.4" {
+		t.Errorf("unexpected line with loaded map: %s", out)
+	}
+	archive := new(bytes.Buffer)
+	gw := gzip.NewWriter(archive)
+	tw := tar.NewWriter(gw)
+	for name, contents := range map[string]string{
+		"collect_info/20231101_sysinfo.log": "['hostname -I']\n10.0.0.1 10.0.0.2\n",
+		"collect_info/20231101_binary.bin":  "\x01\x00\x02",
+	} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(contents))
+	}
+	tw.Close()
+	gw.Close()
+	out := new(bytes.Buffer)
+	if err = b.Stream(archive, out); err != nil {
+		t.Fatal(err)
+	}
+	gr, err := gzip.NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	found := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, _ := io.ReadAll(tr)
+		found++
+		switch {
+		case strings.HasSuffix(header.Name, "sysinfo.log") && string(contents) != "['hostname -I']\n240.0.0.1 240.0.0.2\n":
+			t.Errorf("unexpected sysinfo: %q", string(contents))
+		case strings.HasSuffix(header.Name, ".bin") && string(contents) != "\x01\x00\x02":
+			t.Errorf("binary member changed: %q", string(contents))
+		}
+	}
+	if found != 2 {
+		t.Errorf("expected 2 archive members, got %d", found)
+	}
+	if err = b.Stream(strings.NewReader("\x01\x00\x02"), io.Discard); err != ErrAnonymizeBinary {
+		t.Errorf("expected binary error, got %v", err)
+	}
+}
+
+func TestAnonymizeNamespace(t *testing.T) {
+	rules, err := LoadAnonymizeRules("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nsFile := filepath.Join(t.TempDir(), "ingest", "anonymize-namespace")
+	for expect := 1; expect <= 2; expect++ {
+		if namespace, err := anonymizeNextNamespace(nsFile); err != nil || namespace != expect {
+			t.Fatalf("expected namespace %d, got %d (%v)", expect, namespace, err)
+		}
+	}
+
+	// each run numbers its tokens from 1 again, so the tokens must carry the run namespace
+	a, _ := NewAnonymizer(rules, "")
+	a.SetNamespace(2)
+	line := "NODE-ID bb9 CLUSTER-SIZE 3 CLUSTER-NAME mydc on 10.0.0.1, {bar} cluster-name mydc"
+	if out := a.Line(line); out != "NODE-ID bb9 CLUSTER-SIZE 3 CLUSTER-NAME cluster1-r2 on 240.2.0.1, {namespace1-r2} cluster-name cluster1-r2" {
+		t.Errorf("unexpected line: %s", out)
+	}
+	if v := a.Value("cluster", "mydc"); v != "cluster1-r2" {
+		t.Errorf("expected the cluster name token of the log, got %s", v)
+	}
+	if v := a.Value("cluster", "null"); v != "null" {
+		t.Errorf("kept values must not be replaced, got %s", v)
+	}
+	b, _ := NewAnonymizer(rules, "")
+	b.SetNamespace(3)
+	if out := b.Line("{other} 10.0.0.2"); out != "{namespace1-r3} 240.3.0.1" {
+		t.Errorf("unexpected line in the next run: %s", out)
+	}
+	b.SetNamespace(4096)
+	if out := b.Line("10.0.0.3"); out != "ip2-r4096" {
+		t.Errorf("expected a named token once the IP range is exhausted, got %s", out)
+	}
+}
+
+func TestAnonymizePreProcessOtherFiles(t *testing.T) {
+	i := followTestIngest(t)
+	dir := t.TempDir()
+	i.config.Directories.DirtyTmp = filepath.Join(dir, "input")
+	i.config.Directories.Logs = filepath.Join(dir, "logs")
+	i.config.Directories.CollectInfo = filepath.Join(dir, "collectinfo")
+	i.config.Directories.OtherFiles = filepath.Join(dir, "other")
+	rules, err := LoadAnonymizeRules("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.anonymizer, err = NewAnonymizer(rules, ""); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(i.config.Directories.DirtyTmp, "notes"), 0755)
+	os.WriteFile(filepath.Join(i.config.Directories.DirtyTmp, "notes", "hosts.txt"), []byte("10.0.0.1 db1.example.com\n"), 0644)
+	os.WriteFile(filepath.Join(i.config.Directories.DirtyTmp, "core.bin"), []byte("\x7fELF\x00\x01"), 0644)
+	if err := i.PreProcess(); err != nil {
+		t.Fatal(err)
+	}
+	// files which are not logs or collectinfos are not anonymized, so they must not be kept where the file browser serves them
+	if others, _ := os.ReadDir(i.config.Directories.OtherFiles); len(others) != 0 {
+		t.Errorf("other files kept while anonymizing: %v", others)
+	}
+	if left, _ := os.ReadDir(i.config.Directories.DirtyTmp); len(left) != 0 {
+		t.Errorf("other files left in the input directory: %v", left)
+	}
+}
+
+func TestAnonymizeFollowClusterName(t *testing.T) {
+	i := followTestIngest(t)
+	rules, err := LoadAnonymizeRules("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.anonymizer, err = NewAnonymizer(rules, ""); err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), "aerospike.log")
+	os.WriteFile(fileName, []byte("Nov 01 2023 10:00:00 GMT: INFO (info): (ticker.c:160) NODE-ID bb9 CLUSTER-SIZE 1 CLUSTER-NAME mydc\n"), 0644)
+	// the cluster name labels the data and names the log directories, so it is anonymized when read, before the log lines are
+	f, err := i.followIdentify(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ClusterName != "cluster1" {
+		t.Errorf("expected the anonymized cluster name, got %s", f.ClusterName)
+	}
+	if out := i.anonymizer.Line("NODE-ID bb9 CLUSTER-SIZE 1 CLUSTER-NAME mydc"); out != "NODE-ID bb9 CLUSTER-SIZE 1 CLUSTER-NAME cluster1" {
+		t.Errorf("unexpected line: %s", out)
+	}
+}
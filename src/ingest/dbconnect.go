@@ -83,6 +83,24 @@ func (i *Ingest) dbConnect() error {
 	return i.dbSindex(i.config.Aerospike.WaitForSindexes)
 }
 
+// dbReconnect connects to the database again if the connection was closed by Close, returning true if a new connection was made
+func (i *Ingest) dbReconnect() (bool, error) {
+	if i.db != nil {
+		return false, nil
+	}
+	logger.Debug("DB: Reconnecting")
+	return true, i.dbConnect()
+}
+
+func (i *Ingest) dbClose() {
+	if i.db == nil {
+		return
+	}
+	logger.Debug("CLOSE: Closing database connection")
+	i.db.Close()
+	i.db = nil
+}
+
 func (i *Ingest) dbSindex(wait bool) error {
 	i.createSindex(i.config.Aerospike.DefaultSetName, i.config.Aerospike.TimestampIndexName, wait)
 	i.createSindex(i.config.Aerospike.LogFileRagesSetName, fmt.Sprintf("%s_%s", i.config.Aerospike.TimestampIndexName, i.config.Aerospike.LogFileRagesSetName), wait)
@@ -0,0 +1,525 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bestmethod/logger"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// remoteFile is a file found while enumerating a downloader source
+type remoteFile struct {
+	key          string
+	size         int64
+	lastModified time.Time
+}
+
+// searchMatch applies the source search regex on the key, AFTER the path prefix
+func searchMatch(regex *regexp.Regexp, prefix string, key string) bool {
+	if regex == nil {
+		return true
+	}
+	return regex.MatchString(strings.TrimPrefix(key, prefix))
+}
+
+// downloadKeyPath returns the path of a remote file relative to the download directory; keys with '..' elements, which could place
+// the file outside of the download directory, are rejected
+func downloadKeyPath(key string) (string, error) {
+	for _, elem := range strings.Split(key, "/") {
+		if elem == ".." {
+			return "", errors.New("key contains '..' path elements")
+		}
+	}
+	clean := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if clean == "" {
+		return "", errors.New("key is not a file path")
+	}
+	return clean, nil
+}
+
+// downloadSource merges the enumerated files with the source progress, saves the results and downloads new, changed and
+// previously failed files into DirtyTmp/dir using the configured number of threads; each file download is retried once
+func (i *Ingest) downloadSource(name string, dir string, files map[string]*DownloaderFile, found []*remoteFile, threads int, download func(key string, dst string) error) {
+	fileList := make(map[string]*DownloaderFile)
+	i.progress.RLock()
+	for k, v := range files {
+		if !v.IsDownloaded {
+			fileList[k] = &DownloaderFile{
+				Size:         v.Size,
+				LastModified: v.LastModified,
+				IsDownloaded: false,
+				Validator:    v.Validator,
+			}
+		}
+	}
+	for _, object := range found {
+		if _, err := downloadKeyPath(object.key); err != nil {
+			logger.Warn("%s Skipping file %q: %s", name, object.key, err)
+			continue
+		}
+		// sources which cannot report modification times (http) only download each file once
+		if ofile, ok := files[object.key]; !ok || (!object.lastModified.IsZero() && (ofile.Size != object.size || !ofile.LastModified.Equal(object.lastModified))) {
+			fileList[object.key] = &DownloaderFile{
+				Size:         object.size,
+				LastModified: object.lastModified,
+				IsDownloaded: false,
+			}
+		}
+	}
+	i.progress.RUnlock()
+
+	for okey, ofile := range fileList {
+		logger.Detail("%s to-download: %s (size:%d lastModified:%v)", name, okey, ofile.Size, ofile.LastModified)
+	}
+
+	logger.Debug("%s Enumeration complete, saving results", name)
+	i.progress.Lock()
+	i.progress.Downloader.changed = true
+	for k, v := range fileList {
+		files[k] = v
+	}
+	i.progress.Unlock()
+
+	logger.Debug("%s Beginning download", name)
+	if threads < 1 {
+		threads = 1
+	}
+	wg := new(sync.WaitGroup)
+	threadsChan := make(chan bool, threads)
+	wg.Add(len(fileList))
+	for f := range fileList {
+		threadsChan <- true
+		go func(f string) {
+			defer func() {
+				<-threadsChan
+				wg.Done()
+			}()
+			fpath, err := downloadKeyPath(f)
+			dst := path.Join(i.config.Directories.DirtyTmp, dir, fpath)
+			if err == nil {
+				err = os.MkdirAll(path.Dir(dst), 0755)
+			}
+			if err == nil {
+				err = download(f, dst)
+				if err != nil {
+					err = download(f, dst)
+				}
+			}
+			i.progress.Lock()
+			i.progress.Downloader.changed = true
+			if err != nil {
+				logger.Warn("%s Failed to download file %s: %s", name, f, err)
+				files[f].Error = err.Error()
+			} else {
+				files[f].IsDownloaded = true
+				files[f].Error = ""
+			}
+			i.progress.Unlock()
+		}(f)
+	}
+	wg.Wait()
+	logger.Debug("%s download complete", name)
+}
+
+// httpGetFile downloads the request into dst; if the validator (strong ETag or Last-Modified) of a partial dst is given, the download continues
+// from the partial file size, provided the server supports range requests and the file has not changed since, as checked by the server using If-Range;
+// returns the total size, last modification time and validator of the file, the latter also on error, so that a partial download can be resumed
+func httpGetFile(client *http.Client, req *http.Request, dst string, resumeValidator string) (size int64, lastModified time.Time, validator string, err error) {
+	offset := int64(0)
+	if resumeValidator != "" {
+		if stat, err := os.Stat(dst); err == nil {
+			offset = stat.Size()
+		}
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		req.Header.Set("If-Range", resumeValidator)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, lastModified, "", err
+	}
+	defer resp.Body.Close()
+	lastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	// weak ETags cannot be used with If-Range
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		validator = etag
+	} else if !lastModified.IsZero() {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch resp.StatusCode {
+	case http.StatusOK:
+		offset = 0
+	case http.StatusPartialContent:
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial file is already complete
+		if offset > 0 {
+			return offset, lastModified, resumeValidator, nil
+		}
+		return 0, lastModified, "", fmt.Errorf("http status %s", resp.Status)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, lastModified, "", fmt.Errorf("http status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	size = offset + resp.ContentLength
+	if resp.ContentLength < 0 {
+		size = -1
+	}
+	if rng := resp.Header.Get("Content-Range"); rng != "" {
+		if total, err := strconv.ParseInt(rng[strings.LastIndex(rng, "/")+1:], 10, 64); err == nil {
+			size = total
+		}
+	}
+	f, err := os.OpenFile(dst, flags, 0644)
+	if err != nil {
+		return size, lastModified, validator, err
+	}
+	n, err := io.Copy(f, resp.Body)
+	f.Close()
+	if err != nil {
+		return size, lastModified, validator, err
+	}
+	if size < 0 {
+		size = offset + n
+	}
+	return size, lastModified, validator, nil
+}
+
+type gcsObjectList struct {
+	Items []struct {
+		Name    string    `json:"name"`
+		Size    string    `json:"size"`
+		Updated time.Time `json:"updated"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// gcsClient returns a http client authenticated with the credentials file, or default credentials; without either, anonymous access is used
+func gcsClient(ctx context.Context, credentialsFile string) (*http.Client, error) {
+	scope := "https://www.googleapis.com/auth/devstorage.read_only"
+	if credentialsFile != "" {
+		data, err := os.ReadFile(credentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read credentials file: %s", err)
+		}
+		creds, err := google.CredentialsFromJSON(ctx, data, scope)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse credentials file: %s", err)
+		}
+		return oauth2.NewClient(ctx, creds.TokenSource), nil
+	}
+	client, err := google.DefaultClient(ctx, scope)
+	if err != nil {
+		logger.Debug("GCS default credentials not found, using anonymous access: %s", err)
+		return http.DefaultClient, nil
+	}
+	return client, nil
+}
+
+func (i *Ingest) DownloadGcs() error {
+	src := i.config.Downloader.GcsSource
+	logger.Debug("Connecting to GCS")
+	client, err := gcsClient(context.Background(), src.CredentialsFile)
+	if err != nil {
+		return fmt.Errorf("connecting to GCS: %s", err)
+	}
+	endpoint := strings.TrimSuffix(src.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+	bucketUrl := endpoint + "/storage/v1/b/" + url.PathEscape(src.BucketName) + "/o"
+
+	logger.Debug("GCS enumerating objects in bucket")
+	found := []*remoteFile{}
+	pageToken := ""
+	for {
+		query := url.Values{}
+		query.Set("fields", "items(name,size,updated),nextPageToken")
+		if src.PathPrefix != "" {
+			query.Set("prefix", src.PathPrefix)
+		}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		resp, err := client.Get(bucketUrl + "?" + query.Encode())
+		if err != nil {
+			return fmt.Errorf("GCS list objects: %s", err)
+		}
+		list := new(gcsObjectList)
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			resp.Body.Close()
+			return fmt.Errorf("GCS list objects: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		err = json.NewDecoder(resp.Body).Decode(list)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("GCS list objects: %s", err)
+		}
+		for _, object := range list.Items {
+			if strings.HasSuffix(object.Name, "/") || !searchMatch(src.searchRegex, src.PathPrefix, object.Name) {
+				continue
+			}
+			size, _ := strconv.ParseInt(object.Size, 10, 64)
+			found = append(found, &remoteFile{
+				key:          object.Name,
+				size:         size,
+				lastModified: object.Updated,
+			})
+		}
+		if list.NextPageToken == "" {
+			break
+		}
+		pageToken = list.NextPageToken
+	}
+
+	i.downloadSource("GCS", "gcssource", i.progress.Downloader.GcsFiles, found, src.Threads, func(key string, dst string) error {
+		req, err := http.NewRequest(http.MethodGet, bucketUrl+"/"+url.PathEscape(key)+"?alt=media", nil)
+		if err != nil {
+			return err
+		}
+		_, _, _, err = httpGetFile(client, req, dst, "")
+		return err
+	})
+	return nil
+}
+
+type azureBlobList struct {
+	Blobs struct {
+		Blob []struct {
+			Name       string `xml:"Name"`
+			Properties struct {
+				ContentLength int64  `xml:"Content-Length"`
+				LastModified  string `xml:"Last-Modified"`
+			} `xml:"Properties"`
+		} `xml:"Blob"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// azureRequest creates a blob service request, appending the SAS token to the query
+func azureRequest(rawUrl string, query url.Values, sasToken string) (*http.Request, error) {
+	q := query.Encode()
+	if sas := strings.TrimPrefix(sasToken, "?"); sas != "" {
+		if q != "" {
+			q = q + "&"
+		}
+		q = q + sas
+	}
+	if q != "" {
+		rawUrl = rawUrl + "?" + q
+	}
+	req, err := http.NewRequest(http.MethodGet, rawUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", "2020-10-02")
+	return req, nil
+}
+
+func (i *Ingest) DownloadAzure() error {
+	src := i.config.Downloader.AzureSource
+	endpoint := strings.TrimSuffix(src.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://" + src.AccountName + ".blob.core.windows.net"
+	}
+	containerUrl := endpoint + "/" + url.PathEscape(src.ContainerName)
+	client := &http.Client{}
+
+	logger.Debug("Azure enumerating blobs in container")
+	found := []*remoteFile{}
+	marker := ""
+	for {
+		query := url.Values{}
+		query.Set("restype", "container")
+		query.Set("comp", "list")
+		if src.PathPrefix != "" {
+			query.Set("prefix", src.PathPrefix)
+		}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		req, err := azureRequest(containerUrl, query, src.SasToken)
+		if err != nil {
+			return fmt.Errorf("azure list blobs: %s", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("azure list blobs: %s", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			resp.Body.Close()
+			return fmt.Errorf("azure list blobs: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		list := new(azureBlobList)
+		err = xml.NewDecoder(resp.Body).Decode(list)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("azure list blobs: %s", err)
+		}
+		for _, blob := range list.Blobs.Blob {
+			if strings.HasSuffix(blob.Name, "/") || !searchMatch(src.searchRegex, src.PathPrefix, blob.Name) {
+				continue
+			}
+			lastModified, _ := http.ParseTime(blob.Properties.LastModified)
+			found = append(found, &remoteFile{
+				key:          blob.Name,
+				size:         blob.Properties.ContentLength,
+				lastModified: lastModified,
+			})
+		}
+		if list.NextMarker == "" {
+			break
+		}
+		marker = list.NextMarker
+	}
+
+	i.downloadSource("azure", "azuresource", i.progress.Downloader.AzureFiles, found, src.Threads, func(key string, dst string) error {
+		blobPath := strings.Split(key, "/")
+		for n := range blobPath {
+			blobPath[n] = url.PathEscape(blobPath[n])
+		}
+		req, err := azureRequest(containerUrl+"/"+strings.Join(blobPath, "/"), url.Values{}, src.SasToken)
+		if err != nil {
+			return err
+		}
+		_, _, _, err = httpGetFile(client, req, dst, "")
+		return err
+	})
+	return nil
+}
+
+// httpSourceKey returns the host/path of the URL, without the query, for use as the local file path
+func httpSourceKey(rawUrl string) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%s is not a http(s) URL", u.Redacted())
+	}
+	key := path.Clean("/" + u.Path)
+	if key == "/" {
+		key = "/index"
+	}
+	return u.Host + key, nil
+}
+
+// httpSourceKeys returns the host/path of all valid URLs, for display
+func httpSourceKeys(urls []string) []string {
+	keys := []string{}
+	for _, rawUrl := range urls {
+		if key, err := httpSourceKey(rawUrl); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (i *Ingest) DownloadHttp() error {
+	src := i.config.Downloader.HttpSource
+	urls := make(map[string]string)
+	found := []*remoteFile{}
+	for _, rawUrl := range src.URLs {
+		key, err := httpSourceKey(rawUrl)
+		if err != nil {
+			return fmt.Errorf("http source: %s", err)
+		}
+		if !searchMatch(src.searchRegex, "", key) {
+			continue
+		}
+		urls[key] = rawUrl
+		found = append(found, &remoteFile{
+			key: key,
+		})
+	}
+	client := &http.Client{}
+	files := i.progress.Downloader.HttpFiles
+	i.downloadSource("http", "httpsource", files, found, src.Threads, func(key string, dst string) error {
+		rawUrl, ok := urls[key]
+		if !ok {
+			return errors.New("URL is no longer configured")
+		}
+		req, err := http.NewRequest(http.MethodGet, rawUrl, nil)
+		if err != nil {
+			return err
+		}
+		if src.Username != "" {
+			req.SetBasicAuth(src.Username, src.Password)
+		}
+		i.progress.RLock()
+		resumeValidator := files[key].Validator
+		i.progress.RUnlock()
+		size, lastModified, validator, err := httpGetFile(client, req, dst, resumeValidator)
+		i.progress.Lock()
+		i.progress.Downloader.changed = true
+		if size > 0 {
+			files[key].Size = size
+			files[key].LastModified = lastModified
+		}
+		files[key].Validator = validator
+		i.progress.Unlock()
+		return err
+	})
+	return nil
+}
+
+// DownloadCluster records the cluster log archives staged by aerolab in DirtyTmp/clustersource, for progress reporting
+func (i *Ingest) DownloadCluster() error {
+	dir := path.Join(i.config.Directories.DirtyTmp, "clustersource")
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		logger.Warn("cluster source enabled, but no logs have been staged in %s", dir)
+		return nil
+	}
+	found := make(map[string]*DownloaderFile)
+	err := filepath.WalkDir(dir, func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		key, _ := filepath.Rel(dir, fpath)
+		found[key] = &DownloaderFile{
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+			IsDownloaded: true,
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cluster source: %s", err)
+	}
+	i.progress.Lock()
+	i.progress.Downloader.changed = true
+	for k, v := range found {
+		i.progress.Downloader.ClusterFiles[k] = v
+	}
+	i.progress.Unlock()
+	logger.Debug("ClusterSource recorded %d staged files", len(found))
+	return nil
+}
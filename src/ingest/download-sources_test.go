@@ -0,0 +1,169 @@
+package ingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func downloadTestIngest(t *testing.T) *Ingest {
+	config, err := MakeConfigReader(true, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Directories.DirtyTmp = t.TempDir()
+	i := &Ingest{config: config, progress: new(Progress)}
+	i.progress.Downloader = &ProgressDownloader{
+		GcsFiles:     make(map[string]*DownloaderFile),
+		AzureFiles:   make(map[string]*DownloaderFile),
+		HttpFiles:    make(map[string]*DownloaderFile),
+		ClusterFiles: make(map[string]*DownloaderFile),
+	}
+	return i
+}
+
+func TestDownloadHttpResume(t *testing.T) {
+	content := "0123456789abcdefghij"
+	modified := time.Unix(1698832800, 0)
+	ifRange := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifRange = r.Header.Get("If-Range")
+		http.ServeContent(w, r, "aerospike.log", modified, strings.NewReader(content))
+	}))
+	defer srv.Close()
+	i := downloadTestIngest(t)
+	i.config.Downloader.HttpSource.URLs = []string{srv.URL + "/logs/aerospike.log?token=secret", srv.URL + "/logs/skip.txt"}
+	i.config.Downloader.HttpSource.searchRegex = regexp.MustCompile(`\.log$`)
+	key := strings.TrimPrefix(srv.URL, "http://") + "/logs/aerospike.log"
+	dst := path.Join(i.config.Directories.DirtyTmp, "httpsource", key)
+	os.MkdirAll(path.Dir(dst), 0755)
+	os.WriteFile(dst, []byte(content[:7]), 0644)
+	validator := modified.UTC().Format(http.TimeFormat)
+	i.progress.Downloader.HttpFiles[key] = &DownloaderFile{Validator: validator}
+	if err := i.DownloadHttp(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != content {
+		t.Errorf("unexpected resumed content %q", string(data))
+	}
+	if ifRange != validator {
+		t.Errorf("expected resume with If-Range %q, got %q", validator, ifRange)
+	}
+	if len(i.progress.Downloader.HttpFiles) != 1 || !i.progress.Downloader.HttpFiles[key].IsDownloaded || i.progress.Downloader.HttpFiles[key].Size != int64(len(content)) || i.progress.Downloader.HttpFiles[key].Validator != validator {
+		t.Errorf("unexpected progress %+v", i.progress.Downloader.HttpFiles[key])
+	}
+
+	// the file changed since the partial download, the server ignores the range and sends the whole file
+	content = "the new file contents"
+	modified = modified.Add(time.Hour)
+	os.WriteFile(dst, []byte("0123456"), 0644)
+	i.progress.Downloader.HttpFiles[key] = &DownloaderFile{Validator: validator}
+	if err := i.DownloadHttp(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != content {
+		t.Errorf("changed file: unexpected content %q", string(data))
+	}
+	// a partial download without a validator is not resumed
+	os.WriteFile(dst, []byte("garbage-garbage-garbage"), 0644)
+	i.progress.Downloader.HttpFiles[key] = &DownloaderFile{}
+	if err := i.DownloadHttp(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != content || ifRange != "" {
+		t.Errorf("no validator: unexpected content %q, If-Range %q", string(data), ifRange)
+	}
+	if _, err := httpSourceKey("ftp://host/file"); err == nil {
+		t.Error("expected error for non-http URL")
+	}
+}
+
+func TestDownloadAzure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "x" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Query().Get("comp") == "list" {
+			w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>
+<Blob><Name>logs/node1/aerospike.log</Name><Properties><Last-Modified>Wed, 01 Nov 2023 10:00:00 GMT</Last-Modified><Content-Length>5</Content-Length></Properties></Blob>
+<Blob><Name>logs/readme.txt</Name><Properties><Last-Modified>Wed, 01 Nov 2023 10:00:00 GMT</Last-Modified><Content-Length>1</Content-Length></Properties></Blob>
+</Blobs><NextMarker /></EnumerationResults>`))
+			return
+		}
+		if r.URL.Path == "/logs-container/logs/node1/aerospike.log" {
+			w.Write([]byte("hello"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+	i := downloadTestIngest(t)
+	src := i.config.Downloader.AzureSource
+	src.Endpoint = srv.URL
+	src.ContainerName = "logs-container"
+	src.SasToken = "?sv=2020&sig=x"
+	src.PathPrefix = "logs/"
+	src.searchRegex = regexp.MustCompile(`^node`)
+	if err := i.DownloadAzure(); err != nil {
+		t.Fatal(err)
+	}
+	f, ok := i.progress.Downloader.AzureFiles["logs/node1/aerospike.log"]
+	if len(i.progress.Downloader.AzureFiles) != 1 || !ok || !f.IsDownloaded || f.Size != 5 || f.LastModified.Unix() != 1698832800 {
+		t.Errorf("unexpected progress %+v", i.progress.Downloader.AzureFiles)
+	}
+	if data, _ := os.ReadFile(path.Join(i.config.Directories.DirtyTmp, "azuresource", "logs/node1/aerospike.log")); string(data) != "hello" {
+		t.Errorf("unexpected content %q", string(data))
+	}
+}
+
+func TestDownloadGcs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/storage/v1/b/bucket/o":
+			if r.URL.Query().Get("pageToken") == "" {
+				w.Write([]byte(`{"items":[{"name":"logs/","size":"0","updated":"2023-11-01T10:00:00Z"},{"name":"logs/a.log","size":"3","updated":"2023-11-01T10:00:00Z"}],"nextPageToken":"p2"}`))
+			} else {
+				w.Write([]byte(`{"items":[{"name":"logs/b.log","size":"3","updated":"2023-11-01T10:00:00Z"},{"name":"logs/../../escape.log","size":"3","updated":"2023-11-01T10:00:00Z"}]}`))
+			}
+		case "/storage/v1/b/bucket/o/logs%2Fa.log", "/storage/v1/b/bucket/o/logs%2Fb.log", "/storage/v1/b/bucket/o/logs%2F..%2F..%2Fescape.log":
+			w.Write([]byte("abc"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", path.Join(t.TempDir(), "missing.json"))
+	i := downloadTestIngest(t)
+	src := i.config.Downloader.GcsSource
+	src.Endpoint = srv.URL
+	src.BucketName = "bucket"
+	src.PathPrefix = "logs/"
+	if err := i.DownloadGcs(); err != nil {
+		t.Fatal(err)
+	}
+	if len(i.progress.Downloader.GcsFiles) != 2 || !i.progress.Downloader.GcsFiles["logs/a.log"].IsDownloaded || !i.progress.Downloader.GcsFiles["logs/b.log"].IsDownloaded {
+		t.Errorf("unexpected progress %+v", i.progress.Downloader.GcsFiles)
+	}
+	if _, err := os.Stat(path.Join(i.config.Directories.DirtyTmp, "escape.log")); err == nil {
+		t.Error("key with '..' elements was downloaded outside of the download directory")
+	}
+}
+
+func TestDownloadKeyPath(t *testing.T) {
+	for key, expected := range map[string]string{"logs/a.log": "logs/a.log", "/logs//a.log": "logs/a.log", "./logs/a.log": "logs/a.log"} {
+		if p, err := downloadKeyPath(key); err != nil || p != expected {
+			t.Errorf("%s: expected %s, got %s, %v", key, expected, p, err)
+		}
+	}
+	for _, key := range []string{"../a.log", "logs/../../a.log", "logs/..", "", "/"} {
+		if p, err := downloadKeyPath(key); err == nil {
+			t.Errorf("%q: expected error, got %s", key, p)
+		}
+	}
+}
@@ -50,33 +50,37 @@ func (i *Ingest) Download() error {
 	errs := new(safeError)
 	logger.Debug("Downloader start")
 	wg := new(sync.WaitGroup)
-	if i.config.Downloader.S3Source != nil && i.config.Downloader.S3Source.Enabled {
-		logger.Debug("DOWNLOAD: pulling from S3 source")
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := i.DownloadS3()
-			if err != nil {
-				errs.Set(err)
+	sources := []struct {
+		name     string
+		enabled  bool
+		download func() error
+	}{
+		{"S3", i.config.Downloader.S3Source != nil && i.config.Downloader.S3Source.Enabled, i.DownloadS3},
+		{"sftp", i.config.Downloader.SftpSource != nil && i.config.Downloader.SftpSource.Enabled, i.DownloadAsftp},
+		{"GCS", i.config.Downloader.GcsSource != nil && i.config.Downloader.GcsSource.Enabled, i.DownloadGcs},
+		{"azure", i.config.Downloader.AzureSource != nil && i.config.Downloader.AzureSource.Enabled, i.DownloadAzure},
+		{"http", i.config.Downloader.HttpSource != nil && i.config.Downloader.HttpSource.Enabled, i.DownloadHttp},
+		{"cluster", i.config.Downloader.ClusterSource != nil && i.config.Downloader.ClusterSource.Enabled, i.DownloadCluster},
+	}
+	for _, source := range sources {
+		if !source.enabled {
+			continue
+		}
+		if !i.config.Downloader.ConcurrentSources {
+			wg.Wait()
+			if err := errs.Get(); err != nil {
+				return err
 			}
-		}()
-	}
-	if !i.config.Downloader.ConcurrentSources {
-		wg.Wait()
-		if err := errs.Get(); err != nil {
-			return err
 		}
-	}
-	if i.config.Downloader.SftpSource != nil && i.config.Downloader.SftpSource.Enabled {
-		logger.Debug("DOWNLOAD: pulling from sftp source")
+		logger.Debug("DOWNLOAD: pulling from %s source", source.name)
 		wg.Add(1)
-		go func() {
+		go func(download func() error) {
 			defer wg.Done()
-			err := i.DownloadAsftp()
+			err := download()
 			if err != nil {
 				errs.Set(err)
 			}
-		}()
+		}(source.download)
 	}
 	wg.Wait()
 	if err := errs.Get(); err != nil {
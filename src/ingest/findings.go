@@ -0,0 +1,435 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bestmethod/inslice"
+	"github.com/bestmethod/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// finding severities, most severe first
+var FindingSeverities = []string{"critical", "warning", "info"}
+
+// FindingSeverityRank returns the rank of a severity, 0 being the most severe, or -1 if the severity is not known
+func FindingSeverityRank(severity string) int {
+	return inslice.StringMatch(FindingSeverities, strings.ToLower(severity))
+}
+
+type findingRules struct {
+	MergeWithin time.Duration  `yaml:"mergeWithin"`
+	Rules       []*findingRule `yaml:"rules"`
+}
+
+type findingRule struct {
+	Name        string              `yaml:"name"`
+	Description string              `yaml:"description"`
+	Severity    string              `yaml:"severity"`
+	SetName     string              `yaml:"setName"`
+	Match       []*findingCondition `yaml:"match"`
+	MatchAny    bool                `yaml:"matchAny"`
+	GroupBy     []string            `yaml:"groupBy"`
+	Value       string              `yaml:"value"`
+	Message     string              `yaml:"message"`
+	MergeWithin time.Duration       `yaml:"mergeWithin"` // default: the rules file mergeWithin
+}
+
+type findingCondition struct {
+	Bin   string `yaml:"bin"`
+	Op    string `yaml:"op"` // exists|=|!=|=~|!~|<|>|<=|>=
+	Value string `yaml:"value"`
+	regex *regexp.Regexp
+	num   float64
+}
+
+// Finding is a stretch of time during which a rule matched the statistics of a node
+type Finding struct {
+	Rule        string            `json:"rule"`
+	Severity    string            `json:"severity"`
+	Description string            `json:"description"`
+	Message     string            `json:"message"`
+	ClusterName string            `json:"clusterName"`
+	NodeIdent   string            `json:"nodeIdent"`
+	Labels      map[string]string `json:"labels,omitempty"` // groupBy label values
+	Start       time.Time         `json:"start"`
+	End         time.Time         `json:"end"`
+	Count       int               `json:"count"` // number of matching datapoints
+	ValueName   string            `json:"valueName,omitempty"`
+	MaxValue    float64           `json:"maxValue,omitempty"`
+}
+
+// FindingsReport is the result of the analysis stage, stored as json in the findings output file
+type FindingsReport struct {
+	Generated time.Time  `json:"generated"`
+	Findings  []*Finding `json:"findings"`
+	Errors    []string   `json:"errors,omitempty"` // rules which could not be evaluated
+}
+
+var findingMessageVar = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// loadFindingRules loads and validates the findings rules file, or the embedded rules if rulesFile is empty
+func loadFindingRules(rulesFile string) (*findingRules, error) {
+	r := new(findingRules)
+	if rulesFile == "" {
+		logger.Debug("INIT: Loading embedded findings rules")
+		err := yaml.Unmarshal(findingsEmbed, r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal findings rules: %s", err)
+		}
+	} else {
+		logger.Debug("INIT: Loading %s", rulesFile)
+		f, err := os.Open(rulesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open specified findings rules file: %s", err)
+		}
+		defer f.Close()
+		err = yaml.NewDecoder(f).Decode(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal findings rules: %s", err)
+		}
+	}
+	return r, r.compile()
+}
+
+func (r *findingRules) compile() error {
+	if r.MergeWithin <= 0 {
+		r.MergeWithin = 5 * time.Minute
+	}
+	names := []string{}
+	for n, rule := range r.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("findings rule %d: name is required", n)
+		}
+		if inslice.HasString(names, rule.Name) {
+			return fmt.Errorf("findings rule %s: duplicate name", rule.Name)
+		}
+		names = append(names, rule.Name)
+		if rule.SetName == "" {
+			return fmt.Errorf("findings rule %s: setName is required", rule.Name)
+		}
+		rule.Severity = strings.ToLower(rule.Severity)
+		if FindingSeverityRank(rule.Severity) < 0 {
+			return fmt.Errorf("findings rule %s: severity %s not supported, supported: %s", rule.Name, rule.Severity, strings.Join(FindingSeverities, "|"))
+		}
+		if len(rule.Match) == 0 {
+			return fmt.Errorf("findings rule %s: at least one match condition is required", rule.Name)
+		}
+		if rule.MergeWithin <= 0 {
+			rule.MergeWithin = r.MergeWithin
+		}
+		for _, cond := range rule.Match {
+			if cond.Bin == "" {
+				return fmt.Errorf("findings rule %s: match bin is required", rule.Name)
+			}
+			var err error
+			switch cond.Op {
+			case "exists", "=", "!=":
+			case "=~", "!~":
+				cond.regex, err = regexp.Compile("^" + cond.Value + "$")
+				if err != nil {
+					return fmt.Errorf("findings rule %s: invalid regex %s: %s", rule.Name, cond.Value, err)
+				}
+			case "<", ">", "<=", ">=":
+				cond.num, err = strconv.ParseFloat(cond.Value, 64)
+				if err != nil {
+					return fmt.Errorf("findings rule %s: %s requires a numeric value, got %s", rule.Name, cond.Op, cond.Value)
+				}
+			default:
+				return fmt.Errorf("findings rule %s: op %s not supported, supported: exists|=|!=|=~|!~|<|>|<=|>=", rule.Name, cond.Op)
+			}
+		}
+	}
+	return nil
+}
+
+// bins returns the bins needed to evaluate the rule
+func (rule *findingRule) bins(timestampBin string) []string {
+	bins := []string{timestampBin, "ClusterName", "NodeIdent"}
+	add := func(bin string) {
+		if bin != "" && !inslice.HasString(bins, bin) {
+			bins = append(bins, bin)
+		}
+	}
+	for _, cond := range rule.Match {
+		add(cond.Bin)
+	}
+	for _, bin := range rule.GroupBy {
+		add(bin)
+	}
+	add(rule.Value)
+	for _, v := range findingMessageVar.FindAllStringSubmatch(rule.Message, -1) {
+		add(v[1])
+	}
+	return bins
+}
+
+// match returns true if the record bins, with labels resolved to their values, match the rule conditions
+func (rule *findingRule) match(bins map[string]interface{}) bool {
+	for _, cond := range rule.Match {
+		if cond.match(bins) {
+			if rule.MatchAny {
+				return true
+			}
+		} else if !rule.MatchAny {
+			return false
+		}
+	}
+	return !rule.MatchAny
+}
+
+func (cond *findingCondition) match(bins map[string]interface{}) bool {
+	v, ok := bins[cond.Bin]
+	if !ok || v == nil {
+		return cond.Op == "!=" || cond.Op == "!~"
+	}
+	switch cond.Op {
+	case "exists":
+		return true
+	case "=":
+		return fmt.Sprint(v) == cond.Value
+	case "!=":
+		return fmt.Sprint(v) != cond.Value
+	case "=~":
+		return cond.regex.MatchString(fmt.Sprint(v))
+	case "!~":
+		return !cond.regex.MatchString(fmt.Sprint(v))
+	}
+	num, ok := findingNumber(v)
+	if !ok {
+		return false
+	}
+	switch cond.Op {
+	case "<":
+		return num < cond.num
+	case ">":
+		return num > cond.num
+	case "<=":
+		return num <= cond.num
+	case ">=":
+		return num >= cond.num
+	}
+	return false
+}
+
+func findingNumber(v interface{}) (float64, bool) {
+	switch vv := v.(type) {
+	case int:
+		return float64(vv), true
+	case int64:
+		return float64(vv), true
+	case float64:
+		return vv, true
+	case string:
+		f, err := strconv.ParseFloat(vv, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// findingMatch is a record which matched a rule
+type findingMatch struct {
+	timestamp time.Time
+	bins      map[string]interface{}
+}
+
+// findings merges the matches of a rule into findings, per cluster, node and groupBy labels; matches less than MergeWithin apart become a single finding
+func (rule *findingRule) findings(matches []*findingMatch) []*Finding {
+	groups := make(map[string][]*findingMatch)
+	keys := []string{}
+	for _, m := range matches {
+		key := fmt.Sprint(m.bins["ClusterName"]) + "::" + fmt.Sprint(m.bins["NodeIdent"])
+		for _, bin := range rule.GroupBy {
+			key += "::" + fmt.Sprint(m.bins[bin])
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], m)
+	}
+	sort.Strings(keys)
+	findings := []*Finding{}
+	for _, key := range keys {
+		group := groups[key]
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].timestamp.Before(group[j].timestamp)
+		})
+		var f *Finding
+		var top *findingMatch
+		for _, m := range group {
+			if f != nil && m.timestamp.Sub(f.End) <= rule.MergeWithin {
+				f.End = m.timestamp
+				f.Count++
+				if val, ok := findingNumber(m.bins[rule.Value]); ok && (top == nil || val > f.MaxValue) {
+					f.MaxValue = val
+					top = m
+				}
+				continue
+			}
+			if f != nil {
+				f.Message = rule.message(top.bins)
+				findings = append(findings, f)
+			}
+			f = rule.newFinding(m)
+			top = m
+		}
+		if f != nil {
+			f.Message = rule.message(top.bins)
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+func (rule *findingRule) newFinding(m *findingMatch) *Finding {
+	f := &Finding{
+		Rule:        rule.Name,
+		Severity:    rule.Severity,
+		Description: rule.Description,
+		ClusterName: findingString(m.bins["ClusterName"]),
+		NodeIdent:   findingString(m.bins["NodeIdent"]),
+		Start:       m.timestamp,
+		End:         m.timestamp,
+		Count:       1,
+	}
+	if len(rule.GroupBy) > 0 {
+		f.Labels = make(map[string]string)
+		for _, bin := range rule.GroupBy {
+			f.Labels[bin] = findingString(m.bins[bin])
+		}
+	}
+	if rule.Value != "" {
+		f.ValueName = rule.Value
+		f.MaxValue, _ = findingNumber(m.bins[rule.Value])
+	}
+	return f
+}
+
+func (rule *findingRule) message(bins map[string]interface{}) string {
+	if rule.Message == "" {
+		return rule.Description
+	}
+	return findingMessageVar.ReplaceAllStringFunc(rule.Message, func(v string) string {
+		return findingString(bins[strings.Trim(v, "{}")])
+	})
+}
+
+func findingString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// findingsSort orders findings by start time, most severe first
+func findingsSort(findings []*Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if !findings[i].Start.Equal(findings[j].Start) {
+			return findings[i].Start.Before(findings[j].Start)
+		}
+		return FindingSeverityRank(findings[i].Severity) < FindingSeverityRank(findings[j].Severity)
+	})
+}
+
+// Analyze runs the findings rules over the ingested statistics and writes the findings report to the configured output file
+func (i *Ingest) Analyze() error {
+	if !i.config.Findings.Enabled {
+		return nil
+	}
+	if i.findingRules == nil {
+		return errors.New("findings rules not loaded")
+	}
+	// Analyze runs after Close; the connection it opens is closed once the report is written
+	reconnected, err := i.dbReconnect()
+	if err != nil {
+		return fmt.Errorf("could not connect to the database: %s", err)
+	}
+	if reconnected {
+		defer i.dbClose()
+	}
+	logger.Info("Analyze: running %d findings rules", len(i.findingRules.Rules))
+	meta := i.processLogsLoadMeta()
+	report := &FindingsReport{
+		Findings: []*Finding{},
+	}
+	for _, rule := range i.findingRules.Rules {
+		matches, err := i.analyzeRule(rule, meta)
+		if err != nil {
+			logger.Warn("Analyze: rule %s: %s", rule.Name, err)
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", rule.Name, err))
+			continue
+		}
+		findings := rule.findings(matches)
+		logger.Detail("Analyze: rule %s: %d matches, %d findings", rule.Name, len(matches), len(findings))
+		report.Findings = append(report.Findings, findings...)
+	}
+	findingsSort(report.Findings)
+	report.Generated = time.Now().UTC()
+	logger.Info("Analyze: found %d findings", len(report.Findings))
+	return writeFindingsReport(i.config.Findings.OutputFile, report)
+}
+
+// analyzeRule scans the set of a rule, returning the matching records with labels resolved to their values
+func (i *Ingest) analyzeRule(rule *findingRule, meta map[string]*metaEntries) ([]*findingMatch, error) {
+	recset, err := i.db.ScanAll(nil, i.config.Aerospike.Namespace, rule.SetName, rule.bins(i.config.Aerospike.TimestampBinName)...)
+	if err != nil {
+		return nil, err
+	}
+	matches := []*findingMatch{}
+	for rec := range recset.Results() {
+		if rec.Err != nil {
+			return nil, rec.Err
+		}
+		bins := make(map[string]interface{}, len(rec.Record.Bins))
+		for k, v := range rec.Record.Bins {
+			if m, ok := meta[k]; ok {
+				if idx, ok := v.(int); ok && idx >= 0 && idx < len(m.Entries) {
+					v = m.Entries[idx]
+				}
+			}
+			bins[k] = v
+		}
+		if !rule.match(bins) {
+			continue
+		}
+		ts, ok := bins[i.config.Aerospike.TimestampBinName].(int)
+		if !ok {
+			continue
+		}
+		matches = append(matches, &findingMatch{
+			timestamp: time.UnixMilli(int64(ts)).UTC(),
+			bins:      bins,
+		})
+	}
+	return matches, nil
+}
+
+func writeFindingsReport(fileName string, report *FindingsReport) error {
+	err := os.MkdirAll(path.Dir(fileName), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(fileName + ".new")
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(f).Encode(report)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(fileName+".new", fileName)
+}
+
+// DefaultFindingRules returns the embedded findings rules file, as a starting point for custom rules
+func DefaultFindingRules() []byte {
+	return findingsEmbed
+}
@@ -0,0 +1,103 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestFindingRules(t *testing.T) {
+	r, err := loadFindingRules("")
+	if err != nil {
+		t.Fatalf("embedded rules: %s", err)
+	}
+	if r.MergeWithin != 5*time.Minute || len(r.Rules) == 0 {
+		t.Fatalf("unexpected embedded rules: %v %d", r.MergeWithin, len(r.Rules))
+	}
+	for _, bad := range []string{
+		`rules: [{name: a, severity: fatal, setName: s, match: [{bin: b, op: exists}]}]`,
+		`rules: [{name: a, severity: info, setName: s, match: [{bin: b, op: "~"}]}]`,
+		`rules: [{name: a, severity: info, setName: s, match: [{bin: b, op: ">", value: x}]}]`,
+		`rules: [{name: a, severity: info, setName: s}]`,
+		`rules: [{name: a, severity: info, setName: s, match: [{bin: b, op: exists}]}, {name: a, severity: info, setName: s, match: [{bin: b, op: exists}]}]`,
+	} {
+		nr := new(findingRules)
+		if err := yaml.Unmarshal([]byte(bad), nr); err != nil {
+			t.Fatal(err)
+		}
+		if err := nr.compile(); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestFindingRuleFindings(t *testing.T) {
+	r := new(findingRules)
+	err := yaml.Unmarshal([]byte(`
+mergeWithin: 1m
+rules:
+    - name: "write-queue"
+      severity: "Warning"
+      setName: "nsDevice"
+      matchAny: true
+      match:
+          - {bin: "WriteQ", op: ">", value: "10"}
+          - {bin: "Device", op: "=~", value: "/dev/full.*"}
+      groupBy: ["Device"]
+      value: "WriteQ"
+      message: "{Device} write-q {WriteQ}"
+`), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.compile(); err != nil {
+		t.Fatal(err)
+	}
+	rule := r.Rules[0]
+	base := time.Date(2023, 11, 1, 10, 0, 0, 0, time.UTC)
+	records := []struct {
+		offset time.Duration
+		bins   map[string]interface{}
+	}{
+		{0, map[string]interface{}{"NodeIdent": "1_bb8", "Device": "/dev/sda", "WriteQ": 20}},
+		{30 * time.Second, map[string]interface{}{"NodeIdent": "1_bb8", "Device": "/dev/sda", "WriteQ": 50}},
+		{10 * time.Second, map[string]interface{}{"NodeIdent": "1_bb8", "Device": "/dev/sda", "WriteQ": 5}}, // no match
+		{5 * time.Minute, map[string]interface{}{"NodeIdent": "1_bb8", "Device": "/dev/sda", "WriteQ": 15}},
+		{0, map[string]interface{}{"NodeIdent": "1_bb8", "Device": "/dev/sdb", "WriteQ": 11}},
+		{0, map[string]interface{}{"NodeIdent": "2_bb9", "Device": "/dev/full0", "WriteQ": 0}}, // matchAny
+		{0, map[string]interface{}{"NodeIdent": "2_bb9", "Device": "/dev/sda"}},                // no value
+	}
+	matches := []*findingMatch{}
+	for _, rec := range records {
+		rec.bins["ClusterName"] = "mydc"
+		if rule.match(rec.bins) {
+			matches = append(matches, &findingMatch{timestamp: base.Add(rec.offset), bins: rec.bins})
+		}
+	}
+	findings := rule.findings(matches)
+	findingsSort(findings)
+	expect := []struct {
+		node, device, message string
+		start, end            time.Duration
+		count                 int
+		max                   float64
+	}{
+		{"1_bb8", "/dev/sda", "/dev/sda write-q 50", 0, 30 * time.Second, 2, 50},
+		{"1_bb8", "/dev/sdb", "/dev/sdb write-q 11", 0, 0, 1, 11},
+		{"2_bb9", "/dev/full0", "/dev/full0 write-q 0", 0, 0, 1, 0},
+		{"1_bb8", "/dev/sda", "/dev/sda write-q 15", 5 * time.Minute, 5 * time.Minute, 1, 15},
+	}
+	if len(findings) != len(expect) {
+		t.Fatalf("expected %d findings, got %d", len(expect), len(findings))
+	}
+	for n, e := range expect {
+		f := findings[n]
+		if f.NodeIdent != e.node || f.Labels["Device"] != e.device || f.Message != e.message || !f.Start.Equal(base.Add(e.start)) || !f.End.Equal(base.Add(e.end)) || f.Count != e.count || f.MaxValue != e.max {
+			t.Errorf("finding %d: unexpected %+v", n, f)
+		}
+		if f.Severity != "warning" || f.ClusterName != "mydc" || f.Rule != "write-queue" {
+			t.Errorf("finding %d: unexpected rule details %+v", n, f)
+		}
+	}
+}
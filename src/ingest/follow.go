@@ -0,0 +1,312 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bestmethod/logger"
+)
+
+// Follow tails the log files matching the configured follow paths and stores points as new lines are written, until ctx is cancelled.
+// Per-file offsets are kept in the log processor progress, so that a restart resumes where it stopped.
+// Follow may be called after Close; Close must be called again once it returns.
+func (i *Ingest) Follow(ctx context.Context) error {
+	if len(i.config.Follow.Paths) == 0 {
+		return errors.New("no follow paths configured")
+	}
+	for _, pattern := range i.config.Follow.Paths {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid follow path %s: %s", pattern, err)
+		}
+	}
+	// progress tracking and the database connection are stopped by Close at the end of a batch ingest; resume them while following
+	if _, err := i.dbReconnect(); err != nil {
+		return fmt.Errorf("could not connect to the database: %s", err)
+	}
+	i.endLock.Lock()
+	if i.end {
+		i.end = false
+		go i.saveProgressInterval()
+		go i.printProgressInterval()
+	}
+	i.endLock.Unlock()
+	i.progress.Lock()
+	i.followInitProgress()
+	i.progress.LogProcessor.running = true
+	i.progress.LogProcessor.wasRunning = true
+	i.progress.Unlock()
+	defer func() {
+		i.progress.Lock()
+		i.progress.LogProcessor.running = false
+		i.progress.Unlock()
+	}()
+	meta := i.processLogsLoadMeta()
+
+	resultsChan := make(chan *processResult)
+	storeDone := make(chan struct{})
+	go func() {
+		i.processLogsStore(resultsChan, meta)
+		close(storeDone)
+	}()
+
+	logger.Info("Follow: tailing %s", strings.Join(i.config.Follow.Paths, ","))
+	wg := new(sync.WaitGroup)
+	following := make(map[string]bool)
+	unidentified := make(map[string]int64) // size at which identification last failed
+	ticker := time.NewTicker(i.config.Follow.PollInterval)
+	defer ticker.Stop()
+	for {
+		for _, pattern := range i.config.Follow.Paths {
+			matches, _ := filepath.Glob(pattern)
+			for _, fileName := range matches {
+				if following[fileName] {
+					continue
+				}
+				info, err := os.Stat(fileName)
+				if err != nil || info.IsDir() {
+					continue
+				}
+				if size, ok := unidentified[fileName]; ok && size == info.Size() {
+					continue
+				}
+				f, err := i.followIdentify(fileName)
+				if err != nil {
+					logger.Detail("Follow: %s: %s, will retry when the file grows", fileName, err)
+					unidentified[fileName] = info.Size()
+					continue
+				}
+				delete(unidentified, fileName)
+				following[fileName] = true
+				logger.Info("Follow: following %s (clusterName:%s) (nodeIdent:%s_%s) (offset:%d)", fileName, f.ClusterName, f.NodePrefix, f.NodeID, f.Processed)
+				wg.Add(1)
+				go func(fileName string, f *LogFile) {
+					defer wg.Done()
+					i.followFile(ctx, fileName, f, resultsChan)
+				}(fileName, f)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info("Follow: stopping")
+			wg.Wait()
+			close(resultsChan)
+			<-storeDone
+			if i.anonymizer != nil {
+				return i.anonymizer.SaveMap(i.config.Anonymize.MapFile)
+			}
+			return nil
+		case <-ticker.C:
+			if i.anonymizer != nil {
+				// tokens must survive a crash, or a restart could hand out the same token to a different value
+				err := i.anonymizer.SaveMap(i.config.Anonymize.MapFile)
+				if err != nil {
+					logger.Warn("Follow: failed to save anonymize map: %s", err)
+				}
+			}
+		}
+	}
+}
+
+// followInitProgress initializes the progress maps used by follow mode; the caller must hold the progress lock
+func (i *Ingest) followInitProgress() {
+	if i.progress.LogProcessor.Followed == nil {
+		i.progress.LogProcessor.Followed = make(map[string]*LogFile)
+	}
+	if i.progress.PreProcessor.LastUsedSuffixForPrefix == nil {
+		i.progress.PreProcessor.LastUsedSuffixForPrefix = make(map[int]int)
+	}
+	if i.progress.PreProcessor.NodeToPrefix == nil {
+		i.progress.PreProcessor.NodeToPrefix = make(map[string]int)
+	}
+}
+
+// followIdentify returns the progress entry of a followed file, finding the cluster name and node ID from the log the first time the file is seen;
+// node prefixes are shared with the pre-processor, so that followed and batch-ingested logs of the same node have the same identity
+func (i *Ingest) followIdentify(fileName string) (*LogFile, error) {
+	i.progress.RLock()
+	f, ok := i.progress.LogProcessor.Followed[fileName]
+	i.progress.RUnlock()
+	if ok {
+		return f, nil
+	}
+	clusterName, nodeId, err := i.preProcessGetClusterNode(fileName)
+	if err != nil {
+		return nil, err
+	}
+	clusterName = i.anonymizeClusterName(clusterName)
+	i.progress.Lock()
+	defer i.progress.Unlock()
+	prefix, ok := i.progress.PreProcessor.NodeToPrefix[clusterName+"_"+nodeId]
+	if !ok {
+		i.progress.PreProcessor.LastUsedPrefix++
+		prefix = i.progress.PreProcessor.LastUsedPrefix
+		i.progress.PreProcessor.NodeToPrefix[clusterName+"_"+nodeId] = prefix
+		i.progress.PreProcessor.changed = true
+	}
+	f = &LogFile{
+		ClusterName: clusterName,
+		NodePrefix:  strconv.Itoa(prefix),
+		NodeID:      nodeId,
+		NodeSuffix:  "follow",
+	}
+	i.progress.LogProcessor.Followed[fileName] = f
+	i.progress.LogProcessor.changed = true
+	return f, nil
+}
+
+// followAckOffset records the offset up to which a followed file has been processed, once the store has written all points read before it;
+// an offset recorded earlier would skip lines whose points were lost if aerolab stopped in between
+func (i *Ingest) followAckOffset(f *LogFile, offset int64, size int64, resultsChan chan *processResult) {
+	resultsChan <- &processResult{
+		Ack: func() {
+			i.followSetOffset(f, offset, size)
+		},
+	}
+}
+
+// followSetOffset records the offset up to which a followed file has been processed
+func (i *Ingest) followSetOffset(f *LogFile, offset int64, size int64) {
+	i.progress.Lock()
+	if f.Processed != offset || f.Size != size {
+		f.Processed = offset
+		f.Size = size
+		i.progress.LogProcessor.changed = true
+	}
+	i.progress.Unlock()
+}
+
+// followFile tails a single file from its recorded offset, processing complete lines only; the file is re-read from the start if it is truncated or replaced (log rotation)
+func (i *Ingest) followFile(ctx context.Context, fileName string, f *LogFile, resultsChan chan *processResult) {
+	labels := map[string]interface{}{
+		"ClusterName": f.ClusterName,
+		"NodeIdent":   f.NodePrefix + "_" + f.NodeID,
+	}
+	nodePrefix, _ := strconv.Atoi(f.NodePrefix)
+	uniqNodeString := f.ClusterName + "::/::" + f.NodePrefix + "_" + f.NodeID
+	unmatched := &noStatLog{
+		fileName: path.Join(i.config.Directories.NoStatLogs, f.ClusterName, path.Base(fileName)),
+		append:   true,
+	}
+	defer unmatched.close()
+	stream := newLogStream(i.patterns, &i.config.IngestTimeRanges, i.config.Aerospike.TimestampBinName)
+	defer i.processLogClose(stream, fileName, resultsChan, labels, nodePrefix, uniqNodeString)
+
+	i.progress.RLock()
+	offset := f.Processed
+	i.progress.RUnlock()
+	var fd *os.File
+	var fdInfo os.FileInfo
+	var reader *bufio.Reader
+	defer func() {
+		if fd != nil {
+			fd.Close()
+		}
+	}()
+	partial := ""
+	// process processes a complete line, ending at the given offset
+	process := func(line string) {
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if i.anonymizer != nil {
+			line = i.anonymizer.Line(line)
+		}
+		i.processLogLine(line, stream, fileName, resultsChan, labels, nodePrefix, uniqNodeString, unmatched)
+	}
+	timer := time.Now()
+	stepper := i.config.ProgressPrint.UpdateInterval / 2
+	wait := func() bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(i.config.Follow.PollInterval):
+			return true
+		}
+	}
+	for {
+		if fd == nil {
+			var err error
+			fd, err = os.Open(fileName)
+			if err == nil {
+				fdInfo, err = fd.Stat()
+			}
+			if err != nil {
+				if fd != nil {
+					fd.Close()
+					fd = nil
+				}
+				logger.Detail("Follow: %s: %s", fileName, err)
+				if !wait() {
+					return
+				}
+				continue
+			}
+			if fdInfo.Size() < offset {
+				logger.Info("Follow: %s is smaller than the processed offset, reading from start", fileName)
+				offset = 0
+			}
+			fd.Seek(offset, 0)
+			reader = bufio.NewReaderSize(fd, i.config.Processor.LogReadBufferSizeKb*1024)
+			partial = ""
+		}
+		line, err := reader.ReadString('\n')
+		if err == nil {
+			line = partial + line
+			partial = ""
+			offset += int64(len(line))
+			process(line)
+			if time.Since(timer) > stepper {
+				i.followAckOffset(f, offset, offset, resultsChan)
+				timer = time.Now()
+			}
+			continue
+		}
+		partial += line
+		if err != io.EOF {
+			logger.Error("Follow: could not read %s: %s", fileName, err)
+			fd.Close()
+			fd = nil
+			if !wait() {
+				return
+			}
+			continue
+		}
+		// end of file reached: record progress and check for truncation or rotation before waiting for more data
+		size := offset + int64(len(partial))
+		if info, err := fd.Stat(); err == nil {
+			size = info.Size()
+		}
+		i.followAckOffset(f, offset, size, resultsChan)
+		if info, err := os.Stat(fileName); err == nil && !os.SameFile(info, fdInfo) {
+			logger.Info("Follow: %s was replaced, reading new file from start", fileName)
+			// the last line of the old file will not be completed anymore
+			if partial != "" {
+				process(partial)
+				partial = ""
+			}
+			fd.Close()
+			fd = nil
+			offset = 0
+		} else if size < offset+int64(len(partial)) {
+			logger.Info("Follow: %s was truncated, reading from start", fileName)
+			if partial != "" {
+				process(partial)
+				partial = ""
+			}
+			fd.Seek(0, 0)
+			reader.Reset(fd)
+			offset = 0
+		}
+		if !wait() {
+			return
+		}
+	}
+}
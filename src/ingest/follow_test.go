@@ -0,0 +1,213 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func followTestIngest(t *testing.T) *Ingest {
+	config, err := MakeConfigReader(true, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Directories.NoStatLogs = t.TempDir()
+	config.Follow.PollInterval = 10 * time.Millisecond
+	config.findClusterNameNodeIdRegex = regexp.MustCompile(config.FindClusterNameNodeIdRegex)
+	p := new(patterns)
+	if err = yaml.Unmarshal(patternEmbed, p); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.compile(); err != nil {
+		t.Fatal(err)
+	}
+	i := &Ingest{config: config, patterns: p, progress: new(Progress)}
+	i.progress.LogProcessor = &ProgressLogProcessor{LineErrors: new(lineErrors)}
+	i.progress.PreProcessor = &ProgressPreProcessor{LastUsedPrefix: 2}
+	i.followInitProgress()
+	return i
+}
+
+// followTestRun tails the file until the expected number of system set points is received, acknowledging offsets like the store does
+func followTestRun(t *testing.T, i *Ingest, fileName string, f *LogFile, appendLines string, expect int) []*processResult {
+	return followTestRunAck(t, i, fileName, f, appendLines, expect, true)
+}
+
+func followTestRunAck(t *testing.T, i *Ingest, fileName string, f *LogFile, appendLines string, expect int, ack bool) []*processResult {
+	ctx, cancel := context.WithCancel(context.Background())
+	resultsChan := make(chan *processResult)
+	results := []*processResult{}
+	lock := new(sync.Mutex)
+	done := make(chan struct{})
+	go func() {
+		for r := range resultsChan {
+			if r.Ack != nil {
+				if ack {
+					r.Ack()
+				}
+				continue
+			}
+			lock.Lock()
+			results = append(results, r)
+			lock.Unlock()
+		}
+		close(done)
+	}()
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		i.followFile(ctx, fileName, f, resultsChan)
+	}()
+	if appendLines != "" {
+		time.Sleep(50 * time.Millisecond)
+		fd, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fd.WriteString(appendLines)
+		fd.Close()
+	}
+	timeout := time.Now().Add(5 * time.Second)
+	for time.Now().Before(timeout) {
+		lock.Lock()
+		count := 0
+		for _, r := range results {
+			if r.SetName == "system" {
+				count++
+			}
+		}
+		lock.Unlock()
+		if count >= expect {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+	close(resultsChan)
+	<-done
+	system := []*processResult{}
+	for _, r := range results {
+		if r.SetName == "system" {
+			system = append(system, r)
+		}
+	}
+	return system
+}
+
+func TestFollow(t *testing.T) {
+	i := followTestIngest(t)
+	fileName := path.Join(t.TempDir(), "aerospike.log")
+	err := os.WriteFile(fileName, []byte(`Nov 01 2023 10:00:00 GMT: INFO (info): (ticker.c:160) NODE-ID bb9 CLUSTER-SIZE 1 CLUSTER-NAME mydc
+Nov 01 2023 10:00:00 GMT: INFO (info): (ticker.c:170) system-memory: free-kbytes 100 free-pct 50
+Nov 01 2023 10:00:10 GMT: INFO (info): (ticker.c:170) system-memory: free-kbytes 200`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := i.followIdentify(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ClusterName != "mydc" || f.NodeID != "bb9" || f.NodePrefix != "3" || i.progress.PreProcessor.NodeToPrefix["mydc_bb9"] != 3 {
+		t.Fatalf("unexpected identity %+v", f)
+	}
+
+	// the partial last line is only processed once it is complete
+	results := followTestRun(t, i, fileName, f, " free-pct 40\n", 2)
+	if len(results) != 2 || results[0].Data["FreeMemKB"] != 100 || results[1].Data["FreeMemKB"] != 200 || results[1].Metadata["NodeIdent"] != "3_bb9" || results[1].UniqNodeString != "mydc::/::3_bb9" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	info, _ := os.Stat(fileName)
+	if f.Processed != info.Size() {
+		t.Fatalf("offset %d not at end of file %d", f.Processed, info.Size())
+	}
+
+	// restart resumes from the recorded offset
+	if f2, _ := i.followIdentify(fileName); f2 != f {
+		t.Fatal("progress entry not reused")
+	}
+	results = followTestRun(t, i, fileName, f, "Nov 01 2023 10:00:20 GMT: INFO (info): (ticker.c:170) system-memory: free-kbytes 300 free-pct 30\n", 1)
+	if len(results) != 1 || results[0].Data["FreeMemKB"] != 300 {
+		t.Fatalf("unexpected results after resume %+v", results)
+	}
+
+	// truncation restarts from the beginning of the file
+	os.WriteFile(fileName, []byte("Nov 01 2023 10:00:30 GMT: INFO (info): (ticker.c:170) system-memory: free-kbytes 400 free-pct 20\n"), 0644)
+	results = followTestRun(t, i, fileName, f, "", 1)
+	if len(results) != 1 || results[0].Data["FreeMemKB"] != 400 {
+		t.Fatalf("unexpected results after truncation %+v", results)
+	}
+}
+
+func TestFollowOffsetAck(t *testing.T) {
+	i := followTestIngest(t)
+	fileName := path.Join(t.TempDir(), "aerospike.log")
+	err := os.WriteFile(fileName, []byte(`Nov 01 2023 10:00:00 GMT: INFO (info): (ticker.c:160) NODE-ID bb9 CLUSTER-SIZE 1 CLUSTER-NAME mydc
+Nov 01 2023 10:00:00 GMT: INFO (info): (ticker.c:170) system-memory: free-kbytes 100 free-pct 50
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := i.followIdentify(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// points the store has not acknowledged may not have been written, their lines must be read again after a restart
+	if results := followTestRunAck(t, i, fileName, f, "", 1, false); len(results) != 1 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if f.Processed != 0 {
+		t.Fatalf("offset %d recorded without the store acknowledging it", f.Processed)
+	}
+	if results := followTestRun(t, i, fileName, f, "", 1); len(results) != 1 || results[0].Data["FreeMemKB"] != 100 {
+		t.Fatalf("unexpected results after restart %+v", results)
+	}
+	info, _ := os.Stat(fileName)
+	if f.Processed != info.Size() {
+		t.Fatalf("offset %d not at end of file %d", f.Processed, info.Size())
+	}
+}
+
+func TestFollowRotatePartialLine(t *testing.T) {
+	i := followTestIngest(t)
+	dir := t.TempDir()
+	fileName := path.Join(dir, "aerospike.log")
+	err := os.WriteFile(fileName, []byte(`Nov 01 2023 10:00:00 GMT: INFO (info): (ticker.c:160) NODE-ID bb9 CLUSTER-SIZE 1 CLUSTER-NAME mydc
+Nov 01 2023 10:00:00 GMT: INFO (info): (ticker.c:170) system-memory: free-kbytes 100 free-pct 50`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := i.followIdentify(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the last line of the rotated file has no newline; it is processed before the new file is read
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.Rename(fileName, fileName+".1")
+		os.WriteFile(fileName, []byte("Nov 01 2023 10:00:10 GMT: INFO (info): (ticker.c:170) system-memory: free-kbytes 200 free-pct 40\n"), 0644)
+	}()
+	results := followTestRun(t, i, fileName, f, "", 2)
+	if len(results) != 2 || results[0].Data["FreeMemKB"] != 100 || results[1].Data["FreeMemKB"] != 200 {
+		t.Fatalf("unexpected results after rotation %+v", results)
+	}
+
+	// same on truncation
+	os.WriteFile(fileName, []byte("Nov 01 2023 10:00:20 GMT: INFO (info): (ticker.c:170) system-memory: free-kbytes 300 free-pct 30\nNov 01 2023 10:00:30 GMT: INFO (info): (ticker.c:170) system-memory: free-kbytes 400 free-pct 20"), 0644)
+	f.Processed = 0
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(fileName, []byte("Nov 01 2023 10:00:40 GMT: INFO (info): (ticker.c:170) system-memory: free-kbytes 500 free-pct 10\n"), 0644)
+	}()
+	results = followTestRun(t, i, fileName, f, "", 3)
+	if len(results) != 3 || results[1].Data["FreeMemKB"] != 400 || results[2].Data["FreeMemKB"] != 500 {
+		t.Fatalf("unexpected results after truncation %+v", results)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"regexp"
 	"runtime/pprof"
+	"strings"
 	"sync"
 
 	"github.com/aerospike/aerospike-client-go/v6"
@@ -21,6 +22,10 @@ func MakeConfigReader(setDefaults bool, configYaml io.Reader, parseEnv bool) (*C
 	config := new(Config)
 	config.Downloader.S3Source = &S3Source{}
 	config.Downloader.SftpSource = &SftpSource{}
+	config.Downloader.GcsSource = &GcsSource{}
+	config.Downloader.AzureSource = &AzureSource{}
+	config.Downloader.HttpSource = &HttpSource{}
+	config.Downloader.ClusterSource = &ClusterSource{}
 	if setDefaults {
 		if err := defaults.Set(config); err != nil {
 			return nil, fmt.Errorf("could not set defaults: %s", err)
@@ -62,30 +67,32 @@ func Init(config *Config) (*Ingest, error) {
 		logger.Debug("==== CONFIG ====")
 		yaml.NewEncoder(os.Stdout).Encode(config)
 	}
-	p := new(patterns)
-	if config.PatternsFile == "" {
-		logger.Debug("INIT: Loading embedded patterns")
-		err := yaml.Unmarshal(patternEmbed, p)
+	p, err := loadPatterns(config.PatternsFile)
+	if err != nil {
+		return nil, err
+	}
+	fr, err := loadFindingRules(config.Findings.RulesFile)
+	if err != nil {
+		return nil, err
+	}
+	var anon *Anonymizer
+	if config.Anonymize.Enabled {
+		ar, err := LoadAnonymizeRules(config.Anonymize.RulesFile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal patterns: %s", err)
+			return nil, err
 		}
-	} else {
-		logger.Debug("INIT: Loading %s", config.PatternsFile)
-		f, err := os.Open(config.PatternsFile)
+		anon, err = NewAnonymizer(ar, config.Anonymize.MapFile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open specified patterns file: %s", err)
+			return nil, err
 		}
-		defer f.Close()
-		err = yaml.NewDecoder(f).Decode(p)
-		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal patterns: %s", err)
+		if config.Anonymize.MapFile == "" {
+			namespace, err := anonymizeNextNamespace(config.Anonymize.NamespaceFile)
+			if err != nil {
+				return nil, err
+			}
+			anon.SetNamespace(namespace)
 		}
 	}
-	logger.Debug("INIT: Compiling patterns")
-	err := p.compile()
-	if err != nil {
-		return nil, err
-	}
 	logger.Debug("INIT: Compiling config regexes")
 	if config.Downloader.S3Source.SearchRegex != "" {
 		regex, err := regexp.Compile(config.Downloader.S3Source.SearchRegex)
@@ -101,15 +108,38 @@ func Init(config *Config) (*Ingest, error) {
 		}
 		config.Downloader.SftpSource.searchRegex = regex
 	}
+	if config.Downloader.GcsSource.SearchRegex != "" {
+		regex, err := regexp.Compile(config.Downloader.GcsSource.SearchRegex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile %s: %s", config.Downloader.GcsSource.SearchRegex, err)
+		}
+		config.Downloader.GcsSource.searchRegex = regex
+	}
+	if config.Downloader.AzureSource.SearchRegex != "" {
+		regex, err := regexp.Compile(config.Downloader.AzureSource.SearchRegex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile %s: %s", config.Downloader.AzureSource.SearchRegex, err)
+		}
+		config.Downloader.AzureSource.searchRegex = regex
+	}
+	if config.Downloader.HttpSource.SearchRegex != "" {
+		regex, err := regexp.Compile(config.Downloader.HttpSource.SearchRegex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile %s: %s", config.Downloader.HttpSource.SearchRegex, err)
+		}
+		config.Downloader.HttpSource.searchRegex = regex
+	}
 	regex, err := regexp.Compile(config.FindClusterNameNodeIdRegex)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compile %s: %s", config.FindClusterNameNodeIdRegex, err)
 	}
 	config.findClusterNameNodeIdRegex = regex
 	i := &Ingest{
-		config:   config,
-		patterns: p,
-		progress: new(Progress),
+		config:       config,
+		patterns:     p,
+		findingRules: fr,
+		anonymizer:   anon,
+		progress:     new(Progress),
 	}
 	logger.Debug("INIT: Connect to backend")
 	err = i.dbConnect()
@@ -145,6 +175,30 @@ func Init(config *Config) (*Ingest, error) {
 		}
 		sources = sources + "sftp " + i.config.Downloader.SftpSource.Host + ":" + i.config.Downloader.SftpSource.PathPrefix + i.config.Downloader.SftpSource.SearchRegex
 	}
+	if i.config.Downloader.GcsSource.Enabled {
+		if sources != "" {
+			sources = sources + "\n"
+		}
+		sources = sources + "gcs " + i.config.Downloader.GcsSource.BucketName + ":/" + i.config.Downloader.GcsSource.PathPrefix + i.config.Downloader.GcsSource.SearchRegex
+	}
+	if i.config.Downloader.AzureSource.Enabled {
+		if sources != "" {
+			sources = sources + "\n"
+		}
+		sources = sources + "azure " + i.config.Downloader.AzureSource.AccountName + "/" + i.config.Downloader.AzureSource.ContainerName + ":/" + i.config.Downloader.AzureSource.PathPrefix + i.config.Downloader.AzureSource.SearchRegex
+	}
+	if i.config.Downloader.HttpSource.Enabled {
+		if sources != "" {
+			sources = sources + "\n"
+		}
+		sources = sources + "http " + strings.Join(httpSourceKeys(i.config.Downloader.HttpSource.URLs), ",") + i.config.Downloader.HttpSource.SearchRegex
+	}
+	if i.config.Downloader.ClusterSource.Enabled {
+		if sources != "" {
+			sources = sources + "\n"
+		}
+		sources = sources + "cluster " + i.config.Downloader.ClusterSource.ClusterName + ":" + i.config.Downloader.ClusterSource.Nodes + ":" + i.config.Downloader.ClusterSource.PathPrefix + i.config.Downloader.ClusterSource.SearchRegex
+	}
 	if i.config.CustomSourceName != "" {
 		if sources != "" {
 			sources = sources + "\n"
@@ -202,6 +256,41 @@ func (i *Ingest) Close() {
 		logger.Debug("CLOSE: Closing CPU profiling file")
 		i.cpuProfile.Close()
 	}
+	i.dbClose()
+}
+
+// loadPatterns loads and compiles the patterns file, or the embedded patterns if patternsFile is empty
+func loadPatterns(patternsFile string) (*patterns, error) {
+	p := new(patterns)
+	if patternsFile == "" {
+		logger.Debug("INIT: Loading embedded patterns")
+		err := yaml.Unmarshal(patternEmbed, p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal patterns: %s", err)
+		}
+	} else {
+		logger.Debug("INIT: Loading %s", patternsFile)
+		f, err := os.Open(patternsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open specified patterns file: %s", err)
+		}
+		defer f.Close()
+		err = yaml.NewDecoder(f).Decode(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal patterns: %s", err)
+		}
+	}
+	logger.Debug("INIT: Compiling patterns")
+	err := p.compile()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DefaultPatterns returns the embedded patterns file, as a starting point for custom patterns
+func DefaultPatterns() []byte {
+	return patternEmbed
 }
 
 func (p *patterns) compile() error {
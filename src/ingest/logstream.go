@@ -22,14 +22,24 @@ type logStream struct {
 	aggregateItems      map[string]*aggregator // where string is the unique string to aggregate against
 	logFileStartTime    time.Time
 	logFileEndTime      time.Time
+	lastAction          string // how the last line was handled if it did not directly produce a point, used by pattern tests
 }
 
+const (
+	logActionTimeRange      = "time-range-skip"
+	logActionMultilineStart = "multiline-start"
+	logActionMultilineJoin  = "multiline-join"
+	logActionAggregated     = "aggregated"
+)
+
 type logStreamOutput struct {
 	Data     map[string]interface{}
 	Metadata map[string]interface{}
 	Line     string
 	Error    error
 	SetName  string
+	Search   string // search string of the pattern which produced the output
+	Regex    string // export regex which produced the output
 }
 
 type multilineItem struct {
@@ -75,15 +85,18 @@ func (s *logStream) Close() (outputs []*logStreamOutput, logStartTime time.Time,
 const parseTimeError = "TIME PARSE: %s"
 
 func (s *logStream) Process(line string, nodePrefix int) ([]*logStreamOutput, error) {
+	s.lastAction = ""
 	timestamp, lineOffset, err := s.lineGetTimestamp(line)
 	if err != nil {
 		return nil, fmt.Errorf(parseTimeError, err)
 	}
 	if s.TimeRanges != nil {
 		if !s.TimeRanges.From.IsZero() && timestamp.Before(s.TimeRanges.From) {
+			s.lastAction = logActionTimeRange
 			return nil, nil
 		}
 		if !s.TimeRanges.To.IsZero() && timestamp.After(s.TimeRanges.To) {
+			s.lastAction = logActionTimeRange
 			return nil, nil
 		}
 	}
@@ -94,6 +107,7 @@ func (s *logStream) Process(line string, nodePrefix int) ([]*logStreamOutput, er
 	for _, m := range s.patterns.Multiline {
 		// check and handle new multiline
 		if strings.Contains(line, m.StartLineSearch) {
+			s.lastAction = logActionMultilineStart
 			if _, ok := s.multilineItems[m.StartLineSearch]; ok {
 				outx, err := s.lineProcess(s.multilineItems[m.StartLineSearch].line, s.multilineItems[m.StartLineSearch].timestamp, s.multilineItems[m.StartLineSearch].nodePrefix)
 				s.multilineItems[m.StartLineSearch] = &multilineItem{
@@ -121,6 +135,7 @@ func (s *logStream) Process(line string, nodePrefix int) ([]*logStreamOutput, er
 			if m.StartLineSearch == mStart {
 				results := m.reMatchLines.FindStringSubmatch(line)
 				if len(results) > 0 {
+					s.lastAction = logActionMultilineJoin
 					if timestamp.Before(s.multilineItems[mStart].timestamp) {
 						//if s.multilineItems[mStart].timestamp != timestamp { // turns out this can happen
 						return nil, errors.New("multiline statistic had timestamps move backwards in time")
@@ -341,12 +356,15 @@ func (s *logStream) lineProcess(line string, timestamp time.Time, nodePrefix int
 							Line:     line,
 							Error:    nil,
 							SetName:  setName,
+							Search:   p.Search,
+							Regex:    r.String(),
 						},
 					}
 				} else {
 					s.aggregateItems[uniq].stat += newVal
 					s.aggregateItems[uniq].out.Data[p.Aggregate.Field] = s.aggregateItems[uniq].stat
 				}
+				s.lastAction = logActionAggregated
 				return ret, nil
 			}
 			ret = append(ret, &logStreamOutput{
@@ -355,6 +373,8 @@ func (s *logStream) lineProcess(line string, timestamp time.Time, nodePrefix int
 				Line:     line,
 				Error:    nil,
 				SetName:  setName,
+				Search:   p.Search,
+				Regex:    r.String(),
 			})
 			if s.logFileStartTime.IsZero() || timestamp.Before(s.logFileStartTime) {
 				s.logFileStartTime = timestamp
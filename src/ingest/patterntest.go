@@ -0,0 +1,166 @@
+package ingest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// pattern test line results
+const (
+	PatternTestMatched        = "matched"
+	PatternTestNotMatched     = "not-matched"
+	PatternTestNoTimestamp    = "no-timestamp"
+	PatternTestMultilineStart = logActionMultilineStart
+	PatternTestMultilineJoin  = logActionMultilineJoin
+	PatternTestAggregated     = logActionAggregated
+	PatternTestError          = "error"
+	PatternTestEndOfFile      = "end-of-file"
+)
+
+// PatternTestLine describes how a log line was handled by the log stream; points may belong to earlier lines, as multiline statistics and aggregations are stored when they complete
+type PatternTestLine struct {
+	LineNo   int                 `json:"lineNo"` // 0 for points flushed at the end of the file
+	Line     string              `json:"line,omitempty"`
+	Severity string              `json:"severity,omitempty"` // log level, ex: INFO, WARNING
+	Context  string              `json:"context,omitempty"`  // log context and source location, ex: (info): (ticker.c:160)
+	Result   string              `json:"result"`
+	Error    string              `json:"error,omitempty"`
+	Points   []*PatternTestPoint `json:"points,omitempty"`
+}
+
+// PatternTestPoint is a point which would be stored in the database
+type PatternTestPoint struct {
+	Search  string                 `json:"search"`
+	Regex   string                 `json:"regex"`
+	SetName string                 `json:"setName"`
+	Labels  map[string]interface{} `json:"labels"`
+	Values  map[string]interface{} `json:"values"`
+	Line    string                 `json:"line"` // line which produced the point; for multiline statistics, the joined line
+}
+
+type PatternTestSummary struct {
+	Lines       int                     `json:"lines"`
+	Matched     int                     `json:"matched"` // lines producing points or taking part in multiline statistics or aggregations
+	NotMatched  int                     `json:"notMatched"`
+	NoTimestamp int                     `json:"noTimestamp"`
+	Errors      int                     `json:"errors"`
+	Points      map[string]int          `json:"points"`    // number of points by set name
+	Unmatched   []*PatternTestUnmatched `json:"unmatched"` // WARNING and INFO lines not matched by any pattern, most frequent first
+}
+
+// PatternTestUnmatched is a group of unmatched lines with the same severity and context
+type PatternTestUnmatched struct {
+	Severity string `json:"severity"`
+	Context  string `json:"context"`
+	Count    int    `json:"count"`
+	Sample   string `json:"sample"`
+}
+
+var patternTestSeverity = regexp.MustCompile(`(CRITICAL|WARNING|INFO|DETAIL|DEBUG|FAILED) (\([^)]*\)): (\([^)]*\))`)
+
+// PatternTest runs the log lines from r through the log stream offline, using the patterns file or the embedded patterns if patternsFile is empty; fn is called for each line
+func PatternTest(patternsFile string, r io.Reader, fn func(*PatternTestLine)) (*PatternTestSummary, error) {
+	p, err := loadPatterns(patternsFile)
+	if err != nil {
+		return nil, err
+	}
+	stream := newLogStream(p, nil, "timestamp")
+	summary := &PatternTestSummary{
+		Points: make(map[string]int),
+	}
+	unmatched := make(map[string]*PatternTestUnmatched)
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 1024*1024), 1024*1024)
+	lineNo := 0
+	for s.Scan() {
+		lineNo++
+		line := s.Text()
+		res := &PatternTestLine{
+			LineNo: lineNo,
+			Line:   line,
+		}
+		if sev := patternTestSeverity.FindStringSubmatch(line); len(sev) > 0 {
+			res.Severity = sev[1]
+			res.Context = sev[2] + ": " + sev[3]
+		}
+		out, err := stream.Process(line, 1)
+		switch {
+		case err == errNotMatched:
+			res.Result = PatternTestNotMatched
+			summary.NotMatched++
+			if res.Severity == "WARNING" || res.Severity == "INFO" {
+				key := res.Severity + " " + res.Context
+				if _, ok := unmatched[key]; !ok {
+					unmatched[key] = &PatternTestUnmatched{
+						Severity: res.Severity,
+						Context:  res.Context,
+						Sample:   line,
+					}
+				}
+				unmatched[key].Count++
+			}
+		case err != nil && strings.HasPrefix(err.Error(), "TIME PARSE:"):
+			res.Result = PatternTestNoTimestamp
+			res.Error = err.Error()
+			summary.NoTimestamp++
+		case err != nil:
+			res.Result = PatternTestError
+			res.Error = err.Error()
+			summary.Errors++
+		case stream.lastAction != "":
+			res.Result = stream.lastAction
+			summary.Matched++
+		default:
+			res.Result = PatternTestMatched
+			summary.Matched++
+		}
+		res.Points = patternTestPoints(out, summary)
+		if fn != nil {
+			fn(res)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("could not read log: %s", err)
+	}
+	summary.Lines = lineNo
+	out, _, _ := stream.Close()
+	if len(out) > 0 && fn != nil {
+		fn(&PatternTestLine{
+			Result: PatternTestEndOfFile,
+			Points: patternTestPoints(out, summary),
+		})
+	}
+	for _, u := range unmatched {
+		summary.Unmatched = append(summary.Unmatched, u)
+	}
+	sort.Slice(summary.Unmatched, func(i, j int) bool {
+		if summary.Unmatched[i].Count == summary.Unmatched[j].Count {
+			return summary.Unmatched[i].Context < summary.Unmatched[j].Context
+		}
+		return summary.Unmatched[i].Count > summary.Unmatched[j].Count
+	})
+	return summary, nil
+}
+
+func patternTestPoints(out []*logStreamOutput, summary *PatternTestSummary) []*PatternTestPoint {
+	points := []*PatternTestPoint{}
+	for _, o := range out {
+		if o == nil {
+			continue
+		}
+		summary.Points[o.SetName]++
+		points = append(points, &PatternTestPoint{
+			Search:  o.Search,
+			Regex:   o.Regex,
+			SetName: o.SetName,
+			Labels:  o.Metadata,
+			Values:  o.Data,
+			Line:    o.Line,
+		})
+	}
+	return points
+}
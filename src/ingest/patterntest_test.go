@@ -0,0 +1,56 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPatternTest(t *testing.T) {
+	log := `Nov 01 2023 10:00:00 GMT: INFO (info): (hist.c:321) histogram dump: {test}-write (100 total) msec
+Nov 01 2023 10:00:00 GMT: INFO (info): (hist.c:331)  (00: 0000000090) (01: 0000000010)
+Nov 01 2023 10:00:10 GMT: INFO (info): (ticker.c:170) system-memory: free-kbytes 100 free-pct 50
+Nov 01 2023 10:00:10 GMT: INFO (info): (ticker.c:999) not a statistic
+Nov 01 2023 10:00:11 GMT: INFO (info): (ticker.c:999) still not a statistic
+Nov 01 2023 10:00:12 GMT: WARNING (drv_ssd): (drv_ssd.c:100) device overloaded
+no timestamp here
+`
+	lines := []*PatternTestLine{}
+	summary, err := PatternTest("", strings.NewReader(log), func(l *PatternTestLine) {
+		lines = append(lines, l)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{PatternTestMultilineStart, PatternTestMultilineJoin, PatternTestMatched, PatternTestNotMatched, PatternTestNotMatched, PatternTestAggregated, PatternTestNoTimestamp, PatternTestEndOfFile}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(lines))
+	}
+	for i, e := range expected {
+		if lines[i].Result != e {
+			t.Errorf("line %d: expected %s, got %s (%s)", i+1, e, lines[i].Result, lines[i].Error)
+		}
+	}
+	if p := lines[2].Points; len(p) != 1 || p[0].SetName != "system" || p[0].Search != "system-memory" || p[0].Values["FreeMemKB"] != "100" {
+		t.Errorf("unexpected system-memory points %+v", p)
+	}
+	// the histogram and the aggregated warnings are flushed at the end of the file
+	hist := 0
+	for _, p := range lines[7].Points {
+		if p.SetName != "histMs" {
+			continue
+		}
+		hist++
+		if p.Labels["Namespace"] != "test" || p.Labels["Histogram"] != "write" || p.Values["00"] != "0000000090" || p.Values["01plus"] != 10 {
+			t.Errorf("unexpected histogram point %+v", p)
+		}
+	}
+	if hist != 1 || len(lines[7].Points) != 2 {
+		t.Errorf("unexpected end of file points %+v", lines[7].Points)
+	}
+	if summary.Lines != 7 || summary.Matched != 4 || summary.NotMatched != 2 || summary.NoTimestamp != 1 || summary.Points["system"] != 1 || summary.Points["histMs"] != 1 || summary.Points["warnings"] != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if len(summary.Unmatched) != 1 || summary.Unmatched[0].Count != 2 || summary.Unmatched[0].Context != "(info): (ticker.c:999)" || summary.Unmatched[0].Severity != "INFO" {
+		t.Errorf("unexpected unmatched groups %+v", summary.Unmatched)
+	}
+}
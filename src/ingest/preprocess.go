@@ -107,21 +107,32 @@ func (i *Ingest) PreProcess() error {
 	}
 	wg.Wait()
 
-	// move other files
-	logger.Debug("Pre-process moving anything left over to the 'other' directory")
-	dirtyRun := path.Join(i.config.Directories.OtherFiles, strconv.Itoa(int(time.Now().Unix())))
-	err = os.MkdirAll(dirtyRun, 0755)
-	if err != nil {
-		return fmt.Errorf("could not create %s for other files: %s", dirtyRun, err)
-	}
+	// move other files; when anonymizing, they are removed instead, as they are not anonymized and the 'other' directory is accessible from the file browser
 	others, err := os.ReadDir(i.config.Directories.DirtyTmp)
 	if err != nil {
 		return fmt.Errorf("could not list directory contents %s for other files: %s", i.config.Directories.DirtyTmp, err)
 	}
-	for _, other := range others {
-		err = os.Rename(path.Join(i.config.Directories.DirtyTmp, other.Name()), path.Join(dirtyRun, other.Name()))
+	if i.anonymizer != nil {
+		logger.Debug("Pre-process removing anything left over, as anonymization is enabled")
+		for _, other := range others {
+			logger.Detail("Pre-process removing %s, it cannot be anonymized", other.Name())
+			err = os.RemoveAll(path.Join(i.config.Directories.DirtyTmp, other.Name()))
+			if err != nil {
+				logger.Error("could not remove %s: %s", path.Join(i.config.Directories.DirtyTmp, other.Name()), err)
+			}
+		}
+	} else {
+		logger.Debug("Pre-process moving anything left over to the 'other' directory")
+		dirtyRun := path.Join(i.config.Directories.OtherFiles, strconv.Itoa(int(time.Now().Unix())))
+		err = os.MkdirAll(dirtyRun, 0755)
 		if err != nil {
-			logger.Error("could not move %s to %s: %s", path.Join(i.config.Directories.DirtyTmp, other.Name()), dirtyRun, err)
+			return fmt.Errorf("could not create %s for other files: %s", dirtyRun, err)
+		}
+		for _, other := range others {
+			err = os.Rename(path.Join(i.config.Directories.DirtyTmp, other.Name()), path.Join(dirtyRun, other.Name()))
+			if err != nil {
+				logger.Error("could not move %s to %s: %s", path.Join(i.config.Directories.DirtyTmp, other.Name()), dirtyRun, err)
+			}
 		}
 	}
 
@@ -149,6 +160,7 @@ func (i *Ingest) preProcessTextFile(fn string, files map[string]*EnumFile) error
 		if err != nil {
 			return err
 		}
+		clusterName = i.anonymizeClusterName(clusterName)
 		var prefix, suffix int
 		i.progress.Lock()
 		if _, ok := i.progress.PreProcessor.NodeToPrefix[clusterName+"_"+nodeId]; !ok {
@@ -0,0 +1,257 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aerospike/aerospike-client-go/v6"
+	"github.com/bestmethod/logger"
+)
+
+// collectinfo sections stored by processCollectInfoSections
+const (
+	cfSectionConfig  = "config"
+	cfSectionStats   = "stats"
+	cfSectionSysinfo = "sysinfo"
+	cfSectionLatency = "latency"
+)
+
+// cfSectionValue is a single value of a collectinfo section, as reported by one node
+type cfSectionValue struct {
+	Section     string
+	ClusterName string
+	NodeID      string
+	IP          string
+	Context     string // ex: service, network, namespace, xdr; for sysinfo and latency, the first level of the section
+	Namespace   string // set for namespace contexts
+	Name        string // dot-separated path of the value within the context
+	Value       string
+}
+
+// cfSections flattens the config, statistics, latency and sysinfo sections of each node in a decoded ascinfo.json; only the latest snapshot is used if the collectinfo contains more than one
+func cfSections(ascinfo map[string]interface{}) []*cfSectionValue {
+	snapshots := []string{}
+	for ts, v := range ascinfo {
+		if _, ok := v.(map[string]interface{}); ok {
+			snapshots = append(snapshots, ts)
+		}
+	}
+	if len(snapshots) == 0 {
+		return nil
+	}
+	sort.Strings(snapshots)
+	values := []*cfSectionValue{}
+	for clusterName, clusterVal := range ascinfo[snapshots[len(snapshots)-1]].(map[string]interface{}) {
+		nodes, ok := clusterVal.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for ipPort, nodeVal := range nodes {
+			node, ok := nodeVal.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			ip := strings.Split(ipPort, ":")[0]
+			nodeId := ip
+			asStat, _ := node["as_stat"].(map[string]interface{})
+			if meta, ok := asStat["meta_data"].(map[string]interface{}); ok {
+				if id, ok := meta["node_id"].(string); ok && id != "" {
+					nodeId = id
+				}
+			}
+			add := func(section string, v interface{}) {
+				cfFlatten(nil, v, func(path []string, value string) {
+					context, namespace, name := cfSectionPath(path)
+					values = append(values, &cfSectionValue{
+						Section:     section,
+						ClusterName: clusterName,
+						NodeID:      nodeId,
+						IP:          ip,
+						Context:     context,
+						Namespace:   namespace,
+						Name:        name,
+						Value:       value,
+					})
+				})
+			}
+			if v, ok := asStat["config"]; ok {
+				add(cfSectionConfig, v)
+			}
+			if v, ok := asStat["statistics"]; ok {
+				add(cfSectionStats, v)
+			}
+			for _, k := range []string{"latency", "latencies"} {
+				if v, ok := asStat[k]; ok {
+					add(cfSectionLatency, v)
+				}
+			}
+			if v, ok := node["sys_stat"]; ok {
+				add(cfSectionSysinfo, v)
+			}
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a := values[i]
+		b := values[j]
+		for _, c := range [][2]string{{a.Section, b.Section}, {a.ClusterName, b.ClusterName}, {a.NodeID, b.NodeID}, {a.Context, b.Context}, {a.Namespace, b.Namespace}} {
+			if c[0] != c[1] {
+				return c[0] < c[1]
+			}
+		}
+		return a.Name < b.Name
+	})
+	return values
+}
+
+// cfFlatten calls fn for each leaf value of v with its path; lists are stored as json, and tables of columns and values (as produced for latencies) are stored one value per column, using the last row
+func cfFlatten(path []string, v interface{}, fn func(path []string, value string)) {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		columns, cok := vv["columns"].([]interface{})
+		rows, rok := vv["values"].([]interface{})
+		if cok && rok && len(vv) == 2 {
+			if len(rows) == 0 {
+				return
+			}
+			row, ok := rows[len(rows)-1].([]interface{})
+			if !ok {
+				break
+			}
+			for n, column := range columns {
+				if n >= len(row) {
+					break
+				}
+				cfFlatten(cfPathAppend(path, fmt.Sprint(column)), row[n], fn)
+			}
+			return
+		}
+		for k, item := range vv {
+			cfFlatten(cfPathAppend(path, k), item, fn)
+		}
+		return
+	case string:
+		fn(path, vv)
+		return
+	case float64:
+		fn(path, strconv.FormatFloat(vv, 'f', -1, 64))
+		return
+	case bool:
+		fn(path, strconv.FormatBool(vv))
+		return
+	case nil:
+		fn(path, "")
+		return
+	}
+	j, _ := json.Marshal(v)
+	fn(path, string(j))
+}
+
+func cfPathAppend(path []string, item string) []string {
+	n := make([]string, len(path), len(path)+1)
+	copy(n, path)
+	return append(n, item)
+}
+
+// cfSectionPath splits the path of a value into its context, namespace and name
+func cfSectionPath(path []string) (context string, namespace string, name string) {
+	if len(path) == 0 {
+		return "", "", ""
+	}
+	if len(path) == 1 {
+		return "", "", path[0]
+	}
+	parts := []string{}
+	for n := 0; n < len(path); n++ {
+		if path[n] == "namespace" && namespace == "" && n < len(path)-2 {
+			namespace = path[n+1]
+			if n == 0 {
+				// namespace context; parameters of the namespace itself are nested under service
+				parts = append(parts, path[n])
+				if path[n+2] == "service" && n+2 < len(path)-1 {
+					n++
+				}
+			}
+			n++
+			continue
+		}
+		parts = append(parts, path[n])
+	}
+	if len(parts) == 1 {
+		return parts[0], namespace, parts[0]
+	}
+	return parts[0], namespace, strings.Join(parts[1:], ".")
+}
+
+// processCollectInfoSections stores the section values of a collectinfo, labelled with the cluster name and node identity used by the log processor; returns the number of values stored
+func (i *Ingest) processCollectInfoSections(filePath string, cfName string, ascinfo map[string]interface{}, logs map[string]map[string]string) (int, error) {
+	sets := map[string]string{
+		cfSectionConfig:  i.config.CollectInfoSections.ConfigSetName,
+		cfSectionStats:   i.config.CollectInfoSections.StatsSetName,
+		cfSectionSysinfo: i.config.CollectInfoSections.SysinfoSetName,
+		cfSectionLatency: i.config.CollectInfoSections.LatencySetName,
+	}
+	values := cfSections(ascinfo)
+	wg := new(sync.WaitGroup)
+	threads := make(chan bool, i.config.Aerospike.MaxPutThreads)
+	errLock := new(sync.Mutex)
+	var firstErr error
+	count := 0
+	for _, v := range values {
+		setName := sets[v.Section]
+		if setName == "" {
+			continue
+		}
+		clusterName := v.ClusterName
+		if clusterName == "null" {
+			clusterName = "unset"
+		}
+		nodeIdent := v.NodeID
+		if prefix, ok := logs[strings.ToLower(clusterName)][strings.ToLower(v.NodeID)]; ok {
+			nodeIdent = prefix + "_" + v.NodeID
+		}
+		bins := aerospike.BinMap{
+			"cfName":      cfName,
+			"ClusterName": clusterName,
+			"NodeIdent":   nodeIdent,
+			"nodeId":      v.NodeID,
+			"ip":          v.IP,
+			"context":     v.Context,
+			"name":        v.Name,
+			"value":       v.Value,
+		}
+		if v.Namespace != "" {
+			bins["Namespace"] = v.Namespace
+		}
+		key, err := aerospike.NewKey(i.config.Aerospike.Namespace, setName, fmt.Sprintf("%s::%s::%s::%s::%s", filePath, v.NodeID, v.Context, v.Namespace, v.Name))
+		if err != nil {
+			return count, fmt.Errorf("aerospike.NewKey: %s", err)
+		}
+		count++
+		wg.Add(1)
+		threads <- true
+		go func(key *aerospike.Key, bins aerospike.BinMap) {
+			defer func() {
+				<-threads
+				wg.Done()
+			}()
+			aerr := i.db.Put(i.wp, key, bins)
+			if aerr != nil {
+				errLock.Lock()
+				if firstErr == nil {
+					firstErr = aerr
+				}
+				errLock.Unlock()
+			}
+		}(key, bins)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return count, fmt.Errorf("aerospike.Put: %s", firstErr)
+	}
+	logger.Detail("processCollectInfoSections: stored %d values of %s", count, filePath)
+	return count, nil
+}
@@ -0,0 +1,67 @@
+package ingest
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCfSections(t *testing.T) {
+	ascinfo := make(map[string]interface{})
+	err := json.Unmarshal([]byte(`{
+	"2023-11-01 09:00:00": {"mydc": {"10.0.0.1:3000": {"as_stat": {"config": {"service": {"proto-fd-max": 10000}}}}}},
+	"2023-11-01 10:00:00": {"mydc": {
+		"10.0.0.1:3000": {
+			"as_stat": {
+				"meta_data": {"node_id": "BB9", "ip": "10.0.0.1:3000"},
+				"config": {
+					"service": {"proto-fd-max": 15000, "feature-key-file": "/etc/aerospike/features.conf"},
+					"namespace": {"test": {"service": {"replication-factor": 2, "storage-engine": "device"}, "set": {"s1": {"stop-writes-count": 0}}}}
+				},
+				"statistics": {"namespace": {"test": {"service": {"objects": 100}}}},
+				"latency": {"read": {"namespace": {"test": {"columns": ["ops/sec", ">1ms"], "values": [[1, 2], [100.5, 0.25]]}}}}
+			},
+			"sys_stat": {"uname": {"kernel_release": "5.15"}, "hostname": ["node1"]}
+		},
+		"10.0.0.2:3000": {
+			"as_stat": {"config": {"service": {"proto-fd-max": 10000}}}
+		}
+	}}}`), &ascinfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]*cfSectionValue)
+	for _, v := range cfSections(ascinfo) {
+		got[v.Section+"/"+v.NodeID+"/"+v.Context+"/"+v.Namespace+"/"+v.Name] = v
+	}
+	expect := map[string]string{
+		"config/BB9/service//proto-fd-max":                   "15000",
+		"config/BB9/service//feature-key-file":               "/etc/aerospike/features.conf",
+		"config/BB9/namespace/test/replication-factor":       "2",
+		"config/BB9/namespace/test/storage-engine":           "device",
+		"config/BB9/namespace/test/set.s1.stop-writes-count": "0",
+		"config/10.0.0.2/service//proto-fd-max":              "10000", // no meta_data, node identified by IP
+		"stats/BB9/namespace/test/objects":                   "100",
+		"latency/BB9/read/test/ops/sec":                      "100.5", // last row of the table
+		"latency/BB9/read/test/>1ms":                         "0.25",
+		"sysinfo/BB9/uname//kernel_release":                  "5.15",
+		"sysinfo/BB9///hostname":                             `["node1"]`,
+	}
+	for k, v := range expect {
+		if got[k] == nil {
+			t.Errorf("missing %s", k)
+			continue
+		}
+		if got[k].Value != v {
+			t.Errorf("%s: expected %q, got %q", k, v, got[k].Value)
+		}
+		if got[k].ClusterName != "mydc" {
+			t.Errorf("%s: unexpected cluster name %s", k, got[k].ClusterName)
+		}
+	}
+	if len(got) != len(expect) {
+		t.Errorf("expected %d values, got %d: %v", len(expect), len(got), got)
+	}
+	if got["config/BB9/service//proto-fd-max"].IP != "10.0.0.1" {
+		t.Errorf("unexpected IP %s", got["config/BB9/service//proto-fd-max"].IP)
+	}
+}
@@ -175,6 +175,7 @@ type cfContents struct {
 	summary     string
 	infoNetJson *cfInfoNetwork
 	ipToNode    map[string][]string
+	ascinfo     map[string]interface{}
 }
 
 func (i *Ingest) processCollectInfoFile(filePath string, cf *CfFile, logs map[string]map[string]string) (string, error) {
@@ -311,6 +312,17 @@ func (i *Ingest) processCollectInfoFile(filePath string, cf *CfFile, logs map[st
 			}
 		}
 	}
+	if i.config.CollectInfoSections.Enabled && ct.ascinfo != nil {
+		logger.Detail("processCollectInfoFile: storing sections of %s", new)
+		count, err := i.processCollectInfoSections(new, fname, ct.ascinfo, logs)
+		i.progress.Lock()
+		cf.SectionRecords = count
+		i.progress.CollectinfoProcessor.changed = true
+		i.progress.Unlock()
+		if err != nil {
+			return newName, fmt.Errorf("storing sections: %s", err)
+		}
+	}
 	logger.Detail("processCollectInfoFile: done %s", new)
 	i.progress.Lock()
 	i.progress.CollectinfoProcessor.changed = true
@@ -516,6 +528,7 @@ func (i *Ingest) processCollectInfoFileRead(filePath string, cf *CfFile, ct *cfC
 			if err != nil {
 				return fmt.Errorf("could not decode ascinfo.json: %s", err)
 			}
+			ct.ascinfo = ret
 			for _, inDtVal := range ret {
 				if _, ok := inDtVal.(map[string]interface{}); !ok {
 					continue
@@ -75,6 +75,25 @@ func (i *Ingest) ProcessLogs() error {
 		i.progress.LogProcessor.Files[n] = f
 	}
 	i.progress.LogProcessor.changed = true
+	i.progress.Unlock()
+	meta := i.processLogsLoadMeta()
+
+	// process
+	resultsChan := make(chan *processResult)
+	go i.processLogsFeed(foundLogs, resultsChan)
+
+	// feed results to backend DB
+	i.processLogsStore(resultsChan, meta)
+
+	// done
+	i.progress.Lock()
+	i.progress.LogProcessor.Finished = true
+	i.progress.Unlock()
+	return nil
+}
+
+// processLogsLoadMeta reads the existing label entries from the database, so that label indexes are reused across runs
+func (i *Ingest) processLogsLoadMeta() map[string]*metaEntries {
 	meta := make(map[string]*metaEntries)
 	recset, err := i.db.ScanAll(nil, i.config.Aerospike.Namespace, i.patterns.LabelsSetName)
 	if err != nil {
@@ -86,7 +105,7 @@ func (i *Ingest) ProcessLogs() error {
 			}
 			for k, v := range rec.Record.Bins {
 				metaItem := &metaEntries{}
-				err = json.Unmarshal(sbs.StringToByteSlice(v.(string)), &metaItem)
+				err := json.Unmarshal(sbs.StringToByteSlice(v.(string)), &metaItem)
 				if err != nil {
 					logger.Warn("Failed to unmarshal existing label data: %s", err)
 				}
@@ -94,17 +113,20 @@ func (i *Ingest) ProcessLogs() error {
 			}
 		}
 	}
-	metaLock := new(sync.Mutex)
-	i.progress.Unlock()
-
-	// process
-	resultsChan := make(chan *processResult)
-	go i.processLogsFeed(foundLogs, resultsChan)
+	return meta
+}
 
-	// feed results to backend DB
+// processLogsStore writes the results to the database until resultsChan is closed, storing labels as indexes into their entries in the labels set
+func (i *Ingest) processLogsStore(resultsChan chan *processResult, meta map[string]*metaEntries) {
+	metaLock := new(sync.Mutex)
 	wg := new(sync.WaitGroup)
 	threads := make(chan bool, i.config.Aerospike.MaxPutThreads)
 	for data := range resultsChan {
+		if data.Ack != nil {
+			wg.Wait()
+			data.Ack()
+			continue
+		}
 		if data.Error != nil {
 			logger.Error("Log Processor: error encountered processing %s: %s", data.FileName, data.Error)
 		}
@@ -181,12 +203,6 @@ func (i *Ingest) ProcessLogs() error {
 		}
 	}
 	wg.Wait()
-
-	// done
-	i.progress.Lock()
-	i.progress.LogProcessor.Finished = true
-	i.progress.Unlock()
-	return nil
 }
 
 func (i *Ingest) processLogsFeed(foundLogs map[string]*LogFile, resultsChan chan *processResult) {
@@ -238,12 +254,14 @@ type processResult struct {
 	SetName        string
 	LogLine        string
 	UniqNodeString string
+	Ack            func() // if set, called by the store once all results received before it have been stored
 }
 
 func (i *Ingest) processLogFile(fileName string, r *os.File, resultsChan chan *processResult, labels map[string]interface{}, nodePrefix int, uniqNodeString string) {
-	_, fn := path.Split(fileName)
-	var unmatched *os.File
-	var err error
+	unmatched := &noStatLog{
+		fileName: path.Join(i.config.Directories.NoStatLogs, labels["ClusterName"].(string), path.Base(fileName)),
+	}
+	defer unmatched.close()
 	s := bufio.NewScanner(r)
 	buffer := make([]byte, i.config.Processor.LogReadBufferSizeKb*1024)
 	s.Buffer(buffer, i.config.Processor.LogReadBufferSizeKb*1024)
@@ -252,66 +270,13 @@ func (i *Ingest) processLogFile(fileName string, r *os.File, resultsChan chan *p
 	stepper := i.config.ProgressPrint.UpdateInterval / 2
 	stream := newLogStream(i.patterns, &i.config.IngestTimeRanges, i.config.Aerospike.TimestampBinName)
 	for s.Scan() {
-		if err = s.Err(); err != nil {
+		if err := s.Err(); err != nil {
 			resultsChan <- &processResult{
 				Error: fmt.Errorf("could not read input file: %s", err),
 			}
 			return
 		}
-		line := s.Text()
-		out, err := stream.Process(line, nodePrefix)
-		if err != nil && err != errNotMatched && err != errNoTimestamp && !strings.HasPrefix(err.Error(), "TIME PARSE:") {
-			logger.Error("Stream Processor for line: %s", err)
-			i.progress.LogProcessor.LineErrors.add(nodePrefix, err.Error())
-			continue
-		}
-		if len(out) == 0 && err != nil && (err == errNotMatched || err == errNoTimestamp || strings.HasPrefix(err.Error(), "TIME PARSE:")) {
-			if unmatched == nil {
-				os.MkdirAll(path.Join(i.config.Directories.NoStatLogs, labels["ClusterName"].(string)), 0755)
-				unmatched, err = os.Create(path.Join(i.config.Directories.NoStatLogs, labels["ClusterName"].(string), fn))
-				if err != nil {
-					logger.Error("Could not create file for non-stat: %s", err)
-				} else {
-					defer unmatched.Close()
-				}
-			}
-			if unmatched != nil {
-				_, err = unmatched.WriteString(line + "\n")
-				if err != nil {
-					logger.Error("Could not write no-stat: %s", err)
-				}
-			}
-			continue
-		}
-		for _, d := range out {
-			results := make(map[string]interface{})
-			for k, v := range d.Data {
-				switch vt := v.(type) {
-				case string:
-					vint, err := strconv.Atoi(vt)
-					if err != nil {
-						results[k] = v
-					} else {
-						results[k] = vint
-					}
-				default:
-					results[k] = v
-				}
-			}
-			meta := d.Metadata
-			for k, v := range labels {
-				meta[k] = v
-			}
-			resultsChan <- &processResult{
-				FileName:       fileName,
-				Data:           results,
-				Error:          d.Error,
-				SetName:        d.SetName,
-				LogLine:        d.Line,
-				Metadata:       meta,
-				UniqNodeString: uniqNodeString,
-			}
-		}
+		i.processLogLine(s.Text(), stream, fileName, resultsChan, labels, nodePrefix, uniqNodeString, unmatched)
 		// tracker of how many lines we processed already
 		if time.Since(timer) > stepper {
 			newloc, _ := r.Seek(0, 1)
@@ -325,6 +290,100 @@ func (i *Ingest) processLogFile(fileName string, r *os.File, resultsChan chan *p
 			timer = time.Now()
 		}
 	}
+	i.processLogClose(stream, fileName, resultsChan, labels, nodePrefix, uniqNodeString)
+
+	// done
+	i.progress.Lock()
+	i.progress.LogProcessor.Files[fileName].Processed = i.progress.LogProcessor.Files[fileName].Size
+	i.progress.LogProcessor.Files[fileName].Finished = true
+	i.progress.LogProcessor.changed = true
+	i.progress.Unlock()
+}
+
+// noStatLog lazily creates the file receiving the log lines which did not produce any stats
+type noStatLog struct {
+	fileName string
+	append   bool
+	f        *os.File
+	failed   bool
+}
+
+func (n *noStatLog) write(line string) {
+	if n.f == nil {
+		if n.failed {
+			return
+		}
+		os.MkdirAll(path.Dir(n.fileName), 0755)
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if n.append {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(n.fileName, flags, 0644)
+		if err != nil {
+			logger.Error("Could not create file for non-stat: %s", err)
+			n.failed = true
+			return
+		}
+		n.f = f
+	}
+	_, err := n.f.WriteString(line + "\n")
+	if err != nil {
+		logger.Error("Could not write no-stat: %s", err)
+	}
+}
+
+func (n *noStatLog) close() {
+	if n.f != nil {
+		n.f.Close()
+		n.f = nil
+	}
+}
+
+// processLogLine feeds a line to the log stream, sending the resulting points to resultsChan and lines without stats to unmatched
+func (i *Ingest) processLogLine(line string, stream *logStream, fileName string, resultsChan chan *processResult, labels map[string]interface{}, nodePrefix int, uniqNodeString string, unmatched *noStatLog) {
+	out, err := stream.Process(line, nodePrefix)
+	if err != nil && err != errNotMatched && err != errNoTimestamp && !strings.HasPrefix(err.Error(), "TIME PARSE:") {
+		logger.Error("Stream Processor for line: %s", err)
+		i.progress.LogProcessor.LineErrors.add(nodePrefix, err.Error())
+		return
+	}
+	if len(out) == 0 && err != nil && (err == errNotMatched || err == errNoTimestamp || strings.HasPrefix(err.Error(), "TIME PARSE:")) {
+		unmatched.write(line)
+		return
+	}
+	for _, d := range out {
+		results := make(map[string]interface{})
+		for k, v := range d.Data {
+			switch vt := v.(type) {
+			case string:
+				vint, err := strconv.Atoi(vt)
+				if err != nil {
+					results[k] = v
+				} else {
+					results[k] = vint
+				}
+			default:
+				results[k] = v
+			}
+		}
+		meta := d.Metadata
+		for k, v := range labels {
+			meta[k] = v
+		}
+		resultsChan <- &processResult{
+			FileName:       fileName,
+			Data:           results,
+			Error:          d.Error,
+			SetName:        d.SetName,
+			LogLine:        d.Line,
+			Metadata:       meta,
+			UniqNodeString: uniqNodeString,
+		}
+	}
+}
+
+// processLogClose flushes the points still held by the log stream and stores the time range covered by the log file
+func (i *Ingest) processLogClose(stream *logStream, fileName string, resultsChan chan *processResult, labels map[string]interface{}, nodePrefix int, uniqNodeString string) {
 	out, startTime, endTime := stream.Close()
 	for _, d := range out {
 		meta := d.Metadata
@@ -342,21 +401,15 @@ func (i *Ingest) processLogFile(fileName string, r *os.File, resultsChan chan *p
 		}
 	}
 	// store startTime and endTime of logs
-	logDir, fileNameOnly := path.Split(fileName)
-	_, clusterName := path.Split(strings.Trim(logDir, "/"))
 	for _, point := range []time.Time{startTime, endTime} {
 		if point.IsZero() {
 			continue
 		}
-		nodePrefix, err := strconv.Atoi(strings.Split(fileNameOnly, "_")[0])
-		if err != nil {
-			continue
-		}
 		meta := make(map[string]interface{})
 		for k, v := range labels {
 			meta[k] = v
 		}
-		meta["fileName"] = clusterName + "/" + fileNameOnly
+		meta["fileName"] = labels["ClusterName"].(string) + "/" + path.Base(fileName)
 		resultsChan <- &processResult{
 			FileName: fileName,
 			Data: map[string]interface{}{
@@ -370,11 +423,4 @@ func (i *Ingest) processLogFile(fileName string, r *os.File, resultsChan chan *p
 			UniqNodeString: uniqNodeString,
 		}
 	}
-
-	// done
-	i.progress.Lock()
-	i.progress.LogProcessor.Files[fileName].Processed = i.progress.LogProcessor.Files[fileName].Size
-	i.progress.LogProcessor.Files[fileName].Finished = true
-	i.progress.LogProcessor.changed = true
-	i.progress.Unlock()
 }
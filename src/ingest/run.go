@@ -55,6 +55,10 @@ func RunWithConfig(config *Config) error {
 	if err != nil {
 		return fmt.Errorf("PreProcess: %s", err)
 	}
+	err = i.Anonymize()
+	if err != nil {
+		return fmt.Errorf("Anonymize: %s", err)
+	}
 	nerr := []error{}
 	wg := new(sync.WaitGroup)
 	wg.Add(2)
@@ -84,5 +88,9 @@ func RunWithConfig(config *Config) error {
 		}
 		return errors.New(errstr)
 	}
+	err = i.Analyze()
+	if err != nil {
+		return fmt.Errorf("Analyze: %s", err)
+	}
 	return nil
 }
@@ -15,6 +15,8 @@ import (
 type Ingest struct {
 	config       *Config
 	patterns     *patterns
+	findingRules *findingRules
+	anonymizer   *Anonymizer
 	cpuProfile   *os.File
 	pprofRunning bool
 	progress     *Progress
@@ -103,6 +105,11 @@ type Config struct {
 		MaxConcurrentLogFiles int `yaml:"maxConcurrentLogFiles" default:"4"`
 		LogReadBufferSizeKb   int `yaml:"logReadBufferSizeKb" default:"1024"`
 	} `yaml:"processor"`
+	Follow struct {
+		Enabled      bool          `yaml:"enabled" envconfig:"LOGINGEST_FOLLOW_ENABLED"`
+		Paths        []string      `yaml:"paths"` // glob patterns of log files to tail; files matching later are picked up while following
+		PollInterval time.Duration `yaml:"pollInterval" default:"1s"`
+	} `yaml:"follow"`
 	PreProcess struct {
 		FileThreads         int `yaml:"fileThreads" default:"6"`
 		UnpackerFileThreads int `yaml:"unpackerFileThreads" default:"4"`
@@ -124,7 +131,25 @@ type Config struct {
 	CollectInfoAsadmTimeout time.Duration `yaml:"collectInfoCommandTimeout" default:"150s"`
 	CollectInfoMaxSize      int64         `yaml:"collectInfoMaxSize" default:"20971520"` // files over 20MiB will be considered not collectinfo
 	CollectInfoSetName      string        `yaml:"collectInfoSetName" default:"collectinfos"`
-	Directories             struct {
+	CollectInfoSections     struct {
+		Enabled        bool   `yaml:"enabled" default:"true"` // store the contents of collectinfo config, statistics, sysinfo and latency sections, one record per node and value
+		ConfigSetName  string `yaml:"configSetName" default:"cfconfig"`
+		StatsSetName   string `yaml:"statsSetName" default:"cfstats"`
+		SysinfoSetName string `yaml:"sysinfoSetName" default:"cfsysinfo"`
+		LatencySetName string `yaml:"latencySetName" default:"cflatency"`
+	} `yaml:"collectInfoSections"`
+	Findings struct {
+		Enabled    bool   `yaml:"enabled" default:"true"` // run the findings rules over the ingested data once processing completes
+		RulesFile  string `yaml:"rulesFile"`              // default: embedded findings rules
+		OutputFile string `yaml:"outputFile" default:"ingest/findings.json"`
+	} `yaml:"findings"`
+	Anonymize struct {
+		Enabled       bool   `yaml:"enabled" envconfig:"LOGINGEST_ANONYMIZE_ENABLED"`    // replace hostnames, IPs, namespace, set and user names in logs and collectinfos before processing
+		RulesFile     string `yaml:"rulesFile"`                                          // default: embedded anonymize rules
+		MapFile       string `yaml:"mapFile"`                                            // if set, keeps tokens consistent across runs; allows reversing the tokens, keep it private; default: tokens are kept in memory only
+		NamespaceFile string `yaml:"namespaceFile" default:"ingest/anonymize-namespace"` // without a map file, each run numbers its tokens in a new namespace counted in this file, so that tokens of separate runs never match
+	} `yaml:"anonymize"`
+	Directories struct {
 		CollectInfo string `yaml:"collectInfo" default:"ingest/files/collectinfo"`
 		Logs        string `yaml:"logs" default:"ingest/files/logs"`
 		DirtyTmp    string `yaml:"dirtyTemp" default:"ingest/files/input"`
@@ -132,9 +157,13 @@ type Config struct {
 		OtherFiles  string `yaml:"otherFiles" default:"ingest/files/other"`
 	} `yaml:"directories"`
 	Downloader struct {
-		ConcurrentSources bool        `yaml:"concurrentSources" default:"true"`
-		S3Source          *S3Source   `yaml:"s3Source"`
-		SftpSource        *SftpSource `yaml:"sftpSource"`
+		ConcurrentSources bool           `yaml:"concurrentSources" default:"true"`
+		S3Source          *S3Source      `yaml:"s3Source"`
+		SftpSource        *SftpSource    `yaml:"sftpSource"`
+		GcsSource         *GcsSource     `yaml:"gcsSource"`
+		AzureSource       *AzureSource   `yaml:"azureSource"`
+		HttpSource        *HttpSource    `yaml:"httpSource"`
+		ClusterSource     *ClusterSource `yaml:"clusterSource"`
 	} `yaml:"downloader"`
 	CustomSourceName           string `yaml:"customSourceName" default:"" envconfig:"LOGINGEST_CUSTOM_SRCNAME"`
 	FindClusterNameNodeIdRegex string `yaml:"findClusterNameNodeIdRegex" default:"NODE-ID (?P<NodeId>[^ ]+) CLUSTER-SIZE (?P<ClusterSize>\\d+)( CLUSTER-NAME (?P<ClusterName>[^$]+))*"`
@@ -160,6 +189,48 @@ type S3Source struct {
 	searchRegex *regexp.Regexp
 }
 
+type GcsSource struct {
+	Enabled         bool   `yaml:"enabled" envconfig:"LOGINGEST_GCSSOURCE_ENABLED"`
+	Threads         int    `yaml:"threads" envconfig:"LOGINGEST_GCSSOURCE_THREADS" default:"4"`
+	BucketName      string `yaml:"bucketName" envconfig:"LOGINGEST_GCSSOURCE_BUCKET"`
+	CredentialsFile string `yaml:"credentialsFile" envconfig:"LOGINGEST_GCSSOURCE_CREDENTIALS"` // service account json; if empty, default credentials are used, falling back to anonymous access
+	Endpoint        string `yaml:"endpoint" envconfig:"LOGINGEST_GCSSOURCE_ENDPOINT"`           // default: https://storage.googleapis.com
+	PathPrefix      string `yaml:"pathPrefix" envconfig:"LOGINGEST_GCSSOURCE_PATH"`
+	SearchRegex     string `yaml:"searchRegex" envconfig:"LOGINGEST_GCSSOURCE_REGEX"`
+	searchRegex     *regexp.Regexp
+}
+
+type AzureSource struct {
+	Enabled       bool   `yaml:"enabled" envconfig:"LOGINGEST_AZURESOURCE_ENABLED"`
+	Threads       int    `yaml:"threads" envconfig:"LOGINGEST_AZURESOURCE_THREADS" default:"4"`
+	AccountName   string `yaml:"accountName" envconfig:"LOGINGEST_AZURESOURCE_ACCOUNT"`
+	ContainerName string `yaml:"containerName" envconfig:"LOGINGEST_AZURESOURCE_CONTAINER"`
+	SasToken      string `yaml:"sasToken" envconfig:"LOGINGEST_AZURESOURCE_SAS"`      // if empty, the container must allow anonymous access
+	Endpoint      string `yaml:"endpoint" envconfig:"LOGINGEST_AZURESOURCE_ENDPOINT"` // default: https://ACCOUNTNAME.blob.core.windows.net
+	PathPrefix    string `yaml:"pathPrefix" envconfig:"LOGINGEST_AZURESOURCE_PATH"`
+	SearchRegex   string `yaml:"searchRegex" envconfig:"LOGINGEST_AZURESOURCE_REGEX"`
+	searchRegex   *regexp.Regexp
+}
+
+type HttpSource struct {
+	Enabled     bool     `yaml:"enabled" envconfig:"LOGINGEST_HTTPSOURCE_ENABLED"`
+	Threads     int      `yaml:"threads" envconfig:"LOGINGEST_HTTPSOURCE_THREADS" default:"4"`
+	URLs        []string `yaml:"urls" envconfig:"LOGINGEST_HTTPSOURCE_URLS"`
+	Username    string   `yaml:"username" envconfig:"LOGINGEST_HTTPSOURCE_USER"`
+	Password    string   `yaml:"password" envconfig:"LOGINGEST_HTTPSOURCE_PASSWORD"`
+	SearchRegex string   `yaml:"searchRegex" envconfig:"LOGINGEST_HTTPSOURCE_REGEX"` // applied on host/path of each URL
+	searchRegex *regexp.Regexp
+}
+
+// ClusterSource logs are pulled from the cluster nodes by aerolab using the backend, and staged in DirtyTmp/clustersource/CLUSTERNAME/node-X.tgz; the downloader only records them
+type ClusterSource struct {
+	Enabled     bool   `yaml:"enabled" envconfig:"LOGINGEST_CLUSTERSOURCE_ENABLED"`
+	ClusterName string `yaml:"clusterName" envconfig:"LOGINGEST_CLUSTERSOURCE_NAME"`
+	Nodes       string `yaml:"nodes" envconfig:"LOGINGEST_CLUSTERSOURCE_NODES"`
+	PathPrefix  string `yaml:"pathPrefix" envconfig:"LOGINGEST_CLUSTERSOURCE_PATH"`
+	SearchRegex string `yaml:"searchRegex" envconfig:"LOGINGEST_CLUSTERSOURCE_REGEX"`
+}
+
 type SftpSource struct {
 	Enabled     bool   `yaml:"enabled" envconfig:"LOGINGEST_SFTPSOURCE_ENABLED"`
 	Threads     int    `yaml:"threads" envconfig:"LOGINGEST_SFTPSOURCE_THREADS" default:"4"`
@@ -176,6 +247,12 @@ type SftpSource struct {
 //go:embed patterns.yml
 var patternEmbed []byte
 
+//go:embed findings.yml
+var findingsEmbed []byte
+
+//go:embed anonymize.yml
+var anonymizeEmbed []byte
+
 type patterns struct {
 	Timestamps []*struct {
 		Definition string `yaml:"definition"`
@@ -234,12 +311,16 @@ type Progress struct {
 }
 
 type ProgressDownloader struct {
-	S3Files    map[string]*DownloaderFile // map[key]*details
-	SftpFiles  map[string]*DownloaderFile // map[path]*details
-	Finished   bool
-	running    bool
-	wasRunning bool
-	changed    bool
+	S3Files      map[string]*DownloaderFile // map[key]*details
+	SftpFiles    map[string]*DownloaderFile // map[path]*details
+	GcsFiles     map[string]*DownloaderFile // map[object]*details
+	AzureFiles   map[string]*DownloaderFile // map[blob]*details
+	HttpFiles    map[string]*DownloaderFile // map[host/path]*details
+	ClusterFiles map[string]*DownloaderFile // map[cluster/node-X.tgz]*details
+	Finished     bool
+	running      bool
+	wasRunning   bool
+	changed      bool
 }
 
 type ProgressUnpacker struct {
@@ -264,6 +345,7 @@ type ProgressPreProcessor struct {
 
 type ProgressLogProcessor struct {
 	Files      map[string]*LogFile
+	Followed   map[string]*LogFile // files tailed in follow mode; Processed is the offset from which following resumes
 	Finished   bool
 	running    bool
 	wasRunning bool
@@ -298,14 +380,28 @@ type CfFile struct {
 	OriginalName        string
 	ProcessingAttempted bool
 	Processed           bool
+	SectionRecords      int // number of config, statistics, sysinfo and latency values stored
 	Errors              []string
 }
 
+// Sources returns the files of each downloader source, keyed by the source directory name in DirtyTmp
+func (p *ProgressDownloader) Sources() map[string]map[string]*DownloaderFile {
+	return map[string]map[string]*DownloaderFile{
+		"s3source":      p.S3Files,
+		"sftpsource":    p.SftpFiles,
+		"gcssource":     p.GcsFiles,
+		"azuresource":   p.AzureFiles,
+		"httpsource":    p.HttpFiles,
+		"clustersource": p.ClusterFiles,
+	}
+}
+
 type DownloaderFile struct {
 	Size         int64
 	LastModified time.Time
 	IsDownloaded bool
 	Error        string
+	Validator    string // http only: strong ETag or Last-Modified of a partial download, sent as If-Range when resuming it
 }
 
 type EnumFile struct {
@@ -322,6 +418,7 @@ type EnumFile struct {
 	PreProcessDuplicateOf []string
 	StartAt               int64 // workaround for log files starting at binary 000s
 	PreProcessOutPaths    []string
+	Anonymized            bool
 }
 
 type jsonPayload struct {
@@ -364,8 +461,10 @@ type IngestSteps struct {
 	Download             bool
 	Unpack               bool
 	PreProcess           bool
+	Anonymize            bool
 	ProcessLogs          bool
 	ProcessCollectInfo   bool
+	Analyze              bool
 	CriticalError        string
 	DownloadStartTime    time.Time
 	DownloadEndTime      time.Time
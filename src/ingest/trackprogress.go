@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/bestmethod/logger"
@@ -20,6 +22,10 @@ func (i *Ingest) loadProgress() error {
 	i.progress.Unpacker = new(ProgressUnpacker)
 	i.progress.Downloader.S3Files = make(map[string]*DownloaderFile)
 	i.progress.Downloader.SftpFiles = make(map[string]*DownloaderFile)
+	i.progress.Downloader.GcsFiles = make(map[string]*DownloaderFile)
+	i.progress.Downloader.AzureFiles = make(map[string]*DownloaderFile)
+	i.progress.Downloader.HttpFiles = make(map[string]*DownloaderFile)
+	i.progress.Downloader.ClusterFiles = make(map[string]*DownloaderFile)
 	os.MkdirAll(i.config.ProgressFile.OutputFilePath, 0755)
 	fileList := []string{"downloader.json", "unpacker.json", "pre-processor.json", "log-processor.json", "cf-processor.json"}
 	logger.Debug("INIT: Loading progress")
@@ -224,60 +230,47 @@ func (i *Ingest) printProgressInterval() {
 func (i *Ingest) printProgress() error {
 	i.progress.RLock()
 	if i.progress.Downloader.wasRunning {
-		s3total := 0
-		s3done := 0
-		sftptotal := 0
-		sftpdone := 0
-		s3sizeTotal := int64(0)
-		s3sizeDown := int64(0)
-		sftpSizeTotal := int64(0)
-		sftpSizeDown := int64(0)
-		for k, o := range i.progress.Downloader.S3Files {
-			s3total++
-			s3sizeTotal += o.Size
-			downloadedSize := o.Size
-			if o.IsDownloaded {
-				s3done++
-				s3sizeDown += o.Size
-			} else {
-				fs, err := os.Stat(path.Join(i.config.Directories.DirtyTmp, "s3source", k))
-				if err == nil {
-					s3sizeDown += fs.Size()
-					downloadedSize = fs.Size()
-				} else {
-					logger.Detail("TrackProcess: stat %s: %s", path.Join(i.config.Directories.DirtyTmp, "sftpsource", k), err)
-					downloadedSize = 0
-				}
-			}
-			if i.config.ProgressPrint.PrintDetailProgress {
-				logger.Info("downloader detail source:s3 file:%s size:%s downloadedSize=%s modified:%v isDownloaded:%t error:'%s'", k, convSize(o.Size), convSize(downloadedSize), o.LastModified, o.IsDownloaded, o.Error)
-			}
+		sources := i.progress.Downloader.Sources()
+		sourceNames := []string{}
+		for source := range sources {
+			sourceNames = append(sourceNames, source)
 		}
-		for k, o := range i.progress.Downloader.SftpFiles {
-			sftptotal++
-			sftpSizeTotal += o.Size
-			downloadedSize := o.Size
-			if o.IsDownloaded {
-				sftpdone++
-				sftpSizeDown += o.Size
-			} else {
-				fs, err := os.Stat(path.Join(i.config.Directories.DirtyTmp, "sftpsource", k))
-				if err == nil {
-					sftpSizeDown += fs.Size()
-					downloadedSize = fs.Size()
+		sort.Strings(sourceNames)
+		for _, source := range sourceNames {
+			files := sources[source]
+			if len(files) == 0 {
+				continue
+			}
+			name := strings.TrimSuffix(source, "source")
+			total := 0
+			done := 0
+			sizeTotal := int64(0)
+			sizeDown := int64(0)
+			for k, o := range files {
+				total++
+				sizeTotal += o.Size
+				downloadedSize := o.Size
+				if o.IsDownloaded {
+					done++
+					sizeDown += o.Size
 				} else {
-					logger.Detail("TrackProcess: stat %s: %s", path.Join(i.config.Directories.DirtyTmp, "sftpsource", k), err)
-					downloadedSize = 0
+					fs, err := os.Stat(path.Join(i.config.Directories.DirtyTmp, source, k))
+					if err == nil {
+						sizeDown += fs.Size()
+						downloadedSize = fs.Size()
+					} else {
+						logger.Detail("TrackProcess: stat %s: %s", path.Join(i.config.Directories.DirtyTmp, source, k), err)
+						downloadedSize = 0
+					}
+				}
+				if i.config.ProgressPrint.PrintDetailProgress {
+					logger.Info("downloader detail source:%s file:%s size:%s downloadedSize:%s modified:%v isDownloaded:%t error:'%s'", name, k, convSize(o.Size), convSize(downloadedSize), o.LastModified, o.IsDownloaded, o.Error)
 				}
 			}
-			if i.config.ProgressPrint.PrintDetailProgress {
-				logger.Info("downloader detail source:sftp file:%s size:%s downloadedSize:%s modified:%v isDownloaded:%t error:'%s'", k, convSize(o.Size), convSize(downloadedSize), o.LastModified, o.IsDownloaded, o.Error)
+			if i.config.ProgressPrint.PrintOverallProgress {
+				logger.Info("downloader progress source:%-7s totalFiles:%d downloadedFiles:%d totalSize:%s downloadedSize:%s", name, total, done, convSize(sizeTotal), convSize(sizeDown))
 			}
 		}
-		if i.config.ProgressPrint.PrintOverallProgress {
-			logger.Info("downloader progress source:s3   totalFiles:%d downloadedFiles:%d totalSize:%s downloadedSize:%s", s3total, s3done, convSize(s3sizeTotal), convSize(s3sizeDown))
-			logger.Info("downloader progress source:sftp totalFiles:%d downloadedFiles:%d totalSize:%s downloadedSize:%s", sftptotal, sftpdone, convSize(sftpSizeTotal), convSize(sftpSizeDown))
-		}
 		i.progress.Downloader.wasRunning = i.progress.Downloader.running
 		if !i.progress.Downloader.wasRunning {
 			logger.Info("downloader finished")
@@ -352,6 +345,9 @@ func (i *Ingest) printProgress() error {
 			for fn, file := range i.progress.LogProcessor.Files {
 				logger.Info("LogProcessor detail file:%s (size:%s) (processed:%s) (clusterName:%s) (finished:%t) (fullNodeIdent:%s)", fn, convSize(file.Size), convSize(file.Processed), file.ClusterName, file.Finished, file.NodePrefix+"_"+file.NodeID+"_"+file.NodeSuffix)
 			}
+			for fn, file := range i.progress.LogProcessor.Followed {
+				logger.Info("LogProcessor follow file:%s (size:%s) (processed:%s) (clusterName:%s) (fullNodeIdent:%s)", fn, convSize(file.Size), convSize(file.Processed), file.ClusterName, file.NodePrefix+"_"+file.NodeID)
+			}
 		}
 		if i.config.ProgressPrint.PrintOverallProgress {
 			timePassedx := time.Since(i.progress.LogProcessor.StartTime)
@@ -30,6 +30,7 @@ type helpCmd struct{}
 type commandsDefaults struct {
 	MakeConfig bool    `hidden:"true" long:"make-config" description:"Make configuration file with current parameters"`
 	DryRun     bool    `hidden:"true" long:"dry-run" description:"Do not run the command (useful with --make-config parameter)"`
+	NoCache    bool    `long:"no-cache" description:"Do not use the inventory cache, list clusters and nodes from the backend directly"`
 	Help       helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
 }
 
@@ -161,8 +162,10 @@ var chooseBackendHelpMsg = `
 Create a config file and select a backend first using one of:
 
 $ %s config backend -t docker [-d /path/to/tmpdir/for-aerolab/to/use]
+$ %s config backend -t podman [-d /path/to/tmpdir/for-aerolab/to/use]
 $ %s config backend -t aws [-r region] [-p /custom/path/to/store/ssh/keys/in/] [-d /path/to/tmpdir/for-aerolab/to/use]
 $ %s config backend -t gcp -o project-name [-d /path/to/tmpdir/for-aerolab/to/use]
+$ %s config backend -t kubernetes [--kube-context name] [--kube-registry registry]
 
 Default file path is ${HOME}/.aerolab/conf
 
@@ -213,12 +216,12 @@ func (a *aerolab) main(name string, args []string) error {
 	_, err := a.parseFile()
 	if err != nil {
 		_, fna := path.Split(os.Args[0])
-		fmt.Printf(chooseBackendHelpMsg, fna, fna, fna)
+		fmt.Printf(chooseBackendHelpMsg, fna, fna, fna, fna, fna)
 		os.Exit(1)
 	}
 	if !a.forceFileOptional && a.opts.Config.Backend.Type == "" {
 		_, fna := path.Split(os.Args[0])
-		fmt.Printf(chooseBackendHelpMsg, fna, fna, fna)
+		fmt.Printf(chooseBackendHelpMsg, fna, fna, fna, fna, fna)
 		os.Exit(1)
 	}
 
@@ -290,6 +293,12 @@ func earlyProcessV2(tail []string, initBackend bool) (early bool) {
 	return false
 }
 
+// telemetryWait blocks until the telemetry started by the previous earlyProcess has finished reading the options
+func telemetryWait() {
+	telemetryNoSaveMutex.Lock()
+	telemetryNoSaveMutex.Unlock()
+}
+
 var currentTelemetry telemetryItem
 var telemetryDir string
 var telemetryNoSave = true
@@ -1,8 +1,47 @@
 package plugin
 
-import "net/http"
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
 
+	"github.com/bestmethod/logger"
+)
+
+type tagKeyResponse struct {
+	Type string `json:"type"` // string|number
+	Text string `json:"text"`
+}
+
+// handleTagKeys lists the bins available to ad-hoc filters; labels are strings, other bins numbers
 func (p *Plugin) handleTagKeys(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
-	w.Write([]byte("not implemented"))
+	logger.Info("QUERY START (type:tag-keys) (remote:%s)", r.RemoteAddr)
+	defer logger.Info("QUERY END (type:tag-keys) (remote:%s)", r.RemoteAddr)
+	response := []*tagKeyResponse{}
+	p.cache.lock.RLock()
+	for name := range p.cache.metadata {
+		response = append(response, &tagKeyResponse{
+			Type: "string",
+			Text: name,
+		})
+	}
+	for _, name := range p.cache.binNames {
+		if _, ok := p.cache.metadata[name]; ok || name == p.config.Aerospike.TimestampBinName {
+			continue
+		}
+		response = append(response, &tagKeyResponse{
+			Type: "number",
+			Text: name,
+		})
+	}
+	p.cache.lock.RUnlock()
+	sort.Slice(response, func(i, j int) bool {
+		if response[i].Type != response[j].Type {
+			return response[i].Type == "string"
+		}
+		return response[i].Text < response[j].Text
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(response)
 }
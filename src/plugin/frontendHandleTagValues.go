@@ -1,8 +1,55 @@
 package plugin
 
-import "net/http"
+import (
+	"encoding/json"
+	"io"
+	"net/http"
 
+	"github.com/bestmethod/logger"
+	"github.com/rglonek/sbs"
+)
+
+type tagValuesQuery struct {
+	Key string `json:"key"`
+}
+
+type tagValueResponse struct {
+	Text string `json:"text"`
+}
+
+// handleTagValues lists the values of a label for ad-hoc filters; numeric bins have no predefined values
 func (p *Plugin) handleTagValues(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
-	w.Write([]byte("not implemented"))
+	logger.Info("QUERY START (type:tag-values) (remote:%s)", r.RemoteAddr)
+	defer logger.Info("QUERY END (type:tag-values) (remote:%s)", r.RemoteAddr)
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Failed to read body (remote:%s) (error:%s)", r.RemoteAddr, err)
+		return
+	}
+	logger.Detail("(remote:%s) (payload:%s)", r.RemoteAddr, sbs.ByteSliceToString(body))
+	query := new(tagValuesQuery)
+	err = json.Unmarshal(body, query)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Failed to unmarshal json request (remote:%s) (error:%s)", r.RemoteAddr, err)
+		return
+	}
+	response := []*tagValueResponse{}
+	p.cache.lock.RLock()
+	if meta, ok := p.cache.metadata[query.Key]; ok {
+		found := make(map[string]bool)
+		for _, entry := range meta.Entries {
+			if found[entry] {
+				continue
+			}
+			found[entry] = true
+			response = append(response, &tagValueResponse{
+				Text: entry,
+			})
+		}
+	}
+	p.cache.lock.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(response)
 }
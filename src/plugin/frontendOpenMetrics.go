@@ -0,0 +1,128 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bestmethod/logger"
+)
+
+// handleOpenMetrics exports ingested timeseries for offline import into prometheus-compatible databases;
+// form: ?set=NAME&bin=NAME&from=TIME&to=TIME&format=openmetrics|prometheus, where set and bin may be repeated,
+// no sets exports all sets, no bins exports all numeric bins, and the required times are RFC3339 or unix milliseconds
+func (p *Plugin) handleOpenMetrics(w http.ResponseWriter, r *http.Request) {
+	logger.Info("QUERY INCOMING (type:openmetrics) (remote:%s)", r.RemoteAddr)
+	qtime := time.Now()
+	p.requests <- true
+	defer func() {
+		<-p.requests
+		logger.Info("QUERY END (type:openmetrics) (runningRequests:%d) (runningJobs:%d) (remote:%s) (totalTime:%s)", len(p.requests), len(p.jobs), r.RemoteAddr, time.Since(qtime).String())
+	}()
+	if err := r.ParseForm(); err != nil {
+		responseError(w, http.StatusBadRequest, "Failed to parse form (remote:%s) (error:%s)", r.RemoteAddr, err)
+		return
+	}
+	req := &metricsRequest{
+		Sets:        r.Form["set"],
+		Bins:        r.Form["bin"],
+		OpenMetrics: true,
+	}
+	switch r.FormValue("format") {
+	case "", "openmetrics":
+	case "prometheus":
+		req.OpenMetrics = false
+	default:
+		responseError(w, http.StatusBadRequest, "Unsupported format %s, supported: openmetrics|prometheus (remote:%s)", r.FormValue("format"), r.RemoteAddr)
+		return
+	}
+	// an export of all data would walk every set from the epoch, the time range must be given, ex: from=${__from}&to=${__to} in a grafana dashboard link
+	if r.FormValue("from") == "" || r.FormValue("to") == "" {
+		responseError(w, http.StatusBadRequest, "The from and to times are required (remote:%s)", r.RemoteAddr)
+		return
+	}
+	var err error
+	req.From, err = parseMetricsTime(r.FormValue("from"))
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid from time (remote:%s) (error:%s)", r.RemoteAddr, err)
+		return
+	}
+	req.To, err = parseMetricsTime(r.FormValue("to"))
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "Invalid to time (remote:%s) (error:%s)", r.RemoteAddr, err)
+		return
+	}
+	if len(req.Sets) == 0 {
+		p.cache.lock.RLock()
+		for _, setName := range p.cache.setNames {
+			if setName != p.config.LabelsSetName {
+				req.Sets = append(req.Sets, setName)
+			}
+		}
+		p.cache.lock.RUnlock()
+	}
+	logger.Detail("(remote:%s) (sets:%v) (bins:%v) (from:%s) (to:%s) (openmetrics:%t)", r.RemoteAddr, req.Sets, req.Bins, req.From, req.To, req.OpenMetrics)
+	logger.Info("QUERY ALLOCATE_JOB (type:openmetrics) (runningJobs:%d) (remote:%s)", len(p.jobs), r.RemoteAddr)
+	p.jobs <- true
+	defer func() {
+		<-p.jobs
+	}()
+	// sets are queried and written one at a time, as samples are only ordered by time once a set has been read; errors after the first
+	// set has been written abort the response, so that a truncated export is not mistaken for a complete one
+	streaming := false
+	writeHeader := func() {
+		if streaming {
+			return
+		}
+		if req.OpenMetrics {
+			w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		}
+		w.WriteHeader(http.StatusOK)
+		streaming = true
+	}
+	datapointCount := 0
+	written := make(map[string]string)
+	for _, setName := range req.Sets {
+		families, err := p.queryMetrics(req, setName, &datapointCount, r.RemoteAddr, r)
+		if err == nil {
+			for name := range families {
+				if prev, ok := written[name]; ok {
+					err = fmt.Errorf("sets %s and %s export the same metric %s, export them separately", prev, setName, name)
+					break
+				}
+				written[name] = setName
+			}
+		}
+		if err != nil {
+			if !streaming {
+				responseError(w, http.StatusInternalServerError, "Query failed (remote:%s) (error:%s)", r.RemoteAddr, err)
+				return
+			}
+			logger.Error("Query failed, aborting export (type:openmetrics) (remote:%s) (error:%s)", r.RemoteAddr, err)
+			panic(http.ErrAbortHandler)
+		}
+		writeHeader()
+		if err = writeMetricsFamilies(w, families, req.OpenMetrics); err != nil {
+			logger.Warn("Failed to write metrics (type:openmetrics) (remote:%s) (error:%s)", r.RemoteAddr, err)
+			return
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	writeHeader()
+	if err = writeMetricsEnd(w, req.OpenMetrics); err != nil {
+		logger.Warn("Failed to write metrics (type:openmetrics) (remote:%s) (error:%s)", r.RemoteAddr, err)
+	}
+}
+
+// parseMetricsTime parses RFC3339 or unix millisecond times
+func parseMetricsTime(value string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,113 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/aerospike/aerospike-client-go/v6"
+	ParticleType "github.com/aerospike/aerospike-client-go/v6/types/particle_type"
+)
+
+// adHocExpressions converts grafana ad-hoc filters to filter expressions, to be and-ed with the query filters
+func (p *Plugin) adHocExpressions(filters []*adHocFilter) ([]*aerospike.Expression, error) {
+	exps := []*aerospike.Expression{}
+	for _, filter := range filters {
+		exp, err := p.adHocExpression(filter)
+		if err != nil {
+			return nil, fmt.Errorf("ad-hoc filter %s %s %s: %s", filter.Key, filter.Operator, filter.Value, err)
+		}
+		exps = append(exps, exp)
+	}
+	return exps, nil
+}
+
+// adHocExpression converts an ad-hoc filter to a filter expression; label bins stored as metadata indexes are matched against the metadata entries,
+// string bins are compared as strings and integer or float bins as numbers; records without the bin only match negative operators (!=, !~)
+func (p *Plugin) adHocExpression(filter *adHocFilter) (*aerospike.Expression, error) {
+	if filter.Key == "" {
+		return nil, fmt.Errorf("filter key not set")
+	}
+	p.cache.lock.RLock()
+	meta, isLabel := p.cache.metadata[filter.Key]
+	p.cache.lock.RUnlock()
+	var match *aerospike.Expression
+	switch filter.Operator {
+	case "=", "!=":
+		branches := []*aerospike.Expression{
+			aerospike.ExpAnd(adHocBinType(filter.Key, ParticleType.STRING), aerospike.ExpEq(aerospike.ExpStringBin(filter.Key), aerospike.ExpStringVal(filter.Value))),
+		}
+		if isLabel {
+			branches = append(branches, adHocLabelMatch(filter.Key, meta, func(entry string) bool { return entry == filter.Value }))
+		} else if val, err := strconv.ParseFloat(filter.Value, 64); err == nil {
+			branches = append(branches, adHocNumberCompare(filter.Key, val, aerospike.ExpEq)...)
+		}
+		match = expOr(branches...)
+	case "=~", "!~":
+		re, err := regexp.Compile("^(?:" + filter.Value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %s", err)
+		}
+		branches := []*aerospike.Expression{
+			aerospike.ExpAnd(adHocBinType(filter.Key, ParticleType.STRING), aerospike.ExpRegexCompare("^("+filter.Value+")$", aerospike.ExpRegexFlagEXTENDED, aerospike.ExpStringBin(filter.Key))),
+		}
+		if isLabel {
+			branches = append(branches, adHocLabelMatch(filter.Key, meta, re.MatchString))
+		}
+		match = expOr(branches...)
+	case "<", ">":
+		if isLabel {
+			return nil, fmt.Errorf("operator %s is not supported on label %s", filter.Operator, filter.Key)
+		}
+		val, err := strconv.ParseFloat(filter.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("operator %s requires a numeric value", filter.Operator)
+		}
+		compare := aerospike.ExpLess
+		if filter.Operator == ">" {
+			compare = aerospike.ExpGreater
+		}
+		match = expOr(adHocNumberCompare(filter.Key, val, compare)...)
+	default:
+		return nil, fmt.Errorf("operator %s not supported, supported: = != =~ !~ < >", filter.Operator)
+	}
+	if filter.Operator == "!=" || filter.Operator == "!~" {
+		return aerospike.ExpNot(match), nil
+	}
+	return match, nil
+}
+
+// adHocBinType matches records where the bin is of the given particle type, guarding typed bin reads
+func adHocBinType(binName string, particleType int) *aerospike.Expression {
+	return aerospike.ExpEq(aerospike.ExpBinType(binName), aerospike.ExpIntVal(int64(particleType)))
+}
+
+// adHocLabelMatch matches label bins which store the index of a matching metadata entry
+func adHocLabelMatch(binName string, meta *metaEntries, matches func(entry string) bool) *aerospike.Expression {
+	vals := []*aerospike.Expression{}
+	for idx, entry := range meta.Entries {
+		if matches(entry) {
+			vals = append(vals, aerospike.ExpEq(aerospike.ExpIntBin(binName), aerospike.ExpIntVal(int64(idx))))
+		}
+	}
+	if len(vals) == 0 {
+		return aerospike.ExpBoolVal(false)
+	}
+	return aerospike.ExpAnd(adHocBinType(binName, ParticleType.INTEGER), expOr(vals...))
+}
+
+// adHocNumberCompare compares integer and float bins with a value, as floats
+func adHocNumberCompare(binName string, val float64, compare func(left *aerospike.Expression, right *aerospike.Expression) *aerospike.Expression) []*aerospike.Expression {
+	return []*aerospike.Expression{
+		aerospike.ExpAnd(adHocBinType(binName, ParticleType.INTEGER), compare(aerospike.ExpToFloat(aerospike.ExpIntBin(binName)), aerospike.ExpFloatVal(val))),
+		aerospike.ExpAnd(adHocBinType(binName, ParticleType.FLOAT), compare(aerospike.ExpFloatBin(binName), aerospike.ExpFloatVal(val))),
+	}
+}
+
+// expOr ors the expressions, as the server requires at least two
+func expOr(exps ...*aerospike.Expression) *aerospike.Expression {
+	if len(exps) == 1 {
+		return exps[0]
+	}
+	return aerospike.ExpOr(exps...)
+}
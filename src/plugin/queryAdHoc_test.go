@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aerospike/aerospike-client-go/v6"
+	ParticleType "github.com/aerospike/aerospike-client-go/v6/types/particle_type"
+)
+
+func adHocTestPlugin(t *testing.T) *Plugin {
+	config, err := MakeConfigReader(true, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Plugin{config: config}
+	p.cache.lock = new(sync.RWMutex)
+	p.cache.metadata = map[string]*metaEntries{
+		"ClusterName": {Entries: []string{"dc1", "dc2", "dc10"}},
+	}
+	p.cache.binNames = []string{"timestamp", "ClusterName", "tps", "latency"}
+	return p
+}
+
+func TestAdHocExpression(t *testing.T) {
+	p := adHocTestPlugin(t)
+	exp, err := p.adHocExpression(&adHocFilter{Key: "ClusterName", Operator: "=~", Value: "dc1.*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := aerospike.ExpOr(
+		aerospike.ExpAnd(adHocBinType("ClusterName", ParticleType.STRING), aerospike.ExpRegexCompare("^(dc1.*)$", aerospike.ExpRegexFlagEXTENDED, aerospike.ExpStringBin("ClusterName"))),
+		aerospike.ExpAnd(adHocBinType("ClusterName", ParticleType.INTEGER), aerospike.ExpOr(
+			aerospike.ExpEq(aerospike.ExpIntBin("ClusterName"), aerospike.ExpIntVal(0)),
+			aerospike.ExpEq(aerospike.ExpIntBin("ClusterName"), aerospike.ExpIntVal(2)),
+		)),
+	)
+	if !reflect.DeepEqual(exp, expected) {
+		t.Error("unexpected regex label expression")
+	}
+
+	exp, err = p.adHocExpression(&adHocFilter{Key: "tps", Operator: "!=", Value: "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = aerospike.ExpNot(aerospike.ExpOr(
+		aerospike.ExpAnd(adHocBinType("tps", ParticleType.STRING), aerospike.ExpEq(aerospike.ExpStringBin("tps"), aerospike.ExpStringVal("5"))),
+		aerospike.ExpAnd(adHocBinType("tps", ParticleType.INTEGER), aerospike.ExpEq(aerospike.ExpToFloat(aerospike.ExpIntBin("tps")), aerospike.ExpFloatVal(5))),
+		aerospike.ExpAnd(adHocBinType("tps", ParticleType.FLOAT), aerospike.ExpEq(aerospike.ExpFloatBin("tps"), aerospike.ExpFloatVal(5))),
+	))
+	if !reflect.DeepEqual(exp, expected) {
+		t.Error("unexpected numeric not-equals expression")
+	}
+
+	exp, err = p.adHocExpression(&adHocFilter{Key: "ClusterName", Operator: "=", Value: "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = aerospike.ExpOr(
+		aerospike.ExpAnd(adHocBinType("ClusterName", ParticleType.STRING), aerospike.ExpEq(aerospike.ExpStringBin("ClusterName"), aerospike.ExpStringVal("missing"))),
+		aerospike.ExpBoolVal(false),
+	)
+	if !reflect.DeepEqual(exp, expected) {
+		t.Error("unexpected expression for label value not in metadata")
+	}
+
+	for _, filter := range []*adHocFilter{
+		{Key: "ClusterName", Operator: "<", Value: "5"},
+		{Key: "tps", Operator: ">", Value: "many"},
+		{Key: "tps", Operator: "=~", Value: "("},
+		{Key: "tps", Operator: "<=", Value: "5"},
+		{Key: "", Operator: "=", Value: "5"},
+	} {
+		if _, err = p.adHocExpressions([]*adHocFilter{filter}); err == nil {
+			t.Errorf("expected error for filter %s %s %s", filter.Key, filter.Operator, filter.Value)
+		}
+	}
+}
+
+func TestAdHocTags(t *testing.T) {
+	p := adHocTestPlugin(t)
+	w := httptest.NewRecorder()
+	p.handleTagKeys(w, httptest.NewRequest("POST", "/tag-keys", strings.NewReader("{}")))
+	if out := strings.TrimSpace(w.Body.String()); out != `[{"type":"string","text":"ClusterName"},{"type":"number","text":"latency"},{"type":"number","text":"tps"}]` {
+		t.Errorf("unexpected tag keys %s", out)
+	}
+	w = httptest.NewRecorder()
+	p.handleTagValues(w, httptest.NewRequest("POST", "/tag-values", strings.NewReader(`{"key":"ClusterName"}`)))
+	if out := strings.TrimSpace(w.Body.String()); out != `[{"text":"dc1"},{"text":"dc2"},{"text":"dc10"}]` {
+		t.Errorf("unexpected tag values %s", out)
+	}
+	w = httptest.NewRecorder()
+	p.handleTagValues(w, httptest.NewRequest("POST", "/tag-values", strings.NewReader(`{"key":"tps"}`)))
+	if out := strings.TrimSpace(w.Body.String()); out != `[]` {
+		t.Errorf("unexpected numeric tag values %s", out)
+	}
+}
@@ -0,0 +1,249 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aerospike/aerospike-client-go/v6"
+	"github.com/bestmethod/inslice"
+	"github.com/bestmethod/logger"
+)
+
+type metricsRequest struct {
+	Sets        []string
+	Bins        []string // empty: all numeric bins
+	From        time.Time
+	To          time.Time
+	OpenMetrics bool // false: prometheus text exposition format, with millisecond timestamps
+}
+
+type metricsFamily struct {
+	name   string
+	series map[string]*metricsSeries
+}
+
+type metricsSeries struct {
+	labels  []*metricsLabel
+	samples []*metricsSample
+}
+
+type metricsLabel struct {
+	name  string
+	value string
+}
+
+type metricsSample struct {
+	timestampMs int64
+	value       float64
+}
+
+var metricsNameInvalid = regexp.MustCompile("[^a-zA-Z0-9_:]")
+var metricsLabelInvalid = regexp.MustCompile("[^a-zA-Z0-9_]")
+var metricsLabelEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsName returns the metric family name of a bin in a set, ex: agi_latency_read
+func metricsName(setName string, binName string) string {
+	return metricsNameInvalid.ReplaceAllString("agi_"+setName+"_"+binName, "_")
+}
+
+// metricsLabelName returns a valid label name for a metadata bin, ex: ClusterName
+func metricsLabelName(binName string) string {
+	name := metricsLabelInvalid.ReplaceAllString(binName, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// add stores a sample in the series of the given labels, creating the series as needed; labels must be sorted by name
+func (f *metricsFamily) add(labels []*metricsLabel, sample *metricsSample) {
+	key := ""
+	for _, label := range labels {
+		key = key + label.name + "\x00" + label.value + "\x00"
+	}
+	series, ok := f.series[key]
+	if !ok {
+		series = &metricsSeries{
+			labels: labels,
+		}
+		f.series[key] = series
+	}
+	series.samples = append(series.samples, sample)
+}
+
+// writeMetrics writes the families in the OpenMetrics or prometheus text format, followed by the OpenMetrics end marker
+func writeMetrics(w io.Writer, families map[string]*metricsFamily, openMetrics bool) error {
+	if err := writeMetricsFamilies(w, families, openMetrics); err != nil {
+		return err
+	}
+	return writeMetricsEnd(w, openMetrics)
+}
+
+// writeMetricsEnd writes the end marker the OpenMetrics format requires after the last family
+func writeMetricsEnd(w io.Writer, openMetrics bool) error {
+	if !openMetrics {
+		return nil
+	}
+	_, err := io.WriteString(w, "# EOF\n")
+	return err
+}
+
+// writeMetricsFamilies writes the families, sorted by name, series by labels and samples by time;
+// samples with a duplicate timestamp in a series are skipped, as neither format allows them
+func writeMetricsFamilies(w io.Writer, families map[string]*metricsFamily, openMetrics bool) error {
+	names := []string{}
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		family := families[name]
+		if _, err := fmt.Fprintf(w, "# TYPE %s gauge\n", name); err != nil {
+			return err
+		}
+		keys := []string{}
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			series := family.series[key]
+			labels := []string{}
+			for _, label := range series.labels {
+				labels = append(labels, label.name+"=\""+metricsLabelEscape.Replace(label.value)+"\"")
+			}
+			seriesName := name
+			if len(labels) > 0 {
+				seriesName = name + "{" + strings.Join(labels, ",") + "}"
+			}
+			sort.SliceStable(series.samples, func(i, j int) bool {
+				return series.samples[i].timestampMs < series.samples[j].timestampMs
+			})
+			lastTs := int64(-1)
+			for _, sample := range series.samples {
+				if sample.timestampMs == lastTs {
+					continue
+				}
+				lastTs = sample.timestampMs
+				ts := strconv.FormatInt(sample.timestampMs, 10)
+				if openMetrics {
+					ts = fmt.Sprintf("%d.%03d", sample.timestampMs/1000, sample.timestampMs%1000)
+				}
+				if _, err := fmt.Fprintf(w, "%s %s %s\n", seriesName, strconv.FormatFloat(sample.value, 'g', -1, 64), ts); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// queryMetrics reads the numeric bins of a set in the time range, converting label bins to their metadata values;
+// datapointCount is the number of datapoints read so far by the request, limited to MaxDataPointsReceived
+func (p *Plugin) queryMetrics(req *metricsRequest, setName string, datapointCount *int, remote string, r *http.Request) (map[string]*metricsFamily, error) {
+	families := make(map[string]*metricsFamily)
+	tsBin := p.config.Aerospike.TimestampBinName
+	logger.Detail("Query start (type:openmetrics) (set:%s) (remote:%s)", setName, remote)
+	var stmt *aerospike.Statement
+	if len(req.Bins) == 0 {
+		stmt = aerospike.NewStatement(p.config.Aerospike.Namespace, setName)
+	} else {
+		binList := append([]string{tsBin}, req.Bins...)
+		p.cache.lock.RLock()
+		for name := range p.cache.metadata {
+			binList = append(binList, name)
+		}
+		p.cache.lock.RUnlock()
+		stmt = aerospike.NewStatement(p.config.Aerospike.Namespace, setName, binList...)
+	}
+	aerr := stmt.SetFilter(aerospike.NewRangeFilter(tsBin, req.From.UnixMilli(), req.To.UnixMilli()))
+	if aerr != nil {
+		return nil, fmt.Errorf("error creating aerospike filter: %s", aerr)
+	}
+	recset, aerr := p.db.Query(p.queryPolicy(), stmt)
+	if aerr != nil {
+		return nil, fmt.Errorf("%s", aerr)
+	}
+	timedIsCancelled := make(chan bool, 1)
+	timedIsEnd := make(chan bool, 1)
+	go p.timedCheckSocketTimeout(r.Context(), recset, timedIsCancelled, timedIsEnd)
+	err := func() error {
+		defer func() {
+			timedIsEnd <- true
+		}()
+		p.cache.lock.RLock()
+		defer p.cache.lock.RUnlock()
+		for rec := range recset.Results() {
+			if len(timedIsCancelled) > 0 {
+				return errors.New("socket closed by client while enumerating")
+			}
+			if rec.Err != nil {
+				return fmt.Errorf("%s", rec.Err)
+			}
+			ts, ok := rec.Record.Bins[tsBin].(int)
+			if !ok {
+				continue
+			}
+			labels := []*metricsLabel{}
+			values := make(map[string]float64)
+			for k, v := range rec.Record.Bins {
+				if k == tsBin {
+					continue
+				}
+				if meta, ok := p.cache.metadata[k]; ok {
+					idx, ok := v.(int)
+					if !ok || len(meta.Entries) <= idx {
+						return fmt.Errorf("metadata entry at index %v for item %s not found, metadata corrupt or log ingestion in progress", v, k)
+					}
+					labels = append(labels, &metricsLabel{name: metricsLabelName(k), value: meta.Entries[idx]})
+					continue
+				}
+				if len(req.Bins) > 0 && !inslice.HasString(req.Bins, k) {
+					continue
+				}
+				switch vv := v.(type) {
+				case int:
+					values[k] = float64(vv)
+				case int64:
+					values[k] = float64(vv)
+				case float64:
+					values[k] = vv
+				case string:
+					if vva, err := strconv.ParseFloat(vv, 64); err == nil {
+						values[k] = vva
+					}
+				}
+			}
+			sort.Slice(labels, func(i, j int) bool {
+				return labels[i].name < labels[j].name
+			})
+			for k, v := range values {
+				name := metricsName(setName, k)
+				if _, ok := families[name]; !ok {
+					families[name] = &metricsFamily{
+						name:   name,
+						series: make(map[string]*metricsSeries),
+					}
+				}
+				families[name].add(labels, &metricsSample{timestampMs: int64(ts), value: v})
+				*datapointCount++
+				if *datapointCount > p.config.MaxDataPointsReceived {
+					return errors.New("too many datapoints received, limit data by reducing the time range or selecting sets and bins")
+				}
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		return nil, err
+	}
+	logger.Detail("Query end (type:openmetrics) (set:%s) (datapoints:%d) (remote:%s)", setName, *datapointCount, remote)
+	return families, nil
+}
@@ -0,0 +1,86 @@
+package plugin
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOpenMetricsWrite(t *testing.T) {
+	if name := metricsName("latency-read", "ops/sec"); name != "agi_latency_read_ops_sec" {
+		t.Errorf("unexpected metric name %s", name)
+	}
+	if name := metricsLabelName("1st label"); name != "_1st_label" {
+		t.Errorf("unexpected label name %s", name)
+	}
+	families := map[string]*metricsFamily{}
+	for _, name := range []string{"agi_b_x", "agi_a_x"} {
+		families[name] = &metricsFamily{name: name, series: make(map[string]*metricsSeries)}
+	}
+	node1 := []*metricsLabel{{name: "ClusterName", value: `my "dc"`}, {name: "NodeIdent", value: "node1"}}
+	families["agi_a_x"].add(node1, &metricsSample{timestampMs: 2500, value: 2})
+	families["agi_a_x"].add(node1, &metricsSample{timestampMs: 1000, value: 1})
+	families["agi_a_x"].add(node1, &metricsSample{timestampMs: 1000, value: 5})
+	families["agi_b_x"].add([]*metricsLabel{}, &metricsSample{timestampMs: 1000, value: 0.5})
+
+	out := new(bytes.Buffer)
+	if err := writeMetrics(out, families, true); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# TYPE agi_a_x gauge
+agi_a_x{ClusterName="my \"dc\"",NodeIdent="node1"} 1 1.000
+agi_a_x{ClusterName="my \"dc\"",NodeIdent="node1"} 2 2.500
+# TYPE agi_b_x gauge
+agi_b_x 0.5 1.000
+# EOF
+`
+	if out.String() != expected {
+		t.Errorf("unexpected openmetrics output:\n%s", out.String())
+	}
+	out.Reset()
+	if err := writeMetrics(out, families, false); err != nil {
+		t.Fatal(err)
+	}
+	expected = `# TYPE agi_a_x gauge
+agi_a_x{ClusterName="my \"dc\"",NodeIdent="node1"} 1 1000
+agi_a_x{ClusterName="my \"dc\"",NodeIdent="node1"} 2 2500
+# TYPE agi_b_x gauge
+agi_b_x 0.5 1000
+`
+	if out.String() != expected {
+		t.Errorf("unexpected prometheus output:\n%s", out.String())
+	}
+}
+
+func TestOpenMetricsTime(t *testing.T) {
+	if ts, err := parseMetricsTime("1700000000123"); err != nil || ts.UnixMilli() != 1700000000123 {
+		t.Errorf("unix ms: %s, %v", ts, err)
+	}
+	if ts, err := parseMetricsTime("2023-11-14T22:13:20Z"); err != nil || ts.Unix() != 1700000000 {
+		t.Errorf("rfc3339: %s, %v", ts, err)
+	}
+	if _, err := parseMetricsTime("yesterday"); err == nil {
+		t.Error("expected invalid time error")
+	}
+}
+
+func TestOpenMetricsTimeRangeRequired(t *testing.T) {
+	p := &Plugin{config: &Config{}, requests: make(chan bool, 1), jobs: make(chan bool, 1)}
+	p.cache.lock = new(sync.RWMutex)
+	for _, query := range []string{"", "?set=latency", "?from=1000", "?to=2000"} {
+		w := httptest.NewRecorder()
+		p.handleOpenMetrics(w, httptest.NewRequest(http.MethodGet, "/openmetrics"+query, nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "required") {
+			t.Errorf("%q: expected time range required error, got %d %s", query, w.Code, w.Body.String())
+		}
+	}
+	// no sets ingested yet, an empty but complete export
+	w := httptest.NewRecorder()
+	p.handleOpenMetrics(w, httptest.NewRequest(http.MethodGet, "/openmetrics?from=1000&to=2000", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "# EOF\n" || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/openmetrics-text") {
+		t.Errorf("expected empty export, got %d %s %q", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
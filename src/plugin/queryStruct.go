@@ -13,7 +13,13 @@ type queryRequest struct {
 	MaxDataPoints int                        `json:"maxDataPoints"`
 	ScopedVars    map[string]*queryScopedVar `json:"scopedVars"`
 	selectedVars  map[string][]string        // extracted from ScopedVars
-	AdHocFilters  []interface{}              `json:"adHocFilters"` // not implemented
+	AdHocFilters  []*adHocFilter             `json:"adhocFilters"`
+}
+
+type adHocFilter struct {
+	Key      string `json:"key"`      // bin name
+	Operator string `json:"operator"` // =|!=|=~|!~|<|>
+	Value    string `json:"value"`
 }
 
 type queryTarget struct {
@@ -38,6 +44,14 @@ type queryPayload struct {
 	SortOrder        []int             `json:"sortOrder"`        // table: by which grouping to sort first, and then second, etc
 	GroupBy          []*requestGroupBy `json:"groupBy"`          // timeseries: which bin values to group by, e.g. ClusterName,NodeIdent
 	TimestampBinName string            `json:"timestampBinName"` // timeseries: name of timestamp bin
+	Pivot            *tablePivot       `json:"pivot"`            // table: turn the values of a bin into columns, e.g. one column per node
+}
+
+type tablePivot struct {
+	Column       string `json:"column"`       // bin whose values become columns, e.g. NodeIdent; must be in bins
+	Value        string `json:"value"`        // bin whose values fill the pivoted columns, e.g. value; must be in bins
+	Mismatch     string `json:"mismatch"`     // if set, add a column with this name, set to "true" for rows where the pivoted values differ
+	MismatchOnly bool   `json:"mismatchOnly"` // only return rows where the pivoted values differ
 }
 
 type requestGroupBy struct {
@@ -3,8 +3,10 @@ package plugin
 import (
 	"fmt"
 	"sort"
+	"strconv"
 
 	"github.com/aerospike/aerospike-client-go/v6"
+	"github.com/bestmethod/inslice"
 	"github.com/bestmethod/logger"
 )
 
@@ -63,6 +65,17 @@ func (p *Plugin) handleQueryTable(req *queryRequest, i int, remote string) (*tab
 			exp = aerospike.ExpAnd(exp, new)
 		}
 	}
+	adHoc, err := p.adHocExpressions(req.AdHocFilters)
+	if err != nil {
+		return nil, err
+	}
+	for _, new := range adHoc {
+		if exp == nil {
+			exp = new
+		} else {
+			exp = aerospike.ExpAnd(exp, new)
+		}
+	}
 	for _, bin := range target.Payload.Bins {
 		if !bin.Required {
 			continue
@@ -110,55 +123,207 @@ func (p *Plugin) handleQueryTable(req *queryRequest, i int, remote string) (*tab
 		}
 		resp.Rows = append(resp.Rows, row)
 	}
+	sortOrder := target.Payload.SortOrder
+	if target.Payload.Pivot != nil {
+		logger.Detail("Pivot data (type:table) (remote:%s)", remote)
+		sortOrder, err = tablePivotSortOrder(resp.Columns, target.Payload.Pivot, sortOrder)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = tablePivotRows(resp, target.Payload.Pivot)
+		if err != nil {
+			return nil, err
+		}
+	}
 	// sort
 	logger.Detail("Sort data (type:table) (remote:%s)", remote)
-	if len(target.Payload.SortOrder) > 0 {
-		sort.Slice(resp.Rows, func(i, j int) bool {
-			for _, so := range target.Payload.SortOrder {
-				rev := false
-				if so < 0 {
-					so = so * -1
-					rev = true
-				}
-				so--
-				ni := resp.Rows[i]
-				nj := resp.Rows[j]
-				switch vi := ni[so].(type) {
+	if err = tableSortRows(resp, sortOrder); err != nil {
+		return nil, err
+	}
+	if resp.Rows == nil {
+		resp.Rows = [][]interface{}{}
+	}
+	logger.Detail("Return data (type:table) (remote:%s)", remote)
+	return resp, nil
+}
+
+// tableSortRows sorts rows by the given 1-based column numbers, negative for descending order
+func tableSortRows(resp *tableResponse, sortOrder []int) error {
+	for _, so := range sortOrder {
+		if so == 0 || so > len(resp.Columns) || -so > len(resp.Columns) {
+			return fmt.Errorf("sortOrder %d out of range, the table has %d columns", so, len(resp.Columns))
+		}
+	}
+	if len(sortOrder) == 0 {
+		return nil
+	}
+	sort.Slice(resp.Rows, func(i, j int) bool {
+		for _, so := range sortOrder {
+			rev := false
+			if so < 0 {
+				so = so * -1
+				rev = true
+			}
+			so--
+			ni := resp.Rows[i]
+			nj := resp.Rows[j]
+			switch vi := ni[so].(type) {
+			case int:
+				switch vj := nj[so].(type) {
 				case int:
-					switch vj := nj[so].(type) {
-					case int:
-						if vi < vj {
-							return !rev
-						} else if vi > vj {
-							return rev
-						}
+					if vi < vj {
+						return !rev
+					} else if vi > vj {
+						return rev
 					}
+				}
+			case float64:
+				switch vj := nj[so].(type) {
 				case float64:
-					switch vj := nj[so].(type) {
-					case float64:
-						if vi < vj {
-							return !rev
-						} else if vi > vj {
-							return rev
-						}
+					if vi < vj {
+						return !rev
+					} else if vi > vj {
+						return rev
 					}
+				}
+			case string:
+				switch vj := nj[so].(type) {
 				case string:
-					switch vj := nj[so].(type) {
-					case string:
-						if vi < vj {
-							return !rev
-						} else if vi > vj {
-							return rev
-						}
+					if vi < vj {
+						return !rev
+					} else if vi > vj {
+						return rev
 					}
 				}
 			}
-			return false
+		}
+		return false
+	})
+	return nil
+}
+
+// tablePivotSortOrder maps sortOrder column numbers of the selected bins to the column numbers of the pivoted table; sorting by the pivot column or value is not possible
+func tablePivotSortOrder(columns []*tableColumn, pivot *tablePivot, sortOrder []int) ([]int, error) {
+	newOrder := []int{}
+	for _, so := range sortOrder {
+		n := so
+		if n < 0 {
+			n = n * -1
+		}
+		if n == 0 || n > len(columns) {
+			return nil, fmt.Errorf("sortOrder %d out of range, %d bins selected", so, len(columns))
+		}
+		if columns[n-1].binName == pivot.Column || columns[n-1].binName == pivot.Value {
+			return nil, fmt.Errorf("sortOrder %d: cannot sort by the pivot column or value %s", so, columns[n-1].binName)
+		}
+		// key columns keep their order, less the pivot column and value before them
+		for _, col := range columns[:n-1] {
+			if col.binName == pivot.Column || col.binName == pivot.Value {
+				n--
+			}
+		}
+		if so < 0 {
+			n = n * -1
+		}
+		newOrder = append(newOrder, n)
+	}
+	return newOrder, nil
+}
+
+// tablePivotRows groups rows by the values of all columns other than the pivot column and value, adding one column per distinct pivot column value;
+// a row missing a pivot column value counts as a mismatch
+func tablePivotRows(resp *tableResponse, pivot *tablePivot) (*tableResponse, error) {
+	colIdx := -1
+	valIdx := -1
+	for n, col := range resp.Columns {
+		switch col.binName {
+		case pivot.Column:
+			colIdx = n
+		case pivot.Value:
+			valIdx = n
+		}
+	}
+	if colIdx < 0 || valIdx < 0 {
+		return nil, fmt.Errorf("pivot column %s and value %s must both be in bins", pivot.Column, pivot.Value)
+	}
+	keyCols := []*tableColumn{}
+	for n, col := range resp.Columns {
+		if n != colIdx && n != valIdx {
+			keyCols = append(keyCols, col)
+		}
+	}
+	type pivotRow struct {
+		key    []interface{}
+		values map[string]interface{}
+	}
+	rows := []*pivotRow{}
+	rowsByKey := make(map[string]*pivotRow)
+	pivotNames := []string{}
+	for _, row := range resp.Rows {
+		key := []interface{}{}
+		for n, v := range row {
+			if n != colIdx && n != valIdx {
+				key = append(key, v)
+			}
+		}
+		keyString := fmt.Sprintf("%#v", key)
+		prow, ok := rowsByKey[keyString]
+		if !ok {
+			prow = &pivotRow{
+				key:    key,
+				values: make(map[string]interface{}),
+			}
+			rowsByKey[keyString] = prow
+			rows = append(rows, prow)
+		}
+		name := fmt.Sprint(row[colIdx])
+		if !inslice.HasString(pivotNames, name) {
+			pivotNames = append(pivotNames, name)
+		}
+		prow.values[name] = row[valIdx]
+	}
+	sort.Strings(pivotNames)
+	presp := &tableResponse{
+		Type:    resp.Type,
+		Columns: keyCols,
+	}
+	for _, name := range pivotNames {
+		presp.Columns = append(presp.Columns, &tableColumn{
+			Text:    name,
+			binName: pivot.Value,
+			Type:    resp.Columns[valIdx].Type,
 		})
 	}
-	if resp.Rows == nil {
-		resp.Rows = [][]interface{}{}
+	if pivot.Mismatch != "" {
+		presp.Columns = append(presp.Columns, &tableColumn{
+			Text: pivot.Mismatch,
+			Type: "string",
+		})
 	}
-	logger.Detail("Return data (type:table) (remote:%s)", remote)
-	return resp, nil
+	for _, prow := range rows {
+		mismatch := len(prow.values) != len(pivotNames)
+		row := prow.key
+		var first interface{}
+		for _, name := range pivotNames {
+			v, ok := prow.values[name]
+			if !ok {
+				row = append(row, "")
+				continue
+			}
+			if first == nil {
+				first = v
+			} else if fmt.Sprint(v) != fmt.Sprint(first) {
+				mismatch = true
+			}
+			row = append(row, v)
+		}
+		if pivot.MismatchOnly && !mismatch {
+			continue
+		}
+		if pivot.Mismatch != "" {
+			row = append(row, strconv.FormatBool(mismatch))
+		}
+		presp.Rows = append(presp.Rows, row)
+	}
+	return presp, nil
 }
@@ -0,0 +1,102 @@
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTablePivotRows(t *testing.T) {
+	resp := &tableResponse{
+		Type: "table",
+		Columns: []*tableColumn{
+			{Text: "Context", binName: "context", Type: "string"},
+			{Text: "Node", binName: "NodeIdent", Type: "string"},
+			{Text: "Name", binName: "name", Type: "string"},
+			{Text: "Value", binName: "value", Type: "string"},
+		},
+		Rows: [][]interface{}{
+			{"service", "2_bb9", "proto-fd-max", "15000"},
+			{"service", "1_bb8", "proto-fd-max", "10000"},
+			{"service", "1_bb8", "batch-index-threads", "4"},
+			{"service", "2_bb9", "batch-index-threads", "4"},
+			{"service", "1_bb8", "feature-key-file", "/etc/features.conf"},
+		},
+	}
+	presp, err := tablePivotRows(resp, &tablePivot{Column: "NodeIdent", Value: "value", Mismatch: "Mismatch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := []string{}
+	for _, c := range presp.Columns {
+		columns = append(columns, c.Text)
+	}
+	if !reflect.DeepEqual(columns, []string{"Context", "Name", "1_bb8", "2_bb9", "Mismatch"}) {
+		t.Fatalf("unexpected columns %v", columns)
+	}
+	expect := [][]interface{}{
+		{"service", "proto-fd-max", "10000", "15000", "true"},
+		{"service", "batch-index-threads", "4", "4", "false"},
+		{"service", "feature-key-file", "/etc/features.conf", "", "true"}, // missing on a node
+	}
+	if !reflect.DeepEqual(presp.Rows, expect) {
+		t.Fatalf("unexpected rows %v", presp.Rows)
+	}
+
+	presp, err = tablePivotRows(resp, &tablePivot{Column: "NodeIdent", Value: "value", MismatchOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(presp.Rows) != 2 || len(presp.Columns) != 4 || presp.Rows[0][1] != "proto-fd-max" || presp.Rows[1][1] != "feature-key-file" {
+		t.Fatalf("unexpected mismatch-only result %v", presp.Rows)
+	}
+
+	if _, err = tablePivotRows(resp, &tablePivot{Column: "ClusterName", Value: "value"}); err == nil {
+		t.Fatal("expected error for pivot column not in bins")
+	}
+}
+
+func TestTablePivotSortOrder(t *testing.T) {
+	columns := []*tableColumn{
+		{Text: "Context", binName: "context", Type: "string"},
+		{Text: "Node", binName: "NodeIdent", Type: "string"},
+		{Text: "Name", binName: "name", Type: "string"},
+		{Text: "Value", binName: "value", Type: "string"},
+	}
+	pivot := &tablePivot{Column: "NodeIdent", Value: "value"}
+	sortOrder, err := tablePivotSortOrder(columns, pivot, []int{-3, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sortOrder, []int{-2, 1}) {
+		t.Fatalf("unexpected pivoted sortOrder %v", sortOrder)
+	}
+	for _, so := range [][]int{{4}, {-2}, {5}, {0}} {
+		if _, err = tablePivotSortOrder(columns, pivot, so); err == nil {
+			t.Errorf("expected error for sortOrder %v", so)
+		}
+	}
+
+	// two pivoted columns, sorting beyond the width of the pivoted table is an error rather than a panic
+	resp := &tableResponse{
+		Type:    "table",
+		Columns: columns,
+		Rows: [][]interface{}{
+			{"service", "1_bb8", "proto-fd-max", "10000"},
+			{"service", "1_bb8", "batch-index-threads", "4"},
+			{"service", "2_bb9", "batch-index-threads", "4"},
+		},
+	}
+	presp, err := tablePivotRows(resp, pivot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tableSortRows(presp, []int{5}); err == nil {
+		t.Fatal("expected error for sortOrder beyond the pivoted table")
+	}
+	if err = tableSortRows(presp, sortOrder); err != nil {
+		t.Fatal(err)
+	}
+	if presp.Rows[0][1] != "proto-fd-max" || presp.Rows[1][1] != "batch-index-threads" {
+		t.Fatalf("unexpected sorted rows %v", presp.Rows)
+	}
+}
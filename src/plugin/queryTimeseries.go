@@ -109,6 +109,12 @@ func (p *Plugin) handleQueryTimeseries(req *queryRequest, i int, remote string,
 		}
 		exp = append(exp, new)
 	}
+	// expression: ad-hoc filters
+	adHoc, err := p.adHocExpressions(req.AdHocFilters)
+	if err != nil {
+		return nil, err
+	}
+	exp = append(exp, adHoc...)
 	// data bin list and expression bin selection
 	binList := []string{}
 	for _, bin := range target.Payload.Bins {
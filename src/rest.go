@@ -1,17 +1,21 @@
 package main
 
 import (
-	"io"
+	"crypto/tls"
+	"errors"
 	"log"
 	"net/http"
-	"os"
 	"reflect"
 	"strings"
+	"time"
+
+	flags "github.com/rglonek/jeddevdk-goflags"
 )
 
 // if tag "command" - /command/
 // if tag "long" - part of payload
 // payload is json containing: {"longName":"value",...}
+// commands are executed as jobs, see restJobs.go
 /* example
    http://127.0.0.1:3030/cluster/create
    {
@@ -22,30 +26,74 @@ import (
 */
 
 type restCmd struct {
-	Listen      string        `short:"l" long:"listen" description:"IP:PORT to listen on" default:"127.0.0.1:3030"`
-	Help        attachCmdHelp `command:"help" subcommands-optional:"true" description:"Print help"`
-	apiCommands []apiCommand
-	stdout      *os.File
-	stderr      *os.File
-	logout      io.Writer
+	Listen              string          `short:"l" long:"listen" description:"IP:PORT to listen on" default:"127.0.0.1:3030"`
+	ReadOnlyConcurrency int             `long:"readonly-concurrency" description:"number of read-only jobs (such as inventory list) which may run at the same time; other jobs run one at a time" default:"4"`
+	CancelTimeout       time.Duration   `long:"cancel-timeout" description:"when cancelling a running job, kill it if it did not exit after an interrupt within this time" default:"30s"`
+	JobExpiry           time.Duration   `long:"job-expiry" description:"forget finished jobs after this time; 0 to keep forever" default:"24h"`
+	CertFile            flags.Filename  `long:"cert-file" description:"path to server cert file; if set together with key-file, the listener uses TLS"`
+	KeyFile             flags.Filename  `long:"key-file" description:"path to server key file for TLS"`
+	AuthType            string          `long:"auth-type" description:"authentication type; supported: none|basic|token" default:"none"`
+	BasicAuthUser       string          `long:"basic-auth-user" description:"basic authentication username" default:"admin"`
+	BasicAuthPass       string          `long:"basic-auth-pass" description:"basic authentication password; required for basic auth"`
+	TokenAuthLocation   flags.Filename  `long:"token-path" description:"directory of token files for token auth, see 'rest-api add-token'; default: ~/.aerolab/rest-tokens"`
+	AddToken            restAddTokenCmd `command:"add-token" subcommands-optional:"true" description:"Generate a new auth token for the rest api"`
+	OpenApi             restOpenApiCmd  `command:"openapi" subcommands-optional:"true" description:"Print the OpenAPI 3 document describing the rest api"`
+	Exec                restExecCmd     `command:"exec" hidden:"true" subcommands-optional:"true" description:"Execute a single rest-api job, reading the request from stdin"`
+	Help                attachCmdHelp   `command:"help" subcommands-optional:"true" description:"Print help"`
+	apiCommands         []apiCommand
+	jobs                *restJobs
+	tokens              restTokens
+	srv                 *http.Server
 }
 
 type apiCommand struct {
 	path        string
 	description string
+	hidden      bool
 }
 
 func (c *restCmd) Execute(args []string) error {
 	if earlyProcessV2(args, false) {
 		return nil
 	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("both cert-file and key-file must be specified to enable TLS")
+	}
+	if c.AuthType == "basic" && c.BasicAuthPass == "" {
+		return errors.New("basic-auth-pass must be specified for basic auth")
+	}
+	err := c.startAuth()
+	if err != nil {
+		return err
+	}
+	if c.AuthType == "none" && !strings.HasPrefix(c.Listen, "127.0.0.1:") && !strings.HasPrefix(c.Listen, "localhost:") {
+		log.Printf("WARNING: listening on %s without authentication", c.Listen)
+	}
 	keys := []string{}
 	keyField := reflect.ValueOf(a.opts).Elem()
 	c.makeApi(keyField, strings.Join(keys, "."), "")
-	log.Printf("Listening on %s...", c.Listen)
-	c.stdout = os.Stdout
-	c.stderr = os.Stderr
-	c.logout = log.Writer()
-	go c.handleApiDo()
-	return http.ListenAndServe(c.Listen, nil)
+	c.startJobs()
+	c.srv = &http.Server{
+		Addr:    c.Listen,
+		Handler: c.authHandler(http.DefaultServeMux),
+	}
+	if c.CertFile != "" {
+		c.srv.TLSConfig = &tls.Config{
+			MinVersion:               tls.VersionTLS12,
+			CurvePreferences:         []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
+			PreferServerCipherSuites: true,
+			CipherSuites: []uint16{
+				tls.TLS_AES_128_GCM_SHA256, tls.TLS_AES_256_GCM_SHA384, tls.TLS_CHACHA20_POLY1305_SHA256, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+		}
+		log.Printf("Listening on %s (TLS)...", c.Listen)
+		err = c.srv.ListenAndServeTLS(string(c.CertFile), string(c.KeyFile))
+	} else {
+		log.Printf("Listening on %s...", c.Listen)
+		err = c.srv.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		return err
+	}
+	log.Print("Done")
+	return nil
 }
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+/*
+token file format - first line is the token, any following lines are command prefixes the token is limited to:
+xJz8...(at least 64 characters)
+cluster/list
+inventory
+aerospike/status
+
+curl -H "Authorization: Bearer xJz8..." http://127.0.0.1:3030/cluster/list
+*/
+
+// restUser is the authenticated caller; an empty allow list means the caller may run all commands
+type restUser struct {
+	name  string
+	allow []string
+}
+
+type restToken struct {
+	restUser
+	token string
+}
+
+type restTokens struct {
+	sync.RWMutex
+	tokens []restToken
+}
+
+type restUserContextKey struct{}
+
+// allowed checks whether the user may execute the given command path, such as "cluster/create"
+func (u *restUser) allowed(command string) bool {
+	if len(u.allow) == 0 {
+		return true
+	}
+	for _, prefix := range u.allow {
+		if command == prefix || strings.HasPrefix(command, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// visible checks whether the user may see the command path in help pages and command listings: the user may run it, or one of its subcommands
+func (u *restUser) visible(command string) bool {
+	if command == "" || u.allowed(command) {
+		return true
+	}
+	for _, prefix := range u.allow {
+		if strings.HasPrefix(prefix, command+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// owns checks whether the user may see and cancel the given job; unrestricted users can access all jobs
+func (u *restUser) owns(j *restJob) bool {
+	return len(u.allow) == 0 || j.Owner == u.name
+}
+
+func getRestUser(r *http.Request) *restUser {
+	u, ok := r.Context().Value(restUserContextKey{}).(*restUser)
+	if !ok {
+		return &restUser{}
+	}
+	return u
+}
+
+func (c *restCmd) tokenPath() (string, error) {
+	if c.TokenAuthLocation != "" {
+		return string(c.TokenAuthLocation), nil
+	}
+	return restDefaultTokenPath()
+}
+
+func restDefaultTokenPath() (string, error) {
+	rootDir, err := a.aerolabRootDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(rootDir, "rest-tokens"), nil
+}
+
+func parseRestToken(name string, contents []byte) (restToken, error) {
+	t := restToken{
+		restUser: restUser{
+			name: name,
+		},
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if t.token == "" {
+			t.token = line
+			continue
+		}
+		t.allow = append(t.allow, strings.Trim(line, "/"))
+	}
+	if len(t.token) < 64 {
+		return t, errors.New("token too short, minimum token length is 64 characters")
+	}
+	return t, nil
+}
+
+// loadTokensDo replaces the tokens with the parsed token files; the path of a token file, relative to the token directory, is the job owner
+func (c *restCmd) loadTokensDo(files []tokenFile) {
+	tokens := []restToken{}
+	for _, f := range files {
+		token, err := parseRestToken(f.name, f.contents)
+		if err != nil {
+			log.Printf("Token file %s: %s", f.path, err)
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	c.tokens.Lock()
+	c.tokens.tokens = tokens
+	c.tokens.Unlock()
+}
+
+// startAuth validates the auth options and starts token monitoring if token auth is in use
+func (c *restCmd) startAuth() error {
+	switch c.AuthType {
+	case "none", "basic":
+		return nil
+	case "token":
+	default:
+		return fmt.Errorf("auth type %s not supported, supported: none|basic|token", c.AuthType)
+	}
+	tokenPath, err := c.tokenPath()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(tokenPath, 0700)
+	if err != nil {
+		return err
+	}
+	t := &tokenDir{
+		path:   tokenPath,
+		logf:   log.Printf,
+		reload: c.loadTokensDo,
+	}
+	t.read()
+	go t.watch()
+	log.Printf("Loading auth tokens from %s", tokenPath)
+	return nil
+}
+
+// authHandler wraps all rest api handlers, rejecting unauthenticated requests and passing the authenticated user in the request context
+func (c *restCmd) authHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.CertFile != "" {
+			w.Header().Add("Strict-Transport-Security", "max-age=31536000")
+		}
+		u, ok := c.checkAuth(r)
+		if !ok {
+			if c.AuthType == "basic" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="restricted"`)
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), restUserContextKey{}, u)))
+	})
+}
+
+func (c *restCmd) checkAuth(r *http.Request) (*restUser, bool) {
+	switch c.AuthType {
+	case "basic":
+		user, pass, ok := r.BasicAuth()
+		if !ok {
+			return nil, false
+		}
+		usermatch := subtle.ConstantTimeCompare([]byte(user), []byte(c.BasicAuthUser))
+		passmatch := subtle.ConstantTimeCompare([]byte(pass), []byte(c.BasicAuthPass))
+		if usermatch == 0 || passmatch == 0 {
+			return nil, false
+		}
+		return &restUser{name: user}, true
+	case "token":
+		t, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || t == "" {
+			return nil, false
+		}
+		c.tokens.RLock()
+		defer c.tokens.RUnlock()
+		for _, token := range c.tokens.tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token.token)) == 1 {
+				u := token.restUser
+				return &u, true
+			}
+		}
+		return nil, false
+	default:
+		return &restUser{}, true
+	}
+}
+
+type restAddTokenCmd struct {
+	TokenName         string         `short:"n" long:"name" description:"token name, used as the file name and as the job owner; default: current timestamp"`
+	TokenAuthLocation flags.Filename `short:"t" long:"token-path" description:"directory where tokens are stored; default: ~/.aerolab/rest-tokens"`
+	TokenSize         int            `short:"s" long:"size" description:"size of the new token to be generated" default:"128"`
+	Allow             []string       `short:"a" long:"allow" description:"limit the token to the given command prefix, ex: cluster/list; can be specified multiple times; default: all commands"`
+	Help              helpCmd        `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *restAddTokenCmd) Execute(args []string) error {
+	if earlyProcessNoBackend(args) {
+		return nil
+	}
+	if c.TokenSize < 64 {
+		return fmt.Errorf("minimum token size is 64")
+	}
+	tokenPath := string(c.TokenAuthLocation)
+	if tokenPath == "" {
+		var err error
+		tokenPath, err = restDefaultTokenPath()
+		if err != nil {
+			return err
+		}
+	}
+	if c.TokenName == "" {
+		c.TokenName = fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	if strings.ContainsAny(c.TokenName, "/\\") {
+		return errors.New("token name cannot contain path separators")
+	}
+	err := os.MkdirAll(tokenPath, 0700)
+	if err != nil {
+		return err
+	}
+	fn := path.Join(tokenPath, c.TokenName)
+	if _, err := os.Stat(fn); err == nil {
+		return fmt.Errorf("token %s already exists", fn)
+	}
+	newToken, err := restRandToken(c.TokenSize)
+	if err != nil {
+		return err
+	}
+	contents := newToken + "\n"
+	for _, allow := range c.Allow {
+		contents = contents + strings.Trim(allow, "/") + "\n"
+	}
+	err = os.WriteFile(fn, []byte(contents), 0600)
+	if err != nil {
+		return err
+	}
+	fmt.Println(newToken)
+	return nil
+}
+
+// restRandToken generates a token of n alphanumeric characters from a cryptographically secure source
+func restRandToken(n int) (string, error) {
+	const letterBytes = "abcdefghijklmnopqrstuvwxyz1234567890ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	sb := strings.Builder{}
+	sb.Grow(n)
+	buf := make([]byte, n)
+	for sb.Len() < n {
+		_, err := rand.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			// 6 bits per character; indexes past the alphabet are discarded, so that all characters are equally likely
+			if idx := int(b & 63); idx < len(letterBytes) && sb.Len() < n {
+				sb.WriteByte(letterBytes[idx])
+			}
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRestToken(t *testing.T) {
+	token := strings.Repeat("a", 64)
+	tok, err := parseRestToken("ro", []byte(token+"\n# comment\n/cluster/list/\n\ninventory\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.token != token || tok.name != "ro" {
+		t.Errorf("unexpected token %q name %q", tok.token, tok.name)
+	}
+	if !reflect.DeepEqual(tok.allow, []string{"cluster/list", "inventory"}) {
+		t.Errorf("unexpected allow list %v", tok.allow)
+	}
+	if _, err := parseRestToken("short", []byte("abc\n")); err == nil {
+		t.Error("expected error for short token")
+	}
+}
+
+func TestRestLoadTokens(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "team"), 0700)
+	os.WriteFile(filepath.Join(dir, "alice"), []byte(strings.Repeat("a", 64)+"\ncluster/list\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "team", "alice"), []byte(strings.Repeat("b", 64)+"\ncluster/list\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "short"), []byte("abc\n"), 0600)
+	c := &restCmd{}
+	(&tokenDir{path: dir, logf: log.Printf, reload: c.loadTokensDo}).read()
+	names := []string{}
+	for _, token := range c.tokens.tokens {
+		names = append(names, token.name)
+	}
+	if !reflect.DeepEqual(names, []string{"alice", "team/alice"}) {
+		t.Fatalf("unexpected token names %v", names)
+	}
+	// token files of the same name in different directories are different users
+	if c.tokens.tokens[1].owns(&restJob{Owner: c.tokens.tokens[0].name}) {
+		t.Error("user owns the jobs of another user with the same token file name")
+	}
+}
+
+func TestRestUserAllowed(t *testing.T) {
+	u := &restUser{name: "ro", allow: []string{"cluster/list", "inventory"}}
+	for command, expect := range map[string]bool{
+		"cluster/list":   true,
+		"inventory/list": true,
+		"cluster/create": false,
+		"cluster/listx":  false,
+		"quit":           false,
+	} {
+		if u.allowed(command) != expect {
+			t.Errorf("%s: expected allowed=%t", command, expect)
+		}
+	}
+	if !(&restUser{}).allowed("quit") {
+		t.Error("unrestricted user must be allowed all commands")
+	}
+	if u.owns(&restJob{Owner: "admin"}) || !u.owns(&restJob{Owner: "ro"}) {
+		t.Error("restricted user must only own its jobs")
+	}
+	if !u.visible("cluster") || !u.visible("") || u.visible("xdr") || !u.visible("inventory/list") {
+		t.Error("restricted user must see the commands, and parents of commands, it may run")
+	}
+}
+
+func TestRestRandToken(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		token, err := restRandToken(64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(token) != 64 || strings.Trim(token, "abcdefghijklmnopqrstuvwxyz1234567890ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			t.Fatalf("unexpected token %q", token)
+		}
+		if seen[token] {
+			t.Fatalf("duplicate token %q", token)
+		}
+		seen[token] = true
+	}
+}
+
+func TestRestHelpAcl(t *testing.T) {
+	c := &restCmd{
+		apiCommands: []apiCommand{
+			{path: "cluster", description: "cluster commands"},
+			{path: "cluster/list", description: "list clusters"},
+			{path: "cluster/create", description: "create a cluster"},
+			{path: "rest-api/exec", hidden: true},
+		},
+	}
+	user := &restUser{name: "ro", allow: []string{"cluster/list"}}
+	for urlpath, expect := range map[string]int{
+		"/cluster/create/help": http.StatusForbidden,
+		"/cluster/create":      http.StatusForbidden,
+		"/xdr":                 http.StatusForbidden,
+		"/rest-api/exec/help":  http.StatusNotFound,
+		"/rest-api/exec":       http.StatusNotFound,
+		"/cluster/help":        http.StatusOK,
+		"/cluster":             http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, urlpath, nil)
+		c.handleApi(rec, req.WithContext(context.WithValue(req.Context(), restUserContextKey{}, user)))
+		if rec.Code != expect {
+			t.Errorf("%s: expected %d, got %d %q", urlpath, expect, rec.Code, rec.Body.String())
+			continue
+		}
+		if expect == http.StatusOK && (!strings.Contains(rec.Body.String(), "cluster/list") || strings.Contains(rec.Body.String(), "cluster/create")) {
+			t.Errorf("%s: listing must only show permitted commands, got %q", urlpath, rec.Body.String())
+		}
+	}
+}
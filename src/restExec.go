@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+)
+
+type restExecCmd struct {
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+// runs a single job submitted to the rest-api; request is read from stdin as restJobRequest, output goes to stdout/stderr
+func (c *restExecCmd) Execute(args []string) error {
+	if earlyProcessNoBackend(args) {
+		return nil
+	}
+	req := restJobRequest{}
+	err := json.NewDecoder(os.Stdin).Decode(&req)
+	if err != nil {
+		return err
+	}
+	rc := &restCmd{}
+	keys := []string{}
+	keyField := reflect.ValueOf(a.opts).Elem()
+	v, err := rc.findCommand(keyField, strings.Join(keys, "."), "", []string{}, req.Command)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return errors.New("command not found")
+	}
+	body := []byte(req.Payload)
+	if len(body) == 0 {
+		body = []byte("{}")
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(v.Addr().Interface())
+	if err != nil {
+		return err
+	}
+
+	if len(req.Command) == 2 && req.Command[0] == "config" && req.Command[1] == "backend" && strings.Contains(string(body), "\"Type\"") {
+		a.opts.Config.Backend.typeSet = "yes"
+	} else {
+		a.opts.Config.Backend.typeSet = ""
+	}
+
+	// some commands inspect the command line, make it look like the command was called directly
+	os.Args = append([]string{os.Args[0]}, req.Command...)
+	tail := []reflect.Value{reflect.ValueOf(rc.getTail(v))}
+	outv := v.Addr().MethodByName("Execute").Call(tail)
+	if err, ok := outv[0].Interface().(error); ok && err != nil {
+		return err
+	}
+	return nil
+}
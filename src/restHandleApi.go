@@ -2,22 +2,20 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"reflect"
 	"strings"
-	"time"
-
-	flags "github.com/rglonek/jeddevdk-goflags"
 )
 
 /*
-curl -X POST http://127.0.0.1:3030/attach/shell -d '{"Tail":["ls"]}'
+curl -X POST 'http://127.0.0.1:3030/attach/shell' -d '{"Tail":["ls"]}'
 curl -vX POST http://127.0.0.1:3030/cluster/create -d '{}'
+curl -vX POST 'http://127.0.0.1:3030/cluster/create?async=false' -d '{}'
 curl -vX POST http://127.0.0.1:3030/cluster/destroy -d '{"Docker":{"Force":true}}'
 /attach/shell/help
 /help (same as / or nothing)
@@ -29,47 +27,45 @@ print json struct representing data
 for each json struct item, print the item and the description
 */
 
-var apiQueue = make(chan apiQueueItem, 1024)
-
-type apiQueueItem struct {
-	w      http.ResponseWriter
-	r      *http.Request
-	finish chan int
-}
-
 func (c *restCmd) handleApi(w http.ResponseWriter, r *http.Request) {
-	f := make(chan int, 1)
-	apiQueue <- apiQueueItem{
-		w:      w,
-		r:      r,
-		finish: f,
-	}
-	<-f
-}
-
-func (c *restCmd) handleApiDo() {
-	for {
-		c.handleApiDoLoop()
-	}
-}
-
-func (c *restCmd) handleApiDoLoop() {
-	queueItem := <-apiQueue
-	w := queueItem.w
-	r := queueItem.r
-	defer close(queueItem.finish)
 	urlpath := strings.Trim(r.URL.Path, "/")
+	user := getRestUser(r)
 	if urlpath == "quit" {
-		os.Exit(0)
+		if !user.allowed(urlpath) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		log.Print("Quit requested, shutting down")
+		w.Write([]byte("OK\n"))
+		go c.srv.Shutdown(context.Background())
+		return
 	}
 	if strings.HasSuffix(urlpath, "/help") || urlpath == "help" {
+		helpPath := strings.TrimSuffix(strings.TrimSuffix(urlpath, "help"), "/")
+		if apiCommandHidden(c.apiCommands, helpPath) {
+			http.Error(w, "command not found", http.StatusNotFound)
+			return
+		}
+		if !user.visible(helpPath) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
 		c.handleHelp(w, r)
 		return
 	}
 
+	if apiCommandHidden(c.apiCommands, urlpath) {
+		http.Error(w, "command not found", http.StatusNotFound)
+		return
+	}
+	if !user.visible(urlpath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
 	subcommands := false
 	for _, command := range c.apiCommands {
-		if !strings.HasSuffix(command.path, "/help") && strings.HasPrefix(command.path, urlpath) && len(command.path) > len(urlpath) {
+		if !strings.HasSuffix(command.path, "/help") && !apiCommandHidden(c.apiCommands, command.path) && user.visible(command.path) && strings.HasPrefix(command.path, urlpath) && len(command.path) > len(urlpath) {
 			fmt.Fprintf(w, command.path+"\n")
 			subcommands = true
 		}
@@ -79,34 +75,12 @@ func (c *restCmd) handleApiDoLoop() {
 	}
 
 	// command = []string{"xdr","connect"}
-	// handle and parse payload to command struct
-	// execute command struct .Execute
-	pr, pw, err := os.Pipe()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// validate payload against a copy of the command struct, the job itself runs in a child process
+	command := strings.Split(urlpath, "/")
+	if !user.allowed(urlpath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
-	os.Stdout = pw
-	os.Stderr = pw
-	log.SetOutput(pw)
-	buf := new(bytes.Buffer)
-	defer func() {
-		os.Stdout = c.stdout
-		os.Stderr = c.stderr
-		log.SetOutput(c.logout)
-	}()
-	defer pr.Close()
-	defer pw.Close()
-	go c.copy(buf, pr)
-
-	command := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	c.resetBools()
-	a.parser = flags.NewParser(a.opts, flags.HelpFlag|flags.PassDoubleDash)
-	a.iniParser = flags.NewIniParser(a.parser)
-	a.parser.ParseArgs([]string{})
-	a.parseFile()
-	a.parser.ParseArgs([]string{})
-
 	keys := []string{}
 	keyField := reflect.ValueOf(a.opts).Elem()
 	v, err := c.findCommand(keyField, strings.Join(keys, "."), "", []string{}, command)
@@ -114,6 +88,10 @@ func (c *restCmd) handleApiDoLoop() {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if v == nil {
+		http.Error(w, "command not found", http.StatusNotFound)
+		return
+	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -123,37 +101,62 @@ func (c *restCmd) handleApiDoLoop() {
 	if string(body) == "" {
 		body = []byte("{}")
 	}
+	nv := reflect.New(v.Type())
 	dec := json.NewDecoder(bytes.NewReader(body))
 	dec.DisallowUnknownFields()
-	err = dec.Decode(v.Addr().Interface())
+	err = dec.Decode(nv.Interface())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	if command[0] == "attach" {
-		if len(c.getTail(v)) == 0 {
+		nve := nv.Elem()
+		if len(c.getTail(&nve)) == 0 {
 			http.Error(w, "Tail is not optional for attach commands via the rest api", http.StatusBadRequest)
 			return
 		}
 	}
 
-	if len(command) == 2 && command[0] == "config" && command[1] == "backend" && strings.Contains(string(body), "\"Type\"") {
-		a.opts.Config.Backend.typeSet = "yes"
-	} else {
-		a.opts.Config.Backend.typeSet = ""
+	// a followed log never finishes and would hold a job slot forever
+	if ls, ok := nv.Interface().(*logsShowCmd); ok && ls.Follow {
+		http.Error(w, "Follow is not supported for logs show via the rest api", http.StatusBadRequest)
+		return
+	}
+
+	j, err := c.submitJob(command, body, user.name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !restIsAsync(r, j.ReadOnly) {
+		c.waitJob(w, r, j)
+		return
 	}
-	c.apiRunCommand(v, w, buf)
+	out, err := j.json()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(out)
 }
 
-func (c *restCmd) copy(buf *bytes.Buffer, pr *os.File) {
-	for {
-		_, err := io.Copy(buf, pr)
-		if err != nil {
-			return
-		}
-		time.Sleep(10 * time.Millisecond)
+// restIsAsync decides whether the job details are returned straight away, instead of waiting for the command output;
+// commands which change anything are asynchronous by default, read-only commands wait; ?async=true|false or the 'Prefer: respond-async' header override the default
+func restIsAsync(r *http.Request, readOnly bool) bool {
+	switch r.URL.Query().Get("async") {
+	case "true", "1", "yes":
+		return true
+	case "false", "0", "no":
+		return false
+	}
+	if strings.Contains(r.Header.Get("Prefer"), "respond-async") {
+		return true
 	}
+	return !readOnly
 }
 
 func (c *restCmd) getTail(v *reflect.Value) []string {
@@ -164,24 +167,6 @@ func (c *restCmd) getTail(v *reflect.Value) []string {
 	return tail.Interface().([]string)
 }
 
-func (c *restCmd) apiRunCommand(v *reflect.Value, w http.ResponseWriter, buf *bytes.Buffer) {
-	// run the execute command and stream results back to response.data, if error from command, also set response.err
-	tailv := c.getTail(v)
-	tail := []reflect.Value{reflect.ValueOf(tailv)}
-	outv := v.Addr().MethodByName("Execute").Call(tail)
-	out := outv[0].Interface()
-	switch out.(type) {
-	case error:
-		w.WriteHeader(http.StatusInternalServerError)
-	}
-	time.Sleep(20 * time.Millisecond)
-	io.Copy(w, buf)
-	switch err := out.(type) {
-	case error:
-		w.Write([]byte(err.Error()))
-	}
-}
-
 func (c *restCmd) findCommand(keyField reflect.Value, start string, tags reflect.StructTag, tagStack []string, command []string) (v *reflect.Value, err error) {
 	tagCommand := tags.Get("command")
 	if tagCommand != "" {
@@ -16,11 +16,12 @@ func (c *restCmd) handleHelp(w http.ResponseWriter, r *http.Request) {
 	if urlpath == "help" {
 		urlpath = ""
 	}
+	user := getRestUser(r)
 	comms := []apiCommand{}
 	maxlen := 7
 	desclen := 11
 	for _, command := range c.apiCommands {
-		if !strings.HasSuffix(command.path, "/help") && strings.HasPrefix(command.path, urlpath) && len(command.path) > len(urlpath) && !strings.Contains(strings.Trim(strings.TrimPrefix(command.path, urlpath), "/"), "/") {
+		if !strings.HasSuffix(command.path, "/help") && !apiCommandHidden(c.apiCommands, command.path) && user.visible(command.path) && strings.HasPrefix(command.path, urlpath) && len(command.path) > len(urlpath) && !strings.Contains(strings.Trim(strings.TrimPrefix(command.path, urlpath), "/"), "/") {
 			comms = append(comms, command)
 			if len(command.path)+1 > maxlen {
 				maxlen = len(command.path) + 1
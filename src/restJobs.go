@@ -0,0 +1,464 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bestmethod/inslice"
+	"github.com/google/uuid"
+)
+
+/*
+curl -X POST http://127.0.0.1:3030/cluster/list                                      # read-only: wait and return output
+curl -X POST http://127.0.0.1:3030/cluster/create -d '{"NodeCount":2}'               # returns job json, status 202
+curl -X POST 'http://127.0.0.1:3030/cluster/create?async=false' -d '{}'              # wait and return output
+curl -X POST -H 'Prefer: respond-async' http://127.0.0.1:3030/cluster/list           # same as ?async=true
+curl http://127.0.0.1:3030/jobs                                                      # list jobs
+curl http://127.0.0.1:3030/jobs/{id}                                                 # job status
+curl -N http://127.0.0.1:3030/jobs/{id}/log                                          # stream job output until it finishes
+curl -N -H 'Accept: text/event-stream' http://127.0.0.1:3030/jobs/{id}/log
+curl -X DELETE http://127.0.0.1:3030/jobs/{id}                                       # cancel job
+*/
+
+// commands which do not modify anything and may therefore run in parallel with each other and with the running job
+var restReadOnlyCommands = []string{
+	"aerospike/status",
+	"agi/details",
+	"agi/list",
+	"agi/status",
+	"apply/plan",
+	"client/list",
+	"cluster/list",
+	"config/aws/list-security-groups",
+	"config/aws/list-subnets",
+	"config/docker/list-networks",
+	"config/gcp/list-firewall-rules",
+	"installer/list-versions",
+	"inventory/instance-types",
+	"inventory/list",
+	"logs/show", // --follow is rejected by handleApi
+	"net/list",
+	"roster/show",
+	"template/list",
+	"version",
+	"volume/list",
+}
+
+var (
+	restJobQueued    = "queued"
+	restJobRunning   = "running"
+	restJobSucceeded = "succeeded"
+	restJobFailed    = "failed"
+	restJobCancelled = "cancelled"
+)
+
+type restJobs struct {
+	lock     sync.Mutex
+	jobs     map[string]*restJob
+	queue    chan *restJob
+	readOnly chan int
+	expiry   time.Duration
+}
+
+type restJob struct {
+	ID       string
+	Command  string
+	ReadOnly bool
+	Owner    string `json:",omitempty"`
+	Status   string
+	Error    string `json:",omitempty"`
+	Created  time.Time
+	Started  *time.Time `json:",omitempty"`
+	Finished *time.Time `json:",omitempty"`
+	lock     sync.Mutex
+	request  []byte
+	log      *restJobLog
+	done     chan struct{}
+	proc     *os.Process
+}
+
+// the request passed to the `rest-api exec` child process on stdin
+type restJobRequest struct {
+	Command []string
+	Payload json.RawMessage
+}
+
+// restJobLog collects job output and allows multiple readers to follow it while it is being written
+// only the last restJobLogMaxSize bytes are kept; offsets count all bytes ever written
+type restJobLog struct {
+	lock    sync.Mutex
+	data    []byte
+	dropped int
+	closed  bool
+	changed chan struct{}
+}
+
+// restJobLogMaxSize caps the output kept in memory per job; replaced in tests
+var restJobLogMaxSize = 4 * 1024 * 1024
+
+// restJobCommand returns the child process which runs a job; replaced in tests
+var restJobCommand = func() (*exec.Cmd, error) {
+	exe, err := findExec()
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command(exe, "rest-api", "exec"), nil
+}
+
+func newRestJobLog() *restJobLog {
+	return &restJobLog{
+		changed: make(chan struct{}),
+	}
+}
+
+func (l *restJobLog) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.data = append(l.data, p...)
+	if over := len(l.data) - restJobLogMaxSize; over > 0 {
+		l.data = append([]byte{}, l.data[over:]...)
+		l.dropped += over
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+	return len(p), nil
+}
+
+func (l *restJobLog) Close() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		return
+	}
+	l.closed = true
+	close(l.changed)
+}
+
+// get returns data past the given offset, the offset to read from next, whether the log is complete, and a channel which closes on next change
+// if the output past the offset has already been dropped, data starts at the oldest byte still kept
+func (l *restJobLog) get(offset int) (data []byte, next int, closed bool, changed chan struct{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if offset < l.dropped {
+		offset = l.dropped
+	}
+	if offset-l.dropped < len(l.data) {
+		data = l.data[offset-l.dropped:]
+	}
+	return data, l.dropped + len(l.data), l.closed, l.changed
+}
+
+func (c *restCmd) startJobs() {
+	if c.ReadOnlyConcurrency < 1 {
+		c.ReadOnlyConcurrency = 1
+	}
+	c.jobs = &restJobs{
+		jobs:     make(map[string]*restJob),
+		queue:    make(chan *restJob, 1024),
+		readOnly: make(chan int, c.ReadOnlyConcurrency),
+		expiry:   c.JobExpiry,
+	}
+	http.HandleFunc("/jobs", c.handleJobs)
+	http.HandleFunc("/jobs/", c.handleJobs)
+	go func() {
+		for j := range c.jobs.queue {
+			c.runJob(j)
+		}
+	}()
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			c.jobs.expire()
+		}
+	}()
+}
+
+// expire forgets finished jobs older than the configured expiry
+func (j *restJobs) expire() {
+	if j.expiry == 0 {
+		return
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	for id, job := range j.jobs {
+		job.lock.Lock()
+		if job.Finished != nil && time.Since(*job.Finished) > j.expiry {
+			delete(j.jobs, id)
+		}
+		job.lock.Unlock()
+	}
+}
+
+func (j *restJobs) get(id string) *restJob {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.jobs[id]
+}
+
+// submitJob registers a new job and queues it; read-only jobs bypass the queue, limited by the read-only concurrency
+func (c *restCmd) submitJob(command []string, payload []byte, owner string) (*restJob, error) {
+	request, err := json.Marshal(restJobRequest{
+		Command: command,
+		Payload: payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+	path := strings.Join(command, "/")
+	isReadOnly := inslice.HasString(restReadOnlyCommands, path)
+	j := &restJob{
+		ID:       uuid.New().String(),
+		Command:  path,
+		ReadOnly: isReadOnly,
+		Owner:    owner,
+		Status:   restJobQueued,
+		Created:  time.Now(),
+		request:  request,
+		log:      newRestJobLog(),
+		done:     make(chan struct{}),
+	}
+	c.jobs.lock.Lock()
+	c.jobs.jobs[j.ID] = j
+	c.jobs.lock.Unlock()
+	if !isReadOnly {
+		c.jobs.queue <- j
+		return j, nil
+	}
+	go func() {
+		c.jobs.readOnly <- 1
+		c.runJob(j)
+		<-c.jobs.readOnly
+	}()
+	return j, nil
+}
+
+// runJob executes the job in a child `rest-api exec` process, so that jobs do not share options, output or backend state
+func (c *restCmd) runJob(j *restJob) {
+	j.lock.Lock()
+	if j.Status == restJobCancelled {
+		j.lock.Unlock()
+		return
+	}
+	now := time.Now()
+	j.Started = &now
+	j.Status = restJobRunning
+	cmd, err := restJobCommand()
+	if err != nil {
+		j.finish(err)
+		j.lock.Unlock()
+		return
+	}
+	cmd.Stdin = bytes.NewReader(j.request)
+	cmd.Stdout = j.log
+	cmd.Stderr = j.log
+	err = cmd.Start()
+	if err != nil {
+		j.finish(err)
+		j.lock.Unlock()
+		return
+	}
+	j.proc = cmd.Process
+	j.lock.Unlock()
+	log.Printf("Job %s: running %s", j.ID, j.Command)
+	err = cmd.Wait()
+	j.lock.Lock()
+	j.finish(err)
+	log.Printf("Job %s: %s", j.ID, j.Status)
+	j.lock.Unlock()
+}
+
+// finish marks the job as done; must be called with the job lock held
+func (j *restJob) finish(err error) {
+	now := time.Now()
+	j.Finished = &now
+	if j.Status != restJobCancelled {
+		if err != nil {
+			j.Status = restJobFailed
+			j.Error = err.Error()
+		} else {
+			j.Status = restJobSucceeded
+		}
+	}
+	j.log.Close()
+	close(j.done)
+}
+
+// cancel removes a queued job, or interrupts a running one, killing it if it does not exit within the timeout
+func (j *restJob) cancel(timeout time.Duration) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	switch j.Status {
+	case restJobQueued:
+		j.Status = restJobCancelled
+		j.finish(nil)
+		return nil
+	case restJobRunning:
+		j.Status = restJobCancelled
+		fmt.Fprintf(j.log, "\nJob cancelled\n")
+		proc := j.proc
+		if runtime.GOOS == "windows" || proc.Signal(os.Interrupt) != nil {
+			return proc.Kill()
+		}
+		go func() {
+			select {
+			case <-j.done:
+			case <-time.After(timeout):
+				proc.Kill()
+			}
+		}()
+		return nil
+	default:
+		return fmt.Errorf("job already %s", j.Status)
+	}
+}
+
+func (j *restJob) json() ([]byte, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return json.MarshalIndent(j, "", "  ")
+}
+
+func (c *restCmd) handleJobs(w http.ResponseWriter, r *http.Request) {
+	urlpath := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(urlpath) == 1 {
+		c.handleJobsList(w, r)
+		return
+	}
+	j := c.jobs.get(urlpath[1])
+	if j == nil || !getRestUser(r).owns(j) {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	if len(urlpath) == 3 && urlpath[2] == "log" && r.Method == http.MethodGet {
+		c.handleJobLog(w, r, j)
+		return
+	}
+	if len(urlpath) != 2 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		out, err := j.json()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(out)
+	case http.MethodDelete:
+		err := j.cancel(c.CancelTimeout)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		out, err := j.json()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(out)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (c *restCmd) handleJobsList(w http.ResponseWriter, r *http.Request) {
+	user := getRestUser(r)
+	c.jobs.lock.Lock()
+	jobs := []*restJob{}
+	for _, j := range c.jobs.jobs {
+		if user.owns(j) {
+			jobs = append(jobs, j)
+		}
+	}
+	c.jobs.lock.Unlock()
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Created.Before(jobs[j].Created)
+	})
+	out := []json.RawMessage{}
+	for _, j := range jobs {
+		jj, err := j.json()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out = append(out, jj)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(out)
+}
+
+// handleJobLog streams the job output, chunked, or as server-sent events if requested by the client, until the job finishes
+func (c *restCmd) handleJobLog(w http.ResponseWriter, r *http.Request, j *restJob) {
+	isSSE := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	if isSSE {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	flusher, _ := w.(http.Flusher)
+	offset := 0
+	for {
+		data, next, closed, changed := j.log.get(offset)
+		offset = next
+		if len(data) > 0 {
+			if isSSE {
+				for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+					fmt.Fprintf(w, "data: %s\n\n", line)
+				}
+			} else {
+				w.Write(data)
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if closed {
+			if isSSE {
+				out, _ := j.json()
+				fmt.Fprintf(w, "event: done\ndata: %s\n\n", strings.ReplaceAll(string(out), "\n", ""))
+			}
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// waitJob provides the default synchronous behaviour: wait for the job to finish and return its output
+func (c *restCmd) waitJob(w http.ResponseWriter, r *http.Request, j *restJob) {
+	select {
+	case <-j.done:
+	case <-r.Context().Done():
+		return
+	}
+	j.lock.Lock()
+	status := j.Status
+	errString := j.Error
+	j.lock.Unlock()
+	if status != restJobSucceeded {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	data, _, _, _ := j.log.get(0)
+	w.Write(data)
+	io.WriteString(w, errString)
+}
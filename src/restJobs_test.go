@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRestJobHelper is not a real test; it is the fake command executed by jobs in the tests below
+func TestRestJobHelper(t *testing.T) {
+	if os.Getenv("AEROLAB_TEST_JOB_HELPER") != "1" {
+		return
+	}
+	req := restJobRequest{}
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		os.Exit(2)
+	}
+	switch req.Command[0] {
+	case "sleep":
+		fmt.Println("sleeping")
+		time.Sleep(time.Minute)
+	case "fail":
+		fmt.Println("failing")
+		os.Exit(1)
+	}
+	fmt.Printf("hello %s\n", strings.Join(req.Command, "/"))
+	os.Exit(0)
+}
+
+func restTestJobs(t *testing.T) *restCmd {
+	t.Helper()
+	orig := restJobCommand
+	restJobCommand = func() (*exec.Cmd, error) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestRestJobHelper$")
+		cmd.Env = append(os.Environ(), "AEROLAB_TEST_JOB_HELPER=1")
+		return cmd, nil
+	}
+	t.Cleanup(func() { restJobCommand = orig })
+	c := &restCmd{
+		CancelTimeout: 5 * time.Second,
+		apiCommands: []apiCommand{
+			{path: "version"},
+			{path: "rest-api/exec", hidden: true},
+			{path: "rest-api/openapi"},
+		},
+	}
+	c.jobs = &restJobs{
+		jobs:     make(map[string]*restJob),
+		queue:    make(chan *restJob, 16),
+		readOnly: make(chan int, 2),
+	}
+	go func() {
+		for j := range c.jobs.queue {
+			c.runJob(j)
+		}
+	}()
+	t.Cleanup(func() { close(c.jobs.queue) })
+	return c
+}
+
+func restWaitJob(t *testing.T, j *restJob) {
+	t.Helper()
+	select {
+	case <-j.done:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("job %s did not finish", j.Command)
+	}
+}
+
+func restJobStatus(j *restJob) string {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.Status
+}
+
+func TestRestJobSubmit(t *testing.T) {
+	c := restTestJobs(t)
+	j, err := c.submitJob([]string{"cluster", "list"}, []byte("{}"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !j.ReadOnly {
+		t.Error("cluster/list must be read-only")
+	}
+	restWaitJob(t, j)
+	if restJobStatus(j) != restJobSucceeded || j.Started == nil || j.Finished == nil {
+		t.Fatalf("unexpected job %+v", j)
+	}
+	if data, _, closed, _ := j.log.get(0); !closed || string(data) != "hello cluster/list\n" {
+		t.Errorf("unexpected log %q closed=%t", string(data), closed)
+	}
+
+	j, _ = c.submitJob([]string{"fail"}, nil, "")
+	restWaitJob(t, j)
+	if restJobStatus(j) != restJobFailed || j.Error == "" {
+		t.Errorf("expected failed job with error, got %+v", j)
+	}
+}
+
+func TestRestJobCancel(t *testing.T) {
+	c := restTestJobs(t)
+	running, _ := c.submitJob([]string{"sleep"}, nil, "")
+	queued, _ := c.submitJob([]string{"cluster", "create"}, nil, "")
+	for i := 0; restJobStatus(running) != restJobRunning; i++ {
+		if i > 300 {
+			t.Fatal("job did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if restJobStatus(queued) != restJobQueued {
+		t.Fatalf("second job must wait in the queue, got %s", restJobStatus(queued))
+	}
+	if err := queued.cancel(c.CancelTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if restJobStatus(queued) != restJobCancelled {
+		t.Errorf("queued job not cancelled: %s", restJobStatus(queued))
+	}
+	if err := running.cancel(c.CancelTimeout); err != nil {
+		t.Fatal(err)
+	}
+	restWaitJob(t, running)
+	if restJobStatus(running) != restJobCancelled {
+		t.Errorf("running job not cancelled: %s", restJobStatus(running))
+	}
+	if err := running.cancel(c.CancelTimeout); err == nil {
+		t.Error("expected error cancelling a finished job")
+	}
+	if queued.Started != nil {
+		t.Error("cancelled queued job must not run")
+	}
+}
+
+func TestRestJobLogStream(t *testing.T) {
+	c := restTestJobs(t)
+	j, _ := c.submitJob([]string{"version"}, nil, "")
+	restWaitJob(t, j)
+
+	rec := httptest.NewRecorder()
+	c.handleJobLog(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+j.ID+"/log", nil), j)
+	if rec.Body.String() != "hello version\n" {
+		t.Errorf("unexpected plain log %q", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/jobs/"+j.ID+"/log", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	c.handleJobLog(rec, req, j)
+	body := rec.Body.String()
+	if rec.Header().Get("Content-Type") != "text/event-stream" || !strings.HasPrefix(body, "data: hello version\n\nevent: done\ndata: {") || !strings.Contains(body, restJobSucceeded) {
+		t.Errorf("unexpected event stream %q", body)
+	}
+}
+
+func TestRestJobLogCap(t *testing.T) {
+	defer func(size int) { restJobLogMaxSize = size }(restJobLogMaxSize)
+	restJobLogMaxSize = 8
+	l := newRestJobLog()
+	l.Write([]byte("0123"))
+	data, next, _, _ := l.get(0)
+	if string(data) != "0123" || next != 4 {
+		t.Fatalf("unexpected log %q next=%d", string(data), next)
+	}
+	l.Write([]byte("456789ab"))
+	data, next, _, _ = l.get(next)
+	if string(data) != "456789ab" || next != 12 {
+		t.Errorf("expected unread data to be returned, got %q next=%d", string(data), next)
+	}
+	l.Write([]byte("cdef"))
+	data, next, _, _ = l.get(0)
+	if string(data) != "89abcdef" || next != 16 {
+		t.Errorf("expected only the last 8 bytes, got %q next=%d", string(data), next)
+	}
+}
+
+func TestRestJobExpiry(t *testing.T) {
+	c := restTestJobs(t)
+	finished, _ := c.submitJob([]string{"version"}, nil, "")
+	restWaitJob(t, finished)
+	running, _ := c.submitJob([]string{"sleep"}, nil, "")
+	c.jobs.expire()
+	if c.jobs.get(finished.ID) == nil {
+		t.Fatal("jobs must be kept when expiry is disabled")
+	}
+	c.jobs.expiry = time.Millisecond
+	time.Sleep(5 * time.Millisecond)
+	c.jobs.expire()
+	if c.jobs.get(finished.ID) != nil {
+		t.Error("finished job not expired")
+	}
+	if c.jobs.get(running.ID) == nil {
+		t.Error("unfinished job must not expire")
+	}
+	running.cancel(c.CancelTimeout)
+	restWaitJob(t, running)
+}
+
+func TestRestApiSyncAsync(t *testing.T) {
+	c := restTestJobs(t)
+	rec := httptest.NewRecorder()
+	c.handleApi(rec, httptest.NewRequest(http.MethodPost, "/version", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello version\n" {
+		t.Errorf("expected synchronous output by default, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/version?async=true", nil),
+		httptest.NewRequest(http.MethodPost, "/version", nil),
+	} {
+		if req.URL.RawQuery == "" {
+			req.Header.Set("Prefer", "respond-async")
+		}
+		rec = httptest.NewRecorder()
+		c.handleApi(rec, req)
+		j := &restJob{}
+		if rec.Code != http.StatusAccepted || json.Unmarshal(rec.Body.Bytes(), j) != nil || rec.Header().Get("Location") != "/jobs/"+j.ID {
+			t.Fatalf("expected job details, got %d %q", rec.Code, rec.Body.String())
+		}
+		restWaitJob(t, c.jobs.get(j.ID))
+	}
+
+	// commands which change anything return the job straight away by default, waiting is opt-in
+	rec = httptest.NewRecorder()
+	c.handleApi(rec, httptest.NewRequest(http.MethodPost, "/cluster/create", nil))
+	j := &restJob{}
+	if rec.Code != http.StatusAccepted || json.Unmarshal(rec.Body.Bytes(), j) != nil || j.ReadOnly {
+		t.Fatalf("expected async job details by default, got %d %q", rec.Code, rec.Body.String())
+	}
+	restWaitJob(t, c.jobs.get(j.ID))
+	rec = httptest.NewRecorder()
+	c.handleApi(rec, httptest.NewRequest(http.MethodPost, "/cluster/create?async=false", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello cluster/create\n" {
+		t.Errorf("expected synchronous output with async=false, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	c.handleApi(rec, httptest.NewRequest(http.MethodPost, "/logs/show", strings.NewReader(`{"Follow":true}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("following logs must be rejected, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	c.handleApi(rec, httptest.NewRequest(http.MethodPost, "/rest-api/exec", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("hidden command must not be reachable, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	c.handleApi(rec, httptest.NewRequest(http.MethodGet, "/rest-api", nil))
+	if strings.Contains(rec.Body.String(), "exec") || !strings.Contains(rec.Body.String(), "rest-api/openapi") {
+		t.Errorf("unexpected subcommand list %q", rec.Body.String())
+	}
+}
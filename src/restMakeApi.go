@@ -11,6 +11,7 @@ func (c *restCmd) makeApi(keyField reflect.Value, start string, tags reflect.Str
 	defer func() {
 		http.HandleFunc("/quit/", c.handleApi)
 		http.HandleFunc("/quit", c.handleApi)
+		http.HandleFunc("/openapi.json", c.handleOpenApi)
 		c.apiCommands = append(c.apiCommands, apiCommand{
 			path:        "quit",
 			description: "Exit aerolab rest service",
@@ -29,6 +30,16 @@ func (c *restCmd) makeApi(keyField reflect.Value, start string, tags reflect.Str
 	}
 }
 
+// apiCommandHidden checks whether the path is a hidden command, or a subcommand of one; hidden commands are internal and not exposed by the api
+func apiCommandHidden(comms []apiCommand, path string) bool {
+	for _, comm := range comms {
+		if comm.hidden && (path == comm.path || strings.HasPrefix(path, comm.path+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *restCmd) getCommands(keyField reflect.Value, start string, ret chan apiCommand, tags reflect.StructTag) {
 	defer close(ret)
 	c.getCommandsNext(keyField, start, ret, tags, []string{})
@@ -71,5 +82,6 @@ func (c *restCmd) getCommandsNext(keyField reflect.Value, start string, ret chan
 	ret <- apiCommand{
 		path:        strings.Join(tagStack, "/"),
 		description: tags.Get("description"),
+		hidden:      tags.Get("hidden") == "true",
 	}
 }
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/bestmethod/inslice"
+	flags "github.com/rglonek/jeddevdk-goflags"
+)
+
+// the OpenAPI document is generated using the same reflection as makeApi: every command without subcommands becomes a POST path, its options the request body
+
+type restOpenApiCmd struct {
+	Help helpCmd `command:"help" subcommands-optional:"true" description:"Print help"`
+}
+
+func (c *restOpenApiCmd) Execute(args []string) error {
+	if earlyProcessNoBackend(args) {
+		return nil
+	}
+	doc, err := a.opts.Rest.openApi(&restUser{})
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func (c *restCmd) handleOpenApi(w http.ResponseWriter, r *http.Request) {
+	doc, err := c.openApi(getRestUser(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(doc)
+}
+
+// openApi builds the OpenAPI 3 document, listing only the commands the given user is allowed to execute
+func (c *restCmd) openApi(user *restUser) (map[string]interface{}, error) {
+	// parse an empty command line into a new set of options, so that the schema contains the default values
+	var na = &aerolab{
+		opts: new(commands),
+	}
+	na.parser = flags.NewParser(na.opts, flags.HelpFlag|flags.PassDoubleDash)
+	na.iniParser = flags.NewIniParser(na.parser)
+	na.parseFile()
+	na.parser.ParseArgs([]string{})
+	keyField := reflect.ValueOf(na.opts).Elem()
+
+	comms := []apiCommand{}
+	ret := make(chan apiCommand, 1)
+	go c.getCommands(keyField, "", ret, "")
+	for {
+		val, ok := <-ret
+		if !ok {
+			break
+		}
+		comms = append(comms, val)
+	}
+
+	errorResponse := map[string]interface{}{
+		"description": "error message",
+		"content": map[string]interface{}{
+			"text/plain": map[string]interface{}{
+				"schema": map[string]interface{}{"type": "string"},
+			},
+		},
+	}
+	jobResponse := map[string]interface{}{
+		"description": "job details",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": "#/components/schemas/Job"},
+			},
+		},
+	}
+	paths := make(map[string]interface{})
+	for _, comm := range comms {
+		if comm.path == "help" || strings.HasSuffix(comm.path, "/help") || !user.allowed(comm.path) || apiCommandHidden(comms, comm.path) {
+			continue
+		}
+		isLeaf := true
+		for _, sub := range comms {
+			if strings.HasPrefix(sub.path, comm.path+"/") && !strings.HasSuffix(sub.path, "/help") {
+				isLeaf = false
+				break
+			}
+		}
+		if !isLeaf {
+			continue
+		}
+		v, err := c.findCommand(keyField, "", "", []string{}, strings.Split(comm.path, "/"))
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		description := "returns the job details straight away with 202 Accepted; set async to false to wait for the command to finish and return its output instead"
+		asyncDescription := "set to false to wait for the command to finish and return its output; default: true"
+		if inslice.HasString(restReadOnlyCommands, comm.path) {
+			description = "read-only; waits for the command to finish and returns its output, unless async is requested"
+			asyncDescription = "set to true to return the job details straight away instead of waiting for the command to finish; same as sending the 'Prefer: respond-async' header; default: false"
+		}
+		paths["/"+comm.path] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"operationId": strings.NewReplacer("/", "_", "-", "_").Replace(comm.path),
+				"summary":     comm.description,
+				"description": description,
+				"tags":        []string{strings.Split(comm.path, "/")[0]},
+				"parameters": []interface{}{
+					map[string]interface{}{
+						"name":        "async",
+						"in":          "query",
+						"description": asyncDescription,
+						"schema":      map[string]interface{}{"type": "boolean"},
+					},
+				},
+				"requestBody": map[string]interface{}{
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": c.openApiSchema(*v, ""),
+						},
+					},
+				},
+				"responses": map[string]interface{}{
+					"202": map[string]interface{}{
+						"description": "job details, unless the command waited for its output",
+						"content":     jobResponse["content"],
+					},
+					"200": map[string]interface{}{
+						"description": "command output, if the command waited",
+						"content": map[string]interface{}{
+							"text/plain": map[string]interface{}{
+								"schema": map[string]interface{}{"type": "string"},
+							},
+						},
+					},
+					"400": errorResponse,
+					"401": errorResponse,
+					"403": errorResponse,
+					"404": errorResponse,
+					"500": errorResponse,
+				},
+			},
+		}
+	}
+
+	jobIdParameter := map[string]interface{}{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "string"},
+	}
+	paths["/jobs"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "jobs_list",
+			"summary":     "List jobs",
+			"tags":        []string{"jobs"},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "list of jobs",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type":  "array",
+								"items": map[string]interface{}{"$ref": "#/components/schemas/Job"},
+							},
+						},
+					},
+				},
+				"401": errorResponse,
+			},
+		},
+	}
+	paths["/jobs/{id}"] = map[string]interface{}{
+		"parameters": []interface{}{jobIdParameter},
+		"get": map[string]interface{}{
+			"operationId": "jobs_get",
+			"summary":     "Get job status",
+			"tags":        []string{"jobs"},
+			"responses": map[string]interface{}{
+				"200": jobResponse,
+				"401": errorResponse,
+				"404": errorResponse,
+			},
+		},
+		"delete": map[string]interface{}{
+			"operationId": "jobs_cancel",
+			"summary":     "Cancel a queued or running job",
+			"tags":        []string{"jobs"},
+			"responses": map[string]interface{}{
+				"200": jobResponse,
+				"401": errorResponse,
+				"404": errorResponse,
+				"409": errorResponse,
+			},
+		},
+	}
+	paths["/jobs/{id}/log"] = map[string]interface{}{
+		"parameters": []interface{}{jobIdParameter},
+		"get": map[string]interface{}{
+			"operationId": "jobs_log",
+			"summary":     "Stream job output until the job finishes",
+			"tags":        []string{"jobs"},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "job output; sent as server-sent events if requested using the Accept header",
+					"content": map[string]interface{}{
+						"text/plain": map[string]interface{}{
+							"schema": map[string]interface{}{"type": "string"},
+						},
+						"text/event-stream": map[string]interface{}{
+							"schema": map[string]interface{}{"type": "string"},
+						},
+					},
+				},
+				"401": errorResponse,
+				"404": errorResponse,
+			},
+		},
+	}
+	if user.allowed("quit") {
+		paths["/quit"] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"operationId": "quit",
+				"summary":     "Exit aerolab rest service",
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{"description": "shutting down"},
+					"401": errorResponse,
+					"403": errorResponse,
+				},
+			},
+		}
+	}
+
+	jobSchema := c.openApiSchema(reflect.ValueOf(restJob{}), "")
+	jobSchema["properties"].(map[string]interface{})["Status"].(map[string]interface{})["enum"] = []string{restJobQueued, restJobRunning, restJobSucceeded, restJobFailed, restJobCancelled}
+	components := map[string]interface{}{
+		"schemas": map[string]interface{}{
+			"Job": jobSchema,
+		},
+	}
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "AeroLab REST API",
+			"version":     version,
+			"description": "Each command runs as a job. Commands which change anything, such as cluster/create, return the job straight away with 202 Accepted; add ?async=false to wait for the job to finish and return its output instead. Read-only commands, such as cluster/list, wait and return their output by default; add ?async=true or send the 'Prefer: respond-async' header to return the job straight away. Use the /jobs endpoints to follow and cancel jobs.",
+		},
+		"paths":      paths,
+		"components": components,
+	}
+	switch c.AuthType {
+	case "basic":
+		components["securitySchemes"] = map[string]interface{}{
+			"basicAuth": map[string]interface{}{"type": "http", "scheme": "basic"},
+		}
+		doc["security"] = []interface{}{map[string]interface{}{"basicAuth": []string{}}}
+	case "token":
+		components["securitySchemes"] = map[string]interface{}{
+			"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+		}
+		doc["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	}
+	return doc, nil
+}
+
+// openApiSchema returns the JSON schema of a value the way encoding/json would (un)marshal it, using the current value as the default
+func (c *restCmd) openApiSchema(keyField reflect.Value, tags reflect.StructTag) map[string]interface{} {
+	schema := make(map[string]interface{})
+	if description := tags.Get("description"); description != "" {
+		schema["description"] = description
+	}
+	if keyField.Type() == reflect.TypeOf(time.Duration(0)) {
+		schema["type"] = "integer"
+		schema["description"] = strings.TrimPrefix(tags.Get("description")+"; duration in nanoseconds", "; ")
+		if !keyField.IsZero() {
+			schema["default"] = keyField.Int()
+		}
+		return schema
+	}
+	if keyField.Type() == reflect.TypeOf(time.Time{}) {
+		schema["type"] = "string"
+		schema["format"] = "date-time"
+		return schema
+	}
+	switch keyField.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		schema["type"] = "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema["type"] = "integer"
+		schema["minimum"] = 0
+	case reflect.Float32, reflect.Float64:
+		schema["type"] = "number"
+	case reflect.String:
+		schema["type"] = "string"
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Slice, reflect.Array:
+		schema["type"] = "array"
+		schema["items"] = c.openApiSchema(reflect.New(keyField.Type().Elem()).Elem(), "")
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = c.openApiSchema(reflect.New(keyField.Type().Elem()).Elem(), "")
+	case reflect.Ptr:
+		return c.openApiSchema(reflect.New(keyField.Type().Elem()).Elem(), tags)
+	case reflect.Struct:
+		props := make(map[string]interface{})
+		c.openApiProperties(keyField, props)
+		schema["type"] = "object"
+		schema["properties"] = props
+		schema["additionalProperties"] = false
+		return schema
+	}
+	if keyField.Kind() != reflect.Ptr && !keyField.IsZero() {
+		schema["default"] = keyField.Interface()
+	}
+	return schema
+}
+
+// openApiProperties adds struct fields as schema properties, promoting fields of embedded structs as encoding/json does; subcommands are skipped
+func (c *restCmd) openApiProperties(keyField reflect.Value, props map[string]interface{}) {
+	for i := 0; i < keyField.NumField(); i++ {
+		field := keyField.Type().Field(i)
+		if field.Tag.Get("command") != "" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			c.openApiProperties(keyField.Field(i), props)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		props[name] = c.openApiSchema(keyField.Field(i), field.Tag)
+	}
+}
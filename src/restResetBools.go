@@ -12,7 +12,9 @@ func (c *restCmd) resetBools() {
 func (c *restCmd) resetBoolsDo(keyField reflect.Value, start string, tags reflect.StructTag) {
 	switch keyField.Type().Kind() {
 	case reflect.Bool:
-		keyField.SetBool(false)
+		if keyField.CanSet() {
+			keyField.SetBool(false)
+		}
 	case reflect.Struct:
 		for i := 0; i < keyField.NumField(); i++ {
 			fieldName := keyField.Type().Field(i).Name
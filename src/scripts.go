@@ -489,4 +489,10 @@ EOF
 chmod 755 /etc/init.d/aerospike
 `
 
+	// kubernetes templates are built using the local container engine
+	for k, v := range aerospikeInstallScript {
+		if strings.HasPrefix(k, "docker:") {
+			aerospikeInstallScript["kubernetes:"+strings.TrimPrefix(k, "docker:")] = v
+		}
+	}
 }
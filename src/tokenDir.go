@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// tokenFile is a file read from a token directory; name is the path relative to the directory, so that files of the same name in different subdirectories are told apart
+type tokenFile struct {
+	name     string
+	path     string
+	contents []byte
+}
+
+// tokenDir is a directory of token files, used by the rest api and the agi proxy
+type tokenDir struct {
+	path   string
+	logf   func(format string, v ...interface{})
+	reload func(files []tokenFile)
+}
+
+// read reads all token files in the directory and its subdirectories
+func (t *tokenDir) read() {
+	files := []tokenFile{}
+	err := filepath.Walk(t.path, func(fpath string, info fs.FileInfo, err error) error {
+		if err != nil {
+			t.logf("error on walk %s: %s", fpath, err)
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(t.path, fpath)
+		if err != nil {
+			t.logf("could not find name of token file %s: %s", fpath, err)
+			return nil
+		}
+		contents, err := os.ReadFile(fpath)
+		if err != nil {
+			t.logf("could not read token file %s: %s", fpath, err)
+			return nil
+		}
+		files = append(files, tokenFile{
+			name:     filepath.ToSlash(name),
+			path:     fpath,
+			contents: contents,
+		})
+		return nil
+	})
+	if err != nil {
+		t.logf("failed to read tokens: %s", err)
+		return
+	}
+	t.reload(files)
+}
+
+func (t *tokenDir) readInterval() {
+	for {
+		t.read()
+		time.Sleep(time.Minute)
+	}
+}
+
+// watch reads the tokens once a minute, and additionally whenever the token directory changes
+func (t *tokenDir) watch() {
+	go t.readInterval()
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.logf("fsnotify could not be started, tokens will not be dynamically monitored; switching to once-a-minute system: %s", err)
+		return
+	}
+	defer watcher.Close()
+	err = watcher.Add(t.path)
+	if err != nil {
+		t.logf("fsnotify could not add token path, tokens will not be dynamically monitored; switching to once-a-minute system: %s", err)
+		return
+	}
+	for {
+		select {
+		case _, ok := <-watcher.Events:
+			if !ok {
+				t.logf("fsnotify events error, tokens will not be dynamically monitored; switching to once-a-minute system")
+				return
+			}
+			t.read()
+		case err, ok := <-watcher.Errors:
+			t.logf("fsnotify watcher error, tokens will not be dynamically monitored; switching to once-a-minute system (ok:%t err:%s)", ok, err)
+			return
+		}
+	}
+}